@@ -4,8 +4,9 @@ package assets
 import "embed"
 
 const (
-	MySQLMigrationDir    = "migrations/mysql"
-	PostgresMigrationDir = "migrations/postgres"
+	MySQLMigrationDir     = "migrations/mysql"
+	PostgresMigrationDir  = "migrations/postgres"
+	SQLServerMigrationDir = "migrations/sqlserver"
 )
 
 // EmbedMigrations within the openfga binary.
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN allowed_object_types TEXT;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN allowed_object_types;
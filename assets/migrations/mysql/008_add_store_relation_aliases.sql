@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN relation_aliases TEXT;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN relation_aliases;
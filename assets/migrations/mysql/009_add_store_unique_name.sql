@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN unique_name VARCHAR(64);
+CREATE UNIQUE INDEX idx_store_unique_name ON store (unique_name);
+
+-- +goose Down
+DROP INDEX idx_store_unique_name ON store;
+ALTER TABLE store DROP COLUMN unique_name;
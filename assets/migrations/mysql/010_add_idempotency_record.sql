@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE idempotency_record (
+    store CHAR(26) NOT NULL,
+    idempotency_key VARCHAR(128) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (store, idempotency_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_record;
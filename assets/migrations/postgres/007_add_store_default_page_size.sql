@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN default_page_size INTEGER;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN default_page_size;
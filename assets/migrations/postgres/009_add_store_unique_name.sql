@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN unique_name TEXT;
+CREATE UNIQUE INDEX idx_store_unique_name ON store (unique_name);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_store_unique_name;
+ALTER TABLE store DROP COLUMN unique_name;
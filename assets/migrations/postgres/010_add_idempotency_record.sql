@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE idempotency_record (
+    store TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (store, idempotency_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_record;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN default_user_type TEXT;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN default_user_type;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN max_authorization_models INTEGER;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN max_authorization_models;
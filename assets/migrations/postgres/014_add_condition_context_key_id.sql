@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE tuple ADD COLUMN condition_context_key_id TEXT;
+ALTER TABLE changelog ADD COLUMN condition_context_key_id TEXT;
+
+-- +goose Down
+ALTER TABLE tuple DROP COLUMN condition_context_key_id;
+ALTER TABLE changelog DROP COLUMN condition_context_key_id;
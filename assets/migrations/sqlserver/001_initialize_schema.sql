@@ -0,0 +1,57 @@
+-- +goose Up
+CREATE TABLE tuple (
+    store CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    object_type VARCHAR(128) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    object_id VARCHAR(128) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    relation VARCHAR(50) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    _user VARCHAR(256) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    user_type VARCHAR(7) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    ulid CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    inserted_at DATETIME2 NOT NULL,
+    CONSTRAINT pk_tuple PRIMARY KEY (store, object_type, object_id, relation, _user)
+);
+
+CREATE UNIQUE INDEX idx_tuple_ulid ON tuple (ulid);
+
+CREATE TABLE authorization_model (
+    store CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    authorization_model_id CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    type VARCHAR(256) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    type_definition VARBINARY(MAX),
+    CONSTRAINT pk_authorization_model PRIMARY KEY (store, authorization_model_id, type)
+);
+
+CREATE TABLE store (
+    id CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    name VARCHAR(64) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    created_at DATETIME2 NOT NULL,
+    updated_at DATETIME2,
+    deleted_at DATETIME2,
+    CONSTRAINT pk_store PRIMARY KEY (id)
+);
+
+CREATE TABLE assertion (
+    store CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    authorization_model_id CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    assertions VARBINARY(MAX),
+    CONSTRAINT pk_assertion PRIMARY KEY (store, authorization_model_id)
+);
+
+CREATE TABLE changelog (
+    store CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    object_type VARCHAR(256) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    object_id VARCHAR(256) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    relation VARCHAR(50) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    _user VARCHAR(512) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    operation INTEGER NOT NULL,
+    ulid CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    inserted_at DATETIME2 NOT NULL,
+    CONSTRAINT pk_changelog PRIMARY KEY (store, ulid, object_type)
+);
+
+-- +goose Down
+DROP TABLE tuple;
+DROP TABLE authorization_model;
+DROP TABLE store;
+DROP TABLE assertion;
+DROP TABLE changelog;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE authorization_model ADD schema_version VARCHAR(5) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL
+    CONSTRAINT df_authorization_model_schema_version DEFAULT '1.0';
+
+-- +goose Down
+ALTER TABLE authorization_model DROP CONSTRAINT df_authorization_model_schema_version;
+ALTER TABLE authorization_model DROP COLUMN schema_version;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD relation_aliases VARCHAR(MAX) COLLATE Latin1_General_100_BIN2_UTF8;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN relation_aliases;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE store ADD unique_name VARCHAR(64) COLLATE Latin1_General_100_BIN2_UTF8;
+CREATE UNIQUE INDEX idx_store_unique_name ON store (unique_name) WHERE unique_name IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_store_unique_name ON store;
+ALTER TABLE store DROP COLUMN unique_name;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE idempotency_record (
+    store CHAR(26) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    idempotency_key VARCHAR(128) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    request_hash CHAR(64) COLLATE Latin1_General_100_BIN2_UTF8 NOT NULL,
+    expires_at DATETIME2 NOT NULL,
+    CONSTRAINT pk_idempotency_record PRIMARY KEY (store, idempotency_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_record;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD default_user_type VARCHAR(256) COLLATE Latin1_General_100_BIN2_UTF8;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN default_user_type;
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX idx_idempotency_record_expires_at ON idempotency_record (expires_at);
+
+-- +goose Down
+DROP INDEX idx_idempotency_record_expires_at ON idempotency_record;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE tuple ADD condition_context_key_id VARCHAR(256) COLLATE Latin1_General_100_BIN2_UTF8;
+ALTER TABLE changelog ADD condition_context_key_id VARCHAR(256) COLLATE Latin1_General_100_BIN2_UTF8;
+
+-- +goose Down
+ALTER TABLE tuple DROP COLUMN condition_context_key_id;
+ALTER TABLE changelog DROP COLUMN condition_context_key_id;
@@ -0,0 +1,250 @@
+package indexadvisor
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openfga/openfga/assets"
+	"github.com/openfga/openfga/cmd/migrate"
+)
+
+// tupleColumns are the columns of the tuple table that reads filter on by equality, in the order of the
+// primary key. The store column is filtered on by every read, so it is implied in a QueryShape.
+var tupleColumns = []string{"store", "object_type", "object_id", "relation", "_user", "user_type"}
+
+var (
+	createTupleTableRe = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+tuple\s*\(.*PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	createIndexRe      = regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(\w+)\s+ON\s+tuple\s*\(([^)]*)\)\s*(WHERE\s+)?`)
+	dropIndexRe        = regexp.MustCompile(`(?is)^DROP\s+INDEX\s+(?:IF\s+EXISTS\s+)?(\w+)`)
+)
+
+// QueryShape is a read of tuples, as recorded by the query metrics: the columns of the tuple table it
+// filters on by equality, and how many times it ran.
+type QueryShape struct {
+	Columns []string `json:"columns"`
+	Count   uint64   `json:"count"`
+}
+
+// Index is an index of the tuple table.
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// Suggestion is an index that no index of the schema can serve for the query shapes, which filter on
+// its columns, and that made up Share of the reads.
+type Suggestion struct {
+	Index
+	Count uint64  `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// Advice are the indexes suggested for a distribution of query shapes.
+type Advice struct {
+	Engine      string       `json:"engine"`
+	Indexes     []Index      `json:"indexes"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// SchemaIndexes returns the indexes of the tuple table that the embedded migrations of the engine create,
+// starting with the primary key. Partial indexes are left out, since they can't serve every read on
+// their columns.
+func SchemaIndexes(engine string) ([]Index, error) {
+	var migrationsPath string
+	switch engine {
+	case "postgres":
+		migrationsPath = assets.PostgresMigrationDir
+	case "mysql":
+		migrationsPath = assets.MySQLMigrationDir
+	default:
+		return nil, fmt.Errorf("storage engine '%s' is unsupported", engine)
+	}
+
+	preview, err := migrate.NewMigrationPreview(migrationsPath, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []Index
+	for _, migration := range preview.Migrations {
+		for _, statement := range migration.Statements {
+			if match := createTupleTableRe.FindStringSubmatch(statement); match != nil {
+				indexes = append(indexes, Index{Name: "primary", Columns: splitColumns(match[1])})
+				continue
+			}
+
+			if match := createIndexRe.FindStringSubmatch(statement); match != nil {
+				if match[3] == "" {
+					indexes = append(indexes, Index{Name: match[1], Columns: splitColumns(match[2])})
+				}
+				continue
+			}
+
+			if match := dropIndexRe.FindStringSubmatch(statement); match != nil {
+				for i, index := range indexes {
+					if index.Name == match[1] {
+						indexes = append(indexes[:i], indexes[i+1:]...)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return indexes, nil
+}
+
+// Advise returns an index for each set of columns that the shapes filter on and that no index can serve,
+// i.e. no index starts with exactly these columns. Suggestions that are a prefix of another one are merged
+// into it, and the ones that made up less than minShare of the reads are left out.
+func Advise(engine string, shapes []QueryShape, minShare float64) (*Advice, error) {
+	indexes, err := SchemaIndexes(engine)
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	counts := map[string]uint64{}
+	for _, shape := range shapes {
+		columns, err := normalizeColumns(shape.Columns)
+		if err != nil {
+			return nil, err
+		}
+
+		total += shape.Count
+		if !servedByAny(indexes, columns) {
+			counts[strings.Join(columns, ",")] += shape.Count
+		}
+	}
+
+	candidates := make([]Suggestion, 0, len(counts))
+	for key, count := range counts {
+		candidates = append(candidates, Suggestion{Index: Index{Columns: strings.Split(key, ",")}, Count: count})
+	}
+	// longest first, so that each candidate is merged into the longest one it is a prefix of
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(candidates[i].Columns) != len(candidates[j].Columns) {
+			return len(candidates[i].Columns) > len(candidates[j].Columns)
+		}
+		return strings.Join(candidates[i].Columns, ",") < strings.Join(candidates[j].Columns, ",")
+	})
+
+	var suggestions []Suggestion
+candidates:
+	for _, candidate := range candidates {
+		for i := range suggestions {
+			if hasPrefix(suggestions[i].Columns, candidate.Columns) {
+				suggestions[i].Count += candidate.Count
+				continue candidates
+			}
+		}
+		suggestions = append(suggestions, candidate)
+	}
+
+	advice := &Advice{Engine: engine, Indexes: indexes, Suggestions: []Suggestion{}}
+	for _, suggestion := range suggestions {
+		suggestion.Share = float64(suggestion.Count) / float64(total)
+		if suggestion.Count == 0 || suggestion.Share < minShare {
+			continue
+		}
+		suggestion.Name = "idx_tuple_" + strings.ReplaceAll(strings.Join(suggestion.Columns[1:], "_"), "__", "_")
+		advice.Suggestions = append(advice.Suggestions, suggestion)
+	}
+	sort.SliceStable(advice.Suggestions, func(i, j int) bool {
+		return advice.Suggestions[i].Count > advice.Suggestions[j].Count
+	})
+
+	return advice, nil
+}
+
+// WriteSQL writes the suggestions as CREATE INDEX statements, with the share of the reads of each in a comment.
+func (a *Advice) WriteSQL(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("-- advisory only: review each index against the write load before creating it\n")
+	if len(a.Suggestions) == 0 {
+		b.WriteString("-- the indexes of the schema serve the query shapes\n")
+	}
+
+	for _, suggestion := range a.Suggestions {
+		fmt.Fprintf(&b, "\n-- %.1f%% of the reads (%d)\n", suggestion.Share*100, suggestion.Count)
+		switch a.Engine {
+		case "postgres":
+			// without CONCURRENTLY, creating the index blocks the writes of tuples
+			fmt.Fprintf(&b, "CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON tuple (%s);\n", suggestion.Name, strings.Join(suggestion.Columns, ", "))
+		default:
+			fmt.Fprintf(&b, "CREATE INDEX %s ON tuple (%s);\n", suggestion.Name, strings.Join(suggestion.Columns, ", "))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// servedByAny returns true if one of the indexes starts with the columns, in any order.
+func servedByAny(indexes []Index, columns []string) bool {
+	for _, index := range indexes {
+		if len(index.Columns) < len(columns) {
+			continue
+		}
+
+		prefix := map[string]bool{}
+		for _, column := range index.Columns[:len(columns)] {
+			prefix[column] = true
+		}
+
+		served := true
+		for _, column := range columns {
+			served = served && prefix[column]
+		}
+		if served {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeColumns returns the columns with the store, without duplicates, in the order of tupleColumns.
+func normalizeColumns(columns []string) ([]string, error) {
+	filtered := map[string]bool{"store": true}
+	for _, column := range columns {
+		known := false
+		for _, tupleColumn := range tupleColumns {
+			known = known || column == tupleColumn
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown tuple column '%s' in query shape", column)
+		}
+		filtered[column] = true
+	}
+
+	normalized := make([]string, 0, len(filtered))
+	for _, column := range tupleColumns {
+		if filtered[column] {
+			normalized = append(normalized, column)
+		}
+	}
+	return normalized, nil
+}
+
+func hasPrefix(columns, prefix []string) bool {
+	if len(prefix) > len(columns) {
+		return false
+	}
+	for i := range prefix {
+		if columns[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitColumns(columns string) []string {
+	split := strings.Split(columns, ",")
+	for i := range split {
+		split[i] = strings.TrimSpace(split[i])
+	}
+	return split
+}
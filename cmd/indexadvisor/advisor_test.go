@@ -0,0 +1,76 @@
+package indexadvisor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaIndexes(t *testing.T) {
+	for _, engine := range []string{"postgres", "mysql"} {
+		t.Run(engine, func(t *testing.T) {
+			indexes, err := SchemaIndexes(engine)
+			require.NoError(t, err)
+			require.Equal(t, []Index{
+				{Name: "primary", Columns: []string{"store", "object_type", "object_id", "relation", "_user"}},
+				{Name: "idx_tuple_ulid", Columns: []string{"ulid"}},
+				{Name: "idx_reverse_lookup_user", Columns: []string{"store", "object_type", "relation", "_user"}},
+			}, indexes)
+		})
+	}
+
+	_, err := SchemaIndexes("memory")
+	require.ErrorContains(t, err, "storage engine 'memory' is unsupported")
+}
+
+func TestAdvise(t *testing.T) {
+	shapes := []QueryShape{
+		// ReadUserTuple, served by the primary key
+		{Columns: []string{"object_type", "object_id", "relation", "_user"}, Count: 5500},
+		// ReadStartingWithUser, served by the reverse lookup index
+		{Columns: []string{"_user", "relation", "object_type"}, Count: 3000},
+		{Columns: []string{"object_type", "_user", "user_type"}, Count: 400},
+		// served by the index suggested for the shape above
+		{Columns: []string{"object_type", "_user"}, Count: 1000},
+		// too rare to be worth an index
+		{Columns: []string{"relation"}, Count: 100},
+	}
+
+	advice, err := Advise("postgres", shapes, 0.05)
+	require.NoError(t, err)
+	require.Equal(t, []Suggestion{
+		{
+			Index: Index{Name: "idx_tuple_object_type_user_user_type", Columns: []string{"store", "object_type", "_user", "user_type"}},
+			Count: 1400,
+			Share: 0.14,
+		},
+	}, advice.Suggestions)
+
+	var sql bytes.Buffer
+	require.NoError(t, advice.WriteSQL(&sql))
+	require.Contains(t, sql.String(), "-- 14.0% of the reads (1400)\n"+
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_tuple_object_type_user_user_type ON tuple (store, object_type, _user, user_type);\n")
+
+	t.Run("min_share", func(t *testing.T) {
+		advice, err := Advise("mysql", shapes, 0)
+		require.NoError(t, err)
+		require.Len(t, advice.Suggestions, 2)
+		require.Equal(t, []string{"store", "relation"}, advice.Suggestions[1].Columns)
+
+		var sql bytes.Buffer
+		require.NoError(t, advice.WriteSQL(&sql))
+		require.Contains(t, sql.String(), "CREATE INDEX idx_tuple_relation ON tuple (store, relation);\n")
+	})
+
+	t.Run("served_shapes", func(t *testing.T) {
+		advice, err := Advise("postgres", shapes[:2], 0.05)
+		require.NoError(t, err)
+		require.Empty(t, advice.Suggestions)
+	})
+
+	t.Run("unknown_column", func(t *testing.T) {
+		_, err := Advise("postgres", []QueryShape{{Columns: []string{"condition_name"}, Count: 1}}, 0.05)
+		require.ErrorContains(t, err, "unknown tuple column 'condition_name'")
+	})
+}
@@ -0,0 +1,88 @@
+// Package indexadvisor contains the command to suggest indexes of the tuple table for the reads of a store.
+package indexadvisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	datastoreEngineFlag = "datastore-engine"
+	queryShapesFlag     = "query-shapes"
+	minShareFlag        = "min-share"
+	outputFlag          = "output"
+)
+
+func NewIndexAdvisorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index-advisor",
+		Short: "Suggest indexes of the tuple table for the observed reads",
+		Long: "Compare the query shapes recorded by the query metrics, i.e. the columns of the tuple table that reads filter on " +
+			"and how many times they ran, with the indexes that the migrations create, and print the indexes that could serve the reads " +
+			"that no index serves. This is advisory only: it doesn't connect to the datastore nor create any index.\n" +
+			"The query shapes are read from a JSON file, e.g. [{\"columns\": [\"object_type\", \"relation\", \"_user\"], \"count\": 1200}].",
+		RunE: runIndexAdvisor,
+		Args: cobra.NoArgs,
+	}
+
+	flags := cmd.Flags()
+	flags.String(datastoreEngineFlag, "postgres", "the datastore engine ('postgres' or 'mysql')")
+	flags.String(queryShapesFlag, "", "the JSON file of the query shapes")
+	flags.Float64(minShareFlag, 0.05, "the share of the reads, between 0 and 1, below which an index is not suggested")
+	flags.String(outputFlag, "sql", "the output format ('sql' or 'json')")
+	_ = cmd.MarkFlagRequired(queryShapesFlag)
+
+	return cmd
+}
+
+func runIndexAdvisor(cmd *cobra.Command, _ []string) error {
+	flags := cmd.Flags()
+	engine, err := flags.GetString(datastoreEngineFlag)
+	if err != nil {
+		return err
+	}
+	file, err := flags.GetString(queryShapesFlag)
+	if err != nil {
+		return err
+	}
+	minShare, err := flags.GetFloat64(minShareFlag)
+	if err != nil {
+		return err
+	}
+	output, err := flags.GetString(outputFlag)
+	if err != nil {
+		return err
+	}
+	if output != "sql" && output != "json" {
+		return fmt.Errorf("output format '%s' is unsupported", output)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read the query shapes: %w", err)
+	}
+	var shapes []QueryShape
+	if err := json.Unmarshal(data, &shapes); err != nil {
+		return fmt.Errorf("failed to parse the query shapes: %w", err)
+	}
+
+	advice, err := Advise(engine, shapes, minShare)
+	if err != nil {
+		return err
+	}
+
+	if output == "sql" {
+		return advice.WriteSQL(cmd.OutOrStdout())
+	}
+
+	marshalled, err := json.MarshalIndent(advice, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error marshalling the advice: %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(marshalled))
+
+	return nil
+}
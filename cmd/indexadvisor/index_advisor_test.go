@@ -0,0 +1,52 @@
+package indexadvisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/cmd"
+)
+
+func TestIndexAdvisorCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "shapes.json")
+	require.NoError(t, os.WriteFile(file, []byte(`[
+		{"columns": ["object_type", "object_id", "relation", "_user"], "count": 900},
+		{"columns": ["object_type", "user_type"], "count": 100}
+	]`), 0o600))
+
+	rootCmd := cmd.NewRootCommand()
+	rootCmd.AddCommand(NewIndexAdvisorCommand())
+
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	rootCmd.SetArgs([]string{"index-advisor", "--query-shapes", file, "--output", "json"})
+	require.NoError(t, rootCmd.Execute())
+
+	var advice Advice
+	require.NoError(t, json.Unmarshal(out.Bytes(), &advice))
+	require.Equal(t, "postgres", advice.Engine)
+	require.Equal(t, []Suggestion{
+		{
+			Index: Index{Name: "idx_tuple_object_type_user_type", Columns: []string{"store", "object_type", "user_type"}},
+			Count: 100,
+			Share: 0.1,
+		},
+	}, advice.Suggestions)
+}
+
+func TestIndexAdvisorCommandUnsupportedOutput(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "shapes.json")
+	require.NoError(t, os.WriteFile(file, []byte(`[]`), 0o600))
+
+	rootCmd := cmd.NewRootCommand()
+	rootCmd.AddCommand(NewIndexAdvisorCommand())
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs([]string{"index-advisor", "--query-shapes", file, "--output", "yaml"})
+	require.ErrorContains(t, rootCmd.Execute(), "output format 'yaml' is unsupported")
+}
@@ -30,5 +30,7 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 
 		util.MustBindPFlag(verboseMigrationFlag, flags.Lookup(verboseMigrationFlag))
 		util.MustBindEnv(verboseMigrationFlag, "OPENFGA_VERBOSE")
+
+		util.MustBindPFlag(previewFlag, flags.Lookup(previewFlag))
 	}
 }
@@ -10,12 +10,14 @@ import (
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/go-sql-driver/mysql"
-	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver.
+	_ "github.com/jackc/pgx/v5/stdlib"  // PostgreSQL driver.
+	_ "github.com/microsoft/go-mssqldb" // SQL Server driver.
 	"github.com/pressly/goose/v3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/openfga/openfga/assets"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
 )
 
 const (
@@ -26,6 +28,7 @@ const (
 	versionFlag           = "version"
 	timeoutFlag           = "timeout"
 	verboseMigrationFlag  = "verbose"
+	previewFlag           = "preview"
 )
 
 func NewMigrateCommand() *cobra.Command {
@@ -46,6 +49,7 @@ func NewMigrateCommand() *cobra.Command {
 	flags.Uint(versionFlag, 0, "the version to migrate to (if omitted the latest schema will be used)")
 	flags.Duration(timeoutFlag, 1*time.Minute, "a timeout for the time it takes the migrate process to connect to the database")
 	flags.Bool(verboseMigrationFlag, false, "enable verbose migration logs (default false)")
+	flags.Bool(previewFlag, false, "print the SQL of the pending up migrations to the version without running them (default false)")
 
 	// NOTE: if you add a new flag here, update the function below, too
 
@@ -54,7 +58,13 @@ func NewMigrateCommand() *cobra.Command {
 	return cmd
 }
 
-func runMigration(_ *cobra.Command, _ []string) error {
+// runMigration runs the migrations, and redacts the credentials of the datastore from the error returned.
+func runMigration(cmd *cobra.Command, _ []string) error {
+	err := migrate(cmd)
+	return sqlcommon.RedactCredentials(err, viper.GetString(datastoreURIFlag), viper.GetString(datastorePasswordFlag))
+}
+
+func migrate(cmd *cobra.Command) error {
 	engine := viper.GetString(datastoreEngineFlag)
 	uri := viper.GetString(datastoreURIFlag)
 	targetVersion := viper.GetUint(versionFlag)
@@ -62,6 +72,7 @@ func runMigration(_ *cobra.Command, _ []string) error {
 	verbose := viper.GetBool(verboseMigrationFlag)
 	username := viper.GetString(datastoreUsernameFlag)
 	password := viper.GetString(datastorePasswordFlag)
+	preview := viper.GetBool(previewFlag)
 
 	goose.SetLogger(goose.NopLogger())
 	goose.SetVerbose(verbose)
@@ -106,6 +117,24 @@ func runMigration(_ *cobra.Command, _ []string) error {
 		dbURI.User = url.UserPassword(username, password)
 
 		// Replace CLI uri with the one we just updated.
+		uri = dbURI.String()
+	case "sqlserver":
+		driver = "sqlserver"
+		migrationsPath = assets.SQLServerMigrationDir
+
+		// Parse the database uri with url.Parse() and update username/password, if set via flags
+		dbURI, err := url.Parse(uri)
+		if err != nil {
+			return fmt.Errorf("invalid database uri: %v", err)
+		}
+		if username == "" && dbURI.User != nil {
+			username = dbURI.User.Username()
+		}
+		if password == "" && dbURI.User != nil {
+			password, _ = dbURI.User.Password()
+		}
+		dbURI.User = url.UserPassword(username, password)
+
 		uri = dbURI.String()
 	case "":
 		return fmt.Errorf("missing datastore engine type")
@@ -137,6 +166,18 @@ func runMigration(_ *cobra.Command, _ []string) error {
 
 	log.Printf("current version %d", currentVersion)
 
+	if preview {
+		if targetVersion != 0 && int64(targetVersion) < currentVersion {
+			return fmt.Errorf("only up migrations can be previewed, but the target version %d is lower than the current version %d", targetVersion, currentVersion)
+		}
+
+		migrationPreview, err := NewMigrationPreview(migrationsPath, currentVersion, int64(targetVersion))
+		if err != nil {
+			return err
+		}
+		return migrationPreview.Write(cmd.OutOrStdout())
+	}
+
 	if targetVersion == 0 {
 		log.Println("running all migrations")
 		if err := goose.Up(db, migrationsPath); err != nil {
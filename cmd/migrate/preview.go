@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/openfga/openfga/assets"
+)
+
+// MigrationPreview is the SQL that migrating a database from CurrentVersion up to TargetVersion runs.
+type MigrationPreview struct {
+	CurrentVersion int64
+	TargetVersion  int64
+	// Migrations are the pending migrations in the order in which they run.
+	Migrations []PendingMigration
+}
+
+// PendingMigration is a migration that hasn't been applied yet.
+type PendingMigration struct {
+	Version int64
+	Source  string
+	// Statements are the statements of the up migration in the order in which they run.
+	Statements []string
+}
+
+// NewMigrationPreview returns the up migrations of the embedded migrations directory, e.g.
+// [assets.PostgresMigrationDir], with a version greater than currentVersion and not greater than
+// targetVersion. If targetVersion is 0, all the migrations after currentVersion are pending.
+func NewMigrationPreview(migrationsPath string, currentVersion, targetVersion int64) (*MigrationPreview, error) {
+	entries, err := fs.ReadDir(assets.EmbedMigrations, migrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the migrations: %w", err)
+	}
+
+	preview := &MigrationPreview{CurrentVersion: currentVersion, TargetVersion: currentVersion}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		version, err := goose.NumericComponent(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration %s: %w", entry.Name(), err)
+		}
+		if version <= currentVersion || (targetVersion != 0 && version > targetVersion) {
+			continue
+		}
+
+		source := path.Join(migrationsPath, entry.Name())
+		file, err := assets.EmbedMigrations.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", source, err)
+		}
+		statements, err := parseUpStatements(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration %s: %w", source, err)
+		}
+
+		preview.Migrations = append(preview.Migrations, PendingMigration{
+			Version:    version,
+			Source:     source,
+			Statements: statements,
+		})
+	}
+
+	sort.Slice(preview.Migrations, func(i, j int) bool {
+		return preview.Migrations[i].Version < preview.Migrations[j].Version
+	})
+
+	if targetVersion != 0 {
+		preview.TargetVersion = targetVersion
+	} else if len(preview.Migrations) > 0 {
+		preview.TargetVersion = preview.Migrations[len(preview.Migrations)-1].Version
+	}
+
+	return preview, nil
+}
+
+// Write writes the preview as a SQL script with the versions and the sources of the migrations in comments.
+func (p *MigrationPreview) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- current version %d, target version %d\n", p.CurrentVersion, p.TargetVersion)
+	if len(p.Migrations) == 0 {
+		b.WriteString("-- nothing to do\n")
+	}
+	for _, migration := range p.Migrations {
+		fmt.Fprintf(&b, "\n-- version %d: %s\n", migration.Version, migration.Source)
+		for _, statement := range migration.Statements {
+			b.WriteString(statement)
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// parseUpStatements returns the statements of the '-- +goose Up' section of a goose SQL migration.
+// As in goose, a statement ends with a line ending with a semicolon, unless it is between
+// '-- +goose StatementBegin' and '-- +goose StatementEnd'.
+func parseUpStatements(r io.Reader) ([]string, error) {
+	var statements []string
+	var statement strings.Builder
+	up, inBlock := false, false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "-- +goose") {
+			switch strings.TrimSpace(strings.TrimPrefix(trimmed, "-- +goose")) {
+			case "Up":
+				up = true
+			case "Down":
+				up = false
+			case "StatementBegin":
+				inBlock = true
+			case "StatementEnd":
+				inBlock = false
+				if up && statement.Len() > 0 {
+					statements = append(statements, strings.TrimSpace(statement.String()))
+					statement.Reset()
+				}
+			}
+			continue
+		}
+
+		if !up || (statement.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--"))) {
+			continue
+		}
+
+		statement.WriteString(line)
+		statement.WriteString("\n")
+
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(statement.String()))
+			statement.Reset()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if statement.Len() > 0 {
+		return nil, fmt.Errorf("unterminated statement: %s", strings.TrimSpace(statement.String()))
+	}
+
+	return statements, nil
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/assets"
+)
+
+func TestMigrationPreview(t *testing.T) {
+	engines := map[string]string{
+		"postgres":  assets.PostgresMigrationDir,
+		"mysql":     assets.MySQLMigrationDir,
+		"sqlserver": assets.SQLServerMigrationDir,
+	}
+
+	for engine, migrationsPath := range engines {
+		t.Run(engine, func(t *testing.T) {
+			entries, err := fs.ReadDir(assets.EmbedMigrations, migrationsPath)
+			require.NoError(t, err)
+
+			t.Run("matches_the_embedded_migrations", func(t *testing.T) {
+				preview, err := NewMigrationPreview(migrationsPath, 0, 0)
+				require.NoError(t, err)
+				require.Equal(t, int64(0), preview.CurrentVersion)
+				require.Equal(t, int64(len(entries)), preview.TargetVersion)
+				require.Len(t, preview.Migrations, len(entries))
+
+				for i, migration := range preview.Migrations {
+					require.Equal(t, int64(i+1), migration.Version)
+					require.Equal(t, path.Join(migrationsPath, entries[i].Name()), migration.Source)
+
+					contents, err := fs.ReadFile(assets.EmbedMigrations, migration.Source)
+					require.NoError(t, err)
+					up, down, found := strings.Cut(string(contents), "-- +goose Down")
+					require.True(t, found)
+
+					require.NotEmpty(t, migration.Statements)
+					for _, statement := range migration.Statements {
+						require.Contains(t, up, statement)
+						require.NotContains(t, down, statement)
+						require.True(t, strings.HasSuffix(statement, ";"))
+					}
+					// all the statements of the up migration are previewed, in order
+					require.Equal(t, strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(up), "-- +goose Up")), " "),
+						strings.Join(strings.Fields(strings.Join(migration.Statements, " ")), " "))
+				}
+			})
+
+			t.Run("only_pending_migrations", func(t *testing.T) {
+				preview, err := NewMigrationPreview(migrationsPath, 2, 4)
+				require.NoError(t, err)
+				require.Equal(t, int64(2), preview.CurrentVersion)
+				require.Equal(t, int64(4), preview.TargetVersion)
+				require.Len(t, preview.Migrations, 2)
+				require.Equal(t, int64(3), preview.Migrations[0].Version)
+				require.Equal(t, int64(4), preview.Migrations[1].Version)
+
+				var out bytes.Buffer
+				require.NoError(t, preview.Write(&out))
+				require.True(t, strings.HasPrefix(out.String(), "-- current version 2, target version 4\n"))
+				require.Contains(t, out.String(), "-- version 3: "+preview.Migrations[0].Source)
+				for _, statement := range preview.Migrations[1].Statements {
+					require.Contains(t, out.String(), statement)
+				}
+			})
+
+			t.Run("nothing_to_do", func(t *testing.T) {
+				preview, err := NewMigrationPreview(migrationsPath, int64(len(entries)), 0)
+				require.NoError(t, err)
+				require.Empty(t, preview.Migrations)
+				require.Equal(t, preview.CurrentVersion, preview.TargetVersion)
+
+				var out bytes.Buffer
+				require.NoError(t, preview.Write(&out))
+				require.Contains(t, out.String(), "-- nothing to do")
+			})
+		})
+	}
+}
+
+func TestParseUpStatements(t *testing.T) {
+	statements, err := parseUpStatements(strings.NewReader(`-- +goose Up
+CREATE TABLE a (
+    id TEXT
+);
+-- a comment
+CREATE INDEX idx_a ON a (id);
+
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS void AS $$
+BEGIN
+    PERFORM 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE a;
+`))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"CREATE TABLE a (\n    id TEXT\n);",
+		"CREATE INDEX idx_a ON a (id);",
+		"CREATE FUNCTION f() RETURNS void AS $$\nBEGIN\n    PERFORM 1;\nEND;\n$$ LANGUAGE plpgsql;",
+	}, statements)
+
+	_, err = parseUpStatements(strings.NewReader("-- +goose Up\nCREATE TABLE a (id TEXT)\n"))
+	require.ErrorContains(t, err, "unterminated statement")
+}
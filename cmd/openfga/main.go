@@ -5,8 +5,12 @@ import (
 	"os"
 
 	"github.com/openfga/openfga/cmd"
+	"github.com/openfga/openfga/cmd/indexadvisor"
 	"github.com/openfga/openfga/cmd/migrate"
+	"github.com/openfga/openfga/cmd/rebuildindexes"
+	"github.com/openfga/openfga/cmd/relationgraph"
 	"github.com/openfga/openfga/cmd/run"
+	"github.com/openfga/openfga/cmd/store"
 	"github.com/openfga/openfga/cmd/validatemodels"
 )
 
@@ -22,6 +26,18 @@ func main() {
 	validateModelsCmd := validatemodels.NewValidateCommand()
 	rootCmd.AddCommand(validateModelsCmd)
 
+	relationGraphCmd := relationgraph.NewRelationGraphCommand()
+	rootCmd.AddCommand(relationGraphCmd)
+
+	indexAdvisorCmd := indexadvisor.NewIndexAdvisorCommand()
+	rootCmd.AddCommand(indexAdvisorCmd)
+
+	rebuildIndexesCmd := rebuildindexes.NewRebuildIndexesCommand()
+	rootCmd.AddCommand(rebuildIndexesCmd)
+
+	storeCmd := store.NewStoreCommand()
+	rootCmd.AddCommand(storeCmd)
+
 	versionCmd := cmd.NewVersionCommand()
 	rootCmd.AddCommand(versionCmd)
 
@@ -0,0 +1,22 @@
+package rebuildindexes
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/openfga/openfga/cmd/util"
+)
+
+// bindRunFlagsFunc binds the cobra cmd flags to the equivalent config value being managed
+// by viper. This bridges the config between cobra flags and viper flags.
+func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		util.MustBindPFlag(datastoreEngineFlag, flags.Lookup(datastoreEngineFlag))
+		util.MustBindEnv(datastoreEngineFlag, "OPENFGA_DATASTORE_ENGINE")
+
+		util.MustBindPFlag(datastoreURIFlag, flags.Lookup(datastoreURIFlag))
+		util.MustBindEnv(datastoreURIFlag, "OPENFGA_DATASTORE_URI")
+
+		util.MustBindPFlag(timeoutFlag, flags.Lookup(timeoutFlag))
+	}
+}
@@ -0,0 +1,113 @@
+// Package rebuildindexes contains the command to rebuild the indexes of the datastore.
+package rebuildindexes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/mysql"
+	"github.com/openfga/openfga/pkg/storage/postgres"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+)
+
+const (
+	datastoreEngineFlag = "datastore-engine"
+	datastoreURIFlag    = "datastore-uri"
+	timeoutFlag         = "timeout"
+)
+
+func NewRebuildIndexesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rebuild-indexes",
+		Short: "Rebuild the indexes of the tuple and changelog tables",
+		Long: "Rebuild the indexes of the tuple and changelog tables, e.g. after a bulk import left them bloated: REINDEX TABLE " +
+			"for 'postgres', OPTIMIZE TABLE for 'mysql'.\n" +
+			"A lock of the database makes sure that a single rebuild runs at a time, across all the pods sharing the datastore. " +
+			"On 'postgres', the writes to a table, and the reads that use its indexes, are blocked while its indexes are rebuilt. " +
+			"On 'mysql', the reads and writes are only blocked briefly, at the start and at the end of the rebuild of each table.\n" +
+			"The rebuild is canceled on SIGINT or SIGTERM, or when the timeout elapses.",
+		RunE: runRebuildIndexes,
+		Args: cobra.NoArgs,
+	}
+
+	flags := cmd.Flags()
+	flags.String(datastoreEngineFlag, "", "the datastore engine ('postgres' or 'mysql')")
+	flags.String(datastoreURIFlag, "", "the connection uri to the datastore")
+	flags.Duration(timeoutFlag, 0, "the time after which the rebuild is canceled (if 0, it's never canceled by a timeout)")
+
+	// NOTE: if you add a new flag here, update the function below, too
+
+	cmd.PreRun = bindRunFlagsFunc(flags)
+
+	return cmd
+}
+
+func runRebuildIndexes(cmd *cobra.Command, _ []string) error {
+	engine := viper.GetString(datastoreEngineFlag)
+	uri := viper.GetString(datastoreURIFlag)
+	timeout := viper.GetDuration(timeoutFlag)
+
+	var (
+		db interface {
+			storage.OpenFGADatastore
+			storage.IndexRebuilder
+		}
+		err error
+	)
+	switch engine {
+	case "mysql":
+		db, err = mysql.New(uri, sqlcommon.NewConfig())
+	case "postgres":
+		db, err = postgres.New(uri, sqlcommon.NewConfig())
+	case "":
+		return fmt.Errorf("missing datastore engine type")
+	case "memory":
+		fallthrough
+	default:
+		return fmt.Errorf("storage engine '%s' is unsupported", engine)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open a connection to the datastore: %v", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	duration, err := RebuildIndexes(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "rebuilt the indexes in %s\n", duration)
+	return nil
+}
+
+// RebuildIndexes rebuilds the indexes of the datastore and returns how long it took.
+func RebuildIndexes(ctx context.Context, db storage.IndexRebuilder) (time.Duration, error) {
+	start := time.Now()
+	err := db.RebuildIndexes(ctx)
+	switch {
+	case errors.Is(err, storage.ErrIndexRebuildInProgress):
+		return 0, fmt.Errorf("the indexes are already being rebuilt by another process")
+	case ctx.Err() != nil:
+		return 0, fmt.Errorf("rebuild of the indexes canceled after %s: %w", time.Since(start), ctx.Err())
+	case err != nil:
+		return 0, fmt.Errorf("failed to rebuild the indexes: %w", err)
+	}
+	return time.Since(start), nil
+}
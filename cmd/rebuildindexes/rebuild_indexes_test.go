@@ -0,0 +1,94 @@
+package rebuildindexes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/cmd/util"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestRebuildIndexes(t *testing.T) {
+	for _, engine := range []string{"postgres", "mysql"} {
+		t.Run(engine, func(t *testing.T) {
+			_, ds, uri := util.MustBootstrapDatastore(t, engine)
+
+			ctx := context.Background()
+
+			// seed a store, with deletes so that the indexes have dead entries
+			storeID := ulid.Make().String()
+			var tuples []*openfgav1.TupleKey
+			var deletes []*openfgav1.TupleKeyWithoutCondition
+			for i := 0; i < ds.MaxTuplesPerWrite(); i++ {
+				tk := tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:anne")
+				tuples = append(tuples, tk)
+				if i%2 == 0 {
+					deletes = append(deletes, tuple.TupleKeyToTupleKeyWithoutCondition(tk))
+				}
+			}
+			require.NoError(t, ds.Write(ctx, storeID, nil, tuples))
+			require.NoError(t, ds.Write(ctx, storeID, deletes, nil))
+
+			t.Run("completes", func(t *testing.T) {
+				duration, err := RebuildIndexes(ctx, ds.(storage.IndexRebuilder))
+				require.NoError(t, err)
+				require.Positive(t, duration)
+
+				// the tuples are still readable through the rebuilt indexes
+				_, err = ds.ReadUserTuple(ctx, storeID, tuples[1], storage.ReadUserTupleOptions{})
+				require.NoError(t, err)
+			})
+
+			t.Run("canceled", func(t *testing.T) {
+				canceledCtx, cancel := context.WithCancel(ctx)
+				cancel()
+
+				_, err := RebuildIndexes(canceledCtx, ds.(storage.IndexRebuilder))
+				require.ErrorIs(t, err, context.Canceled)
+
+				// the lock was released
+				_, err = RebuildIndexes(ctx, ds.(storage.IndexRebuilder))
+				require.NoError(t, err)
+			})
+
+			t.Run("command", func(t *testing.T) {
+				out := &bytes.Buffer{}
+				rebuildIndexesCmd := NewRebuildIndexesCommand()
+				rebuildIndexesCmd.SetOut(out)
+				rebuildIndexesCmd.SetArgs([]string{"--datastore-engine", engine, "--datastore-uri", uri})
+				require.NoError(t, rebuildIndexesCmd.Execute())
+				require.Contains(t, out.String(), "rebuilt the indexes in")
+			})
+		})
+	}
+}
+
+func TestRebuildIndexesCommandWhenInvalidEngine(t *testing.T) {
+	for _, tc := range []struct {
+		engine        string
+		errorExpected string
+	}{
+		{
+			engine:        "memory",
+			errorExpected: "storage engine 'memory' is unsupported",
+		},
+		{
+			engine:        "",
+			errorExpected: "missing datastore engine type",
+		},
+	} {
+		t.Run(tc.engine, func(t *testing.T) {
+			rebuildIndexesCmd := NewRebuildIndexesCommand()
+			rebuildIndexesCmd.SetArgs([]string{"--datastore-engine", tc.engine, "--datastore-uri", ""})
+			err := rebuildIndexesCmd.Execute()
+			require.ErrorContains(t, err, tc.errorExpected)
+		})
+	}
+}
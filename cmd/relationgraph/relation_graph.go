@@ -0,0 +1,83 @@
+// Package relationgraph contains the command to export the relation graph of an authorization model.
+package relationgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+const fileFlag = "file"
+
+func NewRelationGraphCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "relation-graph",
+		Short: "Export the relation graph of an authorization model",
+		Long: "Print, as JSON, the nodes (types and type#relation) and the edges (direct, computed and tuple to userset references, " +
+			"and the set operation they are an operand of) of the relation graph of an authorization model, e.g. to render it.\n" +
+			"The model is read from a file in the DSL, or in JSON if the file has a .json extension.",
+		RunE: runRelationGraph,
+		Args: cobra.NoArgs,
+	}
+
+	flags := cmd.Flags()
+	flags.String(fileFlag, "", "the file of the authorization model")
+	_ = cmd.MarkFlagRequired(fileFlag)
+
+	return cmd
+}
+
+func runRelationGraph(cmd *cobra.Command, _ []string) error {
+	file, err := cmd.Flags().GetString(fileFlag)
+	if err != nil {
+		return err
+	}
+
+	model, err := readModel(file)
+	if err != nil {
+		return err
+	}
+
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	if err != nil {
+		return fmt.Errorf("invalid authorization model: %w", err)
+	}
+
+	marshalled, err := json.MarshalIndent(typesys.RelationGraph(), "", "    ")
+	if err != nil {
+		return fmt.Errorf("error marshalling the relation graph: %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(marshalled))
+
+	return nil
+}
+
+func readModel(file string) (*openfgav1.AuthorizationModel, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the authorization model: %w", err)
+	}
+
+	if filepath.Ext(file) == ".json" {
+		model := &openfgav1.AuthorizationModel{}
+		if err := protojson.Unmarshal(data, model); err != nil {
+			return nil, fmt.Errorf("failed to parse the authorization model: %w", err)
+		}
+		return model, nil
+	}
+
+	model, err := parser.TransformDSLToProto(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the authorization model: %w", err)
+	}
+	return model, nil
+}
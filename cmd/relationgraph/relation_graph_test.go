@@ -0,0 +1,59 @@
+package relationgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/cmd"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestRelationGraphCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.fga")
+	require.NoError(t, os.WriteFile(file, []byte(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define owner: [user]
+				define viewer: [user] or owner`), 0o600))
+
+	rootCmd := cmd.NewRootCommand()
+	rootCmd.AddCommand(NewRelationGraphCommand())
+
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	rootCmd.SetArgs([]string{"relation-graph", "--file", file})
+	require.NoError(t, rootCmd.Execute())
+
+	var graph typesystem.RelationGraph
+	require.NoError(t, json.Unmarshal(out.Bytes(), &graph))
+	require.Equal(t, []typesystem.RelationGraphEdge{
+		{From: "document#owner", To: "user", Kind: typesystem.RelationGraphEdgeDirect},
+		{From: "document#viewer", To: "document#owner", Kind: typesystem.RelationGraphEdgeComputed, Operand: typesystem.RelationGraphOperandUnion},
+		{From: "document#viewer", To: "user", Kind: typesystem.RelationGraphEdgeDirect, Operand: typesystem.RelationGraphOperandUnion},
+	}, graph.Edges)
+}
+
+func TestRelationGraphCommandInvalidModel(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.fga")
+	require.NoError(t, os.WriteFile(file, []byte(`
+		model
+			schema 1.1
+		type document
+			relations
+				define viewer: [user]`), 0o600))
+
+	rootCmd := cmd.NewRootCommand()
+	rootCmd.AddCommand(NewRelationGraphCommand())
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs([]string{"relation-graph", "--file", file})
+	require.ErrorContains(t, rootCmd.Execute(), "invalid authorization model")
+}
@@ -14,6 +14,33 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("experimentals", flags.Lookup("experimentals"))
 		util.MustBindEnv("experimentals", "OPENFGA_EXPERIMENTALS")
 
+		util.MustBindPFlag("disabledConditions", flags.Lookup("disabled-conditions"))
+		util.MustBindEnv("disabledConditions", "OPENFGA_DISABLED_CONDITIONS", "OPENFGA_DISABLEDCONDITIONS")
+
+		util.MustBindPFlag("checkSelfRelations", flags.Lookup("check-self-relations"))
+		util.MustBindEnv("checkSelfRelations", "OPENFGA_CHECK_SELF_RELATIONS", "OPENFGA_CHECKSELFRELATIONS")
+
+		util.MustBindPFlag("conditionEvaluationErrorPolicy", flags.Lookup("condition-evaluation-error-policy"))
+		util.MustBindEnv("conditionEvaluationErrorPolicy", "OPENFGA_CONDITION_EVALUATION_ERROR_POLICY", "OPENFGA_CONDITIONEVALUATIONERRORPOLICY")
+
+		util.MustBindPFlag("checkStrictConditionContext", flags.Lookup("check-strict-condition-context"))
+		util.MustBindEnv("checkStrictConditionContext", "OPENFGA_CHECK_STRICT_CONDITION_CONTEXT", "OPENFGA_CHECKSTRICTCONDITIONCONTEXT")
+
+		util.MustBindPFlag("checkResolutionStrategy", flags.Lookup("check-resolution-strategy"))
+		util.MustBindEnv("checkResolutionStrategy", "OPENFGA_CHECK_RESOLUTION_STRATEGY", "OPENFGA_CHECKRESOLUTIONSTRATEGY")
+
+		util.MustBindPFlag("knownCheckResultsEnabled", flags.Lookup("known-check-results-enabled"))
+		util.MustBindEnv("knownCheckResultsEnabled", "OPENFGA_KNOWN_CHECK_RESULTS_ENABLED", "OPENFGA_KNOWNCHECKRESULTSENABLED")
+
+		util.MustBindPFlag("modelNotFoundFallback", flags.Lookup("model-not-found-fallback"))
+		util.MustBindEnv("modelNotFoundFallback", "OPENFGA_MODEL_NOT_FOUND_FALLBACK", "OPENFGA_MODELNOTFOUNDFALLBACK")
+
+		util.MustBindPFlag("disabledMethods", flags.Lookup("disabled-methods"))
+		util.MustBindEnv("disabledMethods", "OPENFGA_DISABLED_METHODS", "OPENFGA_DISABLEDMETHODS")
+
+		util.MustBindPFlag("methodConcurrencyLimits", flags.Lookup("method-concurrency-limits"))
+		util.MustBindEnv("methodConcurrencyLimits", "OPENFGA_METHOD_CONCURRENCY_LIMITS", "OPENFGA_METHODCONCURRENCYLIMITS")
+
 		util.MustBindPFlag("grpc.addr", flags.Lookup("grpc-addr"))
 		util.MustBindEnv("grpc.addr", "OPENFGA_GRPC_ADDR")
 
@@ -84,6 +111,18 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("datastore.password", flags.Lookup("datastore-password"))
 		util.MustBindEnv("datastore.password", "OPENFGA_DATASTORE_PASSWORD")
 
+		util.MustBindPFlag("datastore.readReplicaURIs", flags.Lookup("datastore-read-replica-uris"))
+		util.MustBindEnv("datastore.readReplicaURIs", "OPENFGA_DATASTORE_READ_REPLICA_URIS", "OPENFGA_DATASTORE_READREPLICAURIS")
+
+		util.MustBindPFlag("datastore.maxStatementSize", flags.Lookup("datastore-max-statement-size"))
+		util.MustBindEnv("datastore.maxStatementSize", "OPENFGA_DATASTORE_MAX_STATEMENT_SIZE", "OPENFGA_DATASTORE_MAXSTATEMENTSIZE")
+
+		util.MustBindPFlag("datastore.splitLargeWrites", flags.Lookup("datastore-split-large-writes"))
+		util.MustBindEnv("datastore.splitLargeWrites", "OPENFGA_DATASTORE_SPLIT_LARGE_WRITES", "OPENFGA_DATASTORE_SPLITLARGEWRITES")
+
+		util.MustBindPFlag("datastore.autoMigrate", flags.Lookup("datastore-auto-migrate"))
+		util.MustBindEnv("datastore.autoMigrate", "OPENFGA_DATASTORE_AUTO_MIGRATE", "OPENFGA_DATASTORE_AUTOMIGRATE")
+
 		util.MustBindPFlag("datastore.maxCacheSize", flags.Lookup("datastore-max-cache-size"))
 		util.MustBindEnv("datastore.maxCacheSize", "OPENFGA_DATASTORE_MAX_CACHE_SIZE", "OPENFGA_DATASTORE_MAXCACHESIZE")
 
@@ -99,6 +138,21 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("datastore.connMaxLifetime", flags.Lookup("datastore-conn-max-lifetime"))
 		util.MustBindEnv("datastore.connMaxLifetime", "OPENFGA_DATASTORE_CONN_MAX_LIFETIME", "OPENFGA_DATASTORE_CONNMAXLIFETIME")
 
+		util.MustBindPFlag("datastore.readTimeout", flags.Lookup("datastore-read-timeout"))
+		util.MustBindEnv("datastore.readTimeout", "OPENFGA_DATASTORE_READ_TIMEOUT", "OPENFGA_DATASTORE_READTIMEOUT")
+
+		util.MustBindPFlag("datastore.writeTimeout", flags.Lookup("datastore-write-timeout"))
+		util.MustBindEnv("datastore.writeTimeout", "OPENFGA_DATASTORE_WRITE_TIMEOUT", "OPENFGA_DATASTORE_WRITETIMEOUT")
+
+		util.MustBindPFlag("datastore.changelogBatchDelay", flags.Lookup("datastore-changelog-batch-delay"))
+		util.MustBindEnv("datastore.changelogBatchDelay", "OPENFGA_DATASTORE_CHANGELOG_BATCH_DELAY", "OPENFGA_DATASTORE_CHANGELOGBATCHDELAY")
+
+		util.MustBindPFlag("datastore.changelogBatchMaxWrites", flags.Lookup("datastore-changelog-batch-max-writes"))
+		util.MustBindEnv("datastore.changelogBatchMaxWrites", "OPENFGA_DATASTORE_CHANGELOG_BATCH_MAX_WRITES", "OPENFGA_DATASTORE_CHANGELOGBATCHMAXWRITES")
+
+		util.MustBindPFlag("datastore.changelogBatchMaxConcurrentGroups", flags.Lookup("datastore-changelog-batch-max-concurrent-groups"))
+		util.MustBindEnv("datastore.changelogBatchMaxConcurrentGroups", "OPENFGA_DATASTORE_CHANGELOG_BATCH_MAX_CONCURRENT_GROUPS", "OPENFGA_DATASTORE_CHANGELOGBATCHMAXCONCURRENTGROUPS")
+
 		util.MustBindPFlag("datastore.metrics.enabled", flags.Lookup("datastore-metrics-enabled"))
 		util.MustBindEnv("datastore.metrics.enabled", "OPENFGA_DATASTORE_METRICS_ENABLED")
 
@@ -150,12 +204,21 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("maxTuplesPerWrite", flags.Lookup("max-tuples-per-write"))
 		util.MustBindEnv("maxTuplesPerWrite", "OPENFGA_MAX_TUPLES_PER_WRITE", "OPENFGA_MAXTUPLESPERWRITE")
 
+		util.MustBindPFlag("writeConflictStrategy", flags.Lookup("write-conflict-strategy"))
+		util.MustBindEnv("writeConflictStrategy", "OPENFGA_WRITE_CONFLICT_STRATEGY", "OPENFGA_WRITECONFLICTSTRATEGY")
+
+		util.MustBindPFlag("writeCompleteConditionContext", flags.Lookup("write-complete-condition-context"))
+		util.MustBindEnv("writeCompleteConditionContext", "OPENFGA_WRITE_COMPLETE_CONDITION_CONTEXT", "OPENFGA_WRITECOMPLETECONDITIONCONTEXT")
+
 		util.MustBindPFlag("maxTypesPerAuthorizationModel", flags.Lookup("max-types-per-authorization-model"))
 		util.MustBindEnv("maxTypesPerAuthorizationModel", "OPENFGA_MAX_TYPES_PER_AUTHORIZATION_MODEL", "OPENFGA_MAXTYPESPERAUTHORIZATIONMODEL")
 
 		util.MustBindPFlag("maxAuthorizationModelSizeInBytes", flags.Lookup("max-authorization-model-size-in-bytes"))
 		util.MustBindEnv("maxAuthorizationModelSizeInBytes", "OPENFGA_MAX_AUTHORIZATION_MODEL_SIZE_IN_BYTES", "OPENFGA_MAXAUTHORIZATIONMODELSIZEINBYTES")
 
+		util.MustBindPFlag("maxAuthorizationModelsPerStore", flags.Lookup("max-authorization-models-per-store"))
+		util.MustBindEnv("maxAuthorizationModelsPerStore", "OPENFGA_MAX_AUTHORIZATION_MODELS_PER_STORE", "OPENFGA_MAXAUTHORIZATIONMODELSPERSTORE")
+
 		util.MustBindPFlag("maxConcurrentReadsForListObjects", flags.Lookup("max-concurrent-reads-for-list-objects"))
 		util.MustBindEnv("maxConcurrentReadsForListObjects", "OPENFGA_MAX_CONCURRENT_READS_FOR_LIST_OBJECTS", "OPENFGA_MAXCONCURRENTREADSFORLISTOBJECTS")
 
@@ -171,24 +234,48 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("changelogHorizonOffset", flags.Lookup("changelog-horizon-offset"))
 		util.MustBindEnv("changelogHorizonOffset", "OPENFGA_CHANGELOG_HORIZON_OFFSET", "OPENFGA_CHANGELOGHORIZONOFFSET")
 
+		util.MustBindPFlag("watchPollInterval", flags.Lookup("watch-poll-interval"))
+		util.MustBindEnv("watchPollInterval", "OPENFGA_WATCH_POLL_INTERVAL", "OPENFGA_WATCHPOLLINTERVAL")
+
+		util.MustBindPFlag("watchMaxConcurrentStreams", flags.Lookup("watch-max-concurrent-streams"))
+		util.MustBindEnv("watchMaxConcurrentStreams", "OPENFGA_WATCH_MAX_CONCURRENT_STREAMS", "OPENFGA_WATCHMAXCONCURRENTSTREAMS")
+
 		util.MustBindPFlag("resolveNodeLimit", flags.Lookup("resolve-node-limit"))
 		util.MustBindEnv("resolveNodeLimit", "OPENFGA_RESOLVE_NODE_LIMIT", "OPENFGA_RESOLVENODELIMIT")
 
 		util.MustBindPFlag("resolveNodeBreadthLimit", flags.Lookup("resolve-node-breadth-limit"))
 		util.MustBindEnv("resolveNodeBreadthLimit", "OPENFGA_RESOLVE_NODE_BREADTH_LIMIT", "OPENFGA_RESOLVENODEBREADTHLIMIT")
 
+		util.MustBindPFlag("checkMaxVisitedObjects", flags.Lookup("check-max-visited-objects"))
+		util.MustBindEnv("checkMaxVisitedObjects", "OPENFGA_CHECK_MAX_VISITED_OBJECTS", "OPENFGA_CHECKMAXVISITEDOBJECTS")
+
 		util.MustBindPFlag("listObjectsDeadline", flags.Lookup("listObjects-deadline"))
 		util.MustBindEnv("listObjectsDeadline", "OPENFGA_LIST_OBJECTS_DEADLINE", "OPENFGA_LISTOBJECTSDEADLINE")
 
 		util.MustBindPFlag("listObjectsMaxResults", flags.Lookup("listObjects-max-results"))
 		util.MustBindEnv("listObjectsMaxResults", "OPENFGA_LIST_OBJECTS_MAX_RESULTS", "OPENFGA_LISTOBJECTSMAXRESULTS")
 
+		util.MustBindPFlag("listObjectsCheckFallback", flags.Lookup("listObjects-check-fallback"))
+		util.MustBindEnv("listObjectsCheckFallback", "OPENFGA_LIST_OBJECTS_CHECK_FALLBACK", "OPENFGA_LISTOBJECTSCHECKFALLBACK")
+
+		util.MustBindPFlag("reverseExpansionParallelism", flags.Lookup("reverse-expansion-parallelism"))
+		util.MustBindEnv("reverseExpansionParallelism", "OPENFGA_REVERSE_EXPANSION_PARALLELISM", "OPENFGA_REVERSEEXPANSIONPARALLELISM")
+
+		util.MustBindPFlag("reverseExpansionBackoffLatencyThreshold", flags.Lookup("reverse-expansion-backoff-latency-threshold"))
+		util.MustBindEnv("reverseExpansionBackoffLatencyThreshold", "OPENFGA_REVERSE_EXPANSION_BACKOFF_LATENCY_THRESHOLD", "OPENFGA_REVERSEEXPANSIONBACKOFFLATENCYTHRESHOLD")
+
+		util.MustBindPFlag("reverseExpansionMaxBackoff", flags.Lookup("reverse-expansion-max-backoff"))
+		util.MustBindEnv("reverseExpansionMaxBackoff", "OPENFGA_REVERSE_EXPANSION_MAX_BACKOFF", "OPENFGA_REVERSEEXPANSIONMAXBACKOFF")
+
 		util.MustBindPFlag("listUsersDeadline", flags.Lookup("listUsers-deadline"))
 		util.MustBindEnv("listUsersDeadline", "OPENFGA_LIST_USERS_DEADLINE", "OPENFGA_LISTUSERSDEADLINE")
 
 		util.MustBindPFlag("listUsersMaxResults", flags.Lookup("listUsers-max-results"))
 		util.MustBindEnv("listUsersMaxResults", "OPENFGA_LIST_USERS_MAX_RESULTS", "OPENFGA_LISTUSERSMAXRESULTS")
 
+		util.MustBindPFlag("expandMaxDirectUsers", flags.Lookup("expand-max-direct-users"))
+		util.MustBindEnv("expandMaxDirectUsers", "OPENFGA_EXPAND_MAX_DIRECT_USERS", "OPENFGA_EXPANDMAXDIRECTUSERS")
+
 		util.MustBindPFlag("checkQueryCache.enabled", flags.Lookup("check-query-cache-enabled"))
 		util.MustBindEnv("checkQueryCache.enabled", "OPENFGA_CHECK_QUERY_CACHE_ENABLED")
 
@@ -198,6 +285,27 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("checkQueryCache.ttl", flags.Lookup("check-query-cache-ttl"))
 		util.MustBindEnv("checkQueryCache.ttl", "OPENFGA_CHECK_QUERY_CACHE_TTL")
 
+		util.MustBindPFlag("checkQueryCache.positiveTtl", flags.Lookup("check-query-cache-positive-ttl"))
+		util.MustBindEnv("checkQueryCache.positiveTtl", "OPENFGA_CHECK_QUERY_CACHE_POSITIVE_TTL")
+
+		util.MustBindPFlag("checkQueryCache.negativeTtl", flags.Lookup("check-query-cache-negative-ttl"))
+		util.MustBindEnv("checkQueryCache.negativeTtl", "OPENFGA_CHECK_QUERY_CACHE_NEGATIVE_TTL")
+
+		util.MustBindPFlag("checkQueryCache.emptyObjectTtl", flags.Lookup("check-query-cache-empty-object-ttl"))
+		util.MustBindEnv("checkQueryCache.emptyObjectTtl", "OPENFGA_CHECK_QUERY_CACHE_EMPTY_OBJECT_TTL")
+
+		util.MustBindPFlag("checkQueryCache.rejectNonDeterministicConditions", flags.Lookup("check-query-cache-reject-non-deterministic-conditions"))
+		util.MustBindEnv("checkQueryCache.rejectNonDeterministicConditions", "OPENFGA_CHECK_QUERY_CACHE_REJECT_NON_DETERMINISTIC_CONDITIONS")
+
+		util.MustBindPFlag("checkQueryCache.backend", flags.Lookup("check-query-cache-backend"))
+		util.MustBindEnv("checkQueryCache.backend", "OPENFGA_CHECK_QUERY_CACHE_BACKEND")
+
+		util.MustBindPFlag("checkQueryCache.redisUrl", flags.Lookup("check-query-cache-redis-url"))
+		util.MustBindEnv("checkQueryCache.redisUrl", "OPENFGA_CHECK_QUERY_CACHE_REDIS_URL")
+
+		util.MustBindPFlag("checkQueryCache.changelogInvalidationInterval", flags.Lookup("check-query-cache-changelog-invalidation-interval"))
+		util.MustBindEnv("checkQueryCache.changelogInvalidationInterval", "OPENFGA_CHECK_QUERY_CACHE_CHANGELOG_INVALIDATION_INTERVAL")
+
 		util.MustBindPFlag("requestDurationDatastoreQueryCountBuckets", flags.Lookup("request-duration-datastore-query-count-buckets"))
 		util.MustBindEnv("requestDurationDatastoreQueryCountBuckets", "OPENFGA_REQUEST_DURATION_DATASTORE_QUERY_COUNT_BUCKETS")
 
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	storeIDFlag = "store-id"
+	outputFlag  = "output"
+
+	// exportPageSize is the number of stores, models or tuples read at once.
+	exportPageSize = 100
+)
+
+func NewExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export stores to a file",
+		Long: "Export the stores of the datastore, with their settings, authorization models, assertions and tuples, " +
+			"to a file of JSON lines that 'openfga store import' can import into any datastore engine.\n" +
+			"The export isn't a snapshot: the writes to a store while it's exported may or may not be exported. " +
+			"The changelog of the stores isn't exported.",
+		RunE: runExport,
+		Args: cobra.NoArgs,
+	}
+
+	flags := cmd.Flags()
+	flags.String(datastoreEngineFlag, "", "the datastore engine ('postgres', 'mysql' or 'sqlserver')")
+	flags.String(datastoreURIFlag, "", "the connection uri to the datastore")
+	flags.StringSlice(storeIDFlag, nil, "the IDs of the stores to export (if empty, all the stores are exported)")
+	flags.String(outputFlag, "", "the file to export to (if empty, the standard output)")
+
+	// NOTE: if you add a new flag here, update the function below, too
+
+	cmd.PreRun = bindExportFlagsFunc(flags)
+
+	return cmd
+}
+
+func runExport(cmd *cobra.Command, _ []string) error {
+	db, err := openDatastore(viper.GetString(datastoreEngineFlag), viper.GetString(datastoreURIFlag))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	out := cmd.OutOrStdout()
+	if output := viper.GetString(outputFlag); output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create the output file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	summary, err := Export(ctx, db, out, viper.GetStringSlice(storeIDFlag))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "exported %s\n", summary)
+	return nil
+}
+
+// Export writes the stores with the given IDs, or all the stores if none is given, to w.
+func Export(ctx context.Context, db storage.OpenFGADatastore, w io.Writer, storeIDs []string) (Summary, error) {
+	e := &exporter{db: db, out: bufio.NewWriter(w)}
+
+	if err := e.write(&record{Version: formatVersion}); err != nil {
+		return Summary{}, err
+	}
+
+	if len(storeIDs) == 0 {
+		var err error
+		if storeIDs, err = e.listStores(ctx); err != nil {
+			return Summary{}, err
+		}
+	}
+
+	for _, storeID := range storeIDs {
+		if err := e.exportStore(ctx, storeID); err != nil {
+			return Summary{}, fmt.Errorf("failed to export the store '%s': %w", storeID, err)
+		}
+	}
+
+	if err := e.out.Flush(); err != nil {
+		return Summary{}, err
+	}
+	return e.summary, nil
+}
+
+type exporter struct {
+	db      storage.OpenFGADatastore
+	out     *bufio.Writer
+	summary Summary
+}
+
+func (e *exporter) listStores(ctx context.Context) ([]string, error) {
+	var storeIDs []string
+	token := ""
+	for {
+		stores, next, err := e.db.ListStores(ctx, storage.ListStoresOptions{
+			Pagination: storage.NewPaginationOptions(exportPageSize, token),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the stores: %w", err)
+		}
+		for _, store := range stores {
+			storeIDs = append(storeIDs, store.GetId())
+		}
+		if len(next) == 0 {
+			return storeIDs, nil
+		}
+		token = string(next)
+	}
+}
+
+func (e *exporter) exportStore(ctx context.Context, storeID string) error {
+	store, err := e.db.GetStore(ctx, storeID)
+	if err != nil {
+		return err
+	}
+	rec := &record{}
+	if err := e.writeMessage(rec, &rec.Store, store); err != nil {
+		return err
+	}
+	e.summary.Stores++
+
+	if backend, ok := e.db.(storage.StoreSettingsBackend); ok {
+		settings, err := backend.ReadStoreSettings(ctx, storeID)
+		if err != nil {
+			return err
+		}
+		if exported := newStoreSettings(settings); exported != nil {
+			if err := e.write(&record{StoreID: storeID, StoreSettings: exported}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := e.exportAuthorizationModels(ctx, storeID); err != nil {
+		return err
+	}
+	return e.exportTuples(ctx, storeID)
+}
+
+// exportAuthorizationModels exports the models of the store from the oldest to the latest, so that the latest
+// model is imported last, and the assertions of each model after it.
+func (e *exporter) exportAuthorizationModels(ctx context.Context, storeID string) error {
+	var models []*openfgav1.AuthorizationModel
+	token := ""
+	for {
+		page, next, err := e.db.ReadAuthorizationModels(ctx, storeID, storage.ReadAuthorizationModelsOptions{
+			Pagination: storage.NewPaginationOptions(exportPageSize, token),
+		})
+		if err != nil {
+			return err
+		}
+		models = append(models, page...)
+		if len(next) == 0 {
+			break
+		}
+		token = string(next)
+	}
+	slices.Reverse(models)
+
+	for _, model := range models {
+		rec := &record{StoreID: storeID}
+		if err := e.writeMessage(rec, &rec.AuthorizationModel, model); err != nil {
+			return err
+		}
+		e.summary.AuthorizationModels++
+
+		assertions, err := e.db.ReadAssertions(ctx, storeID, model.GetId())
+		if err != nil {
+			return err
+		}
+		if len(assertions) == 0 {
+			continue
+		}
+		rec = &record{StoreID: storeID}
+		if err := e.writeMessage(rec, &rec.Assertions, &openfgav1.ReadAssertionsResponse{
+			AuthorizationModelId: model.GetId(),
+			Assertions:           assertions,
+		}); err != nil {
+			return err
+		}
+		e.summary.Assertions += len(assertions)
+	}
+	return nil
+}
+
+func (e *exporter) exportTuples(ctx context.Context, storeID string) error {
+	token := ""
+	for {
+		tuples, next, err := e.db.ReadPage(ctx, storeID, &openfgav1.TupleKey{}, storage.ReadPageOptions{
+			Pagination: storage.NewPaginationOptions(exportPageSize, token),
+		})
+		if err != nil {
+			return err
+		}
+		for _, tuple := range tuples {
+			rec := &record{StoreID: storeID}
+			if err := e.writeMessage(rec, &rec.Tuple, tuple.GetKey()); err != nil {
+				return err
+			}
+			e.summary.Tuples++
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		token = string(next)
+	}
+}
+
+// writeMessage sets the field of the record to the message, and writes the record.
+func (e *exporter) writeMessage(rec *record, field *json.RawMessage, m proto.Message) error {
+	data, err := marshalMessage(m)
+	if err != nil {
+		return err
+	}
+	*field = data
+	return e.write(rec)
+}
+
+func (e *exporter) write(rec *record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	_, err = e.out.Write(line)
+	return err
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/openfga/openfga/cmd/util"
+)
+
+// bindExportFlagsFunc binds the cobra cmd flags to the equivalent config value being managed
+// by viper. This bridges the config between cobra flags and viper flags.
+func bindExportFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		bindDatastoreFlags(flags)
+
+		util.MustBindPFlag(storeIDFlag, flags.Lookup(storeIDFlag))
+		util.MustBindPFlag(outputFlag, flags.Lookup(outputFlag))
+	}
+}
+
+// bindImportFlagsFunc binds the cobra cmd flags to the equivalent config value being managed
+// by viper. This bridges the config between cobra flags and viper flags.
+func bindImportFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		bindDatastoreFlags(flags)
+
+		util.MustBindPFlag(inputFlag, flags.Lookup(inputFlag))
+		util.MustBindPFlag(batchSizeFlag, flags.Lookup(batchSizeFlag))
+		util.MustBindPFlag(progressFileFlag, flags.Lookup(progressFileFlag))
+		util.MustBindPFlag(dryRunFlag, flags.Lookup(dryRunFlag))
+	}
+}
+
+func bindDatastoreFlags(flags *pflag.FlagSet) {
+	util.MustBindPFlag(datastoreEngineFlag, flags.Lookup(datastoreEngineFlag))
+	util.MustBindEnv(datastoreEngineFlag, "OPENFGA_DATASTORE_ENGINE")
+
+	util.MustBindPFlag(datastoreURIFlag, flags.Lookup(datastoreURIFlag))
+	util.MustBindEnv(datastoreURIFlag, "OPENFGA_DATASTORE_URI")
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// formatVersion is the version of the format of the export files. It must be incremented whenever the format
+// changes in a way that the previous versions of the import can't read.
+const formatVersion = 1
+
+// record is a line of an export file, in JSON. The first line of a file is a header, that only has the version of
+// the format. Every other line has one of the other fields, along with the ID of the store it belongs to unless it
+// is the Store record of the store. The records of a store follow its Store record.
+type record struct {
+	Version int `json:"version,omitempty"`
+
+	StoreID string `json:"store_id,omitempty"`
+
+	// Store is an openfgav1.Store.
+	Store         json.RawMessage `json:"store,omitempty"`
+	StoreSettings *storeSettings  `json:"store_settings,omitempty"`
+	// AuthorizationModel is an openfgav1.AuthorizationModel. The models of a store are ordered from the oldest
+	// to the latest.
+	AuthorizationModel json.RawMessage `json:"authorization_model,omitempty"`
+	// Assertions are an openfgav1.ReadAssertionsResponse, with the assertions of an authorization model.
+	Assertions json.RawMessage `json:"assertions,omitempty"`
+	// Tuple is an openfgav1.TupleKey.
+	Tuple json.RawMessage `json:"tuple,omitempty"`
+}
+
+// storeSettings are the storage.StoreSettings of a store, in the export files.
+type storeSettings struct {
+	AllowedObjectTypes     []string          `json:"allowed_object_types,omitempty"`
+	DefaultPageSize        int32             `json:"default_page_size,omitempty"`
+	RelationAliases        map[string]string `json:"relation_aliases,omitempty"`
+	DefaultUserType        string            `json:"default_user_type,omitempty"`
+	MaxAuthorizationModels int32             `json:"max_authorization_models,omitempty"`
+}
+
+// newStoreSettings returns the settings to export, or nil if they are all unset.
+func newStoreSettings(settings *storage.StoreSettings) *storeSettings {
+	if settings == nil || (len(settings.AllowedObjectTypes) == 0 && settings.DefaultPageSize == 0 &&
+		len(settings.RelationAliases) == 0 && settings.DefaultUserType == "" && settings.MaxAuthorizationModels == 0) {
+		return nil
+	}
+	return &storeSettings{
+		AllowedObjectTypes:     settings.AllowedObjectTypes,
+		DefaultPageSize:        settings.DefaultPageSize,
+		RelationAliases:        settings.RelationAliases,
+		DefaultUserType:        settings.DefaultUserType,
+		MaxAuthorizationModels: settings.MaxAuthorizationModels,
+	}
+}
+
+func (s *storeSettings) toStorage() *storage.StoreSettings {
+	return &storage.StoreSettings{
+		AllowedObjectTypes:     s.AllowedObjectTypes,
+		DefaultPageSize:        s.DefaultPageSize,
+		RelationAliases:        s.RelationAliases,
+		DefaultUserType:        s.DefaultUserType,
+		MaxAuthorizationModels: s.MaxAuthorizationModels,
+	}
+}
+
+func marshalMessage(m proto.Message) (json.RawMessage, error) {
+	return protojson.Marshal(m)
+}
+
+func unmarshalMessage(data json.RawMessage, m proto.Message) error {
+	if err := protojson.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("invalid %s: %w", m.ProtoReflect().Descriptor().Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	inputFlag        = "input"
+	batchSizeFlag    = "batch-size"
+	progressFileFlag = "progress-file"
+	dryRunFlag       = "dry-run"
+
+	// maxRecordSize is the maximum size of a line of an export file, i.e. of an authorization model or of the
+	// assertions of a model.
+	maxRecordSize = 16 * 1024 * 1024
+
+	// dryRunBatchSize is the number of tuples of a batch when no datastore bounds it.
+	dryRunBatchSize = 100
+)
+
+func NewImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import stores from a file",
+		Long: "Import the stores of a file exported by 'openfga store export' into the datastore, which may be of " +
+			"another engine than the exported one.\n" +
+			"The stores, authorization models and tuples already in the datastore are left as they are, so an " +
+			"interrupted import can be run again. With a progress file, the number of records imported is saved " +
+			"after each write, and the records imported by a previous run are skipped.\n" +
+			"The tuples are written in batches, which are each written atomically. The imported stores have the IDs, " +
+			"names and authorization model IDs of the exported ones, but their tuples are timestamped with the time " +
+			"of the import, and their changelog starts with the import.",
+		RunE: runImport,
+		Args: cobra.NoArgs,
+	}
+
+	flags := cmd.Flags()
+	flags.String(datastoreEngineFlag, "", "the datastore engine ('postgres', 'mysql' or 'sqlserver')")
+	flags.String(datastoreURIFlag, "", "the connection uri to the datastore")
+	flags.String(inputFlag, "", "the file to import from (if empty, the standard input)")
+	flags.Int(batchSizeFlag, 0, "the maximum number of tuples written at once (if 0, the maximum number of tuples per write of the datastore)")
+	flags.String(progressFileFlag, "", "the file that the progress of the import is saved to, and resumed from")
+	flags.Bool(dryRunFlag, false, "read and check the file without connecting to the datastore nor importing anything")
+
+	// NOTE: if you add a new flag here, update the function below, too
+
+	cmd.PreRun = bindImportFlagsFunc(flags)
+
+	return cmd
+}
+
+func runImport(cmd *cobra.Command, _ []string) error {
+	opts := ImportOptions{
+		BatchSize:    viper.GetInt(batchSizeFlag),
+		ProgressFile: viper.GetString(progressFileFlag),
+		DryRun:       viper.GetBool(dryRunFlag),
+	}
+
+	var db storage.OpenFGADatastore
+	if !opts.DryRun {
+		var err error
+		if db, err = openDatastore(viper.GetString(datastoreEngineFlag), viper.GetString(datastoreURIFlag)); err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	in := cmd.InOrStdin()
+	if input := viper.GetString(inputFlag); input != "" {
+		file, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open the input file: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	summary, err := Import(ctx, db, in, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		fmt.Fprintf(cmd.OutOrStdout(), "would import %s\n", summary)
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "imported %s\n", summary)
+	}
+	return nil
+}
+
+// ImportOptions configure Import.
+type ImportOptions struct {
+	// BatchSize is the maximum number of tuples written at once. If zero, it's the maximum number of tuples per
+	// write of the datastore.
+	BatchSize int
+	// ProgressFile, if set, is the file that the number of records imported is saved to after each write, and that
+	// the records imported by a previous import are skipped according to.
+	ProgressFile string
+	// DryRun reads and checks the records without importing them, and without a datastore.
+	DryRun bool
+}
+
+// Import imports the stores read from r into the datastore, and returns the number of records imported, not
+// counting the ones skipped according to the progress file.
+func Import(ctx context.Context, db storage.OpenFGADatastore, r io.Reader, opts ImportOptions) (Summary, error) {
+	imp := &importer{db: db, opts: opts, batchSize: opts.BatchSize}
+	if db != nil {
+		maxTuplesPerWrite := db.MaxTuplesPerWrite()
+		if imp.batchSize > maxTuplesPerWrite {
+			return Summary{}, fmt.Errorf("the batch size must not be greater than the maximum number of tuples per write of the datastore, %d", maxTuplesPerWrite)
+		}
+		if imp.batchSize <= 0 {
+			imp.batchSize = maxTuplesPerWrite
+		}
+	}
+	if imp.batchSize <= 0 {
+		imp.batchSize = dryRunBatchSize
+	}
+
+	imported, err := readProgress(opts.ProgressFile)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordSize)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return Summary{}, err
+		}
+		return Summary{}, fmt.Errorf("the file is empty")
+	}
+	var header record
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return Summary{}, fmt.Errorf("invalid header: %w", err)
+	}
+	if header.Version != formatVersion {
+		return Summary{}, fmt.Errorf("unsupported format version %d, expected %d", header.Version, formatVersion)
+	}
+
+	// the records are numbered from 1, after the header
+	for n := 1; scanner.Scan(); n++ {
+		if n <= imported {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return imp.summary, fmt.Errorf("invalid record on line %d: %w", n+1, err)
+		}
+		if err := imp.apply(ctx, n, &rec); err != nil {
+			return imp.summary, fmt.Errorf("failed to import the record on line %d: %w", n+1, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imp.summary, err
+	}
+
+	if err := imp.flush(ctx); err != nil {
+		return imp.summary, err
+	}
+	return imp.summary, nil
+}
+
+type importer struct {
+	db        storage.OpenFGADatastore
+	opts      ImportOptions
+	batchSize int
+	summary   Summary
+
+	// batch holds the tuples of batchStoreID that are not written yet, the last of which is the record batchEnd.
+	batch        []*openfgav1.TupleKey
+	batchStoreID string
+	batchEnd     int
+}
+
+// apply imports the record n, or adds it to the batch of tuples if it's a tuple.
+func (imp *importer) apply(ctx context.Context, n int, rec *record) error {
+	if rec.Tuple != nil {
+		if rec.StoreID != imp.batchStoreID || len(imp.batch) == imp.batchSize {
+			if err := imp.flush(ctx); err != nil {
+				return err
+			}
+		}
+
+		tk := &openfgav1.TupleKey{}
+		if err := unmarshalMessage(rec.Tuple, tk); err != nil {
+			return err
+		}
+		imp.batch = append(imp.batch, tk)
+		imp.batchStoreID = rec.StoreID
+		imp.batchEnd = n
+		return nil
+	}
+
+	if err := imp.flush(ctx); err != nil {
+		return err
+	}
+
+	var err error
+	switch {
+	case rec.Store != nil:
+		err = imp.importStore(ctx, rec.Store)
+	case rec.StoreSettings != nil:
+		err = imp.importStoreSettings(ctx, rec.StoreID, rec.StoreSettings)
+	case rec.AuthorizationModel != nil:
+		err = imp.importAuthorizationModel(ctx, rec.StoreID, rec.AuthorizationModel)
+	case rec.Assertions != nil:
+		err = imp.importAssertions(ctx, rec.StoreID, rec.Assertions)
+	default:
+		err = fmt.Errorf("unknown record")
+	}
+	if err != nil {
+		return err
+	}
+	return imp.saveProgress(n)
+}
+
+func (imp *importer) importStore(ctx context.Context, data json.RawMessage) error {
+	store := &openfgav1.Store{}
+	if err := unmarshalMessage(data, store); err != nil {
+		return err
+	}
+	imp.summary.Stores++
+	if imp.opts.DryRun {
+		return nil
+	}
+
+	_, err := imp.db.CreateStore(ctx, &openfgav1.Store{Id: store.GetId(), Name: store.GetName()})
+	if errors.Is(err, storage.ErrCollision) {
+		// imported already
+		return nil
+	}
+	return err
+}
+
+func (imp *importer) importStoreSettings(ctx context.Context, storeID string, settings *storeSettings) error {
+	if imp.opts.DryRun {
+		return nil
+	}
+
+	backend, ok := imp.db.(storage.StoreSettingsBackend)
+	if !ok {
+		return fmt.Errorf("the datastore doesn't support store settings")
+	}
+	return backend.WriteStoreSettings(ctx, storeID, settings.toStorage())
+}
+
+func (imp *importer) importAuthorizationModel(ctx context.Context, storeID string, data json.RawMessage) error {
+	model := &openfgav1.AuthorizationModel{}
+	if err := unmarshalMessage(data, model); err != nil {
+		return err
+	}
+	imp.summary.AuthorizationModels++
+	if imp.opts.DryRun {
+		return nil
+	}
+
+	_, err := imp.db.ReadAuthorizationModel(ctx, storeID, model.GetId())
+	switch {
+	case err == nil:
+		// imported already
+		return nil
+	case !errors.Is(err, storage.ErrNotFound):
+		return err
+	}
+	return imp.db.WriteAuthorizationModel(ctx, storeID, model)
+}
+
+func (imp *importer) importAssertions(ctx context.Context, storeID string, data json.RawMessage) error {
+	assertions := &openfgav1.ReadAssertionsResponse{}
+	if err := unmarshalMessage(data, assertions); err != nil {
+		return err
+	}
+	imp.summary.Assertions += len(assertions.GetAssertions())
+	if imp.opts.DryRun {
+		return nil
+	}
+
+	return imp.db.WriteAssertions(ctx, storeID, assertions.GetAuthorizationModelId(), assertions.GetAssertions())
+}
+
+// flush writes the batch of tuples. If some of them exist already, e.g. because they were written by an import
+// that was interrupted before saving its progress, only the others are written.
+func (imp *importer) flush(ctx context.Context) error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+
+	if !imp.opts.DryRun {
+		err := imp.db.Write(ctx, imp.batchStoreID, nil, imp.batch)
+		if errors.Is(err, storage.ErrInvalidWriteInput) {
+			var missing []*openfgav1.TupleKey
+			if missing, err = imp.missingTuples(ctx); err == nil && len(missing) > 0 {
+				err = imp.db.Write(ctx, imp.batchStoreID, nil, missing)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write the tuples of the store '%s': %w", imp.batchStoreID, err)
+		}
+	}
+	imp.summary.Tuples += len(imp.batch)
+
+	if err := imp.saveProgress(imp.batchEnd); err != nil {
+		return err
+	}
+	imp.batch = imp.batch[:0]
+	return nil
+}
+
+// missingTuples returns the tuples of the batch that don't exist in the datastore.
+func (imp *importer) missingTuples(ctx context.Context) ([]*openfgav1.TupleKey, error) {
+	var missing []*openfgav1.TupleKey
+	for _, tk := range imp.batch {
+		_, err := imp.db.ReadUserTuple(ctx, imp.batchStoreID, tk, storage.ReadUserTupleOptions{})
+		if errors.Is(err, storage.ErrNotFound) {
+			missing = append(missing, tk)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// saveProgress saves to the progress file that the records up to the record n were imported.
+func (imp *importer) saveProgress(n int) error {
+	if imp.opts.ProgressFile == "" || imp.opts.DryRun {
+		return nil
+	}
+
+	// the progress is written to a temporary file first, so that it's never saved partially
+	tmp := imp.opts.ProgressFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(n)+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to save the progress: %w", err)
+	}
+	if err := os.Rename(tmp, imp.opts.ProgressFile); err != nil {
+		return fmt.Errorf("failed to save the progress: %w", err)
+	}
+	return nil
+}
+
+// readProgress returns the number of records imported according to the progress file, which is zero if it
+// doesn't exist.
+func readProgress(file string) (int, error) {
+	if file == "" {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the progress: %w", err)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid progress file: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,73 @@
+// Package store contains the commands to export the stores of a datastore to a file, and to import them into
+// another datastore, e.g. of another engine.
+package store
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/mysql"
+	"github.com/openfga/openfga/pkg/storage/postgres"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+	"github.com/openfga/openfga/pkg/storage/sqlserver"
+)
+
+const (
+	datastoreEngineFlag = "datastore-engine"
+	datastoreURIFlag    = "datastore-uri"
+)
+
+func NewStoreCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "store",
+		Short: "Export and import stores",
+		Long: "Export the stores of a datastore to a file, and import them into a datastore, e.g. to move them to " +
+			"another datastore engine or to back them up.",
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(NewExportCommand())
+	cmd.AddCommand(NewImportCommand())
+
+	return cmd
+}
+
+// Summary counts the records exported or imported.
+type Summary struct {
+	Stores              int
+	AuthorizationModels int
+	Assertions          int
+	Tuples              int
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d stores, %d authorization models, %d assertions, %d tuples",
+		s.Stores, s.AuthorizationModels, s.Assertions, s.Tuples)
+}
+
+func openDatastore(engine, uri string) (storage.OpenFGADatastore, error) {
+	var (
+		db  storage.OpenFGADatastore
+		err error
+	)
+	switch engine {
+	case "mysql":
+		db, err = mysql.New(uri, sqlcommon.NewConfig())
+	case "postgres":
+		db, err = postgres.New(uri, sqlcommon.NewConfig())
+	case "sqlserver":
+		db, err = sqlserver.New(uri, sqlcommon.NewConfig())
+	case "":
+		return nil, fmt.Errorf("missing datastore engine type")
+	case "memory":
+		fallthrough
+	default:
+		return nil, fmt.Errorf("storage engine '%s' is unsupported", engine)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a connection to the datastore: %v", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,284 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/openfga/openfga/cmd/util"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+const numTuples = 7
+
+// seedStore creates a store with settings, two authorization models, assertions and tuples.
+func seedStore(t *testing.T, ds storage.OpenFGADatastore) string {
+	ctx := context.Background()
+
+	storeID := ulid.Make().String()
+	_, err := ds.CreateStore(ctx, &openfgav1.Store{Id: storeID, Name: "store-" + storeID})
+	require.NoError(t, err)
+
+	require.NoError(t, ds.(storage.StoreSettingsBackend).WriteStoreSettings(ctx, storeID, &storage.StoreSettings{
+		DefaultUserType: "user",
+		RelationAliases: map[string]string{"document#reader": "viewer"},
+	}))
+
+	for _, dsl := range []string{`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`, `
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user, user with valid]
+		condition valid(x: int) {
+			x < 100
+		}`,
+	} {
+		model := testutils.MustTransformDSLToProtoWithID(dsl)
+		require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+		require.NoError(t, ds.WriteAssertions(ctx, storeID, model.GetId(), []*openfgav1.Assertion{
+			{TupleKey: tuple.NewAssertionTupleKey("document:1", "viewer", "user:anne"), Expectation: true},
+		}))
+	}
+
+	tuples := make([]*openfgav1.TupleKey, 0, numTuples)
+	for i := 0; i < numTuples-1; i++ {
+		tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:anne"))
+	}
+	tuples = append(tuples, tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:bob", "valid", testutils.MustNewStruct(t, map[string]interface{}{"x": 1})))
+	require.NoError(t, ds.Write(ctx, storeID, nil, tuples))
+
+	return storeID
+}
+
+// requireSameStore asserts that the store of the datastores have the same contents.
+func requireSameStore(t *testing.T, expected, actual storage.OpenFGADatastore, storeID string) {
+	ctx := context.Background()
+
+	expectedStore, err := expected.GetStore(ctx, storeID)
+	require.NoError(t, err)
+	actualStore, err := actual.GetStore(ctx, storeID)
+	require.NoError(t, err)
+	require.Equal(t, expectedStore.GetName(), actualStore.GetName())
+
+	expectedSettings, err := expected.(storage.StoreSettingsBackend).ReadStoreSettings(ctx, storeID)
+	require.NoError(t, err)
+	actualSettings, err := actual.(storage.StoreSettingsBackend).ReadStoreSettings(ctx, storeID)
+	require.NoError(t, err)
+	require.Equal(t, expectedSettings, actualSettings)
+
+	expectedModels, _, err := expected.ReadAuthorizationModels(ctx, storeID, storage.ReadAuthorizationModelsOptions{})
+	require.NoError(t, err)
+	actualModels, _, err := actual.ReadAuthorizationModels(ctx, storeID, storage.ReadAuthorizationModelsOptions{})
+	require.NoError(t, err)
+	require.Empty(t, cmp.Diff(expectedModels, actualModels, protocmp.Transform()))
+
+	expectedLatest, err := expected.FindLatestAuthorizationModel(ctx, storeID)
+	require.NoError(t, err)
+	actualLatest, err := actual.FindLatestAuthorizationModel(ctx, storeID)
+	require.NoError(t, err)
+	require.Equal(t, expectedLatest.GetId(), actualLatest.GetId())
+
+	for _, model := range expectedModels {
+		expectedAssertions, err := expected.ReadAssertions(ctx, storeID, model.GetId())
+		require.NoError(t, err)
+		actualAssertions, err := actual.ReadAssertions(ctx, storeID, model.GetId())
+		require.NoError(t, err)
+		require.Empty(t, cmp.Diff(expectedAssertions, actualAssertions, protocmp.Transform()))
+	}
+
+	readTuples := func(ds storage.OpenFGADatastore) []*openfgav1.TupleKey {
+		tuples, _, err := ds.ReadPage(ctx, storeID, &openfgav1.TupleKey{}, storage.ReadPageOptions{
+			Pagination: storage.NewPaginationOptions(100, ""),
+		})
+		require.NoError(t, err)
+		keys := make([]*openfgav1.TupleKey, 0, len(tuples))
+		for _, tuple := range tuples {
+			keys = append(keys, tuple.GetKey())
+		}
+		return keys
+	}
+	require.Empty(t, cmp.Diff(readTuples(expected), readTuples(actual), protocmp.Transform(), testutils.TupleKeyCmpTransformer))
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	src := memory.New()
+	t.Cleanup(src.Close)
+	storeIDs := []string{seedStore(t, src), seedStore(t, src)}
+
+	export := &bytes.Buffer{}
+	summary, err := Export(ctx, src, export, nil)
+	require.NoError(t, err)
+	require.Equal(t, Summary{Stores: 2, AuthorizationModels: 4, Assertions: 4, Tuples: 2 * numTuples}, summary)
+
+	t.Run("imports_all_the_stores", func(t *testing.T) {
+		dst := memory.New()
+		t.Cleanup(dst.Close)
+
+		summary, err := Import(ctx, dst, bytes.NewReader(export.Bytes()), ImportOptions{BatchSize: 3})
+		require.NoError(t, err)
+		require.Equal(t, Summary{Stores: 2, AuthorizationModels: 4, Assertions: 4, Tuples: 2 * numTuples}, summary)
+
+		for _, storeID := range storeIDs {
+			requireSameStore(t, src, dst, storeID)
+		}
+
+		// importing again leaves the stores as they are
+		_, err = Import(ctx, dst, bytes.NewReader(export.Bytes()), ImportOptions{})
+		require.NoError(t, err)
+		for _, storeID := range storeIDs {
+			requireSameStore(t, src, dst, storeID)
+		}
+	})
+
+	t.Run("exports_the_given_stores", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		summary, err := Export(ctx, src, out, storeIDs[1:])
+		require.NoError(t, err)
+		require.Equal(t, 1, summary.Stores)
+		require.NotContains(t, out.String(), storeIDs[0])
+	})
+
+	t.Run("resumes_from_the_progress_file", func(t *testing.T) {
+		dst := memory.New()
+		t.Cleanup(dst.Close)
+		progressFile := filepath.Join(t.TempDir(), "progress")
+
+		// the first import is interrupted in the middle of the tuples of the first store
+		lines := strings.SplitAfter(export.String(), "\n")
+		interrupted := strings.Join(lines[:len(lines)/3], "")
+		_, err := Import(ctx, dst, strings.NewReader(interrupted), ImportOptions{BatchSize: 2, ProgressFile: progressFile})
+		require.NoError(t, err)
+
+		progress, err := os.ReadFile(progressFile)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("%d\n", len(lines)/3-1), string(progress))
+
+		summary, err := Import(ctx, dst, bytes.NewReader(export.Bytes()), ImportOptions{BatchSize: 2, ProgressFile: progressFile})
+		require.NoError(t, err)
+		require.Equal(t, 1, summary.Stores)
+		for _, storeID := range storeIDs {
+			requireSameStore(t, src, dst, storeID)
+		}
+	})
+
+	t.Run("writes_the_tuples_missing_from_a_batch_written_partially", func(t *testing.T) {
+		dst := memory.New()
+		t.Cleanup(dst.Close)
+
+		_, err := Import(ctx, dst, bytes.NewReader(export.Bytes()), ImportOptions{})
+		require.NoError(t, err)
+		require.NoError(t, dst.Write(ctx, storeIDs[0], []*openfgav1.TupleKeyWithoutCondition{
+			tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:0", "viewer", "user:anne")),
+		}, nil))
+
+		_, err = Import(ctx, dst, bytes.NewReader(export.Bytes()), ImportOptions{})
+		require.NoError(t, err)
+		requireSameStore(t, src, dst, storeIDs[0])
+	})
+
+	t.Run("dry_run", func(t *testing.T) {
+		summary, err := Import(ctx, nil, bytes.NewReader(export.Bytes()), ImportOptions{DryRun: true})
+		require.NoError(t, err)
+		require.Equal(t, Summary{Stores: 2, AuthorizationModels: 4, Assertions: 4, Tuples: 2 * numTuples}, summary)
+	})
+
+	t.Run("batch_size_greater_than_the_maximum_tuples_per_write", func(t *testing.T) {
+		dst := memory.New(memory.WithMaxTuplesPerWrite(10))
+		t.Cleanup(dst.Close)
+
+		_, err := Import(ctx, dst, bytes.NewReader(export.Bytes()), ImportOptions{BatchSize: 11})
+		require.ErrorContains(t, err, "the batch size must not be greater than the maximum number of tuples per write")
+	})
+
+	t.Run("unsupported_format_version", func(t *testing.T) {
+		_, err := Import(ctx, nil, strings.NewReader(`{"version":2}`), ImportOptions{DryRun: true})
+		require.ErrorContains(t, err, "unsupported format version 2")
+
+		_, err = Import(ctx, nil, strings.NewReader(""), ImportOptions{DryRun: true})
+		require.ErrorContains(t, err, "the file is empty")
+	})
+
+	t.Run("invalid_record", func(t *testing.T) {
+		_, err := Import(ctx, nil, strings.NewReader("{\"version\":1}\n{\"store_id\":\"a\"}\n"), ImportOptions{DryRun: true})
+		require.ErrorContains(t, err, "failed to import the record on line 2: unknown record")
+
+		_, err = Import(ctx, nil, strings.NewReader("{\"version\":1}\n{\"tuple\":{\"object\":1}}\n"), ImportOptions{DryRun: true})
+		require.ErrorContains(t, err, "invalid TupleKey")
+	})
+}
+
+func TestExportImportCommands(t *testing.T) {
+	_, src, srcURI := util.MustBootstrapDatastore(t, "postgres")
+	_, dst, dstURI := util.MustBootstrapDatastore(t, "mysql")
+
+	storeID := seedStore(t, src)
+	file := filepath.Join(t.TempDir(), "export.jsonl")
+
+	exportCmd := NewStoreCommand()
+	exportCmd.SetErr(&bytes.Buffer{})
+	exportCmd.SetArgs([]string{"export", "--datastore-engine", "postgres", "--datastore-uri", srcURI, "--store-id", storeID, "--output", file})
+	require.NoError(t, exportCmd.Execute())
+
+	out := &bytes.Buffer{}
+	importCmd := NewStoreCommand()
+	importCmd.SetOut(out)
+	importCmd.SetArgs([]string{"import", "--datastore-engine", "mysql", "--datastore-uri", dstURI, "--input", file})
+	require.NoError(t, importCmd.Execute())
+	require.Contains(t, out.String(), fmt.Sprintf("imported 1 stores, 2 authorization models, 2 assertions, %d tuples", numTuples))
+
+	requireSameStore(t, src, dst, storeID)
+}
+
+func TestStoreCommandsWhenInvalidEngine(t *testing.T) {
+	for _, subcommand := range []string{"export", "import"} {
+		for _, tc := range []struct {
+			engine        string
+			errorExpected string
+		}{
+			{engine: "memory", errorExpected: "storage engine 'memory' is unsupported"},
+			{engine: "", errorExpected: "missing datastore engine type"},
+		} {
+			t.Run(subcommand+"_"+tc.engine, func(t *testing.T) {
+				cmd := NewStoreCommand()
+				cmd.SetArgs([]string{subcommand, "--datastore-engine", tc.engine})
+				cmd.SetIn(strings.NewReader(""))
+				cmd.SetOut(&bytes.Buffer{})
+				cmd.SetErr(&bytes.Buffer{})
+				require.ErrorContains(t, cmd.Execute(), tc.errorExpected)
+			})
+		}
+	}
+}
+
+func TestImportCommandDryRun(t *testing.T) {
+	out := &bytes.Buffer{}
+	cmd := NewStoreCommand()
+	cmd.SetArgs([]string{"import", "--dry-run"})
+	cmd.SetIn(strings.NewReader("{\"version\":1}\n{\"store\":{\"id\":\"01J\",\"name\":\"a\"}}\n"))
+	cmd.SetOut(out)
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, out.String(), "would import 1 stores, 0 authorization models, 0 assertions, 0 tuples")
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/openfga/openfga/pkg/storage/mysql"
 	"github.com/openfga/openfga/pkg/storage/postgres"
 	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+	"github.com/openfga/openfga/pkg/storage/sqlserver"
 	storagefixtures "github.com/openfga/openfga/pkg/testfixtures/storage"
 )
 
@@ -64,6 +65,8 @@ func MustBootstrapDatastore(t testing.TB, engine string) (storagefixtures.Datast
 		ds, err = postgres.New(uri, sqlcommon.NewConfig())
 	case "mysql":
 		ds, err = mysql.New(uri, sqlcommon.NewConfig())
+	case "sqlserver":
+		ds, err = sqlserver.New(uri, sqlcommon.NewConfig())
 	default:
 		t.Fatalf("unsupported datastore engine: %q", engine)
 	}
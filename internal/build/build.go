@@ -15,7 +15,7 @@ var (
 
 	// MinimumSupportedDatastoreSchemaRevision refers to the minimum schema version that is required to run
 	// this specific build of OpenFGA. Refer to the `assets/migrations` artifacts for more information.
-	MinimumSupportedDatastoreSchemaRevision int64 = 4
+	MinimumSupportedDatastoreSchemaRevision int64 = 14
 
 	ProjectName = "openfga"
 )
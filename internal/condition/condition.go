@@ -29,7 +29,7 @@ func init() {
 		envOpts = append(envOpts, customTypeOpts...)
 	}
 
-	envOpts = append(envOpts, types.IPAddressEnvOption(), cel.EagerlyValidateDeclarations(true))
+	envOpts = append(envOpts, types.IPAddressEnvOption(), functionsEnvOption(), cel.EagerlyValidateDeclarations(true))
 
 	env, err := cel.NewEnv(envOpts...)
 	if err != nil {
@@ -58,6 +58,8 @@ type EvaluableCondition struct {
 	celEnv         *cel.Env
 	celProgram     cel.Program
 	compileOnce    sync.Once
+
+	nonDeterministicFunctions []string
 }
 
 // Compile compiles a condition expression with a CEL environment
@@ -66,13 +68,17 @@ type EvaluableCondition struct {
 func (e *EvaluableCondition) Compile() error {
 	var compileErr error
 
+	compiled := false
 	e.compileOnce.Do(func() {
+		compiled = true
 		if err := e.compile(); err != nil {
 			compileErr = err
 			return
 		}
 	})
 
+	metrics.Metrics.ObserveCompilationCacheLookup(e.Name, !compiled)
+
 	return compileErr
 }
 
@@ -142,9 +148,21 @@ func (e *EvaluableCondition) compile() error {
 
 	e.celEnv = env
 	e.celProgram = prg
+	e.nonDeterministicFunctions = nonDeterministicFunctionsOf(ast)
 	return nil
 }
 
+// NonDeterministicFunctions returns the sorted names of the non-deterministic functions, e.g. 'now', that the
+// condition expression calls, compiling it if it hasn't been done already. The result of a condition that calls
+// any of them must not be cached.
+func (e *EvaluableCondition) NonDeterministicFunctions() ([]string, error) {
+	if err := e.Compile(); err != nil {
+		return nil, err
+	}
+
+	return e.nonDeterministicFunctions, nil
+}
+
 // CastContextToTypedParameters converts the provided context to typed condition
 // parameters and returns an error if any additional context fields are provided
 // that are not defined by the evaluable condition.
@@ -182,6 +200,7 @@ func (e *EvaluableCondition) CastContextToTypedParameters(contextMap map[string]
 		if err != nil {
 			return nil, &ParameterTypeError{
 				Condition: e.Name,
+				Parameter: parameterKey,
 				Cause:     fmt.Errorf("failed to convert context parameter '%s': %w", parameterKey, err),
 			}
 		}
@@ -0,0 +1,121 @@
+package condition
+
+import (
+	"context"
+	"testing"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestEvaluateObservesCompilationCache(t *testing.T) {
+	cond := &openfgav1.Condition{
+		Name:       "compilation_cache_condition",
+		Expression: "param == 'ok'",
+		Parameters: map[string]*openfgav1.ConditionParamTypeRef{
+			"param": {TypeName: openfgav1.ConditionParamTypeRef_TYPE_NAME_STRING},
+		},
+	}
+
+	compiled, err := NewCompiled(cond)
+	require.NoError(t, err)
+
+	require.InDelta(t, 1, gatherConditionCounter(t, "openfga_condition_compilation_cache_total_count", cond.GetName()), 0)
+	require.InDelta(t, 0, gatherConditionCounter(t, "openfga_condition_compilation_cache_hit_count", cond.GetName()), 0)
+
+	contextMap := map[string]*structpb.Value{"param": structpb.NewStringValue("ok")}
+	for i := 0; i < 3; i++ {
+		result, err := compiled.Evaluate(context.Background(), contextMap)
+		require.NoError(t, err)
+		require.True(t, result.ConditionMet)
+	}
+
+	require.InDelta(t, 4, gatherConditionCounter(t, "openfga_condition_compilation_cache_total_count", cond.GetName()), 0)
+	require.InDelta(t, 3, gatherConditionCounter(t, "openfga_condition_compilation_cache_hit_count", cond.GetName()), 0)
+}
+
+// gatherConditionCounter returns the value of a counter for a condition name from the default registry.
+func gatherConditionCounter(t *testing.T, metricName, conditionName string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != metricName {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "condition_name" && label.GetValue() == conditionName {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestNonDeterministicFunctions(t *testing.T) {
+	tests := map[string]struct {
+		expression string
+		expected   []string
+	}{
+		"now": {
+			expression: "now() < expiration",
+			expected:   []string{"now"},
+		},
+		"now_nested_in_macro": {
+			expression: "[expiration].all(e, e > now())",
+			expected:   []string{"now"},
+		},
+		"parameters_only": {
+			expression: "current_time < expiration",
+			expected:   []string{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cond := NewUncompiled(&openfgav1.Condition{
+				Name:       "condition",
+				Expression: test.expression,
+				Parameters: map[string]*openfgav1.ConditionParamTypeRef{
+					"current_time": {TypeName: openfgav1.ConditionParamTypeRef_TYPE_NAME_TIMESTAMP},
+					"expiration":   {TypeName: openfgav1.ConditionParamTypeRef_TYPE_NAME_TIMESTAMP},
+				},
+			})
+
+			functions, err := cond.NonDeterministicFunctions()
+			require.NoError(t, err)
+			require.Equal(t, test.expected, functions)
+		})
+	}
+
+	require.True(t, IsNonDeterministicFunction("now"))
+	require.False(t, IsNonDeterministicFunction("timestamp"))
+}
+
+func TestEvaluateNow(t *testing.T) {
+	compiled, err := NewCompiled(&openfgav1.Condition{
+		Name:       "not_expired",
+		Expression: "now() < expiration",
+		Parameters: map[string]*openfgav1.ConditionParamTypeRef{
+			"expiration": {TypeName: openfgav1.ConditionParamTypeRef_TYPE_NAME_TIMESTAMP},
+		},
+	})
+	require.NoError(t, err)
+
+	for expiration, expected := range map[string]bool{
+		"2000-01-01T00:00:00Z": false,
+		"3000-01-01T00:00:00Z": true,
+	} {
+		result, err := compiled.Evaluate(context.Background(), map[string]*structpb.Value{
+			"expiration": structpb.NewStringValue(expiration),
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, result.ConditionMet)
+	}
+}
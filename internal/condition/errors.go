@@ -47,6 +47,8 @@ func (e *EvaluationError) Unwrap() error {
 
 type ParameterTypeError struct {
 	Condition string
+	// Parameter is the name of the parameter whose value doesn't have its type, if the error is about a parameter.
+	Parameter string
 	Cause     error
 }
 
@@ -57,3 +59,13 @@ func (e *ParameterTypeError) Error() string {
 func (e *ParameterTypeError) Unwrap() error {
 	return e.Cause
 }
+
+// MissingParameterError is returned if a context doesn't have a value for a parameter of the condition.
+type MissingParameterError struct {
+	Condition string
+	Parameter string
+}
+
+func (e *MissingParameterError) Error() string {
+	return fmt.Sprintf("missing parameter '%s' of condition '%s'", e.Parameter, e.Condition)
+}
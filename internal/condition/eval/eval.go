@@ -2,6 +2,7 @@ package eval
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -22,9 +23,104 @@ import (
 
 var tracer = otel.Tracer("openfga/internal/condition/eval")
 
+type disabledConditionsCtxKey struct{}
+
+// ContextWithDisabledConditions returns a context with which the conditions with the given names are never
+// met by [EvaluateTupleCondition], whatever their context, e.g. to stop a condition from granting access
+// without changing the model.
+func ContextWithDisabledConditions(parent context.Context, names ...string) context.Context {
+	disabled := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		disabled[name] = struct{}{}
+	}
+	return context.WithValue(parent, disabledConditionsCtxKey{}, disabled)
+}
+
+func isDisabledCondition(ctx context.Context, name string) bool {
+	disabled, _ := ctx.Value(disabledConditionsCtxKey{}).(map[string]struct{})
+	_, ok := disabled[name]
+	return ok
+}
+
+// EvaluationErrorPolicy is how an error while evaluating the condition of a tuple is handled, e.g. a parameter
+// of the condition that is missing from the context.
+type EvaluationErrorPolicy string
+
+const (
+	// EvaluationErrorPolicyError fails the query with the error.
+	EvaluationErrorPolicyError EvaluationErrorPolicy = "error"
+	// EvaluationErrorPolicyTreatAsFalse handles the condition as not met, so the tuple doesn't match.
+	EvaluationErrorPolicyTreatAsFalse EvaluationErrorPolicy = "treat-as-false"
+	// EvaluationErrorPolicyTreatAsTrue handles the condition as met, so the tuple matches as if it had no
+	// condition. This fails open: a tuple whose condition can't be evaluated grants access.
+	EvaluationErrorPolicyTreatAsTrue EvaluationErrorPolicy = "treat-as-true"
+)
+
+// EvaluationErrorPolicies are all the valid evaluation error policies.
+var EvaluationErrorPolicies = []EvaluationErrorPolicy{
+	EvaluationErrorPolicyError,
+	EvaluationErrorPolicyTreatAsFalse,
+	EvaluationErrorPolicyTreatAsTrue,
+}
+
+type evaluationErrorPolicyCtxKey struct{}
+
+// ContextWithEvaluationErrorPolicy returns a context with which [HandleEvaluationError] applies the given policy.
+func ContextWithEvaluationErrorPolicy(parent context.Context, policy EvaluationErrorPolicy) context.Context {
+	return context.WithValue(parent, evaluationErrorPolicyCtxKey{}, policy)
+}
+
+// HandleEvaluationError applies the evaluation error policy of the context, [EvaluationErrorPolicyError] by default,
+// to err, an error while evaluating the condition of a tuple. It returns whether the condition is met according to
+// the policy, or err itself if the policy is to fail. Errors that aren't a [condition.ErrEvaluationFailed], and
+// errors once the context is done, are always returned.
+func HandleEvaluationError(ctx context.Context, err error) (bool, error) {
+	if !errors.Is(err, condition.ErrEvaluationFailed) || ctx.Err() != nil {
+		return false, err
+	}
+
+	policy, _ := ctx.Value(evaluationErrorPolicyCtxKey{}).(EvaluationErrorPolicy)
+	switch policy {
+	case EvaluationErrorPolicyTreatAsFalse:
+		return false, nil
+	case EvaluationErrorPolicyTreatAsTrue:
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+type strictConditionContextCtxKey struct{}
+
+// ContextWithStrictConditionContext returns a context with which [HandleMissingParameters] handles a condition whose
+// parameters are missing from the context of the query as not met if strict is false. Without it, or if strict is
+// true, the missing parameters are an evaluation error.
+func ContextWithStrictConditionContext(parent context.Context, strict bool) context.Context {
+	return context.WithValue(parent, strictConditionContextCtxKey{}, strict)
+}
+
+// HandleMissingParameters handles the condition of the tuple, whose parameters missingParameters are neither in the
+// context of the query nor in the context of the tuple. Unless the context is strict, see
+// [ContextWithStrictConditionContext], the condition isn't met and no error is returned, whatever the evaluation error
+// policy; otherwise the missing parameters are an evaluation error handled by [HandleEvaluationError].
+func HandleMissingParameters(ctx context.Context, tupleKey *openfgav1.TupleKey, missingParameters []string) (bool, error) {
+	if strict, ok := ctx.Value(strictConditionContextCtxKey{}).(bool); ok && !strict {
+		return false, nil
+	}
+
+	return HandleEvaluationError(ctx, condition.NewEvaluationError(
+		tupleKey.GetCondition().GetName(),
+		fmt.Errorf("tuple '%s' is missing context parameters '%v'",
+			tuple.TupleKeyToString(tupleKey),
+			missingParameters),
+	))
+}
+
 // EvaluateTupleCondition looks at the given tuple's condition and returns an evaluation result for the given context.
 // If the tuple doesn't have a condition, it exits early and doesn't create a span.
 // If the tuple's condition isn't found in the model it returns an EvaluationError.
+// If the tuple's condition is disabled with [ContextWithDisabledConditions], it is not met.
+// Errors, and results with missing parameters, are counted by condition name.
 func EvaluateTupleCondition(
 	ctx context.Context,
 	tupleKey *openfgav1.TupleKey,
@@ -44,12 +140,20 @@ func EvaluateTupleCondition(
 		attribute.String("condition_name", conditionName)))
 	defer span.End()
 
+	if isDisabledCondition(ctx, conditionName) {
+		span.SetAttributes(attribute.Bool("condition_disabled", true))
+		return &condition.EvaluationResult{
+			ConditionMet: false,
+		}, nil
+	}
+
 	start := time.Now()
 
 	evaluableCondition, ok := typesys.GetCondition(conditionName)
 	if !ok {
 		err := condition.NewEvaluationError(conditionName, fmt.Errorf("condition was not found"))
 		telemetry.TraceError(span, err)
+		metrics.Metrics.ObserveEvaluationError(conditionName)
 		return nil, err
 	}
 
@@ -71,9 +175,14 @@ func EvaluateTupleCondition(
 	conditionResult, err := evaluableCondition.Evaluate(ctx, contextFields...)
 	if err != nil {
 		telemetry.TraceError(span, err)
+		metrics.Metrics.ObserveEvaluationError(conditionName)
 		return nil, err
 	}
 
+	if len(conditionResult.MissingParameters) > 0 {
+		metrics.Metrics.ObserveEvaluationError(conditionName)
+	}
+
 	metrics.Metrics.ObserveEvaluationDuration(time.Since(start))
 	metrics.Metrics.ObserveEvaluationCost(conditionResult.Cost)
 
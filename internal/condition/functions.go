@@ -0,0 +1,60 @@
+package condition
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// nonDeterministicFunctions is the registry of the functions of the condition expressions whose result doesn't
+// only depend on their arguments. A condition that calls one of them may evaluate differently for the same
+// parameters, so its result must not be cached.
+var nonDeterministicFunctions = map[string]struct{}{
+	"now": {},
+}
+
+// IsNonDeterministicFunction returns whether the function of the condition expressions with the given name is
+// non-deterministic.
+func IsNonDeterministicFunction(name string) bool {
+	_, ok := nonDeterministicFunctions[name]
+	return ok
+}
+
+// functionsEnvOption declares the functions of the condition expressions that CEL doesn't provide:
+//
+//	now() -> google.protobuf.Timestamp, the current time.
+func functionsEnvOption() cel.EnvOption {
+	return cel.Function("now",
+		cel.Overload("now", []*cel.Type{}, cel.TimestampType,
+			cel.FunctionBinding(func(...ref.Val) ref.Val {
+				return celtypes.Timestamp{Time: time.Now().UTC()}
+			}),
+		),
+	)
+}
+
+// nonDeterministicFunctionsOf returns the sorted names of the non-deterministic functions that the expression
+// calls.
+func nonDeterministicFunctionsOf(ast *cel.Ast) []string {
+	called := map[string]struct{}{}
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if expr.Kind() != celast.CallKind {
+			return
+		}
+
+		if name := expr.AsCall().FunctionName(); IsNonDeterministicFunction(name) {
+			called[name] = struct{}{}
+		}
+	}))
+
+	functions := make([]string, 0, len(called))
+	for name := range called {
+		functions = append(functions, name)
+	}
+	sort.Strings(functions)
+	return functions
+}
@@ -2,6 +2,8 @@
 package metrics
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,6 +17,14 @@ import (
 // Metrics provides access to Condition metrics.
 var Metrics *ConditionMetrics
 
+const (
+	// maxConditionNameLabels is the maximum number of distinct condition names used as metric labels.
+	// The lookups of any other condition are recorded with otherConditionNameLabel.
+	maxConditionNameLabels = 100
+
+	otherConditionNameLabel = "other"
+)
+
 func init() {
 	m := &ConditionMetrics{
 		compilationTime: promauto.NewHistogram(prometheus.HistogramOpts{
@@ -40,6 +50,24 @@ func init() {
 			NativeHistogramMaxBucketNumber:  config.DefaultMaxConditionEvaluationCost,
 			NativeHistogramMinResetDuration: time.Hour,
 		}),
+
+		compilationCacheTotalCounter: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: build.ProjectName,
+			Name:      "condition_compilation_cache_total_count",
+			Help:      "The total number of lookups of a compiled Condition.",
+		}, []string{"condition_name"}),
+
+		compilationCacheHitCounter: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: build.ProjectName,
+			Name:      "condition_compilation_cache_hit_count",
+			Help:      "The total number of lookups of a compiled Condition that didn't require compiling it.",
+		}, []string{"condition_name"}),
+
+		evaluationErrorCounter: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: build.ProjectName,
+			Name:      "condition_evaluation_error_count",
+			Help:      "The total number of evaluations of a Condition that failed, including the ones missing parameters from the context.",
+		}, []string{"condition_name"}),
 	}
 
 	Metrics = m
@@ -49,6 +77,18 @@ type ConditionMetrics struct {
 	compilationTime prometheus.Histogram
 	evaluationTime  prometheus.Histogram
 	evaluationCost  prometheus.Histogram
+
+	compilationCacheTotalCounter *prometheus.CounterVec
+	compilationCacheHitCounter   *prometheus.CounterVec
+
+	evaluationErrorCounter *prometheus.CounterVec
+
+	// conditionNameLabels has the condition names that are their own label. It is only written to while it has
+	// less than maxConditionNameLabels names, under conditionNameLabelsMu, so that the lookups on the per-tuple
+	// path never take the lock.
+	conditionNameLabels     sync.Map
+	conditionNameLabelCount atomic.Int32
+	conditionNameLabelsMu   sync.Mutex
 }
 
 // ObserveCompilationDuration records the duration (in milliseconds) that Condition compilation took.
@@ -65,3 +105,44 @@ func (m *ConditionMetrics) ObserveEvaluationDuration(elapsed time.Duration) {
 func (m *ConditionMetrics) ObserveEvaluationCost(cost uint64) {
 	m.evaluationCost.Observe(float64(cost))
 }
+
+// ObserveCompilationCacheLookup records a lookup of the compiled Condition with the given name, and whether
+// it had already been compiled (a hit) or had to be compiled (a miss).
+func (m *ConditionMetrics) ObserveCompilationCacheLookup(conditionName string, hit bool) {
+	label := m.conditionNameLabel(conditionName)
+
+	m.compilationCacheTotalCounter.WithLabelValues(label).Inc()
+	if hit {
+		m.compilationCacheHitCounter.WithLabelValues(label).Inc()
+	}
+}
+
+// ObserveEvaluationError records a failed evaluation of the Condition with the given name.
+func (m *ConditionMetrics) ObserveEvaluationError(conditionName string) {
+	m.evaluationErrorCounter.WithLabelValues(m.conditionNameLabel(conditionName)).Inc()
+}
+
+// conditionNameLabel returns the label value for a condition name, which is the name itself for the first
+// maxConditionNameLabels distinct names and otherConditionNameLabel afterward, to bound the cardinality of the metrics.
+func (m *ConditionMetrics) conditionNameLabel(conditionName string) string {
+	if _, ok := m.conditionNameLabels.Load(conditionName); ok {
+		return conditionName
+	}
+	if m.conditionNameLabelCount.Load() >= maxConditionNameLabels {
+		return otherConditionNameLabel
+	}
+
+	m.conditionNameLabelsMu.Lock()
+	defer m.conditionNameLabelsMu.Unlock()
+
+	if _, ok := m.conditionNameLabels.Load(conditionName); ok {
+		return conditionName
+	}
+	if m.conditionNameLabelCount.Load() >= maxConditionNameLabels {
+		return otherConditionNameLabel
+	}
+
+	m.conditionNameLabels.Store(conditionName, struct{}{})
+	m.conditionNameLabelCount.Add(1)
+	return conditionName
+}
@@ -9,6 +9,9 @@ type CheckResolverOrderedBuilder struct {
 	dispatchThrottlingCheckResolverOptions []DispatchThrottlingCheckResolverOpt
 	trackerCheckResolverEnabled            bool
 	trackerCheckResolverOptions            []TrackerCheckResolverOpt
+	iterativeDeepeningEnabled              bool
+	iterativeDeepeningOptions              []IterativeDeepeningCheckResolverOpt
+	entrypoint                             CheckResolver
 }
 
 type CheckResolverOrderedBuilderOpt func(checkResolver *CheckResolverOrderedBuilder)
@@ -44,6 +47,14 @@ func WithTrackerCheckResolverOpts(enabled bool, opts ...TrackerCheckResolverOpt)
 	}
 }
 
+// WithIterativeDeepeningCheckResolverOpts sets the opts to be used to build IterativeDeepeningCheckResolver.
+func WithIterativeDeepeningCheckResolverOpts(enabled bool, opts ...IterativeDeepeningCheckResolverOpt) CheckResolverOrderedBuilderOpt {
+	return func(r *CheckResolverOrderedBuilder) {
+		r.iterativeDeepeningEnabled = enabled
+		r.iterativeDeepeningOptions = opts
+	}
+}
+
 func NewOrderedCheckResolvers(opts ...CheckResolverOrderedBuilderOpt) *CheckResolverOrderedBuilder {
 	checkResolverBuilder := &CheckResolverOrderedBuilder{}
 	for _, opt := range opts {
@@ -58,6 +69,9 @@ func NewOrderedCheckResolvers(opts ...CheckResolverOrderedBuilderOpt) *CheckReso
 //	[...Other resolvers depending on the opts order]
 //		LocalChecker    ----------------------------^
 //
+// If enabled, the IterativeDeepeningCheckResolver is the entrypoint of the list, outside of the loop, since it
+// only deepens the parent problem.
+//
 // The returned CheckResolverCloser should be used to close all resolvers involved in the list.
 func (c *CheckResolverOrderedBuilder) Build() (CheckResolver, CheckResolverCloser) {
 	c.resolvers = []CheckResolver{}
@@ -84,11 +98,21 @@ func (c *CheckResolverOrderedBuilder) Build() (CheckResolver, CheckResolverClose
 		resolver.SetDelegate(c.resolvers[i+1])
 	}
 
+	if c.iterativeDeepeningEnabled {
+		iterativeDeepening := NewIterativeDeepeningCheckResolver(c.iterativeDeepeningOptions...)
+		iterativeDeepening.SetDelegate(c.resolvers[0])
+		c.entrypoint = iterativeDeepening
+		return iterativeDeepening, c.close
+	}
+
 	return c.resolvers[0], c.close
 }
 
 // close will ensure all the CheckResolver constructed are closed.
 func (c *CheckResolverOrderedBuilder) close() {
+	if c.entrypoint != nil {
+		c.entrypoint.Close()
+	}
 	for _, resolver := range c.resolvers {
 		resolver.Close()
 	}
@@ -0,0 +1,177 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	defaultCacheBackendFailureThreshold = 5
+	defaultCacheBackendCooldown         = 10 * time.Second
+
+	// at most one backend error is logged every cacheBackendLogInterval
+	cacheBackendLogInterval = 10 * time.Second
+)
+
+var (
+	checkCacheBackendErrorCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "check_cache_backend_error_count",
+		Help:      "The total number of Check cache backend operations that failed, and were treated as cache misses.",
+	}, []string{"operation"})
+
+	checkCacheBackendSkippedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "check_cache_backend_skipped_count",
+		Help:      "The total number of Check cache backend operations that were skipped because the circuit breaker was open.",
+	}, []string{"operation"})
+)
+
+// CheckCacheBackend stores serialized Check cache entries outside the process, e.g. in a Redis shared
+// by every OpenFGA instance. Unlike an in-memory cache, its operations can fail.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+type CheckCacheBackend interface {
+	// Get returns the entry of the key, or nil if there is no entry or it has expired.
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// CheckCacheInvalidator is implemented by the CheckCacheBackends that can invalidate the entries of a store in
+// every instance that shares them, e.g. a backend whose instances keep local copies of the entries. An
+// invalidation propagates asynchronously, so an instance may still serve an invalidated entry for a while.
+type CheckCacheInvalidator interface {
+	// InvalidateStore invalidates the entries of the store, i.e. the entries whose keys start with
+	// CheckCacheKeyStorePrefix(storeID), without waiting for the invalidation to propagate.
+	InvalidateStore(ctx context.Context, storeID string) error
+	// AwaitStoreInvalidation returns once every instance has acknowledged the invalidations of the store made
+	// before the call, or with an error if ctx is done first.
+	AwaitStoreInvalidation(ctx context.Context, storeID string) error
+}
+
+// CheckCacheKeyStorePrefix returns the prefix of the keys of the Check cache entries of the store.
+func CheckCacheKeyStorePrefix(storeID string) string {
+	return storeID + "/"
+}
+
+// CacheBackendOpt configures how the CachedCheckResolver degrades when its CheckCacheBackend fails.
+type CacheBackendOpt func(*failOpenCache)
+
+// WithCacheBackendCircuitBreaker sets the number of consecutive failures of the backend after which it
+// isn't called for the cooldown, during which every lookup is a miss and nothing is stored. After
+// the cooldown the backend is called again, and a single failure opens the circuit again.
+func WithCacheBackendCircuitBreaker(failureThreshold int, cooldown time.Duration) CacheBackendOpt {
+	return func(c *failOpenCache) {
+		c.failureThreshold = failureThreshold
+		c.cooldown = cooldown
+	}
+}
+
+// failOpenCache adapts a CheckCacheBackend to a cache that never fails: an error of the backend is
+// logged, counted and treated as a miss, so that Checks are resolved without the cache rather than failing.
+type failOpenCache struct {
+	backend CheckCacheBackend
+	logger  logger.Logger
+	limiter *rate.Limiter
+	now     func() time.Time
+
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu sync.Mutex
+	// consecutiveFailures is the number of failures since the last successful call to the backend.
+	consecutiveFailures int // GUARDED_BY(mu).
+	// openUntil is the time until which the backend isn't called.
+	openUntil time.Time // GUARDED_BY(mu).
+}
+
+var _ storage.InMemoryCache[[]byte] = (*failOpenCache)(nil)
+
+func newFailOpenCache(backend CheckCacheBackend, logger logger.Logger, opts ...CacheBackendOpt) *failOpenCache {
+	c := &failOpenCache{
+		backend:          backend,
+		logger:           logger,
+		limiter:          rate.NewLimiter(rate.Every(cacheBackendLogInterval), 1),
+		now:              time.Now,
+		failureThreshold: defaultCacheBackendFailureThreshold,
+		cooldown:         defaultCacheBackendCooldown,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *failOpenCache) Get(key string) *storage.CachedResult[[]byte] {
+	if !c.allow("get") {
+		return nil
+	}
+
+	value, err := c.backend.Get(key)
+	c.done("get", err)
+	if err != nil || value == nil {
+		return nil
+	}
+	return &storage.CachedResult[[]byte]{Value: value}
+}
+
+func (c *failOpenCache) Set(key string, value []byte, ttl time.Duration) {
+	if !c.allow("set") {
+		return
+	}
+
+	c.done("set", c.backend.Set(key, value, ttl))
+}
+
+// Stop does nothing, the backend is owned by the caller.
+func (c *failOpenCache) Stop() {}
+
+// allow returns false if the backend must not be called because the circuit is open.
+func (c *failOpenCache) allow(operation string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.now().Before(c.openUntil) {
+		checkCacheBackendSkippedCounter.WithLabelValues(operation).Inc()
+		return false
+	}
+	return true
+}
+
+// done records the outcome of a call to the backend, and opens the circuit if the backend
+// failed too many times in a row.
+func (c *failOpenCache) done(operation string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		c.consecutiveFailures = 0
+		return
+	}
+
+	checkCacheBackendErrorCounter.WithLabelValues(operation).Inc()
+	if c.limiter.Allow() {
+		c.logger.Warn("check cache backend error, resolving without the cache",
+			zap.String("operation", operation),
+			zap.Error(err),
+		)
+	}
+
+	c.consecutiveFailures++
+	if c.failureThreshold > 0 && c.consecutiveFailures >= c.failureThreshold {
+		c.openUntil = c.now().Add(c.cooldown)
+		// after the cooldown, a single failure is enough to open the circuit again
+		c.consecutiveFailures = c.failureThreshold - 1
+	}
+}
@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var errCacheBackendDown = errors.New("cache backend down")
+
+// fakeCacheBackend is a CheckCacheBackend that keeps the entries in a map, and fails all the operations while down.
+type fakeCacheBackend struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	down    bool
+	calls   int
+}
+
+func (f *fakeCacheBackend) Get(key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.down {
+		return nil, errCacheBackendDown
+	}
+	return f.entries[key], nil
+}
+
+func (f *fakeCacheBackend) Set(key string, value []byte, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.down {
+		return errCacheBackendDown
+	}
+	f.entries[key] = value
+	return nil
+}
+
+func (f *fakeCacheBackend) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeCacheBackend) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func TestCachedCheckResolverWithCacheBackend(t *testing.T) {
+	req := &ResolveCheckRequest{
+		StoreID:              "store",
+		AuthorizationModelID: "model",
+		TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+		RequestMetadata:      NewCheckRequestMetadata(25),
+	}
+
+	t.Run("entries_are_stored_in_the_backend", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		delegate := NewMockCheckResolver(ctrl)
+		delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{
+			Allowed:            true,
+			ResolutionMetadata: &ResolveCheckResponseMetadata{},
+		}, nil)
+
+		backend := &fakeCacheBackend{entries: map[string][]byte{}}
+		resolver := NewCachedCheckResolver(WithCacheBackend(backend))
+		t.Cleanup(resolver.Close)
+		resolver.SetDelegate(delegate)
+
+		for i := 0; i < 2; i++ {
+			resp, err := resolver.ResolveCheck(context.Background(), req)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+		}
+		require.Len(t, backend.entries, 1)
+	})
+
+	t.Run("checks_succeed_while_the_backend_is_down", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		delegate := NewMockCheckResolver(ctrl)
+		delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(3).Return(&ResolveCheckResponse{
+			Allowed:            true,
+			ResolutionMetadata: &ResolveCheckResponseMetadata{},
+		}, nil)
+
+		backend := &fakeCacheBackend{entries: map[string][]byte{}, down: true}
+		resolver := NewCachedCheckResolver(WithCacheBackend(backend), WithLogger(logger.NewNoopLogger()))
+		t.Cleanup(resolver.Close)
+		resolver.SetDelegate(delegate)
+
+		for i := 0; i < 3; i++ {
+			resp, err := resolver.ResolveCheck(context.Background(), req)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+			require.False(t, resp.GetResolutionMetadata().Cached)
+		}
+	})
+}
+
+func TestFailOpenCacheCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	backend := &fakeCacheBackend{entries: map[string][]byte{}, down: true}
+	cache := newFailOpenCache(backend, logger.NewNoopLogger(), WithCacheBackendCircuitBreaker(3, time.Minute))
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, cache.Get("key"))
+	}
+	require.Equal(t, 3, backend.callCount())
+
+	// the circuit is open: the backend isn't called until the cooldown is over
+	cache.Set("key", []byte("value"), time.Minute)
+	require.Nil(t, cache.Get("key"))
+	require.Equal(t, 3, backend.callCount())
+
+	// a single failure after the cooldown opens the circuit again
+	now = now.Add(time.Minute)
+	require.Nil(t, cache.Get("key"))
+	require.Nil(t, cache.Get("key"))
+	require.Equal(t, 4, backend.callCount())
+
+	now = now.Add(time.Minute)
+	backend.setDown(false)
+	cache.Set("key", []byte("value"), time.Minute)
+	require.Equal(t, []byte("value"), cache.Get("key").Value)
+	require.Equal(t, 6, backend.callCount())
+
+	// once the backend is back, it takes the full threshold of failures to open the circuit
+	backend.setDown(true)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, cache.Get("key"))
+	}
+	require.Nil(t, cache.Get("key"))
+	require.Equal(t, 9, backend.callCount())
+}
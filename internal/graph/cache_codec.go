@@ -0,0 +1,146 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+var checkCacheInvalidEntryCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: build.ProjectName,
+	Name:      "check_cache_invalid_entry_count",
+	Help:      "The total number of Check cache entries that couldn't be decoded, and were treated as cache misses.",
+})
+
+// ErrInvalidCacheEntry is returned by a CheckCacheCodec when the bytes it is asked to decode
+// are not a valid encoding of a ResolveCheckResponse.
+var ErrInvalidCacheEntry = errors.New("invalid check cache entry")
+
+// CheckCacheCodec encodes and decodes Check cache entries. It is used by the CachedCheckResolver
+// when cache entries are stored in serialized form, which is where most of the cost of a cache
+// lookup goes for caches that live outside the process.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+type CheckCacheCodec interface {
+	Encode(resp *ResolveCheckResponse) ([]byte, error)
+	Decode(data []byte) (*ResolveCheckResponse, error)
+}
+
+// GobCheckCacheCodec encodes Check cache entries with encoding/gob.
+type GobCheckCacheCodec struct{}
+
+var _ CheckCacheCodec = GobCheckCacheCodec{}
+
+func (GobCheckCacheCodec) Encode(resp *ResolveCheckResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(resp); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCheckCacheCodec) Decode(data []byte) (*ResolveCheckResponse, error) {
+	var resp ResolveCheckResponse
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCacheEntry, err)
+	}
+	return &resp, nil
+}
+
+const binaryCodecVersion byte = 1
+
+const (
+	binaryCodecFlagAllowed byte = 1 << iota
+	binaryCodecFlagCycleDetected
+)
+
+// BinaryCheckCacheCodec encodes Check cache entries in a compact, fixed layout: a version byte,
+// a flags byte and the datastore query count as a uvarint.
+type BinaryCheckCacheCodec struct{}
+
+var _ CheckCacheCodec = BinaryCheckCacheCodec{}
+
+func (BinaryCheckCacheCodec) Encode(resp *ResolveCheckResponse) ([]byte, error) {
+	var flags byte
+	var queryCount uint32
+	if resp.GetAllowed() {
+		flags |= binaryCodecFlagAllowed
+	}
+	if metadata := resp.GetResolutionMetadata(); metadata != nil {
+		if metadata.CycleDetected {
+			flags |= binaryCodecFlagCycleDetected
+		}
+		queryCount = metadata.DatastoreQueryCount
+	}
+
+	buf := make([]byte, 2, 2+binary.MaxVarintLen32)
+	buf[0] = binaryCodecVersion
+	buf[1] = flags
+	return binary.AppendUvarint(buf, uint64(queryCount)), nil
+}
+
+func (BinaryCheckCacheCodec) Decode(data []byte) (*ResolveCheckResponse, error) {
+	if len(data) < 3 || data[0] != binaryCodecVersion {
+		return nil, ErrInvalidCacheEntry
+	}
+
+	flags := data[1]
+	queryCount, n := binary.Uvarint(data[2:])
+	if n <= 0 || 2+n != len(data) || queryCount > uint64(^uint32(0)) {
+		return nil, ErrInvalidCacheEntry
+	}
+
+	return &ResolveCheckResponse{
+		Allowed: flags&binaryCodecFlagAllowed != 0,
+		ResolutionMetadata: &ResolveCheckResponseMetadata{
+			DatastoreQueryCount: uint32(queryCount),
+			CycleDetected:       flags&binaryCodecFlagCycleDetected != 0,
+		},
+	}, nil
+}
+
+// encodedCheckCache adapts a cache of serialized entries to a cache of ResolveCheckResponse
+// using the given codec.
+type encodedCheckCache struct {
+	cache storage.InMemoryCache[[]byte]
+	codec CheckCacheCodec
+}
+
+var _ storage.InMemoryCache[*ResolveCheckResponse] = (*encodedCheckCache)(nil)
+
+func (e *encodedCheckCache) Get(key string) *storage.CachedResult[*ResolveCheckResponse] {
+	res := e.cache.Get(key)
+	if res == nil {
+		return nil
+	}
+
+	resp, err := e.codec.Decode(res.Value)
+	if err != nil {
+		// an entry we can't decode, e.g. one encoded by another version of the codec during a rolling
+		// deploy, is as good as a miss: the Check is resolved again and the entry overwritten
+		checkCacheInvalidEntryCounter.Inc()
+		return nil
+	}
+	return &storage.CachedResult[*ResolveCheckResponse]{Value: resp, Expired: res.Expired}
+}
+
+func (e *encodedCheckCache) Set(key string, value *ResolveCheckResponse, ttl time.Duration) {
+	data, err := e.codec.Encode(value)
+	if err != nil {
+		return
+	}
+	e.cache.Set(key, data, ttl)
+}
+
+func (e *encodedCheckCache) Stop() {
+	e.cache.Stop()
+}
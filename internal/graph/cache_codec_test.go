@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var checkCacheCodecs = map[string]CheckCacheCodec{
+	"gob":    GobCheckCacheCodec{},
+	"binary": BinaryCheckCacheCodec{},
+}
+
+func TestCheckCacheCodecRoundTrip(t *testing.T) {
+	responses := map[string]*ResolveCheckResponse{
+		"allowed": {
+			Allowed:            true,
+			ResolutionMetadata: &ResolveCheckResponseMetadata{DatastoreQueryCount: 3},
+		},
+		"not_allowed_with_cycle": {
+			Allowed:            false,
+			ResolutionMetadata: &ResolveCheckResponseMetadata{DatastoreQueryCount: 1 << 20, CycleDetected: true},
+		},
+		"zero_value": {
+			ResolutionMetadata: &ResolveCheckResponseMetadata{},
+		},
+	}
+
+	for codecName, codec := range checkCacheCodecs {
+		for respName, resp := range responses {
+			t.Run(codecName+"/"+respName, func(t *testing.T) {
+				data, err := codec.Encode(resp)
+				require.NoError(t, err)
+
+				decoded, err := codec.Decode(data)
+				require.NoError(t, err)
+				require.Equal(t, resp, decoded)
+			})
+		}
+
+		t.Run(codecName+"/invalid_data", func(t *testing.T) {
+			_, err := codec.Decode([]byte{0xff})
+			require.ErrorIs(t, err, ErrInvalidCacheEntry)
+		})
+	}
+}
+
+func TestCachedCheckResolverWithCacheCodec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	delegate := NewMockCheckResolver(ctrl)
+	delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{
+		Allowed:            true,
+		ResolutionMetadata: &ResolveCheckResponseMetadata{DatastoreQueryCount: 2},
+	}, nil)
+
+	resolver := NewCachedCheckResolver(WithCacheCodec(BinaryCheckCacheCodec{}))
+	t.Cleanup(resolver.Close)
+	resolver.SetDelegate(delegate)
+
+	req := &ResolveCheckRequest{
+		StoreID:              "store",
+		AuthorizationModelID: "model",
+		TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+	}
+
+	resp, err := resolver.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+
+	resp, err = resolver.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+	require.Zero(t, resp.GetResolutionMetadata().DatastoreQueryCount)
+}
+
+func BenchmarkCheckCacheCodec(b *testing.B) {
+	resp := &ResolveCheckResponse{
+		Allowed:            true,
+		ResolutionMetadata: &ResolveCheckResponseMetadata{DatastoreQueryCount: 42},
+	}
+
+	for name, codec := range checkCacheCodecs {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := codec.Encode(resp)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := codec.Decode(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedCheckResolverWithInvalidCacheEntry(t *testing.T) {
+	for codecName, codec := range checkCacheCodecs {
+		t.Run(codecName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			delegate := NewMockCheckResolver(ctrl)
+			delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{
+				Allowed:            true,
+				ResolutionMetadata: &ResolveCheckResponseMetadata{DatastoreQueryCount: 2},
+			}, nil)
+
+			entries := storage.NewInMemoryLRUCache[[]byte]()
+			cache := &encodedCheckCache{cache: entries, codec: codec}
+			t.Cleanup(cache.Stop)
+
+			resolver := NewCachedCheckResolver(WithExistingCache(cache))
+			t.Cleanup(resolver.Close)
+			resolver.SetDelegate(delegate)
+
+			req := &ResolveCheckRequest{
+				StoreID:              "store",
+				AuthorizationModelID: "model",
+				TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			}
+
+			// e.g. an entry written by a previous version of the codec
+			key, err := CheckRequestCacheKey(req)
+			require.NoError(t, err)
+			entries.Set(key, []byte{0xff}, time.Minute)
+
+			invalidEntries := testutil.ToFloat64(checkCacheInvalidEntryCounter)
+
+			resp, err := resolver.ResolveCheck(context.Background(), req)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+			require.False(t, resp.GetResolutionMetadata().Cached)
+			require.InDelta(t, invalidEntries+1, testutil.ToFloat64(checkCacheInvalidEntryCounter), 0)
+
+			// the entry was overwritten by the fresh resolution
+			resp, err = resolver.ResolveCheck(context.Background(), req)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+			require.True(t, resp.GetResolutionMetadata().Cached)
+		})
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"github.com/openfga/openfga/internal/keys"
 	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/telemetry"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
 )
 
 const (
@@ -51,11 +53,24 @@ type CachedCheckResolver struct {
 	cache        storage.InMemoryCache[*ResolveCheckResponse]
 	maxCacheSize int64
 	cacheTTL     time.Duration
-	logger       logger.Logger
+	// positiveCacheTTL and negativeCacheTTL override cacheTTL for allowed and denied results, if set.
+	positiveCacheTTL time.Duration
+	negativeCacheTTL time.Duration
+	logger           logger.Logger
 	// allocatedCache is used to denote whether the cache is allocated by this struct.
 	// If so, CachedCheckResolver is responsible for cleaning up.
 	allocatedCache           bool
 	enableConsistencyOptions bool
+	codec                    CheckCacheCodec
+	// nonCacheableContextualTupleRelations holds the 'objectType#relation' of the contextual tuples
+	// that prevent a Check from being cached
+	nonCacheableContextualTupleRelations map[string]struct{}
+	// nonCacheableRelations holds the 'objectType#relation' of the Checks that are never cached
+	nonCacheableRelations map[string]struct{}
+	backend               CheckCacheBackend
+	backendOpts           []CacheBackendOpt
+	// cacheWritesPaused returns whether the resolved Checks must not be cached, see WithCacheWritesPaused.
+	cacheWritesPaused func() bool
 }
 
 var _ CheckResolver = (*CachedCheckResolver)(nil)
@@ -79,6 +94,22 @@ func WithCacheTTL(ttl time.Duration) CachedCheckResolverOpt {
 	}
 }
 
+// WithPositiveCacheTTL sets the TTL of the cached Check results that are allowed, which overrides
+// WithCacheTTL for them. If the TTL is zero, WithCacheTTL applies.
+func WithPositiveCacheTTL(ttl time.Duration) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.positiveCacheTTL = ttl
+	}
+}
+
+// WithNegativeCacheTTL sets the TTL of the cached Check results that are not allowed, which overrides
+// WithCacheTTL for them. If the TTL is zero, WithCacheTTL applies.
+func WithNegativeCacheTTL(ttl time.Duration) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.negativeCacheTTL = ttl
+	}
+}
+
 // WithExistingCache sets the cache to the specified cache.
 // Note that the original cache will not be stopped as it may still be used by others. It is up to the caller
 // to check whether the original cache should be stopped.
@@ -95,6 +126,66 @@ func WithLogger(logger logger.Logger) CachedCheckResolverOpt {
 	}
 }
 
+// WithCacheCodec sets the codec used to serialize Check cache entries. By default entries are kept
+// in memory as-is and no serialization takes place. If combined with WithExistingCache, the codec
+// is ignored, since the existing cache defines its own storage format.
+func WithCacheCodec(codec CheckCacheCodec) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.codec = codec
+	}
+}
+
+// WithCacheWritesPaused doesn't cache the Checks resolved while paused returns true, e.g. while they are
+// resolved from the tuples of a standby datastore that may be stale. The entries cached before are still
+// served.
+func WithCacheWritesPaused(paused func() bool) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.cacheWritesPaused = paused
+	}
+}
+
+// WithCacheBackend stores the Check cache entries in the given backend, serialized with the codec set by
+// WithCacheCodec or with GobCheckCacheCodec if there is none. If the backend fails, e.g. because it is
+// unreachable, Checks are resolved as if the entries weren't cached: the error is logged and counted, and
+// after repeated failures the backend isn't called for a while, see WithCacheBackendCircuitBreaker.
+// WithMaxCacheSize has no effect on a backend, and WithExistingCache takes precedence over it.
+func WithCacheBackend(backend CheckCacheBackend, opts ...CacheBackendOpt) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.backend = backend
+		ccr.backendOpts = opts
+	}
+}
+
+// WithNonCacheableContextualTupleRelations marks the contextual tuples of the given relations, each of
+// the form 'objectType#relation' (e.g. 'document#viewer'), as a non-cacheable overlay. These are meant for
+// contextual tuples that are so volatile that caching the Checks that include them would barely produce any hits.
+// A Check, and any of its sub-problems, that includes at least one such contextual tuple is always resolved
+// by the delegate: neither is its result looked up in the cache, nor is it stored in the cache. Checks
+// without such contextual tuples are cached as usual, including their other contextual tuples in the cache key.
+func WithNonCacheableContextualTupleRelations(relations ...string) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.nonCacheableContextualTupleRelations = make(map[string]struct{}, len(relations))
+		for _, relation := range relations {
+			ccr.nonCacheableContextualTupleRelations[relation] = struct{}{}
+		}
+	}
+}
+
+// WithNonCacheableRelations marks the given relations, each of the form 'objectType#relation' (e.g.
+// 'user#online'), as non-cacheable. These are meant for relations that change so often that a cached result
+// would likely be stale. A Check, or sub-problem, of such a relation is always resolved by the delegate: neither
+// is its result looked up in the cache, nor is it stored in the cache. Only the relation of the Check is
+// considered, so the Checks of the relations that are rewritten from a non-cacheable relation are still cached,
+// unless they are marked as non-cacheable too.
+func WithNonCacheableRelations(relations ...string) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.nonCacheableRelations = make(map[string]struct{}, len(relations))
+		for _, relation := range relations {
+			ccr.nonCacheableRelations[relation] = struct{}{}
+		}
+	}
+}
+
 func WithEnabledConsistencyParams(enable bool) CachedCheckResolverOpt {
 	return func(ccr *CachedCheckResolver) {
 		ccr.enableConsistencyOptions = enable
@@ -118,6 +209,26 @@ func NewCachedCheckResolver(opts ...CachedCheckResolverOpt) *CachedCheckResolver
 		opt(checker)
 	}
 
+	if checker.cache == nil && checker.backend != nil {
+		codec := checker.codec
+		if codec == nil {
+			codec = GobCheckCacheCodec{}
+		}
+		checker.allocatedCache = true
+		checker.cache = &encodedCheckCache{
+			cache: newFailOpenCache(checker.backend, checker.logger, checker.backendOpts...),
+			codec: codec,
+		}
+	}
+
+	if checker.cache == nil && checker.codec != nil {
+		checker.allocatedCache = true
+		checker.cache = &encodedCheckCache{
+			cache: storage.NewInMemoryLRUCache[[]byte](storage.WithMaxCacheSize[[]byte](checker.maxCacheSize)),
+			codec: checker.codec,
+		}
+	}
+
 	if checker.cache == nil {
 		checker.allocatedCache = true
 		cacheOptions := []storage.InMemoryLRUCacheOpt[*ResolveCheckResponse]{
@@ -153,6 +264,14 @@ func (c *CachedCheckResolver) ResolveCheck(
 ) (*ResolveCheckResponse, error) {
 	span := trace.SpanFromContext(ctx)
 
+	// the results of a request with known results depend on them, so they must not be shared with other requests,
+	// and the results of non-deterministic conditions may change while the request doesn't
+	if c.hasNonCacheableContextualTuples(req) || c.hasNonCacheableRelation(req) || len(req.GetKnownResults()) > 0 ||
+		c.involvesNonDeterministicCondition(ctx, req) {
+		span.SetAttributes(attribute.Bool("is_cacheable", false))
+		return c.delegate.ResolveCheck(ctx, req)
+	}
+
 	cacheKey, err := CheckRequestCacheKey(req)
 	if err != nil {
 		c.logger.Error("cache key computation failed with error", zap.Error(err))
@@ -162,6 +281,27 @@ func (c *CachedCheckResolver) ResolveCheck(
 
 	tryCache := !c.enableConsistencyOptions || req.Consistency != openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY
 
+	// a cached response may predate the changes the request must observe
+	if _, ok := storage.MinChangelogTokenFromContext(ctx); ok {
+		tryCache = false
+	}
+
+	// a cached response may have been resolved with a fast path
+	if req.GetDisableFastPath() {
+		tryCache = false
+	}
+
+	if req.GetBypassCache() {
+		tryCache = false
+	}
+
+	// the result is resolved from the tuples of the shadow datastore, so it's neither read from nor written to
+	// the cache shared with the primary datastore
+	shadowReads := storage.ShadowReadsFromContext(ctx)
+	if shadowReads {
+		tryCache = false
+	}
+
 	if tryCache {
 		checkCacheTotalCounter.Inc()
 
@@ -172,7 +312,9 @@ func (c *CachedCheckResolver) ResolveCheck(
 			checkCacheHitCounter.Inc()
 
 			// return a copy to avoid races across goroutines
-			return CloneResolveCheckResponse(cachedResp.Value), nil
+			resp := CloneResolveCheckResponse(cachedResp.Value)
+			resp.ResolutionMetadata.Cached = true
+			return resp, nil
 		}
 	}
 
@@ -183,22 +325,38 @@ func (c *CachedCheckResolver) ResolveCheck(
 		return nil, err
 	}
 
+	if shadowReads || (c.cacheWritesPaused != nil && c.cacheWritesPaused()) {
+		return resp, nil
+	}
+
 	// the cached subproblem's resolution metadata doesn't necessarily reflect
 	// the actual number of database reads for the inflight request, so set it
 	// to 0 so it doesn't bias the resolution metadata negatively
 	clonedResp := CloneResolveCheckResponse(resp)
 	clonedResp.ResolutionMetadata.DatastoreQueryCount = 0
 
-	c.cache.Set(cacheKey, clonedResp, c.cacheTTL)
+	c.cache.Set(cacheKey, clonedResp, c.ttlFor(clonedResp))
 	return resp, nil
 }
 
+// ttlFor returns the TTL of the cache entry of the response, which depends on whether it is allowed.
+func (c *CachedCheckResolver) ttlFor(resp *ResolveCheckResponse) time.Duration {
+	if resp.GetAllowed() && c.positiveCacheTTL > 0 {
+		return c.positiveCacheTTL
+	}
+	if !resp.GetAllowed() && c.negativeCacheTTL > 0 {
+		return c.negativeCacheTTL
+	}
+	return c.cacheTTL
+}
+
 // CheckRequestCacheKey converts the ResolveCheckRequest into a canonical cache key that can be
 // used for Check resolution cache key lookups in a stable way.
 //
 // For one store and model ID, the same tuple provided with the same contextual tuples and context
 // should produce the same cache key. Contextual tuple order and context parameter order is ignored,
-// only the contents are compared.
+// only the contents are compared. The key starts with CheckCacheKeyStorePrefix, so that the entries
+// of a store can be invalidated, see CheckCacheInvalidator.
 func CheckRequestCacheKey(req *ResolveCheckRequest) (string, error) {
 	hasher := keys.NewCacheKeyHasher(xxhash.New())
 
@@ -230,5 +388,58 @@ func CheckRequestCacheKey(req *ResolveCheckRequest) (string, error) {
 		}
 	}
 
-	return strconv.FormatUint(hasher.Key().ToUInt64(), 10), nil
+	return CheckCacheKeyStorePrefix(req.GetStoreID()) + strconv.FormatUint(hasher.Key().ToUInt64(), 10), nil
+}
+
+// hasNonCacheableRelation returns true if the relation of the Check is marked as non-cacheable.
+func (c *CachedCheckResolver) hasNonCacheableRelation(req *ResolveCheckRequest) bool {
+	if len(c.nonCacheableRelations) == 0 {
+		return false
+	}
+
+	tk := req.GetTupleKey()
+	_, ok := c.nonCacheableRelations[tuple.ToObjectRelationString(tuple.GetType(tk.GetObject()), tk.GetRelation())]
+	return ok
+}
+
+// hasNonCacheableContextualTuples returns true if any of the contextual tuples of the request
+// is of a relation marked with WithNonCacheableContextualTupleRelations.
+func (c *CachedCheckResolver) hasNonCacheableContextualTuples(req *ResolveCheckRequest) bool {
+	if len(c.nonCacheableContextualTupleRelations) == 0 {
+		return false
+	}
+
+	for _, tk := range req.GetContextualTuples() {
+		objectType := tuple.GetType(tk.GetObject())
+		if _, ok := c.nonCacheableContextualTupleRelations[tuple.ToObjectRelationString(objectType, tk.GetRelation())]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// involvesNonDeterministicCondition returns true if the result of the Check may depend on a condition that calls
+// a non-deterministic function, e.g. 'now()', either through the tuples of its relation or through its
+// contextual tuples. Such a result may change while the request doesn't, so it is never cached.
+func (c *CachedCheckResolver) involvesNonDeterministicCondition(ctx context.Context, req *ResolveCheckRequest) bool {
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	nonDeterministicConditions := typesys.NonDeterministicConditions()
+	if len(nonDeterministicConditions) == 0 {
+		return false
+	}
+
+	for _, tk := range req.GetContextualTuples() {
+		if _, ok := nonDeterministicConditions[tk.GetCondition().GetName()]; ok {
+			return true
+		}
+	}
+
+	tk := req.GetTupleKey()
+	involves, err := typesys.RelationInvolvesNonDeterministicCondition(tuple.GetType(tk.GetObject()), tk.GetRelation())
+	// if the relation can't be analyzed, the Check fails anyway
+	return err == nil && involves
 }
@@ -0,0 +1,360 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestCachedCheckResolverWithNonCacheableContextualTuples(t *testing.T) {
+	newRequest := func(contextualTuples ...*openfgav1.TupleKey) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:              "store",
+			AuthorizationModelID: "model",
+			TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			ContextualTuples:     contextualTuples,
+			RequestMetadata:      NewCheckRequestMetadata(25),
+		}
+	}
+
+	tests := map[string]struct {
+		request          *ResolveCheckRequest
+		expectedDelegate int
+	}{
+		"non_cacheable_contextual_tuple_is_never_cached": {
+			request:          newRequest(tuple.NewTupleKey("document:1", "viewer", "user:jon")),
+			expectedDelegate: 2,
+		},
+		"non_cacheable_among_other_contextual_tuples_is_never_cached": {
+			request: newRequest(
+				tuple.NewTupleKey("document:1", "owner", "user:jon"),
+				tuple.NewTupleKey("document:2", "viewer", "user:maria"),
+			),
+			expectedDelegate: 2,
+		},
+		"other_contextual_tuples_are_cached": {
+			request:          newRequest(tuple.NewTupleKey("document:1", "owner", "user:jon")),
+			expectedDelegate: 1,
+		},
+		"no_contextual_tuples_are_cached": {
+			request:          newRequest(),
+			expectedDelegate: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			delegate := NewMockCheckResolver(ctrl)
+			delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(test.expectedDelegate).Return(&ResolveCheckResponse{
+				Allowed:            true,
+				ResolutionMetadata: &ResolveCheckResponseMetadata{},
+			}, nil)
+
+			resolver := NewCachedCheckResolver(WithNonCacheableContextualTupleRelations("document#viewer"))
+			t.Cleanup(resolver.Close)
+			resolver.SetDelegate(delegate)
+
+			for i := 0; i < 2; i++ {
+				resp, err := resolver.ResolveCheck(context.Background(), test.request)
+				require.NoError(t, err)
+				require.True(t, resp.GetAllowed())
+			}
+		})
+	}
+}
+
+func TestCachedCheckResolverWithNonCacheableRelations(t *testing.T) {
+	newRequest := func(tk *openfgav1.TupleKey) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:              "store",
+			AuthorizationModelID: "model",
+			TupleKey:             tk,
+			RequestMetadata:      NewCheckRequestMetadata(25),
+		}
+	}
+
+	tests := map[string]struct {
+		request          *ResolveCheckRequest
+		expectedDelegate int
+		expectedCached   bool
+	}{
+		"non_cacheable_relation_is_never_cached": {
+			request:          newRequest(tuple.NewTupleKey("user:jon", "online", "user:jon")),
+			expectedDelegate: 2,
+		},
+		"same_relation_of_other_type_is_cached": {
+			request:          newRequest(tuple.NewTupleKey("device:1", "online", "user:jon")),
+			expectedDelegate: 1,
+			expectedCached:   true,
+		},
+		"other_relation_is_cached": {
+			request:          newRequest(tuple.NewTupleKey("document:1", "viewer", "user:jon")),
+			expectedDelegate: 1,
+			expectedCached:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			delegate := NewMockCheckResolver(ctrl)
+			delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(test.expectedDelegate).Return(&ResolveCheckResponse{
+				Allowed:            true,
+				ResolutionMetadata: &ResolveCheckResponseMetadata{},
+			}, nil)
+
+			resolver := NewCachedCheckResolver(WithNonCacheableRelations("user#online"))
+			t.Cleanup(resolver.Close)
+			resolver.SetDelegate(delegate)
+
+			resp, err := resolver.ResolveCheck(context.Background(), test.request)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+
+			resp, err = resolver.ResolveCheck(context.Background(), test.request)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+			require.Equal(t, test.expectedCached, resp.GetResolutionMetadata().Cached)
+		})
+	}
+}
+
+func TestCachedCheckResolverReportsCachedResults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	delegate := NewMockCheckResolver(ctrl)
+	delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{
+		Allowed:            true,
+		ResolutionMetadata: &ResolveCheckResponseMetadata{},
+	}, nil)
+
+	resolver := NewCachedCheckResolver()
+	t.Cleanup(resolver.Close)
+	resolver.SetDelegate(delegate)
+
+	req := &ResolveCheckRequest{
+		StoreID:              "store",
+		AuthorizationModelID: "model",
+		TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+		RequestMetadata:      NewCheckRequestMetadata(25),
+	}
+
+	resp, err := resolver.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+	require.False(t, resp.GetResolutionMetadata().Cached)
+
+	resp, err = resolver.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+	require.True(t, resp.GetResolutionMetadata().Cached)
+}
+
+func TestCachedCheckResolverWithCacheWritesPaused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	delegate := NewMockCheckResolver(ctrl)
+	delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(3).Return(&ResolveCheckResponse{
+		Allowed:            true,
+		ResolutionMetadata: &ResolveCheckResponseMetadata{},
+	}, nil)
+
+	paused := true
+	resolver := NewCachedCheckResolver(WithCacheWritesPaused(func() bool { return paused }))
+	t.Cleanup(resolver.Close)
+	resolver.SetDelegate(delegate)
+
+	req := &ResolveCheckRequest{
+		StoreID:              "store",
+		AuthorizationModelID: "model",
+		TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+		RequestMetadata:      NewCheckRequestMetadata(25),
+	}
+
+	// while paused, nothing is cached
+	for i := 0; i < 2; i++ {
+		resp, err := resolver.ResolveCheck(context.Background(), req)
+		require.NoError(t, err)
+		require.False(t, resp.GetResolutionMetadata().Cached)
+	}
+
+	paused = false
+	resp, err := resolver.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.False(t, resp.GetResolutionMetadata().Cached)
+
+	resp, err = resolver.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.GetResolutionMetadata().Cached)
+}
+
+func TestCachedCheckResolverPolarityTTLs(t *testing.T) {
+	req := &ResolveCheckRequest{
+		StoreID:              "store",
+		AuthorizationModelID: "model",
+		TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+		RequestMetadata:      NewCheckRequestMetadata(25),
+	}
+
+	tests := map[string]struct {
+		opts        []CachedCheckResolverOpt
+		allowed     bool
+		expectedTTL time.Duration
+	}{
+		"allowed_honors_positive_ttl": {
+			opts:        []CachedCheckResolverOpt{WithCacheTTL(time.Minute), WithPositiveCacheTTL(time.Hour), WithNegativeCacheTTL(time.Second)},
+			allowed:     true,
+			expectedTTL: time.Hour,
+		},
+		"denied_honors_negative_ttl": {
+			opts:        []CachedCheckResolverOpt{WithCacheTTL(time.Minute), WithPositiveCacheTTL(time.Hour), WithNegativeCacheTTL(time.Second)},
+			allowed:     false,
+			expectedTTL: time.Second,
+		},
+		"allowed_defaults_to_cache_ttl": {
+			opts:        []CachedCheckResolverOpt{WithCacheTTL(time.Minute), WithNegativeCacheTTL(time.Second)},
+			allowed:     true,
+			expectedTTL: time.Minute,
+		},
+		"denied_defaults_to_cache_ttl": {
+			opts:        []CachedCheckResolverOpt{WithCacheTTL(time.Minute), WithPositiveCacheTTL(time.Hour)},
+			allowed:     false,
+			expectedTTL: time.Minute,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			delegate := NewMockCheckResolver(ctrl)
+			delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{
+				Allowed:            test.allowed,
+				ResolutionMetadata: &ResolveCheckResponseMetadata{},
+			}, nil)
+
+			cache := mocks.NewMockInMemoryCache[*ResolveCheckResponse](ctrl)
+			cache.EXPECT().Get(gomock.Any()).Times(1).Return(nil)
+			cache.EXPECT().Set(gomock.Any(), gomock.Any(), test.expectedTTL).Times(1)
+
+			resolver := NewCachedCheckResolver(append(test.opts, WithExistingCache(cache))...)
+			t.Cleanup(resolver.Close)
+			resolver.SetDelegate(delegate)
+
+			resp, err := resolver.ResolveCheck(context.Background(), req)
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, resp.GetAllowed())
+		})
+	}
+}
+
+func TestCachedCheckResolverWithNonDeterministicConditions(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define viewer: [user with not_expired]
+		type document
+			relations
+				define parent: [folder]
+				define owner: [user]
+				define editor: [user with in_office_hours]
+				define viewer: owner or viewer from parent
+				define commenter: editor or owner
+
+		condition not_expired(expiration: timestamp) {
+			now() < expiration
+		}
+
+		condition in_office_hours(current_time: timestamp, start: timestamp, end: timestamp) {
+			start <= current_time && current_time < end
+		}`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"not_expired": {"now"}}, typesys.NonDeterministicConditions())
+
+	newRequest := func(tk *openfgav1.TupleKey, contextualTuples ...*openfgav1.TupleKey) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:              "store",
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tk,
+			ContextualTuples:     contextualTuples,
+			RequestMetadata:      NewCheckRequestMetadata(25),
+		}
+	}
+
+	tests := map[string]struct {
+		request        *ResolveCheckRequest
+		expectedCached bool
+	}{
+		"relation_with_now_condition_is_never_cached": {
+			request: newRequest(tuple.NewTupleKey("folder:1", "viewer", "user:jon")),
+		},
+		"relation_through_now_condition_is_never_cached": {
+			request: newRequest(tuple.NewTupleKey("document:1", "viewer", "user:jon")),
+		},
+		"contextual_tuple_with_now_condition_is_never_cached": {
+			request: newRequest(
+				tuple.NewTupleKey("document:1", "owner", "user:jon"),
+				tuple.NewTupleKeyWithCondition("folder:1", "viewer", "user:jon", "not_expired", nil),
+			),
+		},
+		"relation_with_deterministic_condition_is_cached": {
+			request:        newRequest(tuple.NewTupleKey("document:1", "commenter", "user:jon")),
+			expectedCached: true,
+		},
+		"relation_without_conditions_is_cached": {
+			request:        newRequest(tuple.NewTupleKey("document:1", "owner", "user:jon")),
+			expectedCached: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			expectedDelegate := 2
+			if test.expectedCached {
+				expectedDelegate = 1
+			}
+
+			delegate := NewMockCheckResolver(ctrl)
+			delegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(expectedDelegate).Return(&ResolveCheckResponse{
+				Allowed:            true,
+				ResolutionMetadata: &ResolveCheckResponseMetadata{},
+			}, nil)
+
+			resolver := NewCachedCheckResolver()
+			t.Cleanup(resolver.Close)
+			resolver.SetDelegate(delegate)
+
+			ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+			for i := 0; i < 2; i++ {
+				resp, err := resolver.ResolveCheck(ctx, test.request)
+				require.NoError(t, err)
+				require.True(t, resp.GetAllowed())
+				require.Equal(t, test.expectedCached && i == 1, resp.GetResolutionMetadata().Cached)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// changelogInvalidationPageSize is the number of changes read at once from the changelog of a store.
+const changelogInvalidationPageSize = 100
+
+var checkCacheChangelogInvalidationCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: build.ProjectName,
+	Name:      "check_cache_changelog_invalidation_count",
+	Help:      "The total number of invalidations of the Check cache entries of a store caused by new changes in its changelog.",
+})
+
+// ChangelogCacheInvalidator invalidates the Check cache entries of the stores that have new changes in their
+// changelog, so that they are invalidated after their tuples are written even if the instance that wrote them
+// didn't invalidate them, e.g. because the invalidation failed or the instance was terminated in between.
+//
+// Only the stores whose entries are stored through the backend returned by TrackStores are polled, until the last
+// of those entries expires. The changelog of a store is read from its head, i.e. its most recent change, and only
+// the changes found after the first read cause an invalidation. The changes are read with the horizon offset of
+// the changelog, and the changes within the horizon offset before the head are read again on every poll, so that
+// the changes committed after more recent ones still cause an invalidation.
+type ChangelogCacheInvalidator struct {
+	changelog   storage.ChangelogBackend
+	invalidator CheckCacheInvalidator
+	interval    time.Duration
+	// trackingTTL is the time during which a store is polled after one of its entries was stored, i.e. the
+	// longest TTL of the entries.
+	trackingTTL   time.Duration
+	horizonOffset time.Duration
+	logger        logger.Logger
+	now           func() time.Time
+
+	mu     sync.Mutex
+	stores map[string]*trackedStore // GUARDED_BY(mu).
+}
+
+type trackedStore struct {
+	// lastStored is the time at which an entry of the store was last stored.
+	lastStored time.Time
+	// started is set once the changelog of the store was read.
+	started bool
+	// head is the time of the most recent change read from the changelog of the store, if any.
+	head time.Time
+	// window has the changes read within the horizon offset before head.
+	window map[string]struct{}
+}
+
+// NewChangelogCacheInvalidator creates a ChangelogCacheInvalidator that polls the changelog every interval, and
+// invalidates the entries of the stores with new changes with the invalidator. The trackingTTL must be the longest
+// TTL of the entries, and the horizonOffset the one the changelog is read with.
+func NewChangelogCacheInvalidator(
+	changelog storage.ChangelogBackend,
+	invalidator CheckCacheInvalidator,
+	interval time.Duration,
+	trackingTTL time.Duration,
+	horizonOffset time.Duration,
+	logger logger.Logger,
+) *ChangelogCacheInvalidator {
+	return &ChangelogCacheInvalidator{
+		changelog:     changelog,
+		invalidator:   invalidator,
+		interval:      interval,
+		trackingTTL:   trackingTTL,
+		horizonOffset: horizonOffset,
+		logger:        logger,
+		now:           time.Now,
+		stores:        map[string]*trackedStore{},
+	}
+}
+
+// TrackStores returns a backend that stores the entries in the given backend, and makes the invalidator poll the
+// changelog of the stores of the entries stored.
+func (c *ChangelogCacheInvalidator) TrackStores(backend CheckCacheBackend) CheckCacheBackend {
+	return &storeTrackingCacheBackend{CheckCacheBackend: backend, invalidator: c}
+}
+
+// Run polls the changelog of the tracked stores every interval until ctx is done.
+func (c *ChangelogCacheInvalidator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.poll(ctx)
+		}
+	}
+}
+
+func (c *ChangelogCacheInvalidator) track(storeID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	store, ok := c.stores[storeID]
+	if !ok {
+		store = &trackedStore{}
+		c.stores[storeID] = store
+	}
+	store.lastStored = c.now()
+}
+
+// poll reads the new changes of each tracked store, and invalidates the entries of the stores that have any. The
+// stores whose entries all expired are no longer tracked.
+func (c *ChangelogCacheInvalidator) poll(ctx context.Context) {
+	c.mu.Lock()
+	stores := make(map[string]trackedStore, len(c.stores))
+	for storeID, store := range c.stores {
+		if c.now().Sub(store.lastStored) > c.trackingTTL {
+			delete(c.stores, storeID)
+			continue
+		}
+		stores[storeID] = *store
+	}
+	c.mu.Unlock()
+
+	for storeID, store := range stores {
+		head, window, changed, err := c.readChanges(ctx, storeID, store)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Warn("failed to read the changelog of the store to invalidate its check cache",
+					zap.String("store_id", storeID),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+
+		if changed {
+			if err := c.invalidator.InvalidateStore(ctx, storeID); err != nil {
+				c.logger.Warn("failed to invalidate the check cache of the store",
+					zap.String("store_id", storeID),
+					zap.Error(err),
+				)
+				// the changes are read again on the next poll
+				continue
+			}
+			checkCacheChangelogInvalidationCounter.Inc()
+		}
+
+		c.mu.Lock()
+		if tracked, ok := c.stores[storeID]; ok {
+			tracked.started = true
+			tracked.head = head
+			tracked.window = window
+		}
+		c.mu.Unlock()
+	}
+}
+
+// readChanges reads the changelog of the store from its head back to the horizon offset before the head previously
+// read, or before the new head if there was none. It returns the new head, the changes within the horizon offset
+// before it, and whether any change read wasn't read before, unless the store's changelog wasn't read yet.
+func (c *ChangelogCacheInvalidator) readChanges(ctx context.Context, storeID string, store trackedStore) (time.Time, map[string]struct{}, bool, error) {
+	var head, stop time.Time
+	window := map[string]struct{}{}
+	changed := false
+	token := ""
+	for {
+		opts := storage.ReadChangesOptions{
+			Pagination: storage.NewPaginationOptions(changelogInvalidationPageSize, token),
+			SortDesc:   true,
+		}
+		changes, next, err := c.changelog.ReadChanges(ctx, storeID, "", opts, c.horizonOffset)
+		if errors.Is(err, storage.ErrNotFound) {
+			return head, window, changed, nil
+		}
+		if err != nil {
+			return time.Time{}, nil, false, err
+		}
+
+		for _, change := range changes {
+			timestamp := change.GetTimestamp().AsTime()
+			if head.IsZero() {
+				head = timestamp
+				stop = head.Add(-c.horizonOffset)
+				if !store.head.IsZero() {
+					stop = store.head.Add(-c.horizonOffset)
+				}
+			}
+			if timestamp.Before(stop) {
+				return head, window, changed, nil
+			}
+
+			key := changelogWindowKey(change)
+			if !timestamp.Before(head.Add(-c.horizonOffset)) {
+				window[key] = struct{}{}
+			}
+			if _, ok := store.window[key]; !ok && store.started {
+				changed = true
+			}
+		}
+		token = string(next)
+	}
+}
+
+// changelogWindowKey identifies a change within the changes read around the head of a changelog.
+func changelogWindowKey(change *openfgav1.TupleChange) string {
+	return fmt.Sprintf("%d|%s|%s", change.GetTimestamp().AsTime().UnixNano(), change.GetOperation(), tuple.TupleKeyToString(change.GetTupleKey()))
+}
+
+// storeTrackingCacheBackend is a CheckCacheBackend that makes a ChangelogCacheInvalidator track the stores of the
+// entries stored.
+type storeTrackingCacheBackend struct {
+	CheckCacheBackend
+	invalidator *ChangelogCacheInvalidator
+}
+
+func (b *storeTrackingCacheBackend) Set(key string, value []byte, ttl time.Duration) error {
+	if storeID, _, ok := strings.Cut(key, "/"); ok {
+		b.invalidator.track(storeID)
+	}
+	return b.CheckCacheBackend.Set(key, value, ttl)
+}
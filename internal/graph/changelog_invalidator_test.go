@@ -0,0 +1,239 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// fakeChangelog is a changelog whose changes are given with their time, and that counts its reads.
+type fakeChangelog struct {
+	changes []*openfgav1.TupleChange
+	reads   int
+}
+
+func (f *fakeChangelog) add(object string, timestamp time.Time) {
+	f.changes = append(f.changes, &openfgav1.TupleChange{
+		TupleKey:  tuple.NewTupleKey(object, "viewer", "user:anne"),
+		Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+		Timestamp: timestamppb.New(timestamp),
+	})
+}
+
+// ReadChanges only supports reading the changes from the most recent to the oldest.
+func (f *fakeChangelog) ReadChanges(_ context.Context, _, _ string, options storage.ReadChangesOptions, horizonOffset time.Duration) ([]*openfgav1.TupleChange, []byte, error) {
+	f.reads++
+
+	var changes []*openfgav1.TupleChange
+	for _, change := range f.changes {
+		if !change.GetTimestamp().AsTime().After(time.Now().Add(-horizonOffset)) {
+			changes = append(changes, change)
+		}
+	}
+	slices.SortStableFunc(changes, func(a, b *openfgav1.TupleChange) int {
+		return b.GetTimestamp().AsTime().Compare(a.GetTimestamp().AsTime())
+	})
+
+	from := 0
+	if options.Pagination.From != "" {
+		from, _ = strconv.Atoi(options.Pagination.From)
+	}
+	to := min(from+options.Pagination.PageSize, len(changes))
+	if from >= to {
+		return nil, nil, storage.ErrNotFound
+	}
+	return changes[from:to], []byte(strconv.Itoa(to)), nil
+}
+
+// fakeCacheInvalidator records the invalidations of the stores.
+type fakeCacheInvalidator struct {
+	mu            sync.Mutex
+	invalidations map[string]int
+}
+
+func (f *fakeCacheInvalidator) InvalidateStore(_ context.Context, storeID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalidations[storeID]++
+	return nil
+}
+
+func (f *fakeCacheInvalidator) AwaitStoreInvalidation(context.Context, string) error {
+	return nil
+}
+
+func (f *fakeCacheInvalidator) count(storeID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.invalidations[storeID]
+}
+
+func TestChangelogCacheInvalidator(t *testing.T) {
+	ctx := context.Background()
+
+	writeTuple := func(t *testing.T, ds storage.OpenFGADatastore, storeID, object string) {
+		err := ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tuple.NewTupleKey(object, "viewer", "user:anne")})
+		require.NoError(t, err)
+		// the changes are only read once they are in the past
+		time.Sleep(time.Millisecond)
+	}
+
+	setup := func(t *testing.T) (storage.OpenFGADatastore, *fakeCacheInvalidator, *ChangelogCacheInvalidator, CheckCacheBackend) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		invalidator := &fakeCacheInvalidator{invalidations: map[string]int{}}
+		changelogInvalidator := NewChangelogCacheInvalidator(ds, invalidator, time.Hour, time.Minute, 0, logger.NewNoopLogger())
+		backend := changelogInvalidator.TrackStores(&fakeCacheBackend{entries: map[string][]byte{}})
+		return ds, invalidator, changelogInvalidator, backend
+	}
+
+	t.Run("invalidates_the_stores_with_new_changes", func(t *testing.T) {
+		ds, invalidator, changelogInvalidator, backend := setup(t)
+
+		writeTuple(t, ds, "store", "document:1")
+		require.NoError(t, backend.Set(CheckCacheKeyStorePrefix("store")+"1", []byte("allowed"), time.Minute))
+
+		// the changes before the first poll don't cause an invalidation
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 0, invalidator.count("store"))
+
+		writeTuple(t, ds, "store", "document:2")
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 1, invalidator.count("store"))
+
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 1, invalidator.count("store"))
+	})
+
+	t.Run("invalidates_a_store_without_changes_before_the_first_poll", func(t *testing.T) {
+		ds, invalidator, changelogInvalidator, backend := setup(t)
+
+		require.NoError(t, backend.Set(CheckCacheKeyStorePrefix("store")+"1", []byte("allowed"), time.Minute))
+		changelogInvalidator.poll(ctx)
+
+		writeTuple(t, ds, "store", "document:1")
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 1, invalidator.count("store"))
+	})
+
+	t.Run("only_polls_the_stores_of_the_entries_stored", func(t *testing.T) {
+		ds, invalidator, changelogInvalidator, backend := setup(t)
+
+		require.NoError(t, backend.Set(CheckCacheKeyStorePrefix("store")+"1", []byte("allowed"), time.Minute))
+		require.NoError(t, backend.Set("authorization_model", []byte("model"), time.Minute))
+		changelogInvalidator.poll(ctx)
+
+		writeTuple(t, ds, "other", "document:1")
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 0, invalidator.count("other"))
+		require.Equal(t, 0, invalidator.count("store"))
+	})
+
+	t.Run("stops_polling_a_store_once_its_entries_expired", func(t *testing.T) {
+		ds, invalidator, changelogInvalidator, backend := setup(t)
+
+		now := time.Now()
+		changelogInvalidator.now = func() time.Time { return now }
+
+		require.NoError(t, backend.Set(CheckCacheKeyStorePrefix("store")+"1", []byte("allowed"), time.Minute))
+		changelogInvalidator.poll(ctx)
+
+		now = now.Add(2 * time.Minute)
+		writeTuple(t, ds, "store", "document:1")
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 0, invalidator.count("store"))
+		require.Empty(t, changelogInvalidator.stores)
+	})
+
+	t.Run("reads_the_changelog_from_its_head", func(t *testing.T) {
+		changelog := &fakeChangelog{}
+		now := time.Now()
+		for i := 0; i < 3*changelogInvalidationPageSize; i++ {
+			changelog.add(fmt.Sprintf("document:%d", i), now.Add(time.Duration(i-3*changelogInvalidationPageSize)*time.Second))
+		}
+
+		invalidator := &fakeCacheInvalidator{invalidations: map[string]int{}}
+		changelogInvalidator := NewChangelogCacheInvalidator(changelog, invalidator, time.Hour, time.Minute, 0, logger.NewNoopLogger())
+		backend := changelogInvalidator.TrackStores(&fakeCacheBackend{entries: map[string][]byte{}})
+
+		require.NoError(t, backend.Set(CheckCacheKeyStorePrefix("store")+"1", []byte("allowed"), time.Minute))
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 1, changelog.reads)
+
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 2, changelog.reads)
+		require.Equal(t, 0, invalidator.count("store"))
+	})
+
+	t.Run("invalidates_the_changes_committed_late_within_the_horizon_offset", func(t *testing.T) {
+		changelog := &fakeChangelog{}
+		now := time.Now()
+		changelog.add("document:1", now.Add(-10*time.Minute))
+
+		invalidator := &fakeCacheInvalidator{invalidations: map[string]int{}}
+		changelogInvalidator := NewChangelogCacheInvalidator(changelog, invalidator, time.Hour, time.Hour, time.Minute, logger.NewNoopLogger())
+		backend := changelogInvalidator.TrackStores(&fakeCacheBackend{entries: map[string][]byte{}})
+
+		require.NoError(t, backend.Set(CheckCacheKeyStorePrefix("store")+"1", []byte("allowed"), time.Hour))
+		changelogInvalidator.poll(ctx)
+
+		// the changes within the horizon offset aren't read yet
+		changelog.add("document:2", now)
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 0, invalidator.count("store"))
+
+		changelog.add("document:3", now.Add(-5*time.Minute))
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 1, invalidator.count("store"))
+
+		// a change committed after the head, but within the horizon offset before it
+		changelog.add("document:4", now.Add(-5*time.Minute-30*time.Second))
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 2, invalidator.count("store"))
+
+		changelogInvalidator.poll(ctx)
+		require.Equal(t, 2, invalidator.count("store"))
+	})
+
+	t.Run("run_stops_when_the_context_is_done", func(t *testing.T) {
+		ds, invalidator, _, _ := setup(t)
+		changelogInvalidator := NewChangelogCacheInvalidator(ds, invalidator, time.Millisecond, time.Minute, 0, logger.NewNoopLogger())
+		backend := changelogInvalidator.TrackStores(&fakeCacheBackend{entries: map[string][]byte{}})
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			changelogInvalidator.Run(runCtx)
+		}()
+
+		require.NoError(t, backend.Set(CheckCacheKeyStorePrefix("store")+"1", []byte("allowed"), time.Minute))
+		require.Eventually(t, func() bool {
+			changelogInvalidator.mu.Lock()
+			defer changelogInvalidator.mu.Unlock()
+			return changelogInvalidator.stores["store"].started
+		}, time.Second, time.Millisecond)
+
+		writeTuple(t, ds, "store", "document:1")
+		require.Eventually(t, func() bool {
+			return invalidator.count("store") == 1
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+	})
+}
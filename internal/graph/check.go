@@ -4,17 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/exp/maps"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/openfga/openfga/internal/concurrency"
-	"github.com/openfga/openfga/internal/condition"
 	"github.com/openfga/openfga/internal/condition/eval"
 	openfgaErrors "github.com/openfga/openfga/internal/errors"
 	serverconfig "github.com/openfga/openfga/internal/server/config"
@@ -35,8 +35,34 @@ type ResolveCheckRequest struct {
 	ContextualTuples     []*openfgav1.TupleKey
 	Context              *structpb.Struct
 	RequestMetadata      *ResolveCheckRequestMetadata
-	VisitedPaths         map[string]struct{}
-	Consistency          openfgav1.ConsistencyPreference
+	// VisitedPaths are the Checks on the path from the root problem to this one, to detect cycles. The path is
+	// immutable, so it is shared between a request and its clones.
+	VisitedPaths *visitedPath
+	Consistency  openfgav1.ConsistencyPreference
+
+	// DisableFastPath resolves the request with the canonical algorithms only, bypassing the fast paths
+	// and the cache, e.g. to compare their results.
+	DisableFastPath bool
+
+	// BypassCache resolves the request without reading the cached results of the Check cache, so that the
+	// result reflects the tuples read from the datastore. The result is still cached for the other requests.
+	BypassCache bool
+
+	// KnownResults are results of subproblems supplied by the caller, keyed by 'object#relation@user', that are
+	// trusted instead of being resolved. They are not validated by the resolver, so they must only be set from
+	// a trusted source.
+	KnownResults map[string]bool
+}
+
+// visitedPath is a Check on a path, linked to the previous ones. The Checks of a path are partitioned by the
+// type of their object, so that looking up a Check only compares it to the Checks of the same type.
+type visitedPath struct {
+	objectType string
+	key        string
+	// next is the previous Check of the path.
+	next *visitedPath
+	// nextOfType is the previous Check of the path whose object is of the same type.
+	nextOfType *visitedPath
 }
 
 func clone(r *ResolveCheckRequest) *ResolveCheckRequest {
@@ -51,9 +77,13 @@ func clone(r *ResolveCheckRequest) *ResolveCheckRequest {
 			Depth:               r.GetRequestMetadata().Depth,
 			DatastoreQueryCount: r.GetRequestMetadata().DatastoreQueryCount,
 			WasThrottled:        r.GetRequestMetadata().WasThrottled,
+			VisitedObjects:      r.GetRequestMetadata().VisitedObjects,
 		},
-		VisitedPaths: maps.Clone(r.VisitedPaths),
-		Consistency:  r.Consistency,
+		VisitedPaths:    r.VisitedPaths,
+		Consistency:     r.Consistency,
+		DisableFastPath: r.DisableFastPath,
+		BypassCache:     r.BypassCache,
+		KnownResults:    r.KnownResults,
 	}
 }
 
@@ -70,6 +100,7 @@ func CloneResolveCheckResponse(r *ResolveCheckResponse) *ResolveCheckResponse {
 	if r.GetResolutionMetadata() != nil {
 		resolutionMetadata.DatastoreQueryCount = r.GetResolutionMetadata().DatastoreQueryCount
 		resolutionMetadata.CycleDetected = r.GetResolutionMetadata().CycleDetected
+		resolutionMetadata.Cached = r.GetResolutionMetadata().Cached
 	}
 
 	return &ResolveCheckResponse{
@@ -161,6 +192,27 @@ func (r *ResolveCheckRequest) GetConsistency() openfgav1.ConsistencyPreference {
 	return openfgav1.ConsistencyPreference_UNSPECIFIED
 }
 
+func (r *ResolveCheckRequest) GetDisableFastPath() bool {
+	if r != nil {
+		return r.DisableFastPath
+	}
+	return false
+}
+
+func (r *ResolveCheckRequest) GetBypassCache() bool {
+	if r != nil {
+		return r.BypassCache
+	}
+	return false
+}
+
+func (r *ResolveCheckRequest) GetKnownResults() map[string]bool {
+	if r != nil {
+		return r.KnownResults
+	}
+	return nil
+}
+
 type setOperatorType int
 
 const (
@@ -181,6 +233,14 @@ type LocalChecker struct {
 	usersetBatchSize     uint32
 	optimizationsEnabled bool
 	logger               logger.Logger
+	assumptions          map[string]struct{}
+	selfRelations        map[SelfRelation]struct{}
+	maxVisitedObjects    uint32
+	emptyObjectCache     storage.InMemoryCache[bool]
+	emptyObjectCacheTTL  time.Duration
+	// emptyObjectCacheWritesPaused returns whether the objects read must not be cached, see
+	// WithEmptyObjectCacheWritesPaused.
+	emptyObjectCacheWritesPaused func() bool
 }
 
 type LocalCheckerOption func(d *LocalChecker)
@@ -212,12 +272,119 @@ func WithMaxConcurrentReads(limit uint32) LocalCheckerOption {
 	}
 }
 
+// WithMaxVisitedObjects see server.WithCheckMaxVisitedObjects.
+func WithMaxVisitedObjects(limit uint32) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.maxVisitedObjects = limit
+	}
+}
+
 func WithLocalCheckerLogger(logger logger.Logger) LocalCheckerOption {
 	return func(d *LocalChecker) {
 		d.logger = logger
 	}
 }
 
+// WithAssumptions makes the LocalChecker resolve as allowed every Check and subproblem that matches one
+// of the given 'object#relation@user' assumptions, as if the assumption were true. An assumption matches
+// the Checks of its exact object, relation and user, whether the relation is directly assignable or
+// computed. If its user is a typed wildcard (e.g. 'user:*'), it also matches the Checks of any user object
+// of that type. Assumptions can only make Checks allowed: relations that exclude an assumed relation
+// (e.g. 'but not blocked') become not allowed. The results of such a LocalChecker must not be cached.
+func WithAssumptions(assumptions ...*openfgav1.TupleKey) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.assumptions = make(map[string]struct{}, len(assumptions))
+		for _, assumption := range assumptions {
+			d.assumptions[tuple.TupleKeyToString(assumption)] = struct{}{}
+		}
+	}
+}
+
+// isAssumed returns whether the Check of tk matches one of the assumptions set with WithAssumptions.
+func (c *LocalChecker) isAssumed(tk *openfgav1.TupleKey) bool {
+	if len(c.assumptions) == 0 {
+		return false
+	}
+
+	if _, ok := c.assumptions[tuple.TupleKeyToString(tk)]; ok {
+		return true
+	}
+
+	user := tk.GetUser()
+	if !tuple.IsValidObject(user) || tuple.IsTypedWildcard(user) {
+		return false
+	}
+	wildcard := tuple.TypedPublicWildcard(tuple.GetType(user))
+	_, ok := c.assumptions[tuple.TupleKeyToString(tuple.NewTupleKey(tk.GetObject(), tk.GetRelation(), wildcard))]
+	return ok
+}
+
+// SelfRelation is a relation of an object type that every user of a type has with the object of the same ID,
+// e.g. with 'profile#viewer@user', user:anne is a viewer of profile:anne.
+type SelfRelation struct {
+	ObjectType string
+	Relation   string
+	UserType   string
+}
+
+func (r SelfRelation) String() string {
+	return fmt.Sprintf("%s#%s@%s", r.ObjectType, r.Relation, r.UserType)
+}
+
+// ParseSelfRelation parses a SelfRelation of the form 'objectType#relation@userType'.
+func ParseSelfRelation(s string) (SelfRelation, error) {
+	objectRelation, userType, found := strings.Cut(s, "@")
+	objectType, relation, foundRelation := strings.Cut(objectRelation, "#")
+	if !found || !foundRelation || objectType == "" || relation == "" || userType == "" ||
+		strings.ContainsAny(objectType+relation+userType, ":#@ ") {
+		return SelfRelation{}, fmt.Errorf("invalid self relation '%s', must be of the form 'objectType#relation@userType'", s)
+	}
+	return SelfRelation{ObjectType: objectType, Relation: relation, UserType: userType}, nil
+}
+
+// WithSelfRelations makes the LocalChecker resolve as allowed every Check and subproblem of a self relation
+// whose user is an object with the same ID as the object, e.g. profile:anne#viewer@user:anne, as if the
+// relation had a tuple for it. Like a tuple, it also grants the relations that are computed from it, e.g. a
+// 'define can_view: viewer' of the profile, and the relations of other objects that reach it, e.g. a
+// 'define viewer: viewer from profile' of the posts of a profile.
+func WithSelfRelations(relations ...SelfRelation) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.selfRelations = make(map[SelfRelation]struct{}, len(relations))
+		for _, relation := range relations {
+			d.selfRelations[relation] = struct{}{}
+		}
+	}
+}
+
+// isSelf returns whether the Check of tk is for a self relation set with WithSelfRelations, and its user is the
+// object of the user type with the ID of the object.
+func (c *LocalChecker) isSelf(tk *openfgav1.TupleKey) bool {
+	if len(c.selfRelations) == 0 {
+		return false
+	}
+
+	user := tk.GetUser()
+	if !tuple.IsValidObject(user) || tuple.IsTypedWildcard(user) {
+		return false
+	}
+
+	objectType, objectID := tuple.SplitObject(tk.GetObject())
+	userType, userID := tuple.SplitObject(user)
+	if objectID != userID {
+		return false
+	}
+
+	_, ok := c.selfRelations[SelfRelation{ObjectType: objectType, Relation: tk.GetRelation(), UserType: userType}]
+	return ok
+}
+
+// canUseFastPath returns whether the usersets and the tuple to usersets of the request can be resolved with
+// the fast paths, which read the tuples of their subproblems instead of dispatching them, so they don't see
+// the self relations.
+func (c *LocalChecker) canUseFastPath(req *ResolveCheckRequest) bool {
+	return c.optimizationsEnabled && len(c.selfRelations) == 0 && !req.GetDisableFastPath()
+}
+
 // NewLocalChecker constructs a LocalChecker that can be used to evaluate a Check
 // request locally.
 //
@@ -559,6 +726,9 @@ func exclusion(ctx context.Context, concurrencyLimit uint32, handlers ...CheckHa
 
 // Close is a noop.
 func (c *LocalChecker) Close() {
+	if c.emptyObjectCache != nil {
+		c.emptyObjectCache.Stop()
+	}
 }
 
 // dispatch clones the parent request, modifies its metadata and tupleKey, and dispatches the new request
@@ -615,10 +785,17 @@ func (c *LocalChecker) ResolveCheck(
 	object := tupleKey.GetObject()
 	relation := tupleKey.GetRelation()
 
+	// unlike the depth, which bounds each path, this bounds the breadth of the whole Check
+	if visitedObjects := req.GetRequestMetadata().VisitedObjects; c.maxVisitedObjects > 0 && visitedObjects != nil {
+		if visited := visitedObjects.Visit(object); visited > c.maxVisitedObjects {
+			return nil, &MaxVisitedObjectsExceededError{Limit: c.maxVisitedObjects, Visited: visited}
+		}
+	}
+
 	userObject, userRelation := tuple.SplitObjectRelation(req.GetTupleKey().GetUser())
 
 	// Check(document:1#viewer@document:1#viewer) will always return true
-	if relation == userRelation && object == userObject {
+	if (relation == userRelation && object == userObject) || c.isAssumed(tupleKey) || c.isSelf(tupleKey) {
 		return &ResolveCheckResponse{
 			Allowed: true,
 			ResolutionMetadata: &ResolveCheckResponseMetadata{
@@ -627,6 +804,16 @@ func (c *LocalChecker) ResolveCheck(
 		}, nil
 	}
 
+	if allowed, ok := req.GetKnownResults()[tuple.TupleKeyToString(tupleKey)]; ok {
+		span.SetAttributes(attribute.Bool("known_result", true))
+		return &ResolveCheckResponse{
+			Allowed: allowed,
+			ResolutionMetadata: &ResolveCheckResponseMetadata{
+				DatastoreQueryCount: req.GetRequestMetadata().DatastoreQueryCount,
+			},
+		}, nil
+	}
+
 	typesys, ok := typesystem.TypesystemFromContext(ctx)
 	if !ok {
 		return nil, fmt.Errorf("%w: typesystem missing in context", openfgaErrors.ErrUnknown)
@@ -642,6 +829,16 @@ func (c *LocalChecker) ResolveCheck(
 		return nil, fmt.Errorf("relation '%s' undefined for object type '%s'", relation, objectType)
 	}
 
+	if !req.GetDisableFastPath() && grantsDirectly(ctx, req, typesys, rel.GetRewrite()) {
+		span.SetAttributes(attribute.Bool("contextual_tuple_grant", true))
+		return &ResolveCheckResponse{
+			Allowed: true,
+			ResolutionMetadata: &ResolveCheckResponseMetadata{
+				DatastoreQueryCount: req.GetRequestMetadata().DatastoreQueryCount,
+			},
+		}, nil
+	}
+
 	resp, err := c.checkRewrite(ctx, req, rel.GetRewrite())(ctx)
 	if err != nil {
 		telemetry.TraceError(span, err)
@@ -651,19 +848,72 @@ func (c *LocalChecker) ResolveCheck(
 	return resp, nil
 }
 
+// grantsDirectly returns true if one of the contextual tuples of the request is the requested tuple and is
+// enough to grant the relation, so that the Check is answered without reading any tuple. This requires the
+// relation to be either directly assignable or a union with a directly assignable operand, since an
+// intersection or an exclusion could still deny it.
+func grantsDirectly(ctx context.Context, req *ResolveCheckRequest, typesys *typesystem.TypeSystem, rewrite *openfgav1.Userset) bool {
+	if len(req.GetContextualTuples()) == 0 {
+		return false
+	}
+
+	_, directlyAssignable := rewrite.GetUserset().(*openfgav1.Userset_This)
+	for _, operand := range rewrite.GetUnion().GetChild() {
+		if _, ok := operand.GetUserset().(*openfgav1.Userset_This); ok {
+			directlyAssignable = true
+		}
+	}
+	if !directlyAssignable {
+		return false
+	}
+
+	tk := req.GetTupleKey()
+	for _, contextualTuple := range req.GetContextualTuples() {
+		if contextualTuple.GetObject() != tk.GetObject() || contextualTuple.GetRelation() != tk.GetRelation() || contextualTuple.GetUser() != tk.GetUser() {
+			continue
+		}
+
+		if validation.ValidateTuple(typesys, contextualTuple) != nil {
+			return false
+		}
+
+		// a condition that can't be evaluated is reported by the regular resolution
+		conditionMet, err := buildTupleKeyConditionFilter(ctx, req.GetContext(), typesys)(contextualTuple)
+		return err == nil && conditionMet
+	}
+	return false
+}
+
 // hasCycle returns true if a cycle has been found. It modifies the request object.
+// Contextual tuples are read along with the stored tuples, so a cycle is detected the same way whether its tuples
+// are contextual, stored or both.
+// Only the Checks of the type of the object are compared, and the path is extended without copying it, so that
+// cloning a request is free and the cost of the visited paths doesn't grow with the number of types of a model.
 func (c *LocalChecker) hasCycle(req *ResolveCheckRequest) bool {
-	key := tuple.TupleKeyToString(req.GetTupleKey())
-	if req.VisitedPaths == nil {
-		req.VisitedPaths = map[string]struct{}{}
+	tk := req.GetTupleKey()
+	objectType := tuple.GetType(tk.GetObject())
+	key := tuple.TupleKeyToString(tk)
+
+	var lastOfType *visitedPath
+	for v := req.VisitedPaths; v != nil; v = v.next {
+		if v.objectType == objectType {
+			lastOfType = v
+			break
+		}
 	}
 
-	_, cycleDetected := req.VisitedPaths[key]
-	if cycleDetected {
-		return true
+	for v := lastOfType; v != nil; v = v.nextOfType {
+		if v.key == key {
+			return true
+		}
 	}
 
-	req.VisitedPaths[key] = struct{}{}
+	req.VisitedPaths = &visitedPath{
+		objectType: objectType,
+		key:        key,
+		next:       req.VisitedPaths,
+		nextOfType: lastOfType,
+	}
 	return false
 }
 
@@ -845,16 +1095,11 @@ func buildTupleKeyConditionFilter(ctx context.Context, reqCtx *structpb.Struct,
 	return func(t *openfgav1.TupleKey) (bool, error) {
 		condEvalResult, err := eval.EvaluateTupleCondition(ctx, t, typesys, reqCtx)
 		if err != nil {
-			return false, err
+			return eval.HandleEvaluationError(ctx, err)
 		}
 
 		if len(condEvalResult.MissingParameters) > 0 {
-			return false, condition.NewEvaluationError(
-				t.GetCondition().GetName(),
-				fmt.Errorf("tuple '%s' is missing context parameters '%v'",
-					tuple.TupleKeyToString(t),
-					condEvalResult.MissingParameters),
-			)
+			return eval.HandleMissingParameters(ctx, t, condEvalResult.MissingParameters)
 		}
 
 		return condEvalResult.ConditionMet, nil
@@ -1223,7 +1468,7 @@ func (c *LocalChecker) checkDirect(parentctx context.Context, req *ResolveCheckR
 			defer filteredIter.Stop()
 			resolver := c.checkUsersetSlowPath
 
-			if c.optimizationsEnabled {
+			if c.canUseFastPath(req) {
 				if !tuple.IsObjectRelation(reqTupleKey.GetUser()) {
 					if typesys.UsersetCanFastPath(directlyRelatedUsersetTypes) {
 						resolver = c.checkUsersetFastPath
@@ -1289,6 +1534,7 @@ func (c *LocalChecker) checkTTUSlowPath(ctx context.Context, req *ResolveCheckRe
 
 	computedRelation := rewrite.GetTupleToUserset().GetComputedUserset().GetRelation()
 	tk := req.GetTupleKey()
+	skipEmptyObjects := c.canUseEmptyObjectCache(ctx, req)
 
 	for {
 		t, err := iter.Next(ctx)
@@ -1314,6 +1560,10 @@ func (c *LocalChecker) checkTTUSlowPath(ctx context.Context, req *ResolveCheckRe
 		}
 
 		// Note: we add TTU read below
+		if skipEmptyObjects {
+			handlers = append(handlers, c.dispatchUnlessEmpty(ctx, req, tupleKey))
+			continue
+		}
 		handlers = append(handlers, c.dispatch(ctx, req, tupleKey))
 	}
 
@@ -1415,7 +1665,7 @@ func (c *LocalChecker) checkTTU(parentctx context.Context, req *ResolveCheckRequ
 
 		resolver := c.checkTTUSlowPath
 
-		if c.optimizationsEnabled {
+		if c.canUseFastPath(req) {
 			// TODO: optimize the case where user is an userset.
 			// If the user is a userset, we will not be able to use the shortcut because the algo
 			// will look up the objects associated with user.
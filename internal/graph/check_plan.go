@@ -0,0 +1,561 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgaErrors "github.com/openfga/openfga/internal/errors"
+	"github.com/openfga/openfga/internal/validation"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// CheckPlan is the resolution plan of the Checks of a relation of an object type, compiled by CompileCheckPlan.
+// It captures the rewrites of the relation, and of all the relations that it can reach, once, so that
+// ExecutePlan doesn't walk the model on each Check. Instances may be safely shared by multiple goroutines.
+type CheckPlan struct {
+	typesys  *typesystem.TypeSystem
+	root     *relationPlan
+	maxDepth uint32
+	// wildcards is whether the schema version of the model supports typed wildcards
+	wildcards bool
+}
+
+// CheckPlanOption defines an option that can be used to change the behavior of a CheckPlan.
+type CheckPlanOption func(*CheckPlan)
+
+// WithCheckPlanResolveNodeLimit sets the maximum depth of the resolution of the Checks of a CheckPlan.
+// Defaults to the same limit as the standard Check resolution.
+func WithCheckPlanResolveNodeLimit(limit uint32) CheckPlanOption {
+	return func(p *CheckPlan) {
+		p.maxDepth = limit
+	}
+}
+
+// relationPlan is the plan of a relation of an object type.
+type relationPlan struct {
+	objectType string
+	relation   string
+	rewrite    planNode
+}
+
+// planNode is a compiled rewrite, which resolves the relation of the object for the user.
+type planNode interface {
+	execute(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error)
+}
+
+// planResult is the outcome of a planNode, with the same semantics as a ResolveCheckResponse.
+type planResult struct {
+	allowed       bool
+	cycleDetected bool
+}
+
+// CompileCheckPlan returns the plan of the Checks of the relation of the object type in the model. The plan
+// resolves a Check the same way as the standard resolution without the fast paths, i.e. with the same depth
+// limit and cycle detection, but sequentially, without dispatching sub-problems nor caching them, which
+// makes it cheaper for Checks that are resolved with few reads, e.g. the same relation for many objects.
+func CompileCheckPlan(typesys *typesystem.TypeSystem, objectType, relation string, opts ...CheckPlanOption) (*CheckPlan, error) {
+	plan := &CheckPlan{
+		typesys:   typesys,
+		maxDepth:  defaultResolveNodeLimit,
+		wildcards: typesystem.IsSchemaVersionSupported(typesys.GetSchemaVersion()),
+	}
+	for _, opt := range opts {
+		opt(plan)
+	}
+
+	c := &planCompiler{typesys: typesys, plans: map[string]*relationPlan{}}
+	root, err := c.compileRelation(objectType, relation)
+	if err != nil {
+		return nil, err
+	}
+	plan.root = root
+	return plan, nil
+}
+
+// ExecutePlan resolves the Check of the relation of the plan between the object, which must be of the object
+// type of the plan, and the user, with the given context for the conditions. It reads the tuples of the store
+// from the storage.RelationshipTupleReader of ctx.
+func ExecutePlan(ctx context.Context, plan *CheckPlan, storeID, user, object string, reqContext *structpb.Struct) (bool, error) {
+	ctx, span := tracer.Start(ctx, "ExecutePlan", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+		attribute.String("relation", tuple.ToObjectRelationString(plan.root.objectType, plan.root.relation)),
+	))
+	defer span.End()
+
+	if objectType := tuple.GetType(object); objectType != plan.root.objectType {
+		return false, fmt.Errorf("the plan resolves Checks of objects of type '%s', not '%s'", plan.root.objectType, objectType)
+	}
+
+	ds, ok := storage.RelationshipTupleReaderFromContext(ctx)
+	if !ok {
+		return false, fmt.Errorf("%w: relationship tuple reader datastore missing in context", openfgaErrors.ErrUnknown)
+	}
+
+	e := &planExecution{
+		plan:            plan,
+		ds:              ds,
+		storeID:         storeID,
+		conditionFilter: buildTupleKeyConditionFilter(ctx, reqContext, plan.typesys),
+		visited:         map[string]struct{}{},
+	}
+	result, err := e.evaluate(ctx, plan.root, object, user, plan.maxDepth)
+	if err != nil {
+		return false, err
+	}
+	span.SetAttributes(attribute.Bool("allowed", result.allowed))
+	return result.allowed, nil
+}
+
+// planCompiler compiles the plans of the relations reachable from a relation. Relations that are reached
+// several times, including recursively, share the same plan.
+type planCompiler struct {
+	typesys *typesystem.TypeSystem
+	// plans of the relations by 'objectType#relation'
+	plans map[string]*relationPlan
+}
+
+func (c *planCompiler) compileRelation(objectType, relation string) (*relationPlan, error) {
+	key := tuple.ToObjectRelationString(objectType, relation)
+	if plan, ok := c.plans[key]; ok {
+		return plan, nil
+	}
+
+	rel, err := c.typesys.GetRelation(objectType, relation)
+	if err != nil {
+		return nil, err
+	}
+
+	// registered before compiling the rewrite, so that recursive relations refer to it
+	plan := &relationPlan{objectType: objectType, relation: relation}
+	c.plans[key] = plan
+
+	plan.rewrite, err = c.compileRewrite(objectType, relation, rel.GetRewrite())
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (c *planCompiler) compileRewrite(objectType, relation string, rewrite *openfgav1.Userset) (planNode, error) {
+	switch rw := rewrite.GetUserset().(type) {
+	case *openfgav1.Userset_This:
+		return c.compileDirect(objectType, relation)
+	case *openfgav1.Userset_ComputedUserset:
+		plan, err := c.compileRelation(objectType, rw.ComputedUserset.GetRelation())
+		if err != nil {
+			return nil, err
+		}
+		return &computedUsersetNode{plan: plan}, nil
+	case *openfgav1.Userset_TupleToUserset:
+		return c.compileTTU(objectType, rw.TupleToUserset)
+	case *openfgav1.Userset_Union:
+		children, err := c.compileRewrites(objectType, relation, rw.Union.GetChild())
+		if err != nil {
+			return nil, err
+		}
+		return &unionNode{children: children}, nil
+	case *openfgav1.Userset_Intersection:
+		children, err := c.compileRewrites(objectType, relation, rw.Intersection.GetChild())
+		if err != nil {
+			return nil, err
+		}
+		return &intersectionNode{children: children}, nil
+	case *openfgav1.Userset_Difference:
+		children, err := c.compileRewrites(objectType, relation, []*openfgav1.Userset{rw.Difference.GetBase(), rw.Difference.GetSubtract()})
+		if err != nil {
+			return nil, err
+		}
+		return &exclusionNode{base: children[0], subtract: children[1]}, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected set operator type encountered", openfgaErrors.ErrUnknown)
+	}
+}
+
+func (c *planCompiler) compileRewrites(objectType, relation string, rewrites []*openfgav1.Userset) ([]planNode, error) {
+	nodes := make([]planNode, 0, len(rewrites))
+	for _, rewrite := range rewrites {
+		node, err := c.compileRewrite(objectType, relation, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (c *planCompiler) compileDirect(objectType, relation string) (planNode, error) {
+	refs, err := c.typesys.GetDirectlyRelatedUserTypes(objectType, relation)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &directNode{
+		relation:       relation,
+		directUserRefs: map[string]struct{}{},
+		usersetPlans:   map[string]*relationPlan{},
+	}
+	for _, ref := range refs {
+		switch {
+		case ref.GetWildcard() != nil:
+			node.usersetRefs = append(node.usersetRefs, ref)
+		case ref.GetRelation() != "":
+			node.usersetRefs = append(node.usersetRefs, ref)
+			node.directUserRefs[tuple.ToObjectRelationString(ref.GetType(), ref.GetRelation())] = struct{}{}
+
+			plan, err := c.compileRelation(ref.GetType(), ref.GetRelation())
+			if err != nil {
+				return nil, err
+			}
+			node.usersetPlans[tuple.ToObjectRelationString(ref.GetType(), ref.GetRelation())] = plan
+		default:
+			node.directUserRefs[ref.GetType()] = struct{}{}
+		}
+	}
+	return node, nil
+}
+
+func (c *planCompiler) compileTTU(objectType string, ttu *openfgav1.TupleToUserset) (planNode, error) {
+	tuplesetRelation := ttu.GetTupleset().GetRelation()
+	computedRelation := ttu.GetComputedUserset().GetRelation()
+
+	refs, err := c.typesys.GetDirectlyRelatedUserTypes(objectType, tuplesetRelation)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &ttuNode{
+		tuplesetRelation: tuplesetRelation,
+		parentPlans:      map[string]*relationPlan{},
+	}
+	for _, ref := range refs {
+		plan, err := c.compileRelation(ref.GetType(), computedRelation)
+		if err != nil {
+			if errors.Is(err, typesystem.ErrRelationUndefined) {
+				continue // the computed relation is undefined on some parents
+			}
+			return nil, err
+		}
+		node.parentPlans[ref.GetType()] = plan
+	}
+	return node, nil
+}
+
+// planExecution is the state of the resolution of a Check by ExecutePlan.
+type planExecution struct {
+	plan            *CheckPlan
+	ds              storage.RelationshipTupleReader
+	storeID         string
+	conditionFilter storage.TupleKeyConditionFilterFunc
+	// visited are the Checks of the current path, to detect cycles
+	visited map[string]struct{}
+}
+
+// evaluate resolves the relation of the plan between the object and the user, like ResolveCheck.
+func (e *planExecution) evaluate(ctx context.Context, plan *relationPlan, object, user string, depth uint32) (planResult, error) {
+	if ctx.Err() != nil {
+		return planResult{}, ctx.Err()
+	}
+
+	if depth == 0 {
+		return planResult{}, ErrResolutionDepthExceeded
+	}
+
+	// the Checks are resolved sequentially, so the path is backtracked instead of cloned
+	key := tuple.ToObjectRelationString(object, plan.relation) + "@" + user
+	if _, ok := e.visited[key]; ok {
+		return planResult{cycleDetected: true}, nil
+	}
+	e.visited[key] = struct{}{}
+	defer delete(e.visited, key)
+
+	if userObject, userRelation := tuple.SplitObjectRelation(user); userObject == object && userRelation == plan.relation {
+		return planResult{allowed: true}, nil
+	}
+
+	return plan.rewrite.execute(ctx, e, object, user, depth)
+}
+
+// filteredIterator returns the tuples of iter that are valid and whose condition is met.
+func (e *planExecution) filteredIterator(iter storage.TupleIterator) *storage.ConditionsFilteredTupleKeyIterator {
+	return storage.NewConditionsFilteredTupleKeyIterator(
+		storage.NewFilteredTupleKeyIterator(
+			storage.NewTupleKeyIteratorFromTupleIterator(iter),
+			validation.FilterInvalidTuples(e.plan.typesys),
+		),
+		e.conditionFilter,
+	)
+}
+
+// directNode resolves the tuples of the relation, like checkDirect.
+type directNode struct {
+	relation string
+	// directUserRefs are the 'type' and 'type#relation' of the users that can be directly assigned
+	directUserRefs map[string]struct{}
+	// usersetRefs are the typed wildcards and the usersets that can be directly assigned
+	usersetRefs []*openfgav1.RelationReference
+	// usersetPlans are the plans of the usersets by 'type#relation'
+	usersetPlans map[string]*relationPlan
+}
+
+func (n *directNode) execute(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error) {
+	var result planResult
+	var userTupleErr error
+
+	userType := tuple.GetType(user)
+	userRef := userType
+	if userRelation := tuple.GetRelation(user); userRelation != "" {
+		userRef = tuple.ToObjectRelationString(userType, userRelation)
+	}
+	if _, ok := n.directUserRefs[userRef]; ok {
+		allowed, err := n.executeUserTuple(ctx, e, object, user)
+		if err != nil {
+			userTupleErr = err
+		} else if allowed {
+			return planResult{allowed: true}, nil
+		}
+	}
+
+	if len(n.usersetRefs) > 0 {
+		var err error
+		result, err = n.executeUsersetTuples(ctx, e, object, user, depth)
+		if err != nil {
+			return planResult{}, err
+		}
+		if result.allowed {
+			return result, nil
+		}
+	}
+
+	if userTupleErr != nil {
+		return planResult{}, userTupleErr
+	}
+	return result, nil
+}
+
+func (n *directNode) executeUserTuple(ctx context.Context, e *planExecution, object, user string) (bool, error) {
+	t, err := e.ds.ReadUserTuple(ctx, e.storeID, tuple.NewTupleKey(object, n.relation, user), storage.ReadUserTupleOptions{})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if validation.ValidateTuple(e.plan.typesys, t.GetKey()) != nil {
+		return false, nil
+	}
+	return e.conditionFilter(t.GetKey())
+}
+
+func (n *directNode) executeUsersetTuples(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error) {
+	iter, err := e.ds.ReadUsersetTuples(ctx, e.storeID, storage.ReadUsersetTuplesFilter{
+		Object:                      object,
+		Relation:                    n.relation,
+		AllowedUserTypeRestrictions: n.usersetRefs,
+	}, storage.ReadUsersetTuplesOptions{})
+	if err != nil {
+		return planResult{}, err
+	}
+	filteredIter := e.filteredIterator(iter)
+	defer filteredIter.Stop()
+
+	var usersets []*openfgav1.TupleKey
+	for {
+		t, err := filteredIter.Next(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrIteratorDone) {
+				break
+			}
+			return planResult{}, err
+		}
+
+		usersetObject, usersetRelation := tuple.SplitObjectRelation(t.GetUser())
+		if tuple.IsTypedWildcard(usersetObject) && e.plan.wildcards {
+			if tuple.GetType(user) == tuple.GetType(usersetObject) {
+				return planResult{allowed: true}, nil
+			}
+			continue
+		}
+
+		if usersetRelation != "" {
+			usersets = append(usersets, t)
+		}
+	}
+
+	// the usersets are resolved once the iterator is done, like the dispatches of checkUsersetSlowPath
+	var result planResult
+	var unionErr error
+	for _, t := range usersets {
+		usersetObject, usersetRelation := tuple.SplitObjectRelation(t.GetUser())
+		plan, ok := n.usersetPlans[tuple.ToObjectRelationString(tuple.GetType(usersetObject), usersetRelation)]
+		if !ok {
+			continue
+		}
+
+		childResult, err := e.evaluate(ctx, plan, usersetObject, user, depth-1)
+		if err != nil {
+			unionErr = err
+			continue
+		}
+		if childResult.allowed {
+			return childResult, nil
+		}
+		result.cycleDetected = result.cycleDetected || childResult.cycleDetected
+	}
+	if unionErr != nil {
+		return planResult{}, unionErr
+	}
+	return result, nil
+}
+
+// computedUsersetNode resolves another relation of the same object, like checkComputedUserset.
+type computedUsersetNode struct {
+	plan *relationPlan
+}
+
+func (n *computedUsersetNode) execute(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error) {
+	// like checkComputedUserset, this doesn't increase the resolution depth
+	return e.evaluate(ctx, n.plan, object, user, depth)
+}
+
+// ttuNode resolves the computed relation of the parents of the object, like checkTTU.
+type ttuNode struct {
+	tuplesetRelation string
+	// parentPlans are the plans of the computed relation by type of parent
+	parentPlans map[string]*relationPlan
+}
+
+func (n *ttuNode) execute(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error) {
+	iter, err := e.ds.Read(ctx, e.storeID, tuple.NewTupleKey(object, n.tuplesetRelation, ""), storage.ReadOptions{})
+	if err != nil {
+		return planResult{}, err
+	}
+	filteredIter := e.filteredIterator(iter)
+	defer filteredIter.Stop()
+
+	var parents []string
+	for {
+		t, err := filteredIter.Next(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrIteratorDone) {
+				break
+			}
+			return planResult{}, err
+		}
+
+		parent, _ := tuple.SplitObjectRelation(t.GetUser())
+		parents = append(parents, parent)
+	}
+
+	var result planResult
+	var unionErr error
+	for _, parent := range parents {
+		plan, ok := n.parentPlans[tuple.GetType(parent)]
+		if !ok {
+			continue
+		}
+
+		childResult, err := e.evaluate(ctx, plan, parent, user, depth-1)
+		if err != nil {
+			unionErr = err
+			continue
+		}
+		if childResult.allowed {
+			return childResult, nil
+		}
+		result.cycleDetected = result.cycleDetected || childResult.cycleDetected
+	}
+	if unionErr != nil {
+		return planResult{}, unionErr
+	}
+	return result, nil
+}
+
+// unionNode resolves its children like union, but sequentially.
+type unionNode struct {
+	children []planNode
+}
+
+func (n *unionNode) execute(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error) {
+	var result planResult
+	var unionErr error
+	for _, child := range n.children {
+		childResult, err := child.execute(ctx, e, object, user, depth)
+		if err != nil {
+			unionErr = err
+			continue
+		}
+		if childResult.allowed {
+			return childResult, nil
+		}
+		result.cycleDetected = result.cycleDetected || childResult.cycleDetected
+	}
+	if unionErr != nil {
+		return planResult{}, unionErr
+	}
+	return result, nil
+}
+
+// intersectionNode resolves its children like intersection, but sequentially.
+type intersectionNode struct {
+	children []planNode
+}
+
+func (n *intersectionNode) execute(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error) {
+	var intersectionErr error
+	for _, child := range n.children {
+		childResult, err := child.execute(ctx, e, object, user, depth)
+		if err != nil {
+			intersectionErr = errors.Join(intersectionErr, err)
+			continue
+		}
+		if childResult.cycleDetected || !childResult.allowed {
+			return childResult, nil
+		}
+	}
+	if intersectionErr != nil {
+		return planResult{}, intersectionErr
+	}
+	return planResult{allowed: true}, nil
+}
+
+// exclusionNode resolves its base and subtract like exclusion, but sequentially.
+type exclusionNode struct {
+	base     planNode
+	subtract planNode
+}
+
+func (n *exclusionNode) execute(ctx context.Context, e *planExecution, object, user string, depth uint32) (planResult, error) {
+	baseResult, baseErr := n.base.execute(ctx, e, object, user, depth)
+	if baseErr == nil {
+		if baseResult.cycleDetected {
+			return planResult{cycleDetected: true}, nil
+		}
+		if !baseResult.allowed {
+			return planResult{}, nil
+		}
+	}
+
+	subtractResult, subtractErr := n.subtract.execute(ctx, e, object, user, depth)
+	if subtractErr == nil {
+		if subtractResult.cycleDetected {
+			return planResult{cycleDetected: true}, nil
+		}
+		if subtractResult.allowed {
+			return planResult{}, nil
+		}
+	}
+
+	if baseErr != nil || subtractErr != nil {
+		return planResult{}, errors.Join(baseErr, subtractErr)
+	}
+	return planResult{allowed: true}, nil
+}
@@ -0,0 +1,243 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestExecutePlanMatchesStandardCheck(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, user:*, group#member]
+		type folder
+			relations
+				define parent: [folder]
+				define viewer: [user, group#member] or viewer from parent
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define owner: [user]
+				define editor: [user, group#member, user with in_office_hours]
+				define viewer: [user] or editor or viewer from parent
+				define restricted: viewer but not blocked
+				define owning_editor: editor and owner
+
+		condition in_office_hours(hour: int) {
+			hour >= 9 && hour < 17
+		}`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("group:all", "member", "group:eng#member"),
+		tuple.NewTupleKey("group:public", "member", "user:*"),
+		// a cycle
+		tuple.NewTupleKey("group:a", "member", "group:b#member"),
+		tuple.NewTupleKey("group:b", "member", "group:a#member"),
+		tuple.NewTupleKey("folder:root", "viewer", "group:all#member"),
+		tuple.NewTupleKey("folder:x", "parent", "folder:root"),
+		tuple.NewTupleKey("folder:y", "viewer", "group:a#member"),
+		tuple.NewTupleKey("document:1", "parent", "folder:x"),
+		tuple.NewTupleKey("document:2", "parent", "folder:y"),
+		tuple.NewTupleKey("document:2", "editor", "group:public#member"),
+		tuple.NewTupleKey("document:3", "editor", "group:b#member"),
+		tuple.NewTupleKeyWithCondition("document:3", "editor", "user:bob", "in_office_hours", nil),
+		tuple.NewTupleKey("document:3", "owner", "user:bob"),
+		tuple.NewTupleKey("document:1", "blocked", "user:anne"),
+	}))
+
+	checker := NewLocalChecker()
+	t.Cleanup(checker.Close)
+
+	ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+	ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+
+	allowedCount := 0
+	for _, relation := range []string{"editor", "viewer", "restricted", "owning_editor"} {
+		plan, err := CompileCheckPlan(typesys, "document", relation)
+		require.NoError(t, err)
+
+		for _, object := range []string{"document:1", "document:2", "document:3"} {
+			for _, user := range []string{"user:anne", "user:bob", "user:carl", "group:eng#member"} {
+				for _, hour := range []int{10, 20} {
+					reqContext := testutils.MustNewStruct(t, map[string]interface{}{"hour": hour})
+
+					t.Run(fmt.Sprintf("%s#%s@%s_at_%d", object, relation, user, hour), func(t *testing.T) {
+						resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+							StoreID:              storeID,
+							AuthorizationModelID: model.GetId(),
+							TupleKey:             tuple.NewTupleKey(object, relation, user),
+							Context:              reqContext,
+							RequestMetadata:      NewCheckRequestMetadata(defaultResolveNodeLimit),
+						})
+						require.NoError(t, err)
+
+						allowed, err := ExecutePlan(ctx, plan, storeID, user, object, reqContext)
+						require.NoError(t, err)
+						require.Equal(t, resp.GetAllowed(), allowed)
+						if allowed {
+							allowedCount++
+						}
+					})
+				}
+			}
+		}
+	}
+
+	// both outcomes are covered by the matrix
+	require.Positive(t, allowedCount)
+	require.Less(t, allowedCount, 4*3*4*2)
+}
+
+func TestExecutePlanErrors(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:0", "member", "group:1#member"),
+		tuple.NewTupleKey("group:1", "member", "group:2#member"),
+		tuple.NewTupleKey("group:2", "member", "user:anne"),
+	}))
+
+	ctx := storage.ContextWithRelationshipTupleReader(context.Background(), ds)
+
+	t.Run("undefined_relation", func(t *testing.T) {
+		_, err := CompileCheckPlan(typesys, "group", "owner")
+		require.ErrorIs(t, err, typesystem.ErrRelationUndefined)
+	})
+
+	t.Run("object_of_another_type", func(t *testing.T) {
+		plan, err := CompileCheckPlan(typesys, "group", "member")
+		require.NoError(t, err)
+
+		_, err = ExecutePlan(ctx, plan, storeID, "user:anne", "user:bob", nil)
+		require.ErrorContains(t, err, "type 'group'")
+	})
+
+	t.Run("resolution_depth_exceeded", func(t *testing.T) {
+		plan, err := CompileCheckPlan(typesys, "group", "member", WithCheckPlanResolveNodeLimit(2))
+		require.NoError(t, err)
+
+		_, err = ExecutePlan(ctx, plan, storeID, "user:anne", "group:0", nil)
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+
+		allowed, err := ExecutePlan(ctx, plan, storeID, "user:anne", "group:1", nil)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("missing_tuple_reader", func(t *testing.T) {
+		plan, err := CompileCheckPlan(typesys, "group", "member")
+		require.NoError(t, err)
+
+		_, err = ExecutePlan(context.Background(), plan, storeID, "user:anne", "group:0", nil)
+		require.Error(t, err)
+	})
+}
+
+// BenchmarkExecutePlan compares the resolution of the same shape of Check, i.e. one relation across many
+// objects for one user, with the standard resolution and with a compiled plan.
+func BenchmarkExecutePlan(b *testing.B) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]
+		type folder
+			relations
+				define viewer: [user, group#member]
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define editor: [user, group#member]
+				define viewer: ([user] or editor or viewer from parent) but not blocked`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(b, err)
+
+	const numDocuments = 100
+
+	storeID := ulid.Make().String()
+	ds := memory.New(memory.WithMaxTuplesPerWrite(3 * numDocuments))
+	b.Cleanup(ds.Close)
+	tuples := []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("folder:shared", "viewer", "group:eng#member"),
+	}
+	for i := 0; i < numDocuments; i++ {
+		tuples = append(tuples,
+			tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "parent", "folder:shared"),
+			tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "editor", "user:bob"),
+		)
+	}
+	require.NoError(b, ds.Write(context.Background(), storeID, nil, tuples))
+
+	ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+	ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+	reqContext := &structpb.Struct{}
+
+	b.Run("standard", func(b *testing.B) {
+		checker := NewLocalChecker()
+		b.Cleanup(checker.Close)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey(fmt.Sprintf("document:%d", i%numDocuments), "viewer", "user:anne"),
+				Context:              reqContext,
+				RequestMetadata:      NewCheckRequestMetadata(defaultResolveNodeLimit),
+			})
+			require.NoError(b, err)
+			require.True(b, resp.GetAllowed())
+		}
+	})
+
+	b.Run("compiled_plan", func(b *testing.B) {
+		plan, err := CompileCheckPlan(typesys, "document", "viewer")
+		require.NoError(b, err)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			allowed, err := ExecutePlan(ctx, plan, storeID, "user:anne", fmt.Sprintf("document:%d", i%numDocuments), reqContext)
+			require.NoError(b, err)
+			require.True(b, allowed)
+		}
+	})
+}
@@ -0,0 +1,585 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestIntersectionShortCircuitsOnFalseOperand(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	slow := func(ctx context.Context) (*ResolveCheckResponse, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			close(canceled)
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Second):
+			return &ResolveCheckResponse{Allowed: true, ResolutionMetadata: &ResolveCheckResponseMetadata{}}, nil
+		}
+	}
+	fastFalse := func(ctx context.Context) (*ResolveCheckResponse, error) {
+		// the slow operand must be in flight for its cancellation to be observed
+		<-started
+		return &ResolveCheckResponse{Allowed: false, ResolutionMetadata: &ResolveCheckResponseMetadata{}}, nil
+	}
+
+	start := time.Now()
+	resp, err := intersection(context.Background(), 2, slow, fastFalse)
+	require.NoError(t, err)
+	require.False(t, resp.GetAllowed())
+	require.Less(t, time.Since(start), time.Second)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		require.Fail(t, "the slow operand was not canceled")
+	}
+}
+
+func TestCheckDirectlyGrantingContextualTuple(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define blocked: [user]
+				define editor: [user]
+				define viewer: [user] or editor
+				define restricted_viewer: [user] but not blocked`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	newRequest := func(relation string) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:              ulid.Make().String(),
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", relation, "user:anne"),
+			ContextualTuples:     []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", relation, "user:anne")},
+			RequestMetadata:      NewCheckRequestMetadata(25),
+		}
+	}
+
+	t.Run("answers_without_reading_tuples", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		// any read of the datastore fails the test
+		ds := mocks.NewMockRelationshipTupleReader(ctrl)
+		req := newRequest("viewer")
+
+		ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, storagewrappers.NewCombinedTupleReader(ds, req.GetContextualTuples()))
+
+		resp, err := NewLocalChecker().ResolveCheck(ctx, req)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.Zero(t, resp.GetResolutionMetadata().DatastoreQueryCount)
+	})
+
+	t.Run("exclusion_is_resolved", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		req := newRequest("restricted_viewer")
+		require.NoError(t, ds.Write(context.Background(), req.GetStoreID(), nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "blocked", "user:anne"),
+		}))
+
+		ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, storagewrappers.NewCombinedTupleReader(ds, req.GetContextualTuples()))
+
+		resp, err := NewLocalChecker().ResolveCheck(ctx, req)
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+}
+
+func TestCheckWithDisabledFastPathMatchesFastPath(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type team
+			relations
+				define member: [user]
+		type group
+			relations
+				define member: [user, group#member]
+		type folder
+			relations
+				define viewer: [user, team#member, group#member]
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define owner: [user]
+				define editor: [user, team#member, group#member]
+				define viewer: [user] or editor
+				define parent_viewer: viewer from parent
+				define restricted: [user] but not blocked
+				define owning_editor: editor and owner`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("team:a", "member", "user:bob"),
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("group:all", "member", "group:eng#member"),
+		tuple.NewTupleKey("folder:x", "viewer", "user:carl"),
+		tuple.NewTupleKey("folder:y", "viewer", "team:a#member"),
+		tuple.NewTupleKey("folder:z", "viewer", "group:all#member"),
+		tuple.NewTupleKey("document:1", "parent", "folder:x"),
+		tuple.NewTupleKey("document:2", "parent", "folder:y"),
+		tuple.NewTupleKey("document:3", "parent", "folder:z"),
+		tuple.NewTupleKey("document:1", "editor", "team:a#member"),
+		tuple.NewTupleKey("document:2", "editor", "group:all#member"),
+		tuple.NewTupleKey("document:1", "owner", "user:bob"),
+		tuple.NewTupleKey("document:1", "blocked", "user:dan"),
+		tuple.NewTupleKey("document:1", "restricted", "user:dan"),
+		tuple.NewTupleKey("document:3", "viewer", "user:erin"),
+	}))
+
+	checker := NewLocalChecker(WithOptimizations(true))
+	t.Cleanup(checker.Close)
+
+	resolve := func(tk *openfgav1.TupleKey, contextualTuples []*openfgav1.TupleKey, disableFastPath bool) bool {
+		ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, storagewrappers.NewCombinedTupleReader(ds, contextualTuples))
+
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tk,
+			ContextualTuples:     contextualTuples,
+			RequestMetadata:      NewCheckRequestMetadata(25),
+			DisableFastPath:      disableFastPath,
+		})
+		require.NoError(t, err)
+		return resp.GetAllowed()
+	}
+
+	allowedCount := 0
+	for _, object := range []string{"document:1", "document:2", "document:3"} {
+		for _, relation := range []string{"editor", "viewer", "parent_viewer", "restricted", "owning_editor"} {
+			for _, user := range []string{"user:anne", "user:bob", "user:carl", "user:dan", "user:erin"} {
+				tk := tuple.NewTupleKey(object, relation, user)
+
+				for _, contextualTuples := range [][]*openfgav1.TupleKey{nil, {tk}} {
+					name := tuple.TupleKeyToString(tk)
+					if len(contextualTuples) > 0 {
+						name += "_with_contextual_tuple"
+					}
+
+					t.Run(name, func(t *testing.T) {
+						allowed := resolve(tk, contextualTuples, false)
+						require.Equal(t, allowed, resolve(tk, contextualTuples, true))
+						if allowed {
+							allowedCount++
+						}
+					})
+				}
+			}
+		}
+	}
+
+	// both outcomes are covered by the matrix
+	require.Positive(t, allowedCount)
+	require.Less(t, allowedCount, 3*5*5*2)
+}
+
+func TestCheckResolvesContextualTupleChainsLikeStoredTupleChains(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]
+		type folder
+			relations
+				define parent: [folder]
+				define viewer: [user] or viewer from parent`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	const depth = 25
+
+	// groupChain returns the tuples of group:0#member@group:1#member ... group:n#member@user:anne
+	groupChain := func(n int) []*openfgav1.TupleKey {
+		var tks []*openfgav1.TupleKey
+		for i := 0; i < n; i++ {
+			tks = append(tks, tuple.NewTupleKey(fmt.Sprintf("group:%d", i), "member", fmt.Sprintf("group:%d#member", i+1)))
+		}
+		return append(tks, tuple.NewTupleKey(fmt.Sprintf("group:%d", n), "member", "user:anne"))
+	}
+	// folderChain returns the tuples of folder:0#parent@folder:1 ... folder:n#viewer@user:anne
+	folderChain := func(n int) []*openfgav1.TupleKey {
+		var tks []*openfgav1.TupleKey
+		for i := 0; i < n; i++ {
+			tks = append(tks, tuple.NewTupleKey(fmt.Sprintf("folder:%d", i), "parent", fmt.Sprintf("folder:%d", i+1)))
+		}
+		return append(tks, tuple.NewTupleKey(fmt.Sprintf("folder:%d", n), "viewer", "user:anne"))
+	}
+	groupCycle := []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:0", "member", "group:1#member"),
+		tuple.NewTupleKey("group:1", "member", "group:2#member"),
+		tuple.NewTupleKey("group:2", "member", "group:0#member"),
+	}
+
+	tests := []struct {
+		name          string
+		tuples        []*openfgav1.TupleKey
+		tupleKey      *openfgav1.TupleKey
+		expectAllowed bool
+		expectCycle   bool
+		expectErr     error
+	}{
+		{
+			name:        "cycle",
+			tuples:      groupCycle,
+			tupleKey:    tuple.NewTupleKey("group:0", "member", "user:anne"),
+			expectCycle: true,
+		},
+		{
+			name:          "cycle_with_a_path_to_the_user",
+			tuples:        append(slices.Clone(groupCycle), tuple.NewTupleKey("group:2", "member", "user:anne")),
+			tupleKey:      tuple.NewTupleKey("group:0", "member", "user:anne"),
+			expectAllowed: true,
+		},
+		{
+			name:          "userset_chain_within_the_depth",
+			tuples:        groupChain(10),
+			tupleKey:      tuple.NewTupleKey("group:0", "member", "user:anne"),
+			expectAllowed: true,
+		},
+		{
+			name:      "userset_chain_deeper_than_the_depth",
+			tuples:    groupChain(depth + 5),
+			tupleKey:  tuple.NewTupleKey("group:0", "member", "user:anne"),
+			expectErr: ErrResolutionDepthExceeded,
+		},
+		{
+			name:          "ttu_chain_within_the_depth",
+			tuples:        folderChain(10),
+			tupleKey:      tuple.NewTupleKey("folder:0", "viewer", "user:anne"),
+			expectAllowed: true,
+		},
+		{
+			name:      "ttu_chain_deeper_than_the_depth",
+			tuples:    folderChain(depth + 5),
+			tupleKey:  tuple.NewTupleKey("folder:0", "viewer", "user:anne"),
+			expectErr: ErrResolutionDepthExceeded,
+		},
+	}
+
+	// splits return the tuples that are contextual, and the ones that are stored
+	splits := map[string]func(tks []*openfgav1.TupleKey) ([]*openfgav1.TupleKey, []*openfgav1.TupleKey){
+		"stored": func(tks []*openfgav1.TupleKey) ([]*openfgav1.TupleKey, []*openfgav1.TupleKey) {
+			return nil, tks
+		},
+		"contextual": func(tks []*openfgav1.TupleKey) ([]*openfgav1.TupleKey, []*openfgav1.TupleKey) {
+			return tks, nil
+		},
+		"mixed": func(tks []*openfgav1.TupleKey) ([]*openfgav1.TupleKey, []*openfgav1.TupleKey) {
+			var contextual, stored []*openfgav1.TupleKey
+			for i, tk := range tks {
+				if i%2 == 0 {
+					contextual = append(contextual, tk)
+				} else {
+					stored = append(stored, tk)
+				}
+			}
+			return contextual, stored
+		},
+	}
+
+	for _, test := range tests {
+		for splitName, split := range splits {
+			for _, optimizations := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%s_%s_optimizations_%t", test.name, splitName, optimizations), func(t *testing.T) {
+					contextualTuples, storedTuples := split(test.tuples)
+
+					storeID := ulid.Make().String()
+					ds := memory.New(memory.WithMaxTuplesPerWrite(len(test.tuples)))
+					t.Cleanup(ds.Close)
+					if len(storedTuples) > 0 {
+						require.NoError(t, ds.Write(context.Background(), storeID, nil, storedTuples))
+					}
+
+					checker := NewLocalChecker(WithOptimizations(optimizations))
+					t.Cleanup(checker.Close)
+
+					ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+					ctx = storage.ContextWithRelationshipTupleReader(ctx, storagewrappers.NewCombinedTupleReader(ds, contextualTuples))
+
+					resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+						StoreID:              storeID,
+						AuthorizationModelID: model.GetId(),
+						TupleKey:             test.tupleKey,
+						ContextualTuples:     contextualTuples,
+						RequestMetadata:      NewCheckRequestMetadata(depth),
+					})
+					if test.expectErr != nil {
+						require.ErrorIs(t, err, test.expectErr)
+						return
+					}
+					require.NoError(t, err)
+					require.Equal(t, test.expectAllowed, resp.GetAllowed())
+					require.Equal(t, test.expectCycle, resp.GetCycleDetected())
+				})
+			}
+		}
+	}
+}
+
+// wideModel returns a model of numTypes types chained by their parent, the last one having the first one as
+// parent, so that a Check of a viewer visits all the types.
+func wideModel(numTypes int) *openfgav1.AuthorizationModel {
+	var sb strings.Builder
+	sb.WriteString("model\n  schema 1.1\ntype user\n")
+	for i := 0; i < numTypes; i++ {
+		fmt.Fprintf(&sb, "type t%d\n  relations\n    define parent: [t%d]\n    define viewer: [user] or viewer from parent\n", i, (i+1)%numTypes)
+	}
+	return testutils.MustTransformDSLToProtoWithID(sb.String())
+}
+
+func TestCheckDetectsCyclesAcrossTypes(t *testing.T) {
+	const numTypes = 5
+
+	model := wideModel(numTypes)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	var typeCycle []*openfgav1.TupleKey
+	for i := 0; i < numTypes; i++ {
+		typeCycle = append(typeCycle, tuple.NewTupleKey(fmt.Sprintf("t%d:a", i), "parent", fmt.Sprintf("t%d:a", (i+1)%numTypes)))
+	}
+
+	tests := []struct {
+		name          string
+		tuples        []*openfgav1.TupleKey
+		expectAllowed bool
+		expectCycle   bool
+	}{
+		{
+			name:        "cycle",
+			tuples:      typeCycle,
+			expectCycle: true,
+		},
+		{
+			name:          "cycle_with_a_path_to_the_user",
+			tuples:        append(slices.Clone(typeCycle), tuple.NewTupleKey(fmt.Sprintf("t%d:a", numTypes-1), "viewer", "user:anne")),
+			expectAllowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storeID := ulid.Make().String()
+			ds := memory.New()
+			t.Cleanup(ds.Close)
+			require.NoError(t, ds.Write(context.Background(), storeID, nil, test.tuples))
+
+			checker := NewLocalChecker()
+			t.Cleanup(checker.Close)
+
+			ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+			ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+
+			resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey("t0:a", "viewer", "user:anne"),
+				RequestMetadata:      NewCheckRequestMetadata(defaultResolveNodeLimit),
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.expectAllowed, resp.GetAllowed())
+			require.Equal(t, test.expectCycle, resp.GetCycleDetected())
+		})
+	}
+}
+
+func TestHasCycleDoesNotShareVisitsBetweenClones(t *testing.T) {
+	checker := NewLocalChecker()
+	t.Cleanup(checker.Close)
+
+	parent := &ResolveCheckRequest{
+		TupleKey:        tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		RequestMetadata: NewCheckRequestMetadata(defaultResolveNodeLimit),
+	}
+	require.False(t, checker.hasCycle(parent))
+	require.True(t, checker.hasCycle(clone(parent)))
+
+	child := clone(parent)
+	child.TupleKey = tuple.NewTupleKey("document:2", "viewer", "user:anne")
+	require.False(t, checker.hasCycle(child))
+
+	// the visit of the child is only on its own path
+	sibling := clone(parent)
+	sibling.TupleKey = tuple.NewTupleKey("document:2", "viewer", "user:anne")
+	require.False(t, checker.hasCycle(sibling))
+
+	grandchild := clone(child)
+	grandchild.TupleKey = tuple.NewTupleKey("document:1", "viewer", "user:anne")
+	require.True(t, checker.hasCycle(grandchild))
+}
+
+// BenchmarkCheckWideModel measures a Check whose path visits many types.
+func BenchmarkCheckWideModel(b *testing.B) {
+	const numTypes = 50
+
+	model := wideModel(numTypes)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(b, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	b.Cleanup(ds.Close)
+	var tks []*openfgav1.TupleKey
+	for i := 0; i < numTypes-1; i++ {
+		tks = append(tks, tuple.NewTupleKey(fmt.Sprintf("t%d:a", i), "parent", fmt.Sprintf("t%d:a", i+1)))
+	}
+	tks = append(tks, tuple.NewTupleKey(fmt.Sprintf("t%d:a", numTypes-1), "viewer", "user:anne"))
+	require.NoError(b, ds.Write(context.Background(), storeID, nil, tks))
+
+	checker := NewLocalChecker()
+	b.Cleanup(checker.Close)
+
+	ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+	ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("t0:a", "viewer", "user:anne"),
+			RequestMetadata:      NewCheckRequestMetadata(numTypes + 10),
+		})
+		require.NoError(b, err)
+		require.True(b, resp.GetAllowed())
+	}
+}
+
+func TestCheckWithSelfRelations(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type bot
+		type group
+			relations
+				define member: [user]
+		type profile
+			relations
+				define blocked: [user]
+				define viewer: [user, user:*, group#member]
+				define can_view: viewer
+				define can_comment: viewer but not blocked
+		type post
+			relations
+				define profile: [profile]
+				define viewer: viewer from profile
+				define editor: [user, group#member]`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("profile:bob", "viewer", "user:carl"),
+		tuple.NewTupleKey("profile:erin", "blocked", "user:erin"),
+		tuple.NewTupleKey("post:1", "profile", "profile:anne"),
+		tuple.NewTupleKey("post:2", "profile", "profile:bob"),
+	}))
+
+	selfRelations := []SelfRelation{
+		{ObjectType: "profile", Relation: "viewer", UserType: "user"},
+		{ObjectType: "post", Relation: "editor", UserType: "user"},
+	}
+
+	tests := []struct {
+		tk      *openfgav1.TupleKey
+		allowed bool
+	}{
+		// the user whose ID is the ID of the object
+		{tk: tuple.NewTupleKey("profile:anne", "viewer", "user:anne"), allowed: true},
+		{tk: tuple.NewTupleKey("post:anne", "editor", "user:anne"), allowed: true},
+		// the other users, unless they have a tuple
+		{tk: tuple.NewTupleKey("profile:anne", "viewer", "user:bob"), allowed: false},
+		{tk: tuple.NewTupleKey("profile:bob", "viewer", "user:carl"), allowed: true},
+		{tk: tuple.NewTupleKey("post:1", "editor", "user:anne"), allowed: false},
+		// the users of other types with the same ID
+		{tk: tuple.NewTupleKey("profile:anne", "viewer", "bot:anne"), allowed: false},
+		// the relations that aren't self relations
+		{tk: tuple.NewTupleKey("profile:anne", "blocked", "user:anne"), allowed: false},
+		// the relations computed from a self relation
+		{tk: tuple.NewTupleKey("profile:anne", "can_view", "user:anne"), allowed: true},
+		{tk: tuple.NewTupleKey("profile:anne", "can_view", "user:bob"), allowed: false},
+		{tk: tuple.NewTupleKey("profile:anne", "can_comment", "user:anne"), allowed: true},
+		{tk: tuple.NewTupleKey("profile:erin", "can_comment", "user:erin"), allowed: false},
+		{tk: tuple.NewTupleKey("post:1", "viewer", "user:anne"), allowed: true},
+		{tk: tuple.NewTupleKey("post:1", "viewer", "user:bob"), allowed: false},
+		{tk: tuple.NewTupleKey("post:2", "viewer", "user:bob"), allowed: true},
+		{tk: tuple.NewTupleKey("post:2", "viewer", "user:anne"), allowed: false},
+	}
+
+	for _, optimizations := range []bool{false, true} {
+		checker := NewLocalChecker(WithOptimizations(optimizations), WithSelfRelations(selfRelations...))
+		t.Cleanup(checker.Close)
+
+		ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s_optimizations_%t", tuple.TupleKeyToString(test.tk), optimizations), func(t *testing.T) {
+				resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+					StoreID:              storeID,
+					AuthorizationModelID: model.GetId(),
+					TupleKey:             test.tk,
+					RequestMetadata:      NewCheckRequestMetadata(25),
+				})
+				require.NoError(t, err)
+				require.Equal(t, test.allowed, resp.GetAllowed())
+			})
+		}
+	}
+}
+
+func TestParseSelfRelation(t *testing.T) {
+	relation, err := ParseSelfRelation("profile#viewer@user")
+	require.NoError(t, err)
+	require.Equal(t, SelfRelation{ObjectType: "profile", Relation: "viewer", UserType: "user"}, relation)
+	require.Equal(t, "profile#viewer@user", relation.String())
+
+	for _, invalid := range []string{"", "profile", "profile#viewer", "profile@user", "#viewer@user", "profile#@user", "profile#viewer@", "profile#viewer@user#member", "profile:1#viewer@user"} {
+		t.Run(invalid, func(t *testing.T) {
+			_, err := ParseSelfRelation(invalid)
+			require.ErrorContains(t, err, "must be of the form 'objectType#relation@userType'")
+		})
+	}
+}
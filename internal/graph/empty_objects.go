@@ -0,0 +1,149 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var emptyObjectSkippedCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: build.ProjectName,
+	Name:      "check_empty_object_skipped_count",
+	Help:      "The total number of Checks of the objects of tuple to usersets that were skipped because the objects have no tuples.",
+})
+
+// WithEmptyObjectCache makes the LocalChecker remember, for the ttl, whether the objects that a tuple to userset
+// leads to, e.g. the folders of 'viewer from parent', have any tuple, in a cache of at most maxSize objects. The
+// Checks of an object that had no tuples when it was last read are skipped as not allowed, without reading,
+// since an object without tuples has no relation with any user. An object that is not cached yet is read once
+// to learn it.
+//
+// An object that gets its first tuples may still be skipped until its entry expires, so, like the Check cache,
+// this makes Check eventually consistent. The cache isn't used for the requests with higher consistency, for
+// the objects of their contextual tuples, nor when the Checks of objects without tuples can be allowed, i.e.
+// with assumptions, self relations or known results.
+func WithEmptyObjectCache(ttl time.Duration, maxSize int64) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		if ttl <= 0 || maxSize <= 0 {
+			return
+		}
+		d.emptyObjectCacheTTL = ttl
+		d.emptyObjectCache = storage.NewInMemoryLRUCache[bool](storage.WithMaxCacheSize[bool](maxSize))
+	}
+}
+
+// WithEmptyObjectCacheWritesPaused doesn't cache whether the objects read while paused returns true have any
+// tuple, e.g. while they are read from a standby datastore that may be stale. See WithEmptyObjectCache.
+func WithEmptyObjectCacheWritesPaused(paused func() bool) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.emptyObjectCacheWritesPaused = paused
+	}
+}
+
+// canUseEmptyObjectCache returns whether the Checks of the objects without tuples can be skipped for the
+// request, see WithEmptyObjectCache.
+func (c *LocalChecker) canUseEmptyObjectCache(ctx context.Context, req *ResolveCheckRequest) bool {
+	if c.emptyObjectCache == nil || len(c.assumptions) > 0 || len(c.selfRelations) > 0 {
+		return false
+	}
+
+	if req.GetConsistency() == openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY ||
+		req.GetDisableFastPath() || req.GetBypassCache() || len(req.GetKnownResults()) > 0 {
+		return false
+	}
+
+	// the entries are shared with the reads served by the primary datastore
+	if storage.ShadowReadsFromContext(ctx) {
+		return false
+	}
+
+	// the entries may predate the changes the request must observe
+	_, ok := storage.MinChangelogTokenFromContext(ctx)
+	return !ok
+}
+
+func emptyObjectCacheKey(storeID, object string) string {
+	return storeID + "/" + object
+}
+
+// dispatchUnlessEmpty dispatches the Check of tk, unless its object has no tuples, in which case it's not
+// allowed. The datastore query that learns whether the object has tuples, if any, is counted in the response.
+func (c *LocalChecker) dispatchUnlessEmpty(ctx context.Context, req *ResolveCheckRequest, tk *openfgav1.TupleKey) CheckHandlerFunc {
+	dispatch := c.dispatch(ctx, req, tk)
+
+	object := tk.GetObject()
+	for _, contextualTuple := range req.GetContextualTuples() {
+		if contextualTuple.GetObject() == object {
+			return dispatch
+		}
+	}
+
+	return func(ctx context.Context) (*ResolveCheckResponse, error) {
+		key := emptyObjectCacheKey(req.GetStoreID(), object)
+
+		var queryCount uint32
+		hasTuples := true
+		if cached := c.emptyObjectCache.Get(key); cached != nil && !cached.Expired {
+			hasTuples = cached.Value
+		} else {
+			var err error
+			hasTuples, err = c.objectHasTuples(ctx, req, object)
+			if err != nil {
+				return nil, err
+			}
+			queryCount++
+			if c.emptyObjectCacheWritesPaused == nil || !c.emptyObjectCacheWritesPaused() {
+				c.emptyObjectCache.Set(key, hasTuples, c.emptyObjectCacheTTL)
+			}
+		}
+
+		if !hasTuples {
+			emptyObjectSkippedCounter.Inc()
+			return &ResolveCheckResponse{
+				Allowed: false,
+				ResolutionMetadata: &ResolveCheckResponseMetadata{
+					DatastoreQueryCount: queryCount,
+				},
+			}, nil
+		}
+
+		resp, err := dispatch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		resp.GetResolutionMetadata().DatastoreQueryCount += queryCount
+		return resp, nil
+	}
+}
+
+// objectHasTuples reads whether the object has any tuple.
+func (c *LocalChecker) objectHasTuples(ctx context.Context, req *ResolveCheckRequest, object string) (bool, error) {
+	ds, _ := storage.RelationshipTupleReaderFromContext(ctx)
+
+	iter, err := ds.Read(ctx, req.GetStoreID(), tuple.NewTupleKey(object, "", ""), storage.ReadOptions{
+		Consistency: storage.ConsistencyOptions{
+			Preference: req.GetConsistency(),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	defer iter.Stop()
+
+	_, err = iter.Next(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrIteratorDone) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
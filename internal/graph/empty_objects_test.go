@@ -0,0 +1,218 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+const emptyParentsModel = `
+	model
+		schema 1.1
+	type user
+	type folder
+		relations
+			define parent: [folder]
+			define viewer: [user] or viewer from parent
+	type document
+		relations
+			define parent: [folder]
+			define viewer: [user] or viewer from parent`
+
+// writeEmptyParents writes a document whose parents are numParents folders without tuples, and the folders
+// that grant user:granted.
+func writeEmptyParents(t testing.TB, ds storage.OpenFGADatastore, storeID string, numParents int) {
+	var tks []*openfgav1.TupleKey
+	for i := 0; i < numParents; i++ {
+		tks = append(tks, tuple.NewTupleKey("document:1", "parent", fmt.Sprintf("folder:empty-%d", i)))
+	}
+	tks = append(tks,
+		tuple.NewTupleKey("document:1", "parent", "folder:granting"),
+		tuple.NewTupleKey("folder:granting", "parent", "folder:root"),
+		tuple.NewTupleKey("folder:root", "viewer", "user:granted"),
+	)
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, tks))
+}
+
+func countQueries(shapes *storagewrappers.QueryShapes) int {
+	var total int
+	for _, count := range shapes.Counts() {
+		total += count
+	}
+	return total
+}
+
+func TestCheckSkipsEmptyObjects(t *testing.T) {
+	const numParents = 10
+
+	model := testutils.MustTransformDSLToProtoWithID(emptyParentsModel)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+	writeEmptyParents(t, ds, storeID, numParents)
+
+	check := func(t *testing.T, checker *LocalChecker, req *ResolveCheckRequest) (bool, int) {
+		shapes := storagewrappers.NewQueryShapes()
+		ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, storagewrappers.NewQueryShapeRecordingTupleReader(
+			storagewrappers.NewCombinedTupleReader(ds, req.GetContextualTuples()), shapes))
+
+		req.StoreID = storeID
+		req.AuthorizationModelID = model.GetId()
+		req.RequestMetadata = NewCheckRequestMetadata(25)
+		resp, err := checker.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+		return resp.GetAllowed(), countQueries(shapes)
+	}
+
+	denied := tuple.NewTupleKey("document:1", "viewer", "user:denied")
+	granted := tuple.NewTupleKey("document:1", "viewer", "user:granted")
+
+	t.Run("reads_less", func(t *testing.T) {
+		uncached := NewLocalChecker()
+		t.Cleanup(uncached.Close)
+		allowed, uncachedQueries := check(t, uncached, &ResolveCheckRequest{TupleKey: denied})
+		require.False(t, allowed)
+
+		checker := NewLocalChecker(WithEmptyObjectCache(time.Minute, 100))
+		t.Cleanup(checker.Close)
+
+		// the empty parents are read once to learn that they are empty, instead of once per relation
+		allowed, firstQueries := check(t, checker, &ResolveCheckRequest{TupleKey: denied})
+		require.False(t, allowed)
+		require.Less(t, firstQueries, uncachedQueries)
+
+		// then they aren't read anymore, whatever the user
+		allowed, cachedQueries := check(t, checker, &ResolveCheckRequest{TupleKey: tuple.NewTupleKey("document:1", "viewer", "user:other")})
+		require.False(t, allowed)
+		require.Equal(t, uncachedQueries-2*numParents, cachedQueries)
+
+		allowed, _ = check(t, checker, &ResolveCheckRequest{TupleKey: granted})
+		require.True(t, allowed)
+	})
+
+	t.Run("eventually_sees_new_tuples", func(t *testing.T) {
+		checker := NewLocalChecker(WithEmptyObjectCache(time.Minute, 100))
+		t.Cleanup(checker.Close)
+
+		storeID := ulid.Make().String()
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "parent", "folder:1"),
+		}))
+
+		shapes := storagewrappers.NewQueryShapes()
+		ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, storagewrappers.NewQueryShapeRecordingTupleReader(ds, shapes))
+		newRequest := func(consistency openfgav1.ConsistencyPreference) *ResolveCheckRequest {
+			return &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+				RequestMetadata:      NewCheckRequestMetadata(25),
+				Consistency:          consistency,
+			}
+		}
+
+		resp, err := checker.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_UNSPECIFIED))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("folder:1", "viewer", "user:anne"),
+		}))
+
+		// the folder is still known to be empty until its entry expires
+		resp, err = checker.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_UNSPECIFIED))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+
+		resp, err = checker.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("contextual_tuples_of_empty_objects", func(t *testing.T) {
+		checker := NewLocalChecker(WithEmptyObjectCache(time.Minute, 100))
+		t.Cleanup(checker.Close)
+
+		allowed, _ := check(t, checker, &ResolveCheckRequest{TupleKey: denied})
+		require.False(t, allowed)
+
+		allowed, _ = check(t, checker, &ResolveCheckRequest{
+			TupleKey:         denied,
+			ContextualTuples: []*openfgav1.TupleKey{tuple.NewTupleKey("folder:empty-0", "viewer", "user:denied")},
+		})
+		require.True(t, allowed)
+	})
+
+	t.Run("self_relations", func(t *testing.T) {
+		checker := NewLocalChecker(
+			WithEmptyObjectCache(time.Minute, 100),
+			WithSelfRelations(SelfRelation{ObjectType: "folder", Relation: "viewer", UserType: "user"}),
+		)
+		t.Cleanup(checker.Close)
+
+		allowed, _ := check(t, checker, &ResolveCheckRequest{TupleKey: tuple.NewTupleKey("document:1", "viewer", "user:empty-0")})
+		require.True(t, allowed)
+	})
+}
+
+// BenchmarkCheckEmptyParents measures a Check of a document with many parents without tuples.
+func BenchmarkCheckEmptyParents(b *testing.B) {
+	const numParents = 100
+
+	model := testutils.MustTransformDSLToProtoWithID(emptyParentsModel)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(b, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	b.Cleanup(ds.Close)
+	writeEmptyParents(b, ds, storeID, numParents)
+
+	for _, tc := range []struct {
+		name string
+		opts []LocalCheckerOption
+	}{
+		{name: "uncached"},
+		{name: "empty_object_cache", opts: []LocalCheckerOption{WithEmptyObjectCache(time.Minute, 1000)}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			checker := NewLocalChecker(tc.opts...)
+			b.Cleanup(checker.Close)
+
+			shapes := storagewrappers.NewQueryShapes()
+			ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+			ctx = storage.ContextWithRelationshipTupleReader(ctx, storagewrappers.NewQueryShapeRecordingTupleReader(ds, shapes))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+					StoreID:              storeID,
+					AuthorizationModelID: model.GetId(),
+					TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+					RequestMetadata:      NewCheckRequestMetadata(25),
+				})
+				require.NoError(b, err)
+				require.False(b, resp.GetAllowed())
+			}
+			b.ReportMetric(float64(countQueries(shapes))/float64(b.N), "reads/op")
+		})
+	}
+}
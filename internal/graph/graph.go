@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
@@ -22,8 +23,45 @@ const (
 
 var (
 	ErrResolutionDepthExceeded = errors.New("resolution depth exceeded")
+
+	// ErrMaxVisitedObjectsExceeded is wrapped by the MaxVisitedObjectsExceededError returned when a Check visits
+	// more distinct objects than allowed by WithMaxVisitedObjects.
+	ErrMaxVisitedObjectsExceeded = errors.New("maximum number of visited objects exceeded")
 )
 
+// MaxVisitedObjectsExceededError is returned when a Check visits more distinct objects than allowed by
+// WithMaxVisitedObjects.
+type MaxVisitedObjectsExceededError struct {
+	Limit   uint32
+	Visited uint32
+}
+
+func (e *MaxVisitedObjectsExceededError) Error() string {
+	return fmt.Sprintf("%s: visited %d distinct objects, more than the limit of %d", ErrMaxVisitedObjectsExceeded, e.Visited, e.Limit)
+}
+
+func (e *MaxVisitedObjectsExceededError) Unwrap() error {
+	return ErrMaxVisitedObjectsExceeded
+}
+
+// VisitedObjects is the set of the distinct objects visited by a Check and all its subproblems.
+// It is safe for concurrent use by multiple goroutines.
+type VisitedObjects struct {
+	mu      sync.Mutex
+	objects map[string]struct{}
+}
+
+// Visit adds the object to the set, and returns the number of distinct objects visited so far.
+func (v *VisitedObjects) Visit(object string) uint32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.objects == nil {
+		v.objects = map[string]struct{}{}
+	}
+	v.objects[object] = struct{}{}
+	return uint32(len(v.objects))
+}
+
 type findEdgeOption int
 
 const (
@@ -59,6 +97,10 @@ type ResolveCheckRequestMetadata struct {
 
 	// WasThrottled indicates whether the request was throttled
 	WasThrottled *atomic.Bool
+
+	// VisitedObjects is the address to the set of distinct objects visited to solve the root/parent problem,
+	// shared by all its subproblems. It is only written to if a limit is set with WithMaxVisitedObjects.
+	VisitedObjects *VisitedObjects
 }
 
 func NewCheckRequestMetadata(maxDepth uint32) *ResolveCheckRequestMetadata {
@@ -67,6 +109,7 @@ func NewCheckRequestMetadata(maxDepth uint32) *ResolveCheckRequestMetadata {
 		DatastoreQueryCount: 0,
 		DispatchCounter:     new(atomic.Uint32),
 		WasThrottled:        new(atomic.Bool),
+		VisitedObjects:      &VisitedObjects{},
 	}
 }
 
@@ -81,6 +124,10 @@ type ResolveCheckResponseMetadata struct {
 	// Indicates if the ResolveCheck subproblem that was evaluated involved
 	// a cycle in the evaluation.
 	CycleDetected bool
+
+	// Indicates if the result was served from the Check cache rather than
+	// resolved. It is informational only.
+	Cached bool
 }
 
 type RelationshipEdgeType int
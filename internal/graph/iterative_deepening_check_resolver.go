@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CheckResolutionStrategy defines how the Checks are resolved.
+type CheckResolutionStrategy string
+
+const (
+	// CheckResolutionStrategyRecursive resolves a Check in a single recursive resolution, up to the resolve node limit.
+	CheckResolutionStrategyRecursive CheckResolutionStrategy = "recursive"
+	// CheckResolutionStrategyIterativeDeepening resolves a Check with the IterativeDeepeningCheckResolver.
+	CheckResolutionStrategyIterativeDeepening CheckResolutionStrategy = "iterative-deepening"
+)
+
+// CheckResolutionStrategies are all the valid Check resolution strategies.
+var CheckResolutionStrategies = []CheckResolutionStrategy{
+	CheckResolutionStrategyRecursive,
+	CheckResolutionStrategyIterativeDeepening,
+}
+
+const defaultIterativeDeepeningInitialDepth = 4
+
+// IterativeDeepeningCheckResolver resolves a Check with increasing depth limits, starting from a small one and
+// doubling it every time the resolution exceeds it, up to the depth of the request. Most Checks are resolved
+// with a depth limit much lower than the resolve node limit, so the stacks of goroutines and the visited objects
+// of a resolution are bounded by the depth that the Check actually needs, at the cost of resolving again the
+// subproblems of the shallower attempts for the Checks that need a deeper resolution.
+//
+// The result is the same as a single resolution with the depth of the request: a resolution that doesn't exceed
+// its depth limit has explored all the paths that a deeper one would have explored, and the last attempt has the
+// depth of the request.
+//
+// It must be the entrypoint of the chain of resolvers, since the subproblems of a Check must not be deepened.
+type IterativeDeepeningCheckResolver struct {
+	delegate     CheckResolver
+	initialDepth uint32
+}
+
+var _ CheckResolver = (*IterativeDeepeningCheckResolver)(nil)
+
+type IterativeDeepeningCheckResolverOpt func(*IterativeDeepeningCheckResolver)
+
+// WithIterativeDeepeningInitialDepth sets the depth limit of the first resolution of a Check.
+func WithIterativeDeepeningInitialDepth(depth uint32) IterativeDeepeningCheckResolverOpt {
+	return func(r *IterativeDeepeningCheckResolver) {
+		r.initialDepth = depth
+	}
+}
+
+func NewIterativeDeepeningCheckResolver(opts ...IterativeDeepeningCheckResolverOpt) *IterativeDeepeningCheckResolver {
+	r := &IterativeDeepeningCheckResolver{
+		initialDepth: defaultIterativeDeepeningInitialDepth,
+	}
+	r.delegate = r
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *IterativeDeepeningCheckResolver) SetDelegate(delegate CheckResolver) {
+	r.delegate = delegate
+}
+
+func (r *IterativeDeepeningCheckResolver) GetDelegate() CheckResolver {
+	return r.delegate
+}
+
+func (r *IterativeDeepeningCheckResolver) Close() {}
+
+func (r *IterativeDeepeningCheckResolver) ResolveCheck(ctx context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+	maxDepth := req.GetRequestMetadata().Depth
+	depth := min(max(r.initialDepth, 1), maxDepth)
+
+	var datastoreQueryCount uint32
+	attempts := 0
+	for {
+		attempts++
+
+		attempt := clone(req)
+		attempt.GetRequestMetadata().Depth = depth
+		if attempt.GetRequestMetadata().VisitedObjects != nil {
+			// the objects visited by a shallower attempt are visited again by a deeper one
+			attempt.GetRequestMetadata().VisitedObjects = &VisitedObjects{}
+		}
+
+		resp, err := r.delegate.ResolveCheck(ctx, attempt)
+		if resp != nil {
+			datastoreQueryCount += resp.GetResolutionMetadata().DatastoreQueryCount
+		}
+		if errors.Is(err, ErrResolutionDepthExceeded) && depth < maxDepth {
+			depth = min(2*depth, maxDepth)
+			continue
+		}
+
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Int("iterative_deepening_attempts", attempts),
+			attribute.Int("iterative_deepening_depth", int(depth)),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp = CloneResolveCheckResponse(resp)
+		resp.GetResolutionMetadata().DatastoreQueryCount = datastoreQueryCount
+		return resp, nil
+	}
+}
@@ -0,0 +1,187 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestIterativeDeepeningMatchesRecursiveCheck(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, user:*, group#member]
+		type folder
+			relations
+				define parent: [folder]
+				define viewer: [user, group#member] or viewer from parent
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define owner: [user]
+				define editor: [user, group#member]
+				define viewer: [user] or editor or viewer from parent
+				define restricted: viewer but not blocked
+				define owning_editor: editor and owner`)
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	storeID := ulid.Make().String()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	tuples := []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("group:all", "member", "group:eng#member"),
+		tuple.NewTupleKey("group:public", "member", "user:*"),
+		// a cycle
+		tuple.NewTupleKey("group:a", "member", "group:b#member"),
+		tuple.NewTupleKey("group:b", "member", "group:a#member"),
+		tuple.NewTupleKey("folder:root", "viewer", "group:all#member"),
+		tuple.NewTupleKey("folder:x", "parent", "folder:root"),
+		tuple.NewTupleKey("folder:y", "viewer", "group:a#member"),
+		tuple.NewTupleKey("document:1", "parent", "folder:x"),
+		tuple.NewTupleKey("document:2", "parent", "folder:y"),
+		tuple.NewTupleKey("document:2", "editor", "group:public#member"),
+		tuple.NewTupleKey("document:3", "editor", "group:b#member"),
+		tuple.NewTupleKey("document:3", "owner", "user:bob"),
+		tuple.NewTupleKey("document:1", "blocked", "user:anne"),
+		// a chain of folders that needs a deeper resolution than the first attempts
+		tuple.NewTupleKey("document:deep", "parent", "folder:deep_0"),
+		tuple.NewTupleKey("folder:deep_10", "viewer", "user:anne"),
+		// a chain of folders that exceeds the resolve node limit
+		tuple.NewTupleKey("document:too_deep", "parent", "folder:too_deep_0"),
+		tuple.NewTupleKey(fmt.Sprintf("folder:too_deep_%d", defaultResolveNodeLimit), "viewer", "user:anne"),
+	}
+	for i := 0; i < 10; i++ {
+		tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("folder:deep_%d", i), "parent", fmt.Sprintf("folder:deep_%d", i+1)))
+	}
+	for i := 0; i < defaultResolveNodeLimit; i++ {
+		tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("folder:too_deep_%d", i), "parent", fmt.Sprintf("folder:too_deep_%d", i+1)))
+	}
+	for start := 0; start < len(tuples); start += ds.MaxTuplesPerWrite() {
+		end := min(start+ds.MaxTuplesPerWrite(), len(tuples))
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, tuples[start:end]))
+	}
+
+	recursive, recursiveCloser := NewOrderedCheckResolvers().Build()
+	t.Cleanup(recursiveCloser)
+	iterativeDeepening, iterativeDeepeningCloser := NewOrderedCheckResolvers(
+		WithIterativeDeepeningCheckResolverOpts(true, WithIterativeDeepeningInitialDepth(1)),
+	).Build()
+	t.Cleanup(iterativeDeepeningCloser)
+
+	ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+	ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+
+	check := func(resolver CheckResolver, object, relation, user string) (bool, error) {
+		resp, err := resolver.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey(object, relation, user),
+			RequestMetadata:      NewCheckRequestMetadata(defaultResolveNodeLimit),
+		})
+		return resp.GetAllowed(), err
+	}
+
+	allowedCount := 0
+	for _, relation := range []string{"editor", "viewer", "restricted", "owning_editor"} {
+		for _, object := range []string{"document:1", "document:2", "document:3", "document:deep"} {
+			for _, user := range []string{"user:anne", "user:bob", "user:carl", "group:eng#member"} {
+				t.Run(fmt.Sprintf("%s#%s@%s", object, relation, user), func(t *testing.T) {
+					expected, err := check(recursive, object, relation, user)
+					require.NoError(t, err)
+
+					allowed, err := check(iterativeDeepening, object, relation, user)
+					require.NoError(t, err)
+					require.Equal(t, expected, allowed)
+					if allowed {
+						allowedCount++
+					}
+				})
+			}
+		}
+	}
+
+	// both outcomes are covered by the matrix
+	require.Positive(t, allowedCount)
+	require.Less(t, allowedCount, 4*4*4)
+
+	t.Run("deep_chain_is_resolved", func(t *testing.T) {
+		allowed, err := check(iterativeDeepening, "document:deep", "viewer", "user:anne")
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("resolve_node_limit_exceeded", func(t *testing.T) {
+		_, err := check(recursive, "document:too_deep", "viewer", "user:anne")
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+
+		_, err = check(iterativeDeepening, "document:too_deep", "viewer", "user:anne")
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+	})
+}
+
+func TestIterativeDeepeningCheckResolverDepths(t *testing.T) {
+	var depths []uint32
+	delegate := &depthRecordingCheckResolver{
+		depths: &depths,
+		// the Check needs a depth of 10
+		requiredDepth: 10,
+	}
+
+	r := NewIterativeDeepeningCheckResolver(WithIterativeDeepeningInitialDepth(2))
+	r.SetDelegate(delegate)
+
+	resp, err := r.ResolveCheck(context.Background(), &ResolveCheckRequest{
+		RequestMetadata: NewCheckRequestMetadata(25),
+	})
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+	require.Equal(t, []uint32{2, 4, 8, 16}, depths)
+	// the reads of all the attempts are counted
+	require.Equal(t, uint32(4), resp.GetResolutionMetadata().DatastoreQueryCount)
+
+	depths = nil
+	_, err = r.ResolveCheck(context.Background(), &ResolveCheckRequest{
+		RequestMetadata: NewCheckRequestMetadata(6),
+	})
+	require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+	require.Equal(t, []uint32{2, 4, 6}, depths)
+}
+
+// depthRecordingCheckResolver records the depth of the requests, and exceeds it if it's lower than requiredDepth.
+type depthRecordingCheckResolver struct {
+	depths        *[]uint32
+	requiredDepth uint32
+}
+
+func (r *depthRecordingCheckResolver) ResolveCheck(_ context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+	*r.depths = append(*r.depths, req.GetRequestMetadata().Depth)
+	resp := &ResolveCheckResponse{ResolutionMetadata: &ResolveCheckResponseMetadata{DatastoreQueryCount: 1}}
+	if req.GetRequestMetadata().Depth < r.requiredDepth {
+		return resp, ErrResolutionDepthExceeded
+	}
+	resp.Allowed = true
+	return resp, nil
+}
+
+func (r *depthRecordingCheckResolver) Close() {}
+
+func (r *depthRecordingCheckResolver) SetDelegate(CheckResolver) {}
+
+func (r *depthRecordingCheckResolver) GetDelegate() CheckResolver { return nil }
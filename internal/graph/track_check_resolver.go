@@ -94,11 +94,10 @@ func NewTrackCheckResolver(opts ...TrackerCheckResolverOpt) *TrackerCheckResolve
 	t.delegate = t
 
 	if t.ctx == nil {
-		t.ctx, t.cancel = context.WithCancel(context.Background())
-	} else {
-		t.cancel = func() { // no op
-		}
+		t.ctx = context.Background()
 	}
+	// the flush stops either when the given context is done or when the resolver is closed
+	t.ctx, t.cancel = context.WithCancel(t.ctx)
 
 	t.launchFlush()
 
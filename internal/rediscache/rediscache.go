@@ -0,0 +1,189 @@
+// Package rediscache contains a Check cache backend that stores the entries in Redis, so that they are shared by
+// every OpenFGA instance.
+package rediscache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	defaultKeyPrefix = "openfga:"
+	defaultTimeout   = 100 * time.Millisecond
+
+	// observedGenerationsTTL bounds the time between the lookup of a key and the entry stored for it, i.e. the
+	// time to resolve the Check of a cache miss.
+	observedGenerationsTTL = time.Minute
+	maxObservedGenerations = 10000
+
+	// generationHeaderLength is the length of the generation stored before the value of each entry.
+	generationHeaderLength = 8
+)
+
+// Backend is a [graph.CheckCacheBackend] that stores the entries in Redis.
+//
+// The entries are grouped by the part of their key before the first '/', i.e. the ID of the store for the Check
+// cache entries (see [graph.CheckCacheKeyStorePrefix]). Each group has a generation, which is stored in Redis along
+// with the entries, and an entry is only returned if it was stored at the current generation of its group.
+// InvalidateStore increments the generation of the store, which invalidates its entries in every instance at once,
+// without deleting them: they expire with their TTL. The keys of a group share a Redis Cluster hash tag, so that
+// an entry and its generation are read with a single command.
+type Backend struct {
+	client    redis.UniversalClient
+	keyPrefix string
+	timeout   time.Duration
+
+	// observedGenerations holds the generation of the group of each key when the key was last looked up, so that
+	// the entry resolved after a miss isn't stored at a generation incremented while it was being resolved.
+	observedGenerations storage.InMemoryCache[uint64]
+}
+
+var (
+	_ graph.CheckCacheBackend     = (*Backend)(nil)
+	_ graph.CheckCacheInvalidator = (*Backend)(nil)
+)
+
+// Option configures a Backend.
+type Option func(*Backend)
+
+// WithKeyPrefix sets the prefix of the Redis keys of the entries, e.g. so that several OpenFGA deployments share a
+// Redis. Defaults to 'openfga:'.
+func WithKeyPrefix(prefix string) Option {
+	return func(b *Backend) {
+		b.keyPrefix = prefix
+	}
+}
+
+// WithTimeout sets the timeout of each operation. Defaults to 100ms.
+func WithTimeout(timeout time.Duration) Option {
+	return func(b *Backend) {
+		b.timeout = timeout
+	}
+}
+
+// New creates a Backend that stores the entries with the client, which is owned by the caller.
+func New(client redis.UniversalClient, opts ...Option) *Backend {
+	b := &Backend{
+		client:    client,
+		keyPrefix: defaultKeyPrefix,
+		timeout:   defaultTimeout,
+		observedGenerations: storage.NewInMemoryLRUCache[uint64](
+			storage.WithMaxCacheSize[uint64](maxObservedGenerations),
+		),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Close releases the resources of the backend, but doesn't close its client.
+func (b *Backend) Close() {
+	b.observedGenerations.Stop()
+}
+
+// Get see [graph.CheckCacheBackend].Get.
+func (b *Backend) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	generationKey, entryKey := b.keys(key)
+	values, err := b.client.MGet(ctx, generationKey, entryKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	generation, err := parseGeneration(values[0])
+	if err != nil {
+		return nil, err
+	}
+	b.observedGenerations.Set(key, generation, observedGenerationsTTL)
+
+	entry, ok := values[1].(string)
+	if !ok || len(entry) < generationHeaderLength {
+		return nil, nil
+	}
+	if binary.BigEndian.Uint64([]byte(entry[:generationHeaderLength])) != generation {
+		// stored before the last invalidation of its group
+		return nil, nil
+	}
+	return []byte(entry[generationHeaderLength:]), nil
+}
+
+// Set see [graph.CheckCacheBackend].Set. The entry is stored at the generation of its group when its key was last
+// looked up, if it was, so that it is invalid already if its group was invalidated since.
+func (b *Backend) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	generationKey, entryKey := b.keys(key)
+
+	var generation uint64
+	if observed := b.observedGenerations.Get(key); observed != nil && !observed.Expired {
+		generation = observed.Value
+	} else {
+		stored, err := b.client.Get(ctx, generationKey).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if generation, err = parseGeneration(stored); err != nil {
+			return err
+		}
+	}
+
+	entry := make([]byte, generationHeaderLength, generationHeaderLength+len(value))
+	binary.BigEndian.PutUint64(entry, generation)
+	entry = append(entry, value...)
+
+	return b.client.Set(ctx, entryKey, entry, ttl).Err()
+}
+
+// InvalidateStore see [graph.CheckCacheInvalidator].InvalidateStore. It increments the generation of the store.
+func (b *Backend) InvalidateStore(ctx context.Context, storeID string) error {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+
+	return b.client.Incr(ctx, b.generationKey(storeID)).Err()
+}
+
+// AwaitStoreInvalidation see [graph.CheckCacheInvalidator].AwaitStoreInvalidation. It returns at once, since every
+// instance reads the generation of the store from Redis along with each entry.
+func (b *Backend) AwaitStoreInvalidation(ctx context.Context, _ string) error {
+	return ctx.Err()
+}
+
+// keys returns the Redis keys of the generation of the group of the key, and of the entry of the key.
+func (b *Backend) keys(key string) (string, string) {
+	group, rest, _ := strings.Cut(key, "/")
+	return b.generationKey(group), b.keyPrefix + "{" + group + "}/" + rest
+}
+
+func (b *Backend) generationKey(group string) string {
+	// unlike the keys of the entries, it has no '/' after the hash tag
+	return b.keyPrefix + "{" + group + "}:generation"
+}
+
+// parseGeneration parses the generation read from Redis, which is zero if the group was never invalidated.
+func parseGeneration(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		return strconv.ParseUint(v, 10, 64)
+	default:
+		return 0, errors.New("unexpected type of the generation")
+	}
+}
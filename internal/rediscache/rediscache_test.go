@@ -0,0 +1,128 @@
+package rediscache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/openfga/openfga/internal/graph"
+)
+
+// newBackends returns n backends that share a Redis, like n OpenFGA instances.
+func newBackends(t *testing.T, n int, opts ...Option) (*miniredis.Miniredis, []*Backend) {
+	mr := miniredis.RunT(t)
+
+	backends := make([]*Backend, 0, n)
+	for i := 0; i < n; i++ {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		backend := New(client, opts...)
+		t.Cleanup(backend.Close)
+		backends = append(backends, backend)
+	}
+	return mr, backends
+}
+
+func TestBackend(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	key := graph.CheckCacheKeyStorePrefix("store") + "1234"
+	otherStoreKey := graph.CheckCacheKeyStorePrefix("other") + "1234"
+
+	t.Run("returns_the_entries_stored_by_other_instances", func(t *testing.T) {
+		_, backends := newBackends(t, 2)
+
+		value, err := backends[0].Get(key)
+		require.NoError(t, err)
+		require.Nil(t, value)
+
+		require.NoError(t, backends[0].Set(key, []byte("allowed"), time.Minute))
+
+		value, err = backends[1].Get(key)
+		require.NoError(t, err)
+		require.Equal(t, []byte("allowed"), value)
+	})
+
+	t.Run("entries_expire_with_their_ttl", func(t *testing.T) {
+		mr, backends := newBackends(t, 1)
+
+		require.NoError(t, backends[0].Set(key, []byte("allowed"), time.Second))
+		mr.FastForward(2 * time.Second)
+
+		value, err := backends[0].Get(key)
+		require.NoError(t, err)
+		require.Nil(t, value)
+	})
+
+	t.Run("invalidating_a_store_invalidates_its_entries_in_every_instance", func(t *testing.T) {
+		_, backends := newBackends(t, 2)
+
+		require.NoError(t, backends[0].Set(key, []byte("allowed"), time.Minute))
+		require.NoError(t, backends[0].Set(otherStoreKey, []byte("allowed"), time.Minute))
+
+		require.NoError(t, backends[1].InvalidateStore(ctx, "store"))
+		require.NoError(t, backends[1].AwaitStoreInvalidation(ctx, "store"))
+
+		for _, backend := range backends {
+			value, err := backend.Get(key)
+			require.NoError(t, err)
+			require.Nil(t, value)
+
+			value, err = backend.Get(otherStoreKey)
+			require.NoError(t, err)
+			require.Equal(t, []byte("allowed"), value)
+		}
+
+		// entries stored after the invalidation are valid
+		require.NoError(t, backends[0].Set(key, []byte("denied"), time.Minute))
+		value, err := backends[1].Get(key)
+		require.NoError(t, err)
+		require.Equal(t, []byte("denied"), value)
+	})
+
+	t.Run("an_entry_resolved_across_an_invalidation_is_invalid", func(t *testing.T) {
+		_, backends := newBackends(t, 2)
+
+		// the Check is resolved by the first instance while the second one invalidates the store
+		value, err := backends[0].Get(key)
+		require.NoError(t, err)
+		require.Nil(t, value)
+
+		require.NoError(t, backends[1].InvalidateStore(ctx, "store"))
+
+		require.NoError(t, backends[0].Set(key, []byte("stale"), time.Minute))
+
+		value, err = backends[1].Get(key)
+		require.NoError(t, err)
+		require.Nil(t, value)
+	})
+
+	t.Run("keys_are_prefixed", func(t *testing.T) {
+		mr, backends := newBackends(t, 1, WithKeyPrefix("tenant:"))
+
+		require.NoError(t, backends[0].Set(key, []byte("allowed"), time.Minute))
+		require.NoError(t, backends[0].InvalidateStore(ctx, "store"))
+
+		require.ElementsMatch(t, []string{"tenant:{store}/1234", "tenant:{store}:generation"}, mr.Keys())
+	})
+
+	t.Run("fails_if_redis_is_unavailable", func(t *testing.T) {
+		mr, backends := newBackends(t, 1, WithTimeout(50*time.Millisecond))
+		mr.Close()
+
+		_, err := backends[0].Get(key)
+		require.Error(t, err)
+		require.Error(t, backends[0].Set(key, []byte("allowed"), time.Minute))
+		require.Error(t, backends[0].InvalidateStore(ctx, "store"))
+	})
+}
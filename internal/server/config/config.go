@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/spf13/viper"
 
 	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/middleware/disabledmethods"
+	"github.com/openfga/openfga/pkg/middleware/methodconcurrency"
 )
 
 const (
@@ -23,23 +26,37 @@ const (
 	DefaultChangelogHorizonOffset           = 0
 	DefaultResolveNodeLimit                 = 25
 	DefaultResolveNodeBreadthLimit          = 100
+	DefaultCheckMaxVisitedObjects           = 0
 	DefaultUsersetBatchSize                 = 1000
 	DefaultListObjectsDeadline              = 3 * time.Second
 	DefaultListObjectsMaxResults            = 1000
+	DefaultListObjectsCheckFallback         = true
 	DefaultMaxConcurrentReadsForCheck       = math.MaxUint32
 	DefaultMaxConcurrentReadsForListObjects = math.MaxUint32
 	DefaultListUsersDeadline                = 3 * time.Second
 	DefaultListUsersMaxResults              = 1000
+	DefaultExpandMaxDirectUsers             = 0
 	DefaultMaxConcurrentReadsForListUsers   = math.MaxUint32
 
-	DefaultWriteContextByteLimit = 32 * 1_024 // 32KB
-	DefaultCheckQueryCacheLimit  = 10000
-	DefaultCheckQueryCacheTTL    = 10 * time.Second
-	DefaultCheckQueryCacheEnable = false
+	DefaultWriteContextByteLimit  = 32 * 1_024 // 32KB
+	DefaultWriteConflictStrategy  = "reject"
+	DefaultWriteIdempotencyKeyTTL = 24 * time.Hour
+	DefaultStoreSettingsCacheTTL  = 10 * time.Second
+	DefaultCheckQueryCacheLimit   = 10000
+	DefaultCheckQueryCacheTTL     = 10 * time.Second
+	DefaultCheckQueryCacheEnable  = false
+
+	DefaultCheckQueryCacheBackend                       = "memory"
+	DefaultCheckQueryCacheChangelogInvalidationInterval = 1 * time.Second
+
+	DefaultWatchPollInterval         = 1 * time.Second
+	DefaultWatchMaxConcurrentStreams = 100
 
 	// Care should be taken here - decreasing can cause API compatibility problems with Conditions.
-	DefaultMaxConditionEvaluationCost = 100
-	DefaultInterruptCheckFrequency    = 100
+	DefaultMaxConditionEvaluationCost     = 100
+	DefaultInterruptCheckFrequency        = 100
+	DefaultConditionEvaluationErrorPolicy = "error"
+	DefaultCheckResolutionStrategy        = "recursive"
 
 	DefaultCheckDispatchThrottlingEnabled          = false
 	DefaultCheckDispatchThrottlingFrequency        = 10 * time.Microsecond
@@ -75,6 +92,22 @@ type DatastoreConfig struct {
 	Username string
 	Password string `json:"-"` // private field, won't be logged
 
+	// ReadReplicaURIs are the connection uris of the read replicas of the datastore. Tuple reads that
+	// don't request HIGHER_CONSISTENCY are sent to them. Only supported by the 'mysql' engine.
+	ReadReplicaURIs []string `json:"-"` // private field, won't be logged
+
+	// MaxStatementSize is the size in bytes above which a statement of a Write is rejected. If 0, the
+	// 'mysql' engine uses its max_allowed_packet.
+	MaxStatementSize int
+
+	// SplitLargeWrites splits the changelog entries of a Write above MaxStatementSize into several
+	// statements of the same transaction, instead of rejecting the Write.
+	SplitLargeWrites bool
+
+	// AutoMigrate runs the migrations of the schema at startup. If false, the server refuses to start
+	// unless the migrations have been run, e.g. with 'openfga migrate'.
+	AutoMigrate bool
+
 	// MaxCacheSize is the maximum number of authorization models that will be cached in memory.
 	MaxCacheSize int
 
@@ -91,6 +124,27 @@ type DatastoreConfig struct {
 	// ConnMaxLifetime is the maximum amount of time a connection to the datastore may be reused.
 	ConnMaxLifetime time.Duration
 
+	// ReadTimeout is the maximum amount of time a read of tuples from the datastore may take. If 0,
+	// only the deadline of the request applies.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the maximum amount of time a write of tuples to the datastore may take. If 0,
+	// only the deadline of the request applies.
+	WriteTimeout time.Duration
+
+	// ChangelogBatchDelay is the maximum delay for which a write of tuples is held to be committed
+	// together with the concurrent writes, so that their changelog entries are inserted with grouped
+	// statements. If 0, every write is committed on its own.
+	ChangelogBatchDelay time.Duration
+
+	// ChangelogBatchMaxWrites is the maximum number of writes committed together when ChangelogBatchDelay
+	// is set. If 0, there is no maximum.
+	ChangelogBatchMaxWrites int
+
+	// ChangelogBatchMaxConcurrentGroups is the maximum number of groups of writes committed at the same time
+	// when ChangelogBatchDelay is set.
+	ChangelogBatchMaxConcurrentGroups int
+
 	// Metrics is configuration for the Datastore metrics.
 	Metrics DatastoreMetricsConfig
 }
@@ -199,6 +253,23 @@ type CheckQueryCache struct {
 	Enabled bool
 	Limit   uint32 // (in items)
 	TTL     time.Duration
+	// PositiveTTL and NegativeTTL override TTL for allowed and denied results, if not zero.
+	PositiveTTL time.Duration
+	NegativeTTL time.Duration
+	// EmptyObjectTTL is the TTL of the cached knowledge of whether the objects of tuple to usersets have tuples.
+	// If zero, it isn't cached.
+	EmptyObjectTTL time.Duration
+	// RejectNonDeterministicConditions rejects the models with conditions that call non-deterministic functions,
+	// instead of not caching the Checks that involve them.
+	RejectNonDeterministicConditions bool
+	// Backend is where the cached results are stored: 'memory', in each instance, or 'redis', shared by every
+	// instance.
+	Backend  string
+	RedisURL string `json:"-"` // private field, won't be logged
+	// ChangelogInvalidationInterval is the interval at which the changelog of the stores with cached results is
+	// polled, to invalidate them once the tuples of the store change. If zero, it isn't polled. Only used by the
+	// 'redis' backend.
+	ChangelogInvalidationInterval time.Duration
 }
 
 // DispatchThrottlingConfig defines configurations for dispatch throttling.
@@ -223,6 +294,23 @@ type Config struct {
 	// This is to protect the server from misuse of the ListObjects endpoints.
 	ListObjectsMaxResults uint32
 
+	// ListObjectsCheckFallback allows ListObjects on the relations that involve an intersection or an exclusion,
+	// for which every object found must be Checked, which can be very slow. If true, which is the default, a
+	// warning is logged for these relations. If false, ListObjects on these relations fails.
+	ListObjectsCheckFallback bool
+
+	// ReverseExpansionParallelism defines the maximum number of objects found by a single datastore read of the
+	// reverse expansion of a ListObjects query that are expanded concurrently. If 0, ResolveNodeBreadthLimit is used.
+	ReverseExpansionParallelism uint32
+
+	// ReverseExpansionBackoffLatencyThreshold defines the latency of a datastore read of a reverse expansion above
+	// which the datastore is considered overloaded. If 0, only the reads that fail are signs of overload.
+	ReverseExpansionBackoffLatencyThreshold time.Duration
+
+	// ReverseExpansionMaxBackoff defines the maximum delay of the datastore reads of the reverse expansions of
+	// ListObjects queries while the datastore is overloaded. If 0, the reads are never delayed.
+	ReverseExpansionMaxBackoff time.Duration
+
 	// ListUsersDeadline defines the maximum amount of time to accumulate ListUsers results
 	// before the server will respond. This is to protect the server from misuse of the
 	// ListUsers endpoints. It cannot be larger than the configured server's request timeout (RequestTimeout or HTTPConfig.UpstreamTimeout).
@@ -233,9 +321,21 @@ type Config struct {
 	// This is to protect the server from misuse of the ListUsers endpoints.
 	ListUsersMaxResults uint32
 
+	// ExpandMaxDirectUsers defines the maximum number of direct users of each node of an Expand tree.
+	// The users beyond it are summarized in a single truncation entry. If 0, all the users are returned.
+	ExpandMaxDirectUsers uint32
+
 	// MaxTuplesPerWrite defines the maximum number of tuples per Write endpoint.
 	MaxTuplesPerWrite int
 
+	// WriteConflictStrategy defines how the Write endpoint resolves a tuple that is both deleted and written by
+	// the same request: 'reject' rejects the request, 'last-wins' drops the delete and 'delete-wins' drops the write.
+	WriteConflictStrategy string
+
+	// WriteCompleteConditionContext makes the Write endpoint reject the conditioned tuples whose condition
+	// context doesn't have a value for every parameter of the condition.
+	WriteCompleteConditionContext bool
+
 	// MaxTypesPerAuthorizationModel defines the maximum number of type definitions per
 	// authorization model for the WriteAuthorizationModel endpoint.
 	MaxTypesPerAuthorizationModel int
@@ -244,6 +344,10 @@ type Config struct {
 	// persisting an Authorization Model.
 	MaxAuthorizationModelSizeInBytes int
 
+	// MaxAuthorizationModelsPerStore defines the maximum number of authorization models of the stores whose
+	// settings don't set one. If zero, the number of models is unlimited.
+	MaxAuthorizationModelsPerStore int
+
 	// MaxConcurrentReadsForListObjects defines the maximum number of concurrent database reads
 	// allowed in ListObjects queries
 	MaxConcurrentReadsForListObjects uint32
@@ -263,9 +367,59 @@ type Config struct {
 	// after this offset will not be included in the response of ReadChanges.
 	ChangelogHorizonOffset int
 
+	// WatchPollInterval is the interval at which a Watch stream reads the changelog of its store once it has
+	// streamed all of its changes.
+	WatchPollInterval time.Duration
+
+	// WatchMaxConcurrentStreams is the maximum number of concurrent Watch streams. The streams beyond it are
+	// rejected with a ResourceExhausted error.
+	WatchMaxConcurrentStreams uint32
+
 	// Experimentals is a list of the experimental features to enable in the OpenFGA server.
 	Experimentals []string
 
+	// DisabledConditions is a list of the names of the conditions that are never met while resolving
+	// Check, ListObjects and ListUsers, so that the tuples with these conditions don't grant any access.
+	DisabledConditions []string
+
+	// CheckSelfRelations is a list of 'objectType#relation@userType' relations that Check resolves as allowed
+	// for the users whose ID is the ID of the object, e.g. with 'profile#viewer@user', user:anne is a viewer of
+	// profile:anne.
+	CheckSelfRelations []string
+
+	// ConditionEvaluationErrorPolicy defines how Check handles an error while evaluating the condition of a
+	// tuple: 'error' fails the Check, 'treat-as-false' makes the tuple not match and 'treat-as-true' makes
+	// the tuple match as if it had no condition.
+	ConditionEvaluationErrorPolicy string
+
+	// CheckStrictConditionContext makes Check, ListObjects and ListUsers handle a tuple whose condition has
+	// parameters missing from the context, e.g. a Check without context, as an evaluation error, handled by
+	// ConditionEvaluationErrorPolicy for Check. Otherwise, such a tuple doesn't match.
+	CheckStrictConditionContext bool
+
+	// CheckResolutionStrategy defines how Check resolves a query: 'recursive' resolves it once, up to the
+	// ResolveNodeLimit, and 'iterative-deepening' resolves it with increasing depth limits up to the
+	// ResolveNodeLimit, which bounds the memory of the queries that don't need the full depth at the cost of
+	// resolving again the queries that need a deeper resolution.
+	CheckResolutionStrategy string
+
+	// KnownCheckResultsEnabled makes Check trust the results of subproblems supplied by the client in the
+	// Openfga-Known-Check-Results header. It must only be enabled if all the clients are trusted.
+	KnownCheckResultsEnabled bool
+
+	// ModelNotFoundFallback makes the queries that request an authorization model that is not found resolve
+	// against the latest authorization model of the store instead of failing.
+	ModelNotFoundFallback bool
+
+	// DisabledMethods is a list of the RPC methods of the OpenFGA service (e.g. 'Expand') that are
+	// rejected with an Unimplemented error before reaching their handler.
+	DisabledMethods []string
+
+	// MethodConcurrencyLimits is a list of limits of the form 'Method=limit' (e.g. 'ListObjects=10') on the
+	// number of concurrent calls to RPC methods of the OpenFGA service. Calls beyond the limit are rejected with
+	// a ResourceExhausted error.
+	MethodConcurrencyLimits []string
+
 	// ResolveNodeLimit indicates how deeply nested an authorization model can be before a query
 	// errors out.
 	ResolveNodeLimit uint32
@@ -274,6 +428,10 @@ type Config struct {
 	// concurrently in a query
 	ResolveNodeBreadthLimit uint32
 
+	// CheckMaxVisitedObjects indicates how many distinct objects a Check can visit before it errors out.
+	// If 0, the number of visited objects is not limited.
+	CheckMaxVisitedObjects uint32
+
 	// RequestTimeout configures request timeout.  If both HTTP upstream timeout and request timeout are specified,
 	// request timeout will be prioritized
 	RequestTimeout time.Duration
@@ -345,6 +503,46 @@ func (cfg *Config) Verify() error {
 		return fmt.Errorf("config 'log.TimestampFormat' must be one of ['Unix', 'ISO8601']")
 	}
 
+	if cfg.WriteConflictStrategy != "reject" &&
+		cfg.WriteConflictStrategy != "last-wins" &&
+		cfg.WriteConflictStrategy != "delete-wins" {
+		return fmt.Errorf("config 'writeConflictStrategy' must be one of ['reject', 'last-wins', 'delete-wins']")
+	}
+
+	if cfg.CheckResolutionStrategy != "recursive" && cfg.CheckResolutionStrategy != "iterative-deepening" {
+		return fmt.Errorf("config 'checkResolutionStrategy' must be one of ['recursive', 'iterative-deepening']")
+	}
+
+	if cfg.CheckQueryCache.Backend != "memory" && cfg.CheckQueryCache.Backend != "redis" {
+		return fmt.Errorf("config 'checkQueryCache.backend' must be one of ['memory', 'redis']")
+	}
+
+	if cfg.CheckQueryCache.Backend == "redis" && cfg.CheckQueryCache.RedisURL == "" {
+		return fmt.Errorf("config 'checkQueryCache.redisUrl' is required by the 'redis' check query cache backend")
+	}
+
+	if cfg.ConditionEvaluationErrorPolicy != "error" &&
+		cfg.ConditionEvaluationErrorPolicy != "treat-as-false" &&
+		cfg.ConditionEvaluationErrorPolicy != "treat-as-true" {
+		return fmt.Errorf("config 'conditionEvaluationErrorPolicy' must be one of ['error', 'treat-as-false', 'treat-as-true']")
+	}
+
+	for _, method := range cfg.DisabledMethods {
+		if !slices.Contains(disabledmethods.MethodNames(), method) && method != disabledmethods.WatchMethod {
+			return fmt.Errorf("config 'disabledMethods' contains unknown method '%s', must be one of %v", method, append(disabledmethods.MethodNames(), disabledmethods.WatchMethod))
+		}
+	}
+
+	for _, limit := range cfg.MethodConcurrencyLimits {
+		method, _, err := methodconcurrency.ParseMethodConcurrency(limit)
+		if err != nil {
+			return fmt.Errorf("config 'methodConcurrencyLimits': %w", err)
+		}
+		if !slices.Contains(disabledmethods.MethodNames(), method) {
+			return fmt.Errorf("config 'methodConcurrencyLimits' contains unknown method '%s', must be one of %v", method, disabledmethods.MethodNames())
+		}
+	}
+
 	if cfg.Playground.Enabled {
 		if !cfg.HTTP.Enabled {
 			return errors.New("the HTTP server must be enabled to run the openfga playground")
@@ -433,6 +631,14 @@ func (cfg *Config) Verify() error {
 		return errors.New("listObjectsDeadline must be non-negative time duration")
 	}
 
+	if cfg.WatchPollInterval <= 0 {
+		return errors.New("watchPollInterval must be greater than zero")
+	}
+
+	if cfg.WatchMaxConcurrentStreams == 0 {
+		return errors.New("watchMaxConcurrentStreams must be greater than zero")
+	}
+
 	if cfg.ListUsersDeadline < 0 {
 		return errors.New("listUsersDeadline must be non-negative time duration")
 	}
@@ -526,19 +732,39 @@ func MaxConditionEvaluationCost() uint64 {
 func DefaultConfig() *Config {
 	return &Config{
 		MaxTuplesPerWrite:                         DefaultMaxTuplesPerWrite,
+		WriteConflictStrategy:                     DefaultWriteConflictStrategy,
+		WriteCompleteConditionContext:             false,
 		MaxTypesPerAuthorizationModel:             DefaultMaxTypesPerAuthorizationModel,
 		MaxAuthorizationModelSizeInBytes:          DefaultMaxAuthorizationModelSizeInBytes,
+		MaxAuthorizationModelsPerStore:            0,
 		MaxConcurrentReadsForCheck:                DefaultMaxConcurrentReadsForCheck,
 		MaxConcurrentReadsForListObjects:          DefaultMaxConcurrentReadsForListObjects,
 		MaxConcurrentReadsForListUsers:            DefaultMaxConcurrentReadsForListUsers,
 		MaxConditionEvaluationCost:                DefaultMaxConditionEvaluationCost,
 		ChangelogHorizonOffset:                    DefaultChangelogHorizonOffset,
+		WatchPollInterval:                         DefaultWatchPollInterval,
+		WatchMaxConcurrentStreams:                 DefaultWatchMaxConcurrentStreams,
 		ResolveNodeLimit:                          DefaultResolveNodeLimit,
 		ResolveNodeBreadthLimit:                   DefaultResolveNodeBreadthLimit,
+		CheckMaxVisitedObjects:                    DefaultCheckMaxVisitedObjects,
 		Experimentals:                             []string{},
+		DisabledConditions:                        []string{},
+		CheckSelfRelations:                        []string{},
+		ConditionEvaluationErrorPolicy:            DefaultConditionEvaluationErrorPolicy,
+		CheckStrictConditionContext:               false,
+		CheckResolutionStrategy:                   DefaultCheckResolutionStrategy,
+		KnownCheckResultsEnabled:                  false,
+		ModelNotFoundFallback:                     false,
+		DisabledMethods:                           []string{},
+		MethodConcurrencyLimits:                   []string{},
 		ListObjectsDeadline:                       DefaultListObjectsDeadline,
 		ListObjectsMaxResults:                     DefaultListObjectsMaxResults,
+		ListObjectsCheckFallback:                  DefaultListObjectsCheckFallback,
+		ReverseExpansionParallelism:               0,
+		ReverseExpansionBackoffLatencyThreshold:   0,
+		ReverseExpansionMaxBackoff:                0,
 		ListUsersMaxResults:                       DefaultListUsersMaxResults,
+		ExpandMaxDirectUsers:                      DefaultExpandMaxDirectUsers,
 		ListUsersDeadline:                         DefaultListUsersDeadline,
 		RequestDurationDatastoreQueryCountBuckets: []string{"50", "200"},
 		RequestDurationDispatchCountBuckets:       []string{"50", "200"},
@@ -547,6 +773,9 @@ func DefaultConfig() *Config {
 			MaxCacheSize: DefaultMaxAuthorizationModelCacheSize,
 			MaxIdleConns: 10,
 			MaxOpenConns: 30,
+
+			ChangelogBatchMaxWrites:           100,
+			ChangelogBatchMaxConcurrentGroups: 4,
 		},
 		GRPC: GRPCConfig{
 			Addr: "0.0.0.0:8081",
@@ -598,6 +827,9 @@ func DefaultConfig() *Config {
 			Enabled: DefaultCheckQueryCacheEnable,
 			Limit:   DefaultCheckQueryCacheLimit,
 			TTL:     DefaultCheckQueryCacheTTL,
+
+			Backend:                       DefaultCheckQueryCacheBackend,
+			ChangelogInvalidationInterval: DefaultCheckQueryCacheChangelogInvalidationInterval,
 		},
 		DispatchThrottling: DispatchThrottlingConfig{
 			Enabled:      DefaultCheckDispatchThrottlingEnabled,
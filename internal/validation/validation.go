@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
+	"github.com/openfga/openfga/internal/condition"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
@@ -260,6 +262,39 @@ func validateCondition(typesys *typesystem.TypeSystem, tk *openfgav1.TupleKey) e
 	return nil
 }
 
+// ValidateConditionContextComplete returns an error if the context of the condition of the tuple doesn't have a
+// value for every parameter of the condition. Otherwise, the parameters that are missing must be provided by the
+// context of the queries. The tuple must be valid according to ValidateTuple.
+func ValidateConditionContextComplete(typesys *typesystem.TypeSystem, tk *openfgav1.TupleKey) error {
+	if tk.GetCondition() == nil {
+		return nil
+	}
+
+	evaluableCondition, ok := typesys.GetConditions()[tk.GetCondition().GetName()]
+	if !ok {
+		return nil
+	}
+
+	parameters := make([]string, 0, len(evaluableCondition.GetParameters()))
+	for parameter := range evaluableCondition.GetParameters() {
+		parameters = append(parameters, parameter)
+	}
+	// report the same missing parameter every time
+	slices.Sort(parameters)
+
+	contextFieldMap := tk.GetCondition().GetContext().GetFields()
+	for _, parameter := range parameters {
+		if _, ok := contextFieldMap[parameter]; !ok {
+			return &tuple.InvalidConditionalTupleError{
+				Cause:    &condition.MissingParameterError{Condition: evaluableCondition.Name, Parameter: parameter},
+				TupleKey: tk,
+			}
+		}
+	}
+
+	return nil
+}
+
 // FilterInvalidTuples filters out tuples that aren't valid according to the provided model.
 func FilterInvalidTuples(typesys *typesystem.TypeSystem) storage.TupleKeyFilterFunc {
 	return func(tupleKey *openfgav1.TupleKey) bool {
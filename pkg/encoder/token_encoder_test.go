@@ -0,0 +1,41 @@
+package encoder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/encrypter"
+)
+
+func FuzzTokenEncoderDecode(f *testing.F) {
+	gcm, err := encrypter.NewGCMEncrypter("key")
+	require.NoError(f, err)
+	tokenEncoder := NewTokenEncoder(gcm, NewBase64Encoder())
+
+	valid, err := tokenEncoder.Encode([]byte("continuation token"))
+	require.NoError(f, err)
+
+	for _, seed := range []string{"", valid, valid[:len(valid)-4], "AAAA", "====", "!@#$", "a"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, token string) {
+		decoded, err := tokenEncoder.Decode(token)
+		if err != nil {
+			require.Nil(t, decoded)
+			return
+		}
+
+		// only the tokens issued by the encoder, and the empty token, can be decoded
+		encoded, err := tokenEncoder.Encode(decoded)
+		require.NoError(t, err)
+		require.Equal(t, decoded, mustDecode(t, tokenEncoder, encoded))
+	})
+}
+
+func mustDecode(t *testing.T, encoder Encoder, token string) []byte {
+	decoded, err := encoder.Decode(token)
+	require.NoError(t, err)
+	return decoded
+}
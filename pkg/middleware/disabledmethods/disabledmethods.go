@@ -0,0 +1,65 @@
+package disabledmethods
+
+import (
+	"context"
+	"fmt"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchMethod is the name of the Watch method of the OpenFGA service, which has no gRPC method yet and is only
+// served over HTTP by the server itself. It can be disabled too, but not by the interceptors of this package.
+const WatchMethod = "Watch"
+
+// MethodNames returns the names of the methods of the OpenFGA service, which are the names that can be disabled.
+func MethodNames() []string {
+	desc := openfgav1.OpenFGAService_ServiceDesc
+
+	names := make([]string, 0, len(desc.Methods)+len(desc.Streams))
+	for _, method := range desc.Methods {
+		names = append(names, method.MethodName)
+	}
+	for _, stream := range desc.Streams {
+		names = append(names, stream.StreamName)
+	}
+	return names
+}
+
+// fullMethods returns the full gRPC method names, e.g. "/openfga.v1.OpenFGAService/Expand", of the
+// given methods of the OpenFGA service.
+func fullMethods(methods []string) map[string]struct{} {
+	full := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		full[fmt.Sprintf("/%s/%s", openfgav1.OpenFGAService_ServiceDesc.ServiceName, method)] = struct{}{}
+	}
+	return full
+}
+
+// NewUnaryInterceptor returns a grpc.UnaryServerInterceptor that rejects the calls to the given methods of the
+// OpenFGA service, e.g. "Expand", with codes.Unimplemented, before they reach their handler.
+// It should come before any interceptor that does work on behalf of the handler, e.g. authentication.
+func NewUnaryInterceptor(methods []string) grpc.UnaryServerInterceptor {
+	disabled := fullMethods(methods)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := disabled[info.FullMethod]; ok {
+			return nil, status.Errorf(codes.Unimplemented, "method %s is disabled", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStreamingInterceptor is like NewUnaryInterceptor, for streaming methods.
+func NewStreamingInterceptor(methods []string) grpc.StreamServerInterceptor {
+	disabled := fullMethods(methods)
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, ok := disabled[info.FullMethod]; ok {
+			return status.Errorf(codes.Unimplemented, "method %s is disabled", info.FullMethod)
+		}
+		return handler(srv, stream)
+	}
+}
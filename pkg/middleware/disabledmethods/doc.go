@@ -0,0 +1,2 @@
+// Package disabledmethods contains middleware that rejects the calls to disabled RPC methods.
+package disabledmethods
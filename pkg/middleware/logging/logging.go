@@ -67,6 +67,9 @@ func (r *reporter) PostCall(err error, rpcDuration time.Duration) {
 	r.fields = append(r.fields, zap.Int32(grpcCodeKey, code))
 
 	if err != nil {
+		// terse errors are only terse for clients, they are logged in full
+		err = serverErrors.Verbose(err)
+
 		var internalError serverErrors.InternalError
 		if errors.As(err, &internalError) {
 			r.fields = append(r.fields, zap.String(internalErrorKey, internalError.Internal().Error()))
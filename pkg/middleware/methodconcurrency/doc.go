@@ -0,0 +1,2 @@
+// Package methodconcurrency contains middleware that limits the number of concurrent calls to each RPC method.
+package methodconcurrency
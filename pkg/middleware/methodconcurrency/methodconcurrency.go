@@ -0,0 +1,128 @@
+package methodconcurrency
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfga/openfga/internal/build"
+)
+
+var inFlightRequestsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: build.ProjectName,
+	Name:      "method_in_flight_requests",
+	Help:      "The number of in-flight calls to each RPC method with a concurrency limit.",
+}, []string{"grpc_method"})
+
+// Option sets the concurrency limit of a method.
+type Option func(limits map[string]int)
+
+// WithMethodConcurrency limits the number of concurrent calls to the given method of the OpenFGA service,
+// e.g. "ListObjects". The calls beyond the limit are rejected with codes.ResourceExhausted.
+func WithMethodConcurrency(method string, limit int) Option {
+	return func(limits map[string]int) {
+		limits[method] = limit
+	}
+}
+
+// ParseMethodConcurrency parses a limit of the form 'Method=limit', e.g. 'ListObjects=10'.
+func ParseMethodConcurrency(value string) (string, int, error) {
+	method, rawLimit, ok := strings.Cut(value, "=")
+	if !ok || method == "" {
+		return "", 0, fmt.Errorf("invalid method concurrency limit '%s', must be of the form 'Method=limit'", value)
+	}
+
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit <= 0 {
+		return "", 0, fmt.Errorf("invalid method concurrency limit '%s', the limit must be a positive integer", value)
+	}
+
+	return method, limit, nil
+}
+
+// semaphore limits the concurrent calls to a method.
+type semaphore struct {
+	slots    chan struct{}
+	inFlight prometheus.Gauge
+}
+
+func (s *semaphore) acquire(fullMethod string) error {
+	select {
+	case s.slots <- struct{}{}:
+		s.inFlight.Inc()
+		return nil
+	default:
+		return status.Errorf(codes.ResourceExhausted, "too many concurrent calls to method %s, the limit is %d", fullMethod, cap(s.slots))
+	}
+}
+
+func (s *semaphore) release() {
+	s.inFlight.Dec()
+	<-s.slots
+}
+
+// newSemaphores returns the semaphores of the methods with a limit, keyed by their full gRPC method name,
+// e.g. "/openfga.v1.OpenFGAService/ListObjects".
+func newSemaphores(opts []Option) map[string]*semaphore {
+	limits := map[string]int{}
+	for _, opt := range opts {
+		opt(limits)
+	}
+
+	semaphores := make(map[string]*semaphore, len(limits))
+	for method, limit := range limits {
+		semaphores[fmt.Sprintf("/%s/%s", openfgav1.OpenFGAService_ServiceDesc.ServiceName, method)] = &semaphore{
+			slots:    make(chan struct{}, limit),
+			inFlight: inFlightRequestsGauge.WithLabelValues(method),
+		}
+	}
+	return semaphores
+}
+
+// NewUnaryInterceptor returns a grpc.UnaryServerInterceptor that rejects the calls to a method with
+// codes.ResourceExhausted while as many calls as its limit are in flight. Each method has its own limit,
+// so that e.g. saturating ListObjects doesn't affect Check. Methods without a limit are not limited.
+func NewUnaryInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	semaphores := newSemaphores(opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		sem, ok := semaphores[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		if err := sem.acquire(info.FullMethod); err != nil {
+			return nil, err
+		}
+		defer sem.release()
+
+		return handler(ctx, req)
+	}
+}
+
+// NewStreamingInterceptor is like NewUnaryInterceptor, for streaming methods.
+func NewStreamingInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	semaphores := newSemaphores(opts)
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		sem, ok := semaphores[info.FullMethod]
+		if !ok {
+			return handler(srv, stream)
+		}
+
+		if err := sem.acquire(info.FullMethod); err != nil {
+			return err
+		}
+		defer sem.release()
+
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,129 @@
+package methodconcurrency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	checkMethod       = "/openfga.v1.OpenFGAService/Check"
+	listObjectsMethod = "/openfga.v1.OpenFGAService/ListObjects"
+)
+
+func TestUnaryInterceptor(t *testing.T) {
+	interceptor := NewUnaryInterceptor(
+		WithMethodConcurrency("Check", 1),
+		WithMethodConcurrency("ListObjects", 2),
+	)
+
+	// saturate calls the method until its limit is reached, with handlers that block until the returned
+	// function is called
+	saturate := func(t *testing.T, method string, limit int) func() {
+		unblock := make(chan struct{})
+		done := make(chan struct{})
+		started := make(chan struct{}, limit)
+		for i := 0; i < limit; i++ {
+			go func() {
+				defer func() { done <- struct{}{} }()
+				_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+					started <- struct{}{}
+					<-unblock
+					return nil, nil
+				})
+				require.NoError(t, err)
+			}()
+		}
+		for i := 0; i < limit; i++ {
+			<-started
+		}
+
+		return func() {
+			close(unblock)
+			for i := 0; i < limit; i++ {
+				<-done
+			}
+		}
+	}
+
+	call := func(method string) error {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	t.Run("saturated_list_objects_does_not_block_check", func(t *testing.T) {
+		release := saturate(t, listObjectsMethod, 2)
+		require.InDelta(t, 2, testutil.ToFloat64(inFlightRequestsGauge.WithLabelValues("ListObjects")), 0)
+
+		err := call(listObjectsMethod)
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		require.NoError(t, call(checkMethod))
+
+		release()
+		require.InDelta(t, 0, testutil.ToFloat64(inFlightRequestsGauge.WithLabelValues("ListObjects")), 0)
+		require.NoError(t, call(listObjectsMethod))
+	})
+
+	t.Run("saturated_check_does_not_block_list_objects", func(t *testing.T) {
+		release := saturate(t, checkMethod, 1)
+		require.InDelta(t, 1, testutil.ToFloat64(inFlightRequestsGauge.WithLabelValues("Check")), 0)
+
+		err := call(checkMethod)
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		require.NoError(t, call(listObjectsMethod))
+
+		release()
+		require.NoError(t, call(checkMethod))
+	})
+
+	t.Run("methods_without_limit_are_not_limited", func(t *testing.T) {
+		release := saturate(t, "/openfga.v1.OpenFGAService/Expand", 10)
+		release()
+	})
+}
+
+func TestStreamingInterceptor(t *testing.T) {
+	const method = "/openfga.v1.OpenFGAService/StreamedListObjects"
+	interceptor := NewStreamingInterceptor(WithMethodConcurrency("StreamedListObjects", 1))
+
+	unblock := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- interceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, stream grpc.ServerStream) error {
+			close(started)
+			<-unblock
+			return nil
+		})
+	}()
+	<-started
+
+	err := interceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	close(unblock)
+	require.NoError(t, <-done)
+}
+
+func TestParseMethodConcurrency(t *testing.T) {
+	method, limit, err := ParseMethodConcurrency("ListObjects=10")
+	require.NoError(t, err)
+	require.Equal(t, "ListObjects", method)
+	require.Equal(t, 10, limit)
+
+	for _, value := range []string{"ListObjects", "=10", "ListObjects=", "ListObjects=0", "ListObjects=-1", "ListObjects=ten"} {
+		_, _, err := ParseMethodConcurrency(value)
+		require.Error(t, err, value)
+	}
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/openfga/openfga/internal/graph"
+	serverconfig "github.com/openfga/openfga/internal/server/config"
+	"github.com/openfga/openfga/internal/validation"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+const (
+	defaultAccessMatrixMaxCells              = 10000
+	defaultAccessMatrixMaxConcurrentChecks   = 10
+	defaultAccessMatrixMaxSharedCheckResults = 10000
+)
+
+// AccessMatrixRequest selects the grid of an access matrix: every relation of Relations is checked for
+// every pair of a user of Users and an object of Objects.
+type AccessMatrixRequest struct {
+	StoreID   string
+	Users     []string
+	Objects   []string
+	Relations []string
+	Context   *structpb.Struct
+}
+
+// AccessMatrixRow is a row of an access matrix, for one pair of a user and an object.
+type AccessMatrixRow struct {
+	User   string
+	Object string
+	// Relations are the relations of the request that the user has on the object, in the order of the request.
+	Relations []string
+}
+
+// AccessMatrixCommand computes which relations hold for every pair of a set of users and a set of objects,
+// e.g. for audit reports. The rows are emitted as they are resolved, so the memory used doesn't grow with
+// the size of the grid. The results of the Checks, and of their sub-problems, are shared between the cells
+// of an Execute call, so that e.g. the members of a group are only read once for all the objects shared
+// with it.
+type AccessMatrixCommand struct {
+	tupleReader         storage.RelationshipTupleReader
+	resolveNodeLimit    uint32
+	maxConcurrentReads  uint32
+	maxConcurrentChecks uint32
+	maxCells            int
+}
+
+type AccessMatrixCmdOption func(*AccessMatrixCommand)
+
+// WithAccessMatrixResolveNodeLimit see server.WithResolveNodeLimit.
+func WithAccessMatrixResolveNodeLimit(limit uint32) AccessMatrixCmdOption {
+	return func(c *AccessMatrixCommand) {
+		c.resolveNodeLimit = limit
+	}
+}
+
+// WithAccessMatrixMaxConcurrentReads see server.WithMaxConcurrentReadsForCheck. The limit applies
+// to all the Checks run by an Execute call combined.
+func WithAccessMatrixMaxConcurrentReads(limit uint32) AccessMatrixCmdOption {
+	return func(c *AccessMatrixCommand) {
+		c.maxConcurrentReads = limit
+	}
+}
+
+// WithAccessMatrixMaxConcurrentChecks sets the maximum number of Checks that are run at the same time.
+func WithAccessMatrixMaxConcurrentChecks(limit uint32) AccessMatrixCmdOption {
+	return func(c *AccessMatrixCommand) {
+		c.maxConcurrentChecks = limit
+	}
+}
+
+// WithAccessMatrixMaxCells sets the maximum number of cells of a matrix, i.e. of users times objects
+// times relations. Larger requests are rejected before any Check is run.
+func WithAccessMatrixMaxCells(n int) AccessMatrixCmdOption {
+	return func(c *AccessMatrixCommand) {
+		c.maxCells = n
+	}
+}
+
+func NewAccessMatrixCommand(tupleReader storage.RelationshipTupleReader, opts ...AccessMatrixCmdOption) *AccessMatrixCommand {
+	cmd := &AccessMatrixCommand{
+		tupleReader:         tupleReader,
+		resolveNodeLimit:    serverconfig.DefaultResolveNodeLimit,
+		maxConcurrentReads:  serverconfig.DefaultMaxConcurrentReadsForCheck,
+		maxConcurrentChecks: defaultAccessMatrixMaxConcurrentChecks,
+		maxCells:            defaultAccessMatrixMaxCells,
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Execute computes the access matrix of the request against the authorization model in the context, and
+// emits its rows one by one, users first then objects, in the order of the request. Every cell is
+// validated before the first row is emitted, so an invalid request emits no rows. If emit returns an
+// error, Execute stops and returns it.
+func (c *AccessMatrixCommand) Execute(ctx context.Context, req *AccessMatrixRequest, emit func(*AccessMatrixRow) error) error {
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return serverErrors.HandleError("", fmt.Errorf("typesystem missing in context"))
+	}
+
+	cells := len(req.Users) * len(req.Objects) * len(req.Relations)
+	if cells > c.maxCells {
+		return serverErrors.ValidationError(fmt.Errorf("the matrix has %d cells, more than the maximum of %d", cells, c.maxCells))
+	}
+
+	for _, user := range req.Users {
+		for _, object := range req.Objects {
+			for _, relation := range req.Relations {
+				if err := validation.ValidateUserObjectRelation(typesys, tuple.NewTupleKey(object, relation, user)); err != nil {
+					return serverErrors.ValidationError(err)
+				}
+			}
+		}
+	}
+
+	// the results are only shared within the request, so that the matrix reflects the tuples as they are
+	checkResolver, checkResolverCloser := graph.NewOrderedCheckResolvers(
+		graph.WithLocalCheckerOpts(graph.WithMaxConcurrentReads(c.maxConcurrentReads)),
+		graph.WithCachedCheckResolverOpts(true, graph.WithMaxCacheSize(defaultAccessMatrixMaxSharedCheckResults)),
+	).Build()
+	defer checkResolverCloser()
+
+	ctx = storage.ContextWithRelationshipTupleReader(ctx,
+		storagewrappers.NewBoundedConcurrencyTupleReader(c.tupleReader, c.maxConcurrentReads),
+	)
+
+	allowed := make([]bool, len(req.Relations))
+	for _, user := range req.Users {
+		for _, object := range req.Objects {
+			pool, poolCtx := errgroup.WithContext(ctx)
+			pool.SetLimit(int(c.maxConcurrentChecks))
+			for i, relation := range req.Relations {
+				pool.Go(func() error {
+					resp, err := checkResolver.ResolveCheck(poolCtx, &graph.ResolveCheckRequest{
+						StoreID:              req.StoreID,
+						AuthorizationModelID: typesys.GetAuthorizationModelID(),
+						TupleKey:             tuple.NewTupleKey(object, relation, user),
+						Context:              req.Context,
+						RequestMetadata:      graph.NewCheckRequestMetadata(c.resolveNodeLimit),
+					})
+					if err != nil {
+						return handleResolveCheckError(err)
+					}
+					allowed[i] = resp.GetAllowed()
+					return nil
+				})
+			}
+			if err := pool.Wait(); err != nil {
+				return err
+			}
+
+			row := &AccessMatrixRow{User: user, Object: object, Relations: []string{}}
+			for i, relation := range req.Relations {
+				if allowed[i] {
+					row.Relations = append(row.Relations, relation)
+				}
+			}
+			if err := emit(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
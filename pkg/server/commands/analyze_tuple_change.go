@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/openfga/openfga/internal/graph"
+	serverconfig "github.com/openfga/openfga/internal/server/config"
+	"github.com/openfga/openfga/internal/validation"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// AnalyzeTupleChangeRequest holds two snapshots of the tuples of a store, and the Checks to compare
+// between them.
+type AnalyzeTupleChangeRequest struct {
+	// Before are the tuples of the first snapshot.
+	Before []*openfgav1.TupleKey
+	// After are the tuples of the second snapshot. If Changes is set, After must not be, and the
+	// second snapshot is instead the result of applying Changes to Before in order, e.g. a range
+	// of the changelog of the store.
+	After   []*openfgav1.TupleKey
+	Changes []*openfgav1.TupleChange
+
+	// Probes are the 'object#relation@user' Checks whose results are compared.
+	Probes []*openfgav1.TupleKey
+	// Context is the context of the probes.
+	Context *structpb.Struct
+}
+
+type AnalyzeTupleChangeResponse struct {
+	// Changed are the probes whose results differ between the snapshots, in the order of the request.
+	Changed []*AnalyzeTupleChangeResult
+}
+
+type AnalyzeTupleChangeResult struct {
+	Probe         *openfgav1.TupleKey
+	AllowedBefore bool
+	AllowedAfter  bool
+}
+
+// AnalyzeTupleChangeCommand reports the Checks whose results change between two snapshots of tuples,
+// e.g. to review the access impact of a tuple migration before running it. Each snapshot is loaded
+// in its own in-memory datastore, so none of the tuples of the request have to be written to a store.
+type AnalyzeTupleChangeCommand struct {
+	resolveNodeLimit   uint32
+	maxConcurrentReads uint32
+}
+
+type AnalyzeTupleChangeCmdOption func(*AnalyzeTupleChangeCommand)
+
+// WithAnalyzeTupleChangeResolveNodeLimit see server.WithResolveNodeLimit.
+func WithAnalyzeTupleChangeResolveNodeLimit(limit uint32) AnalyzeTupleChangeCmdOption {
+	return func(c *AnalyzeTupleChangeCommand) {
+		c.resolveNodeLimit = limit
+	}
+}
+
+// WithAnalyzeTupleChangeMaxConcurrentReads see server.WithMaxConcurrentReadsForCheck.
+func WithAnalyzeTupleChangeMaxConcurrentReads(limit uint32) AnalyzeTupleChangeCmdOption {
+	return func(c *AnalyzeTupleChangeCommand) {
+		c.maxConcurrentReads = limit
+	}
+}
+
+func NewAnalyzeTupleChangeCommand(opts ...AnalyzeTupleChangeCmdOption) *AnalyzeTupleChangeCommand {
+	cmd := &AnalyzeTupleChangeCommand{
+		resolveNodeLimit:   serverconfig.DefaultResolveNodeLimit,
+		maxConcurrentReads: serverconfig.DefaultMaxConcurrentReadsForCheck,
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Execute compares the results of the probes of the request between its snapshots, against the
+// authorization model in the context.
+func (c *AnalyzeTupleChangeCommand) Execute(ctx context.Context, req *AnalyzeTupleChangeRequest) (*AnalyzeTupleChangeResponse, error) {
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return nil, serverErrors.HandleError("", fmt.Errorf("typesystem missing in context"))
+	}
+
+	after := req.After
+	if len(req.Changes) > 0 {
+		if len(req.After) > 0 {
+			return nil, serverErrors.ValidationError(errors.New("the second snapshot must be set either by its tuples or by changes, not both"))
+		}
+		after = applyTupleChanges(req.Before, req.Changes)
+	}
+
+	for _, probe := range req.Probes {
+		if err := validation.ValidateUserObjectRelation(typesys, probe); err != nil {
+			return nil, serverErrors.ValidationError(err)
+		}
+	}
+
+	before, err := loadTupleSnapshot(ctx, typesys, req.Before)
+	if err != nil {
+		return nil, err
+	}
+	defer before.Close()
+
+	afterDatastore, err := loadTupleSnapshot(ctx, typesys, after)
+	if err != nil {
+		return nil, err
+	}
+	defer afterDatastore.Close()
+
+	resp := &AnalyzeTupleChangeResponse{}
+	for _, probe := range req.Probes {
+		allowedBefore, err := c.check(ctx, typesys, before, probe, req.Context)
+		if err != nil {
+			return nil, err
+		}
+
+		allowedAfter, err := c.check(ctx, typesys, afterDatastore, probe, req.Context)
+		if err != nil {
+			return nil, err
+		}
+
+		if allowedBefore != allowedAfter {
+			resp.Changed = append(resp.Changed, &AnalyzeTupleChangeResult{
+				Probe:         probe,
+				AllowedBefore: allowedBefore,
+				AllowedAfter:  allowedAfter,
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+// snapshotStoreID is the store of the in-memory datastores of the snapshots, which only have one store.
+var snapshotStoreID = ulid.Make().String()
+
+// loadTupleSnapshot validates the tuples against the model, and returns an in-memory datastore with them.
+func loadTupleSnapshot(ctx context.Context, typesys *typesystem.TypeSystem, tuples []*openfgav1.TupleKey) (storage.OpenFGADatastore, error) {
+	for _, tk := range tuples {
+		if err := validation.ValidateTuple(typesys, tk); err != nil {
+			return nil, serverErrors.HandleTupleValidateError(err)
+		}
+	}
+
+	ds := memory.New()
+	if err := ds.Write(ctx, snapshotStoreID, nil, tuples); err != nil {
+		ds.Close()
+		return nil, serverErrors.HandleError("", err)
+	}
+	return ds, nil
+}
+
+// applyTupleChanges returns the tuples that result from applying the changes to the given tuples, in order.
+func applyTupleChanges(tuples []*openfgav1.TupleKey, changes []*openfgav1.TupleChange) []*openfgav1.TupleKey {
+	var keys []string
+	byKey := make(map[string]*openfgav1.TupleKey, len(tuples))
+
+	set := func(tk *openfgav1.TupleKey) {
+		key := tuple.TupleKeyToString(tk)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = tk
+	}
+
+	for _, tk := range tuples {
+		set(tk)
+	}
+
+	for _, change := range changes {
+		if change.GetOperation() == openfgav1.TupleOperation_TUPLE_OPERATION_DELETE {
+			delete(byKey, tuple.TupleKeyToString(change.GetTupleKey()))
+			continue
+		}
+		set(change.GetTupleKey())
+	}
+
+	result := make([]*openfgav1.TupleKey, 0, len(byKey))
+	for _, key := range keys {
+		if tk, ok := byKey[key]; ok {
+			result = append(result, tk)
+			// a key written again after being deleted is only returned once
+			delete(byKey, key)
+		}
+	}
+	return result
+}
+
+func (c *AnalyzeTupleChangeCommand) check(
+	ctx context.Context,
+	typesys *typesystem.TypeSystem,
+	ds storage.RelationshipTupleReader,
+	probe *openfgav1.TupleKey,
+	checkContext *structpb.Struct,
+) (bool, error) {
+	// the results of Checks on a snapshot must not be cached, so a dedicated resolver is used
+	checker := graph.NewLocalChecker(graph.WithMaxConcurrentReads(c.maxConcurrentReads))
+	defer checker.Close()
+
+	ctx = storage.ContextWithRelationshipTupleReader(ctx,
+		storagewrappers.NewBoundedConcurrencyTupleReader(ds, c.maxConcurrentReads),
+	)
+
+	resp, err := checker.ResolveCheck(ctx, &graph.ResolveCheckRequest{
+		StoreID:              snapshotStoreID,
+		AuthorizationModelID: typesys.GetAuthorizationModelID(),
+		TupleKey:             tuple.NewTupleKey(probe.GetObject(), probe.GetRelation(), probe.GetUser()),
+		Context:              checkContext,
+		RequestMetadata:      graph.NewCheckRequestMetadata(c.resolveNodeLimit),
+	})
+	if err != nil {
+		return false, handleResolveCheckError(err)
+	}
+	return resp.GetAllowed(), nil
+}
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/openfga/openfga/internal/graph"
+	serverconfig "github.com/openfga/openfga/internal/server/config"
+	"github.com/openfga/openfga/internal/validation"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+const (
+	defaultBatchCheckMaxChecks           = 10000
+	defaultBatchCheckChunkSize           = 50
+	defaultBatchCheckMaxConcurrentChunks = 10
+)
+
+// BatchCheckItem is one of the Checks of a batch, identified in the results by its CorrelationID.
+type BatchCheckItem struct {
+	TupleKey         *openfgav1.CheckRequestTupleKey
+	ContextualTuples []*openfgav1.TupleKey
+	Context          *structpb.Struct
+	CorrelationID    string
+}
+
+// BatchCheckRequest is a batch of Checks against the same store.
+type BatchCheckRequest struct {
+	StoreID string
+	Checks  []*BatchCheckItem
+}
+
+// BatchCheckResult is the outcome of one Check of a batch. Err is set if the Check failed, in which case
+// Allowed is false; the failure of a Check doesn't fail the other Checks of the batch.
+type BatchCheckResult struct {
+	CorrelationID string
+	Allowed       bool
+	Err           error
+}
+
+// BatchCheckCommand resolves a batch of Checks, e.g. to filter thousands of items in a single request.
+// The batch is split into chunks of Checks that are resolved one after the other, and the chunks are
+// resolved concurrently up to a limit, so that a large batch doesn't use more resources than a few small
+// ones. The results of a chunk are emitted as soon as it is resolved, so the memory used doesn't grow with
+// the size of the batch.
+type BatchCheckCommand struct {
+	tupleReader         storage.RelationshipTupleReader
+	checkResolver       graph.CheckResolver
+	resolveNodeLimit    uint32
+	maxConcurrentReads  uint32
+	maxChecks           int
+	chunkSize           int
+	maxConcurrentChunks int
+}
+
+type BatchCheckCmdOption func(*BatchCheckCommand)
+
+// WithBatchCheckResolveNodeLimit see server.WithResolveNodeLimit.
+func WithBatchCheckResolveNodeLimit(limit uint32) BatchCheckCmdOption {
+	return func(c *BatchCheckCommand) {
+		c.resolveNodeLimit = limit
+	}
+}
+
+// WithBatchCheckMaxConcurrentReads see server.WithMaxConcurrentReadsForCheck. The limit applies
+// to each Check of the batch.
+func WithBatchCheckMaxConcurrentReads(limit uint32) BatchCheckCmdOption {
+	return func(c *BatchCheckCommand) {
+		c.maxConcurrentReads = limit
+	}
+}
+
+// WithBatchCheckMaxChecks sets the maximum number of Checks of a batch. Larger batches are rejected
+// before any Check is run.
+func WithBatchCheckMaxChecks(n int) BatchCheckCmdOption {
+	return func(c *BatchCheckCommand) {
+		c.maxChecks = n
+	}
+}
+
+// WithBatchCheckChunkSize sets the number of Checks of a chunk.
+func WithBatchCheckChunkSize(n int) BatchCheckCmdOption {
+	return func(c *BatchCheckCommand) {
+		c.chunkSize = n
+	}
+}
+
+// WithBatchCheckMaxConcurrentChunks sets the maximum number of chunks that are resolved at the same time.
+func WithBatchCheckMaxConcurrentChunks(n int) BatchCheckCmdOption {
+	return func(c *BatchCheckCommand) {
+		c.maxConcurrentChunks = n
+	}
+}
+
+func NewBatchCheckCommand(
+	tupleReader storage.RelationshipTupleReader,
+	checkResolver graph.CheckResolver,
+	opts ...BatchCheckCmdOption,
+) *BatchCheckCommand {
+	cmd := &BatchCheckCommand{
+		tupleReader:         tupleReader,
+		checkResolver:       checkResolver,
+		resolveNodeLimit:    serverconfig.DefaultResolveNodeLimit,
+		maxConcurrentReads:  serverconfig.DefaultMaxConcurrentReadsForCheck,
+		maxChecks:           defaultBatchCheckMaxChecks,
+		chunkSize:           defaultBatchCheckChunkSize,
+		maxConcurrentChunks: defaultBatchCheckMaxConcurrentChunks,
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Execute resolves the Checks of the request against the authorization model in the context, and emits
+// the result of every Check exactly once, in no particular order. The batch is rejected if it has more
+// Checks than the maximum, or if a correlation ID is empty or repeated. emit is never called concurrently;
+// if it returns an error, Execute stops and returns it.
+func (c *BatchCheckCommand) Execute(ctx context.Context, req *BatchCheckRequest, emit func(*BatchCheckResult) error) error {
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return serverErrors.HandleError("", fmt.Errorf("typesystem missing in context"))
+	}
+
+	if len(req.Checks) > c.maxChecks {
+		return serverErrors.ValidationError(fmt.Errorf("the batch has %d checks, more than the maximum of %d", len(req.Checks), c.maxChecks))
+	}
+
+	correlationIDs := make(map[string]struct{}, len(req.Checks))
+	for _, check := range req.Checks {
+		if check.CorrelationID == "" {
+			return serverErrors.ValidationError(fmt.Errorf("a check of the batch has no correlation ID"))
+		}
+		if _, ok := correlationIDs[check.CorrelationID]; ok {
+			return serverErrors.ValidationError(fmt.Errorf("the correlation ID '%s' is repeated in the batch", check.CorrelationID))
+		}
+		correlationIDs[check.CorrelationID] = struct{}{}
+	}
+
+	var emitMu sync.Mutex
+	pool, ctx := errgroup.WithContext(ctx)
+	pool.SetLimit(max(c.maxConcurrentChunks, 1))
+	chunkSize := max(c.chunkSize, 1)
+	for start := 0; start < len(req.Checks); start += chunkSize {
+		chunk := req.Checks[start:min(start+chunkSize, len(req.Checks))]
+		pool.Go(func() error {
+			results := make([]*BatchCheckResult, 0, len(chunk))
+			for _, check := range chunk {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				allowed, err := c.check(ctx, typesys, req.StoreID, check)
+				results = append(results, &BatchCheckResult{
+					CorrelationID: check.CorrelationID,
+					Allowed:       allowed,
+					Err:           err,
+				})
+			}
+
+			emitMu.Lock()
+			defer emitMu.Unlock()
+			for _, result := range results {
+				if err := emit(result); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return pool.Wait()
+}
+
+func (c *BatchCheckCommand) check(ctx context.Context, typesys *typesystem.TypeSystem, storeID string, check *BatchCheckItem) (bool, error) {
+	tk := tuple.ConvertCheckRequestTupleKeyToTupleKey(check.TupleKey)
+	if err := validation.ValidateUserObjectRelation(typesys, tk); err != nil {
+		return false, serverErrors.ValidationError(err)
+	}
+
+	for _, ctxTuple := range check.ContextualTuples {
+		if err := validation.ValidateTuple(typesys, ctxTuple); err != nil {
+			return false, serverErrors.HandleTupleValidateError(err)
+		}
+	}
+
+	ctx = storage.ContextWithRelationshipTupleReader(ctx,
+		storagewrappers.NewBoundedConcurrencyTupleReader(
+			storagewrappers.NewCombinedTupleReader(c.tupleReader, check.ContextualTuples),
+			c.maxConcurrentReads,
+		),
+	)
+
+	resp, err := c.checkResolver.ResolveCheck(ctx, &graph.ResolveCheckRequest{
+		StoreID:              storeID,
+		AuthorizationModelID: typesys.GetAuthorizationModelID(),
+		TupleKey:             tk,
+		ContextualTuples:     check.ContextualTuples,
+		Context:              check.Context,
+		RequestMetadata:      graph.NewCheckRequestMetadata(c.resolveNodeLimit),
+	})
+	if err != nil {
+		return false, handleResolveCheckError(err)
+	}
+	return resp.GetAllowed(), nil
+}
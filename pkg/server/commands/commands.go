@@ -1,6 +1,30 @@
 // Package commands contains the code that handles each endpoint.
 package commands
 
-import "go.opentelemetry.io/otel"
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/openfga/openfga/internal/condition"
+	"github.com/openfga/openfga/internal/graph"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+)
 
 var tracer = otel.Tracer("openfga/pkg/server/commands")
+
+// handleResolveCheckError converts an error returned by a [graph.CheckResolver] into the error returned
+// by the commands that resolve Checks on their own.
+func handleResolveCheckError(err error) error {
+	if errors.Is(err, graph.ErrResolutionDepthExceeded) {
+		return serverErrors.AuthorizationModelResolutionTooComplex
+	}
+	var visitedObjectsErr *graph.MaxVisitedObjectsExceededError
+	if errors.As(err, &visitedObjectsErr) {
+		return serverErrors.VisitedObjectsLimitExceeded(visitedObjectsErr.Visited, visitedObjectsErr.Limit)
+	}
+	if errors.Is(err, condition.ErrEvaluationFailed) {
+		return serverErrors.ValidationError(err)
+	}
+	return serverErrors.HandleError("", err)
+}
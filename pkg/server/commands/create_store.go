@@ -2,8 +2,8 @@ package commands
 
 import (
 	"context"
+	"time"
 
-	"github.com/oklog/ulid/v2"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
 	"github.com/openfga/openfga/pkg/logger"
@@ -12,8 +12,9 @@ import (
 )
 
 type CreateStoreCommand struct {
-	storesBackend storage.StoresBackend
-	logger        logger.Logger
+	storesBackend          storage.StoresBackend
+	uniqueStoreNameCreator storage.UniqueStoreNameCreator
+	logger                 logger.Logger
 }
 
 type CreateStoreCmdOption func(*CreateStoreCommand)
@@ -24,6 +25,13 @@ func WithCreateStoreCmdLogger(l logger.Logger) CreateStoreCmdOption {
 	}
 }
 
+// WithCreateStoreCmdUniqueNames creates the stores with the creator, which rejects the names of existing stores.
+func WithCreateStoreCmdUniqueNames(creator storage.UniqueStoreNameCreator) CreateStoreCmdOption {
+	return func(c *CreateStoreCommand) {
+		c.uniqueStoreNameCreator = creator
+	}
+}
+
 func NewCreateStoreCommand(
 	storesBackend storage.StoresBackend,
 	opts ...CreateStoreCmdOption,
@@ -40,8 +48,13 @@ func NewCreateStoreCommand(
 }
 
 func (s *CreateStoreCommand) Execute(ctx context.Context, req *openfgav1.CreateStoreRequest) (*openfgav1.CreateStoreResponse, error) {
-	store, err := s.storesBackend.CreateStore(ctx, &openfgav1.Store{
-		Id:   ulid.Make().String(),
+	createStore := s.storesBackend.CreateStore
+	if s.uniqueStoreNameCreator != nil {
+		createStore = s.uniqueStoreNameCreator.CreateStoreWithUniqueName
+	}
+
+	store, err := createStore(ctx, &openfgav1.Store{
+		Id:   storage.NewULID(time.Now()).String(),
 		Name: req.GetName(),
 	})
 	if err != nil {
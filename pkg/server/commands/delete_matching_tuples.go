@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/logger"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// DeleteMatchingTuplesRequest selects the tuples of a store to delete. Object selects every tuple of one
+// object (e.g. "document:1"), User selects every tuple of one user (e.g. "user:jon"). At least one must be set;
+// if both are set, only the tuples matching both are selected.
+type DeleteMatchingTuplesRequest struct {
+	StoreID string
+	Object  string
+	User    string
+}
+
+// DeleteMatchingTuplesPreview describes the tuples that a DeleteMatchingTuplesRequest currently matches.
+type DeleteMatchingTuplesPreview struct {
+	TupleKeys []*openfgav1.TupleKey
+
+	// ConfirmationToken identifies this exact set of tuples. It must be passed to
+	// [DeleteMatchingTuplesCommand.Execute] to perform the delete.
+	ConfirmationToken string
+}
+
+// DeleteMatchingTuplesCommand deletes all the tuples that match an object and/or a user in two phases:
+// Preview returns the matching tuples and a confirmation token for them, and Execute deletes them only if the
+// tuples matching at that point are the same ones that were previewed.
+type DeleteMatchingTuplesCommand struct {
+	datastore storage.OpenFGADatastore
+	logger    logger.Logger
+}
+
+type DeleteMatchingTuplesCmdOption func(*DeleteMatchingTuplesCommand)
+
+func WithDeleteMatchingTuplesCmdLogger(l logger.Logger) DeleteMatchingTuplesCmdOption {
+	return func(c *DeleteMatchingTuplesCommand) {
+		c.logger = l
+	}
+}
+
+func NewDeleteMatchingTuplesCommand(
+	datastore storage.OpenFGADatastore,
+	opts ...DeleteMatchingTuplesCmdOption,
+) *DeleteMatchingTuplesCommand {
+	cmd := &DeleteMatchingTuplesCommand{
+		datastore: datastore,
+		logger:    logger.NewNoopLogger(),
+	}
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Preview returns the tuples currently matching the request and the token needed to delete them.
+func (c *DeleteMatchingTuplesCommand) Preview(ctx context.Context, req *DeleteMatchingTuplesRequest) (*DeleteMatchingTuplesPreview, error) {
+	tupleKeys, err := c.readMatchingTuples(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeleteMatchingTuplesPreview{
+		TupleKeys:         tupleKeys,
+		ConfirmationToken: matchSetConfirmationToken(req, tupleKeys),
+	}, nil
+}
+
+// Execute deletes the tuples matching the request, as long as they are the same tuples that were returned
+// by the Preview that issued confirmationToken. Otherwise, nothing is deleted and
+// [serverErrors.DeleteConfirmationMismatch] is returned. All the tuples are deleted in a single write,
+// so the delete fails if the match set is larger than the datastore's MaxTuplesPerWrite.
+func (c *DeleteMatchingTuplesCommand) Execute(ctx context.Context, req *DeleteMatchingTuplesRequest, confirmationToken string) error {
+	tupleKeys, err := c.readMatchingTuples(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if matchSetConfirmationToken(req, tupleKeys) != confirmationToken {
+		return serverErrors.DeleteConfirmationMismatch
+	}
+
+	if len(tupleKeys) == 0 {
+		return nil
+	}
+
+	if len(tupleKeys) > c.datastore.MaxTuplesPerWrite() {
+		return serverErrors.ExceededEntityLimit("write operations", c.datastore.MaxTuplesPerWrite())
+	}
+
+	deletes := make([]*openfgav1.TupleKeyWithoutCondition, 0, len(tupleKeys))
+	for _, tk := range tupleKeys {
+		deletes = append(deletes, tupleUtils.TupleKeyToTupleKeyWithoutCondition(tk))
+	}
+
+	// if a previewed tuple is deleted concurrently, the write fails with storage.ErrInvalidWriteInput
+	if err := c.datastore.Write(ctx, req.StoreID, deletes, nil); err != nil {
+		return serverErrors.HandleError("", err)
+	}
+	return nil
+}
+
+func (c *DeleteMatchingTuplesCommand) readMatchingTuples(ctx context.Context, req *DeleteMatchingTuplesRequest) ([]*openfgav1.TupleKey, error) {
+	if req.Object == "" && req.User == "" {
+		return nil, serverErrors.ValidationError(errors.New("an object or a user must be provided"))
+	}
+
+	if req.Object != "" && !tupleUtils.IsValidObject(req.Object) {
+		return nil, serverErrors.ValidationError(&tupleUtils.InvalidTupleError{
+			Cause:    errors.New("the 'object' field is malformed"),
+			TupleKey: tupleUtils.NewTupleKey(req.Object, "", req.User),
+		})
+	}
+
+	if req.User != "" && !tupleUtils.IsValidUser(req.User) {
+		return nil, serverErrors.ValidationError(&tupleUtils.InvalidTupleError{
+			Cause:    errors.New("the 'user' field is malformed"),
+			TupleKey: tupleUtils.NewTupleKey(req.Object, "", req.User),
+		})
+	}
+
+	iter, err := c.datastore.Read(ctx, req.StoreID, tupleUtils.NewTupleKey(req.Object, "", req.User), storage.ReadOptions{})
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+	defer iter.Stop()
+
+	var tupleKeys []*openfgav1.TupleKey
+	for {
+		t, err := iter.Next(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrIteratorDone) {
+				break
+			}
+			return nil, serverErrors.HandleError("", err)
+		}
+		tupleKeys = append(tupleKeys, t.GetKey())
+	}
+
+	sort.Slice(tupleKeys, func(i, j int) bool {
+		return tupleUtils.TupleKeyToString(tupleKeys[i]) < tupleUtils.TupleKeyToString(tupleKeys[j])
+	})
+
+	return tupleKeys, nil
+}
+
+// matchSetConfirmationToken returns a token that identifies a request and the sorted set of tuples it matched.
+func matchSetConfirmationToken(req *DeleteMatchingTuplesRequest, sortedTupleKeys []*openfgav1.TupleKey) string {
+	hasher := sha256.New()
+	for _, s := range []string{req.StoreID, req.Object, req.User} {
+		hasher.Write([]byte(s))
+		hasher.Write([]byte{0})
+	}
+	for _, tk := range sortedTupleKeys {
+		hasher.Write([]byte(tupleUtils.TupleKeyToString(tk)))
+		hasher.Write([]byte{0})
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
@@ -3,6 +3,8 @@ package commands
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"golang.org/x/sync/errgroup"
@@ -15,10 +17,15 @@ import (
 	"github.com/openfga/openfga/pkg/typesystem"
 )
 
+// ExpandTruncatedUsersFormat is the format of the last user of the leaf nodes whose direct users were
+// truncated by WithExpandQueryMaxDirectUsers. It is formatted with the number of users left out.
+const ExpandTruncatedUsersFormat = "+%d more, truncated"
+
 // ExpandQuery resolves a target TupleKey into a UsersetTree by expanding type definitions.
 type ExpandQuery struct {
-	logger    logger.Logger
-	datastore storage.OpenFGADatastore
+	logger         logger.Logger
+	datastore      storage.OpenFGADatastore
+	maxDirectUsers uint32
 }
 
 type ExpandQueryOption func(*ExpandQuery)
@@ -29,6 +36,15 @@ func WithExpandQueryLogger(l logger.Logger) ExpandQueryOption {
 	}
 }
 
+// WithExpandQueryMaxDirectUsers limits the number of direct users of each node of the tree. The users beyond
+// the limit are left out, and replaced by a last user formatted with ExpandTruncatedUsersFormat.
+// If 0, all the direct users are returned.
+func WithExpandQueryMaxDirectUsers(limit uint32) ExpandQueryOption {
+	return func(eq *ExpandQuery) {
+		eq.maxDirectUsers = limit
+	}
+}
+
 // NewExpandQuery creates a new ExpandQuery using the supplied backends for retrieving data.
 func NewExpandQuery(datastore storage.OpenFGADatastore, opts ...ExpandQueryOption) *ExpandQuery {
 	eq := &ExpandQuery{
@@ -43,6 +59,49 @@ func NewExpandQuery(datastore storage.OpenFGADatastore, opts ...ExpandQueryOptio
 }
 
 func (q *ExpandQuery) Execute(ctx context.Context, req *openfgav1.ExpandRequest) (*openfgav1.ExpandResponse, error) {
+	userset, tk, typesys, err := q.resolveRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := q.resolveUserset(ctx, req.GetStoreId(), userset, tk, typesys, req.GetConsistency())
+	if err != nil {
+		return nil, err
+	}
+
+	return &openfgav1.ExpandResponse{
+		Tree: &openfgav1.UsersetTree{
+			Root: root,
+		},
+	}, nil
+}
+
+// ExpandStreamedNode is a node of the tree of an Expand, as emitted by ExecuteStreamed.
+type ExpandStreamedNode struct {
+	// Path is the position of the node in the tree: the index of the node among the children of its parent,
+	// preceded by the path of its parent. The path of the root is empty. The base of a difference is its
+	// child 0, and the subtracted node its child 1.
+	Path []int
+	// Node is the node without its children, i.e. the nodes of unions and intersections are empty, and the
+	// base and the subtracted node of differences are nil. The children are emitted after the node.
+	Node *openfgav1.UsersetTree_Node
+}
+
+// ExecuteStreamed is like Execute, but emits the nodes of the tree one by one as they are resolved, every node
+// before its children. The children of a node are resolved one after the other, so that it stops as soon as the
+// context is done or emit returns an error, which is returned.
+func (q *ExpandQuery) ExecuteStreamed(ctx context.Context, req *openfgav1.ExpandRequest, emit func(*ExpandStreamedNode) error) error {
+	userset, tk, typesys, err := q.resolveRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return q.streamUserset(ctx, req.GetStoreId(), userset, tk, typesys, req.GetConsistency(), nil, emit)
+}
+
+// resolveRequest validates the request and returns the rewrite of its relation, the tuple key to expand, and the
+// typesystem of its model.
+func (q *ExpandQuery) resolveRequest(ctx context.Context, req *openfgav1.ExpandRequest) (*openfgav1.Userset, *openfgav1.TupleKey, *typesystem.TypeSystem, error) {
 	store := req.GetStoreId()
 	modelID := req.GetAuthorizationModelId()
 	tupleKey := req.GetTupleKey()
@@ -50,7 +109,7 @@ func (q *ExpandQuery) Execute(ctx context.Context, req *openfgav1.ExpandRequest)
 	relation := tupleKey.GetRelation()
 
 	if object == "" || relation == "" {
-		return nil, serverErrors.InvalidExpandInput
+		return nil, nil, nil, serverErrors.InvalidExpandInput
 	}
 
 	tk := tupleUtils.NewTupleKey(object, relation, "")
@@ -58,56 +117,94 @@ func (q *ExpandQuery) Execute(ctx context.Context, req *openfgav1.ExpandRequest)
 	model, err := q.datastore.ReadAuthorizationModel(ctx, store, modelID)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			return nil, serverErrors.AuthorizationModelNotFound(modelID)
+			return nil, nil, nil, serverErrors.AuthorizationModelNotFound(modelID)
 		}
 
-		return nil, serverErrors.HandleError("", err)
+		return nil, nil, nil, serverErrors.HandleError("", err)
 	}
 
 	if !typesystem.IsSchemaVersionSupported(model.GetSchemaVersion()) {
-		return nil, serverErrors.ValidationError(typesystem.ErrInvalidSchemaVersion)
+		return nil, nil, nil, serverErrors.ValidationError(typesystem.ErrInvalidSchemaVersion)
 	}
 
 	typesys, err := typesystem.NewAndValidate(ctx, model)
 	if err != nil {
-		return nil, serverErrors.ValidationError(typesystem.ErrInvalidModel)
+		return nil, nil, nil, serverErrors.ValidationError(typesystem.ErrInvalidModel)
 	}
 
 	if err = validation.ValidateObject(typesys, tk); err != nil {
-		return nil, serverErrors.ValidationError(err)
+		return nil, nil, nil, serverErrors.ValidationError(err)
 	}
 
 	err = validation.ValidateRelation(typesys, tk)
 	if err != nil {
-		return nil, serverErrors.ValidationError(err)
+		return nil, nil, nil, serverErrors.ValidationError(err)
 	}
 
 	objectType := tupleUtils.GetType(object)
 	rel, err := typesys.GetRelation(objectType, relation)
 	if err != nil {
 		if errors.Is(err, typesystem.ErrObjectTypeUndefined) {
-			return nil, serverErrors.TypeNotFound(objectType)
+			return nil, nil, nil, serverErrors.TypeNotFound(objectType)
 		}
 
 		if errors.Is(err, typesystem.ErrRelationUndefined) {
-			return nil, serverErrors.RelationNotFound(relation, objectType, tk)
+			return nil, nil, nil, serverErrors.RelationNotFound(relation, objectType, tk)
 		}
 
-		return nil, serverErrors.HandleError("", err)
+		return nil, nil, nil, serverErrors.HandleError("", err)
 	}
 
-	userset := rel.GetRewrite()
+	return rel.GetRewrite(), tk, typesys, nil
+}
 
-	root, err := q.resolveUserset(ctx, store, userset, tk, typesys, req.GetConsistency())
-	if err != nil {
-		return nil, err
+// streamUserset emits the node of the userset and then, depth first, the nodes of its children.
+func (q *ExpandQuery) streamUserset(
+	ctx context.Context,
+	store string,
+	userset *openfgav1.Userset,
+	tk *openfgav1.TupleKey,
+	typesys *typesystem.TypeSystem,
+	consistency openfgav1.ConsistencyPreference,
+	path []int,
+	emit func(*ExpandStreamedNode) error,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	return &openfgav1.ExpandResponse{
-		Tree: &openfgav1.UsersetTree{
-			Root: root,
-		},
-	}, nil
+	node := &openfgav1.UsersetTree_Node{Name: toObjectRelation(tk)}
+	var children []*openfgav1.Userset
+	switch us := userset.GetUserset().(type) {
+	case *openfgav1.Userset_Union:
+		node.Value = &openfgav1.UsersetTree_Node_Union{Union: &openfgav1.UsersetTree_Nodes{}}
+		children = us.Union.GetChild()
+	case *openfgav1.Userset_Intersection:
+		node.Value = &openfgav1.UsersetTree_Node_Intersection{Intersection: &openfgav1.UsersetTree_Nodes{}}
+		children = us.Intersection.GetChild()
+	case *openfgav1.Userset_Difference:
+		node.Value = &openfgav1.UsersetTree_Node_Difference{Difference: &openfgav1.UsersetTree_Difference{}}
+		children = []*openfgav1.Userset{us.Difference.GetBase(), us.Difference.GetSubtract()}
+	default:
+		var err error
+		node, err = q.resolveUserset(ctx, store, userset, tk, typesys, consistency)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := emit(&ExpandStreamedNode{Path: path, Node: node}); err != nil {
+		return err
+	}
+
+	for i, child := range children {
+		// clipped so that the paths of the children don't share their last element
+		childPath := append(slices.Clip(path), i)
+		if err := q.streamUserset(ctx, store, child, tk, typesys, consistency, childPath, emit); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (q *ExpandQuery) resolveUserset(
@@ -161,6 +258,7 @@ func (q *ExpandQuery) resolveThis(ctx context.Context, store string, tk *openfga
 	defer filteredIter.Stop()
 
 	distinctUsers := make(map[string]bool)
+	truncated := 0
 	for {
 		tk, err := filteredIter.Next(ctx)
 		if err != nil {
@@ -169,13 +267,24 @@ func (q *ExpandQuery) resolveThis(ctx context.Context, store string, tk *openfga
 			}
 			return nil, serverErrors.HandleError("", err)
 		}
+		if distinctUsers[tk.GetUser()] {
+			continue
+		}
+		// the users beyond the limit are only counted, so that the size of the node stays bounded
+		if q.maxDirectUsers > 0 && len(distinctUsers) >= int(q.maxDirectUsers) {
+			truncated++
+			continue
+		}
 		distinctUsers[tk.GetUser()] = true
 	}
 
-	users := make([]string, 0, len(distinctUsers))
+	users := make([]string, 0, len(distinctUsers)+1)
 	for u := range distinctUsers {
 		users = append(users, u)
 	}
+	if truncated > 0 {
+		users = append(users, fmt.Sprintf(ExpandTruncatedUsersFormat, truncated))
+	}
 
 	return &openfgav1.UsersetTree_Node{
 		Name: toObjectRelation(tk),
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/openfga/openfga/internal/graph"
+	serverconfig "github.com/openfga/openfga/internal/server/config"
+	"github.com/openfga/openfga/internal/validation"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// HypotheticalCheckRequest is a Check to resolve as if its Assumptions were true.
+type HypotheticalCheckRequest struct {
+	StoreID          string
+	TupleKey         *openfgav1.TupleKey
+	ContextualTuples []*openfgav1.TupleKey
+	Context          *structpb.Struct
+
+	// Assumptions are the 'object#relation@user' facts assumed to be true, as described in
+	// [graph.WithAssumptions]. Unlike contextual tuples, their relation can be any relation of
+	// the object type, including computed ones, and it doesn't have to allow the type of the user.
+	// They can't have a condition.
+	Assumptions []*openfgav1.TupleKey
+}
+
+type HypotheticalCheckResponse struct {
+	Allowed bool
+}
+
+// HypotheticalCheckQuery resolves Checks under a set of assumptions, e.g. to find out whether granting a
+// relation to a user would allow another Check without writing any tuple.
+type HypotheticalCheckQuery struct {
+	tupleReader        storage.RelationshipTupleReader
+	resolveNodeLimit   uint32
+	maxConcurrentReads uint32
+}
+
+type HypotheticalCheckQueryOption func(*HypotheticalCheckQuery)
+
+// WithHypotheticalCheckResolveNodeLimit see server.WithResolveNodeLimit.
+func WithHypotheticalCheckResolveNodeLimit(limit uint32) HypotheticalCheckQueryOption {
+	return func(q *HypotheticalCheckQuery) {
+		q.resolveNodeLimit = limit
+	}
+}
+
+// WithHypotheticalCheckMaxConcurrentReads see server.WithMaxConcurrentReadsForCheck.
+func WithHypotheticalCheckMaxConcurrentReads(limit uint32) HypotheticalCheckQueryOption {
+	return func(q *HypotheticalCheckQuery) {
+		q.maxConcurrentReads = limit
+	}
+}
+
+func NewHypotheticalCheckQuery(tupleReader storage.RelationshipTupleReader, opts ...HypotheticalCheckQueryOption) *HypotheticalCheckQuery {
+	q := &HypotheticalCheckQuery{
+		tupleReader:        tupleReader,
+		resolveNodeLimit:   serverconfig.DefaultResolveNodeLimit,
+		maxConcurrentReads: serverconfig.DefaultMaxConcurrentReadsForCheck,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Execute resolves the Check of the request against the authorization model in the context, as if
+// its assumptions were true.
+func (q *HypotheticalCheckQuery) Execute(ctx context.Context, req *HypotheticalCheckRequest) (*HypotheticalCheckResponse, error) {
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return nil, serverErrors.HandleError("", fmt.Errorf("typesystem missing in context"))
+	}
+
+	if err := validation.ValidateUserObjectRelation(typesys, req.TupleKey); err != nil {
+		return nil, serverErrors.ValidationError(err)
+	}
+
+	for _, ctxTuple := range req.ContextualTuples {
+		if err := validation.ValidateTuple(typesys, ctxTuple); err != nil {
+			return nil, serverErrors.HandleTupleValidateError(err)
+		}
+	}
+
+	for _, assumption := range req.Assumptions {
+		if err := validateAssumption(typesys, assumption); err != nil {
+			return nil, serverErrors.ValidationError(
+				fmt.Errorf("invalid assumption '%s': %w", tuple.TupleKeyToString(assumption), err),
+			)
+		}
+	}
+
+	// the results depend on the assumptions, so they must not be cached, and a dedicated resolver is used
+	checker := graph.NewLocalChecker(
+		graph.WithMaxConcurrentReads(q.maxConcurrentReads),
+		graph.WithAssumptions(req.Assumptions...),
+	)
+	defer checker.Close()
+
+	ctx = storage.ContextWithRelationshipTupleReader(ctx,
+		storagewrappers.NewBoundedConcurrencyTupleReader(
+			storagewrappers.NewCombinedTupleReader(q.tupleReader, req.ContextualTuples),
+			q.maxConcurrentReads,
+		),
+	)
+
+	resp, err := checker.ResolveCheck(ctx, &graph.ResolveCheckRequest{
+		StoreID:              req.StoreID,
+		AuthorizationModelID: typesys.GetAuthorizationModelID(),
+		TupleKey:             tuple.NewTupleKey(req.TupleKey.GetObject(), req.TupleKey.GetRelation(), req.TupleKey.GetUser()),
+		ContextualTuples:     req.ContextualTuples,
+		Context:              req.Context,
+		RequestMetadata:      graph.NewCheckRequestMetadata(q.resolveNodeLimit),
+	})
+	if err != nil {
+		return nil, handleResolveCheckError(err)
+	}
+
+	return &HypotheticalCheckResponse{Allowed: resp.GetAllowed()}, nil
+}
+
+// validateAssumption returns nil if the object and the user of the assumption are defined by the model,
+// and the relation is defined for the type of the object.
+func validateAssumption(typesys *typesystem.TypeSystem, assumption *openfgav1.TupleKey) error {
+	if assumption.GetCondition() != nil {
+		return errors.New("assumptions cannot have a condition")
+	}
+	return validation.ValidateUserObjectRelation(typesys, assumption)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	openfgaErrors "github.com/openfga/openfga/internal/errors"
@@ -57,6 +58,11 @@ type ListObjectsQuery struct {
 	resolveNodeLimit        uint32
 	resolveNodeBreadthLimit uint32
 	maxConcurrentReads      uint32
+	sinceTime               time.Time
+
+	reverseExpansionParallelism uint32
+	reverseExpansionBackoff     *reverseexpand.OverloadBackoff
+	checkFallbackEnabled        bool
 
 	dispatchThrottlerConfig threshold.Config
 
@@ -134,6 +140,44 @@ func WithMaxConcurrentReads(limit uint32) ListObjectsQueryOption {
 	}
 }
 
+// WithReverseExpansionParallelism see server.WithReverseExpansionParallelism.
+func WithReverseExpansionParallelism(n uint32) ListObjectsQueryOption {
+	return func(d *ListObjectsQuery) {
+		d.reverseExpansionParallelism = n
+	}
+}
+
+// WithReverseExpansionBackoff sets the backoff of the reads of the reverse expansion, see
+// [reverseexpand.OverloadBackoff]. It's nil by default, i.e. the reads are never delayed.
+func WithReverseExpansionBackoff(b *reverseexpand.OverloadBackoff) ListObjectsQueryOption {
+	return func(d *ListObjectsQuery) {
+		d.reverseExpansionBackoff = b
+	}
+}
+
+// WithListObjectsSinceTime restricts the results to the objects that are reached by reverse expansion
+// through tuples on objects of the requested type written after the given time, e.g. to list the
+// objects recently shared with a user. It filters by the time access was granted on the objects,
+// according to the timestamps of their tuples, not by the time the objects were created. Only the
+// tuples on objects of the requested type are filtered: e.g. a document shared after the given time
+// with a group is returned even if the user joined the group before. Contextual tuples are always
+// considered, and so are older tuples when evaluating intersections and exclusions.
+func WithListObjectsSinceTime(since time.Time) ListObjectsQueryOption {
+	return func(d *ListObjectsQuery) {
+		d.sinceTime = since
+	}
+}
+
+// WithListObjectsCheckFallback allows listing the objects of the relations that involve an intersection or an
+// exclusion, whose objects found by reverse expansion must each be Checked, which can be very slow. If allowed,
+// which is the default, a warning is logged for these relations. If not, ListObjects on these relations fails
+// with a [typesystem.RelationNotListableError].
+func WithListObjectsCheckFallback(enabled bool) ListObjectsQueryOption {
+	return func(d *ListObjectsQuery) {
+		d.checkFallbackEnabled = enabled
+	}
+}
+
 func NewListObjectsQuery(
 	ds storage.RelationshipTupleReader,
 	checkResolver graph.CheckResolver,
@@ -152,6 +196,7 @@ func NewListObjectsQuery(
 		logger:                  logger.NewNoopLogger(),
 		listObjectsDeadline:     serverconfig.DefaultListObjectsDeadline,
 		listObjectsMaxResults:   serverconfig.DefaultListObjectsMaxResults,
+		checkFallbackEnabled:    serverconfig.DefaultListObjectsCheckFallback,
 		resolveNodeLimit:        serverconfig.DefaultResolveNodeLimit,
 		resolveNodeBreadthLimit: serverconfig.DefaultResolveNodeBreadthLimit,
 		maxConcurrentReads:      serverconfig.DefaultMaxConcurrentReadsForListObjects,
@@ -238,6 +283,21 @@ func (q *ListObjectsQuery) evaluate(
 		return serverErrors.HandleError("", err)
 	}
 
+	if err := relationListable(typesys, targetObjectType, targetRelation); err != nil {
+		var notListableErr *typesystem.RelationNotListableError
+		if !errors.As(err, &notListableErr) {
+			return serverErrors.HandleError("", err)
+		}
+		if !q.checkFallbackEnabled {
+			return serverErrors.RelationNotListable(err)
+		}
+		q.logger.WarnWithContext(ctx, "listing the objects of a relation that is not listable, every object found is checked",
+			zap.String("store_id", req.GetStoreId()),
+			zap.String("object_type", targetObjectType),
+			zap.String("relation", targetRelation),
+		)
+	}
+
 	if err := validation.ValidateUser(typesys, req.GetUser()); err != nil {
 		return serverErrors.ValidationError(fmt.Errorf("invalid 'user' value: %s", err))
 	}
@@ -275,13 +335,23 @@ func (q *ListObjectsQuery) evaluate(
 			req.GetContextualTuples().GetTupleKeys(),
 		)
 
+		reverseExpandDatastore := ds
+		if !q.sinceTime.IsZero() {
+			reverseExpandDatastore = storagewrappers.NewCombinedTupleReader(
+				storagewrappers.NewSinceTimeTupleReader(q.datastore, req.GetType(), q.sinceTime),
+				req.GetContextualTuples().GetTupleKeys(),
+			)
+		}
+
 		reverseExpandQuery := reverseexpand.NewReverseExpandQuery(
-			ds,
+			reverseExpandDatastore,
 			typesys,
 			reverseexpand.WithResolveNodeLimit(q.resolveNodeLimit),
 			reverseexpand.WithDispatchThrottlerConfig(q.dispatchThrottlerConfig),
 			reverseexpand.WithResolveNodeBreadthLimit(q.resolveNodeBreadthLimit),
 			reverseexpand.WithLogger(q.logger),
+			reverseexpand.WithReverseExpansionParallelism(q.reverseExpansionParallelism),
+			reverseexpand.WithOverloadBackoff(q.reverseExpansionBackoff),
 		)
 
 		cancelCtx, cancel := context.WithCancel(ctx)
@@ -397,6 +467,23 @@ func (q *ListObjectsQuery) evaluate(
 	return nil
 }
 
+// relationListable returns a [typesystem.RelationNotListableError] if the objects of the relation found by
+// reverse expansion must be Checked, which is the case if the relation involves an intersection or an exclusion.
+func relationListable(typesys *typesystem.TypeSystem, objectType, relation string) error {
+	intersection, err := typesys.RelationInvolvesIntersection(objectType, relation)
+	if err != nil {
+		return err
+	}
+	exclusion, err := typesys.RelationInvolvesExclusion(objectType, relation)
+	if err != nil {
+		return err
+	}
+	if intersection || exclusion {
+		return &typesystem.RelationNotListableError{ObjectType: objectType, Relation: relation}
+	}
+	return nil
+}
+
 func trySendObject(object string, objectsFound *atomic.Uint32, maxResults uint32, resultsChan chan<- ListObjectsResult) {
 	if !(maxResults == 0) {
 		if objectsFound.Add(1) > maxResults {
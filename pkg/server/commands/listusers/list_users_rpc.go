@@ -959,12 +959,7 @@ func tupleConditionMet(ctx context.Context, reqCtx *structpb.Struct, typesys *ty
 	}
 
 	if len(condEvalResult.MissingParameters) > 0 {
-		return false, condition.NewEvaluationError(
-			t.GetCondition().GetName(),
-			fmt.Errorf("tuple '%s' is missing context parameters '%v'",
-				tuple.TupleKeyToString(t),
-				condEvalResult.MissingParameters),
-		)
+		return eval.HandleMissingParameters(ctx, t, condEvalResult.MissingParameters)
 	}
 
 	return condEvalResult.ConditionMet, nil
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/openfga/openfga/internal/graph"
+	serverconfig "github.com/openfga/openfga/internal/server/config"
+	"github.com/openfga/openfga/internal/validation"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// MinimalGrantingSetRequest is a Check whose minimal granting set of tuples is computed.
+type MinimalGrantingSetRequest struct {
+	StoreID          string
+	TupleKey         *openfgav1.TupleKey
+	ContextualTuples []*openfgav1.TupleKey
+	Context          *structpb.Struct
+}
+
+type MinimalGrantingSetResponse struct {
+	// Tuples are the tuples the Check depends on. Contextual tuples of the request are included if the
+	// Check depends on them.
+	Tuples []*openfgav1.TupleKey
+}
+
+// MinimalGrantingSetCommand computes, for a Check that is allowed, a minimal set of tuples that is
+// enough on its own to allow it: removing any one of them makes the Check no longer allowed, at least
+// along the path that decided it. Checks allowed through several paths have several minimal sets,
+// and which one is returned depends on the paths that were resolved first.
+//
+// The tuples read while resolving the Check are the candidates, and each of them is dropped in turn
+// if the Check is still allowed without it, so Execute resolves the Check once per candidate.
+type MinimalGrantingSetCommand struct {
+	tupleReader        storage.RelationshipTupleReader
+	resolveNodeLimit   uint32
+	maxConcurrentReads uint32
+}
+
+type MinimalGrantingSetCmdOption func(*MinimalGrantingSetCommand)
+
+// WithMinimalGrantingSetResolveNodeLimit see server.WithResolveNodeLimit.
+func WithMinimalGrantingSetResolveNodeLimit(limit uint32) MinimalGrantingSetCmdOption {
+	return func(c *MinimalGrantingSetCommand) {
+		c.resolveNodeLimit = limit
+	}
+}
+
+// WithMinimalGrantingSetMaxConcurrentReads see server.WithMaxConcurrentReadsForCheck.
+func WithMinimalGrantingSetMaxConcurrentReads(limit uint32) MinimalGrantingSetCmdOption {
+	return func(c *MinimalGrantingSetCommand) {
+		c.maxConcurrentReads = limit
+	}
+}
+
+func NewMinimalGrantingSetCommand(tupleReader storage.RelationshipTupleReader, opts ...MinimalGrantingSetCmdOption) *MinimalGrantingSetCommand {
+	cmd := &MinimalGrantingSetCommand{
+		tupleReader:        tupleReader,
+		resolveNodeLimit:   serverconfig.DefaultResolveNodeLimit,
+		maxConcurrentReads: serverconfig.DefaultMaxConcurrentReadsForCheck,
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Execute computes the minimal granting set of the Check of the request against the authorization model
+// in the context. It returns a validation error if the Check isn't allowed.
+func (c *MinimalGrantingSetCommand) Execute(ctx context.Context, req *MinimalGrantingSetRequest) (*MinimalGrantingSetResponse, error) {
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return nil, serverErrors.HandleError("", fmt.Errorf("typesystem missing in context"))
+	}
+
+	if err := validation.ValidateUserObjectRelation(typesys, req.TupleKey); err != nil {
+		return nil, serverErrors.ValidationError(err)
+	}
+
+	for _, ctxTuple := range req.ContextualTuples {
+		if err := validation.ValidateTuple(typesys, ctxTuple); err != nil {
+			return nil, serverErrors.HandleTupleValidateError(err)
+		}
+	}
+
+	// the candidates are all the tuples returned to the resolver while it decided the Check. The resolver can
+	// return before all its reads are done, so the tuples read after it returned are ignored.
+	var mu sync.Mutex
+	candidates := map[string]*openfgav1.TupleKey{}
+	decided := false
+	allowed, err := c.check(ctx, typesys, req, func(t *openfgav1.Tuple) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if !decided {
+			candidates[tuple.TupleKeyToString(t.GetKey())] = t.GetKey()
+		}
+		return true
+	})
+
+	mu.Lock()
+	decided = true
+	mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, serverErrors.ValidationError(errors.New("the check is not allowed, so it has no granting tuples"))
+	}
+
+	keys := make([]string, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	granting := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		granting[key] = struct{}{}
+	}
+
+	for _, key := range keys {
+		// the reads of the Check can outlive it, so each Check reads its own set
+		subset := maps.Clone(granting)
+		delete(subset, key)
+
+		allowed, err := c.check(ctx, typesys, req, func(t *openfgav1.Tuple) bool {
+			_, ok := subset[tuple.TupleKeyToString(t.GetKey())]
+			return ok
+		})
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			granting = subset
+		}
+	}
+
+	tuples := make([]*openfgav1.TupleKey, 0, len(granting))
+	for _, key := range keys {
+		if _, ok := granting[key]; ok {
+			tuples = append(tuples, candidates[key])
+		}
+	}
+
+	return &MinimalGrantingSetResponse{Tuples: tuples}, nil
+}
+
+// check resolves the Check of the request as if the only tuples of the store, including the contextual
+// tuples, were the ones for which keep returns true.
+func (c *MinimalGrantingSetCommand) check(
+	ctx context.Context,
+	typesys *typesystem.TypeSystem,
+	req *MinimalGrantingSetRequest,
+	keep func(*openfgav1.Tuple) bool,
+) (bool, error) {
+	// the results of Checks on a subset of the tuples must not be cached, so a dedicated resolver is used
+	checker := graph.NewLocalChecker(graph.WithMaxConcurrentReads(c.maxConcurrentReads))
+	defer checker.Close()
+
+	ctx = storage.ContextWithRelationshipTupleReader(ctx,
+		storagewrappers.NewBoundedConcurrencyTupleReader(
+			storagewrappers.NewFilteredTupleReader(
+				storagewrappers.NewCombinedTupleReader(c.tupleReader, req.ContextualTuples),
+				keep,
+			),
+			c.maxConcurrentReads,
+		),
+	)
+
+	resp, err := checker.ResolveCheck(ctx, &graph.ResolveCheckRequest{
+		StoreID:              req.StoreID,
+		AuthorizationModelID: typesys.GetAuthorizationModelID(),
+		TupleKey:             tuple.NewTupleKey(req.TupleKey.GetObject(), req.TupleKey.GetRelation(), req.TupleKey.GetUser()),
+		ContextualTuples:     req.ContextualTuples,
+		Context:              req.Context,
+		RequestMetadata:      graph.NewCheckRequestMetadata(c.resolveNodeLimit),
+	})
+	if err != nil {
+		return false, handleResolveCheckError(err)
+	}
+	return resp.GetAllowed(), nil
+}
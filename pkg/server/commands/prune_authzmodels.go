@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/pkg/logger"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+type PruneAuthorizationModelsRequest struct {
+	StoreID string
+	// Retain is the number of most recent authorization models of the store to keep. It must be at least 1.
+	Retain int
+}
+
+type PruneAuthorizationModelsResponse struct {
+	// Pruned is the number of authorization models that were deleted.
+	Pruned int
+}
+
+// PruneAuthorizationModelsCommand deletes the old authorization models of a store, e.g. for stores whose
+// models are written by a CI pipeline on every deployment. The latest model, the models that have assertions
+// and the models that are in use are never deleted.
+type PruneAuthorizationModelsCommand struct {
+	pruner storage.AuthorizationModelPruner
+	logger logger.Logger
+	inUse  func(storeID string) []string
+}
+
+type PruneAuthorizationModelsCmdOption func(*PruneAuthorizationModelsCommand)
+
+func WithPruneAuthorizationModelsCmdLogger(l logger.Logger) PruneAuthorizationModelsCmdOption {
+	return func(c *PruneAuthorizationModelsCommand) {
+		c.logger = l
+	}
+}
+
+// WithPruneAuthorizationModelsInUse sets the function that returns the IDs of the authorization models of a
+// store that requests are pinned to, which are kept. It's best-effort: a request pinned to a model after the
+// function returns may still fail because the model was deleted.
+func WithPruneAuthorizationModelsInUse(inUse func(storeID string) []string) PruneAuthorizationModelsCmdOption {
+	return func(c *PruneAuthorizationModelsCommand) {
+		c.inUse = inUse
+	}
+}
+
+func NewPruneAuthorizationModelsCommand(pruner storage.AuthorizationModelPruner, opts ...PruneAuthorizationModelsCmdOption) *PruneAuthorizationModelsCommand {
+	cmd := &PruneAuthorizationModelsCommand{
+		pruner: pruner,
+		logger: logger.NewNoopLogger(),
+		inUse:  func(string) []string { return nil },
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+func (c *PruneAuthorizationModelsCommand) Execute(ctx context.Context, req *PruneAuthorizationModelsRequest) (*PruneAuthorizationModelsResponse, error) {
+	if req.Retain < 1 {
+		return nil, serverErrors.ValidationError(fmt.Errorf("the number of authorization models to retain must be at least 1, got %d", req.Retain))
+	}
+
+	pruned, err := c.pruner.PruneAuthorizationModels(ctx, req.StoreID, req.Retain, c.inUse(req.StoreID))
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	c.logger.InfoWithContext(ctx, "pruned authorization models",
+		zap.String("store_id", req.StoreID),
+		zap.Int("retain", req.Retain),
+		zap.Int("pruned", pruned),
+	)
+
+	return &PruneAuthorizationModelsResponse{Pruned: pruned}, nil
+}
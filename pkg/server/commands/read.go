@@ -19,11 +19,15 @@ import (
 // a given object ID or userset in a type, optionally
 // constrained by a relation name.
 type ReadQuery struct {
-	datastore storage.OpenFGADatastore
-	logger    logger.Logger
-	encoder   encoder.Encoder
+	datastore     storage.OpenFGADatastore
+	logger        logger.Logger
+	encoder       encoder.Encoder
+	storeSettings storage.StoreSettingsBackend
 }
 
+// maxReadPageSize is the largest page_size of a ReadRequest.
+const maxReadPageSize = 100
+
 type ReadQueryOption func(*ReadQuery)
 
 func WithReadQueryLogger(l logger.Logger) ReadQueryOption {
@@ -38,6 +42,14 @@ func WithReadQueryEncoder(e encoder.Encoder) ReadQueryOption {
 	}
 }
 
+// WithReadQueryStoreSettings sets the backend of the store settings. Reads that don't set a page size use
+// the default page size of their store, if any, up to the largest page size of a Read.
+func WithReadQueryStoreSettings(backend storage.StoreSettingsBackend) ReadQueryOption {
+	return func(rq *ReadQuery) {
+		rq.storeSettings = backend
+	}
+}
+
 // NewReadQuery creates a ReadQuery using the provided OpenFGA datastore implementation.
 func NewReadQuery(datastore storage.OpenFGADatastore, opts ...ReadQueryOption) *ReadQuery {
 	rq := &ReadQuery{
@@ -73,8 +85,17 @@ func (q *ReadQuery) Execute(ctx context.Context, req *openfgav1.ReadRequest) (*o
 		return nil, serverErrors.InvalidContinuationToken
 	}
 
+	pageSize := req.GetPageSize().GetValue()
+	if pageSize == 0 && q.storeSettings != nil {
+		settings, err := q.storeSettings.ReadStoreSettings(ctx, store)
+		if err != nil {
+			return nil, serverErrors.HandleError("", err)
+		}
+		pageSize = min(settings.DefaultPageSize, maxReadPageSize)
+	}
+
 	opts := storage.ReadPageOptions{
-		Pagination: storage.NewPaginationOptions(req.GetPageSize().GetValue(), string(decodedContToken)),
+		Pagination: storage.NewPaginationOptions(pageSize, string(decodedContToken)),
 	}
 	tuples, contToken, err := q.datastore.ReadPage(ctx, store, tupleUtils.ConvertReadRequestTupleKeyToTupleKey(tk), opts)
 	if err != nil {
@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -13,8 +14,9 @@ import (
 
 // ReadAuthorizationModelQuery retrieves a single type definition from a storage backend.
 type ReadAuthorizationModelQuery struct {
-	backend storage.AuthorizationModelReadBackend
-	logger  logger.Logger
+	backend           storage.AuthorizationModelReadBackend
+	assertionsBackend storage.AssertionsBackend
+	logger            logger.Logger
 }
 
 type ReadAuthModelQueryOption func(*ReadAuthorizationModelQuery)
@@ -25,6 +27,14 @@ func WithReadAuthModelQueryLogger(l logger.Logger) ReadAuthModelQueryOption {
 	}
 }
 
+// WithReadAuthModelQueryAssertions sets the backend from which ExecuteWithAssertions reads the assertions
+// of the model.
+func WithReadAuthModelQueryAssertions(backend storage.AssertionsBackend) ReadAuthModelQueryOption {
+	return func(m *ReadAuthorizationModelQuery) {
+		m.assertionsBackend = backend
+	}
+}
+
 func NewReadAuthorizationModelQuery(backend storage.AuthorizationModelReadBackend, opts ...ReadAuthModelQueryOption) *ReadAuthorizationModelQuery {
 	m := &ReadAuthorizationModelQuery{
 		backend: backend,
@@ -50,3 +60,33 @@ func (q *ReadAuthorizationModelQuery) Execute(ctx context.Context, req *openfgav
 		AuthorizationModel: azm,
 	}, nil
 }
+
+// ReadAuthorizationModelWithAssertionsResponse is a model along with the assertions written for it.
+type ReadAuthorizationModelWithAssertionsResponse struct {
+	AuthorizationModel *openfgav1.AuthorizationModel
+	Assertions         []*openfgav1.Assertion
+}
+
+// ExecuteWithAssertions is like Execute, but also returns the assertions of the store for the returned model,
+// saving the round trip of a separate ReadAssertions. It requires WithReadAuthModelQueryAssertions.
+func (q *ReadAuthorizationModelQuery) ExecuteWithAssertions(ctx context.Context, req *openfgav1.ReadAuthorizationModelRequest) (*ReadAuthorizationModelWithAssertionsResponse, error) {
+	if q.assertionsBackend == nil {
+		return nil, serverErrors.HandleError("", fmt.Errorf("assertions backend missing"))
+	}
+
+	resp, err := q.Execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the assertions are keyed to the id of the model that was read
+	assertions, err := q.assertionsBackend.ReadAssertions(ctx, req.GetStoreId(), resp.GetAuthorizationModel().GetId())
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	return &ReadAuthorizationModelWithAssertionsResponse{
+		AuthorizationModel: resp.GetAuthorizationModel(),
+		Assertions:         assertions,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package reverseexpand
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// minOverloadBackoffDelay is the delay that an OverloadBackoff starts backing off with.
+const minOverloadBackoffDelay = time.Millisecond
+
+// OverloadBackoff delays the ReadStartingWithUser calls of reverse expansions while the datastore shows signs
+// of overload, i.e. reads that fail or that take longer than a threshold. The delay doubles on every sign of
+// overload, up to a maximum, and halves on every read that completes in time. It's meant to be shared by all
+// the reverse expansions of a server, since they share the datastore.
+type OverloadBackoff struct {
+	latencyThreshold time.Duration
+	maxDelay         time.Duration
+
+	// delay is the current delay, in nanoseconds.
+	delay atomic.Int64
+}
+
+// NewOverloadBackoff returns an OverloadBackoff that backs off when a read fails or takes longer than
+// latencyThreshold, by up to maxDelay. If latencyThreshold is 0, only failed reads are signs of overload.
+func NewOverloadBackoff(latencyThreshold, maxDelay time.Duration) *OverloadBackoff {
+	return &OverloadBackoff{
+		latencyThreshold: latencyThreshold,
+		maxDelay:         maxDelay,
+	}
+}
+
+// Delay returns the current delay before each read.
+func (b *OverloadBackoff) Delay() time.Duration {
+	return time.Duration(b.delay.Load())
+}
+
+// wait blocks for the current delay, or until ctx is done.
+func (b *OverloadBackoff) wait(ctx context.Context) error {
+	delay := b.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observe adjusts the delay after a read that took latency and failed with err, if not nil.
+func (b *OverloadBackoff) observe(latency time.Duration, err error) {
+	// a request that is cancelled or that times out is not a sign of overload, but its latency can be
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		err = nil
+	}
+
+	overloaded := err != nil || (b.latencyThreshold > 0 && latency > b.latencyThreshold)
+	for {
+		current := b.delay.Load()
+		next := current / 2
+		if overloaded {
+			next = min(max(2*current, int64(minOverloadBackoffDelay)), int64(b.maxDelay))
+		} else if next < int64(minOverloadBackoffDelay) {
+			next = 0
+		}
+		if next == current || b.delay.CompareAndSwap(current, next) {
+			return
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"go.opentelemetry.io/otel"
@@ -16,7 +17,6 @@ import (
 
 	"github.com/openfga/openfga/internal/concurrency"
 
-	"github.com/openfga/openfga/internal/condition"
 	"github.com/openfga/openfga/internal/condition/eval"
 	"github.com/openfga/openfga/internal/graph"
 	serverconfig "github.com/openfga/openfga/internal/server/config"
@@ -116,6 +116,8 @@ type ReverseExpandQuery struct {
 	typesystem              *typesystem.TypeSystem
 	resolveNodeLimit        uint32
 	resolveNodeBreadthLimit uint32
+	parallelism             uint32
+	overloadBackoff         *OverloadBackoff
 
 	dispatchThrottlerConfig threshold.Config
 
@@ -145,6 +147,21 @@ func WithResolveNodeBreadthLimit(limit uint32) ReverseExpandQueryOption {
 	}
 }
 
+// WithReverseExpansionParallelism sets the maximum number of objects found by a ReadStartingWithUser call
+// that are expanded concurrently. If 0, the resolve node breadth limit is used.
+func WithReverseExpansionParallelism(n uint32) ReverseExpandQueryOption {
+	return func(d *ReverseExpandQuery) {
+		d.parallelism = n
+	}
+}
+
+// WithOverloadBackoff delays the ReadStartingWithUser calls while b detects that the datastore is overloaded.
+func WithOverloadBackoff(b *OverloadBackoff) ReverseExpandQueryOption {
+	return func(d *ReverseExpandQuery) {
+		d.overloadBackoff = b
+	}
+}
+
 func NewReverseExpandQuery(ds storage.RelationshipTupleReader, ts *typesystem.TypeSystem, opts ...ReverseExpandQueryOption) *ReverseExpandQuery {
 	query := &ReverseExpandQuery{
 		logger:                  logger.NewNoopLogger(),
@@ -489,7 +506,14 @@ func (c *ReverseExpandQuery) readTuplesAndExecute(
 
 	combinedTupleReader := storagewrappers.NewCombinedTupleReader(c.datastore, req.ContextualTuples)
 
+	if c.overloadBackoff != nil {
+		if err := c.overloadBackoff.wait(ctx); err != nil {
+			return err
+		}
+	}
+
 	// find all tuples of the form req.edge.TargetReference.Type:...#relationFilter@userFilter
+	start := time.Now()
 	iter, err := combinedTupleReader.ReadStartingWithUser(ctx, req.StoreID, storage.ReadStartingWithUserFilter{
 		ObjectType: req.edge.TargetReference.GetType(),
 		Relation:   relationFilter,
@@ -500,6 +524,9 @@ func (c *ReverseExpandQuery) readTuplesAndExecute(
 		},
 	})
 	atomic.AddUint32(resolutionMetadata.DatastoreQueryCount, 1)
+	if c.overloadBackoff != nil {
+		c.overloadBackoff.observe(time.Since(start), err)
+	}
 	if err != nil {
 		return err
 	}
@@ -511,7 +538,11 @@ func (c *ReverseExpandQuery) readTuplesAndExecute(
 	)
 	defer filteredIter.Stop()
 
-	pool := concurrency.NewPool(ctx, int(c.resolveNodeBreadthLimit))
+	parallelism := c.parallelism
+	if parallelism == 0 {
+		parallelism = c.resolveNodeBreadthLimit
+	}
+	pool := concurrency.NewPool(ctx, int(parallelism))
 
 	var errs error
 
@@ -532,16 +563,16 @@ LoopOnIterator:
 			continue
 		}
 
-		if !condEvalResult.ConditionMet {
-			if len(condEvalResult.MissingParameters) > 0 {
-				errs = errors.Join(errs, condition.NewEvaluationError(
-					tk.GetCondition().GetName(),
-					fmt.Errorf("tuple '%s' is missing context parameters '%v'",
-						tuple.TupleKeyToString(tk),
-						condEvalResult.MissingParameters),
-				))
+		conditionMet := condEvalResult.ConditionMet
+		if len(condEvalResult.MissingParameters) > 0 {
+			conditionMet, err = eval.HandleMissingParameters(ctx, tk, condEvalResult.MissingParameters)
+			if err != nil {
+				errs = errors.Join(errs, err)
+				continue
 			}
+		}
 
+		if !conditionMet {
 			continue
 		}
 
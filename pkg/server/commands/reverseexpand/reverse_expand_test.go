@@ -0,0 +1,213 @@
+package reverseexpand
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// slowReadStartingWithUserDatastore delays every ReadStartingWithUser, and records how many are in flight at most.
+type slowReadStartingWithUserDatastore struct {
+	storage.RelationshipTupleReader
+	latency     time.Duration
+	err         error
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (s *slowReadStartingWithUserDatastore) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	inFlight := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		current := s.maxInFlight.Load()
+		if inFlight <= current || s.maxInFlight.CompareAndSwap(current, inFlight) {
+			break
+		}
+	}
+
+	time.Sleep(s.latency)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+}
+
+// setupGroupsOfUser writes a store where user:anne is a member of numGroups groups, each of which can view a document.
+func setupGroupsOfUser(t testing.TB, numGroups int) (storage.OpenFGADatastore, string, *typesystem.TypeSystem) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [group#member]`)
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	var tuples []*openfgav1.TupleKey
+	for i := 0; i < numGroups; i++ {
+		tuples = append(tuples,
+			tuple.NewTupleKey(fmt.Sprintf("group:%d", i), "member", "user:anne"),
+			tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", fmt.Sprintf("group:%d#member", i)),
+		)
+	}
+	for start := 0; start < len(tuples); start += ds.MaxTuplesPerWrite() {
+		end := min(start+ds.MaxTuplesPerWrite(), len(tuples))
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, tuples[start:end]))
+	}
+
+	return ds, storeID, typesystem.New(model)
+}
+
+func executeReverseExpand(ctx context.Context, q *ReverseExpandQuery, storeID string) (int, error) {
+	resultChan := make(chan *ReverseExpandResult)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- q.Execute(ctx, &ReverseExpandRequest{
+			StoreID:    storeID,
+			ObjectType: "document",
+			Relation:   "viewer",
+			User: &UserRefObject{
+				Object: &openfgav1.Object{Type: "user", Id: "anne"},
+			},
+		}, resultChan, NewResolutionMetadata())
+	}()
+
+	results := 0
+	for {
+		select {
+		case err := <-errChan:
+			if err != nil {
+				return 0, err
+			}
+		case _, ok := <-resultChan:
+			if !ok {
+				return results, nil
+			}
+			results++
+		}
+	}
+}
+
+func TestReverseExpansionParallelism(t *testing.T) {
+	const numGroups = 20
+	ds, storeID, typesys := setupGroupsOfUser(t, numGroups)
+
+	for _, parallelism := range []uint32{1, 3} {
+		t.Run(fmt.Sprintf("parallelism_%d", parallelism), func(t *testing.T) {
+			slowDS := &slowReadStartingWithUserDatastore{RelationshipTupleReader: ds, latency: 5 * time.Millisecond}
+
+			q := NewReverseExpandQuery(slowDS, typesys, WithReverseExpansionParallelism(parallelism))
+			results, err := executeReverseExpand(context.Background(), q, storeID)
+			require.NoError(t, err)
+			require.Equal(t, numGroups, results)
+			require.Equal(t, int32(parallelism), slowDS.maxInFlight.Load())
+		})
+	}
+
+	t.Run("defaults_to_the_resolve_node_breadth_limit", func(t *testing.T) {
+		slowDS := &slowReadStartingWithUserDatastore{RelationshipTupleReader: ds, latency: 5 * time.Millisecond}
+
+		q := NewReverseExpandQuery(slowDS, typesys, WithResolveNodeBreadthLimit(2))
+		results, err := executeReverseExpand(context.Background(), q, storeID)
+		require.NoError(t, err)
+		require.Equal(t, numGroups, results)
+		require.Equal(t, int32(2), slowDS.maxInFlight.Load())
+	})
+}
+
+func TestOverloadBackoff(t *testing.T) {
+	t.Run("backs_off_on_slow_reads_then_recovers", func(t *testing.T) {
+		ds, storeID, typesys := setupGroupsOfUser(t, 2)
+		backoff := NewOverloadBackoff(time.Millisecond, 4*time.Millisecond)
+
+		slowDS := &slowReadStartingWithUserDatastore{RelationshipTupleReader: ds, latency: 2 * time.Millisecond}
+		_, err := executeReverseExpand(context.Background(), NewReverseExpandQuery(slowDS, typesys, WithOverloadBackoff(backoff)), storeID)
+		require.NoError(t, err)
+		// 3 slow reads double the delay up to its maximum
+		require.Equal(t, 4*time.Millisecond, backoff.Delay())
+
+		_, err = executeReverseExpand(context.Background(), NewReverseExpandQuery(ds, typesys, WithOverloadBackoff(backoff)), storeID)
+		require.NoError(t, err)
+		// 3 fast reads halve the delay down to 0
+		require.Zero(t, backoff.Delay())
+	})
+
+	t.Run("backs_off_on_errors", func(t *testing.T) {
+		ds, storeID, typesys := setupGroupsOfUser(t, 2)
+		backoff := NewOverloadBackoff(0, time.Second)
+
+		errOverloaded := errors.New("too many connections")
+		failingDS := &slowReadStartingWithUserDatastore{RelationshipTupleReader: ds, err: errOverloaded}
+		_, err := executeReverseExpand(context.Background(), NewReverseExpandQuery(failingDS, typesys, WithOverloadBackoff(backoff)), storeID)
+		require.ErrorIs(t, err, errOverloaded)
+		require.Equal(t, minOverloadBackoffDelay, backoff.Delay())
+	})
+
+	t.Run("cancellations_are_not_overload", func(t *testing.T) {
+		backoff := NewOverloadBackoff(time.Second, time.Second)
+		backoff.observe(time.Millisecond, context.Canceled)
+		require.Zero(t, backoff.Delay())
+	})
+
+	t.Run("disabled_without_max_delay", func(t *testing.T) {
+		backoff := NewOverloadBackoff(time.Millisecond, 0)
+		backoff.observe(time.Second, errors.New("error"))
+		require.Zero(t, backoff.Delay())
+	})
+
+	t.Run("wait_respects_the_context", func(t *testing.T) {
+		backoff := NewOverloadBackoff(time.Millisecond, time.Hour)
+		for i := 0; i < 30; i++ {
+			backoff.observe(time.Second, nil)
+		}
+		require.Equal(t, time.Hour, backoff.Delay())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, backoff.wait(ctx), context.Canceled)
+	})
+}
+
+// BenchmarkReverseExpansionParallelism compares the throughput of the reverse expansion of a user that is a member
+// of many groups, with a datastore whose reads take 100µs, for various values of the parallelism.
+func BenchmarkReverseExpansionParallelism(b *testing.B) {
+	const numGroups = 100
+	ds, storeID, typesys := setupGroupsOfUser(b, numGroups)
+	slowDS := &slowReadStartingWithUserDatastore{RelationshipTupleReader: ds, latency: 100 * time.Microsecond}
+
+	for _, parallelism := range []uint32{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("parallelism_%d", parallelism), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				q := NewReverseExpandQuery(slowDS, typesys, WithReverseExpansionParallelism(parallelism))
+				results, err := executeReverseExpand(context.Background(), q, storeID)
+				require.NoError(b, err)
+				require.Equal(b, numGroups, results)
+			}
+		})
+	}
+}
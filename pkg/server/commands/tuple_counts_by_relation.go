@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"sort"
+
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// tupleCountsByRelationPageSize is the page size used to read the tuples of a store when the
+// datastore can't count them natively.
+const tupleCountsByRelationPageSize = 100
+
+type TupleCountsByRelationRequest struct {
+	StoreID string
+	// Approximate allows the counts to be estimated from a sample of the tuples of the store, see
+	// [storage.TupleCountsByRelationOptions].
+	Approximate bool
+}
+
+type TupleCountsByRelationResponse struct {
+	// Counts are sorted by object type and relation.
+	Counts []storage.RelationTupleCount
+	// Approximate is true if the counts may have been estimated.
+	Approximate bool
+}
+
+// TupleCountsByRelationQuery counts the tuples of a store for every object type and relation, e.g. to
+// find out which relations dominate the storage of a store.
+type TupleCountsByRelationQuery struct {
+	tupleReader storage.RelationshipTupleReader
+}
+
+type TupleCountsByRelationQueryOption func(*TupleCountsByRelationQuery)
+
+// NewTupleCountsByRelationQuery returns a query that counts the tuples with the datastore if it implements
+// [storage.TupleCountsByRelationReader], and otherwise by reading every tuple of the store.
+func NewTupleCountsByRelationQuery(tupleReader storage.RelationshipTupleReader, opts ...TupleCountsByRelationQueryOption) *TupleCountsByRelationQuery {
+	q := &TupleCountsByRelationQuery{
+		tupleReader: tupleReader,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+func (q *TupleCountsByRelationQuery) Execute(ctx context.Context, req *TupleCountsByRelationRequest) (*TupleCountsByRelationResponse, error) {
+	var counts []storage.RelationTupleCount
+	var err error
+	approximate := false
+
+	if reader, ok := q.tupleReader.(storage.TupleCountsByRelationReader); ok {
+		counts, err = reader.ReadTupleCountsByRelation(ctx, req.StoreID, storage.TupleCountsByRelationOptions{
+			Approximate: req.Approximate,
+		})
+		approximate = req.Approximate
+	} else {
+		counts, err = q.countByReading(ctx, req.StoreID)
+	}
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].ObjectType != counts[j].ObjectType {
+			return counts[i].ObjectType < counts[j].ObjectType
+		}
+		return counts[i].Relation < counts[j].Relation
+	})
+
+	return &TupleCountsByRelationResponse{Counts: counts, Approximate: approximate}, nil
+}
+
+// countByReading counts the tuples of the store by reading all of them page by page.
+func (q *TupleCountsByRelationQuery) countByReading(ctx context.Context, store string) ([]storage.RelationTupleCount, error) {
+	indexes := map[string]int{}
+	var counts []storage.RelationTupleCount
+
+	from := ""
+	for {
+		tuples, contToken, err := q.tupleReader.ReadPage(ctx, store, nil, storage.ReadPageOptions{
+			Pagination: storage.NewPaginationOptions(tupleCountsByRelationPageSize, from),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range tuples {
+			objectType := tupleUtils.GetType(t.GetKey().GetObject())
+			relation := t.GetKey().GetRelation()
+
+			key := tupleUtils.ToObjectRelationString(objectType, relation)
+			i, ok := indexes[key]
+			if !ok {
+				i = len(counts)
+				indexes[key] = i
+				counts = append(counts, storage.RelationTupleCount{ObjectType: objectType, Relation: relation})
+			}
+			counts[i].Count++
+		}
+
+		if len(contToken) == 0 {
+			return counts, nil
+		}
+		from = string(contToken)
+	}
+}
@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openfga/openfga/internal/graph"
+	serverconfig "github.com/openfga/openfga/internal/server/config"
+	"github.com/openfga/openfga/internal/validation"
+	"github.com/openfga/openfga/pkg/logger"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+const (
+	defaultWarmCacheMaxConcurrentChecks = 10
+	defaultWarmCacheMaxChanges          = 100
+)
+
+// WarmCacheRequest selects the Checks to run to warm the Check cache of a store and authorization model.
+type WarmCacheRequest struct {
+	StoreID              string
+	AuthorizationModelID string
+
+	// TupleKeys are the Checks to run. If empty, the tuples written most recently to the store,
+	// as returned by ReadChanges, are checked instead.
+	TupleKeys []*openfgav1.TupleKey
+}
+
+type WarmCacheResponse struct {
+	// Warmed is the number of Checks that were resolved, and whose results are now cached.
+	Warmed int
+}
+
+// WarmCacheCommand runs a set of representative Checks so that their results are cached before
+// the actual Checks are received, e.g. after writing a new authorization model. Checks are
+// cached without contextual tuples or context, so only Checks without them benefit from warming.
+type WarmCacheCommand struct {
+	tupleReader         storage.RelationshipTupleReader
+	changelogBackend    storage.ChangelogBackend
+	checkResolver       graph.CheckResolver
+	logger              logger.Logger
+	resolveNodeLimit    uint32
+	maxConcurrentReads  uint32
+	maxConcurrentChecks uint32
+	maxChanges          int
+}
+
+type WarmCacheCmdOption func(*WarmCacheCommand)
+
+func WithWarmCacheCmdLogger(l logger.Logger) WarmCacheCmdOption {
+	return func(c *WarmCacheCommand) {
+		c.logger = l
+	}
+}
+
+// WithWarmCacheResolveNodeLimit see server.WithResolveNodeLimit.
+func WithWarmCacheResolveNodeLimit(limit uint32) WarmCacheCmdOption {
+	return func(c *WarmCacheCommand) {
+		c.resolveNodeLimit = limit
+	}
+}
+
+// WithWarmCacheMaxConcurrentReads see server.WithMaxConcurrentReadsForCheck. The limit applies
+// to all the Checks run by an Execute call combined.
+func WithWarmCacheMaxConcurrentReads(limit uint32) WarmCacheCmdOption {
+	return func(c *WarmCacheCommand) {
+		c.maxConcurrentReads = limit
+	}
+}
+
+// WithWarmCacheMaxConcurrentChecks sets the maximum number of Checks that are run at the same time.
+func WithWarmCacheMaxConcurrentChecks(limit uint32) WarmCacheCmdOption {
+	return func(c *WarmCacheCommand) {
+		c.maxConcurrentChecks = limit
+	}
+}
+
+// WithWarmCacheMaxChanges sets the maximum number of recently written tuples that are checked when
+// the request doesn't specify tuple keys.
+func WithWarmCacheMaxChanges(n int) WarmCacheCmdOption {
+	return func(c *WarmCacheCommand) {
+		c.maxChanges = n
+	}
+}
+
+// NewWarmCacheCommand creates a WarmCacheCommand that runs Checks with checkResolver, which should be
+// the same CheckResolver used to serve Checks, reading the tuples from tupleReader.
+func NewWarmCacheCommand(
+	tupleReader storage.RelationshipTupleReader,
+	changelogBackend storage.ChangelogBackend,
+	checkResolver graph.CheckResolver,
+	opts ...WarmCacheCmdOption,
+) *WarmCacheCommand {
+	cmd := &WarmCacheCommand{
+		tupleReader:         tupleReader,
+		changelogBackend:    changelogBackend,
+		checkResolver:       checkResolver,
+		logger:              logger.NewNoopLogger(),
+		resolveNodeLimit:    serverconfig.DefaultResolveNodeLimit,
+		maxConcurrentReads:  serverconfig.DefaultMaxConcurrentReadsForCheck,
+		maxConcurrentChecks: defaultWarmCacheMaxConcurrentChecks,
+		maxChanges:          defaultWarmCacheMaxChanges,
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Execute runs the Checks of the request against the authorization model in the context, and returns once
+// all of them have been resolved. Checks that are invalid for the model or that fail to resolve are
+// logged and skipped.
+func (c *WarmCacheCommand) Execute(ctx context.Context, req *WarmCacheRequest) (*WarmCacheResponse, error) {
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return nil, serverErrors.HandleError("", fmt.Errorf("typesystem missing in context"))
+	}
+
+	tupleKeys := req.TupleKeys
+	if len(tupleKeys) == 0 {
+		var err error
+		tupleKeys, err = c.readRecentlyWrittenTuples(ctx, req.StoreID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = storage.ContextWithRelationshipTupleReader(ctx,
+		storagewrappers.NewBoundedConcurrencyTupleReader(c.tupleReader, c.maxConcurrentReads),
+	)
+
+	var warmed atomic.Int64
+
+	pool, ctx := errgroup.WithContext(ctx)
+	pool.SetLimit(int(c.maxConcurrentChecks))
+	for _, tk := range tupleKeys {
+		if err := validation.ValidateUserObjectRelation(typesys, tk); err != nil {
+			c.logger.WarnWithContext(ctx, "skipping invalid tuple key while warming the check cache",
+				zap.String("tuple_key", tuple.TupleKeyToString(tk)),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		pool.Go(func() error {
+			_, err := c.checkResolver.ResolveCheck(ctx, &graph.ResolveCheckRequest{
+				StoreID:              req.StoreID,
+				AuthorizationModelID: typesys.GetAuthorizationModelID(),
+				TupleKey:             tuple.NewTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+				RequestMetadata:      graph.NewCheckRequestMetadata(c.resolveNodeLimit),
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				c.logger.WarnWithContext(ctx, "failed to warm the check cache",
+					zap.String("tuple_key", tuple.TupleKeyToString(tk)),
+					zap.Error(err),
+				)
+				return nil
+			}
+			warmed.Add(1)
+			return nil
+		})
+	}
+
+	if err := pool.Wait(); err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	return &WarmCacheResponse{Warmed: int(warmed.Load())}, nil
+}
+
+// readRecentlyWrittenTuples returns the distinct tuples written by the last maxChanges changes of the store
+// that haven't been deleted since, most recent first.
+func (c *WarmCacheCommand) readRecentlyWrittenTuples(ctx context.Context, storeID string) ([]*openfgav1.TupleKey, error) {
+	// the most recent changes are read from the tail of the changelog, most recent first
+	changes, _, err := c.changelogBackend.ReadChanges(ctx, storeID, "", storage.ReadChangesOptions{
+		Pagination: storage.NewPaginationOptions(int32(c.maxChanges), ""),
+		SortDesc:   true,
+	}, 0)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	seen := make(map[string]struct{}, len(changes))
+	var tupleKeys []*openfgav1.TupleKey
+	for _, change := range changes {
+		tk := change.GetTupleKey()
+		key := tuple.TupleKeyToString(tk)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		if change.GetOperation() == openfgav1.TupleOperation_TUPLE_OPERATION_WRITE {
+			tupleKeys = append(tupleKeys, tk)
+		}
+	}
+	return tupleKeys, nil
+}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	serverconfig "github.com/openfga/openfga/internal/server/config"
+	"github.com/openfga/openfga/pkg/encoder"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const defaultWatchPageSize = 100
+
+// WatchRequest selects the tuple changes of a store to stream.
+type WatchRequest struct {
+	StoreID string
+	// ObjectType and Relation, if set, filter the changes to the tuples of the object type and relation.
+	ObjectType string
+	Relation   string
+	// ContinuationToken is the token of a change event, or of a heartbeat, to resume from. If empty, the changes are
+	// streamed from the start of the changelog of the store.
+	ContinuationToken string
+	// HeartbeatInterval, if positive, is the time without changes after which a heartbeat is emitted, e.g. so that
+	// the connection of a consumer of an idle store isn't closed by a proxy.
+	HeartbeatInterval time.Duration
+}
+
+// WatchEvent is a set of tuple changes, or a heartbeat if it has none.
+type WatchEvent struct {
+	Changes []*openfgav1.TupleChange
+	// ContinuationToken is the token to resume from after this event.
+	ContinuationToken string
+}
+
+// WatchCommand streams the tuple changes of a store as they are written, by polling its changelog. The next
+// changes are only read once the previous event was emitted, so a slow consumer delays the reads rather than
+// making the changes pile up in memory.
+type WatchCommand struct {
+	backend       storage.ChangelogBackend
+	encoder       encoder.Encoder
+	horizonOffset time.Duration
+	pollInterval  time.Duration
+	pageSize      int32
+}
+
+type WatchCmdOption func(*WatchCommand)
+
+func WithWatchEncoder(e encoder.Encoder) WatchCmdOption {
+	return func(c *WatchCommand) {
+		c.encoder = e
+	}
+}
+
+// WithWatchHorizonOffset see WithReadChangeQueryHorizonOffset.
+func WithWatchHorizonOffset(horizonOffset int) WatchCmdOption {
+	return func(c *WatchCommand) {
+		c.horizonOffset = time.Duration(horizonOffset) * time.Minute
+	}
+}
+
+// WithWatchPollInterval sets the interval between the reads of the changelog once its end was reached.
+func WithWatchPollInterval(interval time.Duration) WatchCmdOption {
+	return func(c *WatchCommand) {
+		c.pollInterval = interval
+	}
+}
+
+// WithWatchPageSize sets the maximum number of changes read from the changelog at once, and so the maximum number
+// of changes of an event.
+func WithWatchPageSize(pageSize int32) WatchCmdOption {
+	return func(c *WatchCommand) {
+		c.pageSize = pageSize
+	}
+}
+
+func NewWatchCommand(backend storage.ChangelogBackend, opts ...WatchCmdOption) *WatchCommand {
+	cmd := &WatchCommand{
+		backend:       backend,
+		encoder:       encoder.NewBase64Encoder(),
+		horizonOffset: time.Duration(serverconfig.DefaultChangelogHorizonOffset) * time.Minute,
+		pollInterval:  serverconfig.DefaultWatchPollInterval,
+		pageSize:      defaultWatchPageSize,
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Execute emits the changes of the store after the continuation token of the request until ctx is done, and
+// returns the error of ctx then. Heartbeats are only emitted between two reads of the changelog, so at the poll
+// interval at best. If emit returns an error, Execute stops and returns it.
+func (c *WatchCommand) Execute(ctx context.Context, req *WatchRequest, emit func(*WatchEvent) error) error {
+	decodedContToken, err := c.encoder.Decode(req.ContinuationToken)
+	if err != nil {
+		return serverErrors.InvalidContinuationToken
+	}
+	token := string(decodedContToken)
+	encodedContToken := req.ContinuationToken
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	lastEmitted := time.Now()
+	for {
+		for {
+			opts := storage.ReadChangesOptions{
+				Pagination: storage.NewPaginationOptions(c.pageSize, token),
+			}
+			changes, next, err := c.backend.ReadChanges(ctx, req.StoreID, req.ObjectType, opts, c.horizonOffset)
+			if errors.Is(err, storage.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return serverErrors.HandleError("", err)
+			}
+
+			token = string(next)
+			if encodedContToken, err = c.encoder.Encode(next); err != nil {
+				return serverErrors.HandleError("", err)
+			}
+
+			changes = filterChangesByRelation(changes, req.Relation)
+			if len(changes) == 0 {
+				// the token moves on, and is emitted with the next event
+				continue
+			}
+			if err := emit(&WatchEvent{Changes: changes, ContinuationToken: encodedContToken}); err != nil {
+				return err
+			}
+			lastEmitted = time.Now()
+		}
+
+		if req.HeartbeatInterval > 0 && time.Since(lastEmitted) >= req.HeartbeatInterval {
+			if err := emit(&WatchEvent{ContinuationToken: encodedContToken}); err != nil {
+				return err
+			}
+			lastEmitted = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func filterChangesByRelation(changes []*openfgav1.TupleChange, relation string) []*openfgav1.TupleChange {
+	if relation == "" {
+		return changes
+	}
+
+	filtered := make([]*openfgav1.TupleChange, 0, len(changes))
+	for _, change := range changes {
+		if change.GetTupleKey().GetRelation() == relation {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
@@ -2,8 +2,12 @@ package commands
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"google.golang.org/protobuf/proto"
@@ -17,11 +21,45 @@ import (
 	"github.com/openfga/openfga/pkg/typesystem"
 )
 
+// WriteConflictStrategy is how a Write resolves a conflict, i.e. a tuple that is both deleted and written by the
+// same request. Tuples are identified by their object, relation and user, so a write conflicts with the delete of
+// the same tuple regardless of its condition. Both operations are validated as usual before the conflict is
+// resolved, and a request that deletes or writes the same tuple twice is always rejected.
+type WriteConflictStrategy string
+
+const (
+	// WriteConflictStrategyReject rejects requests with conflicts.
+	WriteConflictStrategyReject WriteConflictStrategy = "reject"
+	// WriteConflictStrategyLastWins keeps the operation that is applied last and drops the other one. Deletes are
+	// applied before writes, so the delete is dropped: the tuple is written as if the request only wrote it, which
+	// fails if the tuple already exists.
+	WriteConflictStrategyLastWins WriteConflictStrategy = "last-wins"
+	// WriteConflictStrategyDeleteWins drops the write: the tuple is deleted as if the request only deleted it, which
+	// fails if the tuple doesn't exist.
+	WriteConflictStrategyDeleteWins WriteConflictStrategy = "delete-wins"
+)
+
+// WriteConflictStrategies are all the valid write conflict strategies.
+var WriteConflictStrategies = []WriteConflictStrategy{
+	WriteConflictStrategyReject,
+	WriteConflictStrategyLastWins,
+	WriteConflictStrategyDeleteWins,
+}
+
 // WriteCommand is used to Write and Delete tuples. Instances may be safely shared by multiple goroutines.
 type WriteCommand struct {
 	logger                    logger.Logger
 	datastore                 storage.OpenFGADatastore
 	conditionContextByteLimit int
+	maxTuplesPerWrite         int
+	batchWriter               storage.TransactionalBatchWriter
+	fieldLengthLimits         tupleUtils.FieldLengthLimits
+	storeSettings             storage.StoreSettingsBackend
+	conflictStrategy          WriteConflictStrategy
+	completeConditionContext  bool
+	idempotentWriter          storage.IdempotentWriter
+	idempotencyKey            string
+	idempotencyKeyTTL         time.Duration
 }
 
 type WriteCommandOption func(*WriteCommand)
@@ -38,12 +76,76 @@ func WithConditionContextByteLimit(limit int) WriteCommandOption {
 	}
 }
 
+// WithWriteCmdMaxTuplesPerWrite sets the maximum number of tuples allowed per write. Writes with more tuples than
+// the datastore's MaxTuplesPerWrite are only allowed if a batch writer is set with WithWriteCmdBatchWriter.
+// If not set or not greater than zero, the datastore's MaxTuplesPerWrite is used.
+func WithWriteCmdMaxTuplesPerWrite(n int) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.maxTuplesPerWrite = n
+	}
+}
+
+// WithWriteCmdBatchWriter sets the writer used for writes with more tuples than the datastore's MaxTuplesPerWrite.
+// Such writes are split into batches of at most MaxTuplesPerWrite tuples that are written within a single transaction.
+func WithWriteCmdBatchWriter(w storage.TransactionalBatchWriter) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.batchWriter = w
+	}
+}
+
+// WithWriteCmdFieldLengthLimits sets the maximum lengths of the fields of the tuples that are written.
+// Defaults to tuple.DefaultFieldLengthLimits.
+func WithWriteCmdFieldLengthLimits(limits tupleUtils.FieldLengthLimits) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.fieldLengthLimits = limits
+	}
+}
+
+// WithWriteCmdStoreSettings sets the backend of the store settings. Writes of tuples whose object type isn't
+// allowed by the settings of the store are rejected. If not set, any object type of the model is allowed.
+func WithWriteCmdStoreSettings(backend storage.StoreSettingsBackend) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.storeSettings = backend
+	}
+}
+
+// WithWriteCmdConflictStrategy sets how conflicts between the deletes and the writes of a request are resolved.
+// Defaults to WriteConflictStrategyReject.
+func WithWriteCmdConflictStrategy(strategy WriteConflictStrategy) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.conflictStrategy = strategy
+	}
+}
+
+// WithWriteCmdCompleteConditionContext makes the writes of conditioned tuples whose condition context doesn't have
+// a value for every parameter of the condition fail, instead of leaving those parameters to the context of the
+// queries. The values of the context are validated against the types of the parameters either way.
+func WithWriteCmdCompleteConditionContext(enabled bool) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.completeConditionContext = enabled
+	}
+}
+
+// WithWriteCmdIdempotencyKey makes the write idempotent: the key is recorded with the writer, within the same
+// transaction as the tuples, for the ttl, and a later write with the same key within the ttl is not applied
+// again. Instead, it succeeds as the original write did if it has the same tuples, and fails otherwise.
+// If the key is empty, the write is not idempotent.
+func WithWriteCmdIdempotencyKey(writer storage.IdempotentWriter, key string, ttl time.Duration) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.idempotentWriter = writer
+		wc.idempotencyKey = key
+		wc.idempotencyKeyTTL = ttl
+	}
+}
+
 // NewWriteCommand creates a WriteCommand with specified storage.OpenFGADatastore to use for storage.
 func NewWriteCommand(datastore storage.OpenFGADatastore, opts ...WriteCommandOption) *WriteCommand {
 	cmd := &WriteCommand{
 		datastore:                 datastore,
 		logger:                    logger.NewNoopLogger(),
 		conditionContextByteLimit: config.DefaultWriteContextByteLimit,
+		fieldLengthLimits:         tupleUtils.DefaultFieldLengthLimits,
+		conflictStrategy:          WriteConflictStrategyReject,
 	}
 
 	for _, opt := range opts {
@@ -58,12 +160,18 @@ func (c *WriteCommand) Execute(ctx context.Context, req *openfgav1.WriteRequest)
 		return nil, err
 	}
 
-	err := c.datastore.Write(
-		ctx,
-		req.GetStoreId(),
-		req.GetDeletes().GetTupleKeys(),
-		req.GetWrites().GetTupleKeys(),
-	)
+	deletes, writes := c.resolveConflicts(req.GetDeletes().GetTupleKeys(), req.GetWrites().GetTupleKeys())
+
+	if c.idempotencyKey != "" {
+		return c.writeIdempotently(ctx, req, deletes, writes)
+	}
+
+	var err error
+	if batchSize := c.datastore.MaxTuplesPerWrite(); len(deletes)+len(writes) <= batchSize {
+		err = c.datastore.Write(ctx, req.GetStoreId(), deletes, writes)
+	} else {
+		err = c.batchWriter.WriteBatches(ctx, req.GetStoreId(), splitIntoTupleBatches(deletes, writes, batchSize))
+	}
 	if err != nil {
 		return nil, serverErrors.HandleError("", err)
 	}
@@ -71,6 +179,57 @@ func (c *WriteCommand) Execute(ctx context.Context, req *openfgav1.WriteRequest)
 	return &openfgav1.WriteResponse{}, nil
 }
 
+// writeIdempotently writes the tuples with the idempotency record of the request. If the key was already recorded
+// by a write with the same tuples, e.g. because the client retried the request after a network error, nothing is
+// written and the result of the original write is returned.
+func (c *WriteCommand) writeIdempotently(
+	ctx context.Context,
+	req *openfgav1.WriteRequest,
+	deletes storage.Deletes,
+	writes storage.Writes,
+) (*openfgav1.WriteResponse, error) {
+	requestHash, err := hashWriteRequest(req)
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	record := storage.IdempotencyRecord{
+		Key:         c.idempotencyKey,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(c.idempotencyKeyTTL),
+	}
+	batches := splitIntoTupleBatches(deletes, writes, c.datastore.MaxTuplesPerWrite())
+
+	err = c.idempotentWriter.WriteBatchesIdempotently(ctx, req.GetStoreId(), record, batches)
+	if err != nil {
+		var keyExistsErr *storage.IdempotencyKeyExistsError
+		if !errors.As(err, &keyExistsErr) {
+			return nil, serverErrors.HandleError("", err)
+		}
+		if keyExistsErr.Record.RequestHash != requestHash {
+			return nil, serverErrors.IdempotencyKeyReused(c.idempotencyKey)
+		}
+		c.logger.DebugWithContext(ctx, "write already applied with the idempotency key")
+	}
+
+	return &openfgav1.WriteResponse{}, nil
+}
+
+// hashWriteRequest returns the hash of the tuples written and deleted by the request. The authorization model
+// isn't part of it, so that a retry resolved against a newer model is still recognized as the same write.
+func hashWriteRequest(req *openfgav1.WriteRequest) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(&openfgav1.WriteRequest{
+		StoreId: req.GetStoreId(),
+		Writes:  req.GetWrites(),
+		Deletes: req.GetDeletes(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash the write request: %w", err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
 func (c *WriteCommand) validateWriteRequest(ctx context.Context, req *openfgav1.WriteRequest) error {
 	ctx, span := tracer.Start(ctx, "validateWriteRequest")
 	defer span.End()
@@ -99,17 +258,42 @@ func (c *WriteCommand) validateWriteRequest(ctx context.Context, req *openfgav1.
 
 		typesys := typesystem.New(authModel)
 
+		var settings *storage.StoreSettings
+		if c.storeSettings != nil {
+			settings, err = c.storeSettings.ReadStoreSettings(ctx, store)
+			if err != nil {
+				return err
+			}
+		}
+
 		for _, tk := range writes {
+			if err := tupleUtils.ValidateFieldLengths(tk, c.fieldLengthLimits); err != nil {
+				return serverErrors.ValidationError(&tupleUtils.InvalidTupleError{
+					Cause:    err,
+					TupleKey: tk,
+				})
+			}
+
 			err := validation.ValidateTuple(typesys, tk)
 			if err != nil {
 				return serverErrors.ValidationError(err)
 			}
 
+			if c.completeConditionContext {
+				if err := validation.ValidateConditionContextComplete(typesys, tk); err != nil {
+					return serverErrors.ValidationError(err)
+				}
+			}
+
 			err = c.validateNotImplicit(tk)
 			if err != nil {
 				return err
 			}
 
+			if objectType := tupleUtils.GetType(tk.GetObject()); !settings.AllowsObjectType(objectType) {
+				return serverErrors.ValidationError(&storage.DisallowedObjectTypeError{StoreID: store, ObjectType: objectType})
+			}
+
 			contextSize := proto.Size(tk.GetCondition().GetContext())
 			if contextSize > c.conditionContextByteLimit {
 				return serverErrors.ValidationError(&tupleUtils.InvalidTupleError{
@@ -139,6 +323,7 @@ func (c *WriteCommand) validateWriteRequest(ctx context.Context, req *openfgav1.
 }
 
 // validateNoDuplicatesAndCorrectSize ensures the deletes and writes contain no duplicates and length fits.
+// A tuple that is both deleted and written is a duplicate only with WriteConflictStrategyReject.
 func (c *WriteCommand) validateNoDuplicatesAndCorrectSize(
 	deletes []*openfgav1.TupleKeyWithoutCondition,
 	writes []*openfgav1.TupleKey,
@@ -153,20 +338,87 @@ func (c *WriteCommand) validateNoDuplicatesAndCorrectSize(
 		tuples[key] = struct{}{}
 	}
 
+	written := make(map[string]struct{}, len(writes))
 	for _, tk := range writes {
 		key := tupleUtils.TupleKeyToString(tk)
-		if _, ok := tuples[key]; ok {
+		if _, ok := written[key]; ok {
+			return serverErrors.DuplicateTupleInWrite(tk)
+		}
+		if _, ok := tuples[key]; ok && c.conflictStrategy == WriteConflictStrategyReject {
 			return serverErrors.DuplicateTupleInWrite(tk)
 		}
+		written[key] = struct{}{}
 		tuples[key] = struct{}{}
 	}
 
-	if len(tuples) > c.datastore.MaxTuplesPerWrite() {
-		return serverErrors.ExceededEntityLimit("write operations", c.datastore.MaxTuplesPerWrite())
+	if maxTuplesPerWrite := c.getMaxTuplesPerWrite(); len(tuples) > maxTuplesPerWrite {
+		return serverErrors.ExceededEntityLimit("write operations", maxTuplesPerWrite)
 	}
 	return nil
 }
 
+// resolveConflicts drops the deletes or the writes of the tuples that are both deleted and written, according to
+// the conflict strategy. It expects the deletes and the writes to have been validated.
+func (c *WriteCommand) resolveConflicts(
+	deletes []*openfgav1.TupleKeyWithoutCondition,
+	writes []*openfgav1.TupleKey,
+) ([]*openfgav1.TupleKeyWithoutCondition, []*openfgav1.TupleKey) {
+	if len(deletes) == 0 || len(writes) == 0 {
+		return deletes, writes
+	}
+
+	switch c.conflictStrategy {
+	case WriteConflictStrategyLastWins:
+		written := make(map[string]struct{}, len(writes))
+		for _, tk := range writes {
+			written[tupleUtils.TupleKeyToString(tk)] = struct{}{}
+		}
+		return slices.DeleteFunc(slices.Clone(deletes), func(tk *openfgav1.TupleKeyWithoutCondition) bool {
+			_, ok := written[tupleUtils.TupleKeyToString(tk)]
+			return ok
+		}), writes
+	case WriteConflictStrategyDeleteWins:
+		deleted := make(map[string]struct{}, len(deletes))
+		for _, tk := range deletes {
+			deleted[tupleUtils.TupleKeyToString(tk)] = struct{}{}
+		}
+		return deletes, slices.DeleteFunc(slices.Clone(writes), func(tk *openfgav1.TupleKey) bool {
+			_, ok := deleted[tupleUtils.TupleKeyToString(tk)]
+			return ok
+		})
+	default:
+		return deletes, writes
+	}
+}
+
+// getMaxTuplesPerWrite returns the maximum number of tuples allowed per write. It can only exceed the
+// datastore's MaxTuplesPerWrite if there is a batch writer.
+func (c *WriteCommand) getMaxTuplesPerWrite() int {
+	datastoreMax := c.datastore.MaxTuplesPerWrite()
+	if c.maxTuplesPerWrite <= 0 || (c.maxTuplesPerWrite > datastoreMax && c.batchWriter == nil) {
+		return datastoreMax
+	}
+	return c.maxTuplesPerWrite
+}
+
+// splitIntoTupleBatches splits the deletes and writes into batches of at most batchSize tuples,
+// keeping all deletes before all writes.
+func splitIntoTupleBatches(deletes storage.Deletes, writes storage.Writes, batchSize int) []storage.TupleBatch {
+	var batches []storage.TupleBatch
+	for len(deletes)+len(writes) > 0 {
+		var batch storage.TupleBatch
+
+		n := min(batchSize, len(deletes))
+		batch.Deletes, deletes = deletes[:n], deletes[n:]
+
+		n = min(batchSize-len(batch.Deletes), len(writes))
+		batch.Writes, writes = writes[:n], writes[n:]
+
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
 // validateNotImplicit ensures the tuple to be written (not deleted) is not of the form `object:id # relation @ object:id#relation`.
 func (c *WriteCommand) validateNotImplicit(
 	tk *openfgav1.TupleKey,
@@ -3,9 +3,11 @@ package commands
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
-	"github.com/oklog/ulid/v2"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -22,6 +24,9 @@ type WriteAuthorizationModelCommand struct {
 	backend                          storage.TypeDefinitionWriteBackend
 	logger                           logger.Logger
 	maxAuthorizationModelSizeInBytes int
+	storeSettings                    storage.StoreSettingsBackend
+	maxModelsPerStore                int
+	rejectNonDeterministicConditions bool
 }
 
 type WriteAuthModelOption func(*WriteAuthorizationModelCommand)
@@ -38,6 +43,34 @@ func WithWriteAuthModelMaxSizeInBytes(size int) WriteAuthModelOption {
 	}
 }
 
+// WithWriteAuthModelStoreSettings sets the backend of the store settings. Models with a type that isn't
+// allowed by the settings of the store are rejected. If not set, any type is allowed.
+func WithWriteAuthModelStoreSettings(backend storage.StoreSettingsBackend) WriteAuthModelOption {
+	return func(m *WriteAuthorizationModelCommand) {
+		m.storeSettings = backend
+	}
+}
+
+// WithWriteAuthModelMaxModelsPerStore sets the maximum number of authorization models of a store, for the
+// stores whose settings don't set one. A model written to a store that already has the maximum number of
+// models is rejected with [storage.ErrModelQuotaExceeded], until the old models are pruned. If zero, the
+// number of models is unlimited. The number of models is only counted if the backend is also a
+// [storage.AuthorizationModelReadBackend].
+func WithWriteAuthModelMaxModelsPerStore(n int) WriteAuthModelOption {
+	return func(m *WriteAuthorizationModelCommand) {
+		m.maxModelsPerStore = n
+	}
+}
+
+// WithWriteAuthModelRejectNonDeterministicConditions makes the command reject the models with conditions that
+// call non-deterministic functions, e.g. 'now()'. Otherwise, such models are accepted, and the Checks that
+// involve these conditions are never cached.
+func WithWriteAuthModelRejectNonDeterministicConditions(reject bool) WriteAuthModelOption {
+	return func(m *WriteAuthorizationModelCommand) {
+		m.rejectNonDeterministicConditions = reject
+	}
+}
+
 func NewWriteAuthorizationModelCommand(backend storage.TypeDefinitionWriteBackend, opts ...WriteAuthModelOption) *WriteAuthorizationModelCommand {
 	model := &WriteAuthorizationModelCommand{
 		backend:                          backend,
@@ -64,7 +97,7 @@ func (w *WriteAuthorizationModelCommand) Execute(ctx context.Context, req *openf
 	}
 
 	model := &openfgav1.AuthorizationModel{
-		Id:              ulid.Make().String(),
+		Id:              storage.NewULID(time.Now()).String(),
 		SchemaVersion:   req.GetSchemaVersion(),
 		TypeDefinitions: req.GetTypeDefinitions(),
 		Conditions:      req.GetConditions(),
@@ -79,11 +112,50 @@ func (w *WriteAuthorizationModelCommand) Execute(ctx context.Context, req *openf
 		)
 	}
 
-	_, err := typesystem.NewAndValidate(ctx, model)
+	typesys, err := typesystem.NewAndValidate(ctx, model)
 	if err != nil {
 		return nil, serverErrors.InvalidAuthorizationModelInput(err)
 	}
 
+	if w.rejectNonDeterministicConditions {
+		if err := validateConditionsDeterminism(typesys); err != nil {
+			return nil, serverErrors.InvalidAuthorizationModelInput(err)
+		}
+	}
+
+	maxModels := w.maxModelsPerStore
+	if w.storeSettings != nil {
+		settings, err := w.storeSettings.ReadStoreSettings(ctx, req.GetStoreId())
+		if err != nil {
+			return nil, serverErrors.HandleError("", err)
+		}
+		for _, typeDef := range model.GetTypeDefinitions() {
+			if !settings.AllowsObjectType(typeDef.GetType()) {
+				return nil, serverErrors.InvalidAuthorizationModelInput(
+					&storage.DisallowedObjectTypeError{StoreID: req.GetStoreId(), ObjectType: typeDef.GetType()},
+				)
+			}
+		}
+		if settings.MaxAuthorizationModels > 0 {
+			maxModels = int(settings.MaxAuthorizationModels)
+		}
+	}
+
+	if err := w.checkModelQuota(ctx, req.GetStoreId(), maxModels); err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	// unsatisfiable relations are valid, but almost certainly a mistake in the model
+	for _, unsatisfiable := range typesys.UnsatisfiableRelations() {
+		w.logger.WarnWithContext(ctx, "authorization model contains an unsatisfiable relation",
+			zap.String("store_id", req.GetStoreId()),
+			zap.String("authorization_model_id", model.GetId()),
+			zap.String("object_type", unsatisfiable.ObjectType),
+			zap.String("relation", unsatisfiable.Relation),
+			zap.String("reason", unsatisfiable.Reason),
+		)
+	}
+
 	err = w.backend.WriteAuthorizationModel(ctx, req.GetStoreId(), model)
 	if err != nil {
 		return nil, serverErrors.
@@ -94,3 +166,53 @@ func (w *WriteAuthorizationModelCommand) Execute(ctx context.Context, req *openf
 		AuthorizationModelId: model.GetId(),
 	}, nil
 }
+
+// checkModelQuota returns [storage.ErrModelQuotaExceeded] if the store already has maxModels authorization
+// models. It's best-effort: concurrent writes to the same store may go over the quota.
+func (w *WriteAuthorizationModelCommand) checkModelQuota(ctx context.Context, storeID string, maxModels int) error {
+	reader, ok := w.backend.(storage.AuthorizationModelReadBackend)
+	if maxModels <= 0 || !ok {
+		return nil
+	}
+
+	count := 0
+	var continuationToken []byte
+	for {
+		models, token, err := reader.ReadAuthorizationModels(ctx, storeID, storage.ReadAuthorizationModelsOptions{
+			Pagination: storage.PaginationOptions{PageSize: min(maxModels, storage.DefaultPageSize), From: string(continuationToken)},
+		})
+		if err != nil {
+			return err
+		}
+
+		count += len(models)
+		if count >= maxModels {
+			return fmt.Errorf("%w: store '%s' has %d authorization models, the maximum; prune the old models before writing a new one",
+				storage.ErrModelQuotaExceeded, storeID, maxModels)
+		}
+		if len(token) == 0 {
+			return nil
+		}
+		continuationToken = token
+	}
+}
+
+// validateConditionsDeterminism returns an error naming the first condition, in alphabetical order, that calls
+// a non-deterministic function.
+func validateConditionsDeterminism(typesys *typesystem.TypeSystem) error {
+	nonDeterministicConditions := typesys.NonDeterministicConditions()
+	if len(nonDeterministicConditions) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(nonDeterministicConditions))
+	for name := range nonDeterministicConditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf(
+		"condition '%s' calls the non-deterministic function '%s', whose results can't be cached",
+		names[0], nonDeterministicConditions[names[0]][0],
+	)
+}
@@ -27,6 +27,7 @@ var (
 	RequestCancelled                       = status.Error(codes.Code(openfgav1.InternalErrorCode_cancelled), "Request Cancelled")
 	RequestDeadlineExceeded                = status.Error(codes.Code(openfgav1.InternalErrorCode_deadline_exceeded), "Request Deadline Exceeded")
 	ThrottledTimeout                       = status.Error(codes.Code(openfgav1.UnprocessableContentErrorCode_throttled_timeout_error), "timeout due to throttling on complex request")
+	DeleteConfirmationMismatch             = status.Error(codes.FailedPrecondition, "The tuples to delete changed since they were previewed. Request a new preview and confirm the delete again")
 )
 
 type InternalError struct {
@@ -67,6 +68,13 @@ func NewInternalError(public string, internal error) InternalError {
 	}
 }
 
+// VisitedObjectsLimitExceeded is like AuthorizationModelResolutionTooComplex, for Checks that visited more
+// distinct objects than allowed.
+func VisitedObjectsLimitExceeded(visited, limit uint32) error {
+	return status.Error(codes.Code(openfgav1.ErrorCode_authorization_model_resolution_too_complex),
+		fmt.Sprintf("Authorization Model resolution visited %d distinct objects, more than the allowed limit of %d", visited, limit))
+}
+
 func ValidationError(cause error) error {
 	return status.Error(codes.Code(openfgav1.ErrorCode_validation_error), cause.Error())
 }
@@ -83,6 +91,33 @@ func LatestAuthorizationModelNotFound(store string) error {
 	return status.Error(codes.Code(openfgav1.ErrorCode_latest_authorization_model_not_found), fmt.Sprintf("No authorization models found for store '%s'", store))
 }
 
+// NoModelForStore is like LatestAuthorizationModelNotFound, but explains how to fix it.
+func NoModelForStore(store string) error {
+	return status.Error(codes.Code(openfgav1.ErrorCode_latest_authorization_model_not_found), fmt.Sprintf("Store '%s' has no authorization model yet. Write one with WriteAuthorizationModel before querying the store", store))
+}
+
+// StoreNotFound is like StoreIDNotFound, but names the store that was not found.
+func StoreNotFound(storeID string) error {
+	return status.Error(codes.Code(openfgav1.NotFoundErrorCode_store_id_not_found), fmt.Sprintf("Store ID '%s' not found", storeID))
+}
+
+// StoreNameConflict is returned when a store is created with the name of another store, and the names of the
+// stores must be unique.
+func StoreNameConflict(name string) error {
+	return status.Error(codes.AlreadyExists, fmt.Sprintf("A store named '%s' already exists", name))
+}
+
+// IdempotencyKeyReused is returned when a Write has the idempotency key of a previous Write with different tuples.
+func IdempotencyKeyReused(key string) error {
+	return status.Error(codes.Code(openfgav1.ErrorCode_validation_error), fmt.Sprintf("The idempotency key '%s' was already used by a different write", key))
+}
+
+// RelationNotListable is returned by ListObjects for a relation whose objects can only be listed with a Check of
+// every object found, when that slow fallback isn't allowed.
+func RelationNotListable(err error) error {
+	return status.Error(codes.FailedPrecondition, err.Error())
+}
+
 func TypeNotFound(objectType string) error {
 	return status.Error(codes.Code(openfgav1.ErrorCode_type_not_found), fmt.Sprintf("type '%s' not found", objectType))
 }
@@ -119,11 +154,16 @@ func InvalidAuthorizationModelInput(err error) error {
 // HandleError is used to surface some errors, and hide others.
 // Use `public` if you want to return a useful error message to the user.
 func HandleError(public string, err error) error {
+	var storeNotFoundErr *storage.StoreNotFoundError
+	var storeNameConflictErr *storage.StoreNameConflictError
+
 	switch {
 	case errors.Is(err, storage.ErrTransactionalWriteFailed):
 		return status.Error(codes.Aborted, err.Error())
 	case errors.Is(err, storage.ErrInvalidWriteInput):
 		return WriteFailedDueToInvalidInput(err)
+	case errors.Is(err, storage.ErrWriteTooLarge):
+		return WriteFailedDueToInvalidInput(err)
 	case errors.Is(err, storage.ErrInvalidContinuationToken):
 		return InvalidContinuationToken
 	case errors.Is(err, storage.ErrMismatchObjectType):
@@ -132,6 +172,14 @@ func HandleError(public string, err error) error {
 		return RequestCancelled
 	case errors.Is(err, storage.ErrDeadlineExceeded):
 		return RequestDeadlineExceeded
+	case errors.Is(err, storage.ErrModelQuotaExceeded):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, storage.ErrDatastoreUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.As(err, &storeNotFoundErr):
+		return StoreNotFound(storeNotFoundErr.StoreID)
+	case errors.As(err, &storeNameConflictErr):
+		return StoreNameConflict(storeNameConflictErr.Name)
 	default:
 		return NewInternalError(public, err)
 	}
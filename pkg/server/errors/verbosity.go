@@ -0,0 +1,68 @@
+package errors
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/status"
+)
+
+// ErrorVerbosity controls how much detail the errors returned by the server include.
+type ErrorVerbosity int
+
+const (
+	// ErrorVerbosityFull returns errors with all the details available, e.g. the tuples, the model
+	// definitions or the datastore errors that caused them. It is the default.
+	ErrorVerbosityFull ErrorVerbosity = iota
+
+	// ErrorVerbosityTerse returns errors with their code only. Their message is replaced by the name
+	// of the code, and their details are removed, so that they are safe to return to untrusted clients.
+	ErrorVerbosityTerse
+)
+
+// WithVerbosity returns err as it should be returned to clients with the given verbosity. With
+// ErrorVerbosityTerse, both the message and the gRPC status of the returned error are terse, but the
+// error still wraps err, so that [Verbose] can return it to be logged in full.
+func WithVerbosity(err error, verbosity ErrorVerbosity) error {
+	if err == nil || verbosity == ErrorVerbosityFull {
+		return err
+	}
+
+	// errors that are not statuses are returned as Unknown by grpc, with their message
+	code := status.Code(err)
+	message := code.String()
+	if IsValidEncodedError(int32(code)) {
+		message = NewEncodedError(int32(code), "").Code()
+	}
+
+	return &terseError{
+		status: status.New(code, message),
+		cause:  err,
+	}
+}
+
+// Verbose returns the error that err was made terse from by WithVerbosity, or err itself if it isn't terse.
+// It is meant to log errors in full, never to return them to clients.
+func Verbose(err error) error {
+	var terse *terseError
+	if errors.As(err, &terse) {
+		return terse.cause
+	}
+	return err
+}
+
+type terseError struct {
+	status *status.Status
+	cause  error
+}
+
+func (e *terseError) Error() string {
+	return e.status.Message()
+}
+
+func (e *terseError) Unwrap() error {
+	return e.cause
+}
+
+func (e *terseError) GRPCStatus() *status.Status {
+	return e.status
+}
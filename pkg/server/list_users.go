@@ -17,6 +17,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/openfga/openfga/internal/condition"
+	"github.com/openfga/openfga/internal/condition/eval"
 	"github.com/openfga/openfga/internal/graph"
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/telemetry"
@@ -32,8 +33,10 @@ import (
 func (s *Server) ListUsers(
 	ctx context.Context,
 	req *openfgav1.ListUsersRequest,
-) (*openfgav1.ListUsersResponse, error) {
-	err := s.validateConsistencyRequest(req.GetConsistency())
+) (_ *openfgav1.ListUsersResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	err = s.validateConsistencyRequest(req.GetConsistency())
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +59,11 @@ func (s *Server) ListUsers(
 
 	const methodName = "listusers"
 
-	typesys, err := s.resolveTypesystem(ctx, req.GetStoreId(), req.GetAuthorizationModelId())
+	ctx = withReadDatastore(ctx)
+	ctx = s.withDisabledConditions(ctx)
+	ctx = eval.ContextWithStrictConditionContext(ctx, s.checkStrictConditionContext)
+
+	typesys, err := s.resolveQueryTypesystem(ctx, req.GetStoreId(), req.GetAuthorizationModelId())
 	if err != nil {
 		return nil, err
 	}
@@ -66,9 +73,14 @@ func (s *Server) ListUsers(
 		return nil, err
 	}
 
+	ctx, err = s.withContextualTuples(ctx, req.GetStoreId(), req.GetContextualTuples())
+	if err != nil {
+		return nil, err
+	}
+
 	ctx = typesystem.ContextWithTypesystem(ctx, typesys)
 
-	listUsersQuery := listusers.NewListUsersQuery(s.datastore,
+	listUsersQuery := listusers.NewListUsersQuery(s.tupleReader,
 		listusers.WithResolveNodeLimit(s.resolveNodeLimit),
 		listusers.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
 		listusers.WithListUsersQueryLogger(s.logger),
@@ -101,26 +113,26 @@ func (s *Server) ListUsers(
 
 	grpc_ctxtags.Extract(ctx).Set(datastoreQueryCountHistogramName, datastoreQueryCount)
 	span.SetAttributes(attribute.Float64(datastoreQueryCountHistogramName, datastoreQueryCount))
-	datastoreQueryCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(datastoreQueryCount)
+	s.metricsSink.ObserveHistogram(datastoreQueryCountHistogramName, datastoreQueryCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
 
 	dispatchCount := float64(resp.Metadata.DispatchCounter.Load())
 	grpc_ctxtags.Extract(ctx).Set(dispatchCountHistogramName, dispatchCount)
 	span.SetAttributes(attribute.Float64(dispatchCountHistogramName, dispatchCount))
-	dispatchCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(dispatchCount)
-
-	requestDurationHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-		utils.Bucketize(uint(datastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
-		utils.Bucketize(uint(dispatchCount), s.requestDurationByDispatchCountHistogramBuckets),
-		req.GetConsistency().String(),
-	).Observe(float64(time.Since(start).Milliseconds()))
+	s.metricsSink.ObserveHistogram(dispatchCountHistogramName, dispatchCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
+
+	s.metricsSink.ObserveHistogram(requestDurationHistogramName, float64(time.Since(start).Milliseconds()), map[string]string{
+		"grpc_service":          s.serviceName,
+		"grpc_method":           methodName,
+		"datastore_query_count": utils.Bucketize(uint(datastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
+		"dispatch_count":        utils.Bucketize(uint(dispatchCount), s.requestDurationByDispatchCountHistogramBuckets),
+		"consistency":           req.GetConsistency().String(),
+	})
 
 	return &openfgav1.ListUsersResponse{
 		Users: resp.GetUsers(),
@@ -9,6 +9,8 @@ import (
 	"slices"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/openfga/openfga/internal/graph"
@@ -26,10 +28,13 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/openfga/openfga/internal/build"
 	"github.com/openfga/openfga/internal/condition"
+	"github.com/openfga/openfga/internal/condition/eval"
 	serverconfig "github.com/openfga/openfga/internal/server/config"
 	"github.com/openfga/openfga/internal/utils"
 	"github.com/openfga/openfga/internal/validation"
@@ -39,6 +44,7 @@ import (
 	httpmiddleware "github.com/openfga/openfga/pkg/middleware/http"
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/server/commands/reverseexpand"
 	serverErrors "github.com/openfga/openfga/pkg/server/errors"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/storage/storagewrappers"
@@ -53,10 +59,84 @@ const (
 	AuthorizationModelIDHeader = "Openfga-Authorization-Model-Id"
 	authorizationModelIDKey    = "authorization_model_id"
 
+	// AuthorizationModelFallbackHeader is the response header set to the ID of the requested authorization
+	// model when it was not found and the request was resolved against the latest model of the store instead.
+	// See WithModelNotFoundFallback.
+	AuthorizationModelFallbackHeader = "Openfga-Authorization-Model-Fallback"
+
+	// MinChangelogTokenHeader is the request header with which a Check can request to be resolved against
+	// a state that includes all the changes up to a continuation token returned by ReadChanges.
+	MinChangelogTokenHeader = "Openfga-Min-Changelog-Token"
+
+	// ReadDatastoreHeader is the request header with which a Check, ListObjects or ListUsers can request
+	// its tuple reads to be served by the datastore set by WithShadowDatastore, when set to ReadDatastoreShadow.
+	// Such requests bypass the Check cache, and their results are not cached.
+	ReadDatastoreHeader = "Openfga-Read-Datastore"
+	ReadDatastoreShadow = "shadow"
+
+	// DisableFastPathHeader is the request header with which a Check can request, when set to "true", to be
+	// resolved with the canonical algorithms only, bypassing the fast paths and the Check cache, e.g. to
+	// compare the results of both in shadow mode.
+	DisableFastPathHeader = "Openfga-Disable-Fast-Path"
+
+	// IdempotencyKeyHeader is the request header with which a Write can set an idempotency key, so that a retry of
+	// the Write with the same key, e.g. after a network error, returns the result of the original Write instead of
+	// applying it again. The datastore must implement [storage.IdempotentWriter].
+	IdempotencyKeyHeader = "Openfga-Idempotency-Key"
+
+	// WaitForCacheInvalidationHeader is the request header with which a Write can request, when set to "true", to
+	// return only once the invalidation of the cached Check results of the store has propagated to every instance
+	// that shares the Check cache backend, so that no instance serves a Check result that predates the Write.
+	// It costs the latency of the propagation. The backend must implement [graph.CheckCacheInvalidator].
+	WaitForCacheInvalidationHeader = "Openfga-Wait-For-Cache-Invalidation"
+
+	// CacheInvalidationAcknowledgedHeader is the response header set on a Write that requested it with the
+	// WaitForCacheInvalidationHeader: "true" if the invalidation was acknowledged by every instance, "false"
+	// otherwise, in which case the tuples are written but Checks may be served from stale cache entries until
+	// they expire.
+	CacheInvalidationAcknowledgedHeader = "Openfga-Cache-Invalidation-Acknowledged"
+
+	// maxIdempotencyKeyLength is the maximum length of the value of the IdempotencyKeyHeader.
+	maxIdempotencyKeyLength = 128
+
+	// KnownCheckResultsHeader is the request header with which a Check can supply results of its subproblems that
+	// the client has already resolved, one 'object#relation@user=true' or 'object#relation@user=false' per value,
+	// so that the server trusts them instead of resolving these subproblems. It is ignored unless the server
+	// enables it with WithKnownCheckResults.
+	KnownCheckResultsHeader = "Openfga-Known-Check-Results"
+
+	// ReadPolicyHeader is the request header with which a Check can select, with a single ReadPolicy, both
+	// whether its result may be served from the Check cache and which datastore serves its tuple reads. It
+	// can't be combined with the consistency preference of the request, which it sets, and like that preference
+	// it needs the ExperimentalEnableConsistencyParams experimental.
+	ReadPolicyHeader = "Openfga-Read-Policy"
+
+	// maxKnownCheckResults is the maximum number of values of the KnownCheckResultsHeader.
+	maxKnownCheckResults = 100
+
+	// pinnedModelInUseWindow is how long an authorization model that a request was pinned to is considered in use,
+	// and isn't pruned by PruneAuthorizationModels. It's longer than the requests are expected to last.
+	pinnedModelInUseWindow = time.Minute
+
 	ExperimentalEnableConsistencyParams ExperimentalFeatureFlag = "enable-consistency-params"
 	ExperimentalCheckOptimizations      ExperimentalFeatureFlag = "enable-check-optimizations"
 )
 
+// ReadPolicy is a value of the ReadPolicyHeader.
+type ReadPolicy string
+
+const (
+	// ReadPolicyCachedEventuallyConsistent serves the Check from the Check cache if possible, and its tuple reads
+	// from a read replica of the datastore if any. This is the behavior of a Check without a consistency preference.
+	ReadPolicyCachedEventuallyConsistent ReadPolicy = "cached-eventually-consistent"
+	// ReadPolicyFreshFromReplica bypasses the Check cache, and serves the tuple reads from a read replica of the
+	// datastore if any, so the result reflects the writes that the replica has replicated.
+	ReadPolicyFreshFromReplica ReadPolicy = "fresh-from-replica"
+	// ReadPolicyFreshFromPrimary bypasses the Check cache, and serves the tuple reads from the primary of the
+	// datastore, so the result reflects all the writes that preceded the Check.
+	ReadPolicyFreshFromPrimary ReadPolicy = "fresh-from-primary"
+)
+
 var tracer = otel.Tracer("openfga/pkg/server")
 
 var (
@@ -97,28 +177,77 @@ var (
 	}, []string{"grpc_service", "grpc_method", "datastore_query_count", "dispatch_count", "consistency"})
 )
 
+// pinnedModelKey identifies an authorization model that a request was pinned to.
+type pinnedModelKey struct {
+	storeID string
+	modelID string
+}
+
+// prometheusMetricsSink is the default telemetry.MetricsSink, which updates the Prometheus metrics of the server.
+type prometheusMetricsSink struct{}
+
+var _ telemetry.MetricsSink = (*prometheusMetricsSink)(nil)
+
+func (prometheusMetricsSink) IncCounter(name string, value float64, tags map[string]string) {
+	if name == watchStreamsCounterName {
+		watchStreamsCounter.With(tags).Add(value)
+	}
+}
+
+func (prometheusMetricsSink) ObserveHistogram(name string, value float64, tags map[string]string) {
+	var histogram *prometheus.HistogramVec
+	switch name {
+	case dispatchCountHistogramName:
+		histogram = dispatchCountHistogram
+	case datastoreQueryCountHistogramName:
+		histogram = datastoreQueryCountHistogram
+	case requestDurationHistogramName:
+		histogram = requestDurationHistogram
+	default:
+		return
+	}
+	histogram.With(tags).Observe(value)
+}
+
+func (prometheusMetricsSink) SetGauge(name string, value float64, _ map[string]string) {
+	if name == watchInFlightStreamsGaugeName {
+		watchInFlightStreamsGauge.Set(value)
+	}
+}
+
 // A Server implements the OpenFGA service backend as both
 // a GRPC and HTTP server.
 type Server struct {
 	openfgav1.UnimplementedOpenFGAServiceServer
 
-	logger                           logger.Logger
-	datastore                        storage.OpenFGADatastore
+	logger         logger.Logger
+	datastore      storage.OpenFGADatastore
+	typeDatastores map[string]storage.RelationshipTupleReader
+	// tupleReader is the datastore used for reads during query resolution, with per-type routing applied.
+	tupleReader                      storage.RelationshipTupleReader
 	encoder                          encoder.Encoder
 	transport                        gateway.Transport
 	resolveNodeLimit                 uint32
 	resolveNodeBreadthLimit          uint32
 	usersetBatchSize                 uint32
 	changelogHorizonOffset           int
+	watchPollInterval                time.Duration
+	watchEnabled                     bool
+	watchMaxConcurrentStreams        uint32
 	listObjectsDeadline              time.Duration
 	listObjectsMaxResults            uint32
+	listObjectsCheckFallback         bool
 	listUsersDeadline                time.Duration
 	listUsersMaxResults              uint32
 	maxConcurrentReadsForListObjects uint32
 	maxConcurrentReadsForCheck       uint32
 	maxConcurrentReadsForListUsers   uint32
+	reverseExpansionParallelism      uint32
+	// reverseExpansionBackoff is shared by the reverse expansions of all the ListObjects calls, if enabled
+	reverseExpansionBackoff          *reverseexpand.OverloadBackoff
 	maxAuthorizationModelCacheSize   int
 	maxAuthorizationModelSizeInBytes int
+	maxAuthorizationModelsPerStore   int
 	experimentals                    []ExperimentalFeatureFlag
 	serviceName                      string
 
@@ -129,12 +258,28 @@ type Server struct {
 	checkQueryCacheEnabled bool
 	checkQueryCacheLimit   uint32
 	checkQueryCacheTTL     time.Duration
+	// checkQueryCachePositiveTTL and checkQueryCacheNegativeTTL override checkQueryCacheTTL
+	// for allowed and denied Check results, if set
+	checkQueryCachePositiveTTL time.Duration
+	checkQueryCacheNegativeTTL time.Duration
+	// checkQueryCacheEmptyObjectTTL is the TTL of the knowledge of whether the objects of tuple to usersets have
+	// tuples, see graph.WithEmptyObjectCache
+	checkQueryCacheEmptyObjectTTL time.Duration
+	// checkQueryCacheRejectNonDeterministicConditions rejects the models with non-deterministic conditions while
+	// the cache is enabled
+	checkQueryCacheRejectNonDeterministicConditions bool
+	// checkQueryCacheNonCacheableContextualTupleRelations are the 'objectType#relation' of the contextual tuples that
+	// prevent a Check from being cached
+	checkQueryCacheNonCacheableContextualTupleRelations []string
+	// checkQueryCacheNonCacheableRelations are the 'objectType#relation' of the Checks that are never cached
+	checkQueryCacheNonCacheableRelations []string
 
 	checkResolver       graph.CheckResolver
 	checkResolverCloser func()
 
 	requestDurationByQueryHistogramBuckets         []uint
 	requestDurationByDispatchCountHistogramBuckets []uint
+	metricsSink                                    telemetry.MetricsSink
 
 	checkDispatchThrottlingEnabled          bool
 	checkDispatchThrottlingFrequency        time.Duration
@@ -154,8 +299,91 @@ type Server struct {
 	listObjectsDispatchThrottler throttler.Throttler
 	listUsersDispatchThrottler   throttler.Throttler
 
+	// ctx is canceled on Close, to stop the background tasks of the server.
 	ctx                 context.Context
+	cancel              context.CancelFunc
 	checkTrackerEnabled bool
+
+	denyCheckOnUnknownStore bool
+
+	denyCheckOnStoreWithoutModel bool
+
+	checkRelationAliasesEnabled bool
+
+	modelChangeHandler func(storeID, modelID string)
+	// modelChangeHandlers tracks the calls of modelChangeHandler in flight, which Close waits for
+	modelChangeHandlers sync.WaitGroup
+
+	defaultUserTypesEnabled bool
+
+	uniqueStoreNames bool
+	// uniqueStoreNameCreator is the datastore, if uniqueStoreNames is enabled
+	uniqueStoreNameCreator storage.UniqueStoreNameCreator
+
+	errorVerbosity serverErrors.ErrorVerbosity
+
+	tupleFieldLengthLimits tuple.FieldLengthLimits
+
+	maxTuplesPerWrite int
+	// batchWriter is the datastore, if it can write more tuples than its MaxTuplesPerWrite in a single transaction
+	batchWriter storage.TransactionalBatchWriter
+	// storeSettings is the datastore, if it can store settings with the stores
+	storeSettings storage.StoreSettingsBackend
+	// storeSettingsCache caches the settings read to resolve Checks and Writes, if storeSettingsCacheTTL is set
+	storeSettingsCache    storage.InMemoryCache[*storage.StoreSettings]
+	storeSettingsCacheTTL time.Duration
+	// modelPruner is the datastore, if it can delete old authorization models
+	modelPruner storage.AuthorizationModelPruner
+	// watchStreams has a slot for each Watch stream in flight
+	watchStreams chan struct{}
+
+	watchInFlightStreamsMu sync.Mutex
+	watchInFlightStreams   int
+	// pinnedModels records when requests were last pinned to an authorization model, keyed by store and model ID
+	pinnedModels sync.Map
+	// idempotentWriter is the datastore, if it can record the idempotency keys of writes
+	idempotentWriter       storage.IdempotentWriter
+	writeIdempotencyKeyTTL time.Duration
+
+	writeCompleteConditionContext bool
+
+	shadowDatastore            storage.RelationshipTupleReader
+	shadowReadSamplePercentage int
+
+	standbyDatastore     storage.OpenFGADatastore
+	standbyDatastoreOpts []storagewrappers.StandbyDatastoreOpt
+
+	contextualTuplesOverlay           bool
+	rejectConflictingContextualTuples bool
+
+	knownCheckResultsEnabled bool
+	modelNotFoundFallback    bool
+
+	disabledConditions []string
+
+	checkSelfRelations []string
+	// selfRelations are the parsed checkSelfRelations
+	selfRelations []graph.SelfRelation
+
+	conditionEvaluationErrorPolicy eval.EvaluationErrorPolicy
+	checkStrictConditionContext    bool
+
+	checkResolutionStrategy graph.CheckResolutionStrategy
+
+	expandMaxDirectUsers uint32
+
+	checkMaxVisitedObjects uint32
+
+	checkQueryCacheBackend     graph.CheckCacheBackend
+	checkQueryCacheBackendOpts []graph.CacheBackendOpt
+	// checkQueryCacheChangelogInvalidationInterval is zero if the changelog isn't polled to invalidate the cache
+	checkQueryCacheChangelogInvalidationInterval time.Duration
+	// changelogInvalidatorDone is done once the changelog invalidator stopped, after Close
+	changelogInvalidatorDone sync.WaitGroup
+
+	sharedAuthorizationModelCache bool
+
+	writeConflictStrategy commands.WriteConflictStrategy
 }
 
 type OpenFGAServiceV1Option func(s *Server)
@@ -168,7 +396,259 @@ func WithDatastore(ds storage.OpenFGADatastore) OpenFGAServiceV1Option {
 	}
 }
 
-// WithContext passes the server context to allow for graceful shutdowns.
+// WithTypeDatastore routes the tuple reads of Check, ListObjects and ListUsers for objects of the given type
+// to the given datastore instead of the one set by WithDatastore. It may be passed multiple times, once per object type.
+// Writes are not routed: tuples of a routed type must be written to its datastore out of band.
+// You must close the datastore yourself after you have stopped using the Server.
+func WithTypeDatastore(objectType string, ds storage.RelationshipTupleReader) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		if s.typeDatastores == nil {
+			s.typeDatastores = map[string]storage.RelationshipTupleReader{}
+		}
+		s.typeDatastores[objectType] = ds
+	}
+}
+
+// WithShadowDatastore compares the tuple reads of Check, ListObjects and ListUsers with the given datastore,
+// e.g. a datastore being migrated to. samplePercentage percent of the reads are run against both datastores,
+// and the differences between their results are logged as warnings, asynchronously; the results of the
+// datastore set by WithDatastore are always the ones used. Requests can instead be served by the shadow datastore alone by
+// setting the ReadDatastoreHeader. Writes always go to the datastore set by WithDatastore.
+// You must close the datastore yourself after you have stopped using the Server.
+func WithShadowDatastore(ds storage.RelationshipTupleReader, samplePercentage int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.shadowDatastore = ds
+		s.shadowReadSamplePercentage = samplePercentage
+	}
+}
+
+// WithStandbyDatastore fails the reads over to the given datastore, e.g. a replica of the datastore set by
+// WithDatastore in another region, while the datastore set by WithDatastore is unavailable, so that Check,
+// ListObjects, ListUsers and the other reads keep being served, possibly from slightly stale data. The failover
+// is logged and reported by the datastore_degraded metric. The Checks resolved while failed over aren't cached.
+// Writes are never sent to the standby datastore: they fail with an Unavailable error while the datastore set by
+// WithDatastore is unavailable. See [storagewrappers.NewStandbyDatastore].
+// You must close the datastore yourself after you have stopped using the Server.
+func WithStandbyDatastore(ds storage.OpenFGADatastore, opts ...storagewrappers.StandbyDatastoreOpt) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.standbyDatastore = ds
+		s.standbyDatastoreOpts = opts
+	}
+}
+
+// WithContextualTuplesOverlay makes the contextual tuples of Check, ListObjects and ListUsers shadow the stored
+// tuples with the same object, relation and user, e.g. so that a contextual tuple with a condition restricts a
+// stored tuple without one. This is NOT the standard semantics of contextual tuples: by default they can only add
+// relationships. It takes precedence over WithRejectConflictingContextualTuples.
+func WithContextualTuplesOverlay(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.contextualTuplesOverlay = enabled
+	}
+}
+
+// WithRejectConflictingContextualTuples makes Check, ListObjects and ListUsers reject the contextual tuples with a
+// condition that isn't the condition of the stored tuple with the same object, relation and user. Such a tuple
+// can't restrict the stored tuple, since contextual tuples can only add relationships. It costs a read of the
+// datastore per object type, relation and user of the contextual tuples with a condition, so it is disabled by
+// default.
+func WithRejectConflictingContextualTuples(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.rejectConflictingContextualTuples = enabled
+	}
+}
+
+// WithKnownCheckResults makes Check trust the results of subproblems supplied by the client with the
+// KnownCheckResultsHeader, e.g. for edge deployments that have already resolved some relations, instead of
+// resolving them. A client can then make a Check resolve to any result, so this must only be enabled if all the
+// clients that can call Check are trusted. Checks with known results bypass the fast paths and the Check cache.
+// Defaults to false, in which case the header is ignored.
+func WithKnownCheckResults(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.knownCheckResultsEnabled = enabled
+	}
+}
+
+// WithModelNotFoundFallback makes Check, ListObjects, StreamedListObjects, ListUsers and Expand resolve
+// against the latest authorization model of the store when the requested model is not found, e.g. for clients
+// that cache the model ID across deploys while old models are pruned, and set the
+// AuthorizationModelFallbackHeader on the response. Writes are never resolved against another model than the
+// one requested. Defaults to false, in which case such requests fail with an authorization_model_not_found error.
+func WithModelNotFoundFallback(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.modelNotFoundFallback = enabled
+	}
+}
+
+// WithDisabledConditions makes the conditions with the given names never met while resolving Check, ListObjects
+// and ListUsers, so that the tuples with these conditions don't grant any access, e.g. to turn off a break-glass
+// condition during an incident without changing the model.
+func WithDisabledConditions(names ...string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.disabledConditions = names
+	}
+}
+
+// WithCheckSelfRelations makes Check resolve the given 'objectType#relation@userType' self relations as allowed
+// for the users whose ID is the ID of the object, e.g. with 'profile#viewer@user', user:anne is a viewer of
+// profile:anne, as if there were a tuple for it. The relations computed from a self relation are allowed too.
+// It only applies to Check, see [graph.WithSelfRelations].
+func WithCheckSelfRelations(relations ...string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkSelfRelations = relations
+	}
+}
+
+// WithConditionEvaluationErrorPolicy sets how Check handles an error while evaluating the condition of a tuple,
+// e.g. a parameter of the condition missing from the context with WithCheckStrictConditionContext, see
+// [eval.EvaluationErrorPolicy]. Defaults to
+// [eval.EvaluationErrorPolicyError], which fails the Check. [eval.EvaluationErrorPolicyTreatAsTrue] fails open,
+// so it should only be used if the availability of Check matters more than the conditions of the tuples.
+func WithConditionEvaluationErrorPolicy(policy eval.EvaluationErrorPolicy) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.conditionEvaluationErrorPolicy = policy
+	}
+}
+
+// WithCheckStrictConditionContext sets whether Check, ListObjects and ListUsers handle a tuple whose condition has
+// parameters missing from both the context of the request and the context of the tuple, e.g. a Check without
+// context, as an evaluation error, to catch the requests that omit context. Check handles the error with
+// WithConditionEvaluationErrorPolicy, and ListObjects and ListUsers fail. Defaults to false: such a tuple doesn't
+// match, whatever the API and the path that reads it.
+func WithCheckStrictConditionContext(strict bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkStrictConditionContext = strict
+	}
+}
+
+// WithCheckResolutionStrategy sets how Check resolves a query, see [graph.CheckResolutionStrategy]. Defaults to
+// [graph.CheckResolutionStrategyRecursive]. [graph.CheckResolutionStrategyIterativeDeepening] returns the same
+// results, with less memory for the queries that don't need the full resolve node limit, e.g. for deployments
+// with little memory.
+func WithCheckResolutionStrategy(strategy graph.CheckResolutionStrategy) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkResolutionStrategy = strategy
+	}
+}
+
+// WithExpandMaxDirectUsers limits the number of direct users of each node of the Expand tree, so that Expand
+// responses stay bounded for objects with many direct users. The users beyond the limit are left out, and
+// replaced by a last user such as '+42 more, truncated'. If 0, all the direct users are returned.
+func WithExpandMaxDirectUsers(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.expandMaxDirectUsers = limit
+	}
+}
+
+// WithCheckMaxVisitedObjects limits the number of distinct objects that a Check can visit while it is resolved,
+// e.g. the folders of a wide folder hierarchy traversed through a tuple to userset rewrite. Checks that visit more
+// objects fail with an error reporting how many were visited. Thinking of a Check request as a tree of evaluations,
+// WithResolveNodeLimit bounds the depth of each path and this option bounds the breadth of the whole tree.
+// If 0, the number of visited objects is not limited.
+func WithCheckMaxVisitedObjects(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkMaxVisitedObjects = limit
+	}
+}
+
+// WithDenyCheckOnUnknownStore makes Check return `allowed: false` instead of a store not found
+// error when the store in the request doesn't exist. All other APIs still return the error.
+func WithDenyCheckOnUnknownStore(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.denyCheckOnUnknownStore = enabled
+	}
+}
+
+// WithDenyCheckOnStoreWithoutModel makes Check return `allowed: false` instead of an error when the store in
+// the request has no authorization model yet and the request doesn't name one, e.g. for clients that create stores
+// before writing their models. All other APIs still return the error.
+func WithDenyCheckOnStoreWithoutModel(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.denyCheckOnStoreWithoutModel = enabled
+	}
+}
+
+// WithCheckRelationAliases makes Check resolve the relations that are aliases in the RelationAliases of the
+// settings of the store against the relations they are an alias of. The settings of the store are cached
+// for WithStoreSettingsCacheTTL.
+func WithCheckRelationAliases(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkRelationAliasesEnabled = enabled
+	}
+}
+
+// WithModelChangeHandler sets a function that is called with the store ID and the ID of the authorization model
+// after each successful WriteAuthorizationModel, e.g. to warm caches or to notify the owners of the store. It's
+// called asynchronously, so it doesn't delay the response, and a panic in it is recovered and logged.
+func WithModelChangeHandler(handler func(storeID, modelID string)) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.modelChangeHandler = handler
+	}
+}
+
+// WithDefaultUserTypes makes Write and Check prepend the DefaultUserType of the settings of the store to the
+// users that don't have a type, e.g. 'anne' is 'user:anne', for clients that don't send the type of their users.
+// The settings of the store are cached for WithStoreSettingsCacheTTL.
+func WithDefaultUserTypes(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.defaultUserTypesEnabled = enabled
+	}
+}
+
+// WithUniqueStoreNames makes CreateStore reject the name of a store that already exists. Otherwise, several
+// stores may have the same name. The datastore must implement [storage.UniqueStoreNameCreator].
+func WithUniqueStoreNames(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.uniqueStoreNames = enabled
+	}
+}
+
+// WithWriteCompleteConditionContext makes Write reject the conditioned tuples whose condition context doesn't
+// have a value for every parameter of the condition, so that a missing parameter fails the Write rather than
+// the Checks that don't provide it in their context. Defaults to false.
+func WithWriteCompleteConditionContext(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.writeCompleteConditionContext = enabled
+	}
+}
+
+// WithWriteIdempotencyKeyTTL sets for how long the idempotency key of a Write, set with the IdempotencyKeyHeader,
+// is recorded. A retry of the Write with the same key after that is applied again.
+// Defaults to serverconfig.DefaultWriteIdempotencyKeyTTL.
+func WithWriteIdempotencyKeyTTL(ttl time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.writeIdempotencyKeyTTL = ttl
+	}
+}
+
+// WithStoreSettingsCacheTTL sets for how long the settings of a store read by WithCheckRelationAliases and
+// WithDefaultUserTypes are cached, so that the changes to the settings take up to that long to apply.
+// Zero disables the cache. Defaults to serverconfig.DefaultStoreSettingsCacheTTL.
+func WithStoreSettingsCacheTTL(ttl time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.storeSettingsCacheTTL = ttl
+	}
+}
+
+// WithErrorVerbosity controls how much detail the errors returned by the server APIs include.
+// With [serverErrors.ErrorVerbosityTerse], errors only include their code, so that they don't leak
+// tuples, model definitions or datastore errors to untrusted clients. Errors are still logged in full.
+// Defaults to [serverErrors.ErrorVerbosityFull].
+func WithErrorVerbosity(verbosity serverErrors.ErrorVerbosity) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.errorVerbosity = verbosity
+	}
+}
+
+// WithTupleFieldLengthLimits sets the maximum lengths of the object IDs, relations and users of the
+// tuples that are written. Defaults to [tuple.DefaultFieldLengthLimits].
+func WithTupleFieldLengthLimits(limits tuple.FieldLengthLimits) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.tupleFieldLengthLimits = limits
+	}
+}
+
+// WithContext passes the server context to allow for graceful shutdowns. The background tasks of the
+// server stop when either ctx is done or the server is closed.
 func WithContext(ctx context.Context) OpenFGAServiceV1Option {
 	return func(s *Server) {
 		s.ctx = ctx
@@ -263,6 +743,30 @@ func WithChangelogHorizonOffset(offset int) OpenFGAServiceV1Option {
 	}
 }
 
+// WithWatchPollInterval sets the interval at which Watch reads the changelog of the store once it has streamed
+// all of its changes.
+func WithWatchPollInterval(interval time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.watchPollInterval = interval
+	}
+}
+
+// WithWatchEnabled enables Watch, which it is by default. Watch is rejected with an Unimplemented error when
+// disabled.
+func WithWatchEnabled(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.watchEnabled = enabled
+	}
+}
+
+// WithWatchMaxConcurrentStreams sets the maximum number of concurrent Watch streams. The streams beyond it are
+// rejected with a ResourceExhausted error.
+func WithWatchMaxConcurrentStreams(n uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.watchMaxConcurrentStreams = n
+	}
+}
+
 // WithListObjectsDeadline affect the ListObjects API and Streamed ListObjects API only.
 // It sets the maximum amount of time that the server will spend gathering results.
 func WithListObjectsDeadline(deadline time.Duration) OpenFGAServiceV1Option {
@@ -279,6 +783,16 @@ func WithListObjectsMaxResults(limit uint32) OpenFGAServiceV1Option {
 	}
 }
 
+// WithListObjectsCheckFallback affects the ListObjects APIs only. It allows listing the objects of the relations
+// that involve an intersection or an exclusion, for which every object found must be Checked, which can be very
+// slow. If true, which is the default, a warning is logged for these relations. If false, ListObjects on these
+// relations fails with a FailedPrecondition error.
+func WithListObjectsCheckFallback(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.listObjectsCheckFallback = enabled
+	}
+}
+
 // WithListUsersDeadline affect the ListUsers API only.
 // It sets the maximum amount of time that the server will spend gathering results.
 func WithListUsersDeadline(deadline time.Duration) OpenFGAServiceV1Option {
@@ -309,6 +823,29 @@ func WithMaxConcurrentReadsForListObjects(max uint32) OpenFGAServiceV1Option {
 	}
 }
 
+// WithReverseExpansionParallelism sets the maximum number of objects found by a single datastore read of the reverse
+// expansion of a ListObjects call, e.g. the groups that a user is a member of, that are expanded concurrently. Lower it
+// to reduce the load of ListObjects on the datastore, at the expense of its latency. If 0, the resolve node breadth
+// limit is used, see WithResolveNodeBreadthLimit.
+func WithReverseExpansionParallelism(n uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.reverseExpansionParallelism = n
+	}
+}
+
+// WithReverseExpansionBackoff delays the datastore reads of the reverse expansions of ListObjects calls while the
+// datastore shows signs of overload, i.e. reads that fail or that take longer than latencyThreshold, by up to maxDelay.
+// The delay adapts to the datastore across all ListObjects calls. If latencyThreshold is 0, only the reads that fail
+// are signs of overload. If maxDelay is 0, which is the default, the reads are never delayed.
+func WithReverseExpansionBackoff(latencyThreshold, maxDelay time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.reverseExpansionBackoff = nil
+		if maxDelay > 0 {
+			s.reverseExpansionBackoff = reverseexpand.NewOverloadBackoff(latencyThreshold, maxDelay)
+		}
+	}
+}
+
 // WithMaxConcurrentReadsForCheck sets a limit on the number of datastore reads that can be in flight for a given Check call.
 // This number should be set depending on the RPS expected for Check and ListObjects APIs, the number of OpenFGA replicas running,
 // and the number of connections the datastore allows.
@@ -366,6 +903,115 @@ func WithCheckQueryCacheTTL(ttl time.Duration) OpenFGAServiceV1Option {
 	}
 }
 
+// WithCheckQueryCachePositiveTTL sets the TTL of the cached Check results that are allowed, which
+// overrides WithCheckQueryCacheTTL for them. If zero, WithCheckQueryCacheTTL applies.
+// Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCachePositiveTTL(ttl time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCachePositiveTTL = ttl
+	}
+}
+
+// WithCheckQueryCacheNegativeTTL sets the TTL of the cached Check results that are not allowed, which
+// overrides WithCheckQueryCacheTTL for them. If zero, WithCheckQueryCacheTTL applies.
+// Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheNegativeTTL(ttl time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCacheNegativeTTL = ttl
+	}
+}
+
+// WithCheckQueryCacheEmptyObjectTTL sets the TTL of the cached knowledge of whether the objects that tuple to
+// usersets lead to have any tuple, so that the Checks of the objects without tuples are skipped without reading.
+// If zero, the objects are always read. Needs WithCheckQueryCacheEnabled set to true.
+// See [graph.WithEmptyObjectCache].
+func WithCheckQueryCacheEmptyObjectTTL(ttl time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCacheEmptyObjectTTL = ttl
+	}
+}
+
+// WithCheckQueryCacheRejectNonDeterministicConditions makes WriteAuthorizationModel reject the models with
+// conditions that call non-deterministic functions, e.g. 'now()', while WithCheckQueryCacheEnabled is set to true.
+// Otherwise, such models are accepted, and the Checks that involve these conditions are never cached.
+func WithCheckQueryCacheRejectNonDeterministicConditions(reject bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCacheRejectNonDeterministicConditions = reject
+	}
+}
+
+// WithCheckQueryCacheNonCacheableContextualTupleRelations marks the contextual tuples of the given relations,
+// each of the form 'objectType#relation', as volatile: Checks that include any of them as a contextual tuple
+// are resolved without reading from or writing to the cache, while the rest of the Checks are cached as usual.
+// See [graph.WithNonCacheableContextualTupleRelations].
+// Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheNonCacheableContextualTupleRelations(relations ...string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCacheNonCacheableContextualTupleRelations = relations
+	}
+}
+
+// WithCheckQueryCacheNonCacheableRelations marks the given relations, each of the form 'objectType#relation', as
+// volatile: Checks of these relations, including the sub-problems of other Checks, are resolved without reading
+// from or writing to the cache, while the rest of the Checks are cached as usual.
+// See [graph.WithNonCacheableRelations].
+// Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheNonCacheableRelations(relations ...string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCacheNonCacheableRelations = relations
+	}
+}
+
+// WithWriteConflictStrategy sets how Write resolves a tuple that is both deleted and written by the same
+// request, see [commands.WriteConflictStrategy]. Defaults to [commands.WriteConflictStrategyReject].
+func WithWriteConflictStrategy(strategy commands.WriteConflictStrategy) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.writeConflictStrategy = strategy
+	}
+}
+
+// WithCheckQueryCacheBackend stores the cached Check results in the given backend, e.g. a cache shared by
+// every instance, instead of in memory. If the backend fails, Checks are resolved without the cache rather
+// than failing, see [graph.WithCacheBackend].
+// Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheBackend(backend graph.CheckCacheBackend, opts ...graph.CacheBackendOpt) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCacheBackend = backend
+		s.checkQueryCacheBackendOpts = opts
+	}
+}
+
+// WithCheckQueryCacheChangelogInvalidationInterval makes the server poll the changelog of the stores of the Check
+// results it cached every interval, and invalidate the cached results of the stores with new changes, so that they
+// are invalidated even if the instance that wrote the tuples failed to, see [graph.ChangelogCacheInvalidator].
+// If zero, the changelog isn't polled. Needs WithCheckQueryCacheBackend with a backend that implements
+// [graph.CheckCacheInvalidator].
+func WithCheckQueryCacheChangelogInvalidationInterval(interval time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCacheChangelogInvalidationInterval = interval
+	}
+}
+
+// WithSharedAuthorizationModelCache makes the server also cache the authorization models in the backend set
+// with WithCheckQueryCacheBackend, so that the model read from the datastore by one instance is read from the
+// backend by the others, e.g. when every instance starts after a deploy. Models are immutable, so they are never
+// invalidated. Needs WithCheckQueryCacheBackend.
+func WithSharedAuthorizationModelCache(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.sharedAuthorizationModelCache = enabled
+	}
+}
+
+// WithMetricsSink sets the sink to which the server emits its metrics instead of Prometheus, e.g. to forward them
+// to another metrics system. Only the metrics of the Server itself are emitted to the sink, i.e. the request
+// histograms and the Watch metrics. The metrics of the Check resolution, the caches, the datastore wrappers and
+// the interceptors are always exported to Prometheus.
+func WithMetricsSink(sink telemetry.MetricsSink) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.metricsSink = sink
+	}
+}
+
 // WithRequestDurationByQueryHistogramBuckets sets the buckets used in labelling the requestDurationByQueryAndDispatchHistogram.
 func WithRequestDurationByQueryHistogramBuckets(buckets []uint) OpenFGAServiceV1Option {
 	return func(s *Server) {
@@ -388,6 +1034,25 @@ func WithMaxAuthorizationModelSizeInBytes(size int) OpenFGAServiceV1Option {
 	}
 }
 
+// WithMaxAuthorizationModelsPerStore sets the maximum number of authorization models of the stores whose settings
+// don't set one. Writing a model to a store that has the maximum number of models fails until the old models of
+// the store are pruned, see PruneAuthorizationModels. If zero, which is the default, the number is unlimited.
+func WithMaxAuthorizationModelsPerStore(n int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxAuthorizationModelsPerStore = n
+	}
+}
+
+// WithMaxTuplesPerWrite sets the maximum number of tuples (writes and deletes combined) allowed in a Write call.
+// Calls with more tuples than the datastore's MaxTuplesPerWrite are split into batches that are written
+// within a single transaction, which requires the datastore to implement [storage.TransactionalBatchWriter].
+// It defaults to the datastore's MaxTuplesPerWrite.
+func WithMaxTuplesPerWrite(n int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxTuplesPerWrite = n
+	}
+}
+
 // WithDispatchThrottlingCheckResolverEnabled sets whether dispatch throttling is enabled for Check requests.
 // Enabling this feature will prioritize dispatched requests requiring less than the configured dispatch
 // threshold over requests whose dispatch count exceeds the configured threshold.
@@ -522,12 +1187,17 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 	s := &Server{
 		logger:                           logger.NewNoopLogger(),
 		encoder:                          encoder.NewBase64Encoder(),
+		tupleFieldLengthLimits:           tuple.DefaultFieldLengthLimits,
 		transport:                        gateway.NewNoopTransport(),
 		changelogHorizonOffset:           serverconfig.DefaultChangelogHorizonOffset,
+		watchPollInterval:                serverconfig.DefaultWatchPollInterval,
+		watchEnabled:                     true,
+		watchMaxConcurrentStreams:        serverconfig.DefaultWatchMaxConcurrentStreams,
 		resolveNodeLimit:                 serverconfig.DefaultResolveNodeLimit,
 		resolveNodeBreadthLimit:          serverconfig.DefaultResolveNodeBreadthLimit,
 		listObjectsDeadline:              serverconfig.DefaultListObjectsDeadline,
 		listObjectsMaxResults:            serverconfig.DefaultListObjectsMaxResults,
+		listObjectsCheckFallback:         serverconfig.DefaultListObjectsCheckFallback,
 		listUsersDeadline:                serverconfig.DefaultListUsersDeadline,
 		listUsersMaxResults:              serverconfig.DefaultListUsersMaxResults,
 		maxConcurrentReadsForCheck:       serverconfig.DefaultMaxConcurrentReadsForCheck,
@@ -536,6 +1206,11 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		maxAuthorizationModelSizeInBytes: serverconfig.DefaultMaxAuthorizationModelSizeInBytes,
 		maxAuthorizationModelCacheSize:   serverconfig.DefaultMaxAuthorizationModelCacheSize,
 		experimentals:                    make([]ExperimentalFeatureFlag, 0, 10),
+		writeConflictStrategy:            commands.WriteConflictStrategyReject,
+		conditionEvaluationErrorPolicy:   eval.EvaluationErrorPolicyError,
+		checkResolutionStrategy:          graph.CheckResolutionStrategyRecursive,
+		writeIdempotencyKeyTTL:           serverconfig.DefaultWriteIdempotencyKeyTTL,
+		storeSettingsCacheTTL:            serverconfig.DefaultStoreSettingsCacheTTL,
 
 		checkQueryCacheEnabled: serverconfig.DefaultCheckQueryCacheEnable,
 		checkQueryCacheLimit:   serverconfig.DefaultCheckQueryCacheLimit,
@@ -545,6 +1220,7 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 
 		requestDurationByQueryHistogramBuckets:         []uint{50, 200},
 		requestDurationByDispatchCountHistogramBuckets: []uint{50, 200},
+		metricsSink: prometheusMetricsSink{},
 		serviceName: openfgav1.OpenFGAService_ServiceDesc.ServiceName,
 
 		checkDispatchThrottlingEnabled:          serverconfig.DefaultCheckDispatchThrottlingEnabled,
@@ -570,6 +1246,99 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		return nil, fmt.Errorf("a datastore option must be provided")
 	}
 
+	if !slices.Contains(commands.WriteConflictStrategies, s.writeConflictStrategy) {
+		return nil, fmt.Errorf("unknown write conflict strategy '%s', must be one of %v", s.writeConflictStrategy, commands.WriteConflictStrategies)
+	}
+
+	if !slices.Contains(eval.EvaluationErrorPolicies, s.conditionEvaluationErrorPolicy) {
+		return nil, fmt.Errorf("unknown condition evaluation error policy '%s', must be one of %v", s.conditionEvaluationErrorPolicy, eval.EvaluationErrorPolicies)
+	}
+
+	for _, relation := range s.checkSelfRelations {
+		selfRelation, err := graph.ParseSelfRelation(relation)
+		if err != nil {
+			return nil, err
+		}
+		s.selfRelations = append(s.selfRelations, selfRelation)
+	}
+
+	if s.sharedAuthorizationModelCache && s.checkQueryCacheBackend == nil {
+		return nil, fmt.Errorf("the shared authorization model cache needs a check query cache backend")
+	}
+
+	checkQueryCacheInvalidator, _ := s.checkQueryCacheBackend.(graph.CheckCacheInvalidator)
+	if s.checkQueryCacheChangelogInvalidationInterval > 0 && checkQueryCacheInvalidator == nil {
+		return nil, fmt.Errorf("the changelog invalidation of the check query cache needs a check query cache backend that can invalidate its entries")
+	}
+
+	if !slices.Contains(graph.CheckResolutionStrategies, s.checkResolutionStrategy) {
+		return nil, fmt.Errorf("unknown check resolution strategy '%s', must be one of %v", s.checkResolutionStrategy, graph.CheckResolutionStrategies)
+	}
+
+	// the standby wraps the datastore before its optional interfaces are captured, so that their calls fail
+	// over, or fail fast, too
+	var standby *storagewrappers.StandbyDatastore
+	if s.standbyDatastore != nil {
+		standby = storagewrappers.NewStandbyDatastore(s.datastore, s.standbyDatastore, s.logger, s.standbyDatastoreOpts...)
+		s.datastore = standby
+	}
+
+	s.batchWriter, _ = storage.As[storage.TransactionalBatchWriter](s.datastore)
+	s.storeSettings, _ = storage.As[storage.StoreSettingsBackend](s.datastore)
+	if s.storeSettingsCacheTTL < 0 {
+		return nil, fmt.Errorf("the store settings cache TTL must not be negative")
+	}
+	if s.storeSettings != nil && s.storeSettingsCacheTTL > 0 && (s.checkRelationAliasesEnabled || s.defaultUserTypesEnabled) {
+		s.storeSettingsCache = storage.NewInMemoryLRUCache[*storage.StoreSettings]()
+	}
+	s.modelPruner, _ = storage.As[storage.AuthorizationModelPruner](s.datastore)
+	s.idempotentWriter, _ = storage.As[storage.IdempotentWriter](s.datastore)
+	if s.writeIdempotencyKeyTTL <= 0 {
+		return nil, fmt.Errorf("the write idempotency key TTL must be greater than zero")
+	}
+	if s.watchPollInterval <= 0 {
+		return nil, fmt.Errorf("the watch poll interval must be greater than zero")
+	}
+	if s.watchMaxConcurrentStreams == 0 {
+		return nil, fmt.Errorf("the watch max concurrent streams must be greater than zero")
+	}
+	s.watchStreams = make(chan struct{}, s.watchMaxConcurrentStreams)
+	if s.uniqueStoreNames {
+		creator, ok := storage.As[storage.UniqueStoreNameCreator](s.datastore)
+		if !ok {
+			return nil, fmt.Errorf("the datastore doesn't support unique store names")
+		}
+		s.uniqueStoreNameCreator = creator
+	}
+	if s.maxAuthorizationModelsPerStore < 0 {
+		return nil, fmt.Errorf("the maximum number of authorization models per store must not be negative")
+	}
+	if s.maxTuplesPerWrite > s.datastore.MaxTuplesPerWrite() && s.batchWriter == nil {
+		return nil, fmt.Errorf("the datastore doesn't support writing more than %d tuples per write", s.datastore.MaxTuplesPerWrite())
+	}
+
+	for objectType, ds := range s.typeDatastores {
+		if objectType == "" || ds == nil {
+			return nil, fmt.Errorf("type datastores must specify an object type and a datastore")
+		}
+	}
+
+	if s.shadowReadSamplePercentage < 0 || s.shadowReadSamplePercentage > 100 {
+		return nil, fmt.Errorf("shadow read sample percentage must be between 0 and 100")
+	}
+
+	for _, relation := range s.checkQueryCacheNonCacheableContextualTupleRelations {
+		if objectType, relationName := tuple.SplitObjectRelation(relation); objectType == "" || relationName == "" {
+			return nil, fmt.Errorf("non-cacheable contextual tuple relation '%s' must be of the form 'objectType#relation'", relation)
+		}
+	}
+
+	for _, relation := range s.checkQueryCacheNonCacheableRelations {
+		if objectType, relationName := tuple.SplitObjectRelation(relation); objectType == "" || relationName == "" {
+			return nil, fmt.Errorf("non-cacheable relation '%s' must be of the form 'objectType#relation'", relation)
+		}
+	}
+
 	if len(s.requestDurationByQueryHistogramBuckets) == 0 {
 		return nil, fmt.Errorf("request duration datastore count buckets must not be empty")
 	}
@@ -577,6 +1346,10 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 	if len(s.requestDurationByDispatchCountHistogramBuckets) == 0 {
 		return nil, fmt.Errorf("request duration by dispatch count buckets must not be empty")
 	}
+
+	if s.metricsSink == nil {
+		return nil, fmt.Errorf("the metrics sink must not be nil")
+	}
 	if s.checkDispatchThrottlingEnabled && s.checkDispatchThrottlingMaxThreshold != 0 && s.checkDispatchThrottlingDefaultThreshold > s.checkDispatchThrottlingMaxThreshold {
 		return nil, fmt.Errorf("check default dispatch throttling threshold must be equal or smaller than max dispatch threshold for Check")
 	}
@@ -591,6 +1364,11 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 
 	// below this point, don't throw errors or we may leak resources in tests
 
+	if s.ctx == nil {
+		s.ctx = context.Background()
+	}
+	s.ctx, s.cancel = context.WithCancel(s.ctx)
+
 	checkDispatchThrottlingOptions := []graph.DispatchThrottlingCheckResolverOpt{}
 	if s.checkDispatchThrottlingEnabled {
 		checkDispatchThrottlingOptions = []graph.DispatchThrottlingCheckResolverOpt{
@@ -612,19 +1390,55 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		}
 	}
 
+	cachedCheckResolverOpts := []graph.CachedCheckResolverOpt{
+		graph.WithMaxCacheSize(int64(s.checkQueryCacheLimit)),
+		graph.WithLogger(s.logger),
+		graph.WithCacheTTL(s.checkQueryCacheTTL),
+		graph.WithPositiveCacheTTL(s.checkQueryCachePositiveTTL),
+		graph.WithNegativeCacheTTL(s.checkQueryCacheNegativeTTL),
+		graph.WithNonCacheableContextualTupleRelations(s.checkQueryCacheNonCacheableContextualTupleRelations...),
+		graph.WithNonCacheableRelations(s.checkQueryCacheNonCacheableRelations...),
+		graph.WithEnabledConsistencyParams(s.IsExperimentallyEnabled(ExperimentalEnableConsistencyParams)),
+	}
+	if s.checkQueryCacheBackend != nil {
+		backend := s.checkQueryCacheBackend
+		if s.checkQueryCacheEnabled && s.checkQueryCacheChangelogInvalidationInterval > 0 {
+			trackingTTL := max(s.checkQueryCacheTTL, s.checkQueryCachePositiveTTL, s.checkQueryCacheNegativeTTL)
+			invalidator := graph.NewChangelogCacheInvalidator(s.datastore, checkQueryCacheInvalidator,
+				s.checkQueryCacheChangelogInvalidationInterval, trackingTTL,
+				time.Duration(s.changelogHorizonOffset)*time.Minute, s.logger)
+			backend = invalidator.TrackStores(backend)
+
+			s.changelogInvalidatorDone.Add(1)
+			go func() {
+				defer s.changelogInvalidatorDone.Done()
+				invalidator.Run(s.ctx)
+			}()
+		}
+		cachedCheckResolverOpts = append(cachedCheckResolverOpts, graph.WithCacheBackend(backend, s.checkQueryCacheBackendOpts...))
+	}
+
+	localCheckerOpts := []graph.LocalCheckerOption{
+		graph.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
+		graph.WithOptimizations(s.IsExperimentallyEnabled(ExperimentalCheckOptimizations)),
+		graph.WithMaxVisitedObjects(s.checkMaxVisitedObjects),
+		graph.WithSelfRelations(s.selfRelations...),
+	}
+	if s.checkQueryCacheEnabled {
+		localCheckerOpts = append(localCheckerOpts, graph.WithEmptyObjectCache(s.checkQueryCacheEmptyObjectTTL, int64(s.checkQueryCacheLimit)))
+	}
+	if standby != nil {
+		// the results read from the standby may be stale, so they aren't cached
+		cachedCheckResolverOpts = append(cachedCheckResolverOpts, graph.WithCacheWritesPaused(standby.Degraded))
+		localCheckerOpts = append(localCheckerOpts, graph.WithEmptyObjectCacheWritesPaused(standby.Degraded))
+	}
+
 	s.checkResolver, s.checkResolverCloser = graph.NewOrderedCheckResolvers([]graph.CheckResolverOrderedBuilderOpt{
-		graph.WithLocalCheckerOpts([]graph.LocalCheckerOption{
-			graph.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
-			graph.WithOptimizations(s.IsExperimentallyEnabled(ExperimentalCheckOptimizations)),
-		}...),
-		graph.WithCachedCheckResolverOpts(s.checkQueryCacheEnabled, []graph.CachedCheckResolverOpt{
-			graph.WithMaxCacheSize(int64(s.checkQueryCacheLimit)),
-			graph.WithLogger(s.logger),
-			graph.WithCacheTTL(s.checkQueryCacheTTL),
-			graph.WithEnabledConsistencyParams(s.IsExperimentallyEnabled(ExperimentalEnableConsistencyParams)),
-		}...),
+		graph.WithLocalCheckerOpts(localCheckerOpts...),
+		graph.WithCachedCheckResolverOpts(s.checkQueryCacheEnabled, cachedCheckResolverOpts...),
 		graph.WithDispatchThrottlingCheckResolverOpts(s.checkDispatchThrottlingEnabled, checkDispatchThrottlingOptions...),
 		graph.WithTrackerCheckResolverOpts(s.checkTrackerEnabled, checkTrackerOptions...),
+		graph.WithIterativeDeepeningCheckResolverOpts(s.checkResolutionStrategy == graph.CheckResolutionStrategyIterativeDeepening),
 	}...).Build()
 
 	if s.listObjectsDispatchThrottlingEnabled {
@@ -635,7 +1449,16 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		s.listUsersDispatchThrottler = throttler.NewConstantRateThrottler(s.listUsersDispatchThrottlingFrequency, "list_users_dispatch_throttle")
 	}
 
-	s.datastore = storagewrappers.NewCachedOpenFGADatastore(storagewrappers.NewContextWrapper(s.datastore), s.maxAuthorizationModelCacheSize)
+	var modelCacheOpts []storagewrappers.CachedOpenFGADatastoreOpt
+	if s.sharedAuthorizationModelCache {
+		modelCacheOpts = append(modelCacheOpts, storagewrappers.WithSharedModelCache(s.checkQueryCacheBackend))
+	}
+	s.datastore = storagewrappers.NewErrorMetricsDatastore(storagewrappers.NewContextWrapper(s.datastore))
+	s.datastore = storagewrappers.NewCachedOpenFGADatastore(s.datastore, s.maxAuthorizationModelCacheSize, modelCacheOpts...)
+	s.tupleReader = storagewrappers.NewTypeRoutingTupleReader(s.datastore, s.typeDatastores)
+	if s.shadowDatastore != nil {
+		s.tupleReader = storagewrappers.NewShadowTupleReader(s.tupleReader, s.shadowDatastore, s.shadowReadSamplePercentage, s.logger)
+	}
 
 	s.typesystemResolver, s.typesystemResolverStop = typesystem.MemoizedTypesystemResolverFunc(s.datastore)
 
@@ -644,6 +1467,10 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 
 // Close releases the server resources.
 func (s *Server) Close() {
+	s.cancel()
+	s.modelChangeHandlers.Wait()
+	s.changelogInvalidatorDone.Wait()
+
 	if s.listObjectsDispatchThrottler != nil {
 		s.listObjectsDispatchThrottler.Close()
 	}
@@ -654,10 +1481,15 @@ func (s *Server) Close() {
 	s.checkResolverCloser()
 	s.datastore.Close()
 	s.typesystemResolverStop()
+	if s.storeSettingsCache != nil {
+		s.storeSettingsCache.Stop()
+	}
 }
 
-func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequest) (*openfgav1.ListObjectsResponse, error) {
-	err := s.validateConsistencyRequest(req.GetConsistency())
+func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequest) (_ *openfgav1.ListObjectsResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	err = s.validateConsistencyRequest(req.GetConsistency())
 	if err != nil {
 		return nil, err
 	}
@@ -686,16 +1518,25 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 		Service: s.serviceName,
 		Method:  methodName,
 	})
+	ctx = withReadDatastore(ctx)
+	ctx = s.withDisabledConditions(ctx)
+	ctx = eval.ContextWithStrictConditionContext(ctx, s.checkStrictConditionContext)
 
 	storeID := req.GetStoreId()
 
-	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	// the model is resolved once, so the whole request uses it even if a newer model is written meanwhile
+	typesys, err := s.resolveQueryTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = s.withContextualTuples(ctx, storeID, req.GetContextualTuples().GetTupleKeys())
 	if err != nil {
 		return nil, err
 	}
 
 	q, err := commands.NewListObjectsQuery(
-		s.datastore,
+		s.tupleReader,
 		s.checkResolver,
 		commands.WithLogger(s.logger),
 		commands.WithListObjectsDeadline(s.listObjectsDeadline),
@@ -709,6 +1550,9 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 		commands.WithResolveNodeLimit(s.resolveNodeLimit),
 		commands.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
 		commands.WithMaxConcurrentReads(s.maxConcurrentReadsForListObjects),
+		commands.WithReverseExpansionParallelism(s.reverseExpansionParallelism),
+		commands.WithReverseExpansionBackoff(s.reverseExpansionBackoff),
+		commands.WithListObjectsCheckFallback(s.listObjectsCheckFallback),
 	)
 	if err != nil {
 		return nil, serverErrors.NewInternalError("", err)
@@ -739,35 +1583,37 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 
 	grpc_ctxtags.Extract(ctx).Set(datastoreQueryCountHistogramName, datastoreQueryCount)
 	span.SetAttributes(attribute.Float64(datastoreQueryCountHistogramName, datastoreQueryCount))
-	datastoreQueryCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(datastoreQueryCount)
+	s.metricsSink.ObserveHistogram(datastoreQueryCountHistogramName, datastoreQueryCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
 
 	dispatchCount := float64(result.ResolutionMetadata.DispatchCounter.Load())
 
 	grpc_ctxtags.Extract(ctx).Set(dispatchCountHistogramName, dispatchCount)
 	span.SetAttributes(attribute.Float64(dispatchCountHistogramName, dispatchCount))
-	dispatchCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(dispatchCount)
-
-	requestDurationHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-		utils.Bucketize(uint(*result.ResolutionMetadata.DatastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
-		utils.Bucketize(uint(result.ResolutionMetadata.DispatchCounter.Load()), s.requestDurationByDispatchCountHistogramBuckets),
-		req.GetConsistency().String(),
-	).Observe(float64(time.Since(start).Milliseconds()))
+	s.metricsSink.ObserveHistogram(dispatchCountHistogramName, dispatchCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
+
+	s.metricsSink.ObserveHistogram(requestDurationHistogramName, float64(time.Since(start).Milliseconds()), map[string]string{
+		"grpc_service":          s.serviceName,
+		"grpc_method":           methodName,
+		"datastore_query_count": utils.Bucketize(uint(*result.ResolutionMetadata.DatastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
+		"dispatch_count":        utils.Bucketize(uint(result.ResolutionMetadata.DispatchCounter.Load()), s.requestDurationByDispatchCountHistogramBuckets),
+		"consistency":           req.GetConsistency().String(),
+	})
 
 	return &openfgav1.ListObjectsResponse{
 		Objects: result.Objects,
 	}, nil
 }
 
-func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest, srv openfgav1.OpenFGAService_StreamedListObjectsServer) error {
-	err := s.validateConsistencyRequest(req.GetConsistency())
+func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest, srv openfgav1.OpenFGAService_StreamedListObjectsServer) (err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	err = s.validateConsistencyRequest(req.GetConsistency())
 	if err != nil {
 		return err
 	}
@@ -795,16 +1641,24 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 		Service: s.serviceName,
 		Method:  methodName,
 	})
+	ctx = withReadDatastore(ctx)
+	ctx = s.withDisabledConditions(ctx)
+	ctx = eval.ContextWithStrictConditionContext(ctx, s.checkStrictConditionContext)
 
 	storeID := req.GetStoreId()
 
-	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	typesys, err := s.resolveQueryTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		return err
+	}
+
+	ctx, err = s.withContextualTuples(ctx, storeID, req.GetContextualTuples().GetTupleKeys())
 	if err != nil {
 		return err
 	}
 
 	q, err := commands.NewListObjectsQuery(
-		s.datastore,
+		s.tupleReader,
 		s.checkResolver,
 		commands.WithLogger(s.logger),
 		commands.WithListObjectsDeadline(s.listObjectsDeadline),
@@ -818,6 +1672,9 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 		commands.WithResolveNodeLimit(s.resolveNodeLimit),
 		commands.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
 		commands.WithMaxConcurrentReads(s.maxConcurrentReadsForListObjects),
+		commands.WithReverseExpansionParallelism(s.reverseExpansionParallelism),
+		commands.WithReverseExpansionBackoff(s.reverseExpansionBackoff),
+		commands.WithListObjectsCheckFallback(s.listObjectsCheckFallback),
 	)
 	if err != nil {
 		return serverErrors.NewInternalError("", err)
@@ -838,33 +1695,35 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 
 	grpc_ctxtags.Extract(ctx).Set(datastoreQueryCountHistogramName, datastoreQueryCount)
 	span.SetAttributes(attribute.Float64(datastoreQueryCountHistogramName, datastoreQueryCount))
-	datastoreQueryCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(datastoreQueryCount)
+	s.metricsSink.ObserveHistogram(datastoreQueryCountHistogramName, datastoreQueryCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
 
 	dispatchCount := float64(resolutionMetadata.DispatchCounter.Load())
 
 	grpc_ctxtags.Extract(ctx).Set(dispatchCountHistogramName, dispatchCount)
 	span.SetAttributes(attribute.Float64(dispatchCountHistogramName, dispatchCount))
-	dispatchCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(dispatchCount)
-
-	requestDurationHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-		utils.Bucketize(uint(*resolutionMetadata.DatastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
-		utils.Bucketize(uint(resolutionMetadata.DispatchCounter.Load()), s.requestDurationByDispatchCountHistogramBuckets),
-		req.GetConsistency().String(),
-	).Observe(float64(time.Since(start).Milliseconds()))
+	s.metricsSink.ObserveHistogram(dispatchCountHistogramName, dispatchCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
+
+	s.metricsSink.ObserveHistogram(requestDurationHistogramName, float64(time.Since(start).Milliseconds()), map[string]string{
+		"grpc_service":          s.serviceName,
+		"grpc_method":           methodName,
+		"datastore_query_count": utils.Bucketize(uint(*resolutionMetadata.DatastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
+		"dispatch_count":        utils.Bucketize(uint(resolutionMetadata.DispatchCounter.Load()), s.requestDurationByDispatchCountHistogramBuckets),
+		"consistency":           req.GetConsistency().String(),
+	})
 
 	return nil
 }
 
-func (s *Server) Read(ctx context.Context, req *openfgav1.ReadRequest) (*openfgav1.ReadResponse, error) {
-	err := s.validateConsistencyRequest(req.GetConsistency())
+func (s *Server) Read(ctx context.Context, req *openfgav1.ReadRequest) (_ *openfgav1.ReadResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	err = s.validateConsistencyRequest(req.GetConsistency())
 	if err != nil {
 		return nil, err
 	}
@@ -891,6 +1750,7 @@ func (s *Server) Read(ctx context.Context, req *openfgav1.ReadRequest) (*openfga
 	q := commands.NewReadQuery(s.datastore,
 		commands.WithReadQueryLogger(s.logger),
 		commands.WithReadQueryEncoder(s.encoder),
+		commands.WithReadQueryStoreSettings(s.storeSettings),
 	)
 	return q.Execute(ctx, &openfgav1.ReadRequest{
 		StoreId:           req.GetStoreId(),
@@ -901,7 +1761,9 @@ func (s *Server) Read(ctx context.Context, req *openfgav1.ReadRequest) (*openfga
 	})
 }
 
-func (s *Server) Write(ctx context.Context, req *openfgav1.WriteRequest) (*openfgav1.WriteResponse, error) {
+func (s *Server) Write(ctx context.Context, req *openfgav1.WriteRequest) (_ *openfgav1.WriteResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "Write")
 	defer span.End()
 
@@ -918,7 +1780,25 @@ func (s *Server) Write(ctx context.Context, req *openfgav1.WriteRequest) (*openf
 
 	storeID := req.GetStoreId()
 
+	idempotencyKey, err := s.idempotencyKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		if storeErr := s.storeNotFoundError(ctx, storeID, err); storeErr != nil {
+			return nil, storeErr
+		}
+		return nil, err
+	}
+
+	settings, err := s.requestStoreSettings(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	writes, deletes, err := s.withDefaultUserTypeInWrites(settings, typesys, req.GetWrites(), req.GetDeletes())
 	if err != nil {
 		return nil, err
 	}
@@ -926,17 +1806,310 @@ func (s *Server) Write(ctx context.Context, req *openfgav1.WriteRequest) (*openf
 	cmd := commands.NewWriteCommand(
 		s.datastore,
 		commands.WithWriteCmdLogger(s.logger),
+		commands.WithWriteCmdMaxTuplesPerWrite(s.maxTuplesPerWrite),
+		commands.WithWriteCmdBatchWriter(s.batchWriter),
+		commands.WithWriteCmdFieldLengthLimits(s.tupleFieldLengthLimits),
+		commands.WithWriteCmdStoreSettings(s.storeSettings),
+		commands.WithWriteCmdConflictStrategy(s.writeConflictStrategy),
+		commands.WithWriteCmdCompleteConditionContext(s.writeCompleteConditionContext),
+		commands.WithWriteCmdIdempotencyKey(s.idempotentWriter, idempotencyKey, s.writeIdempotencyKeyTTL),
 	)
-	return cmd.Execute(ctx, &openfgav1.WriteRequest{
+	resp, err := cmd.Execute(ctx, &openfgav1.WriteRequest{
 		StoreId:              storeID,
 		AuthorizationModelId: typesys.GetAuthorizationModelID(), // the resolved model id
-		Writes:               req.GetWrites(),
-		Deletes:              req.GetDeletes(),
+		Writes:               writes,
+		Deletes:              deletes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateCheckCache(ctx, storeID)
+	return resp, nil
+}
+
+// invalidateCheckCache invalidates the cached Check results of the store, if the Check cache backend can
+// invalidate them, and waits for the invalidation to propagate if the request set WaitForCacheInvalidationHeader.
+// A failed invalidation is logged rather than returned, since the tuples are written already: the stale results
+// expire with their TTL, as they do when the backend can't invalidate them.
+func (s *Server) invalidateCheckCache(ctx context.Context, storeID string) {
+	invalidator, ok := s.checkQueryCacheBackend.(graph.CheckCacheInvalidator)
+	if !ok || !s.checkQueryCacheEnabled {
+		return
+	}
+
+	wait := false
+	if values := metadata.ValueFromIncomingContext(ctx, WaitForCacheInvalidationHeader); len(values) > 0 && values[0] == "true" {
+		wait = true
+	}
+
+	err := invalidator.InvalidateStore(ctx, storeID)
+	if err == nil && wait {
+		err = invalidator.AwaitStoreInvalidation(ctx, storeID)
+	}
+	if err != nil {
+		s.logger.WarnWithContext(ctx, "failed to invalidate the check cache of the store",
+			zap.String("store_id", storeID),
+			zap.Error(err),
+		)
+	}
+
+	if wait {
+		s.transport.SetHeader(ctx, CacheInvalidationAcknowledgedHeader, strconv.FormatBool(err == nil))
+	}
+}
+
+// WarmCache runs the Checks of the request against the authorization model of the request, or the latest
+// authorization model of the store if none is specified, so that their results are cached by the Check query cache.
+// It returns once all the Checks have been resolved, e.g. so that a new model can be warmed before shifting traffic
+// to it. It returns an error if the Check query cache is not enabled.
+func (s *Server) WarmCache(ctx context.Context, req *commands.WarmCacheRequest) (_ *commands.WarmCacheResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	ctx, span := tracer.Start(ctx, "WarmCache", trace.WithAttributes(
+		attribute.KeyValue{Key: "store_id", Value: attribute.StringValue(req.StoreID)},
+	))
+	defer span.End()
+
+	if !s.checkQueryCacheEnabled {
+		return nil, status.Error(codes.FailedPrecondition, "the check query cache is not enabled")
+	}
+
+	typesys, err := s.resolveTypesystem(ctx, req.StoreID, req.AuthorizationModelID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cached results must be the ones that Check would resolve
+	ctx = s.withDisabledConditions(ctx)
+	ctx = eval.ContextWithEvaluationErrorPolicy(ctx, s.conditionEvaluationErrorPolicy)
+	ctx = eval.ContextWithStrictConditionContext(ctx, s.checkStrictConditionContext)
+	ctx = typesystem.ContextWithTypesystem(ctx, typesys)
+
+	cmd := commands.NewWarmCacheCommand(
+		s.tupleReader,
+		s.datastore,
+		s.checkResolver,
+		commands.WithWarmCacheCmdLogger(s.logger),
+		commands.WithWarmCacheResolveNodeLimit(s.resolveNodeLimit),
+		commands.WithWarmCacheMaxConcurrentReads(s.maxConcurrentReadsForCheck),
+	)
+	return cmd.Execute(ctx, req)
+}
+
+// PruneAuthorizationModels deletes the authorization models of the store except the most recent ones, the ones
+// that have assertions, and the ones that requests to this server were pinned to within the last
+// pinnedModelInUseWindow. It returns an error if the datastore can't prune authorization models.
+func (s *Server) PruneAuthorizationModels(ctx context.Context, req *commands.PruneAuthorizationModelsRequest) (_ *commands.PruneAuthorizationModelsResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	ctx, span := tracer.Start(ctx, "PruneAuthorizationModels", trace.WithAttributes(
+		attribute.KeyValue{Key: "store_id", Value: attribute.StringValue(req.StoreID)},
+	))
+	defer span.End()
+
+	if s.modelPruner == nil {
+		return nil, status.Error(codes.Unimplemented, "the datastore doesn't support pruning authorization models")
+	}
+
+	cmd := commands.NewPruneAuthorizationModelsCommand(
+		s.modelPruner,
+		commands.WithPruneAuthorizationModelsCmdLogger(s.logger),
+		commands.WithPruneAuthorizationModelsInUse(s.pinnedModelIDs),
+	)
+	return cmd.Execute(ctx, req)
+}
+
+// pinnedModelIDs returns the IDs of the authorization models of the store that requests were pinned to within the
+// last pinnedModelInUseWindow, and forgets the models of all the stores that were pinned to before then.
+func (s *Server) pinnedModelIDs(storeID string) []string {
+	var modelIDs []string
+	s.pinnedModels.Range(func(key, value any) bool {
+		k := key.(pinnedModelKey)
+		if time.Since(value.(time.Time)) > pinnedModelInUseWindow {
+			s.pinnedModels.CompareAndDelete(key, value)
+		} else if k.storeID == storeID {
+			modelIDs = append(modelIDs, k.modelID)
+		}
+		return true
 	})
+	return modelIDs
+}
+
+// applyErrorVerbosity replaces *err by the error to return to clients with the verbosity of the server.
+func (s *Server) applyErrorVerbosity(err *error) {
+	*err = serverErrors.WithVerbosity(*err, s.errorVerbosity)
+}
+
+// storeNotFoundError returns the store not found error if resolving the model of a store failed with err
+// because the store itself doesn't exist, or nil otherwise.
+func (s *Server) storeNotFoundError(ctx context.Context, storeID string, err error) error {
+	code := status.Code(err)
+	if code != codes.Code(openfgav1.ErrorCode_latest_authorization_model_not_found) &&
+		code != codes.Code(openfgav1.ErrorCode_authorization_model_not_found) {
+		return nil
+	}
+
+	if _, storeErr := s.datastore.GetStore(ctx, storeID); errors.Is(storeErr, storage.ErrStoreNotFound) {
+		return serverErrors.HandleError("", storeErr)
+	}
+	return nil
+}
+
+// requestStoreSettings returns the settings of the store that WithCheckRelationAliases and WithDefaultUserTypes
+// apply to a request, or nil if neither is enabled. They are read once per request, and cached per store for
+// storeSettingsCacheTTL.
+func (s *Server) requestStoreSettings(ctx context.Context, storeID string) (*storage.StoreSettings, error) {
+	if (!s.checkRelationAliasesEnabled && !s.defaultUserTypesEnabled) || s.storeSettings == nil {
+		return nil, nil
+	}
+
+	if s.storeSettingsCache != nil {
+		if cached := s.storeSettingsCache.Get(storeID); cached != nil && !cached.Expired {
+			return cached.Value, nil
+		}
+	}
+
+	settings, err := s.storeSettings.ReadStoreSettings(ctx, storeID)
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	if s.storeSettingsCache != nil {
+		s.storeSettingsCache.Set(storeID, settings, s.storeSettingsCacheTTL)
+	}
+	return settings, nil
+}
+
+// resolveRelationAlias returns the tuple key with its relation replaced by the relation that it is an alias of
+// in the settings of the store, if any. It returns a validation error if the model doesn't define that relation.
+func (s *Server) resolveRelationAlias(settings *storage.StoreSettings, typesys *typesystem.TypeSystem, tk *openfgav1.CheckRequestTupleKey) (*openfgav1.CheckRequestTupleKey, error) {
+	if !s.checkRelationAliasesEnabled || settings == nil {
+		return tk, nil
+	}
+
+	objectType := tuple.GetType(tk.GetObject())
+	canonical, ok := settings.CanonicalRelation(objectType, tk.GetRelation())
+	if !ok {
+		return tk, nil
+	}
+
+	if _, err := typesys.GetRelation(objectType, canonical); err != nil {
+		return nil, serverErrors.ValidationError(fmt.Errorf("relation alias '%s#%s' resolves to relation '%s': %w", objectType, tk.GetRelation(), canonical, err))
+	}
+
+	return &openfgav1.CheckRequestTupleKey{Object: tk.GetObject(), Relation: canonical, User: tk.GetUser()}, nil
+}
+
+// defaultUserType returns the DefaultUserType of the settings of the store, if WithDefaultUserTypes is enabled.
+// It returns a validation error if the model doesn't define that type.
+func (s *Server) defaultUserType(settings *storage.StoreSettings, typesys *typesystem.TypeSystem) (string, error) {
+	if !s.defaultUserTypesEnabled || settings == nil {
+		return "", nil
+	}
+
+	userType := settings.DefaultUserType
+	if userType == "" {
+		return "", nil
+	}
+
+	if _, ok := typesys.GetTypeDefinition(userType); !ok {
+		return "", serverErrors.ValidationError(fmt.Errorf("default user type '%s': %w", userType, &tuple.TypeNotFoundError{TypeName: userType}))
+	}
+
+	return userType, nil
+}
+
+// withDefaultUserType returns the user with userType prepended if it doesn't have a type, e.g. 'anne' is
+// 'user:anne' and '*' is 'user:*'. A user without a type that has a relation, e.g. 'eng#member', is ambiguous,
+// since the default type is the type of the object and not of the userset.
+func withDefaultUserType(userType, user string) (string, error) {
+	if userType == "" || strings.Contains(user, ":") {
+		return user, nil
+	}
+
+	if strings.Contains(user, "#") {
+		return "", serverErrors.ValidationError(fmt.Errorf("the user '%s' has a relation but no type, so the default user type can't be applied", user))
+	}
+
+	return userType + ":" + user, nil
+}
+
+// withDefaultUserTypeInWrites returns the writes and the deletes with the default user type of the store prepended
+// to the users that don't have a type. The request is not modified.
+func (s *Server) withDefaultUserTypeInWrites(
+	settings *storage.StoreSettings,
+	typesys *typesystem.TypeSystem,
+	writes *openfgav1.WriteRequestWrites,
+	deletes *openfgav1.WriteRequestDeletes,
+) (*openfgav1.WriteRequestWrites, *openfgav1.WriteRequestDeletes, error) {
+	userType, err := s.defaultUserType(settings, typesys)
+	if err != nil || userType == "" {
+		return writes, deletes, err
+	}
+
+	if writes != nil {
+		writes = proto.Clone(writes).(*openfgav1.WriteRequestWrites)
+		for _, tk := range writes.GetTupleKeys() {
+			if tk.User, err = withDefaultUserType(userType, tk.GetUser()); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if deletes != nil {
+		deletes = proto.Clone(deletes).(*openfgav1.WriteRequestDeletes)
+		for _, tk := range deletes.GetTupleKeys() {
+			if tk.User, err = withDefaultUserType(userType, tk.GetUser()); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return writes, deletes, nil
+}
+
+// withDefaultUserTypeInCheck returns the tuple key and the contextual tuples of a Check with the default user
+// type of the store prepended to the users that don't have a type. The request is not modified.
+func (s *Server) withDefaultUserTypeInCheck(
+	settings *storage.StoreSettings,
+	typesys *typesystem.TypeSystem,
+	tk *openfgav1.CheckRequestTupleKey,
+	contextualTuples []*openfgav1.TupleKey,
+) (*openfgav1.CheckRequestTupleKey, []*openfgav1.TupleKey, error) {
+	userType, err := s.defaultUserType(settings, typesys)
+	if err != nil || userType == "" {
+		return tk, contextualTuples, err
+	}
+
+	user, err := withDefaultUserType(userType, tk.GetUser())
+	if err != nil {
+		return nil, nil, err
+	}
+	tk = &openfgav1.CheckRequestTupleKey{Object: tk.GetObject(), Relation: tk.GetRelation(), User: user}
+
+	normalized := make([]*openfgav1.TupleKey, 0, len(contextualTuples))
+	for _, ctxTuple := range contextualTuples {
+		ctxTuple = proto.Clone(ctxTuple).(*openfgav1.TupleKey)
+		if ctxTuple.User, err = withDefaultUserType(userType, ctxTuple.GetUser()); err != nil {
+			return nil, nil, err
+		}
+		normalized = append(normalized, ctxTuple)
+	}
+
+	return tk, normalized, nil
 }
 
-func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openfgav1.CheckResponse, error) {
-	err := s.validateConsistencyRequest(req.GetConsistency())
+func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (_ *openfgav1.CheckResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	consistency, bypassCache, err := readPolicy(ctx, req.GetConsistency())
+	if err != nil {
+		return nil, err
+	}
+
+	// the read policy resolves to a consistency preference, which needs the experimental as well
+	err = s.validateConsistencyRequest(consistency)
 	if err != nil {
 		return nil, err
 	}
@@ -949,7 +2122,7 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 		attribute.KeyValue{Key: "object", Value: attribute.StringValue(tk.GetObject())},
 		attribute.KeyValue{Key: "relation", Value: attribute.StringValue(tk.GetRelation())},
 		attribute.KeyValue{Key: "user", Value: attribute.StringValue(tk.GetUser())},
-		attribute.KeyValue{Key: "consistency", Value: attribute.StringValue(req.GetConsistency().String())},
+		attribute.KeyValue{Key: "consistency", Value: attribute.StringValue(consistency.String())},
 	))
 	defer span.End()
 
@@ -963,10 +2136,47 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 		Service: s.serviceName,
 		Method:  "Check",
 	})
+	ctx = withReadDatastore(ctx)
+	ctx = s.withDisabledConditions(ctx)
+	ctx = eval.ContextWithEvaluationErrorPolicy(ctx, s.conditionEvaluationErrorPolicy)
+	ctx = eval.ContextWithStrictConditionContext(ctx, s.checkStrictConditionContext)
+
+	if values := metadata.ValueFromIncomingContext(ctx, MinChangelogTokenHeader); len(values) > 0 && values[0] != "" {
+		token, err := s.encoder.Decode(values[0])
+		if err != nil {
+			return nil, serverErrors.InvalidContinuationToken
+		}
+		ctx = storage.ContextWithMinChangelogToken(ctx, string(token))
+	}
 
 	storeID := req.GetStoreId()
 
-	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	typesys, err := s.resolveQueryTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		if storeErr := s.storeNotFoundError(ctx, storeID, err); storeErr != nil {
+			if s.denyCheckOnUnknownStore {
+				return &openfgav1.CheckResponse{Allowed: false}, nil
+			}
+			return nil, storeErr
+		}
+		if s.denyCheckOnStoreWithoutModel && req.GetAuthorizationModelId() == "" &&
+			status.Code(err) == codes.Code(openfgav1.ErrorCode_latest_authorization_model_not_found) {
+			return &openfgav1.CheckResponse{Allowed: false}, nil
+		}
+		return nil, err
+	}
+
+	settings, err := s.requestStoreSettings(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	tk, err = s.resolveRelationAlias(settings, typesys, tk)
+	if err != nil {
+		return nil, err
+	}
+
+	tk, contextualTuples, err := s.withDefaultUserTypeInCheck(settings, typesys, tk, req.GetContextualTuples().GetTupleKeys())
 	if err != nil {
 		return nil, err
 	}
@@ -975,18 +2185,36 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 		return nil, serverErrors.ValidationError(err)
 	}
 
-	for _, ctxTuple := range req.GetContextualTuples().GetTupleKeys() {
+	for _, ctxTuple := range contextualTuples {
 		if err := validation.ValidateTuple(typesys, ctxTuple); err != nil {
 			return nil, serverErrors.HandleTupleValidateError(err)
 		}
 	}
 
+	knownResults, err := s.knownCheckResults(ctx, typesys, tuple.ConvertCheckRequestTupleKeyToTupleKey(tk))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = s.withContextualTuples(ctx, storeID, contextualTuples)
+	if err != nil {
+		return nil, err
+	}
+
+	// the shapes of the datastore queries are only counted for the traced Checks, to find N+1 patterns
+	tupleReader := s.tupleReader
+	var queryShapes *storagewrappers.QueryShapes
+	if span.IsRecording() {
+		queryShapes = storagewrappers.NewQueryShapes()
+		tupleReader = storagewrappers.NewQueryShapeRecordingTupleReader(tupleReader, queryShapes)
+	}
+
 	ctx = typesystem.ContextWithTypesystem(ctx, typesys)
 	ctx = storage.ContextWithRelationshipTupleReader(ctx,
 		storagewrappers.NewBoundedConcurrencyTupleReader(
 			storagewrappers.NewCombinedTupleReader(
-				s.datastore,
-				req.GetContextualTuples().GetTupleKeys(),
+				tupleReader,
+				contextualTuples,
 			),
 			s.maxConcurrentReadsForCheck,
 		),
@@ -997,20 +2225,32 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 	resolveCheckRequest := graph.ResolveCheckRequest{
 		StoreID:              req.GetStoreId(),
 		AuthorizationModelID: typesys.GetAuthorizationModelID(), // the resolved model id
-		TupleKey:             tuple.ConvertCheckRequestTupleKeyToTupleKey(req.GetTupleKey()),
-		ContextualTuples:     req.GetContextualTuples().GetTupleKeys(),
+		TupleKey:             tuple.ConvertCheckRequestTupleKeyToTupleKey(tk),
+		ContextualTuples:     contextualTuples,
 		Context:              req.GetContext(),
 		RequestMetadata:      checkRequestMetadata,
-		Consistency:          req.GetConsistency(),
+		Consistency:          consistency,
+		BypassCache:          bypassCache,
+		// the fast paths could resolve a subproblem without looking at its known result
+		DisableFastPath: fastPathDisabled(ctx) || len(knownResults) > 0,
+		KnownResults:    knownResults,
 	}
 
 	resp, err := s.checkResolver.ResolveCheck(ctx, &resolveCheckRequest)
+	if queryShapes != nil {
+		addQueryShapesEvent(span, queryShapes)
+	}
 	if err != nil {
 		telemetry.TraceError(span, err)
 		if errors.Is(err, graph.ErrResolutionDepthExceeded) {
 			return nil, serverErrors.AuthorizationModelResolutionTooComplex
 		}
 
+		var visitedObjectsErr *graph.MaxVisitedObjectsExceededError
+		if errors.As(err, &visitedObjectsErr) {
+			return nil, serverErrors.VisitedObjectsLimitExceeded(visitedObjectsErr.Visited, visitedObjectsErr.Limit)
+		}
+
 		if errors.Is(err, condition.ErrEvaluationFailed) {
 			return nil, serverErrors.ValidationError(err)
 		}
@@ -1027,22 +2267,27 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 	queryCount := float64(resp.GetResolutionMetadata().DatastoreQueryCount)
 	const methodName = "check"
 
+	// whether the result was served from the Check cache, to diagnose stale results from the logs
+	cached := resp.GetResolutionMetadata().Cached
+	grpc_ctxtags.Extract(ctx).Set("cached", cached)
+	span.SetAttributes(attribute.Bool("cached", cached))
+
 	grpc_ctxtags.Extract(ctx).Set(datastoreQueryCountHistogramName, queryCount)
 	span.SetAttributes(attribute.Float64(datastoreQueryCountHistogramName, queryCount))
-	datastoreQueryCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(queryCount)
+	s.metricsSink.ObserveHistogram(datastoreQueryCountHistogramName, queryCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
 
 	rawDispatchCount := checkRequestMetadata.DispatchCounter.Load()
 	dispatchCount := float64(rawDispatchCount)
 
 	grpc_ctxtags.Extract(ctx).Set(dispatchCountHistogramName, dispatchCount)
 	span.SetAttributes(attribute.Float64(dispatchCountHistogramName, dispatchCount))
-	dispatchCountHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-	).Observe(dispatchCount)
+	s.metricsSink.ObserveHistogram(dispatchCountHistogramName, dispatchCount, map[string]string{
+		"grpc_service": s.serviceName,
+		"grpc_method":  methodName,
+	})
 
 	res := &openfgav1.CheckResponse{
 		Allowed: resp.Allowed,
@@ -1050,19 +2295,21 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 
 	span.SetAttributes(attribute.KeyValue{Key: "allowed", Value: attribute.BoolValue(res.GetAllowed())})
 
-	requestDurationHistogram.WithLabelValues(
-		s.serviceName,
-		methodName,
-		utils.Bucketize(uint(resp.GetResolutionMetadata().DatastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
-		utils.Bucketize(uint(rawDispatchCount), s.requestDurationByDispatchCountHistogramBuckets),
-		req.GetConsistency().String(),
-	).Observe(float64(time.Since(start).Milliseconds()))
+	s.metricsSink.ObserveHistogram(requestDurationHistogramName, float64(time.Since(start).Milliseconds()), map[string]string{
+		"grpc_service":          s.serviceName,
+		"grpc_method":           methodName,
+		"datastore_query_count": utils.Bucketize(uint(resp.GetResolutionMetadata().DatastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
+		"dispatch_count":        utils.Bucketize(uint(rawDispatchCount), s.requestDurationByDispatchCountHistogramBuckets),
+		"consistency":           consistency.String(),
+	})
 
 	return res, nil
 }
 
-func (s *Server) Expand(ctx context.Context, req *openfgav1.ExpandRequest) (*openfgav1.ExpandResponse, error) {
-	err := s.validateConsistencyRequest(req.GetConsistency())
+func (s *Server) Expand(ctx context.Context, req *openfgav1.ExpandRequest) (_ *openfgav1.ExpandResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	err = s.validateConsistencyRequest(req.GetConsistency())
 	if err != nil {
 		return nil, err
 	}
@@ -1088,12 +2335,15 @@ func (s *Server) Expand(ctx context.Context, req *openfgav1.ExpandRequest) (*ope
 
 	storeID := req.GetStoreId()
 
-	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	typesys, err := s.resolveQueryTypesystem(ctx, storeID, req.GetAuthorizationModelId())
 	if err != nil {
 		return nil, err
 	}
 
-	q := commands.NewExpandQuery(s.datastore, commands.WithExpandQueryLogger(s.logger))
+	q := commands.NewExpandQuery(s.datastore,
+		commands.WithExpandQueryLogger(s.logger),
+		commands.WithExpandQueryMaxDirectUsers(s.expandMaxDirectUsers),
+	)
 	return q.Execute(ctx, &openfgav1.ExpandRequest{
 		StoreId:              storeID,
 		AuthorizationModelId: typesys.GetAuthorizationModelID(), // the resolved model id
@@ -1102,7 +2352,9 @@ func (s *Server) Expand(ctx context.Context, req *openfgav1.ExpandRequest) (*ope
 	})
 }
 
-func (s *Server) ReadAuthorizationModel(ctx context.Context, req *openfgav1.ReadAuthorizationModelRequest) (*openfgav1.ReadAuthorizationModelResponse, error) {
+func (s *Server) ReadAuthorizationModel(ctx context.Context, req *openfgav1.ReadAuthorizationModelRequest) (_ *openfgav1.ReadAuthorizationModelResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "ReadAuthorizationModel", trace.WithAttributes(
 		attribute.KeyValue{Key: authorizationModelIDKey, Value: attribute.StringValue(req.GetId())},
 	))
@@ -1123,7 +2375,9 @@ func (s *Server) ReadAuthorizationModel(ctx context.Context, req *openfgav1.Read
 	return q.Execute(ctx, req)
 }
 
-func (s *Server) WriteAuthorizationModel(ctx context.Context, req *openfgav1.WriteAuthorizationModelRequest) (*openfgav1.WriteAuthorizationModelResponse, error) {
+func (s *Server) WriteAuthorizationModel(ctx context.Context, req *openfgav1.WriteAuthorizationModelRequest) (_ *openfgav1.WriteAuthorizationModelResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "WriteAuthorizationModel")
 	defer span.End()
 
@@ -1138,9 +2392,16 @@ func (s *Server) WriteAuthorizationModel(ctx context.Context, req *openfgav1.Wri
 		Method:  "WriteAuthorizationModel",
 	})
 
+	if _, err := s.datastore.GetStore(ctx, req.GetStoreId()); err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
 	c := commands.NewWriteAuthorizationModelCommand(s.datastore,
 		commands.WithWriteAuthModelLogger(s.logger),
 		commands.WithWriteAuthModelMaxSizeInBytes(s.maxAuthorizationModelSizeInBytes),
+		commands.WithWriteAuthModelStoreSettings(s.storeSettings),
+		commands.WithWriteAuthModelMaxModelsPerStore(s.maxAuthorizationModelsPerStore),
+		commands.WithWriteAuthModelRejectNonDeterministicConditions(s.checkQueryCacheEnabled && s.checkQueryCacheRejectNonDeterministicConditions),
 	)
 	res, err := c.Execute(ctx, req)
 	if err != nil {
@@ -1148,11 +2409,37 @@ func (s *Server) WriteAuthorizationModel(ctx context.Context, req *openfgav1.Wri
 	}
 
 	s.transport.SetHeader(ctx, httpmiddleware.XHttpCode, strconv.Itoa(http.StatusCreated))
+	s.notifyModelChange(req.GetStoreId(), res.GetAuthorizationModelId())
 
 	return res, nil
 }
 
-func (s *Server) ReadAuthorizationModels(ctx context.Context, req *openfgav1.ReadAuthorizationModelsRequest) (*openfgav1.ReadAuthorizationModelsResponse, error) {
+// notifyModelChange calls the modelChangeHandler, if any, in the background.
+func (s *Server) notifyModelChange(storeID, modelID string) {
+	if s.modelChangeHandler == nil {
+		return
+	}
+
+	s.modelChangeHandlers.Add(1)
+	go func() {
+		defer s.modelChangeHandlers.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				s.logger.Error("model change handler panicked",
+					zap.String("store_id", storeID),
+					zap.String("authorization_model_id", modelID),
+					zap.Error(fmt.Errorf("%v", r)),
+				)
+			}
+		}()
+
+		s.modelChangeHandler(storeID, modelID)
+	}()
+}
+
+func (s *Server) ReadAuthorizationModels(ctx context.Context, req *openfgav1.ReadAuthorizationModelsRequest) (_ *openfgav1.ReadAuthorizationModelsResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "ReadAuthorizationModels")
 	defer span.End()
 
@@ -1174,7 +2461,9 @@ func (s *Server) ReadAuthorizationModels(ctx context.Context, req *openfgav1.Rea
 	return c.Execute(ctx, req)
 }
 
-func (s *Server) WriteAssertions(ctx context.Context, req *openfgav1.WriteAssertionsRequest) (*openfgav1.WriteAssertionsResponse, error) {
+func (s *Server) WriteAssertions(ctx context.Context, req *openfgav1.WriteAssertionsRequest) (_ *openfgav1.WriteAssertionsResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "WriteAssertions")
 	defer span.End()
 
@@ -1211,7 +2500,9 @@ func (s *Server) WriteAssertions(ctx context.Context, req *openfgav1.WriteAssert
 	return res, nil
 }
 
-func (s *Server) ReadAssertions(ctx context.Context, req *openfgav1.ReadAssertionsRequest) (*openfgav1.ReadAssertionsResponse, error) {
+func (s *Server) ReadAssertions(ctx context.Context, req *openfgav1.ReadAssertionsRequest) (_ *openfgav1.ReadAssertionsResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "ReadAssertions")
 	defer span.End()
 
@@ -1235,7 +2526,9 @@ func (s *Server) ReadAssertions(ctx context.Context, req *openfgav1.ReadAssertio
 	return q.Execute(ctx, req.GetStoreId(), typesys.GetAuthorizationModelID())
 }
 
-func (s *Server) ReadChanges(ctx context.Context, req *openfgav1.ReadChangesRequest) (*openfgav1.ReadChangesResponse, error) {
+func (s *Server) ReadChanges(ctx context.Context, req *openfgav1.ReadChangesRequest) (_ *openfgav1.ReadChangesResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "ReadChangesQuery", trace.WithAttributes(
 		attribute.KeyValue{Key: "type", Value: attribute.StringValue(req.GetType())},
 	))
@@ -1260,7 +2553,9 @@ func (s *Server) ReadChanges(ctx context.Context, req *openfgav1.ReadChangesRequ
 	return q.Execute(ctx, req)
 }
 
-func (s *Server) CreateStore(ctx context.Context, req *openfgav1.CreateStoreRequest) (*openfgav1.CreateStoreResponse, error) {
+func (s *Server) CreateStore(ctx context.Context, req *openfgav1.CreateStoreRequest) (_ *openfgav1.CreateStoreResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "CreateStore")
 	defer span.End()
 
@@ -1275,7 +2570,10 @@ func (s *Server) CreateStore(ctx context.Context, req *openfgav1.CreateStoreRequ
 		Method:  "CreateStore",
 	})
 
-	c := commands.NewCreateStoreCommand(s.datastore, commands.WithCreateStoreCmdLogger(s.logger))
+	c := commands.NewCreateStoreCommand(s.datastore,
+		commands.WithCreateStoreCmdLogger(s.logger),
+		commands.WithCreateStoreCmdUniqueNames(s.uniqueStoreNameCreator),
+	)
 	res, err := c.Execute(ctx, req)
 	if err != nil {
 		return nil, err
@@ -1286,7 +2584,9 @@ func (s *Server) CreateStore(ctx context.Context, req *openfgav1.CreateStoreRequ
 	return res, nil
 }
 
-func (s *Server) DeleteStore(ctx context.Context, req *openfgav1.DeleteStoreRequest) (*openfgav1.DeleteStoreResponse, error) {
+func (s *Server) DeleteStore(ctx context.Context, req *openfgav1.DeleteStoreRequest) (_ *openfgav1.DeleteStoreResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "DeleteStore")
 	defer span.End()
 
@@ -1312,7 +2612,9 @@ func (s *Server) DeleteStore(ctx context.Context, req *openfgav1.DeleteStoreRequ
 	return res, nil
 }
 
-func (s *Server) GetStore(ctx context.Context, req *openfgav1.GetStoreRequest) (*openfgav1.GetStoreResponse, error) {
+func (s *Server) GetStore(ctx context.Context, req *openfgav1.GetStoreRequest) (_ *openfgav1.GetStoreResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "GetStore")
 	defer span.End()
 
@@ -1331,7 +2633,9 @@ func (s *Server) GetStore(ctx context.Context, req *openfgav1.GetStoreRequest) (
 	return q.Execute(ctx, req)
 }
 
-func (s *Server) ListStores(ctx context.Context, req *openfgav1.ListStoresRequest) (*openfgav1.ListStoresResponse, error) {
+func (s *Server) ListStores(ctx context.Context, req *openfgav1.ListStoresRequest) (_ *openfgav1.ListStoresResponse, err error) {
+	defer s.applyErrorVerbosity(&err)
+
 	ctx, span := tracer.Start(ctx, "ListStores")
 	defer span.End()
 
@@ -1381,6 +2685,10 @@ func (s *Server) resolveTypesystem(ctx context.Context, storeID, modelID string)
 
 	typesys, err := s.typesystemResolver(ctx, storeID, modelID)
 	if err != nil {
+		if errors.Is(err, typesystem.ErrNoModelForStore) {
+			return nil, serverErrors.NoModelForStore(storeID)
+		}
+
 		if errors.Is(err, typesystem.ErrModelNotFound) {
 			if modelID == "" {
 				return nil, serverErrors.LatestAuthorizationModelNotFound(storeID)
@@ -1399,6 +2707,9 @@ func (s *Server) resolveTypesystem(ctx context.Context, storeID, modelID string)
 	}
 
 	resolvedModelID := typesys.GetAuthorizationModelID()
+	if modelID != "" {
+		s.pinnedModels.Store(pinnedModelKey{storeID: storeID, modelID: resolvedModelID}, time.Now())
+	}
 
 	span.SetAttributes(attribute.KeyValue{Key: authorizationModelIDKey, Value: attribute.StringValue(resolvedModelID)})
 	grpc_ctxtags.Extract(ctx).Set(authorizationModelIDKey, resolvedModelID)
@@ -1407,6 +2718,180 @@ func (s *Server) resolveTypesystem(ctx context.Context, storeID, modelID string)
 	return typesys, nil
 }
 
+// resolveQueryTypesystem is like resolveTypesystem, but falls back to the latest model of the store if the
+// requested model is not found and WithModelNotFoundFallback is enabled.
+func (s *Server) resolveQueryTypesystem(ctx context.Context, storeID, modelID string) (*typesystem.TypeSystem, error) {
+	typesys, err := s.resolveTypesystem(ctx, storeID, modelID)
+	if err == nil || !s.modelNotFoundFallback || modelID == "" ||
+		status.Code(err) != codes.Code(openfgav1.ErrorCode_authorization_model_not_found) {
+		return typesys, err
+	}
+
+	typesys, err = s.resolveTypesystem(ctx, storeID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.DebugWithContext(ctx, "authorization model not found, falling back to the latest model",
+		zap.String("store_id", storeID),
+		zap.String("requested_authorization_model_id", modelID),
+		zap.String("authorization_model_id", typesys.GetAuthorizationModelID()),
+	)
+	s.transport.SetHeader(ctx, AuthorizationModelFallbackHeader, modelID)
+
+	return typesys, nil
+}
+
+// withReadDatastore returns a context whose tuple reads are served by the shadow datastore if the request
+// selects it with the ReadDatastoreHeader. The results of such requests bypass the Check caches.
+func withReadDatastore(ctx context.Context) context.Context {
+	if values := metadata.ValueFromIncomingContext(ctx, ReadDatastoreHeader); len(values) > 0 && values[0] == ReadDatastoreShadow {
+		return storage.ContextWithShadowReads(ctx)
+	}
+	return ctx
+}
+
+// readPolicy returns the consistency preference of a Check, and whether it bypasses the Check cache, according
+// to the ReadPolicyHeader of the request. Without the header, the consistency preference of the request is
+// returned unchanged.
+func readPolicy(ctx context.Context, consistency openfgav1.ConsistencyPreference) (openfgav1.ConsistencyPreference, bool, error) {
+	values := metadata.ValueFromIncomingContext(ctx, ReadPolicyHeader)
+	if len(values) == 0 || values[0] == "" {
+		return consistency, false, nil
+	}
+
+	if consistency != openfgav1.ConsistencyPreference_UNSPECIFIED {
+		return consistency, false, serverErrors.ValidationError(fmt.Errorf("a read policy can't be combined with a consistency preference"))
+	}
+
+	switch ReadPolicy(values[0]) {
+	case ReadPolicyCachedEventuallyConsistent:
+		return openfgav1.ConsistencyPreference_MINIMIZE_LATENCY, false, nil
+	case ReadPolicyFreshFromReplica:
+		return openfgav1.ConsistencyPreference_MINIMIZE_LATENCY, true, nil
+	case ReadPolicyFreshFromPrimary:
+		return openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY, true, nil
+	default:
+		return consistency, false, serverErrors.ValidationError(fmt.Errorf("unknown read policy '%s'", values[0]))
+	}
+}
+
+// fastPathDisabled returns true if the request set DisableFastPathHeader to "true".
+func fastPathDisabled(ctx context.Context) bool {
+	values := metadata.ValueFromIncomingContext(ctx, DisableFastPathHeader)
+	return len(values) > 0 && values[0] == "true"
+}
+
+// addQueryShapesEvent adds to the span an event with the number of datastore queries of every shape.
+func addQueryShapesEvent(span trace.Span, queryShapes *storagewrappers.QueryShapes) {
+	counts := queryShapes.Counts()
+	shapes := make([]string, 0, len(counts))
+	for shape := range counts {
+		shapes = append(shapes, shape)
+	}
+	sort.Strings(shapes)
+
+	attrs := make([]attribute.KeyValue, 0, len(shapes))
+	for _, shape := range shapes {
+		attrs = append(attrs, attribute.Int(shape, counts[shape]))
+	}
+	span.AddEvent("datastore_query_shapes", trace.WithAttributes(attrs...))
+}
+
+// idempotencyKey returns the value of the IdempotencyKeyHeader of the request, if any. It returns an error if the
+// key is too long or the datastore can't record it.
+func (s *Server) idempotencyKey(ctx context.Context) (string, error) {
+	values := metadata.ValueFromIncomingContext(ctx, IdempotencyKeyHeader)
+	if len(values) == 0 || values[0] == "" {
+		return "", nil
+	}
+
+	key := values[0]
+	if len(key) > maxIdempotencyKeyLength {
+		return "", serverErrors.ValidationError(fmt.Errorf("the idempotency key must be at most %d characters long", maxIdempotencyKeyLength))
+	}
+	if s.idempotentWriter == nil {
+		return "", status.Error(codes.Unimplemented, "the datastore doesn't support idempotency keys")
+	}
+	return key, nil
+}
+
+// knownCheckResults returns the known results of the KnownCheckResultsHeader of the request, keyed by
+// 'object#relation@user', or nil if WithKnownCheckResults isn't enabled. It returns an error if a value is
+// malformed or invalid for the model, if the same subproblem has several results, or if the result of the
+// Check itself is supplied.
+func (s *Server) knownCheckResults(ctx context.Context, typesys *typesystem.TypeSystem, checked *openfgav1.TupleKey) (map[string]bool, error) {
+	if !s.knownCheckResultsEnabled {
+		return nil, nil
+	}
+
+	values := metadata.ValueFromIncomingContext(ctx, KnownCheckResultsHeader)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if len(values) > maxKnownCheckResults {
+		return nil, serverErrors.ValidationError(fmt.Errorf("at most %d known check results can be supplied", maxKnownCheckResults))
+	}
+
+	checkedKey := tuple.TupleKeyToString(checked)
+	knownResults := make(map[string]bool, len(values))
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i < 0 {
+			return nil, serverErrors.ValidationError(fmt.Errorf("known check result '%s' must be 'object#relation@user=true' or 'object#relation@user=false'", value))
+		}
+
+		allowed, err := strconv.ParseBool(value[i+1:])
+		if err != nil {
+			return nil, serverErrors.ValidationError(fmt.Errorf("known check result '%s' must end with '=true' or '=false'", value))
+		}
+
+		tk, err := tuple.ParseTupleString(value[:i])
+		if err != nil {
+			return nil, serverErrors.ValidationError(fmt.Errorf("known check result '%s': %w", value, err))
+		}
+		if err := validation.ValidateUserObjectRelation(typesys, tk); err != nil {
+			return nil, serverErrors.ValidationError(fmt.Errorf("known check result '%s': %w", value, err))
+		}
+
+		key := tuple.TupleKeyToString(tk)
+		if key == checkedKey {
+			return nil, serverErrors.ValidationError(fmt.Errorf("known check result '%s' is the check itself", value))
+		}
+		if previous, ok := knownResults[key]; ok && previous != allowed {
+			return nil, serverErrors.ValidationError(fmt.Errorf("known check result '%s' has conflicting results", key))
+		}
+		knownResults[key] = allowed
+	}
+	return knownResults, nil
+}
+
+// withDisabledConditions returns a context with which the conditions disabled by WithDisabledConditions are never met.
+func (s *Server) withDisabledConditions(ctx context.Context) context.Context {
+	if len(s.disabledConditions) == 0 {
+		return ctx
+	}
+	return eval.ContextWithDisabledConditions(ctx, s.disabledConditions...)
+}
+
+// withContextualTuples returns a context whose tuple reads apply the contextual tuples semantics of the server.
+// If WithRejectConflictingContextualTuples is enabled, it returns an error if a contextual tuple conflicts with
+// a stored tuple.
+func (s *Server) withContextualTuples(ctx context.Context, storeID string, contextualTuples []*openfgav1.TupleKey) (context.Context, error) {
+	if s.contextualTuplesOverlay {
+		return storagewrappers.ContextWithContextualTuplesOverlay(ctx), nil
+	}
+
+	if !s.rejectConflictingContextualTuples || len(contextualTuples) == 0 {
+		return ctx, nil
+	}
+
+	if err := storagewrappers.ValidateContextualTuplesAreAdditive(ctx, s.tupleReader, storeID, contextualTuples); err != nil {
+		return nil, serverErrors.HandleTupleValidateError(err)
+	}
+	return ctx, nil
+}
+
 // If the requested consistency preference is not UNSPECIFIED, but the experimental flag is not enabled,
 // returns an error.
 func (s *Server) validateConsistencyRequest(c openfgav1.ConsistencyPreference) error {
@@ -0,0 +1,3730 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/openfga/openfga/internal/authn/presharedkey"
+	"github.com/openfga/openfga/internal/condition/eval"
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/internal/rediscache"
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/server/test"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestServerWithTypeDatastore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	resourceDatastore := memory.New()
+	t.Cleanup(resourceDatastore.Close)
+	groupDatastore := memory.New()
+	t.Cleanup(groupDatastore.Close)
+
+	s := MustNewServerWithOpts(
+		WithDatastore(resourceDatastore),
+		WithTypeDatastore("group", groupDatastore),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]
+		type document
+			relations
+				define parent: [group]
+				define viewer: [user, group#member] or member from parent`)
+
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+	modelID := writeModelResp.GetAuthorizationModelId()
+
+	// group tuples live in the group datastore
+	err = groupDatastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:jon"),
+		tuple.NewTupleKey("group:all", "member", "group:eng#member"),
+	})
+	require.NoError(t, err)
+
+	// resource tuples, and a group tuple that must be ignored, live in the default datastore
+	err = resourceDatastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:userset", "viewer", "group:all#member"),
+		tuple.NewTupleKey("document:ttu", "parent", "group:eng"),
+		tuple.NewTupleKey("document:ignored", "parent", "group:stale"),
+		tuple.NewTupleKey("group:stale", "member", "user:jon"),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		object  string
+		allowed bool
+	}{
+		{object: "document:userset", allowed: true},
+		{object: "document:ttu", allowed: true},
+		{object: "document:ignored", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.object, func(t *testing.T) {
+			checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+				StoreId:              storeID,
+				AuthorizationModelId: modelID,
+				TupleKey:             tuple.NewCheckRequestTupleKey(test.object, "viewer", "user:jon"),
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, checkResp.GetAllowed())
+		})
+	}
+
+	t.Run("list_objects", func(t *testing.T) {
+		listObjectsResp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			Type:                 "document",
+			Relation:             "viewer",
+			User:                 "user:jon",
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"document:userset", "document:ttu"}, listObjectsResp.GetObjects())
+	})
+
+	t.Run("groups_are_not_read_from_default_datastore", func(t *testing.T) {
+		checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			TupleKey:             tuple.NewCheckRequestTupleKey("group:stale", "member", "user:jon"),
+		})
+		require.NoError(t, err)
+		require.False(t, checkResp.GetAllowed())
+	})
+}
+
+func TestServerWithTypeDatastoreRequiresObjectType(t *testing.T) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	_, err := NewServerWithOpts(
+		WithDatastore(ds),
+		WithTypeDatastore("", ds),
+	)
+	require.Error(t, err)
+}
+
+func TestServerWithShadowDatastore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	primaryDatastore := memory.New()
+	shadowDatastore := memory.New()
+	t.Cleanup(shadowDatastore.Close)
+
+	observerLogger, logs := observer.New(zap.WarnLevel)
+	s := MustNewServerWithOpts(
+		WithDatastore(primaryDatastore),
+		WithShadowDatastore(shadowDatastore, 100),
+		WithLogger(&logger.ZapLogger{Logger: zap.New(observerLogger)}),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+	modelID := writeModelResp.GetAuthorizationModelId()
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:both", "viewer", "user:jon"),
+				tuple.NewTupleKey("document:primary", "viewer", "user:jon"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("writes_only_go_to_the_primary_datastore", func(t *testing.T) {
+		_, err := shadowDatastore.ReadUserTuple(ctx, storeID, tuple.NewTupleKey("document:both", "viewer", "user:jon"), storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	err = shadowDatastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:both", "viewer", "user:jon"),
+		tuple.NewTupleKey("document:shadow", "viewer", "user:jon"),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		object         string
+		allowed        bool
+		shadowAllowed  bool
+		onlyInPrimary  []string
+		onlyInShadow   []string
+		expectsWarning bool
+	}{
+		{object: "document:both", allowed: true, shadowAllowed: true},
+		{
+			object:         "document:primary",
+			allowed:        true,
+			onlyInPrimary:  []string{"document:primary#viewer@user:jon"},
+			expectsWarning: true,
+		},
+		{
+			object:         "document:shadow",
+			shadowAllowed:  true,
+			onlyInShadow:   []string{"document:shadow#viewer@user:jon"},
+			expectsWarning: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.object, func(t *testing.T) {
+			logs.TakeAll()
+
+			checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+				StoreId:              storeID,
+				AuthorizationModelId: modelID,
+				TupleKey:             tuple.NewCheckRequestTupleKey(test.object, "viewer", "user:jon"),
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, checkResp.GetAllowed())
+
+			// the reads are compared in the background
+			if !test.expectsWarning {
+				require.Never(t, func() bool {
+					return logs.FilterMessage("shadow tuple read returned different tuples").Len() > 0
+				}, 100*time.Millisecond, 10*time.Millisecond)
+			} else {
+				require.Eventually(t, func() bool {
+					return logs.FilterMessage("shadow tuple read returned different tuples").Len() > 0
+				}, 5*time.Second, 10*time.Millisecond)
+				warnings := logs.FilterMessage("shadow tuple read returned different tuples").All()
+				logs.TakeAll()
+				require.Len(t, warnings, 1)
+				fields := warnings[0].ContextMap()
+				require.Equal(t, storeID, fields["store_id"])
+				require.ElementsMatch(t, test.onlyInPrimary, fields["only_in_primary"])
+				require.ElementsMatch(t, test.onlyInShadow, fields["only_in_shadow"])
+			}
+
+			shadowCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(ReadDatastoreHeader, ReadDatastoreShadow))
+			checkResp, err = s.Check(shadowCtx, &openfgav1.CheckRequest{
+				StoreId:              storeID,
+				AuthorizationModelId: modelID,
+				TupleKey:             tuple.NewCheckRequestTupleKey(test.object, "viewer", "user:jon"),
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.shadowAllowed, checkResp.GetAllowed())
+			require.Zero(t, logs.FilterMessage("shadow tuple read returned different tuples").Len())
+		})
+	}
+
+	t.Run("list_objects", func(t *testing.T) {
+		logs.TakeAll()
+
+		listObjectsResp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			Type:                 "document",
+			Relation:             "viewer",
+			User:                 "user:jon",
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"document:both", "document:primary"}, listObjectsResp.GetObjects())
+		require.Eventually(t, func() bool {
+			return logs.FilterMessage("shadow tuple read returned different tuples").Len() > 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestServerWithShadowDatastoreBypassesTheCheckCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	primaryDatastore := memory.New()
+	shadowDatastore := memory.New()
+	t.Cleanup(shadowDatastore.Close)
+
+	s := MustNewServerWithOpts(
+		WithDatastore(primaryDatastore),
+		WithShadowDatastore(shadowDatastore, 0),
+		WithCheckQueryCacheEnabled(true),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	err = shadowDatastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")})
+	require.NoError(t, err)
+
+	check := func(ctx context.Context) *openfgav1.CheckResponse {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+			TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	shadowCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(ReadDatastoreHeader, ReadDatastoreShadow))
+	require.True(t, check(shadowCtx).GetAllowed())
+	require.False(t, check(ctx).GetAllowed())
+	require.True(t, check(shadowCtx).GetAllowed())
+}
+
+func TestServerWithShadowDatastoreRequiresValidSamplePercentage(t *testing.T) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	_, err := NewServerWithOpts(
+		WithDatastore(ds),
+		WithShadowDatastore(ds, 101),
+	)
+	require.Error(t, err)
+}
+
+// outageDatastore fails the tuple and model reads and the writes of a datastore while it's down.
+type outageDatastore struct {
+	storage.OpenFGADatastore
+	down atomic.Bool
+}
+
+var errOutage = fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED)
+
+func (o *outageDatastore) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	if o.down.Load() {
+		return nil, errOutage
+	}
+	return o.OpenFGADatastore.Read(ctx, store, tupleKey, options)
+}
+
+func (o *outageDatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	if o.down.Load() {
+		return nil, errOutage
+	}
+	return o.OpenFGADatastore.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+func (o *outageDatastore) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	if o.down.Load() {
+		return nil, errOutage
+	}
+	return o.OpenFGADatastore.ReadUsersetTuples(ctx, store, filter, options)
+}
+
+func (o *outageDatastore) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	if o.down.Load() {
+		return nil, errOutage
+	}
+	return o.OpenFGADatastore.ReadStartingWithUser(ctx, store, filter, options)
+}
+
+func (o *outageDatastore) ReadAuthorizationModel(ctx context.Context, store string, id string) (*openfgav1.AuthorizationModel, error) {
+	if o.down.Load() {
+		return nil, errOutage
+	}
+	return o.OpenFGADatastore.ReadAuthorizationModel(ctx, store, id)
+}
+
+func (o *outageDatastore) Write(ctx context.Context, store string, deletes storage.Deletes, writes storage.Writes) error {
+	if o.down.Load() {
+		return errOutage
+	}
+	return o.OpenFGADatastore.Write(ctx, store, deletes, writes)
+}
+
+func TestServerWithStandbyDatastore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	primaryDatastore := &outageDatastore{OpenFGADatastore: memory.New()}
+	standbyDatastore := memory.New()
+	t.Cleanup(standbyDatastore.Close)
+
+	observerLogger, logs := observer.New(zap.WarnLevel)
+	s := MustNewServerWithOpts(
+		WithDatastore(primaryDatastore),
+		WithStandbyDatastore(standbyDatastore, storagewrappers.WithStandbyCircuitBreaker(1, time.Minute)),
+		WithLogger(&logger.ZapLogger{Logger: zap.New(observerLogger)}),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+	modelID := writeModelResp.GetAuthorizationModelId()
+
+	tks := []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")}
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tks},
+	})
+	require.NoError(t, err)
+
+	// the standby is a replica of the primary
+	storedModel, err := primaryDatastore.ReadAuthorizationModel(ctx, storeID, modelID)
+	require.NoError(t, err)
+	require.NoError(t, standbyDatastore.WriteAuthorizationModel(ctx, storeID, storedModel))
+	require.NoError(t, standbyDatastore.Write(ctx, storeID, nil, tks))
+
+	check := func() (*openfgav1.CheckResponse, error) {
+		return s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+		})
+	}
+
+	checkResp, err := check()
+	require.NoError(t, err)
+	require.True(t, checkResp.GetAllowed())
+	require.Zero(t, logs.Len())
+
+	primaryDatastore.down.Store(true)
+
+	t.Run("reads_are_served_by_the_standby", func(t *testing.T) {
+		checkResp, err := check()
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+
+		listObjectsResp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			Type:                 "document",
+			Relation:             "viewer",
+			User:                 "user:jon",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1"}, listObjectsResp.GetObjects())
+
+		require.Equal(t, 1, logs.FilterMessage("primary datastore unavailable, serving reads from the standby datastore and failing writes").Len())
+	})
+
+	t.Run("writes_fail", func(t *testing.T) {
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:2", "viewer", "user:jon")},
+			},
+		})
+		require.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestServerRejectsNonDeterministicConditions(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user with not_expired]
+
+		condition not_expired(expiration: timestamp) {
+			now() < expiration
+		}`)
+
+	writeModel := func(t *testing.T, opts ...OpenFGAServiceV1Option) error {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         createStoreResp.GetId(),
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+			Conditions:      model.GetConditions(),
+		})
+		return err
+	}
+
+	t.Run("rejected_while_the_cache_is_enabled", func(t *testing.T) {
+		err := writeModel(t, WithCheckQueryCacheEnabled(true), WithCheckQueryCacheRejectNonDeterministicConditions(true))
+		require.ErrorContains(t, err, "condition 'not_expired' calls the non-deterministic function 'now'")
+	})
+
+	t.Run("accepted_while_the_cache_is_disabled", func(t *testing.T) {
+		require.NoError(t, writeModel(t, WithCheckQueryCacheRejectNonDeterministicConditions(true)))
+	})
+
+	t.Run("accepted_by_default", func(t *testing.T) {
+		require.NoError(t, writeModel(t, WithCheckQueryCacheEnabled(true)))
+	})
+}
+
+func TestServerContextualTuplesConflictingWithStoredTuples(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user, user with xcond]
+
+		condition xcond(x: int) {
+			x < 100
+		}`)
+
+	conditionalViewer := func(x int) *openfgav1.TupleKey {
+		return tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:jon", "xcond", testutils.MustNewStruct(t, map[string]interface{}{"x": x}))
+	}
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+			Conditions:      model.GetConditions(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, storeID
+	}
+
+	check := func(s *Server, storeID string, contextualTuples ...*openfgav1.TupleKey) (bool, error) {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:          storeID,
+			TupleKey:         tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+			ContextualTuples: &openfgav1.ContextualTupleKeys{TupleKeys: contextualTuples},
+		})
+		return resp.GetAllowed(), err
+	}
+
+	listObjects := func(s *Server, storeID string, contextualTuples ...*openfgav1.TupleKey) ([]string, error) {
+		resp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          storeID,
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:jon",
+			ContextualTuples: &openfgav1.ContextualTupleKeys{TupleKeys: contextualTuples},
+		})
+		return resp.GetObjects(), err
+	}
+
+	listUsers := func(s *Server, storeID string, contextualTuples ...*openfgav1.TupleKey) ([]*openfgav1.User, error) {
+		resp, err := s.ListUsers(ctx, &openfgav1.ListUsersRequest{
+			StoreId:          storeID,
+			Object:           &openfgav1.Object{Type: "document", Id: "1"},
+			Relation:         "viewer",
+			UserFilters:      []*openfgav1.UserTypeFilter{{Type: "user"}},
+			ContextualTuples: contextualTuples,
+		})
+		return resp.GetUsers(), err
+	}
+
+	t.Run("contextual_tuples_are_additive_by_default", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		allowed, err := check(s, storeID, tuple.NewTupleKey("document:1", "viewer", "user:jon"))
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		objects, err := listObjects(s, storeID, tuple.NewTupleKey("document:2", "viewer", "user:jon"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"document:1", "document:2"}, objects)
+	})
+
+	t.Run("contextual_tuples_that_restrict_stored_tuples_are_not_rejected_by_default", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		_, err := check(s, storeID, conditionalViewer(200))
+		require.NoError(t, err)
+	})
+
+	t.Run("contextual_tuples_that_restrict_stored_tuples_are_rejected", func(t *testing.T) {
+		s, storeID := setup(t, WithRejectConflictingContextualTuples(true))
+
+		_, err := check(s, storeID, tuple.NewTupleKey("document:2", "viewer", "user:jon"), conditionalViewer(200))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_invalid_tuple), status.Code(err))
+		require.ErrorContains(t, err, "contextual tuples can only add relationships")
+
+		_, err = listObjects(s, storeID, conditionalViewer(200))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_invalid_tuple), status.Code(err))
+
+		_, err = listUsers(s, storeID, conditionalViewer(200))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_invalid_tuple), status.Code(err))
+	})
+
+	t.Run("contextual_tuples_that_only_add_relationships_are_not_rejected", func(t *testing.T) {
+		s, storeID := setup(t, WithRejectConflictingContextualTuples(true))
+
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.NewTupleKeyWithCondition("document:2", "viewer", "user:jon", "xcond", testutils.MustNewStruct(t, map[string]interface{}{"x": 200})),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		// an unconditional contextual tuple over a stored conditional one
+		objects, err := listObjects(s, storeID, tuple.NewTupleKey("document:2", "viewer", "user:jon"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"document:1", "document:2"}, objects)
+
+		// a contextual tuple with the condition of the stored tuple, and one that doesn't match a stored tuple
+		allowed, err := check(s, storeID,
+			tuple.NewTupleKeyWithCondition("document:2", "viewer", "user:jon", "xcond", testutils.MustNewStruct(t, map[string]interface{}{"x": 200})),
+			tuple.NewTupleKeyWithCondition("document:3", "viewer", "user:jon", "xcond", testutils.MustNewStruct(t, map[string]interface{}{"x": 1})),
+		)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("contextual_tuples_shadow_stored_tuples_with_overlay", func(t *testing.T) {
+		s, storeID := setup(t, WithContextualTuplesOverlay(true))
+
+		allowed, err := check(s, storeID, conditionalViewer(200))
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		allowed, err = check(s, storeID, conditionalViewer(1))
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		objects, err := listObjects(s, storeID, conditionalViewer(200))
+		require.NoError(t, err)
+		require.Empty(t, objects)
+
+		users, err := listUsers(s, storeID, conditionalViewer(200))
+		require.NoError(t, err)
+		require.Empty(t, users)
+
+		users, err = listUsers(s, storeID)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+	})
+}
+
+func TestServerWithDisabledConditions(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user, user with breakglass]
+
+		condition breakglass(active: bool) {
+			active
+		}`)
+
+	breakglassViewer := func(object string) *openfgav1.TupleKey {
+		return tuple.NewTupleKeyWithCondition(object, "viewer", "user:jon", "breakglass", testutils.MustNewStruct(t, map[string]interface{}{"active": true}))
+	}
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+			Conditions:      model.GetConditions(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					breakglassViewer("document:1"),
+					tuple.NewTupleKey("document:2", "viewer", "user:jon"),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, storeID
+	}
+
+	check := func(t *testing.T, s *Server, storeID, object string, contextualTuples ...*openfgav1.TupleKey) bool {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:          storeID,
+			TupleKey:         tuple.NewCheckRequestTupleKey(object, "viewer", "user:jon"),
+			ContextualTuples: &openfgav1.ContextualTupleKeys{TupleKeys: contextualTuples},
+		})
+		require.NoError(t, err)
+		return resp.GetAllowed()
+	}
+
+	t.Run("enabled_condition_grants_access", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		require.True(t, check(t, s, storeID, "document:1"))
+		require.True(t, check(t, s, storeID, "document:3", breakglassViewer("document:3")))
+	})
+
+	t.Run("disabled_condition_does_not_grant_access", func(t *testing.T) {
+		s, storeID := setup(t, WithDisabledConditions("breakglass"))
+
+		require.False(t, check(t, s, storeID, "document:1"))
+		require.False(t, check(t, s, storeID, "document:3", breakglassViewer("document:3")))
+		require.True(t, check(t, s, storeID, "document:2"))
+
+		listObjectsResp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  storeID,
+			Type:     "document",
+			Relation: "viewer",
+			User:     "user:jon",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:2"}, listObjectsResp.GetObjects())
+
+		listUsersResp, err := s.ListUsers(ctx, &openfgav1.ListUsersRequest{
+			StoreId:     storeID,
+			Object:      &openfgav1.Object{Type: "document", Id: "1"},
+			Relation:    "viewer",
+			UserFilters: []*openfgav1.UserTypeFilter{{Type: "user"}},
+		})
+		require.NoError(t, err)
+		require.Empty(t, listUsersResp.GetUsers())
+	})
+}
+
+func TestServerWithConditionEvaluationErrorPolicy(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user, user with in_region, user with under_quota]
+
+		condition in_region(region: string) {
+			region == "eu"
+		}
+
+		condition under_quota(used: int, quota: int) {
+			100 * used / quota < 80
+		}`)
+
+	evaluationErrors := func(t *testing.T, conditionName string) float64 {
+		t.Helper()
+
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != "openfga_condition_evaluation_error_count" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "condition_name" && label.GetValue() == conditionName {
+						return metric.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return 0
+	}
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+			Conditions:      model.GetConditions(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					// the region is missing unless the Check provides it
+					tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:jon", "in_region", nil),
+					// the evaluation divides by zero
+					tuple.NewTupleKeyWithCondition("document:2", "viewer", "user:jon", "under_quota",
+						testutils.MustNewStruct(t, map[string]interface{}{"used": 10, "quota": 0})),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, storeID
+	}
+
+	check := func(s *Server, storeID, object string) (*openfgav1.CheckResponse, error) {
+		return s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(object, "viewer", "user:jon"),
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		s, storeID := setup(t, WithCheckStrictConditionContext(true))
+
+		missingParameterErrors := evaluationErrors(t, "in_region")
+		runtimeErrors := evaluationErrors(t, "under_quota")
+
+		_, err := check(s, storeID, "document:1")
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		_, err = check(s, storeID, "document:2")
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+
+		require.InDelta(t, missingParameterErrors+1, evaluationErrors(t, "in_region"), 0)
+		require.InDelta(t, runtimeErrors+1, evaluationErrors(t, "under_quota"), 0)
+	})
+
+	t.Run("treat_as_false", func(t *testing.T) {
+		s, storeID := setup(t,
+			WithConditionEvaluationErrorPolicy(eval.EvaluationErrorPolicyTreatAsFalse),
+			WithCheckStrictConditionContext(true),
+		)
+
+		runtimeErrors := evaluationErrors(t, "under_quota")
+
+		for _, object := range []string{"document:1", "document:2"} {
+			resp, err := check(s, storeID, object)
+			require.NoError(t, err)
+			require.False(t, resp.GetAllowed())
+		}
+
+		require.InDelta(t, runtimeErrors+1, evaluationErrors(t, "under_quota"), 0)
+	})
+
+	t.Run("treat_as_true", func(t *testing.T) {
+		s, storeID := setup(t,
+			WithConditionEvaluationErrorPolicy(eval.EvaluationErrorPolicyTreatAsTrue),
+			WithCheckStrictConditionContext(true),
+		)
+
+		for _, object := range []string{"document:1", "document:2"} {
+			resp, err := check(s, storeID, object)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+		}
+
+		// conditions that evaluate are still applied
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+			Context:  testutils.MustNewStruct(t, map[string]interface{}{"region": "us"}),
+		})
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("unknown_policy", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		_, err := NewServerWithOpts(
+			WithDatastore(ds),
+			WithConditionEvaluationErrorPolicy("ignore"),
+		)
+		require.ErrorContains(t, err, "unknown condition evaluation error policy")
+	})
+}
+
+func TestServerWithCheckStrictConditionContext(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, user with in_region]
+		type folder
+			relations
+				define viewer: [user]
+		type document
+			relations
+				define parent: [folder, folder with in_region]
+				define viewer: [user, user with in_region, user:* with in_region, group#member, group#member with in_region] or viewer from parent
+
+		condition in_region(region: string) {
+			region == "eu"
+		}`)
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+			Conditions:      model.GetConditions(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.NewTupleKeyWithCondition("document:direct", "viewer", "user:jon", "in_region", nil),
+					tuple.NewTupleKeyWithCondition("document:public", "viewer", "user:*", "in_region", nil),
+					tuple.NewTupleKeyWithCondition("group:eu", "member", "user:jon", "in_region", nil),
+					tuple.NewTupleKey("document:group_member", "viewer", "group:eu#member"),
+					tuple.NewTupleKeyWithCondition("document:group", "viewer", "group:all#member", "in_region", nil),
+					tuple.NewTupleKey("group:all", "member", "user:jon"),
+					tuple.NewTupleKey("folder:shared", "viewer", "user:jon"),
+					tuple.NewTupleKeyWithCondition("document:parent", "parent", "folder:shared", "in_region", nil),
+					// the condition has all its parameters in the context of the tuple
+					tuple.NewTupleKeyWithCondition("document:tuple_context", "viewer", "user:jon", "in_region",
+						testutils.MustNewStruct(t, map[string]interface{}{"region": "eu"})),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, storeID
+	}
+
+	check := func(s *Server, storeID, object string) (*openfgav1.CheckResponse, error) {
+		// without context
+		return s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(object, "viewer", "user:jon"),
+		})
+	}
+
+	listObjects := func(s *Server, storeID string) (*openfgav1.ListObjectsResponse, error) {
+		return s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  storeID,
+			Type:     "document",
+			Relation: "viewer",
+			User:     "user:jon",
+		})
+	}
+
+	listUsers := func(s *Server, storeID, object string) (*openfgav1.ListUsersResponse, error) {
+		return s.ListUsers(ctx, &openfgav1.ListUsersRequest{
+			StoreId:     storeID,
+			Object:      &openfgav1.Object{Type: "document", Id: strings.TrimPrefix(object, "document:")},
+			Relation:    "viewer",
+			UserFilters: []*openfgav1.UserTypeFilter{{Type: "user"}},
+		})
+	}
+
+	// every path reads a tuple whose condition misses its parameter
+	objects := []string{"document:direct", "document:public", "document:group_member", "document:group", "document:parent"}
+
+	t.Run("not_strict", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		for _, object := range objects {
+			resp, err := check(s, storeID, object)
+			require.NoError(t, err, object)
+			require.False(t, resp.GetAllowed(), object)
+		}
+
+		resp, err := check(s, storeID, "document:tuple_context")
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+
+		listObjectsResp, err := listObjects(s, storeID)
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:tuple_context"}, listObjectsResp.GetObjects())
+
+		for _, object := range objects {
+			listUsersResp, err := listUsers(s, storeID, object)
+			require.NoError(t, err, object)
+			require.Empty(t, listUsersResp.GetUsers(), object)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		s, storeID := setup(t, WithCheckStrictConditionContext(true))
+
+		for _, object := range objects {
+			_, err := check(s, storeID, object)
+			require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err), object)
+		}
+
+		resp, err := check(s, storeID, "document:tuple_context")
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+
+		_, err = listObjects(s, storeID)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+
+		for _, object := range objects {
+			_, err := listUsers(s, storeID, object)
+			require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err), object)
+		}
+
+		listUsersResp, err := listUsers(s, storeID, "document:tuple_context")
+		require.NoError(t, err)
+		require.Len(t, listUsersResp.GetUsers(), 1)
+	})
+
+	t.Run("strict_with_treat_as_false_policy", func(t *testing.T) {
+		s, storeID := setup(t,
+			WithCheckStrictConditionContext(true),
+			WithConditionEvaluationErrorPolicy(eval.EvaluationErrorPolicyTreatAsFalse),
+		)
+
+		for _, object := range objects {
+			resp, err := check(s, storeID, object)
+			require.NoError(t, err, object)
+			require.False(t, resp.GetAllowed(), object)
+		}
+	})
+}
+
+func TestServerWithKnownCheckResults(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]
+		type document
+			relations
+				define viewer: [group#member]`)
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+					tuple.NewTupleKey("document:2", "viewer", "group:ops#member"),
+					tuple.NewTupleKey("group:ops", "member", "user:bob"),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, storeID
+	}
+
+	check := func(s *Server, storeID, object, user string, knownResults ...string) (bool, error) {
+		pairs := make([]string, 0, 2*len(knownResults))
+		for _, knownResult := range knownResults {
+			pairs = append(pairs, KnownCheckResultsHeader, knownResult)
+		}
+
+		resp, err := s.Check(metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...)), &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(object, "viewer", user),
+		})
+		return resp.GetAllowed(), err
+	}
+
+	t.Run("ignored_when_disabled", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		allowed, err := check(s, storeID, "document:1", "user:anne", "group:eng#member@user:anne=true")
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		allowed, err = check(s, storeID, "document:2", "user:bob", "group:ops#member@user:bob=false")
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		// not even validated
+		_, err = check(s, storeID, "document:1", "user:anne", "not a result")
+		require.NoError(t, err)
+	})
+
+	t.Run("honored_when_enabled", func(t *testing.T) {
+		s, storeID := setup(t, WithKnownCheckResults(true))
+
+		allowed, err := check(s, storeID, "document:1", "user:anne", "group:eng#member@user:anne=true")
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		allowed, err = check(s, storeID, "document:2", "user:bob", "group:ops#member@user:bob=false")
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		// results of other subproblems don't change the Check
+		allowed, err = check(s, storeID, "document:2", "user:bob", "group:eng#member@user:bob=true")
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("not_cached", func(t *testing.T) {
+		s, storeID := setup(t, WithKnownCheckResults(true), WithCheckQueryCacheEnabled(true))
+
+		allowed, err := check(s, storeID, "document:1", "user:anne", "group:eng#member@user:anne=true")
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		allowed, err = check(s, storeID, "document:1", "user:anne")
+		require.NoError(t, err)
+		require.False(t, allowed)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		s, storeID := setup(t, WithKnownCheckResults(true))
+
+		tooMany := make([]string, 0, maxKnownCheckResults+1)
+		for i := 0; i <= maxKnownCheckResults; i++ {
+			tooMany = append(tooMany, fmt.Sprintf("group:%d#member@user:anne=true", i))
+		}
+
+		for name, knownResults := range map[string][]string{
+			"missing_result":      {"group:eng#member@user:anne"},
+			"not_a_boolean":       {"group:eng#member@user:anne=yes"},
+			"malformed_tuple":     {"group:eng@user:anne=true"},
+			"undefined_relation":  {"group:eng#owner@user:anne=true"},
+			"undefined_type":      {"team:eng#member@user:anne=true"},
+			"conflicting_results": {"group:eng#member@user:anne=true", "group:eng#member@user:anne=false"},
+			"the_check_itself":    {"document:1#viewer@user:anne=true"},
+			"too_many":            tooMany,
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := check(s, storeID, "document:1", "user:anne", knownResults...)
+				require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+			})
+		}
+	})
+}
+
+// headerRecorder is a transport that records the headers set on the responses.
+type headerRecorder struct {
+	mu      sync.Mutex
+	headers map[string]string
+}
+
+func (h *headerRecorder) SetHeader(_ context.Context, key, value string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.headers[key] = value
+}
+
+func (h *headerRecorder) header(key string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	value, ok := h.headers[key]
+	return value, ok
+}
+
+func TestServerWithModelNotFoundFallback(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	// a model ID that was never written
+	const missingModelID = "01GZFXJ2XPAF8FBHDKJ83XAJQP"
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, *headerRecorder, string, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		transport := &headerRecorder{headers: map[string]string{}}
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds), WithTransport(transport)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:anne")},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, transport, storeID, writeModelResp.GetAuthorizationModelId()
+	}
+
+	t.Run("not_found_by_default", func(t *testing.T) {
+		s, transport, storeID, _ := setup(t)
+
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: missingModelID,
+			TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_authorization_model_not_found), status.Code(err))
+		require.ErrorContains(t, err, missingModelID)
+
+		_, ok := transport.header(AuthorizationModelFallbackHeader)
+		require.False(t, ok)
+	})
+
+	t.Run("falls_back_to_the_latest_model", func(t *testing.T) {
+		s, transport, storeID, modelID := setup(t, WithModelNotFoundFallback(true))
+
+		checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: missingModelID,
+			TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+
+		fallback, ok := transport.header(AuthorizationModelFallbackHeader)
+		require.True(t, ok)
+		require.Equal(t, missingModelID, fallback)
+		resolved, _ := transport.header(AuthorizationModelIDHeader)
+		require.Equal(t, modelID, resolved)
+
+		listObjectsResp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: missingModelID,
+			Type:                 "document",
+			Relation:             "viewer",
+			User:                 "user:anne",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1"}, listObjectsResp.GetObjects())
+	})
+
+	t.Run("no_fallback_for_existing_models", func(t *testing.T) {
+		s, transport, storeID, modelID := setup(t, WithModelNotFoundFallback(true))
+
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+
+		_, ok := transport.header(AuthorizationModelFallbackHeader)
+		require.False(t, ok)
+	})
+
+	t.Run("no_fallback_for_writes", func(t *testing.T) {
+		s, _, storeID, _ := setup(t, WithModelNotFoundFallback(true))
+
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: missingModelID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:2", "viewer", "user:anne")},
+			},
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_authorization_model_not_found), status.Code(err))
+	})
+}
+
+func TestServerWithCheckMaxVisitedObjects(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define parent: [folder]
+				define viewer: [user] or viewer from parent
+		type document
+			relations
+				define parent: [folder]
+				define viewer: [user] or viewer from parent`)
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		// a wide hierarchy: document:1 is in 10 folders, each of them in 8 other folders
+		var tuples []*openfgav1.TupleKey
+		for i := 0; i < 10; i++ {
+			folder := fmt.Sprintf("folder:%d", i)
+			tuples = append(tuples, tuple.NewTupleKey("document:1", "parent", folder))
+			for j := 0; j < 8; j++ {
+				tuples = append(tuples, tuple.NewTupleKey(folder, "parent", fmt.Sprintf("folder:%d-%d", i, j)))
+			}
+		}
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tuples},
+		})
+		require.NoError(t, err)
+
+		return s, storeID
+	}
+
+	checkRequest := func(storeID string) *openfgav1.CheckRequest {
+		return &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+		}
+	}
+
+	t.Run("wide_hierarchy_exceeding_the_limit", func(t *testing.T) {
+		s, storeID := setup(t, WithCheckMaxVisitedObjects(50))
+
+		_, err := s.Check(ctx, checkRequest(storeID))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_authorization_model_resolution_too_complex), status.Code(err))
+		require.ErrorContains(t, err, "distinct objects, more than the allowed limit of 50")
+	})
+
+	t.Run("wide_hierarchy_within_the_limit", func(t *testing.T) {
+		s, storeID := setup(t, WithCheckMaxVisitedObjects(91))
+
+		resp, err := s.Check(ctx, checkRequest(storeID))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("no_limit", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		resp, err := s.Check(ctx, checkRequest(storeID))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+}
+
+func TestServerCloseStopsBackgroundTasks(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	// the context given to the server is never canceled, so only Close can stop the background tasks
+	s := MustNewServerWithOpts(
+		WithContext(ctx),
+		WithDatastore(ds),
+		WithCheckTrackerEnabled(true),
+		WithCheckQueryCacheEnabled(true),
+		WithDispatchThrottlingCheckResolverEnabled(true),
+		WithListObjectsDispatchThrottlingEnabled(true),
+		WithListUsersDispatchThrottlingEnabled(true),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         createStoreResp.GetId(),
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Check(ctx, &openfgav1.CheckRequest{
+		StoreId:              createStoreResp.GetId(),
+		AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+		TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+	})
+	require.NoError(t, err)
+}
+
+func TestServerWithMemoryDatastore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	test.RunAllTests(t, ds)
+}
+
+func TestServerWithUnknownStore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	checkRequest := &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+	}
+
+	requireStoreNotFound := func(t *testing.T, err error) {
+		t.Helper()
+		require.Error(t, err)
+		e, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.Code(openfgav1.NotFoundErrorCode_store_id_not_found), e.Code())
+		require.Contains(t, e.Message(), storeID)
+	}
+
+	t.Run("check_returns_store_not_found_by_default", func(t *testing.T) {
+		ds := memory.New()
+		s := MustNewServerWithOpts(WithDatastore(ds))
+		t.Cleanup(s.Close)
+
+		_, err := s.Check(ctx, checkRequest)
+		requireStoreNotFound(t, err)
+	})
+
+	t.Run("check_returns_not_allowed_if_enabled", func(t *testing.T) {
+		ds := memory.New()
+		s := MustNewServerWithOpts(WithDatastore(ds), WithDenyCheckOnUnknownStore(true))
+		t.Cleanup(s.Close)
+
+		checkResp, err := s.Check(ctx, checkRequest)
+		require.NoError(t, err)
+		require.False(t, checkResp.GetAllowed())
+	})
+
+	t.Run("writes_always_return_store_not_found", func(t *testing.T) {
+		ds := memory.New()
+		s := MustNewServerWithOpts(WithDatastore(ds), WithDenyCheckOnUnknownStore(true))
+		t.Cleanup(s.Close)
+
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")},
+			},
+		})
+		requireStoreNotFound(t, err)
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user`)
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		requireStoreNotFound(t, err)
+
+		_, err = ds.FindLatestAuthorizationModel(ctx, storeID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestServerWithStoreWithoutModel(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, *openfgav1.CheckRequest) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		return s, &openfgav1.CheckRequest{
+			StoreId:  createStoreResp.GetId(),
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+		}
+	}
+
+	t.Run("check_returns_no_model_error_by_default", func(t *testing.T) {
+		s, checkRequest := setup(t)
+
+		_, err := s.Check(ctx, checkRequest)
+		require.Error(t, err)
+		e, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_latest_authorization_model_not_found), e.Code())
+		require.Contains(t, e.Message(), checkRequest.GetStoreId())
+		require.Contains(t, e.Message(), "has no authorization model yet")
+		require.Contains(t, e.Message(), "WriteAuthorizationModel")
+	})
+
+	t.Run("check_returns_not_allowed_if_enabled", func(t *testing.T) {
+		s, checkRequest := setup(t, WithDenyCheckOnStoreWithoutModel(true))
+
+		checkResp, err := s.Check(ctx, checkRequest)
+		require.NoError(t, err)
+		require.False(t, checkResp.GetAllowed())
+	})
+
+	t.Run("check_with_unknown_model_id_returns_error_if_enabled", func(t *testing.T) {
+		s, checkRequest := setup(t, WithDenyCheckOnStoreWithoutModel(true))
+		checkRequest.AuthorizationModelId = ulid.Make().String()
+
+		_, err := s.Check(ctx, checkRequest)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_authorization_model_not_found), status.Code(err))
+	})
+
+	t.Run("other_apis_return_no_model_error_if_enabled", func(t *testing.T) {
+		s, checkRequest := setup(t, WithDenyCheckOnStoreWithoutModel(true))
+
+		_, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  checkRequest.GetStoreId(),
+			Type:     "document",
+			Relation: "viewer",
+			User:     "user:jon",
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_latest_authorization_model_not_found), status.Code(err))
+	})
+
+	t.Run("unknown_store_returns_store_not_found_if_enabled", func(t *testing.T) {
+		s, checkRequest := setup(t, WithDenyCheckOnStoreWithoutModel(true))
+		checkRequest.StoreId = ulid.Make().String()
+
+		_, err := s.Check(ctx, checkRequest)
+		require.Equal(t, codes.Code(openfgav1.NotFoundErrorCode_store_id_not_found), status.Code(err))
+	})
+}
+
+func TestServerWithErrorVerbosity(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	writeInvalidTuple := func(t *testing.T, opts ...OpenFGAServiceV1Option) error {
+		t.Helper()
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define viewer: [user]`)
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         createStoreResp.GetId(),
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: createStoreResp.GetId(),
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:secret-doc", "editor", "user:secret-user")},
+			},
+		})
+		require.Error(t, err)
+		return err
+	}
+
+	t.Run("errors_include_details_by_default", func(t *testing.T) {
+		e, ok := status.FromError(writeInvalidTuple(t))
+		require.True(t, ok)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), e.Code())
+		require.Contains(t, e.Message(), "document:secret-doc")
+	})
+
+	t.Run("terse_errors_only_include_their_code", func(t *testing.T) {
+		err := writeInvalidTuple(t, WithErrorVerbosity(serverErrors.ErrorVerbosityTerse))
+
+		e, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), e.Code())
+		require.Equal(t, "validation_error", e.Message())
+		require.Empty(t, e.Details())
+		require.NotContains(t, e.Proto().String(), "secret")
+
+		require.Equal(t, "validation_error", err.Error())
+
+		// the full error is still available to be logged
+		require.Contains(t, serverErrors.Verbose(err).Error(), "document:secret-doc")
+	})
+}
+
+func TestServerCheckWithMinChangelogToken(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")},
+		},
+	})
+	require.NoError(t, err)
+
+	changesResp, err := s.ReadChanges(ctx, &openfgav1.ReadChangesRequest{StoreId: storeID})
+	require.NoError(t, err)
+
+	checkRequest := &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+	}
+
+	t.Run("resolves_with_token_returned_by_read_changes", func(t *testing.T) {
+		tokenCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(MinChangelogTokenHeader, changesResp.GetContinuationToken()))
+
+		checkResp, err := s.Check(tokenCtx, checkRequest)
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+	})
+
+	t.Run("rejects_invalid_token", func(t *testing.T) {
+		tokenCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(MinChangelogTokenHeader, "not-a-token!"))
+
+		_, err := s.Check(tokenCtx, checkRequest)
+		require.ErrorIs(t, err, serverErrors.InvalidContinuationToken)
+	})
+}
+
+func TestServerWithMaxTuplesPerWrite(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	tupleKeys := func(n int) []*openfgav1.TupleKey {
+		tks := make([]*openfgav1.TupleKey, 0, n)
+		for i := 0; i < n; i++ {
+			tks = append(tks, tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:jon"))
+		}
+		return tks
+	}
+
+	setup := func(t *testing.T) (*Server, string) {
+		ds := memory.New(memory.WithMaxTuplesPerWrite(10))
+		s := MustNewServerWithOpts(WithDatastore(ds), WithMaxTuplesPerWrite(50))
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         createStoreResp.GetId(),
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		return s, createStoreResp.GetId()
+	}
+
+	t.Run("writes_more_tuples_than_the_datastore_limit", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tupleKeys(45)},
+		})
+		require.NoError(t, err)
+
+		readResp, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: storeID, PageSize: wrapperspb.Int32(100)})
+		require.NoError(t, err)
+		require.Len(t, readResp.GetTuples(), 45)
+	})
+
+	t.Run("writes_nothing_if_a_batch_fails", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tupleKeys(1)},
+		})
+		require.NoError(t, err)
+
+		// the last batch fails because the first tuple already exists
+		writes := tupleKeys(45)
+		writes[0], writes[44] = writes[44], writes[0]
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: writes},
+		})
+		require.Error(t, err)
+
+		readResp, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: storeID, PageSize: wrapperspb.Int32(100)})
+		require.NoError(t, err)
+		require.Len(t, readResp.GetTuples(), 1)
+	})
+
+	t.Run("rejects_more_tuples_than_the_limit", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tupleKeys(51)},
+		})
+		e, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_exceeded_entity_limit), e.Code())
+	})
+
+	t.Run("requires_a_datastore_that_writes_batches", func(t *testing.T) {
+		ds := memory.New(memory.WithMaxTuplesPerWrite(10))
+		t.Cleanup(ds.Close)
+
+		// embedding the datastore hides its WriteBatches method
+		_, err := NewServerWithOpts(
+			WithDatastore(struct{ storage.OpenFGADatastore }{ds}),
+			WithMaxTuplesPerWrite(50),
+		)
+		require.Error(t, err)
+	})
+}
+
+func TestServerWarmCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, string) {
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(memory.New())}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         createStoreResp.GetId(),
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		return s, createStoreResp.GetId()
+	}
+
+	write := func(t *testing.T, s *Server, storeID string, writes []*openfgav1.TupleKey, deletes []*openfgav1.TupleKeyWithoutCondition) {
+		t.Helper()
+
+		req := &openfgav1.WriteRequest{StoreId: storeID}
+		if len(writes) > 0 {
+			req.Writes = &openfgav1.WriteRequestWrites{TupleKeys: writes}
+		}
+		if len(deletes) > 0 {
+			req.Deletes = &openfgav1.WriteRequestDeletes{TupleKeys: deletes}
+		}
+		_, err := s.Write(ctx, req)
+		require.NoError(t, err)
+	}
+
+	t.Run("subsequent_checks_hit_the_cache", func(t *testing.T) {
+		s, storeID := setup(t, WithCheckQueryCacheEnabled(true))
+
+		tk := tuple.NewTupleKey("document:1", "viewer", "user:jon")
+		write(t, s, storeID, []*openfgav1.TupleKey{tk}, nil)
+
+		warmResp, err := s.WarmCache(ctx, &commands.WarmCacheRequest{
+			StoreID:   storeID,
+			TupleKeys: []*openfgav1.TupleKey{tk},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, warmResp.Warmed)
+
+		// the tuple is gone, so the check can only be allowed if its result is served from the cache
+		write(t, s, storeID, nil, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)})
+
+		checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+		})
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+	})
+
+	t.Run("derives_checks_from_changes", func(t *testing.T) {
+		s, storeID := setup(t, WithCheckQueryCacheEnabled(true))
+
+		write(t, s, storeID, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			tuple.NewTupleKey("document:2", "viewer", "user:jon"),
+		}, nil)
+		write(t, s, storeID, nil, []*openfgav1.TupleKeyWithoutCondition{
+			tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:2", "viewer", "user:jon")),
+		})
+
+		warmResp, err := s.WarmCache(ctx, &commands.WarmCacheRequest{StoreID: storeID})
+		require.NoError(t, err)
+		require.Equal(t, 1, warmResp.Warmed)
+	})
+
+	t.Run("requires_the_check_query_cache", func(t *testing.T) {
+		s, storeID := setup(t)
+
+		_, err := s.WarmCache(ctx, &commands.WarmCacheRequest{StoreID: storeID})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
+
+// propagatingCacheBackend simulates a Check cache backend shared by several instances, each of which keeps a
+// local copy of the entries that it reads and writes, and to which invalidations propagate after a delay.
+type propagatingCacheBackend struct {
+	delay time.Duration
+
+	mu        sync.Mutex
+	instances []*propagatingCacheInstance
+	pending   sync.WaitGroup
+}
+
+type propagatingCacheInstance struct {
+	backend *propagatingCacheBackend
+	entries map[string][]byte
+}
+
+func (b *propagatingCacheBackend) newInstance() *propagatingCacheInstance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	instance := &propagatingCacheInstance{backend: b, entries: map[string][]byte{}}
+	b.instances = append(b.instances, instance)
+	return instance
+}
+
+func (i *propagatingCacheInstance) Get(key string) ([]byte, error) {
+	i.backend.mu.Lock()
+	defer i.backend.mu.Unlock()
+	return i.entries[key], nil
+}
+
+func (i *propagatingCacheInstance) Set(key string, value []byte, _ time.Duration) error {
+	i.backend.mu.Lock()
+	defer i.backend.mu.Unlock()
+	i.entries[key] = value
+	return nil
+}
+
+func (i *propagatingCacheInstance) InvalidateStore(_ context.Context, storeID string) error {
+	b := i.backend
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, instance := range b.instances {
+		b.pending.Add(1)
+		time.AfterFunc(b.delay, func() {
+			defer b.pending.Done()
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for key := range instance.entries {
+				if strings.HasPrefix(key, graph.CheckCacheKeyStorePrefix(storeID)) {
+					delete(instance.entries, key)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+func (i *propagatingCacheInstance) AwaitStoreInvalidation(ctx context.Context, _ string) error {
+	done := make(chan struct{})
+	go func() {
+		i.backend.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sharedMapCacheBackend is a CheckCacheBackend shared by several servers, like a Redis by several instances.
+type sharedMapCacheBackend struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (b *sharedMapCacheBackend) Get(key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.entries[key], nil
+}
+
+func (b *sharedMapCacheBackend) Set(key string, value []byte, _ time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries[key] = value
+	return nil
+}
+
+// modelReadCountingDatastore counts the reads of authorization models by ID.
+type modelReadCountingDatastore struct {
+	storage.OpenFGADatastore
+	modelReads atomic.Int32
+}
+
+func (d *modelReadCountingDatastore) ReadAuthorizationModel(ctx context.Context, storeID, modelID string) (*openfgav1.AuthorizationModel, error) {
+	d.modelReads.Add(1)
+	return d.OpenFGADatastore.ReadAuthorizationModel(ctx, storeID, modelID)
+}
+
+func TestServerWithSharedAuthorizationModelCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	t.Run("a_second_server_reads_the_model_from_the_shared_cache", func(t *testing.T) {
+		ds := &modelReadCountingDatastore{OpenFGADatastore: memory.New()}
+		backend := &sharedMapCacheBackend{entries: map[string][]byte{}}
+		newServer := func() *Server {
+			s := MustNewServerWithOpts(
+				WithDatastore(ds),
+				WithCheckQueryCacheBackend(backend),
+				WithSharedAuthorizationModelCache(true),
+			)
+			t.Cleanup(s.Close)
+			return s
+		}
+
+		first := newServer()
+		createStoreResp, err := first.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define viewer: [user]`)
+		writeModelResp, err := first.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+		modelID := writeModelResp.GetAuthorizationModelId()
+
+		check := func(s *Server) {
+			_, err := s.Check(ctx, &openfgav1.CheckRequest{
+				StoreId:              storeID,
+				AuthorizationModelId: modelID,
+				TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			})
+			require.NoError(t, err)
+		}
+
+		check(first)
+		require.Equal(t, int32(1), ds.modelReads.Load())
+
+		check(newServer())
+		require.Equal(t, int32(1), ds.modelReads.Load())
+	})
+
+	t.Run("needs_a_backend", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		_, err := NewServerWithOpts(WithDatastore(ds), WithSharedAuthorizationModelCache(true))
+		require.ErrorContains(t, err, "needs a check query cache backend")
+	})
+}
+
+func TestServerWaitForCacheInvalidation(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+
+	// setup returns two instances that share a datastore and a Check cache backend, where user:anne can view
+	// document:1 according to the cache of the second instance
+	setup := func(t *testing.T, backend *propagatingCacheBackend) (*Server, *headerRecorder, *Server, string) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		transportA := &headerRecorder{headers: map[string]string{}}
+		podA := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithTransport(transportA),
+			WithCheckQueryCacheEnabled(true),
+			WithCheckQueryCacheBackend(backend.newInstance()),
+		)
+		t.Cleanup(podA.Close)
+		podB := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithCheckQueryCacheEnabled(true),
+			WithCheckQueryCacheBackend(backend.newInstance()),
+		)
+		t.Cleanup(podB.Close)
+
+		createStoreResp, err := podA.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = podA.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		_, err = podA.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{tk}},
+		})
+		require.NoError(t, err)
+		backend.pending.Wait()
+
+		checkResp, err := podB.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+		})
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+
+		return podA, transportA, podB, storeID
+	}
+
+	deleteTuple := func(t *testing.T, ctx context.Context, s *Server, storeID string) {
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Deletes: &openfgav1.WriteRequestDeletes{
+				TupleKeys: []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	checkOnPodB := func(t *testing.T, s *Server, storeID string) *openfgav1.CheckResponse {
+		checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+		})
+		require.NoError(t, err)
+		return checkResp
+	}
+
+	t.Run("without_waiting_other_instances_serve_stale_results", func(t *testing.T) {
+		backend := &propagatingCacheBackend{delay: 100 * time.Millisecond}
+		podA, transportA, podB, storeID := setup(t, backend)
+
+		deleteTuple(t, ctx, podA, storeID)
+		_, ok := transportA.header(CacheInvalidationAcknowledgedHeader)
+		require.False(t, ok)
+
+		// within the propagation window
+		require.True(t, checkOnPodB(t, podB, storeID).GetAllowed())
+
+		backend.pending.Wait()
+		require.False(t, checkOnPodB(t, podB, storeID).GetAllowed())
+	})
+
+	t.Run("waiting_closes_the_staleness_window", func(t *testing.T) {
+		backend := &propagatingCacheBackend{delay: 100 * time.Millisecond}
+		podA, transportA, podB, storeID := setup(t, backend)
+
+		waitCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(WaitForCacheInvalidationHeader, "true"))
+		start := time.Now()
+		deleteTuple(t, waitCtx, podA, storeID)
+		require.GreaterOrEqual(t, time.Since(start), backend.delay)
+
+		acknowledged, _ := transportA.header(CacheInvalidationAcknowledgedHeader)
+		require.Equal(t, "true", acknowledged)
+		require.False(t, checkOnPodB(t, podB, storeID).GetAllowed())
+	})
+
+	t.Run("the_write_succeeds_if_the_wait_times_out", func(t *testing.T) {
+		backend := &propagatingCacheBackend{delay: 500 * time.Millisecond}
+		podA, transportA, _, storeID := setup(t, backend)
+
+		waitCtx, cancel := context.WithTimeout(metadata.NewIncomingContext(ctx, metadata.Pairs(WaitForCacheInvalidationHeader, "true")), 10*time.Millisecond)
+		defer cancel()
+		deleteTuple(t, waitCtx, podA, storeID)
+
+		acknowledged, _ := transportA.header(CacheInvalidationAcknowledgedHeader)
+		require.Equal(t, "false", acknowledged)
+		backend.pending.Wait()
+	})
+}
+
+func TestServerCheckQueryCacheChangelogInvalidation(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	t.Run("needs_a_backend_that_can_invalidate_its_entries", func(t *testing.T) {
+		_, err := NewServerWithOpts(
+			WithDatastore(memory.New()),
+			WithCheckQueryCacheEnabled(true),
+			WithCheckQueryCacheBackend(&sharedMapCacheBackend{entries: map[string][]byte{}}),
+			WithCheckQueryCacheChangelogInvalidationInterval(time.Second),
+		)
+		require.ErrorContains(t, err, "needs a check query cache backend that can invalidate its entries")
+	})
+
+	t.Run("a_change_that_wasnt_invalidated_invalidates_the_shared_cache", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		backend := rediscache.New(client)
+		t.Cleanup(backend.Close)
+
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithCheckQueryCacheEnabled(true),
+			WithCheckQueryCacheBackend(backend),
+			WithCheckQueryCacheChangelogInvalidationInterval(10*time.Millisecond),
+		)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define viewer: [user]`)
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{tk}},
+		})
+		require.NoError(t, err)
+
+		check := func() *openfgav1.CheckResponse {
+			checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+				StoreId:  storeID,
+				TupleKey: tuple.NewCheckRequestTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+			})
+			require.NoError(t, err)
+			return checkResp
+		}
+		require.True(t, check().GetAllowed())
+
+		// wait for the changelog of the store to be read once, so that the next change invalidates it
+		time.Sleep(50 * time.Millisecond)
+
+		// the tuple is deleted by an instance that fails to invalidate the cache
+		err = ds.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}, nil)
+		require.NoError(t, err)
+		require.True(t, check().GetAllowed())
+
+		require.Eventually(t, func() bool {
+			return !check().GetAllowed()
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestServerPruneAuthorizationModels(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	setup := func(t *testing.T, ds storage.OpenFGADatastore) (*Server, string, []string) {
+		s := MustNewServerWithOpts(WithDatastore(ds))
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		var modelIDs []string
+		for i := 0; i < 3; i++ {
+			writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+				StoreId:         createStoreResp.GetId(),
+				TypeDefinitions: model.GetTypeDefinitions(),
+				SchemaVersion:   model.GetSchemaVersion(),
+			})
+			require.NoError(t, err)
+			modelIDs = append(modelIDs, writeModelResp.GetAuthorizationModelId())
+		}
+
+		return s, createStoreResp.GetId(), modelIDs
+	}
+
+	t.Run("preserves_the_models_that_requests_are_pinned_to", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+		s, storeID, modelIDs := setup(t, ds)
+
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelIDs[0],
+			TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+		})
+		require.NoError(t, err)
+
+		resp, err := s.PruneAuthorizationModels(ctx, &commands.PruneAuthorizationModelsRequest{StoreID: storeID, Retain: 1})
+		require.NoError(t, err)
+		require.Equal(t, 1, resp.Pruned)
+
+		_, err = ds.ReadAuthorizationModel(ctx, storeID, modelIDs[0])
+		require.NoError(t, err)
+		_, err = ds.ReadAuthorizationModel(ctx, storeID, modelIDs[1])
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		// once the model hasn't been pinned to for a while, it's pruned
+		s.pinnedModels.Store(pinnedModelKey{storeID: storeID, modelID: modelIDs[0]}, time.Now().Add(-2*pinnedModelInUseWindow))
+
+		resp, err = s.PruneAuthorizationModels(ctx, &commands.PruneAuthorizationModelsRequest{StoreID: storeID, Retain: 1})
+		require.NoError(t, err)
+		require.Equal(t, 1, resp.Pruned)
+
+		_, err = ds.ReadAuthorizationModel(ctx, storeID, modelIDs[2])
+		require.NoError(t, err)
+	})
+
+	t.Run("requires_a_datastore_that_can_prune", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+		s, storeID, _ := setup(t, struct{ storage.OpenFGADatastore }{ds})
+
+		_, err := s.PruneAuthorizationModels(ctx, &commands.PruneAuthorizationModelsRequest{StoreID: storeID, Retain: 1})
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+}
+
+// slowReverseExpandDatastore blocks the first ReadStartingWithUser until released.
+type slowReverseExpandDatastore struct {
+	storage.OpenFGADatastore
+	started  chan struct{}
+	released chan struct{}
+	once     sync.Once
+}
+
+func (s *slowReverseExpandDatastore) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	s.once.Do(func() {
+		close(s.started)
+		<-s.released
+	})
+	return s.OpenFGADatastore.ReadStartingWithUser(ctx, store, filter, options)
+}
+
+func TestServerListObjectsUsesTheModelResolvedAtTheStart(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	ds := &slowReverseExpandDatastore{
+		OpenFGADatastore: memory.New(),
+		started:          make(chan struct{}),
+		released:         make(chan struct{}),
+	}
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	writeModel := func(dsl string) {
+		model := parser.MustTransformDSLToProto(dsl)
+		_, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+	}
+
+	writeModel(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")},
+		},
+	})
+	require.NoError(t, err)
+
+	listObjectsRequest := &openfgav1.ListObjectsRequest{
+		StoreId:  storeID,
+		Type:     "document",
+		Relation: "viewer",
+		User:     "user:jon",
+	}
+
+	type result struct {
+		resp *openfgav1.ListObjectsResponse
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := s.ListObjects(ctx, listObjectsRequest)
+		results <- result{resp, err}
+	}()
+
+	// with the newer model, user:jon views no document since it isn't allowed to
+	<-ds.started
+	writeModel(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define allowed: [user]
+				define viewer: [user] and allowed`)
+	close(ds.released)
+
+	res := <-results
+	require.NoError(t, res.err)
+	require.Equal(t, []string{"document:1"}, res.resp.GetObjects())
+
+	listObjectsResp, err := s.ListObjects(ctx, listObjectsRequest)
+	require.NoError(t, err)
+	require.Empty(t, listObjectsResp.GetObjects())
+}
+
+type recordedMetric struct {
+	name  string
+	value float64
+	tags  map[string]string
+}
+
+// recordingMetricsSink records the metrics it receives.
+type recordingMetricsSink struct {
+	mu         sync.Mutex
+	counters   []recordedMetric
+	histograms []recordedMetric
+	gauges     []recordedMetric
+}
+
+func (r *recordingMetricsSink) IncCounter(name string, value float64, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = append(r.counters, recordedMetric{name: name, value: value, tags: tags})
+}
+
+func (r *recordingMetricsSink) ObserveHistogram(name string, value float64, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.histograms = append(r.histograms, recordedMetric{name: name, value: value, tags: tags})
+}
+
+func (r *recordingMetricsSink) SetGauge(name string, value float64, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges = append(r.gauges, recordedMetric{name: name, value: value, tags: tags})
+}
+
+func TestServerWithMetricsSink(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	sink := &recordingMetricsSink{}
+	s := MustNewServerWithOpts(WithDatastore(ds), WithMetricsSink(sink))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Check(ctx, &openfgav1.CheckRequest{
+		StoreId:              storeID,
+		AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+		TupleKey:             tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:jon"),
+	})
+	require.NoError(t, err)
+
+	checkTags := map[string]string{"grpc_service": openfgav1.OpenFGAService_ServiceDesc.ServiceName, "grpc_method": "check"}
+	require.Len(t, sink.histograms, 3)
+	require.Equal(t, recordedMetric{name: datastoreQueryCountHistogramName, value: 1, tags: checkTags}, sink.histograms[0])
+	require.Equal(t, recordedMetric{name: dispatchCountHistogramName, value: 0, tags: checkTags}, sink.histograms[1])
+	require.Equal(t, requestDurationHistogramName, sink.histograms[2].name)
+	require.Equal(t, map[string]string{
+		"grpc_service":          openfgav1.OpenFGAService_ServiceDesc.ServiceName,
+		"grpc_method":           "check",
+		"datastore_query_count": "50",
+		"dispatch_count":        "50",
+		"consistency":           openfgav1.ConsistencyPreference_UNSPECIFIED.String(),
+	}, sink.histograms[2].tags)
+
+	t.Run("watch", func(t *testing.T) {
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")},
+			},
+		})
+		require.NoError(t, err)
+
+		err = s.Watch(ctx, &commands.WatchRequest{StoreID: storeID}, func(*commands.WatchEvent) error {
+			return context.Canceled
+		})
+		require.ErrorIs(t, err, context.Canceled)
+
+		require.Equal(t, []recordedMetric{
+			{name: watchInFlightStreamsGaugeName, value: 1},
+			{name: watchInFlightStreamsGaugeName, value: 0},
+		}, sink.gauges)
+		require.Equal(t, []recordedMetric{
+			{name: watchStreamsCounterName, value: 1, tags: map[string]string{"grpc_code": status.Code(err).String()}},
+		}, sink.counters)
+	})
+
+	t.Run("requires_a_sink", func(t *testing.T) {
+		_, err := NewServerWithOpts(WithDatastore(ds), WithMetricsSink(nil))
+		require.ErrorContains(t, err, "the metrics sink must not be nil")
+	})
+}
+
+func TestServerWithCheckRelationAliases(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(WithDatastore(ds), WithCheckRelationAliases(true))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	require.NoError(t, ds.(storage.StoreSettingsBackend).WriteStoreSettings(ctx, storeID, &storage.StoreSettings{
+		RelationAliases: map[string]string{
+			"document#reader":  "viewer",
+			"document#deleter": "owner",
+		},
+	}))
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:jon")},
+		},
+	})
+	require.NoError(t, err)
+
+	check := func(object, relation string) (*openfgav1.CheckResponse, error) {
+		return s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(object, relation, "user:jon"),
+		})
+	}
+
+	for _, object := range []string{"document:1", "document:2"} {
+		t.Run(object, func(t *testing.T) {
+			canonicalResp, err := check(object, "viewer")
+			require.NoError(t, err)
+
+			aliasResp, err := check(object, "reader")
+			require.NoError(t, err)
+			require.Equal(t, canonicalResp.GetAllowed(), aliasResp.GetAllowed())
+		})
+	}
+
+	t.Run("undefined_canonical_relation", func(t *testing.T) {
+		_, err := check("document:1", "deleter")
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, "relation alias 'document#deleter' resolves to relation 'owner'")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := MustNewServerWithOpts(WithDatastore(ds))
+		t.Cleanup(s.Close)
+
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "reader", "user:jon"),
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("settings_are_read_once_per_check_and_cached", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			ttl   time.Duration
+			reads int
+		}{
+			"cached":   {ttl: time.Minute, reads: 1},
+			"uncached": {ttl: 0, reads: 2},
+		} {
+			t.Run(name, func(t *testing.T) {
+				counting := &storeSettingsReadCounter{OpenFGADatastore: ds, StoreSettingsBackend: ds.(storage.StoreSettingsBackend)}
+				s := MustNewServerWithOpts(
+					WithDatastore(counting),
+					WithCheckRelationAliases(true),
+					WithDefaultUserTypes(true),
+					WithStoreSettingsCacheTTL(test.ttl),
+				)
+				t.Cleanup(s.Close)
+
+				for i := 0; i < 2; i++ {
+					_, err := s.Check(ctx, &openfgav1.CheckRequest{
+						StoreId:  storeID,
+						TupleKey: tuple.NewCheckRequestTupleKey("document:1", "reader", "user:jon"),
+					})
+					require.NoError(t, err)
+				}
+				require.Equal(t, int64(test.reads), counting.reads.Load())
+			})
+		}
+	})
+}
+
+// storeSettingsReadCounter counts the reads of the settings of the stores.
+type storeSettingsReadCounter struct {
+	storage.OpenFGADatastore
+	storage.StoreSettingsBackend
+	reads atomic.Int64
+}
+
+func (c *storeSettingsReadCounter) ReadStoreSettings(ctx context.Context, store string) (*storage.StoreSettings, error) {
+	c.reads.Add(1)
+	return c.StoreSettingsBackend.ReadStoreSettings(ctx, store)
+}
+
+func TestServerWithModelChangeHandler(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user`)
+
+	writeModel := func(t *testing.T, s *Server) (string, string) {
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         createStoreResp.GetId(),
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+		return createStoreResp.GetId(), writeModelResp.GetAuthorizationModelId()
+	}
+
+	t.Run("called_after_a_model_write", func(t *testing.T) {
+		type modelChange struct{ storeID, modelID string }
+		changes := make(chan modelChange, 1)
+
+		s := MustNewServerWithOpts(
+			WithDatastore(memory.New()),
+			WithModelChangeHandler(func(storeID, modelID string) {
+				changes <- modelChange{storeID, modelID}
+			}),
+		)
+		t.Cleanup(s.Close)
+
+		storeID, modelID := writeModel(t, s)
+
+		select {
+		case change := <-changes:
+			require.Equal(t, modelChange{storeID, modelID}, change)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the model change handler wasn't called")
+		}
+	})
+
+	t.Run("not_called_after_a_failed_model_write", func(t *testing.T) {
+		s := MustNewServerWithOpts(
+			WithDatastore(memory.New()),
+			WithModelChangeHandler(func(storeID, modelID string) {
+				require.Fail(t, "the model change handler was called")
+			}),
+		)
+
+		_, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         ulid.Make().String(),
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.Error(t, err)
+
+		// Close waits for the handlers in flight
+		s.Close()
+	})
+
+	t.Run("panics_are_recovered_and_logged", func(t *testing.T) {
+		observerLogger, logs := observer.New(zap.ErrorLevel)
+		s := MustNewServerWithOpts(
+			WithDatastore(memory.New()),
+			WithLogger(&logger.ZapLogger{Logger: zap.New(observerLogger)}),
+			WithModelChangeHandler(func(storeID, modelID string) {
+				panic("handler failed")
+			}),
+		)
+
+		storeID, modelID := writeModel(t, s)
+		s.Close()
+
+		entries := logs.FilterMessage("model change handler panicked").All()
+		require.Len(t, entries, 1)
+		require.Equal(t, storeID, entries[0].ContextMap()["store_id"])
+		require.Equal(t, modelID, entries[0].ContextMap()["authorization_model_id"])
+		require.Equal(t, "handler failed", entries[0].ContextMap()["error"])
+	})
+}
+
+func TestServerWithCheckResolutionStrategy(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define parent: [folder]
+				define viewer: [user] or viewer from parent`)
+
+	t.Run("iterative_deepening", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithResolveNodeLimit(10),
+			WithCheckResolutionStrategy(graph.CheckResolutionStrategyIterativeDeepening),
+		)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		// folder:0 is a descendant of folder:8, and folder:9 of folder:20, which is too deep
+		var tuples []*openfgav1.TupleKey
+		for i := 0; i < 20; i++ {
+			tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("folder:%d", i), "parent", fmt.Sprintf("folder:%d", i+1)))
+		}
+		tuples = append(tuples, tuple.NewTupleKey("folder:8", "viewer", "user:anne"))
+		tuples = append(tuples, tuple.NewTupleKey("folder:20", "viewer", "user:bob"))
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tuples},
+		})
+		require.NoError(t, err)
+
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("folder:0", "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+
+		_, err = s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("folder:0", "viewer", "user:bob"),
+		})
+		require.ErrorIs(t, err, serverErrors.AuthorizationModelResolutionTooComplex)
+	})
+
+	t.Run("unknown_strategy", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		_, err := NewServerWithOpts(
+			WithDatastore(ds),
+			WithCheckResolutionStrategy("breadth-first"),
+		)
+		require.ErrorContains(t, err, "unknown check resolution strategy")
+	})
+}
+
+func TestServerWithCheckSelfRelations(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	t.Run("self_access", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(WithDatastore(ds), WithCheckSelfRelations("profile#viewer@user"))
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type profile
+				relations
+					define viewer: [user]`)
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		for user, allowed := range map[string]bool{"user:anne": true, "user:bob": false} {
+			resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+				StoreId:  storeID,
+				TupleKey: tuple.NewCheckRequestTupleKey("profile:anne", "viewer", user),
+			})
+			require.NoError(t, err)
+			require.Equal(t, allowed, resp.GetAllowed(), user)
+		}
+	})
+
+	t.Run("invalid_self_relation", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		_, err := NewServerWithOpts(WithDatastore(ds), WithCheckSelfRelations("profile#viewer"))
+		require.ErrorContains(t, err, "invalid self relation 'profile#viewer'")
+	})
+}
+
+func TestServerWithDefaultUserTypes(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(WithDatastore(ds), WithDefaultUserTypes(true))
+	t.Cleanup(s.Close)
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user, user:*, group#member]`)
+
+	createStore := func(t *testing.T, settings *storage.StoreSettings) string {
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		require.NoError(t, ds.(storage.StoreSettingsBackend).WriteStoreSettings(ctx, storeID, settings))
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+		return storeID
+	}
+
+	write := func(storeID string, writes []*openfgav1.TupleKey, deletes []*openfgav1.TupleKeyWithoutCondition) error {
+		req := &openfgav1.WriteRequest{StoreId: storeID}
+		if len(writes) > 0 {
+			req.Writes = &openfgav1.WriteRequestWrites{TupleKeys: writes}
+		}
+		if len(deletes) > 0 {
+			req.Deletes = &openfgav1.WriteRequestDeletes{TupleKeys: deletes}
+		}
+		_, err := s.Write(ctx, req)
+		return err
+	}
+
+	check := func(storeID string, tk *openfgav1.CheckRequestTupleKey, contextualTuples ...*openfgav1.TupleKey) (bool, error) {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:          storeID,
+			TupleKey:         tk,
+			ContextualTuples: &openfgav1.ContextualTupleKeys{TupleKeys: contextualTuples},
+		})
+		return resp.GetAllowed(), err
+	}
+
+	t.Run("with_default_user_type", func(t *testing.T) {
+		storeID := createStore(t, &storage.StoreSettings{DefaultUserType: "user"})
+
+		require.NoError(t, write(storeID, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "viewer", "anne"),
+			tuple.NewTupleKey("document:2", "viewer", "*"),
+			tuple.NewTupleKey("document:3", "viewer", "group:eng#member"),
+			tuple.NewTupleKey("group:eng", "member", "bob"),
+		}, nil))
+
+		_, err := ds.ReadUserTuple(ctx, storeID, tuple.NewTupleKey("document:1", "viewer", "user:anne"), storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+
+		for _, tc := range []struct {
+			tk      *openfgav1.CheckRequestTupleKey
+			allowed bool
+		}{
+			{tk: tuple.NewCheckRequestTupleKey("document:1", "viewer", "anne"), allowed: true},
+			{tk: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"), allowed: true},
+			{tk: tuple.NewCheckRequestTupleKey("document:1", "viewer", "bob"), allowed: false},
+			{tk: tuple.NewCheckRequestTupleKey("document:2", "viewer", "bob"), allowed: true},
+			{tk: tuple.NewCheckRequestTupleKey("document:3", "viewer", "bob"), allowed: true},
+		} {
+			allowed, err := check(storeID, tc.tk)
+			require.NoError(t, err)
+			require.Equal(t, tc.allowed, allowed, tc.tk)
+		}
+
+		allowed, err := check(storeID, tuple.NewCheckRequestTupleKey("document:4", "viewer", "carl"),
+			tuple.NewTupleKey("document:4", "viewer", "carl"))
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		require.NoError(t, write(storeID, nil, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:1", "viewer", "anne"))}))
+		allowed, err = check(storeID, tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"))
+		require.NoError(t, err)
+		require.False(t, allowed)
+	})
+
+	t.Run("ambiguous_users", func(t *testing.T) {
+		storeID := createStore(t, &storage.StoreSettings{DefaultUserType: "user"})
+
+		err := write(storeID, []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "eng#member")}, nil)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, "the user 'eng#member' has a relation but no type")
+
+		_, err = check(storeID, tuple.NewCheckRequestTupleKey("document:1", "viewer", "eng#member"))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+
+		_, err = check(storeID, tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			tuple.NewTupleKey("document:1", "viewer", "eng#member"))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("default_user_type_not_assignable", func(t *testing.T) {
+		storeID := createStore(t, &storage.StoreSettings{DefaultUserType: "group"})
+
+		// the model doesn't allow group:anne as a viewer
+		err := write(storeID, []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "anne")}, nil)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("undefined_default_user_type", func(t *testing.T) {
+		storeID := createStore(t, &storage.StoreSettings{DefaultUserType: "employee"})
+
+		err := write(storeID, []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "anne")}, nil)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, "default user type 'employee'")
+
+		_, err = check(storeID, tuple.NewCheckRequestTupleKey("document:1", "viewer", "anne"))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("without_default_user_type", func(t *testing.T) {
+		storeID := createStore(t, &storage.StoreSettings{})
+
+		err := write(storeID, []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "anne")}, nil)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+
+		_, err = check(storeID, tuple.NewCheckRequestTupleKey("document:1", "viewer", "anne"))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		storeID := createStore(t, &storage.StoreSettings{DefaultUserType: "user"})
+
+		s := MustNewServerWithOpts(WithDatastore(ds))
+		t.Cleanup(s.Close)
+
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "anne"),
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+}
+
+func TestServerWithUniqueStoreNames(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	t.Run("duplicate_name_conflicts", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(WithDatastore(ds), WithUniqueStoreNames(true))
+		t.Cleanup(s.Close)
+
+		_, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		_, err = s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.AlreadyExists, st.Code())
+		require.Contains(t, st.Message(), "openfga-test")
+
+		_, err = s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-other"})
+		require.NoError(t, err)
+	})
+
+	t.Run("duplicate_names_are_allowed_by_default", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		s := MustNewServerWithOpts(WithDatastore(ds))
+		t.Cleanup(s.Close)
+
+		first, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+
+		second, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+		require.NoError(t, err)
+		require.NotEqual(t, first.GetId(), second.GetId())
+	})
+
+	t.Run("datastore_without_support_is_rejected", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		_, err := NewServerWithOpts(WithDatastore(storagewrappers.NewContextWrapper(ds)), WithUniqueStoreNames(true))
+		require.ErrorContains(t, err, "unique store names")
+	})
+}
+
+func TestServerWriteWithIdempotencyKey(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	writeRequest := func(object string) *openfgav1.WriteRequest {
+		return &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey(object, "viewer", "user:jon")},
+			},
+		}
+	}
+
+	t.Run("retried_write_is_a_no_op_returning_the_prior_result", func(t *testing.T) {
+		keyCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(IdempotencyKeyHeader, "retried"))
+
+		first, err := s.Write(keyCtx, writeRequest("document:1"))
+		require.NoError(t, err)
+
+		// without the key, the retry would fail because the tuple already exists
+		retry, err := s.Write(keyCtx, writeRequest("document:1"))
+		require.NoError(t, err)
+		require.Equal(t, first, retry)
+
+		changesResp, err := s.ReadChanges(ctx, &openfgav1.ReadChangesRequest{StoreId: storeID, Type: "document"})
+		require.NoError(t, err)
+		require.Len(t, changesResp.GetChanges(), 1)
+	})
+
+	t.Run("key_reused_by_a_different_write_is_rejected", func(t *testing.T) {
+		keyCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(IdempotencyKeyHeader, "reused"))
+
+		_, err := s.Write(keyCtx, writeRequest("document:2"))
+		require.NoError(t, err)
+
+		_, err = s.Write(keyCtx, writeRequest("document:3"))
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), st.Code())
+
+		readResp, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: storeID, TupleKey: &openfgav1.ReadRequestTupleKey{Object: "document:3"}})
+		require.NoError(t, err)
+		require.Empty(t, readResp.GetTuples())
+	})
+
+	t.Run("datastore_without_support_is_rejected", func(t *testing.T) {
+		s := MustNewServerWithOpts(WithDatastore(storagewrappers.NewContextWrapper(ds)))
+		t.Cleanup(s.Close)
+
+		keyCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(IdempotencyKeyHeader, "unsupported"))
+		_, err := s.Write(keyCtx, writeRequest("document:4"))
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+}
+
+// consistencyRecordingDatastore records the consistency preference of the reads of user tuples.
+type consistencyRecordingDatastore struct {
+	storage.OpenFGADatastore
+	mu          sync.Mutex
+	preferences []openfgav1.ConsistencyPreference
+}
+
+func (d *consistencyRecordingDatastore) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	d.mu.Lock()
+	d.preferences = append(d.preferences, options.Consistency.Preference)
+	d.mu.Unlock()
+	return d.OpenFGADatastore.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+func (d *consistencyRecordingDatastore) reset() []openfgav1.ConsistencyPreference {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	preferences := d.preferences
+	d.preferences = nil
+	return preferences
+}
+
+func TestServerWithReadPolicy(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	ds := &consistencyRecordingDatastore{OpenFGADatastore: memory.New()}
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(
+		WithDatastore(ds),
+		WithCheckQueryCacheEnabled(true),
+		WithExperimentals(ExperimentalEnableConsistencyParams),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:anne")},
+		},
+	})
+	require.NoError(t, err)
+
+	check := func(policy ReadPolicy) (bool, error) {
+		checkCtx := ctx
+		if policy != "" {
+			checkCtx = metadata.NewIncomingContext(ctx, metadata.Pairs(ReadPolicyHeader, string(policy)))
+		}
+		resp, err := s.Check(checkCtx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		})
+		return resp.GetAllowed(), err
+	}
+
+	// the result is in the Check cache
+	allowed, err := check("")
+	require.NoError(t, err)
+	require.True(t, allowed)
+	require.Equal(t, []openfgav1.ConsistencyPreference{openfgav1.ConsistencyPreference_UNSPECIFIED}, ds.reset())
+
+	tests := []struct {
+		name   string
+		policy ReadPolicy
+		// expectedReads are the consistency preferences of the reads, none if the result is served from the cache
+		expectedReads []openfgav1.ConsistencyPreference
+	}{
+		{
+			name: "no_policy",
+		},
+		{
+			name:   "cached_eventually_consistent",
+			policy: ReadPolicyCachedEventuallyConsistent,
+		},
+		{
+			name:          "fresh_from_replica",
+			policy:        ReadPolicyFreshFromReplica,
+			expectedReads: []openfgav1.ConsistencyPreference{openfgav1.ConsistencyPreference_MINIMIZE_LATENCY},
+		},
+		{
+			name:          "fresh_from_primary",
+			policy:        ReadPolicyFreshFromPrimary,
+			expectedReads: []openfgav1.ConsistencyPreference{openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allowed, err := check(test.policy)
+			require.NoError(t, err)
+			require.True(t, allowed)
+			require.Equal(t, test.expectedReads, ds.reset())
+		})
+	}
+
+	t.Run("unknown_policy", func(t *testing.T) {
+		_, err := check("fresh")
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("combined_with_a_consistency_preference", func(t *testing.T) {
+		s := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithExperimentals(ExperimentalEnableConsistencyParams),
+		)
+		t.Cleanup(s.Close)
+
+		_, err := s.Check(
+			metadata.NewIncomingContext(ctx, metadata.Pairs(ReadPolicyHeader, string(ReadPolicyFreshFromPrimary))),
+			&openfgav1.CheckRequest{
+				StoreId:     storeID,
+				TupleKey:    tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+				Consistency: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY,
+			},
+		)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("consistency_params_disabled", func(t *testing.T) {
+		s := MustNewServerWithOpts(WithDatastore(ds))
+		t.Cleanup(s.Close)
+
+		for _, policy := range []ReadPolicy{ReadPolicyCachedEventuallyConsistent, ReadPolicyFreshFromReplica, ReadPolicyFreshFromPrimary} {
+			_, err := s.Check(
+				metadata.NewIncomingContext(ctx, metadata.Pairs(ReadPolicyHeader, string(policy))),
+				&openfgav1.CheckRequest{
+					StoreId:  storeID,
+					TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+				},
+			)
+			require.Equal(t, codes.InvalidArgument, status.Code(err), policy)
+		}
+		require.Empty(t, ds.reset())
+	})
+}
+
+func TestServerCheckDatastoreQueryShapes(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	originalTracer := tracer
+	tracer = tracerProvider.Tracer("test")
+	t.Cleanup(func() {
+		tracer = originalTracer
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define viewer: [user]
+		type document
+			relations
+				define parent: [folder]
+				define viewer: viewer from parent`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:1", "parent", "folder:1"),
+				tuple.NewTupleKey("document:1", "parent", "folder:2"),
+				tuple.NewTupleKey("document:1", "parent", "folder:3"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+	})
+	require.NoError(t, err)
+	require.False(t, resp.GetAllowed())
+
+	var events []sdktrace.Event
+	for _, span := range spanRecorder.Ended() {
+		if span.Name() == "Check" {
+			events = span.Events()
+		}
+	}
+	require.Len(t, events, 1)
+	require.Equal(t, "datastore_query_shapes", events[0].Name)
+	counts := map[string]int64{}
+	for _, attr := range events[0].Attributes {
+		counts[string(attr.Key)] = attr.Value.AsInt64()
+	}
+	// the parents are read once, and then the viewers of every parent are read one by one
+	require.Equal(t, map[string]int64{
+		"Read(document:?#parent@)":              1,
+		"ReadUserTuple(folder:?#viewer@user:?)": 3,
+	}, counts)
+}
+
+func TestServerWatchHTTPHandler(t *testing.T) {
+	ctx := context.Background()
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(
+		WithDatastore(ds),
+		WithWatchPollInterval(5*time.Millisecond),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+	}))
+
+	authenticator, err := presharedkey.NewPresharedKeyAuthenticator([]string{"key"})
+	require.NoError(t, err)
+	handler := s.WatchHTTPHandler(authenticator)
+
+	// watch requests the changes of the store, and returns the response and its events as 'name data' lines
+	watch := func(t *testing.T, storeID, key, query string) (*http.Response, <-chan string) {
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r, map[string]string{"store_id": storeID})
+		}))
+		t.Cleanup(httpServer.Close)
+
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"?"+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := httpServer.Client().Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = resp.Body.Close()
+		})
+
+		events := make(chan string, 100)
+		go func() {
+			defer close(events)
+			scanner := bufio.NewScanner(resp.Body)
+			name := ""
+			for scanner.Scan() {
+				line := scanner.Text()
+				if event, ok := strings.CutPrefix(line, "event: "); ok {
+					name = event
+				}
+				if data, ok := strings.CutPrefix(line, "data: "); ok {
+					events <- name + " " + data
+				}
+			}
+		}()
+		return resp, events
+	}
+
+	nextEvent := func(t *testing.T, events <-chan string) string {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no event was streamed")
+			return ""
+		}
+	}
+
+	t.Run("streams_the_changes_as_server_sent_events", func(t *testing.T) {
+		resp, events := watch(t, storeID, "key", "type=document&relation=viewer&heartbeat_interval=1ms")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		event := nextEvent(t, events)
+		require.True(t, strings.HasPrefix(event, "changes "))
+		require.Contains(t, event, "user:anne")
+
+		require.True(t, strings.HasPrefix(nextEvent(t, events), "heartbeat "))
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		resp, _ := watch(t, storeID, "other", "")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid_heartbeat_interval", func(t *testing.T) {
+		resp, _ := watch(t, storeID, "key", "heartbeat_interval=soon")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("store_not_found", func(t *testing.T) {
+		resp, _ := watch(t, ulid.Make().String(), "key", "")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid_store_id", func(t *testing.T) {
+		resp, _ := watch(t, "store", "key", "")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithWatchEnabled(false),
+		)
+		t.Cleanup(s.Close)
+
+		err := s.Watch(ctx, &commands.WatchRequest{StoreID: storeID}, func(*commands.WatchEvent) error { return nil })
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("streams_beyond_the_limit_are_rejected", func(t *testing.T) {
+		s := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithWatchPollInterval(5*time.Millisecond),
+			WithWatchMaxConcurrentStreams(1),
+		)
+		t.Cleanup(s.Close)
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		streaming := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- s.Watch(watchCtx, &commands.WatchRequest{StoreID: storeID}, func(*commands.WatchEvent) error {
+				select {
+				case streaming <- struct{}{}:
+				default:
+				}
+				return nil
+			})
+		}()
+		<-streaming
+
+		err := s.Watch(ctx, &commands.WatchRequest{StoreID: storeID}, func(*commands.WatchEvent) error { return nil })
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		cancel()
+		<-done
+
+		// the slot of the stream is released once it ended
+		err = s.Watch(ctx, &commands.WatchRequest{StoreID: storeID}, func(*commands.WatchEvent) error {
+			return context.Canceled
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestAccessMatrix(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]
+		type folder
+			relations
+				define viewer: [user, group#member]
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define owner: [user]
+				define editor: [user, group#member] or owner
+				define viewer: ([user] or editor or viewer from parent) but not blocked`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("group:all", "member", "group:eng#member"),
+		tuple.NewTupleKey("group:all", "member", "user:carl"),
+		tuple.NewTupleKey("folder:shared", "viewer", "group:all#member"),
+		tuple.NewTupleKey("document:1", "parent", "folder:shared"),
+		tuple.NewTupleKey("document:1", "owner", "user:bob"),
+		tuple.NewTupleKey("document:1", "blocked", "user:carl"),
+		tuple.NewTupleKey("document:2", "editor", "group:eng#member"),
+		tuple.NewTupleKey("document:3", "viewer", "user:bob"),
+	}))
+
+	typesys := typesystem.New(model)
+	ctx = typesystem.ContextWithTypesystem(ctx, typesys)
+
+	req := &commands.AccessMatrixRequest{
+		StoreID:   storeID,
+		Users:     []string{"user:anne", "user:bob", "user:carl", "group:eng#member"},
+		Objects:   []string{"document:1", "document:2", "document:3"},
+		Relations: []string{"viewer", "editor", "owner"},
+	}
+
+	t.Run("matches_individual_checks", func(t *testing.T) {
+		var rows []*commands.AccessMatrixRow
+		err := commands.NewAccessMatrixCommand(ds).Execute(ctx, req, func(row *commands.AccessMatrixRow) error {
+			rows = append(rows, row)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, rows, len(req.Users)*len(req.Objects))
+
+		checker := graph.NewLocalChecker()
+		defer checker.Close()
+		checkCtx := storage.ContextWithRelationshipTupleReader(ctx, ds)
+
+		allowedCount := 0
+		i := 0
+		for _, user := range req.Users {
+			for _, object := range req.Objects {
+				row := rows[i]
+				i++
+				require.Equal(t, user, row.User)
+				require.Equal(t, object, row.Object)
+
+				var expected []string
+				for _, relation := range req.Relations {
+					resp, err := checker.ResolveCheck(checkCtx, &graph.ResolveCheckRequest{
+						StoreID:              storeID,
+						AuthorizationModelID: model.GetId(),
+						TupleKey:             tuple.NewTupleKey(object, relation, user),
+						RequestMetadata:      graph.NewCheckRequestMetadata(25),
+					})
+					require.NoError(t, err)
+					if resp.GetAllowed() {
+						expected = append(expected, relation)
+					}
+				}
+				require.ElementsMatch(t, expected, row.Relations, "%s on %s", user, object)
+				allowedCount += len(expected)
+			}
+		}
+
+		// both outcomes are covered by the grid
+		require.Positive(t, allowedCount)
+		require.Less(t, allowedCount, len(req.Users)*len(req.Objects)*len(req.Relations))
+	})
+
+	t.Run("rows_are_in_request_order", func(t *testing.T) {
+		var rows []*commands.AccessMatrixRow
+		err := commands.NewAccessMatrixCommand(ds).Execute(ctx, &commands.AccessMatrixRequest{
+			StoreID:   storeID,
+			Users:     []string{"user:bob"},
+			Objects:   []string{"document:1"},
+			Relations: []string{"owner", "viewer", "editor"},
+		}, func(row *commands.AccessMatrixRow) error {
+			rows = append(rows, row)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		require.Equal(t, []string{"owner", "viewer", "editor"}, rows[0].Relations)
+	})
+
+	t.Run("emit_error_stops_the_matrix", func(t *testing.T) {
+		errStop := errors.New("stop")
+		emitted := 0
+		err := commands.NewAccessMatrixCommand(ds).Execute(ctx, req, func(*commands.AccessMatrixRow) error {
+			emitted++
+			return errStop
+		})
+		require.ErrorIs(t, err, errStop)
+		require.Equal(t, 1, emitted)
+	})
+
+	t.Run("max_cells", func(t *testing.T) {
+		emitted := 0
+		err := commands.NewAccessMatrixCommand(ds, commands.WithAccessMatrixMaxCells(35)).Execute(ctx, req, func(*commands.AccessMatrixRow) error {
+			emitted++
+			return nil
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.Zero(t, emitted)
+
+		err = commands.NewAccessMatrixCommand(ds, commands.WithAccessMatrixMaxCells(36)).Execute(ctx, req, func(*commands.AccessMatrixRow) error {
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid_cell_emits_no_rows", func(t *testing.T) {
+		emitted := 0
+		err := commands.NewAccessMatrixCommand(ds).Execute(ctx, &commands.AccessMatrixRequest{
+			StoreID:   storeID,
+			Users:     []string{"user:anne"},
+			Objects:   []string{"document:1", "folder:shared"},
+			Relations: []string{"viewer", "owner"},
+		}, func(*commands.AccessMatrixRow) error {
+			emitted++
+			return nil
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.Zero(t, emitted)
+	})
+}
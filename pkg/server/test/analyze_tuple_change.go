@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// TestAnalyzeTupleChange doesn't use the datastore, because the snapshots are always loaded in memory.
+func TestAnalyzeTupleChange(t *testing.T, _ storage.OpenFGADatastore) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define blocked: [user]
+				define editor: [user, group#member]
+				define viewer: editor but not blocked`)
+
+	ctx := typesystem.ContextWithTypesystem(context.Background(), typesystem.New(model))
+
+	before := []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "editor", "group:eng#member"),
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("group:eng", "member", "user:bob"),
+		tuple.NewTupleKey("document:2", "editor", "user:anne"),
+	}
+
+	probes := []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:1", "viewer", "user:bob"),
+		tuple.NewTupleKey("document:2", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:2", "viewer", "user:bob"),
+	}
+
+	tests := map[string]struct {
+		after    []*openfgav1.TupleKey
+		changes  []*openfgav1.TupleChange
+		expected []*commands.AnalyzeTupleChangeResult
+	}{
+		"no_changes": {
+			after: before,
+		},
+		"deleted_tuple_revokes_access": {
+			after: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:1", "editor", "group:eng#member"),
+				tuple.NewTupleKey("group:eng", "member", "user:bob"),
+				tuple.NewTupleKey("document:2", "editor", "user:anne"),
+			},
+			expected: []*commands.AnalyzeTupleChangeResult{
+				{Probe: probes[0], AllowedBefore: true, AllowedAfter: false},
+			},
+		},
+		"changes_grant_and_revoke_access": {
+			changes: []*openfgav1.TupleChange{
+				{
+					TupleKey:  tuple.NewTupleKey("document:1", "blocked", "user:bob"),
+					Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+				},
+				{
+					TupleKey:  tuple.NewTupleKey("document:2", "editor", "user:bob"),
+					Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+				},
+				{
+					TupleKey:  tuple.NewTupleKey("document:2", "editor", "user:anne"),
+					Operation: openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
+				},
+			},
+			expected: []*commands.AnalyzeTupleChangeResult{
+				{Probe: probes[1], AllowedBefore: true, AllowedAfter: false},
+				{Probe: probes[2], AllowedBefore: true, AllowedAfter: false},
+				{Probe: probes[3], AllowedBefore: false, AllowedAfter: true},
+			},
+		},
+		"tuple_deleted_and_written_again": {
+			changes: []*openfgav1.TupleChange{
+				{
+					TupleKey:  tuple.NewTupleKey("group:eng", "member", "user:anne"),
+					Operation: openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
+				},
+				{
+					TupleKey:  tuple.NewTupleKey("group:eng", "member", "user:anne"),
+					Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+				},
+			},
+		},
+	}
+
+	cmd := commands.NewAnalyzeTupleChangeCommand()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := cmd.Execute(ctx, &commands.AnalyzeTupleChangeRequest{
+				Before:  before,
+				After:   test.after,
+				Changes: test.changes,
+				Probes:  probes,
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, resp.Changed)
+		})
+	}
+
+	t.Run("after_and_changes_are_exclusive", func(t *testing.T) {
+		_, err := cmd.Execute(ctx, &commands.AnalyzeTupleChangeRequest{
+			Before: before,
+			After:  before,
+			Changes: []*openfgav1.TupleChange{{
+				TupleKey:  tuple.NewTupleKey("document:1", "blocked", "user:bob"),
+				Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+			}},
+			Probes: probes,
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("invalid_tuple", func(t *testing.T) {
+		_, err := cmd.Execute(ctx, &commands.AnalyzeTupleChangeRequest{
+			Before: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:anne")},
+			Probes: probes,
+		})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,180 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestBatchCheck(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user, group#member]`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+	}))
+
+	typesys := typesystem.New(model)
+	ctx = typesystem.ContextWithTypesystem(ctx, typesys)
+
+	checker := graph.NewLocalChecker()
+	t.Cleanup(checker.Close)
+
+	// the even documents are viewed by the group of user:anne, through a contextual tuple
+	const numChecks = 123
+	var checks []*commands.BatchCheckItem
+	for i := 0; i < numChecks; i++ {
+		check := &commands.BatchCheckItem{
+			TupleKey:      tuple.NewCheckRequestTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:anne"),
+			CorrelationID: fmt.Sprintf("check-%d", i),
+		}
+		if i%2 == 0 {
+			check.ContextualTuples = []*openfgav1.TupleKey{
+				tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "group:eng#member"),
+			}
+		}
+		checks = append(checks, check)
+	}
+	req := &commands.BatchCheckRequest{StoreID: storeID, Checks: checks}
+
+	t.Run("every_correlation_id_is_returned_once", func(t *testing.T) {
+		results := map[string]*commands.BatchCheckResult{}
+		err := commands.NewBatchCheckCommand(ds, checker,
+			commands.WithBatchCheckChunkSize(10),
+			commands.WithBatchCheckMaxConcurrentChunks(3),
+		).Execute(ctx, req, func(result *commands.BatchCheckResult) error {
+			require.NotContains(t, results, result.CorrelationID)
+			results[result.CorrelationID] = result
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, results, numChecks)
+
+		for i := 0; i < numChecks; i++ {
+			result := results[fmt.Sprintf("check-%d", i)]
+			require.NotNil(t, result)
+			require.NoError(t, result.Err)
+			require.Equal(t, i%2 == 0, result.Allowed, "document:%d", i)
+		}
+	})
+
+	t.Run("emit_is_not_called_concurrently", func(t *testing.T) {
+		var mu sync.Mutex
+		emitted := 0
+		err := commands.NewBatchCheckCommand(ds, checker,
+			commands.WithBatchCheckChunkSize(1),
+			commands.WithBatchCheckMaxConcurrentChunks(20),
+		).Execute(ctx, req, func(*commands.BatchCheckResult) error {
+			require.True(t, mu.TryLock())
+			defer mu.Unlock()
+			emitted++
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, numChecks, emitted)
+	})
+
+	t.Run("failed_check_does_not_fail_the_batch", func(t *testing.T) {
+		var results []*commands.BatchCheckResult
+		err := commands.NewBatchCheckCommand(ds, checker).Execute(ctx, &commands.BatchCheckRequest{
+			StoreID: storeID,
+			Checks: []*commands.BatchCheckItem{
+				{TupleKey: tuple.NewCheckRequestTupleKey("document:1", "owner", "user:anne"), CorrelationID: "invalid"},
+				{TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"), CorrelationID: "valid"},
+			},
+		}, func(result *commands.BatchCheckResult) error {
+			results = append(results, result)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, "invalid", results[0].CorrelationID)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(results[0].Err))
+		require.Equal(t, "valid", results[1].CorrelationID)
+		require.NoError(t, results[1].Err)
+	})
+
+	t.Run("emit_error_stops_the_batch", func(t *testing.T) {
+		errStop := errors.New("stop")
+		emitted := 0
+		err := commands.NewBatchCheckCommand(ds, checker,
+			commands.WithBatchCheckChunkSize(10),
+			commands.WithBatchCheckMaxConcurrentChunks(1),
+		).Execute(ctx, req, func(*commands.BatchCheckResult) error {
+			emitted++
+			return errStop
+		})
+		require.ErrorIs(t, err, errStop)
+		require.Equal(t, 1, emitted)
+	})
+
+	t.Run("max_checks", func(t *testing.T) {
+		emitted := 0
+		err := commands.NewBatchCheckCommand(ds, checker, commands.WithBatchCheckMaxChecks(numChecks-1)).Execute(ctx, req, func(*commands.BatchCheckResult) error {
+			emitted++
+			return nil
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.Zero(t, emitted)
+
+		err = commands.NewBatchCheckCommand(ds, checker, commands.WithBatchCheckMaxChecks(numChecks)).Execute(ctx, req, func(*commands.BatchCheckResult) error {
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid_correlation_ids", func(t *testing.T) {
+		for name, correlationIDs := range map[string][]string{
+			"empty":    {"a", ""},
+			"repeated": {"a", "b", "a"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				var checks []*commands.BatchCheckItem
+				for _, correlationID := range correlationIDs {
+					checks = append(checks, &commands.BatchCheckItem{
+						TupleKey:      tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+						CorrelationID: correlationID,
+					})
+				}
+
+				emitted := 0
+				err := commands.NewBatchCheckCommand(ds, checker).Execute(ctx, &commands.BatchCheckRequest{
+					StoreID: storeID,
+					Checks:  checks,
+				}, func(*commands.BatchCheckResult) error {
+					emitted++
+					return nil
+				})
+				require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+				require.Zero(t, emitted)
+			})
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestDeleteMatchingTuples(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	writeTuples := func(t *testing.T, storeID string, tks ...*openfgav1.TupleKey) {
+		t.Helper()
+		require.NoError(t, datastore.Write(ctx, storeID, nil, tks))
+	}
+
+	readAll := func(t *testing.T, storeID string) []*openfgav1.Tuple {
+		t.Helper()
+		tuples, _, err := datastore.ReadPage(ctx, storeID, nil, storage.ReadPageOptions{
+			Pagination: storage.NewPaginationOptions(100, ""),
+		})
+		require.NoError(t, err)
+		return tuples
+	}
+
+	cmd := commands.NewDeleteMatchingTuplesCommand(datastore)
+
+	t.Run("deletes_previewed_tuples_of_object", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		writeTuples(t, storeID,
+			tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			tuple.NewTupleKey("document:1", "editor", "user:maria"),
+			tuple.NewTupleKey("document:2", "viewer", "user:jon"),
+		)
+
+		req := &commands.DeleteMatchingTuplesRequest{StoreID: storeID, Object: "document:1"}
+		preview, err := cmd.Preview(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, preview.TupleKeys, 2)
+
+		require.NoError(t, cmd.Execute(ctx, req, preview.ConfirmationToken))
+
+		remaining := readAll(t, storeID)
+		require.Len(t, remaining, 1)
+		require.Equal(t, "document:2", remaining[0].GetKey().GetObject())
+	})
+
+	t.Run("deletes_previewed_tuples_of_user", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		writeTuples(t, storeID,
+			tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			tuple.NewTupleKey("folder:1", "viewer", "user:jon"),
+			tuple.NewTupleKey("document:1", "viewer", "user:maria"),
+		)
+
+		req := &commands.DeleteMatchingTuplesRequest{StoreID: storeID, User: "user:jon"}
+		preview, err := cmd.Preview(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, preview.TupleKeys, 2)
+
+		require.NoError(t, cmd.Execute(ctx, req, preview.ConfirmationToken))
+
+		remaining := readAll(t, storeID)
+		require.Len(t, remaining, 1)
+		require.Equal(t, "user:maria", remaining[0].GetKey().GetUser())
+	})
+
+	t.Run("rejects_delete_if_tuples_were_added_after_preview", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		writeTuples(t, storeID, tuple.NewTupleKey("document:1", "viewer", "user:jon"))
+
+		req := &commands.DeleteMatchingTuplesRequest{StoreID: storeID, Object: "document:1"}
+		preview, err := cmd.Preview(ctx, req)
+		require.NoError(t, err)
+
+		writeTuples(t, storeID, tuple.NewTupleKey("document:1", "viewer", "user:maria"))
+
+		err = cmd.Execute(ctx, req, preview.ConfirmationToken)
+		require.ErrorIs(t, err, serverErrors.DeleteConfirmationMismatch)
+		require.Len(t, readAll(t, storeID), 2)
+	})
+
+	t.Run("rejects_delete_if_tuples_were_removed_after_preview", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		writeTuples(t, storeID,
+			tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			tuple.NewTupleKey("document:1", "viewer", "user:maria"),
+		)
+
+		req := &commands.DeleteMatchingTuplesRequest{StoreID: storeID, Object: "document:1"}
+		preview, err := cmd.Preview(ctx, req)
+		require.NoError(t, err)
+
+		err = datastore.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{
+			tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:1", "viewer", "user:maria")),
+		}, nil)
+		require.NoError(t, err)
+
+		err = cmd.Execute(ctx, req, preview.ConfirmationToken)
+		require.ErrorIs(t, err, serverErrors.DeleteConfirmationMismatch)
+		require.Len(t, readAll(t, storeID), 1)
+	})
+
+	t.Run("rejects_token_of_another_request", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		writeTuples(t, storeID, tuple.NewTupleKey("document:1", "viewer", "user:jon"))
+
+		preview, err := cmd.Preview(ctx, &commands.DeleteMatchingTuplesRequest{StoreID: storeID, User: "user:jon"})
+		require.NoError(t, err)
+
+		err = cmd.Execute(ctx, &commands.DeleteMatchingTuplesRequest{StoreID: storeID, Object: "document:1"}, preview.ConfirmationToken)
+		require.ErrorIs(t, err, serverErrors.DeleteConfirmationMismatch)
+	})
+
+	t.Run("requires_object_or_user", func(t *testing.T) {
+		_, err := cmd.Preview(ctx, &commands.DeleteMatchingTuplesRequest{StoreID: ulid.Make().String()})
+		require.Error(t, err)
+	})
+}
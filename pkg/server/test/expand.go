@@ -973,3 +973,176 @@ func TestExpandQueryErrors(t *testing.T, datastore storage.OpenFGADatastore) {
 		})
 	}
 }
+
+func TestExpandQueryWithMaxDirectUsers(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type repo
+			relations
+				define admin: [user]`)
+	require.NoError(t, datastore.WriteAuthorizationModel(ctx, store, model))
+
+	var tuples []*openfgav1.TupleKey
+	var allUsers []string
+	for i := 0; i < 5; i++ {
+		user := fmt.Sprintf("user:%d", i)
+		allUsers = append(allUsers, user)
+		tuples = append(tuples, tuple.NewTupleKey("repo:openfga/foo", "admin", user))
+	}
+	require.NoError(t, datastore.Write(ctx, store, nil, tuples))
+
+	tests := map[string]struct {
+		maxDirectUsers uint32
+		expectedUsers  int
+		truncated      string
+	}{
+		"member_count_exceeding_limit": {
+			maxDirectUsers: 3,
+			expectedUsers:  3,
+			truncated:      "+2 more, truncated",
+		},
+		"member_count_equal_to_limit": {
+			maxDirectUsers: 5,
+			expectedUsers:  5,
+		},
+		"no_limit": {
+			maxDirectUsers: 0,
+			expectedUsers:  5,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			query := commands.NewExpandQuery(datastore, commands.WithExpandQueryMaxDirectUsers(test.maxDirectUsers))
+			got, err := query.Execute(ctx, &openfgav1.ExpandRequest{
+				StoreId:              store,
+				AuthorizationModelId: model.GetId(),
+				TupleKey:             tuple.NewExpandRequestTupleKey("repo:openfga/foo", "admin"),
+			})
+			require.NoError(t, err)
+
+			users := got.GetTree().GetRoot().GetLeaf().GetUsers().GetUsers()
+			if test.truncated != "" {
+				require.Len(t, users, test.expectedUsers+1)
+				require.Equal(t, test.truncated, users[len(users)-1])
+				users = users[:len(users)-1]
+			}
+			require.Len(t, users, test.expectedUsers)
+			require.Subset(t, allUsers, users)
+		})
+	}
+}
+
+func TestExpandQueryStreamed(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define viewer: [user]
+		type document
+			relations
+				define parent: [folder]
+				define owner: [user]
+				define blocked: [user]
+				define allowed: [user]
+				define viewer: (([user] or owner or viewer from parent) and allowed) but not blocked`)
+	require.NoError(t, datastore.WriteAuthorizationModel(ctx, store, model))
+	require.NoError(t, datastore.Write(ctx, store, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:1", "parent", "folder:x"),
+	}))
+
+	req := &openfgav1.ExpandRequest{
+		StoreId:              store,
+		AuthorizationModelId: model.GetId(),
+		TupleKey:             tuple.NewExpandRequestTupleKey("document:1", "viewer"),
+	}
+	query := commands.NewExpandQuery(datastore)
+
+	t.Run("nodes_are_emitted_before_their_children", func(t *testing.T) {
+		var streamed []*commands.ExpandStreamedNode
+		err := query.ExecuteStreamed(ctx, req, func(node *commands.ExpandStreamedNode) error {
+			streamed = append(streamed, node)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, streamed, 8)
+
+		// every node but the root is a child of a node emitted before it
+		nodes := map[string]*openfgav1.UsersetTree_Node{}
+		var root *openfgav1.UsersetTree_Node
+		for _, s := range streamed {
+			if len(s.Path) == 0 {
+				require.Nil(t, root)
+				root = s.Node
+			} else {
+				parent, ok := nodes[fmt.Sprint(s.Path[:len(s.Path)-1])]
+				require.True(t, ok, "node %v was emitted before its parent", s.Path)
+
+				index := s.Path[len(s.Path)-1]
+				switch value := parent.GetValue().(type) {
+				case *openfgav1.UsersetTree_Node_Union:
+					require.Len(t, value.Union.GetNodes(), index)
+					value.Union.Nodes = append(value.Union.Nodes, s.Node)
+				case *openfgav1.UsersetTree_Node_Intersection:
+					require.Len(t, value.Intersection.GetNodes(), index)
+					value.Intersection.Nodes = append(value.Intersection.Nodes, s.Node)
+				case *openfgav1.UsersetTree_Node_Difference:
+					if index == 0 {
+						value.Difference.Base = s.Node
+					} else {
+						value.Difference.Subtract = s.Node
+					}
+				default:
+					require.Fail(t, "a leaf node has children")
+				}
+			}
+			nodes[fmt.Sprint(s.Path)] = s.Node
+		}
+
+		// the reassembled tree is the tree returned by Execute
+		expected, err := query.Execute(ctx, req)
+		require.NoError(t, err)
+		if diff := cmp.Diff(expected.GetTree().GetRoot(), root, protocmp.Transform()); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("cancellation_stops_the_expansion", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		emitted := 0
+		err := query.ExecuteStreamed(ctx, req, func(node *commands.ExpandStreamedNode) error {
+			emitted++
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 1, emitted)
+	})
+
+	t.Run("emit_error_stops_the_expansion", func(t *testing.T) {
+		errStop := fmt.Errorf("stop")
+		emitted := 0
+		err := query.ExecuteStreamed(ctx, req, func(node *commands.ExpandStreamedNode) error {
+			emitted++
+			if emitted == 2 {
+				return errStop
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, errStop)
+		require.Equal(t, 2, emitted)
+	})
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestHypotheticalCheck(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type folder
+			relations
+				define viewer: [user]
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define owner: [user]
+				define editor: [user, group#member] or owner
+				define viewer: [user] or editor or viewer from parent
+				define can_view: viewer but not blocked`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "editor", "group:eng#member"),
+		tuple.NewTupleKey("document:1", "parent", "folder:x"),
+		tuple.NewTupleKey("document:1", "owner", "user:bob"),
+	}))
+
+	ctx = typesystem.ContextWithTypesystem(ctx, typesystem.New(model))
+
+	tests := map[string]struct {
+		tupleKey    *openfgav1.TupleKey
+		assumptions []*openfgav1.TupleKey
+		allowed     bool
+	}{
+		"no_assumptions": {
+			tupleKey: tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+		},
+		"assumed_group_membership": {
+			tupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+			assumptions: []*openfgav1.TupleKey{tuple.NewTupleKey("group:eng", "member", "user:anne")},
+			allowed:     true,
+		},
+		"assumed_computed_relation": {
+			tupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+			assumptions: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "editor", "user:anne")},
+			allowed:     true,
+		},
+		"assumed_relation_on_parent": {
+			tupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+			assumptions: []*openfgav1.TupleKey{tuple.NewTupleKey("folder:x", "viewer", "user:anne")},
+			allowed:     true,
+		},
+		"assumed_wildcard_not_allowed_by_the_model": {
+			tupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+			assumptions: []*openfgav1.TupleKey{tuple.NewTupleKey("folder:x", "viewer", "user:*")},
+			allowed:     true,
+		},
+		"assumed_checked_relation": {
+			tupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+			assumptions: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "can_view", "user:anne")},
+			allowed:     true,
+		},
+		"assumed_exclusion": {
+			tupleKey: tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+			assumptions: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("group:eng", "member", "user:anne"),
+				tuple.NewTupleKey("document:1", "blocked", "user:anne"),
+			},
+		},
+		"unrelated_assumption": {
+			tupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+			assumptions: []*openfgav1.TupleKey{tuple.NewTupleKey("group:other", "member", "user:anne")},
+		},
+		"allowed_without_assumptions": {
+			tupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:bob"),
+			assumptions: []*openfgav1.TupleKey{tuple.NewTupleKey("group:other", "member", "user:bob")},
+			allowed:     true,
+		},
+	}
+
+	query := commands.NewHypotheticalCheckQuery(ds)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := query.Execute(ctx, &commands.HypotheticalCheckRequest{
+				StoreID:     storeID,
+				TupleKey:    test.tupleKey,
+				Assumptions: test.assumptions,
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, resp.Allowed)
+		})
+	}
+
+	invalidAssumptions := map[string]*openfgav1.TupleKey{
+		"undefined_relation":  tuple.NewTupleKey("document:1", "undefined", "user:anne"),
+		"undefined_user_type": tuple.NewTupleKey("document:1", "viewer", "undefined:anne"),
+		"condition":           tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", "condition", nil),
+	}
+
+	for name, assumption := range invalidAssumptions {
+		t.Run(name, func(t *testing.T) {
+			_, err := query.Execute(ctx, &commands.HypotheticalCheckRequest{
+				StoreID:     storeID,
+				TupleKey:    tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+				Assumptions: []*openfgav1.TupleKey{assumption},
+			})
+			require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		})
+	}
+}
@@ -14,6 +14,8 @@ import (
 	parser "github.com/openfga/language/pkg/go/transformer"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/openfga/openfga/internal/mocks"
@@ -587,6 +589,162 @@ func TestListObjects(t *testing.T, ds storage.OpenFGADatastore) {
 	}
 }
 
+func TestListObjectsSinceTime(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define blocked: [user]
+				define viewer: [user, group#member] but not blocked`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:old", "viewer", "user:jon"),
+		tuple.NewTupleKey("document:new_but_blocked_before", "blocked", "user:jon"),
+		tuple.NewTupleKey("group:eng", "member", "user:jon"),
+		tuple.NewTupleKey("document:old_through_old_group", "viewer", "group:eng#member"),
+	}))
+
+	// some datastores store timestamps with a precision of seconds
+	time.Sleep(1 * time.Second)
+	since := time.Now()
+	time.Sleep(1 * time.Second)
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:new", "viewer", "user:jon"),
+		tuple.NewTupleKey("document:new_but_blocked_before", "viewer", "user:jon"),
+		tuple.NewTupleKey("document:new_through_old_group", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("group:new", "member", "user:jon"),
+	}))
+
+	ctx = typesystem.ContextWithTypesystem(ctx, typesystem.New(model))
+
+	checkResolver, closer := graph.NewOrderedCheckResolvers().Build()
+	t.Cleanup(closer)
+
+	listObjectsQuery, err := commands.NewListObjectsQuery(ds, checkResolver,
+		commands.WithListObjectsSinceTime(since),
+	)
+	require.NoError(t, err)
+
+	res, err := listObjectsQuery.Execute(ctx, &openfgav1.ListObjectsRequest{
+		StoreId:  storeID,
+		Type:     "document",
+		Relation: "viewer",
+		User:     "user:jon",
+		ContextualTuples: &openfgav1.ContextualTupleKeys{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:contextual", "viewer", "user:jon")},
+		},
+	})
+	require.NoError(t, err)
+	// only the time the documents were shared counts: document:new_through_old_group was shared after the
+	// since time with a group joined before it, and the group joined after the since time doesn't grant
+	// access to document:old_through_old_group. The old blocked tuple still excludes
+	// document:new_but_blocked_before.
+	require.ElementsMatch(t, []string{"document:new", "document:new_through_old_group", "document:contextual"}, res.Objects)
+
+	listObjectsQuery, err = commands.NewListObjectsQuery(ds, checkResolver)
+	require.NoError(t, err)
+
+	res, err = listObjectsQuery.Execute(ctx, &openfgav1.ListObjectsRequest{
+		StoreId:  storeID,
+		Type:     "document",
+		Relation: "viewer",
+		User:     "user:jon",
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"document:old", "document:new", "document:new_through_old_group", "document:old_through_old_group"}, res.Objects)
+}
+
+func TestListObjectsRelationNotListable(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define allowed: [user]
+				define blocked: [user]
+				define member: [user]
+				define restricted_viewer: [user] and allowed
+				define unblocked_viewer: [user] but not blocked
+		type document
+			relations
+				define parent: [folder]
+				define viewer: member from parent
+				define inherited_viewer: restricted_viewer from parent`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("folder:1", "allowed", "user:anne"),
+		tuple.NewTupleKey("folder:1", "restricted_viewer", "user:anne"),
+		tuple.NewTupleKey("folder:2", "restricted_viewer", "user:anne"),
+	}))
+
+	ctx = typesystem.ContextWithTypesystem(ctx, typesystem.New(model))
+
+	checkResolver, closer := graph.NewOrderedCheckResolvers().Build()
+	t.Cleanup(closer)
+
+	listObjects := func(objectType, relation string, opts ...commands.ListObjectsQueryOption) ([]string, error) {
+		listObjectsQuery, err := commands.NewListObjectsQuery(ds, checkResolver, opts...)
+		require.NoError(t, err)
+
+		res, err := listObjectsQuery.Execute(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  storeID,
+			Type:     objectType,
+			Relation: relation,
+			User:     "user:anne",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return res.Objects, nil
+	}
+
+	t.Run("listable", func(t *testing.T) {
+		_, err := listObjects("folder", "member")
+		require.NoError(t, err)
+		_, err = listObjects("document", "viewer")
+		require.NoError(t, err)
+	})
+
+	for _, relation := range []struct {
+		objectType string
+		relation   string
+	}{
+		{"folder", "restricted_viewer"},
+		{"folder", "unblocked_viewer"},
+		{"document", "inherited_viewer"},
+	} {
+		t.Run("not_listable_"+relation.objectType+"_"+relation.relation, func(t *testing.T) {
+			_, err := listObjects(relation.objectType, relation.relation, commands.WithListObjectsCheckFallback(false))
+			require.Equal(t, codes.FailedPrecondition, status.Code(err))
+			require.ErrorContains(t, err, (&typesystem.RelationNotListableError{
+				ObjectType: relation.objectType,
+				Relation:   relation.relation,
+			}).Error())
+		})
+	}
+
+	t.Run("check_fallback_by_default", func(t *testing.T) {
+		objects, err := listObjects("folder", "restricted_viewer")
+		require.NoError(t, err)
+		require.Equal(t, []string{"folder:1"}, objects)
+	})
+}
+
 // Used to avoid compiler optimizations (see https://dave.cheney.net/2013/06/30/how-to-write-benchmarks-in-go)
 var listObjectsResponse *commands.ListObjectsResponse //nolint
 
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestMinimalGrantingSet(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]
+		type folder
+			relations
+				define viewer: [user, group#member]
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define owner: [user]
+				define editor: [user, group#member] or owner
+				define viewer: ([user, group#member] or editor or viewer from parent) but not blocked
+				define approver: editor and viewer from parent`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		// direct
+		tuple.NewTupleKey("document:direct", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:direct", "viewer", "user:bob"),
+		tuple.NewTupleKey("document:direct", "owner", "user:bob"),
+
+		// through nested groups
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("group:all", "member", "group:eng#member"),
+		tuple.NewTupleKey("group:all", "member", "user:bob"),
+		tuple.NewTupleKey("document:group", "viewer", "group:all#member"),
+
+		// through several paths at once, only one of them is needed
+		tuple.NewTupleKey("document:combined", "owner", "user:anne"),
+		tuple.NewTupleKey("document:combined", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:combined", "parent", "folder:x"),
+		tuple.NewTupleKey("folder:x", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("document:combined", "blocked", "user:bob"),
+
+		// through an intersection, every operand is needed
+		tuple.NewTupleKey("document:intersection", "editor", "user:anne"),
+		tuple.NewTupleKey("document:intersection", "parent", "folder:y"),
+		tuple.NewTupleKey("folder:y", "viewer", "user:anne"),
+		tuple.NewTupleKey("folder:y", "viewer", "user:bob"),
+	}))
+
+	ctx = typesystem.ContextWithTypesystem(ctx, typesystem.New(model))
+
+	tests := map[string]struct {
+		tupleKey         *openfgav1.TupleKey
+		contextualTuples []*openfgav1.TupleKey
+		// expected are the possible minimal granting sets, sorted. Which one is returned depends on the
+		// paths explored first while resolving the Check.
+		expected [][]string
+	}{
+		"direct": {
+			tupleKey: tuple.NewTupleKey("document:direct", "viewer", "user:anne"),
+			expected: [][]string{{"document:direct#viewer@user:anne"}},
+		},
+		"nested_groups": {
+			tupleKey: tuple.NewTupleKey("document:group", "viewer", "user:anne"),
+			expected: [][]string{{
+				"document:group#viewer@group:all#member",
+				"group:all#member@group:eng#member",
+				"group:eng#member@user:anne",
+			}},
+		},
+		"combined_paths": {
+			tupleKey: tuple.NewTupleKey("document:combined", "viewer", "user:anne"),
+			expected: [][]string{
+				{"document:combined#viewer@user:anne"},
+				{"document:combined#owner@user:anne"},
+				{
+					"document:combined#parent@folder:x",
+					"folder:x#viewer@group:eng#member",
+					"group:eng#member@user:anne",
+				},
+			},
+		},
+		"intersection": {
+			tupleKey: tuple.NewTupleKey("document:intersection", "approver", "user:anne"),
+			expected: [][]string{{
+				"document:intersection#editor@user:anne",
+				"document:intersection#parent@folder:y",
+				"folder:y#viewer@user:anne",
+			}},
+		},
+		"contextual_tuple": {
+			tupleKey: tuple.NewTupleKey("document:contextual", "viewer", "user:anne"),
+			contextualTuples: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:contextual", "parent", "folder:x"),
+			},
+			expected: [][]string{{
+				"document:contextual#parent@folder:x",
+				"folder:x#viewer@group:eng#member",
+				"group:eng#member@user:anne",
+			}},
+		},
+	}
+
+	cmd := commands.NewMinimalGrantingSetCommand(ds)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := cmd.Execute(ctx, &commands.MinimalGrantingSetRequest{
+				StoreID:          storeID,
+				TupleKey:         test.tupleKey,
+				ContextualTuples: test.contextualTuples,
+			})
+			require.NoError(t, err)
+
+			tuples := make([]string, 0, len(resp.Tuples))
+			for _, tk := range resp.Tuples {
+				tuples = append(tuples, tuple.TupleKeyToString(tk))
+			}
+			sort.Strings(tuples)
+			require.Contains(t, test.expected, tuples)
+		})
+	}
+
+	t.Run("not_allowed", func(t *testing.T) {
+		_, err := cmd.Execute(ctx, &commands.MinimalGrantingSetRequest{
+			StoreID:  storeID,
+			TupleKey: tuple.NewTupleKey("document:combined", "viewer", "user:bob"),
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+}
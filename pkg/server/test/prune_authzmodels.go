@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestPruneAuthorizationModels(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	pruner, ok := ds.(storage.AuthorizationModelPruner)
+	require.True(t, ok, "the datastore must implement storage.AuthorizationModelPruner")
+
+	// writeModels writes n models to a new store, from oldest to newest
+	writeModels := func(t *testing.T, n int) (string, []string) {
+		storeID := ulid.Make().String()
+		modelIDs := make([]string, n)
+		for i := range modelIDs {
+			model := testutils.MustTransformDSLToProtoWithID(`
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define viewer: [user]`)
+			require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+			modelIDs[i] = model.GetId()
+		}
+		return storeID, modelIDs
+	}
+
+	requireModels := func(t *testing.T, storeID string, expected []string) {
+		models, _, err := ds.ReadAuthorizationModels(ctx, storeID, storage.ReadAuthorizationModelsOptions{})
+		require.NoError(t, err)
+		actual := make([]string, 0, len(models))
+		for _, model := range models {
+			actual = append(actual, model.GetId())
+		}
+		require.ElementsMatch(t, expected, actual)
+	}
+
+	t.Run("retains_the_most_recent_models", func(t *testing.T) {
+		storeID, modelIDs := writeModels(t, 5)
+
+		resp, err := commands.NewPruneAuthorizationModelsCommand(pruner).Execute(ctx, &commands.PruneAuthorizationModelsRequest{
+			StoreID: storeID,
+			Retain:  2,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, resp.Pruned)
+		requireModels(t, storeID, modelIDs[3:])
+
+		latest, err := ds.FindLatestAuthorizationModel(ctx, storeID)
+		require.NoError(t, err)
+		require.Equal(t, modelIDs[4], latest.GetId())
+
+		// pruning again is a no-op
+		resp, err = commands.NewPruneAuthorizationModelsCommand(pruner).Execute(ctx, &commands.PruneAuthorizationModelsRequest{
+			StoreID: storeID,
+			Retain:  2,
+		})
+		require.NoError(t, err)
+		require.Zero(t, resp.Pruned)
+	})
+
+	t.Run("preserves_the_models_of_assertions", func(t *testing.T) {
+		storeID, modelIDs := writeModels(t, 4)
+
+		assertions := []*openfgav1.Assertion{
+			{TupleKey: tuple.NewAssertionTupleKey("document:1", "viewer", "user:anne"), Expectation: true},
+		}
+		require.NoError(t, ds.WriteAssertions(ctx, storeID, modelIDs[1], assertions))
+		// a model whose assertions were all deleted is pruned
+		require.NoError(t, ds.WriteAssertions(ctx, storeID, modelIDs[2], []*openfgav1.Assertion{}))
+
+		resp, err := commands.NewPruneAuthorizationModelsCommand(pruner).Execute(ctx, &commands.PruneAuthorizationModelsRequest{
+			StoreID: storeID,
+			Retain:  1,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, resp.Pruned)
+		requireModels(t, storeID, []string{modelIDs[1], modelIDs[3]})
+
+		got, err := ds.ReadAssertions(ctx, storeID, modelIDs[1])
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+	})
+
+	t.Run("preserves_the_models_in_use", func(t *testing.T) {
+		storeID, modelIDs := writeModels(t, 3)
+
+		resp, err := commands.NewPruneAuthorizationModelsCommand(pruner,
+			commands.WithPruneAuthorizationModelsInUse(func(string) []string { return modelIDs[:1] }),
+		).Execute(ctx, &commands.PruneAuthorizationModelsRequest{
+			StoreID: storeID,
+			Retain:  1,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, resp.Pruned)
+		requireModels(t, storeID, []string{modelIDs[0], modelIDs[2]})
+	})
+
+	t.Run("only_prunes_the_store", func(t *testing.T) {
+		storeID, modelIDs := writeModels(t, 2)
+		otherStoreID, otherModelIDs := writeModels(t, 2)
+
+		resp, err := commands.NewPruneAuthorizationModelsCommand(pruner).Execute(ctx, &commands.PruneAuthorizationModelsRequest{
+			StoreID: storeID,
+			Retain:  1,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, resp.Pruned)
+		requireModels(t, storeID, modelIDs[1:])
+		requireModels(t, otherStoreID, otherModelIDs)
+	})
+
+	t.Run("retain_must_be_positive", func(t *testing.T) {
+		storeID, modelIDs := writeModels(t, 2)
+
+		_, err := commands.NewPruneAuthorizationModelsCommand(pruner).Execute(ctx, &commands.PruneAuthorizationModelsRequest{
+			StoreID: storeID,
+			Retain:  0,
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		requireModels(t, storeID, modelIDs)
+	})
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestReadMalformedContinuationToken(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	err := datastore.Write(ctx, store, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+	})
+	require.NoError(t, err)
+
+	tokens := map[string]string{
+		"not_base64":          "%%%",
+		"negative_offset":     base64.URLEncoding.EncodeToString([]byte("-1")),
+		"garbage":             base64.URLEncoding.EncodeToString([]byte("garbage")),
+		"truncated_structure": base64.URLEncoding.EncodeToString([]byte("{\"ulid\":")),
+	}
+
+	for name, token := range tokens {
+		t.Run(name, func(t *testing.T) {
+			_, err := commands.NewReadQuery(datastore).Execute(ctx, &openfgav1.ReadRequest{
+				StoreId:           store,
+				ContinuationToken: token,
+			})
+			require.ErrorIs(t, err, serverErrors.InvalidContinuationToken)
+
+			_, err = commands.NewReadChangesQuery(datastore).Execute(ctx, &openfgav1.ReadChangesRequest{
+				StoreId:           store,
+				ContinuationToken: token,
+			})
+			require.ErrorIs(t, err, serverErrors.InvalidContinuationToken)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	parser "github.com/openfga/language/pkg/go/transformer"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/openfga/openfga/pkg/server/commands"
 	serverErrors "github.com/openfga/openfga/pkg/server/errors"
@@ -127,3 +128,71 @@ func ReadAuthorizationModelTest(t *testing.T, datastore storage.OpenFGADatastore
 		require.ErrorContains(t, err, serverErrors.AuthorizationModelNotFound(model.GetId()).Error())
 	})
 }
+
+func TestReadAuthorizationModelQueryWithAssertions(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	newModel := func() *openfgav1.AuthorizationModel {
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define reader: [user]`)
+		model.Id = ulid.Make().String()
+		require.NoError(t, datastore.WriteAuthorizationModel(ctx, storeID, model))
+		return model
+	}
+
+	model := newModel()
+	otherModel := newModel()
+
+	assertions := []*openfgav1.Assertion{
+		{
+			TupleKey:    &openfgav1.AssertionTupleKey{Object: "document:1", Relation: "reader", User: "user:anne"},
+			Expectation: true,
+		},
+	}
+	require.NoError(t, datastore.WriteAssertions(ctx, storeID, model.GetId(), assertions))
+	require.NoError(t, datastore.WriteAssertions(ctx, storeID, otherModel.GetId(), []*openfgav1.Assertion{
+		{
+			TupleKey: &openfgav1.AssertionTupleKey{Object: "document:2", Relation: "reader", User: "user:bob"},
+		},
+	}))
+
+	query := commands.NewReadAuthorizationModelQuery(datastore, commands.WithReadAuthModelQueryAssertions(datastore))
+
+	t.Run("model_with_assertions", func(t *testing.T) {
+		resp, err := query.ExecuteWithAssertions(ctx, &openfgav1.ReadAuthorizationModelRequest{
+			StoreId: storeID,
+			Id:      model.GetId(),
+		})
+		require.NoError(t, err)
+		require.Equal(t, model.GetId(), resp.AuthorizationModel.GetId())
+		require.Equal(t, model.GetSchemaVersion(), resp.AuthorizationModel.GetSchemaVersion())
+		require.Len(t, resp.Assertions, 1)
+		require.True(t, proto.Equal(assertions[0], resp.Assertions[0]))
+	})
+
+	t.Run("model_without_assertions", func(t *testing.T) {
+		model := newModel()
+
+		resp, err := query.ExecuteWithAssertions(ctx, &openfgav1.ReadAuthorizationModelRequest{
+			StoreId: storeID,
+			Id:      model.GetId(),
+		})
+		require.NoError(t, err)
+		require.Equal(t, model.GetId(), resp.AuthorizationModel.GetId())
+		require.Empty(t, resp.Assertions)
+	})
+
+	t.Run("model_not_found", func(t *testing.T) {
+		_, err := query.ExecuteWithAssertions(ctx, &openfgav1.ReadAuthorizationModelRequest{
+			StoreId: storeID,
+			Id:      "123",
+		})
+		require.ErrorIs(t, err, serverErrors.AuthorizationModelNotFound("123"))
+	})
+}
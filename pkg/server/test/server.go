@@ -15,8 +15,11 @@ func RunQueryTests(t *testing.T, ds storage.OpenFGADatastore) {
 	t.Run("TestReadAuthorizationModelQueryErrors", func(t *testing.T) { TestReadAuthorizationModelQueryErrors(t, ds) })
 	t.Run("TestSuccessfulReadAuthorizationModelQuery", func(t *testing.T) { TestSuccessfulReadAuthorizationModelQuery(t, ds) })
 	t.Run("TestReadAuthorizationModel", func(t *testing.T) { ReadAuthorizationModelTest(t, ds) })
+	t.Run("TestReadAuthorizationModelQueryWithAssertions", func(t *testing.T) { TestReadAuthorizationModelQueryWithAssertions(t, ds) })
 	t.Run("TestExpandQuery", func(t *testing.T) { TestExpandQuery(t, ds) })
 	t.Run("TestExpandQueryErrors", func(t *testing.T) { TestExpandQueryErrors(t, ds) })
+	t.Run("TestExpandQueryStreamed", func(t *testing.T) { TestExpandQueryStreamed(t, ds) })
+	t.Run("TestExpandQueryWithMaxDirectUsers", func(t *testing.T) { TestExpandQueryWithMaxDirectUsers(t, ds) })
 
 	t.Run("TestGetStoreQuery", func(t *testing.T) { TestGetStoreQuery(t, ds) })
 	t.Run("TestGetStoreSucceeds", func(t *testing.T) { TestGetStoreSucceeds(t, ds) })
@@ -35,17 +38,35 @@ func RunQueryTests(t *testing.T, ds storage.OpenFGADatastore) {
 	t.Run("TestReadAuthorizationModelsInvalidContinuationToken",
 		func(t *testing.T) { TestReadAuthorizationModelsInvalidContinuationToken(t, ds) },
 	)
+	t.Run("TestReadMalformedContinuationToken", func(t *testing.T) { TestReadMalformedContinuationToken(t, ds) })
 
 	t.Run("TestListObjects", func(t *testing.T) { TestListObjects(t, ds) })
+	t.Run("TestListObjectsSinceTime", func(t *testing.T) { TestListObjectsSinceTime(t, ds) })
+	t.Run("TestListObjectsRelationNotListable", func(t *testing.T) { TestListObjectsRelationNotListable(t, ds) })
 	t.Run("TestReverseExpand", func(t *testing.T) { TestReverseExpand(t, ds) })
+	t.Run("TestMinimalGrantingSet", func(t *testing.T) { TestMinimalGrantingSet(t, ds) })
+	t.Run("TestHypotheticalCheck", func(t *testing.T) { TestHypotheticalCheck(t, ds) })
+	t.Run("TestAnalyzeTupleChange", func(t *testing.T) { TestAnalyzeTupleChange(t, ds) })
+	t.Run("TestTupleCountsByRelation", func(t *testing.T) { TestTupleCountsByRelation(t, ds) })
+	t.Run("TestAccessMatrix", func(t *testing.T) { TestAccessMatrix(t, ds) })
+	t.Run("TestWatch", func(t *testing.T) { TestWatch(t, ds) })
+	t.Run("TestBatchCheck", func(t *testing.T) { TestBatchCheck(t, ds) })
 }
 
 func RunCommandTests(t *testing.T, ds storage.OpenFGADatastore) {
 	t.Run("TestWriteCommand", func(t *testing.T) { TestWriteCommand(t, ds) })
+	t.Run("TestWriteCommandFieldLengthLimits", func(t *testing.T) { TestWriteCommandFieldLengthLimits(t, ds) })
+	t.Run("TestWriteCommandConflictStrategies", func(t *testing.T) { TestWriteCommandConflictStrategies(t, ds) })
+	t.Run("TestWriteCommandConditionContext", func(t *testing.T) { TestWriteCommandConditionContext(t, ds) })
 	t.Run("TestWriteAuthorizationModel", func(t *testing.T) { WriteAuthorizationModelTest(t, ds) })
+	t.Run("TestStoreAllowedObjectTypes", func(t *testing.T) { TestStoreAllowedObjectTypes(t, ds) })
+	t.Run("TestStoreDefaultPageSize", func(t *testing.T) { TestStoreDefaultPageSize(t, ds) })
+	t.Run("TestStoreMaxAuthorizationModels", func(t *testing.T) { TestStoreMaxAuthorizationModels(t, ds) })
 	t.Run("TestWriteAndReadAssertions", func(t *testing.T) { TestWriteAndReadAssertions(t, ds) })
 	t.Run("TestCreateStore", func(t *testing.T) { TestCreateStore(t, ds) })
 	t.Run("TestDeleteStore", func(t *testing.T) { TestDeleteStore(t, ds) })
+	t.Run("TestDeleteMatchingTuples", func(t *testing.T) { TestDeleteMatchingTuples(t, ds) })
+	t.Run("TestPruneAuthorizationModels", func(t *testing.T) { TestPruneAuthorizationModels(t, ds) })
 }
 
 func RunAllBenchmarks(b *testing.B, ds storage.OpenFGADatastore) {
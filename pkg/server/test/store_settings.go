@@ -0,0 +1,232 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestStoreAllowedObjectTypes(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	backend, ok := datastore.(storage.StoreSettingsBackend)
+	require.True(t, ok, "the datastore must implement storage.StoreSettingsBackend")
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]
+		type folder
+			relations
+				define viewer: [user]`)
+
+	newStore := func(t *testing.T, allowedObjectTypes ...string) string {
+		store, err := datastore.CreateStore(ctx, &openfgav1.Store{Id: ulid.Make().String(), Name: "allowed-types"})
+		require.NoError(t, err)
+		require.NoError(t, backend.WriteStoreSettings(ctx, store.GetId(), &storage.StoreSettings{AllowedObjectTypes: allowedObjectTypes}))
+		require.NoError(t, datastore.WriteAuthorizationModel(ctx, store.GetId(), model))
+		return store.GetId()
+	}
+
+	writeModel := func(store string) error {
+		cmd := commands.NewWriteAuthorizationModelCommand(datastore, commands.WithWriteAuthModelStoreSettings(backend))
+		_, err := cmd.Execute(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         store,
+			SchemaVersion:   typesystem.SchemaVersion1_1,
+			TypeDefinitions: model.GetTypeDefinitions(),
+		})
+		return err
+	}
+
+	write := func(store string, tk *openfgav1.TupleKey) error {
+		cmd := commands.NewWriteCommand(datastore, commands.WithWriteCmdStoreSettings(backend))
+		_, err := cmd.Execute(ctx, &openfgav1.WriteRequest{
+			StoreId:              store,
+			AuthorizationModelId: model.GetId(),
+			Writes:               &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{tk}},
+		})
+		return err
+	}
+
+	t.Run("no_restriction", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, writeModel(store))
+		require.NoError(t, write(store, tuple.NewTupleKey("folder:1", "viewer", "user:anne")))
+	})
+
+	t.Run("allowed_types", func(t *testing.T) {
+		store := newStore(t, "user", "document", "folder")
+		require.NoError(t, writeModel(store))
+		require.NoError(t, write(store, tuple.NewTupleKey("document:1", "viewer", "user:anne")))
+	})
+
+	t.Run("disallowed_type_in_model", func(t *testing.T) {
+		store := newStore(t, "user", "document")
+		err := writeModel(store)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_invalid_authorization_model), status.Code(err))
+		require.ErrorContains(t, err, (&storage.DisallowedObjectTypeError{StoreID: store, ObjectType: "folder"}).Error())
+	})
+
+	t.Run("disallowed_type_in_tuple", func(t *testing.T) {
+		store := newStore(t, "user", "document")
+		require.NoError(t, write(store, tuple.NewTupleKey("document:1", "viewer", "user:anne")))
+
+		err := write(store, tuple.NewTupleKey("folder:1", "viewer", "user:anne"))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, (&storage.DisallowedObjectTypeError{StoreID: store, ObjectType: "folder"}).Error())
+	})
+}
+
+func TestStoreDefaultPageSize(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	backend, ok := datastore.(storage.StoreSettingsBackend)
+	require.True(t, ok, "the datastore must implement storage.StoreSettingsBackend")
+
+	newStore := func(t *testing.T, defaultPageSize int32) string {
+		store, err := datastore.CreateStore(ctx, &openfgav1.Store{Id: ulid.Make().String(), Name: "default-page-size"})
+		require.NoError(t, err)
+		require.NoError(t, backend.WriteStoreSettings(ctx, store.GetId(), &storage.StoreSettings{DefaultPageSize: defaultPageSize}))
+
+		var tuples []*openfgav1.TupleKey
+		for i := 0; i < 120; i++ {
+			tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:anne"))
+		}
+		for start := 0; start < len(tuples); start += datastore.MaxTuplesPerWrite() {
+			end := min(start+datastore.MaxTuplesPerWrite(), len(tuples))
+			require.NoError(t, datastore.Write(ctx, store.GetId(), nil, tuples[start:end]))
+		}
+		return store.GetId()
+	}
+
+	read := func(t *testing.T, store string, pageSize *wrapperspb.Int32Value) int {
+		resp, err := commands.NewReadQuery(datastore, commands.WithReadQueryStoreSettings(backend)).Execute(ctx, &openfgav1.ReadRequest{
+			StoreId:  store,
+			PageSize: pageSize,
+		})
+		require.NoError(t, err)
+		return len(resp.GetTuples())
+	}
+
+	t.Run("no_default", func(t *testing.T) {
+		require.Equal(t, storage.DefaultPageSize, read(t, newStore(t, 0), nil))
+	})
+
+	t.Run("store_default", func(t *testing.T) {
+		require.Equal(t, 7, read(t, newStore(t, 7), nil))
+	})
+
+	t.Run("request_overrides_store_default", func(t *testing.T) {
+		require.Equal(t, 3, read(t, newStore(t, 7), wrapperspb.Int32(3)))
+	})
+
+	t.Run("store_default_is_bounded", func(t *testing.T) {
+		require.Equal(t, 100, read(t, newStore(t, 1000), nil))
+	})
+}
+
+func TestStoreMaxAuthorizationModels(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	backend, ok := datastore.(storage.StoreSettingsBackend)
+	require.True(t, ok, "the datastore must implement storage.StoreSettingsBackend")
+	pruner, ok := datastore.(storage.AuthorizationModelPruner)
+	require.True(t, ok, "the datastore must implement storage.AuthorizationModelPruner")
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	newStore := func(t *testing.T, maxAuthorizationModels int32) string {
+		store, err := datastore.CreateStore(ctx, &openfgav1.Store{Id: ulid.Make().String(), Name: "max-models"})
+		require.NoError(t, err)
+		require.NoError(t, backend.WriteStoreSettings(ctx, store.GetId(), &storage.StoreSettings{MaxAuthorizationModels: maxAuthorizationModels}))
+		return store.GetId()
+	}
+
+	writeModel := func(store string, maxModelsPerStore int) error {
+		cmd := commands.NewWriteAuthorizationModelCommand(datastore,
+			commands.WithWriteAuthModelStoreSettings(backend),
+			commands.WithWriteAuthModelMaxModelsPerStore(maxModelsPerStore),
+		)
+		_, err := cmd.Execute(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         store,
+			SchemaVersion:   typesystem.SchemaVersion1_1,
+			TypeDefinitions: model.GetTypeDefinitions(),
+		})
+		return err
+	}
+
+	requireQuotaExceeded := func(t *testing.T, err error) {
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		require.ErrorContains(t, err, storage.ErrModelQuotaExceeded.Error())
+	}
+
+	t.Run("unlimited", func(t *testing.T) {
+		store := newStore(t, 0)
+		for i := 0; i < 5; i++ {
+			require.NoError(t, writeModel(store, 0))
+		}
+	})
+
+	t.Run("server_default", func(t *testing.T) {
+		store := newStore(t, 0)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, writeModel(store, 3))
+		}
+		requireQuotaExceeded(t, writeModel(store, 3))
+	})
+
+	t.Run("store_overrides_server_default", func(t *testing.T) {
+		store := newStore(t, 2)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, writeModel(store, 3))
+		}
+		requireQuotaExceeded(t, writeModel(store, 3))
+		requireQuotaExceeded(t, writeModel(store, 0))
+	})
+
+	t.Run("quota_larger_than_a_page", func(t *testing.T) {
+		store := newStore(t, 0)
+		for i := 0; i < storage.DefaultPageSize+1; i++ {
+			require.NoError(t, datastore.WriteAuthorizationModel(ctx, store, testutils.MustTransformDSLToProtoWithID(`
+				model
+					schema 1.1
+				type user`)))
+		}
+		require.NoError(t, writeModel(store, storage.DefaultPageSize+2))
+		requireQuotaExceeded(t, writeModel(store, storage.DefaultPageSize+2))
+	})
+
+	t.Run("pruning_makes_room", func(t *testing.T) {
+		store := newStore(t, 2)
+		require.NoError(t, writeModel(store, 0))
+		require.NoError(t, writeModel(store, 0))
+		requireQuotaExceeded(t, writeModel(store, 0))
+
+		pruned, err := pruner.PruneAuthorizationModels(ctx, store, 1, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, pruned)
+		require.NoError(t, writeModel(store, 0))
+	})
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestTupleCountsByRelation(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	var tuples []*openfgav1.TupleKey
+	for i := 0; i < 150; i++ {
+		tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:anne"))
+	}
+	for i := 0; i < 20; i++ {
+		tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "editor", "user:bob"))
+		tuples = append(tuples, tuple.NewTupleKey(fmt.Sprintf("folder:%d", i), "viewer", "group:eng#member"))
+	}
+	tuples = append(tuples, tuple.NewTupleKey("group:eng", "member", "user:anne"))
+
+	for start := 0; start < len(tuples); start += ds.MaxTuplesPerWrite() {
+		end := min(start+ds.MaxTuplesPerWrite(), len(tuples))
+		require.NoError(t, ds.Write(ctx, storeID, nil, tuples[start:end]))
+	}
+	require.NoError(t, ds.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{
+		tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:0", "editor", "user:bob")),
+	}, nil))
+
+	// a tuple of another store must not be counted
+	require.NoError(t, ds.Write(ctx, ulid.Make().String(), nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:0", "owner", "user:anne"),
+	}))
+
+	expected := []storage.RelationTupleCount{
+		{ObjectType: "document", Relation: "editor", Count: 19},
+		{ObjectType: "document", Relation: "viewer", Count: 150},
+		{ObjectType: "folder", Relation: "viewer", Count: 20},
+		{ObjectType: "group", Relation: "member", Count: 1},
+	}
+
+	t.Run("datastore_counts", func(t *testing.T) {
+		resp, err := commands.NewTupleCountsByRelationQuery(ds).Execute(ctx, &commands.TupleCountsByRelationRequest{StoreID: storeID})
+		require.NoError(t, err)
+		require.Equal(t, expected, resp.Counts)
+		require.False(t, resp.Approximate)
+	})
+
+	t.Run("counts_by_reading_every_tuple", func(t *testing.T) {
+		// the wrapper hides the ability of the datastore to count tuples
+		reader := storagewrappers.NewCombinedTupleReader(ds, nil)
+		resp, err := commands.NewTupleCountsByRelationQuery(reader).Execute(ctx, &commands.TupleCountsByRelationRequest{
+			StoreID:     storeID,
+			Approximate: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, resp.Counts)
+		require.False(t, resp.Approximate)
+	})
+
+	t.Run("empty_store", func(t *testing.T) {
+		resp, err := commands.NewTupleCountsByRelationQuery(ds).Execute(ctx, &commands.TupleCountsByRelationRequest{StoreID: ulid.Make().String()})
+		require.NoError(t, err)
+		require.Empty(t, resp.Counts)
+	})
+}
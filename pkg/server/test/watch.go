@@ -0,0 +1,130 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestWatch(t *testing.T, ds storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:1", "owner", "user:bob"),
+		tuple.NewTupleKey("folder:1", "viewer", "user:anne"),
+	}))
+
+	cmd := commands.NewWatchCommand(ds, commands.WithWatchPollInterval(5*time.Millisecond))
+
+	// watch runs the command in the background, and returns its events and its error once it returns
+	watch := func(t *testing.T, req *commands.WatchRequest) (<-chan *commands.WatchEvent, <-chan error) {
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+
+		events := make(chan *commands.WatchEvent)
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Execute(ctx, req, func(event *commands.WatchEvent) error {
+				select {
+				case events <- event:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}()
+		return events, done
+	}
+
+	nextChanges := func(t *testing.T, events <-chan *commands.WatchEvent) *commands.WatchEvent {
+		for {
+			select {
+			case event := <-events:
+				if len(event.Changes) > 0 {
+					return event
+				}
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "no changes were streamed")
+			}
+		}
+	}
+
+	usersOf := func(event *commands.WatchEvent) []string {
+		users := make([]string, 0, len(event.Changes))
+		for _, change := range event.Changes {
+			users = append(users, change.GetTupleKey().GetUser())
+		}
+		return users
+	}
+
+	t.Run("streams_the_changes_of_the_object_type_and_relation", func(t *testing.T) {
+		events, _ := watch(t, &commands.WatchRequest{StoreID: storeID, ObjectType: "document", Relation: "viewer"})
+
+		event := nextChanges(t, events)
+		require.Equal(t, []string{"user:anne"}, usersOf(event))
+		require.NotEmpty(t, event.ContinuationToken)
+
+		require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:2", "owner", "user:carl"),
+			tuple.NewTupleKey("document:2", "viewer", "user:carl"),
+		}))
+		event = nextChanges(t, events)
+		require.Equal(t, []string{"user:carl"}, usersOf(event))
+		require.Equal(t, openfgav1.TupleOperation_TUPLE_OPERATION_WRITE, event.Changes[0].GetOperation())
+
+		// resuming from the token of the first event only streams the later changes
+		resumed, _ := watch(t, &commands.WatchRequest{
+			StoreID:           storeID,
+			ObjectType:        "document",
+			Relation:          "viewer",
+			ContinuationToken: event.ContinuationToken,
+		})
+		require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:3", "viewer", "user:dana"),
+		}))
+		require.Equal(t, []string{"user:dana"}, usersOf(nextChanges(t, resumed)))
+	})
+
+	t.Run("emits_heartbeats_on_an_idle_store", func(t *testing.T) {
+		events, _ := watch(t, &commands.WatchRequest{StoreID: storeID, ObjectType: "folder", HeartbeatInterval: time.Millisecond})
+
+		event := nextChanges(t, events)
+		require.Equal(t, []string{"user:anne"}, usersOf(event))
+
+		for i := 0; i < 2; i++ {
+			select {
+			case heartbeat := <-events:
+				require.Empty(t, heartbeat.Changes)
+				require.Equal(t, event.ContinuationToken, heartbeat.ContinuationToken)
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "no heartbeat was emitted")
+			}
+		}
+	})
+
+	t.Run("stops_when_the_context_is_done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := cmd.Execute(ctx, &commands.WatchRequest{StoreID: storeID}, func(*commands.WatchEvent) error {
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid_continuation_token", func(t *testing.T) {
+		_, done := watch(t, &commands.WatchRequest{StoreID: storeID, ContinuationToken: "invalid"})
+		require.ErrorIs(t, <-done, serverErrors.InvalidContinuationToken)
+	})
+}
@@ -3,13 +3,17 @@ package test
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/oklog/ulid/v2"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	parser "github.com/openfga/language/pkg/go/transformer"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/openfga/openfga/internal/condition"
 	"github.com/openfga/openfga/pkg/testutils"
 
 	"github.com/openfga/openfga/pkg/server/commands"
@@ -1405,3 +1409,267 @@ func TestWriteCommand(t *testing.T, datastore storage.OpenFGADatastore) {
 		})
 	}
 }
+
+func TestWriteCommandFieldLengthLimits(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	limits := tuple.DefaultFieldLengthLimits
+	longRelation := strings.Repeat("r", limits.Relation)
+	model := testutils.MustTransformDSLToProtoWithID(fmt.Sprintf(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define %s: [user]
+				define %s: [user]`, longRelation, longRelation+"r"))
+
+	tests := map[string]struct {
+		tupleKey *openfgav1.TupleKey
+		err      *tuple.FieldTooLongError
+	}{
+		"object_id_at_limit": {
+			tupleKey: tuple.NewTupleKey("document:"+strings.Repeat("a", limits.ObjectID), longRelation, "user:jon"),
+		},
+		"object_id_over_limit": {
+			tupleKey: tuple.NewTupleKey("document:"+strings.Repeat("a", limits.ObjectID+1), longRelation, "user:jon"),
+			err:      &tuple.FieldTooLongError{Field: "object_id", Length: limits.ObjectID + 1, Limit: limits.ObjectID},
+		},
+		"object_id_over_limit_in_bytes": {
+			tupleKey: tuple.NewTupleKey("document:"+strings.Repeat("é", limits.ObjectID/2+1), longRelation, "user:jon"),
+			err:      &tuple.FieldTooLongError{Field: "object_id", Length: limits.ObjectID + 2, Limit: limits.ObjectID},
+		},
+		"relation_at_limit": {
+			tupleKey: tuple.NewTupleKey("document:1", longRelation, "user:jon"),
+		},
+		"relation_over_limit": {
+			tupleKey: tuple.NewTupleKey("document:1", longRelation+"r", "user:jon"),
+			err:      &tuple.FieldTooLongError{Field: "relation", Length: limits.Relation + 1, Limit: limits.Relation},
+		},
+		"user_at_limit": {
+			tupleKey: tuple.NewTupleKey("document:1", longRelation, "user:"+strings.Repeat("u", limits.User-len("user:"))),
+		},
+		"user_over_limit": {
+			tupleKey: tuple.NewTupleKey("document:1", longRelation, "user:"+strings.Repeat("u", limits.User-len("user:")+1)),
+			err:      &tuple.FieldTooLongError{Field: "user", Length: limits.User + 1, Limit: limits.User},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := ulid.Make().String()
+			require.NoError(t, datastore.WriteAuthorizationModel(ctx, store, model))
+
+			cmd := commands.NewWriteCommand(datastore)
+			_, err := cmd.Execute(ctx, &openfgav1.WriteRequest{
+				StoreId:              store,
+				AuthorizationModelId: model.GetId(),
+				Writes: &openfgav1.WriteRequestWrites{
+					TupleKeys: []*openfgav1.TupleKey{test.tupleKey},
+				},
+			})
+			if test.err == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, serverErrors.ValidationError(&tuple.InvalidTupleError{Cause: test.err, TupleKey: test.tupleKey}))
+			require.ErrorContains(t, err, test.err.Error())
+		})
+	}
+
+	t.Run("limits_are_configurable", func(t *testing.T) {
+		store := ulid.Make().String()
+		require.NoError(t, datastore.WriteAuthorizationModel(ctx, store, model))
+
+		cmd := commands.NewWriteCommand(datastore, commands.WithWriteCmdFieldLengthLimits(tuple.FieldLengthLimits{ObjectID: 1}))
+		_, err := cmd.Execute(ctx, &openfgav1.WriteRequest{
+			StoreId:              store,
+			AuthorizationModelId: model.GetId(),
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:12", longRelation, "user:jon")},
+			},
+		})
+		require.ErrorContains(t, err, (&tuple.FieldTooLongError{Field: "object_id", Length: 2, Limit: 1}).Error())
+	})
+}
+
+func TestWriteCommandConflictStrategies(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	viewer := func(document string) *openfgav1.TupleKey {
+		return tuple.NewTupleKey(document, "viewer", "user:anne")
+	}
+	deleteViewer := func(document string) *openfgav1.TupleKeyWithoutCondition {
+		return tuple.TupleKeyToTupleKeyWithoutCondition(viewer(document))
+	}
+
+	tests := map[string]struct {
+		strategy commands.WriteConflictStrategy
+		deletes  []*openfgav1.TupleKeyWithoutCondition
+		writes   []*openfgav1.TupleKey
+		err      error
+		expected []string
+	}{
+		"reject": {
+			strategy: commands.WriteConflictStrategyReject,
+			deletes:  []*openfgav1.TupleKeyWithoutCondition{deleteViewer("document:2"), deleteViewer("document:4")},
+			writes:   []*openfgav1.TupleKey{viewer("document:4"), viewer("document:3")},
+			err:      serverErrors.DuplicateTupleInWrite(viewer("document:4")),
+			expected: []string{"document:1", "document:2"},
+		},
+		"last_wins_writes_the_tuple": {
+			strategy: commands.WriteConflictStrategyLastWins,
+			deletes:  []*openfgav1.TupleKeyWithoutCondition{deleteViewer("document:2"), deleteViewer("document:4")},
+			writes:   []*openfgav1.TupleKey{viewer("document:4"), viewer("document:3")},
+			expected: []string{"document:1", "document:3", "document:4"},
+		},
+		"last_wins_fails_if_the_tuple_exists": {
+			strategy: commands.WriteConflictStrategyLastWins,
+			deletes:  []*openfgav1.TupleKeyWithoutCondition{deleteViewer("document:1")},
+			writes:   []*openfgav1.TupleKey{viewer("document:1")},
+			err:      serverErrors.WriteFailedDueToInvalidInput(nil),
+			expected: []string{"document:1", "document:2"},
+		},
+		"delete_wins_deletes_the_tuple": {
+			strategy: commands.WriteConflictStrategyDeleteWins,
+			deletes:  []*openfgav1.TupleKeyWithoutCondition{deleteViewer("document:1"), deleteViewer("document:2")},
+			writes:   []*openfgav1.TupleKey{viewer("document:1"), viewer("document:3")},
+			expected: []string{"document:3"},
+		},
+		"delete_wins_fails_if_the_tuple_does_not_exist": {
+			strategy: commands.WriteConflictStrategyDeleteWins,
+			deletes:  []*openfgav1.TupleKeyWithoutCondition{deleteViewer("document:4")},
+			writes:   []*openfgav1.TupleKey{viewer("document:4")},
+			err:      serverErrors.WriteFailedDueToInvalidInput(nil),
+			expected: []string{"document:1", "document:2"},
+		},
+		"duplicate_writes_are_rejected_by_every_strategy": {
+			strategy: commands.WriteConflictStrategyLastWins,
+			deletes:  []*openfgav1.TupleKeyWithoutCondition{deleteViewer("document:3")},
+			writes:   []*openfgav1.TupleKey{viewer("document:3"), viewer("document:3")},
+			err:      serverErrors.DuplicateTupleInWrite(viewer("document:3")),
+			expected: []string{"document:1", "document:2"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := ulid.Make().String()
+			require.NoError(t, datastore.WriteAuthorizationModel(ctx, store, model))
+			require.NoError(t, datastore.Write(ctx, store, nil, []*openfgav1.TupleKey{viewer("document:1"), viewer("document:2")}))
+
+			cmd := commands.NewWriteCommand(datastore, commands.WithWriteCmdConflictStrategy(test.strategy))
+			_, err := cmd.Execute(ctx, &openfgav1.WriteRequest{
+				StoreId:              store,
+				AuthorizationModelId: model.GetId(),
+				Deletes:              &openfgav1.WriteRequestDeletes{TupleKeys: test.deletes},
+				Writes:               &openfgav1.WriteRequestWrites{TupleKeys: test.writes},
+			})
+			if test.err != nil {
+				require.Equal(t, status.Code(test.err), status.Code(err))
+			} else {
+				require.NoError(t, err)
+			}
+
+			tuples, _, err := datastore.ReadPage(ctx, store, nil, storage.ReadPageOptions{
+				Pagination: storage.NewPaginationOptions(storage.DefaultPageSize, ""),
+			})
+			require.NoError(t, err)
+			var documents []string
+			for _, tp := range tuples {
+				documents = append(documents, tp.GetKey().GetObject())
+			}
+			require.ElementsMatch(t, test.expected, documents)
+		})
+	}
+}
+
+func TestWriteCommandConditionContext(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user with in_office_hours]
+
+		condition in_office_hours(hour: int, timezone: string) {
+			hour >= 9 && hour < 17 && timezone != ""
+		}`)
+
+	withContext := func(context map[string]interface{}) *openfgav1.TupleKey {
+		return tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:jon", "in_office_hours", testutils.MustNewStruct(t, context))
+	}
+
+	tests := map[string]struct {
+		tupleKey                 *openfgav1.TupleKey
+		completeConditionContext bool
+		err                      error
+	}{
+		"complete_context": {
+			tupleKey:                 withContext(map[string]interface{}{"hour": 10, "timezone": "UTC"}),
+			completeConditionContext: true,
+		},
+		"type_mismatch": {
+			tupleKey: withContext(map[string]interface{}{"hour": "ten"}),
+			err:      &condition.ParameterTypeError{Condition: "in_office_hours", Parameter: "hour"},
+		},
+		"type_mismatch_with_complete_context": {
+			tupleKey:                 withContext(map[string]interface{}{"hour": "ten", "timezone": "UTC"}),
+			completeConditionContext: true,
+			err:                      &condition.ParameterTypeError{Condition: "in_office_hours", Parameter: "hour"},
+		},
+		"missing_parameter_left_to_the_queries": {
+			tupleKey: withContext(map[string]interface{}{"hour": 10}),
+		},
+		"missing_parameter": {
+			tupleKey:                 withContext(map[string]interface{}{"hour": 10}),
+			completeConditionContext: true,
+			err:                      &condition.MissingParameterError{Condition: "in_office_hours", Parameter: "timezone"},
+		},
+		"missing_context": {
+			tupleKey:                 tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:jon", "in_office_hours", nil),
+			completeConditionContext: true,
+			err:                      &condition.MissingParameterError{Condition: "in_office_hours", Parameter: "hour"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := ulid.Make().String()
+			require.NoError(t, datastore.WriteAuthorizationModel(ctx, store, model))
+
+			cmd := commands.NewWriteCommand(datastore, commands.WithWriteCmdCompleteConditionContext(test.completeConditionContext))
+			_, err := cmd.Execute(ctx, &openfgav1.WriteRequest{
+				StoreId:              store,
+				AuthorizationModelId: model.GetId(),
+				Writes: &openfgav1.WriteRequestWrites{
+					TupleKeys: []*openfgav1.TupleKey{test.tupleKey},
+				},
+			})
+			if test.err == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+			switch paramErr := test.err.(type) {
+			case *condition.ParameterTypeError:
+				require.ErrorContains(t, err, fmt.Sprintf("failed to convert context parameter '%s'", paramErr.Parameter))
+			case *condition.MissingParameterError:
+				require.ErrorIs(t, err, serverErrors.ValidationError(&tuple.InvalidConditionalTupleError{Cause: paramErr, TupleKey: test.tupleKey}))
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/openfga/openfga/internal/authn"
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/middleware/disabledmethods"
+	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/telemetry"
+)
+
+// WatchPath is the path of the HTTP equivalent of Watch, see WatchHTTPHandler.
+const WatchPath = "/stores/{store_id}/watch"
+
+// watchWriteTimeout is the time after which a consumer of the HTTP equivalent of Watch that doesn't read its
+// events is disconnected.
+const watchWriteTimeout = 30 * time.Second
+
+var (
+	watchInFlightStreamsGaugeName = "watch_in_flight_streams"
+
+	watchInFlightStreamsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: build.ProjectName,
+		Name:      watchInFlightStreamsGaugeName,
+		Help:      "The number of in-flight Watch streams.",
+	})
+
+	watchStreamsCounterName = "watch_stream_count"
+
+	watchStreamsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      watchStreamsCounterName,
+		Help:      "The total number of Watch streams, labeled by the gRPC code they ended with.",
+	}, []string{"grpc_code"})
+)
+
+// Watch streams the tuple changes of the store of the request, as returned by ReadChanges, as they are written.
+// It returns when ctx is done or emit returns an error. Since emit is called synchronously, a consumer that is
+// slower than the writes is streamed the changes later rather than dropping any. Watch isn't served by the gRPC
+// server, so it enforces itself what the interceptors enforce for the other methods: it is rejected if disabled,
+// or beyond the maximum number of concurrent streams, and is logged and counted once it ends.
+func (s *Server) Watch(ctx context.Context, req *commands.WatchRequest, emit func(*commands.WatchEvent) error) error {
+	return s.watch(ctx, req, nil, emit)
+}
+
+// watch is Watch, calling started, if set, once the request is accepted and before any event is emitted, so that
+// the HTTP equivalent of Watch can respond to the requests it rejects with the status of their error.
+func (s *Server) watch(ctx context.Context, req *commands.WatchRequest, started func() error, emit func(*commands.WatchEvent) error) (err error) {
+	defer s.applyErrorVerbosity(&err)
+
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "Watch", trace.WithAttributes(
+		attribute.KeyValue{Key: "store_id", Value: attribute.StringValue(req.StoreID)},
+		attribute.KeyValue{Key: "type", Value: attribute.StringValue(req.ObjectType)},
+		attribute.KeyValue{Key: "relation", Value: attribute.StringValue(req.Relation)},
+	))
+	defer span.End()
+	defer func() {
+		s.reportWatch(ctx, req, time.Since(start), err)
+	}()
+
+	if !s.watchEnabled {
+		return status.Errorf(codes.Unimplemented, "method %s is disabled", disabledmethods.WatchMethod)
+	}
+
+	readChangesReq := &openfgav1.ReadChangesRequest{
+		StoreId:           req.StoreID,
+		Type:              req.ObjectType,
+		ContinuationToken: req.ContinuationToken,
+	}
+	if err := readChangesReq.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	select {
+	case s.watchStreams <- struct{}{}:
+	default:
+		return status.Errorf(codes.ResourceExhausted, "too many concurrent calls to method %s, the limit is %d", disabledmethods.WatchMethod, cap(s.watchStreams))
+	}
+	s.addWatchInFlightStreams(1)
+	defer func() {
+		s.addWatchInFlightStreams(-1)
+		<-s.watchStreams
+	}()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  disabledmethods.WatchMethod,
+	})
+
+	if _, err := s.datastore.GetStore(ctx, req.StoreID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return serverErrors.StoreIDNotFound
+		}
+		return serverErrors.HandleError("", err)
+	}
+
+	if started != nil {
+		if err := started(); err != nil {
+			return err
+		}
+	}
+
+	cmd := commands.NewWatchCommand(s.datastore,
+		commands.WithWatchEncoder(s.encoder),
+		commands.WithWatchHorizonOffset(s.changelogHorizonOffset),
+		commands.WithWatchPollInterval(s.watchPollInterval),
+	)
+	return cmd.Execute(ctx, req, emit)
+}
+
+// addWatchInFlightStreams adds delta to the number of Watch streams in flight, and sets the gauge to it.
+func (s *Server) addWatchInFlightStreams(delta int) {
+	s.watchInFlightStreamsMu.Lock()
+	defer s.watchInFlightStreamsMu.Unlock()
+
+	s.watchInFlightStreams += delta
+	s.metricsSink.SetGauge(watchInFlightStreamsGaugeName, float64(s.watchInFlightStreams), nil)
+}
+
+// reportWatch logs and counts a Watch stream once it ended, as the logging and metrics interceptors do for the
+// methods served by the gRPC server.
+func (s *Server) reportWatch(ctx context.Context, req *commands.WatchRequest, duration time.Duration, err error) {
+	s.metricsSink.IncCounter(watchStreamsCounterName, 1, map[string]string{"grpc_code": status.Code(err).String()})
+
+	fields := []zap.Field{
+		zap.String("grpc_service", s.serviceName),
+		zap.String("grpc_method", disabledmethods.WatchMethod),
+		zap.String("grpc_type", "server_stream"),
+		zap.String("store_id", req.StoreID),
+		zap.String("query_duration_ms", strconv.FormatInt(duration.Milliseconds(), 10)),
+		zap.Int32("grpc_code", serverErrors.ConvertToEncodedErrorCode(status.Convert(err))),
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		fields = append(fields, zap.String("trace_id", spanCtx.TraceID().String()))
+	}
+
+	if err != nil {
+		var internalError serverErrors.InternalError
+		if errors.As(err, &internalError) {
+			fields = append(fields, zap.String("internal_error", internalError.Internal().Error()))
+			s.logger.Error(err.Error(), fields...)
+			return
+		}
+		fields = append(fields, zap.Error(err))
+	}
+	s.logger.Info("grpc_req_complete", fields...)
+}
+
+// WatchHTTPHandler returns the HTTP equivalent of Watch, to serve at WatchPath. The events are streamed as
+// Server-Sent Events: 'changes' events and 'heartbeat' events, whose data is a ReadChangesResponse in JSON and
+// whose ID is its continuation token, and a last 'error' event if Watch fails once streaming. The requests that
+// Watch rejects are responded with the HTTP status of their error instead. The filters, the continuation token
+// and the heartbeat interval are read from the 'type', 'relation', 'continuation_token' and 'heartbeat_interval'
+// (e.g. '15s') query parameters, and the continuation token from the Last-Event-ID header when reconnecting. The
+// requests are authenticated with the authenticator, since they aren't served by the gRPC server.
+func (s *Server) WatchHTTPHandler(authenticator authn.Authenticator) func(http.ResponseWriter, *http.Request, map[string]string) {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx := metadata.NewIncomingContext(r.Context(), metadata.Pairs("authorization", r.Header.Get("Authorization")))
+		claims, err := authenticator.Authenticate(ctx)
+		if err != nil {
+			writeWatchHTTPError(w, err)
+			return
+		}
+		ctx = authn.ContextWithAuthClaims(ctx, claims)
+
+		query := r.URL.Query()
+		req := &commands.WatchRequest{
+			StoreID:           pathParams["store_id"],
+			ObjectType:        query.Get("type"),
+			Relation:          query.Get("relation"),
+			ContinuationToken: query.Get("continuation_token"),
+		}
+		if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+			req.ContinuationToken = lastEventID
+		}
+		if heartbeatInterval := query.Get("heartbeat_interval"); heartbeatInterval != "" {
+			if req.HeartbeatInterval, err = time.ParseDuration(heartbeatInterval); err != nil {
+				writeWatchHTTPError(w, status.Errorf(codes.InvalidArgument, "invalid heartbeat interval '%s'", heartbeatInterval))
+				return
+			}
+		}
+
+		controller := http.NewResponseController(w)
+		streaming := false
+		started := func() error {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			streaming = true
+			return controller.Flush()
+		}
+
+		err = s.watch(ctx, req, started, func(event *commands.WatchEvent) error {
+			data, err := protojson.Marshal(&openfgav1.ReadChangesResponse{
+				Changes:           event.Changes,
+				ContinuationToken: event.ContinuationToken,
+			})
+			if err != nil {
+				return err
+			}
+
+			name := "changes"
+			if len(event.Changes) == 0 {
+				name = "heartbeat"
+			}
+			return writeWatchHTTPEvent(controller, w, event.ContinuationToken, name, data)
+		})
+		if err == nil || r.Context().Err() != nil {
+			return
+		}
+		if !streaming {
+			writeWatchHTTPError(w, err)
+			return
+		}
+		_, data := encodeWatchHTTPError(err)
+		_ = writeWatchHTTPEvent(controller, w, "", "error", data)
+	}
+}
+
+// writeWatchHTTPEvent writes a Server-Sent Event and flushes it, failing if the consumer doesn't read it in time.
+func writeWatchHTTPEvent(controller *http.ResponseController, w http.ResponseWriter, id, name string, data []byte) error {
+	// not every ResponseWriter supports deadlines, in which case the write blocks until the consumer disconnects
+	_ = controller.SetWriteDeadline(time.Now().Add(watchWriteTimeout))
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return controller.Flush()
+}
+
+func writeWatchHTTPError(w http.ResponseWriter, err error) {
+	httpStatusCode, data := encodeWatchHTTPError(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusCode)
+	_, _ = w.Write(data)
+}
+
+// encodeWatchHTTPError returns the HTTP status code and the JSON body of the error, as the HTTP gateway does.
+func encodeWatchHTTPError(err error) (int, []byte) {
+	encodedErr := serverErrors.NewEncodedError(serverErrors.ConvertToEncodedErrorCode(status.Convert(err)), err.Error())
+	data, _ := json.Marshal(encodedErr.ActualError)
+	return encodedErr.HTTPStatusCode, data
+}
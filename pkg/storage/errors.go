@@ -38,8 +38,128 @@ var (
 
 	// ErrNotFound is returned when the object does not exist.
 	ErrNotFound = errors.New("not found")
+
+	// ErrStoreNotFound is returned when the store does not exist. It wraps ErrNotFound.
+	ErrStoreNotFound = fmt.Errorf("store %w", ErrNotFound)
+
+	// ErrWriteTooLarge is returned when a write exceeds the size limit of a statement of the datastore.
+	ErrWriteTooLarge = errors.New("write too large")
+
+	// ErrStoreNameConflict is returned when a store is created with the name of another store, and the
+	// names of the stores must be unique.
+	ErrStoreNameConflict = errors.New("store name conflict")
+
+	// ErrIdempotencyKeyExists is returned when a write uses the idempotency key of a write that was
+	// already applied.
+	ErrIdempotencyKeyExists = errors.New("idempotency key exists")
+
+	// ErrIndexRebuildInProgress is returned when the indexes are rebuilt while another rebuild is in progress.
+	ErrIndexRebuildInProgress = errors.New("index rebuild in progress")
+
+	// ErrModelQuotaExceeded is returned when writing an authorization model to a store that already has the
+	// maximum number of models. The old models of the store must be pruned first.
+	ErrModelQuotaExceeded = errors.New("authorization model quota exceeded")
+
+	// ErrDatastoreUnavailable is returned when a call can't be served because the datastore is unavailable,
+	// e.g. a write while the primary datastore is failed over to a standby.
+	ErrDatastoreUnavailable = errors.New("datastore unavailable")
 )
 
+// StoreNotFoundError is returned when an operation references a store that does not exist.
+// It matches both ErrStoreNotFound and ErrNotFound.
+type StoreNotFoundError struct {
+	StoreID string
+}
+
+func (e *StoreNotFoundError) Error() string {
+	return fmt.Sprintf("store '%s' not found", e.StoreID)
+}
+
+func (e *StoreNotFoundError) Unwrap() error {
+	return ErrStoreNotFound
+}
+
+// StoreNameConflictError is returned when a store is created with the name of another store, and the
+// names of the stores must be unique. It matches ErrStoreNameConflict.
+type StoreNameConflictError struct {
+	Name string
+}
+
+func (e *StoreNameConflictError) Error() string {
+	return fmt.Sprintf("a store named '%s' already exists", e.Name)
+}
+
+func (e *StoreNameConflictError) Unwrap() error {
+	return ErrStoreNameConflict
+}
+
+// IdempotencyKeyExistsError is returned when a write uses the idempotency key of a write that was already
+// applied, which Record describes. It matches ErrIdempotencyKeyExists.
+type IdempotencyKeyExistsError struct {
+	Record IdempotencyRecord
+}
+
+func (e *IdempotencyKeyExistsError) Error() string {
+	return fmt.Sprintf("a write with the idempotency key '%s' was already applied", e.Record.Key)
+}
+
+func (e *IdempotencyKeyExistsError) Unwrap() error {
+	return ErrIdempotencyKeyExists
+}
+
+// InvalidContinuationTokenError is returned when a continuation token is malformed, e.g. because it was
+// corrupted or tampered with. It matches ErrInvalidContinuationToken. Continuation tokens don't carry a
+// timestamp, so a well-formed token never expires.
+type InvalidContinuationTokenError struct {
+	Cause error
+}
+
+func (e *InvalidContinuationTokenError) Error() string {
+	return fmt.Sprintf("malformed continuation token: %v", e.Cause)
+}
+
+func (e *InvalidContinuationTokenError) Unwrap() []error {
+	return []error{ErrInvalidContinuationToken, e.Cause}
+}
+
+// WriteTooLargeError is returned when a statement of a write exceeds the size limit of the datastore,
+// e.g. MySQL's max_allowed_packet. It matches ErrWriteTooLarge. Limit is 0 if the limit is unknown,
+// Size is 0 if the datastore rejected the statement.
+type WriteTooLargeError struct {
+	Limit int
+	Size  int
+	Cause error
+}
+
+func (e *WriteTooLargeError) Error() string {
+	switch {
+	case e.Limit > 0 && e.Size > 0:
+		return fmt.Sprintf("write of %d bytes exceeds the datastore limit of %d bytes per statement, split it into smaller writes", e.Size, e.Limit)
+	case e.Limit > 0:
+		return fmt.Sprintf("write exceeds the datastore limit of %d bytes per statement, split it into smaller writes", e.Limit)
+	default:
+		return "write exceeds the datastore size limit, split it into smaller writes"
+	}
+}
+
+func (e *WriteTooLargeError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{ErrWriteTooLarge}
+	}
+	return []error{ErrWriteTooLarge, e.Cause}
+}
+
+// DisallowedObjectTypeError is returned when a model or a tuple uses an object type that isn't in the
+// AllowedObjectTypes of the [StoreSettings] of its store.
+type DisallowedObjectTypeError struct {
+	StoreID    string
+	ObjectType string
+}
+
+func (e *DisallowedObjectTypeError) Error() string {
+	return fmt.Sprintf("object type '%s' is not allowed in store '%s'", e.ObjectType, e.StoreID)
+}
+
 // ExceededMaxTypeDefinitionsLimitError constructs an error indicating that
 // the maximum allowed limit for type definitions has been exceeded.
 func ExceededMaxTypeDefinitionsLimitError(limit int) error {
@@ -2,14 +2,17 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/oklog/ulid/v2"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"go.opentelemetry.io/otel"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -129,14 +132,19 @@ type MemoryBackend struct {
 	// map: store => set of changes
 	changes map[string][]*openfgav1.TupleChange // GUARDED_BY(mutexTuples).
 
+	// map: store => idempotency key => idempotency record
+	idempotencyRecords map[string]map[string]storage.IdempotencyRecord // GUARDED_BY(mutexTuples).
+
 	// AuthorizationModelBackend
 	// map: store = > map: type definition id => type definition
 	authorizationModels map[string]map[string]*AuthorizationModelEntry // GUARDED_BY(mutexModels).
 	mutexModels         sync.RWMutex
 
 	// map: store id => store data
-	stores      map[string]*openfgav1.Store // GUARDED_BY(mutexStores).
-	mutexStores sync.RWMutex
+	stores map[string]*openfgav1.Store // GUARDED_BY(mutexStores).
+	// map: store id => store settings
+	storeSettings map[string]*storage.StoreSettings // GUARDED_BY(mutexStores).
+	mutexStores   sync.RWMutex
 
 	// map: store id | authz model id => assertions
 	assertions      map[string][]*openfgav1.Assertion // GUARDED_BY(mutexAssertions).
@@ -144,7 +152,12 @@ type MemoryBackend struct {
 }
 
 // Ensures that [MemoryBackend] implements the [storage.OpenFGADatastore] interface.
-var _ storage.OpenFGADatastore = (*MemoryBackend)(nil)
+var (
+	_ storage.OpenFGADatastore         = (*MemoryBackend)(nil)
+	_ storage.TransactionalBatchWriter = (*MemoryBackend)(nil)
+	_ storage.IdempotentWriter         = (*MemoryBackend)(nil)
+	_ storage.AuthorizationModelPruner = (*MemoryBackend)(nil)
+)
 
 // AuthorizationModelEntry represents an entry in a storage system
 // that holds information about an authorization model.
@@ -160,8 +173,10 @@ func New(opts ...StorageOption) storage.OpenFGADatastore {
 		maxTypesPerAuthorizationModel: defaultMaxTypesPerAuthorizationModel,
 		tuples:                        make(map[string][]*storage.TupleRecord, 0),
 		changes:                       make(map[string][]*openfgav1.TupleChange, 0),
+		idempotencyRecords:            make(map[string]map[string]storage.IdempotencyRecord, 0),
 		authorizationModels:           make(map[string]map[string]*AuthorizationModelEntry),
 		stores:                        make(map[string]*openfgav1.Store, 0),
+		storeSettings:                 make(map[string]*storage.StoreSettings, 0),
 		assertions:                    make(map[string][]*openfgav1.Assertion, 0),
 	}
 
@@ -218,21 +233,19 @@ func (s *MemoryBackend) ReadChanges(ctx context.Context, store, objectType strin
 	s.mutexTuples.RLock()
 	defer s.mutexTuples.RUnlock()
 
-	var err error
-	var from int64
-	var typeInToken string
+	var concreteToken, typeInToken string
 	var continuationToken string
 	if options.Pagination.From != "" {
-		tokens := strings.Split(options.Pagination.From, "|")
-		if len(tokens) == 2 {
-			concreteToken := tokens[0]
-			typeInToken = tokens[1]
-			from, err = strconv.ParseInt(concreteToken, 10, 32)
-			if err != nil {
-				return nil, nil, err
-			}
+		var found bool
+		concreteToken, typeInToken, found = strings.Cut(options.Pagination.From, "|")
+		if !found {
+			return nil, nil, &storage.InvalidContinuationTokenError{Cause: errors.New("missing object type")}
 		}
 	}
+	from, err := parseContinuationToken(concreteToken, math.MaxInt)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if typeInToken != "" && typeInToken != objectType {
 		return nil, nil, storage.ErrMismatchObjectType
@@ -256,7 +269,25 @@ func (s *MemoryBackend) ReadChanges(ctx context.Context, store, objectType strin
 	if options.Pagination.PageSize > 0 {
 		pageSize = options.Pagination.PageSize
 	}
-	to := int(from) + pageSize
+
+	if options.SortDesc {
+		// the token is the end of the next page, which is stable as changes are appended
+		to := len(allChanges)
+		if concreteToken != "" {
+			to = min(from, len(allChanges))
+		}
+		from = max(0, to-pageSize)
+		res := slices.Clone(allChanges[from:to])
+		if len(res) == 0 {
+			return nil, nil, storage.ErrNotFound
+		}
+		slices.Reverse(res)
+		return res, []byte(fmt.Sprintf("%d|%s", from, objectType)), nil
+	}
+
+	from = min(from, len(allChanges))
+
+	to := from + pageSize
 	if len(allChanges) < to {
 		to = len(allChanges)
 	}
@@ -274,6 +305,23 @@ func (s *MemoryBackend) ReadChanges(ctx context.Context, store, objectType strin
 	return res, []byte(continuationToken), nil
 }
 
+// parseContinuationToken returns the offset in a list of n items encoded in a continuation token, if any. An
+// offset past the end of the list, e.g. because items were deleted since the token was issued, is the end of the list.
+func parseContinuationToken(token string, n int) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	from, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, &storage.InvalidContinuationTokenError{Cause: err}
+	}
+	if from < 0 {
+		return 0, &storage.InvalidContinuationTokenError{Cause: fmt.Errorf("negative offset %d", from)}
+	}
+	return min(from, n), nil
+}
+
 // read returns an iterator of a store's tuples with a given tuple as filter.
 // A nil paginationOptions input means the returned iterator will iterate through all values.
 func (s *MemoryBackend) read(ctx context.Context, store string, tk *openfgav1.TupleKey, options *storage.ReadPageOptions) (*staticIterator, error) {
@@ -297,17 +345,14 @@ func (s *MemoryBackend) read(ctx context.Context, store string, tk *openfgav1.Tu
 
 	var err error
 	var from int
-	if options != nil && options.Pagination.From != "" {
-		from, err = strconv.Atoi(options.Pagination.From)
+	if options != nil {
+		from, err = parseContinuationToken(options.Pagination.From, len(matches))
 		if err != nil {
 			telemetry.TraceError(span, err)
 			return nil, err
 		}
 	}
-
-	if from <= len(matches) {
-		matches = matches[from:]
-	}
+	matches = matches[from:]
 
 	to := 0 // fetch everything
 	if options != nil {
@@ -325,26 +370,135 @@ func (s *MemoryBackend) Write(ctx context.Context, store string, deletes storage
 	_, span := tracer.Start(ctx, "memory.Write")
 	defer span.End()
 
+	return s.writeBatches(store, nil, []storage.TupleBatch{{Deletes: deletes, Writes: writes}})
+}
+
+// WriteBatches see [storage.TransactionalBatchWriter].WriteBatches.
+func (s *MemoryBackend) WriteBatches(ctx context.Context, store string, batches []storage.TupleBatch) error {
+	_, span := tracer.Start(ctx, "memory.WriteBatches")
+	defer span.End()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > s.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	return s.writeBatches(store, nil, batches)
+}
+
+// WriteBatchesIdempotently see [storage.IdempotentWriter].WriteBatchesIdempotently.
+func (s *MemoryBackend) WriteBatchesIdempotently(ctx context.Context, store string, record storage.IdempotencyRecord, batches []storage.TupleBatch) error {
+	_, span := tracer.Start(ctx, "memory.WriteBatchesIdempotently")
+	defer span.End()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > s.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	return s.writeBatches(store, &record, batches)
+}
+
+// ReadTupleCountsByRelation see [storage.TupleCountsByRelationReader].ReadTupleCountsByRelation.
+// The counts are always exact.
+func (s *MemoryBackend) ReadTupleCountsByRelation(ctx context.Context, store string, _ storage.TupleCountsByRelationOptions) ([]storage.RelationTupleCount, error) {
+	_, span := tracer.Start(ctx, "memory.ReadTupleCountsByRelation")
+	defer span.End()
+
+	s.mutexTuples.RLock()
+	defer s.mutexTuples.RUnlock()
+
+	indexes := map[[2]string]int{}
+	var counts []storage.RelationTupleCount
+	for _, record := range s.tuples[store] {
+		key := [2]string{record.ObjectType, record.Relation}
+		i, ok := indexes[key]
+		if !ok {
+			i = len(counts)
+			indexes[key] = i
+			counts = append(counts, storage.RelationTupleCount{ObjectType: record.ObjectType, Relation: record.Relation})
+		}
+		counts[i].Count++
+	}
+
+	return counts, nil
+}
+
+// writeBatches applies the batches to the tuples and changes of the store, which are only updated
+// if all the batches are valid.
+func (s *MemoryBackend) writeBatches(store string, record *storage.IdempotencyRecord, batches []storage.TupleBatch) error {
 	s.mutexTuples.Lock()
 	defer s.mutexTuples.Unlock()
 
 	now := timestamppb.Now()
 
-	if err := validateTuples(s.tuples[store], deletes, writes); err != nil {
-		return err
+	if record != nil {
+		if existing, ok := s.idempotencyRecords[store][record.Key]; ok && existing.ExpiresAt.After(now.AsTime()) {
+			return &storage.IdempotencyKeyExistsError{Record: existing}
+		}
+	}
+
+	records := s.tuples[store]
+	// clipped so that appending never modifies the changes of the store in place
+	changes := slices.Clip(s.changes[store])
+	for _, batch := range batches {
+		var err error
+		records, changes, err = applyTupleBatch(store, records, changes, batch, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.tuples[store] = records
+	s.changes[store] = changes
+
+	if record != nil {
+		s.recordIdempotencyKey(store, *record, now.AsTime())
+	}
+	return nil
+}
+
+// recordIdempotencyKey stores the record, after deleting the expired records of the store.
+func (s *MemoryBackend) recordIdempotencyKey(store string, record storage.IdempotencyRecord, now time.Time) {
+	records, ok := s.idempotencyRecords[store]
+	if !ok {
+		records = make(map[string]storage.IdempotencyRecord)
+		s.idempotencyRecords[store] = records
+	}
+	for key, r := range records {
+		if !r.ExpiresAt.After(now) {
+			delete(records, key)
+		}
 	}
+	records[record.Key] = record
+}
 
-	var records []*storage.TupleRecord
+// applyTupleBatch returns the records and changes that result from applying the batch to the given ones.
+// The given records are not modified.
+func applyTupleBatch(
+	store string,
+	records []*storage.TupleRecord,
+	changes []*openfgav1.TupleChange,
+	batch storage.TupleBatch,
+	now *timestamppb.Timestamp,
+) ([]*storage.TupleRecord, []*openfgav1.TupleChange, error) {
+	if err := validateTuples(records, batch.Deletes, batch.Writes); err != nil {
+		return nil, nil, err
+	}
+
+	var newRecords []*storage.TupleRecord
 Delete:
-	for _, tr := range s.tuples[store] {
+	for _, tr := range records {
 		t := tr.AsTuple()
 		tk := t.GetKey()
-		for _, k := range deletes {
+		for _, k := range batch.Deletes {
 			if match(tr, tupleUtils.TupleKeyWithoutConditionToTupleKey(k)) {
-				s.changes[store] = append(
-					s.changes[store],
+				changes = append(
+					changes,
 					&openfgav1.TupleChange{
-						TupleKey:  tupleUtils.NewTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()), // Redact the condition info.
+						TupleKey:  tk,
 						Operation: openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
 						Timestamp: now,
 					},
@@ -352,12 +506,15 @@ Delete:
 				continue Delete
 			}
 		}
-		records = append(records, tr)
+		newRecords = append(newRecords, tr)
 	}
 
+	ulids := storage.NewULIDGenerator()
+	defer ulids.Release()
+
 Write:
-	for _, t := range writes {
-		for _, et := range records {
+	for _, t := range batch.Writes {
+		for _, et := range newRecords {
 			if match(et, t) {
 				continue Write
 			}
@@ -372,7 +529,7 @@ Write:
 
 		objectType, objectID := tupleUtils.SplitObject(t.GetObject())
 
-		records = append(records, &storage.TupleRecord{
+		newRecords = append(newRecords, &storage.TupleRecord{
 			Store:            store,
 			ObjectType:       objectType,
 			ObjectID:         objectID,
@@ -380,7 +537,7 @@ Write:
 			User:             t.GetUser(),
 			ConditionName:    conditionName,
 			ConditionContext: conditionContext,
-			Ulid:             ulid.MustNew(ulid.Timestamp(now.AsTime()), ulid.DefaultEntropy()).String(),
+			Ulid:             ulids.New(now.AsTime()).String(),
 			InsertedAt:       now.AsTime(),
 		})
 
@@ -392,14 +549,13 @@ Write:
 			conditionContext,
 		)
 
-		s.changes[store] = append(s.changes[store], &openfgav1.TupleChange{
+		changes = append(changes, &openfgav1.TupleChange{
 			TupleKey:  tk,
 			Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
 			Timestamp: now,
 		})
 	}
-	s.tuples[store] = records
-	return nil
+	return newRecords, changes, nil
 }
 
 func validateTuples(
@@ -598,23 +754,19 @@ func (s *MemoryBackend) ReadAuthorizationModels(ctx context.Context, store strin
 		return models[i].GetId() > models[j].GetId()
 	})
 
-	var from int64
 	continuationToken := ""
-	var err error
 
 	pageSize := storage.DefaultPageSize
 	if options.Pagination.PageSize > 0 {
 		pageSize = options.Pagination.PageSize
 	}
 
-	if options.Pagination.From != "" {
-		from, err = strconv.ParseInt(options.Pagination.From, 10, 32)
-		if err != nil {
-			return nil, nil, err
-		}
+	from, err := parseContinuationToken(options.Pagination.From, len(models))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	to := int(from) + pageSize
+	to := from + pageSize
 	if len(models) < to {
 		to = len(models)
 	}
@@ -674,6 +826,39 @@ func (s *MemoryBackend) WriteAuthorizationModel(ctx context.Context, store strin
 	return nil
 }
 
+// PruneAuthorizationModels see [storage.AuthorizationModelPruner].PruneAuthorizationModels.
+func (s *MemoryBackend) PruneAuthorizationModels(ctx context.Context, store string, retain int, keep []string) (int, error) {
+	_, span := tracer.Start(ctx, "memory.PruneAuthorizationModels")
+	defer span.End()
+
+	s.mutexModels.Lock()
+	defer s.mutexModels.Unlock()
+	s.mutexAssertions.Lock()
+	defer s.mutexAssertions.Unlock()
+
+	modelIDs := make([]string, 0, len(s.authorizationModels[store]))
+	for id := range s.authorizationModels[store] {
+		modelIDs = append(modelIDs, id)
+	}
+
+	// From newest to oldest.
+	sort.Sort(sort.Reverse(sort.StringSlice(modelIDs)))
+
+	pruned := 0
+	for _, id := range modelIDs[min(max(retain, 1), len(modelIDs)):] {
+		assertionsID := fmt.Sprintf("%s|%s", store, id)
+		if s.authorizationModels[store][id].latest || len(s.assertions[assertionsID]) > 0 || slices.Contains(keep, id) {
+			continue
+		}
+
+		delete(s.authorizationModels[store], id)
+		delete(s.assertions, assertionsID)
+		pruned++
+	}
+
+	return pruned, nil
+}
+
 // CreateStore adds a new store to the [MemoryBackend].
 func (s *MemoryBackend) CreateStore(ctx context.Context, newStore *openfgav1.Store) (*openfgav1.Store, error) {
 	_, span := tracer.Start(ctx, "memory.CreateStore")
@@ -697,6 +882,35 @@ func (s *MemoryBackend) CreateStore(ctx context.Context, newStore *openfgav1.Sto
 	return s.stores[newStore.GetId()], nil
 }
 
+// CreateStoreWithUniqueName see [storage.UniqueStoreNameCreator].CreateStoreWithUniqueName.
+func (s *MemoryBackend) CreateStoreWithUniqueName(ctx context.Context, newStore *openfgav1.Store) (*openfgav1.Store, error) {
+	_, span := tracer.Start(ctx, "memory.CreateStoreWithUniqueName")
+	defer span.End()
+
+	s.mutexStores.Lock()
+	defer s.mutexStores.Unlock()
+
+	for _, store := range s.stores {
+		if store.GetName() == newStore.GetName() {
+			return nil, &storage.StoreNameConflictError{Name: newStore.GetName()}
+		}
+	}
+
+	if _, ok := s.stores[newStore.GetId()]; ok {
+		return nil, storage.ErrCollision
+	}
+
+	now := timestamppb.New(time.Now().UTC())
+	s.stores[newStore.GetId()] = &openfgav1.Store{
+		Id:        newStore.GetId(),
+		Name:      newStore.GetName(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	return s.stores[newStore.GetId()], nil
+}
+
 // DeleteStore removes a store from the [MemoryBackend].
 func (s *MemoryBackend) DeleteStore(ctx context.Context, id string) error {
 	_, span := tracer.Start(ctx, "memory.DeleteStore")
@@ -706,9 +920,48 @@ func (s *MemoryBackend) DeleteStore(ctx context.Context, id string) error {
 	defer s.mutexStores.Unlock()
 
 	delete(s.stores, id)
+	delete(s.storeSettings, id)
+	return nil
+}
+
+// ReadStoreSettings see [storage.StoreSettingsBackend].ReadStoreSettings.
+func (s *MemoryBackend) ReadStoreSettings(ctx context.Context, store string) (*storage.StoreSettings, error) {
+	_, span := tracer.Start(ctx, "memory.ReadStoreSettings")
+	defer span.End()
+
+	s.mutexStores.RLock()
+	defer s.mutexStores.RUnlock()
+
+	settings, ok := s.storeSettings[store]
+	if !ok {
+		return &storage.StoreSettings{}, nil
+	}
+	return cloneStoreSettings(settings), nil
+}
+
+// WriteStoreSettings see [storage.StoreSettingsBackend].WriteStoreSettings.
+func (s *MemoryBackend) WriteStoreSettings(ctx context.Context, store string, settings *storage.StoreSettings) error {
+	_, span := tracer.Start(ctx, "memory.WriteStoreSettings")
+	defer span.End()
+
+	s.mutexStores.Lock()
+	defer s.mutexStores.Unlock()
+
+	if s.stores[store] == nil {
+		return &storage.StoreNotFoundError{StoreID: store}
+	}
+
+	s.storeSettings[store] = cloneStoreSettings(settings)
 	return nil
 }
 
+func cloneStoreSettings(settings *storage.StoreSettings) *storage.StoreSettings {
+	clone := *settings
+	clone.AllowedObjectTypes = slices.Clone(settings.AllowedObjectTypes)
+	clone.RelationAliases = maps.Clone(settings.RelationAliases)
+	return &clone
+}
+
 // WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
 func (s *MemoryBackend) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
 	_, span := tracer.Start(ctx, "memory.WriteAssertions")
@@ -758,7 +1011,7 @@ func (s *MemoryBackend) GetStore(ctx context.Context, storeID string) (*openfgav
 	defer s.mutexStores.RUnlock()
 
 	if s.stores[storeID] == nil {
-		return nil, storage.ErrNotFound
+		return nil, &storage.StoreNotFoundError{StoreID: storeID}
 	}
 
 	return s.stores[storeID], nil
@@ -782,19 +1035,15 @@ func (s *MemoryBackend) ListStores(ctx context.Context, options storage.ListStor
 		return stores[i].GetId() < stores[j].GetId()
 	})
 
-	var err error
-	var from int64
-	if options.Pagination.From != "" {
-		from, err = strconv.ParseInt(options.Pagination.From, 10, 32)
-		if err != nil {
-			return nil, nil, err
-		}
+	from, err := parseContinuationToken(options.Pagination.From, len(stores))
+	if err != nil {
+		return nil, nil, err
 	}
 	pageSize := storage.DefaultPageSize
 	if options.Pagination.PageSize > 0 {
 		pageSize = options.Pagination.PageSize
 	}
-	to := int(from) + pageSize
+	to := from + pageSize
 	if len(stores) < to {
 		to = len(stores)
 	}
@@ -1,7 +1,10 @@
 package memory
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"math/rand"
 	"strconv"
 	"testing"
 	"time"
@@ -347,3 +350,96 @@ func TestFindTupleKey(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteWithInjectedULIDEntropy(t *testing.T) {
+	writeAndCollectEntropy := func(t *testing.T) [][]byte {
+		restore := storage.SetULIDEntropy(ulid.Monotonic(rand.New(rand.NewSource(42)), 0))
+		t.Cleanup(restore)
+
+		ds := New()
+		t.Cleanup(ds.Close)
+
+		err := ds.Write(context.Background(), "store", nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			tuple.NewTupleKey("document:2", "viewer", "user:jon"),
+			tuple.NewTupleKey("document:3", "viewer", "user:jon"),
+		})
+		require.NoError(t, err)
+
+		var entropy [][]byte
+		for _, record := range ds.(*MemoryBackend).tuples["store"] {
+			entropy = append(entropy, ulid.MustParse(record.Ulid).Entropy())
+		}
+		return entropy
+	}
+
+	first := writeAndCollectEntropy(t)
+	second := writeAndCollectEntropy(t)
+
+	require.Len(t, first, 3)
+	require.Equal(t, first, second)
+	for i := 1; i < len(first); i++ {
+		// all tuples of one write share a timestamp, so the monotonic source must increment the entropy
+		require.Positive(t, bytes.Compare(first[i], first[i-1]))
+	}
+}
+
+func TestWriteULIDsAreOrderedWithTheDefaultEntropy(t *testing.T) {
+	ds := New()
+	t.Cleanup(ds.Close)
+
+	var writes []*openfgav1.TupleKey
+	for i := 0; i < 50; i++ {
+		writes = append(writes, tuple.NewTupleKey("document:"+strconv.Itoa(i), "viewer", "user:jon"))
+	}
+	require.NoError(t, ds.Write(context.Background(), "store", nil, writes))
+
+	records := ds.(*MemoryBackend).tuples["store"]
+	require.Len(t, records, len(writes))
+	for i := 1; i < len(records); i++ {
+		require.Less(t, records[i-1].Ulid, records[i].Ulid)
+	}
+}
+
+func FuzzContinuationTokens(f *testing.F) {
+	ctx := context.Background()
+	ds := New()
+	store := ulid.Make().String()
+	_, err := ds.CreateStore(ctx, &openfgav1.Store{Id: store, Name: "fuzz"})
+	require.NoError(f, err)
+	require.NoError(f, ds.WriteAuthorizationModel(ctx, store, &openfgav1.AuthorizationModel{Id: ulid.Make().String(), SchemaVersion: "1.1"}))
+	require.NoError(f, ds.Write(ctx, store, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:2", "viewer", "user:anne"),
+	}))
+
+	for _, seed := range []string{"", "0", "1", "2|document", "-1", "-1|", "99999999999999999999", "|", "1|folder", "\x00", "{\"ulid\":\"\"}"} {
+		f.Add(seed)
+	}
+
+	// each operation must either succeed or fail with an error that the server reports gracefully
+	requireGracefulError := func(t *testing.T, err error) {
+		if err == nil || errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrMismatchObjectType) {
+			return
+		}
+		var tokenErr *storage.InvalidContinuationTokenError
+		require.ErrorAs(t, err, &tokenErr)
+		require.ErrorIs(t, err, storage.ErrInvalidContinuationToken)
+	}
+
+	f.Fuzz(func(t *testing.T, token string) {
+		pagination := storage.NewPaginationOptions(1, token)
+
+		_, _, err := ds.ReadPage(ctx, store, &openfgav1.TupleKey{Object: "document:"}, storage.ReadPageOptions{Pagination: pagination})
+		requireGracefulError(t, err)
+
+		_, _, err = ds.ReadChanges(ctx, store, "document", storage.ReadChangesOptions{Pagination: pagination}, 0)
+		requireGracefulError(t, err)
+
+		_, _, err = ds.ReadAuthorizationModels(ctx, store, storage.ReadAuthorizationModelsOptions{Pagination: pagination})
+		requireGracefulError(t, err)
+
+		_, _, err = ds.ListStores(ctx, storage.ListStoresOptions{Pagination: pagination})
+		requireGracefulError(t, err)
+	})
+}
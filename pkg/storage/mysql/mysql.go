@@ -22,6 +22,7 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/openfga/openfga/assets"
 	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/storage/sqlcommon"
@@ -39,13 +40,50 @@ type MySQL struct {
 	dbStatsCollector       prometheus.Collector
 	maxTuplesPerWriteField int
 	maxTypesPerModelField  int
+	readTimeout            time.Duration
+	writeTimeout           time.Duration
+	// writeBatcher is nil if the writes are not batched
+	writeBatcher            *sqlcommon.WriteBatcher
+	idempotencyRecordPurger *sqlcommon.IdempotencyRecordPurger
+	// replicas is nil if there are no read replicas
+	replicas *readReplicas
 }
 
 // Ensures that MySQL implements the OpenFGADatastore interface.
-var _ storage.OpenFGADatastore = (*MySQL)(nil)
+var (
+	_ storage.OpenFGADatastore           = (*MySQL)(nil)
+	_ storage.TransactionalBatchWriter   = (*MySQL)(nil)
+	_ storage.IdempotentWriter           = (*MySQL)(nil)
+	_ storage.AuthorizationModelPruner   = (*MySQL)(nil)
+	_ storage.ConditionContextKeyRotator = (*MySQL)(nil)
+	_ storage.IndexRebuilder             = (*MySQL)(nil)
+)
 
-// New creates a new [MySQL] storage.
+// New creates a new [MySQL] storage. The credentials of the uri and of the config are redacted from the errors
+// returned.
 func New(uri string, cfg *sqlcommon.Config) (*MySQL, error) {
+	db, err := open(uri, cfg)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewWithDB(db, cfg)
+	if err != nil {
+		return nil, sqlcommon.RedactCredentials(err, uri, cfg.Password)
+	}
+	return m, nil
+}
+
+// open opens a connection to uri, overriding its credentials with the ones of the config, if any. The
+// credentials are redacted from the errors returned.
+func open(uri string, cfg *sqlcommon.Config) (*sql.DB, error) {
+	db, err := openWithCredentials(uri, cfg)
+	if err != nil {
+		return nil, sqlcommon.RedactCredentials(err, uri, cfg.Password)
+	}
+	return db, nil
+}
+
+func openWithCredentials(uri string, cfg *sqlcommon.Config) (*sql.DB, error) {
 	if cfg.Username != "" || cfg.Password != "" {
 		dsnCfg, err := mysql.ParseDSN(uri)
 		if err != nil {
@@ -66,11 +104,11 @@ func New(uri string, cfg *sqlcommon.Config) (*MySQL, error) {
 	if err != nil {
 		return nil, fmt.Errorf("initialize mysql connection: %w", err)
 	}
-	return NewWithDB(db, cfg)
+	setConnectionLimits(db, cfg)
+	return db, nil
 }
 
-// NewWithDB creates a new [MySQL] storage with the provided database connection.
-func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*MySQL, error) {
+func setConnectionLimits(db *sql.DB, cfg *sqlcommon.Config) {
 	if cfg.MaxOpenConns != 0 {
 		db.SetMaxOpenConns(cfg.MaxOpenConns)
 	}
@@ -86,6 +124,13 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*MySQL, error) {
 	if cfg.ConnMaxLifetime != 0 {
 		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 	}
+}
+
+// NewWithDB creates a new [MySQL] storage with the provided database connection. Tuple reads that don't
+// request HIGHER_CONSISTENCY are sent to the read replicas of the config, if any, in round-robin. If none
+// of them is healthy, they are sent to db.
+func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*MySQL, error) {
+	setConnectionLimits(db, cfg)
 
 	policy := backoff.NewExponentialBackOff()
 	policy.MaxElapsedTime = 1 * time.Minute
@@ -103,6 +148,10 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*MySQL, error) {
 		return nil, fmt.Errorf("ping db: %w", err)
 	}
 
+	if err := sqlcommon.MigrateSchema(db, "mysql", assets.MySQLMigrationDir, cfg.AutoMigrate); err != nil {
+		return nil, err
+	}
+
 	var collector prometheus.Collector
 	if cfg.ExportMetrics {
 		collector = collectors.NewDBStatsCollector(db, "openfga")
@@ -111,34 +160,102 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*MySQL, error) {
 		}
 	}
 
-	stbl := sq.StatementBuilder.RunWith(db)
-	dbInfo := sqlcommon.NewDBInfo(db, stbl, sq.Expr("NOW()"))
+	var replicas *readReplicas
+	if len(cfg.ReadReplicaURIs) > 0 {
+		replicaDBs := make([]*sql.DB, 0, len(cfg.ReadReplicaURIs))
+		for _, uri := range cfg.ReadReplicaURIs {
+			replicaDB, err := open(uri, cfg)
+			if err != nil {
+				for _, replicaDB := range replicaDBs {
+					replicaDB.Close()
+				}
+				return nil, fmt.Errorf("open read replica: %w", err)
+			}
+			replicaDBs = append(replicaDBs, replicaDB)
+		}
+		replicas = newReadReplicas(replicaDBs, cfg.Logger)
+	}
+
+	// statements above max_allowed_packet are rejected by the server, so writes are checked against it
+	maxStatementSize := cfg.MaxStatementSizeInBytes
+	if maxStatementSize == 0 {
+		if err := db.QueryRowContext(context.Background(), "SELECT @@max_allowed_packet").Scan(&maxStatementSize); err != nil {
+			cfg.Logger.Warn("failed to read max_allowed_packet", zap.Error(err))
+		}
+	}
+
+	stbl := sq.StatementBuilder.RunWith(sqlcommon.NewRetryingRunner(db, cfg.Logger))
+	dbInfo := sqlcommon.NewDBInfo(db, stbl, sq.Expr("NOW()")).
+		WithMaxStatementSize(maxStatementSize, cfg.SplitLargeWrites).
+		WithConditionContextCipher(cfg.ConditionContextCipher)
+
+	var writeBatcher *sqlcommon.WriteBatcher
+	if cfg.ChangelogBatchDelay > 0 {
+		writeBatcher = sqlcommon.NewWriteBatcher(dbInfo, cfg.ChangelogBatchDelay, cfg.ChangelogBatchMaxWrites, cfg.ChangelogBatchMaxConcurrentGroups, cfg.WriteTimeout)
+	}
 
 	return &MySQL{
-		stbl:                   stbl,
-		db:                     db,
-		dbInfo:                 dbInfo,
-		logger:                 cfg.Logger,
-		dbStatsCollector:       collector,
-		maxTuplesPerWriteField: cfg.MaxTuplesPerWriteField,
-		maxTypesPerModelField:  cfg.MaxTypesPerModelField,
+		stbl:                    stbl,
+		db:                      db,
+		dbInfo:                  dbInfo,
+		logger:                  cfg.Logger,
+		dbStatsCollector:        collector,
+		maxTuplesPerWriteField:  cfg.MaxTuplesPerWriteField,
+		maxTypesPerModelField:   cfg.MaxTypesPerModelField,
+		readTimeout:             cfg.ReadTimeout,
+		writeTimeout:            cfg.WriteTimeout,
+		writeBatcher:            writeBatcher,
+		idempotencyRecordPurger: sqlcommon.NewIdempotencyRecordPurger(dbInfo, cfg.Logger),
+		replicas:                replicas,
 	}, nil
 }
 
 // Close see [storage.OpenFGADatastore].Close.
 func (m *MySQL) Close() {
+	if m.writeBatcher != nil {
+		m.writeBatcher.Close()
+	}
+	m.idempotencyRecordPurger.Close()
 	if m.dbStatsCollector != nil {
 		prometheus.Unregister(m.dbStatsCollector)
 	}
+	if m.replicas != nil {
+		m.replicas.close()
+	}
 	m.db.Close()
 }
 
+// tupleReadBuilder returns the statement builder to read tuples of the store with: the one of a healthy
+// read replica, unless HIGHER_CONSISTENCY is requested, in which case reads are pinned to the primary.
+// If the context has a minimum changelog token, only the replicas that have replicated it are used.
+func (m *MySQL) tupleReadBuilder(ctx context.Context, store string, consistency storage.ConsistencyOptions) sq.StatementBuilderType {
+	if m.replicas == nil || consistency.Preference == openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY {
+		return m.stbl
+	}
+
+	if token, ok := storage.MinChangelogTokenFromContext(ctx); ok {
+		contToken, err := sqlcommon.UnmarshallContToken(token)
+		if err != nil {
+			return m.stbl
+		}
+		if stbl, ok := m.replicas.pickIncluding(ctx, store, contToken.Ulid); ok {
+			return stbl
+		}
+		return m.stbl
+	}
+
+	if stbl, ok := m.replicas.pick(); ok {
+		return stbl
+	}
+	return m.stbl
+}
+
 // Read see [storage.RelationshipTupleReader].Read.
 func (m *MySQL) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
 	ctx, span := tracer.Start(ctx, "mysql.Read")
 	defer span.End()
 
-	return m.read(ctx, store, tupleKey, nil)
+	return m.read(ctx, m.tupleReadBuilder(ctx, store, options.Consistency), store, tupleKey, nil)
 }
 
 // ReadPage see [storage.RelationshipTupleReader].ReadPage.
@@ -146,23 +263,23 @@ func (m *MySQL) ReadPage(ctx context.Context, store string, tupleKey *openfgav1.
 	ctx, span := tracer.Start(ctx, "mysql.ReadPage")
 	defer span.End()
 
-	iter, err := m.read(ctx, store, tupleKey, &options)
+	iter, err := m.read(ctx, m.tupleReadBuilder(ctx, store, options.Consistency), store, tupleKey, &options)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer iter.Stop()
 
-	return iter.ToArray(options.Pagination)
+	return iter.ToArray(ctx, options.Pagination)
 }
 
-func (m *MySQL) read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, opts *storage.ReadPageOptions) (*sqlcommon.SQLTupleIterator, error) {
+func (m *MySQL) read(ctx context.Context, stbl sq.StatementBuilderType, store string, tupleKey *openfgav1.TupleKey, opts *storage.ReadPageOptions) (*sqlcommon.SQLTupleIterator, error) {
 	ctx, span := tracer.Start(ctx, "mysql.read")
 	defer span.End()
 
-	sb := m.stbl.
+	sb := stbl.
 		Select(
 			"store", "object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context", "ulid", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
 		).
 		From("tuple").
 		Where(sq.Eq{"store": store})
@@ -193,12 +310,14 @@ func (m *MySQL) read(ctx context.Context, store string, tupleKey *openfgav1.Tupl
 		sb = sb.Limit(uint64(opts.Pagination.PageSize + 1)) // + 1 is used to determine whether to return a continuation token.
 	}
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, m.readTimeout)
 	rows, err := sb.QueryContext(ctx)
 	if err != nil {
+		cancel()
 		return nil, sqlcommon.HandleSQLError(err, m.logger)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(rows), nil
+	return sqlcommon.NewSQLTupleIterator(rows, m.dbInfo).WithCancel(cancel), nil
 }
 
 // Write see [storage.RelationshipTupleWriter].Write.
@@ -206,30 +325,91 @@ func (m *MySQL) Write(ctx context.Context, store string, deletes storage.Deletes
 	ctx, span := tracer.Start(ctx, "mysql.Write")
 	defer span.End()
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, m.writeTimeout)
+	defer cancel()
+
 	if len(deletes)+len(writes) > m.MaxTuplesPerWrite() {
 		return storage.ErrExceededWriteBatchLimit
 	}
 
+	if m.writeBatcher != nil {
+		return m.writeBatcher.WriteBatches(ctx, store, []storage.TupleBatch{{Deletes: deletes, Writes: writes}})
+	}
+
 	now := time.Now().UTC()
 
 	return sqlcommon.Write(ctx, m.dbInfo, store, deletes, writes, now)
 }
 
+// WriteBatches see [storage.TransactionalBatchWriter].WriteBatches.
+func (m *MySQL) WriteBatches(ctx context.Context, store string, batches []storage.TupleBatch) error {
+	ctx, span := tracer.Start(ctx, "mysql.WriteBatches")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, m.writeTimeout)
+	defer cancel()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > m.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	if m.writeBatcher != nil {
+		return m.writeBatcher.WriteBatches(ctx, store, batches)
+	}
+
+	now := time.Now().UTC()
+
+	return sqlcommon.WriteBatches(ctx, m.dbInfo, store, batches, now)
+}
+
+// WriteBatchesIdempotently see [storage.IdempotentWriter].WriteBatchesIdempotently.
+func (m *MySQL) WriteBatchesIdempotently(ctx context.Context, store string, record storage.IdempotencyRecord, batches []storage.TupleBatch) error {
+	ctx, span := tracer.Start(ctx, "mysql.WriteBatchesIdempotently")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, m.writeTimeout)
+	defer cancel()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > m.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	now := time.Now().UTC()
+
+	return sqlcommon.WriteBatchesIdempotently(ctx, m.dbInfo, store, record, batches, now)
+}
+
+// ReadTupleCountsByRelation see [storage.TupleCountsByRelationReader].ReadTupleCountsByRelation.
+// MySQL can't sample the blocks of a table, so the counts are always exact.
+func (m *MySQL) ReadTupleCountsByRelation(ctx context.Context, store string, _ storage.TupleCountsByRelationOptions) ([]storage.RelationTupleCount, error) {
+	ctx, span := tracer.Start(ctx, "mysql.ReadTupleCountsByRelation")
+	defer span.End()
+
+	return sqlcommon.ReadTupleCountsByRelation(ctx, m.dbInfo, "tuple", store, 1)
+}
+
 // ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
-func (m *MySQL) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, _ storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+func (m *MySQL) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
 	ctx, span := tracer.Start(ctx, "mysql.ReadUserTuple")
 	defer span.End()
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, m.readTimeout)
+	defer cancel()
+
 	objectType, objectID := tupleUtils.SplitObject(tupleKey.GetObject())
 	userType := tupleUtils.GetUserTypeFromUser(tupleKey.GetUser())
 
-	var conditionName sql.NullString
+	var conditionName, conditionContextKeyID sql.NullString
 	var conditionContext []byte
 	var record storage.TupleRecord
-	err := m.stbl.
+	err := m.tupleReadBuilder(ctx, store, options.Consistency).
 		Select(
 			"object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context",
+			"condition_name", "condition_context", "condition_context_key_id",
 		).
 		From("tuple").
 		Where(sq.Eq{
@@ -248,6 +428,7 @@ func (m *MySQL) ReadUserTuple(ctx context.Context, store string, tupleKey *openf
 			&record.User,
 			&conditionName,
 			&conditionContext,
+			&conditionContextKeyID,
 		)
 	if err != nil {
 		return nil, sqlcommon.HandleSQLError(err, m.logger)
@@ -256,12 +437,9 @@ func (m *MySQL) ReadUserTuple(ctx context.Context, store string, tupleKey *openf
 	if conditionName.String != "" {
 		record.ConditionName = conditionName.String
 
-		if conditionContext != nil {
-			var conditionContextStruct structpb.Struct
-			if err := proto.Unmarshal(conditionContext, &conditionContextStruct); err != nil {
-				return nil, err
-			}
-			record.ConditionContext = &conditionContextStruct
+		record.ConditionContext, err = m.dbInfo.UnmarshalConditionContext(ctx, store, tupleKey, conditionContextKeyID, conditionContext)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -273,15 +451,15 @@ func (m *MySQL) ReadUsersetTuples(
 	ctx context.Context,
 	store string,
 	filter storage.ReadUsersetTuplesFilter,
-	_ storage.ReadUsersetTuplesOptions,
+	options storage.ReadUsersetTuplesOptions,
 ) (storage.TupleIterator, error) {
 	ctx, span := tracer.Start(ctx, "mysql.ReadUsersetTuples")
 	defer span.End()
 
-	sb := m.stbl.
+	sb := m.tupleReadBuilder(ctx, store, options.Consistency).
 		Select(
 			"store", "object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context", "ulid", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
 		).
 		From("tuple").
 		Where(sq.Eq{"store": store}).
@@ -309,12 +487,14 @@ func (m *MySQL) ReadUsersetTuples(
 		}
 		sb = sb.Where(orConditions)
 	}
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, m.readTimeout)
 	rows, err := sb.QueryContext(ctx)
 	if err != nil {
+		cancel()
 		return nil, sqlcommon.HandleSQLError(err, m.logger)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(rows), nil
+	return sqlcommon.NewSQLTupleIterator(rows, m.dbInfo).WithCancel(cancel), nil
 }
 
 // ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
@@ -322,7 +502,7 @@ func (m *MySQL) ReadStartingWithUser(
 	ctx context.Context,
 	store string,
 	opts storage.ReadStartingWithUserFilter,
-	_ storage.ReadStartingWithUserOptions,
+	options storage.ReadStartingWithUserOptions,
 ) (storage.TupleIterator, error) {
 	ctx, span := tracer.Start(ctx, "mysql.ReadStartingWithUser")
 	defer span.End()
@@ -336,10 +516,10 @@ func (m *MySQL) ReadStartingWithUser(
 		targetUsersArg = append(targetUsersArg, targetUser)
 	}
 
-	builder := m.stbl.
+	builder := m.tupleReadBuilder(ctx, store, options.Consistency).
 		Select(
 			"store", "object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context", "ulid", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
 		).
 		From("tuple").
 		Where(sq.Eq{
@@ -353,12 +533,14 @@ func (m *MySQL) ReadStartingWithUser(
 		builder = builder.Where(sq.Eq{"object_id": opts.ObjectIDs.Values()})
 	}
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, m.readTimeout)
 	rows, err := builder.QueryContext(ctx)
 	if err != nil {
+		cancel()
 		return nil, sqlcommon.HandleSQLError(err, m.logger)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(rows), nil
+	return sqlcommon.NewSQLTupleIterator(rows, m.dbInfo).WithCancel(cancel), nil
 }
 
 // MaxTuplesPerWrite see [storage.RelationshipTupleWriter].MaxTuplesPerWrite.
@@ -496,6 +678,14 @@ func (m *MySQL) CreateStore(ctx context.Context, store *openfgav1.Store) (*openf
 	}, nil
 }
 
+// CreateStoreWithUniqueName see [storage.UniqueStoreNameCreator].CreateStoreWithUniqueName.
+func (m *MySQL) CreateStoreWithUniqueName(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
+	ctx, span := tracer.Start(ctx, "mysql.CreateStoreWithUniqueName")
+	defer span.End()
+
+	return sqlcommon.CreateStoreWithUniqueName(ctx, m.dbInfo, store)
+}
+
 // GetStore retrieves the details of a specific store from the MySQL using its storeID.
 func (m *MySQL) GetStore(ctx context.Context, id string) (*openfgav1.Store, error) {
 	ctx, span := tracer.Start(ctx, "mysql.GetStore")
@@ -515,7 +705,7 @@ func (m *MySQL) GetStore(ctx context.Context, id string) (*openfgav1.Store, erro
 	err := row.Scan(&storeID, &name, &createdAt, &updatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, storage.ErrNotFound
+			return nil, &storage.StoreNotFoundError{StoreID: id}
 		}
 		return nil, sqlcommon.HandleSQLError(err, m.logger)
 	}
@@ -597,6 +787,7 @@ func (m *MySQL) DeleteStore(ctx context.Context, id string) error {
 	_, err := m.stbl.
 		Update("store").
 		Set("deleted_at", sq.Expr("NOW()")).
+		Set("unique_name", nil). // frees the name for a new store
 		Where(sq.Eq{"id": id}).
 		ExecContext(ctx)
 	if err != nil {
@@ -606,6 +797,101 @@ func (m *MySQL) DeleteStore(ctx context.Context, id string) error {
 	return nil
 }
 
+// PruneAuthorizationModels see [storage.AuthorizationModelPruner].PruneAuthorizationModels.
+func (m *MySQL) PruneAuthorizationModels(ctx context.Context, store string, retain int, keep []string) (int, error) {
+	ctx, span := tracer.Start(ctx, "mysql.PruneAuthorizationModels")
+	defer span.End()
+
+	return sqlcommon.PruneAuthorizationModels(ctx, m.dbInfo, store, retain, keep)
+}
+
+// RotateConditionContextKeys see [storage.ConditionContextKeyRotator].RotateConditionContextKeys. The condition
+// contexts are encrypted with the cipher set by [sqlcommon.WithConditionContextCipher].
+func (m *MySQL) RotateConditionContextKeys(ctx context.Context, store string) (int, error) {
+	ctx, span := tracer.Start(ctx, "mysql.RotateConditionContextKeys")
+	defer span.End()
+
+	return sqlcommon.RotateConditionContextKeys(ctx, m.dbInfo, store)
+}
+
+// rebuildIndexesLockName is the name of the lock held while the indexes are rebuilt.
+const rebuildIndexesLockName = "openfga_rebuild_indexes"
+
+// RebuildIndexes see [storage.IndexRebuilder].RebuildIndexes. It runs OPTIMIZE TABLE on the tuple and changelog
+// tables, which InnoDB runs as an online rebuild of each table: the reads and writes of the table are only
+// blocked briefly, at the start and at the end of its rebuild.
+func (m *MySQL) RebuildIndexes(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "mysql.RebuildIndexes")
+	defer span.End()
+
+	// the lock belongs to the session, so it's taken and released on the same connection
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return sqlcommon.HandleSQLError(err, m.logger)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", rebuildIndexesLockName).Scan(&locked); err != nil {
+		return sqlcommon.HandleSQLError(err, m.logger)
+	}
+	if locked.Int64 != 1 {
+		return storage.ErrIndexRebuildInProgress
+	}
+	defer func() {
+		// if ctx is canceled the connection is discarded, which releases the lock too
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", rebuildIndexesLockName)
+	}()
+
+	for _, table := range []string{"tuple", "changelog"} {
+		if err := m.optimizeTable(ctx, conn, table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// optimizeTable runs OPTIMIZE TABLE on the table. The failures are reported in its result rows, not as errors.
+func (m *MySQL) optimizeTable(ctx context.Context, conn *sql.Conn, table string) error {
+	rows, err := conn.QueryContext(ctx, "OPTIMIZE TABLE "+table)
+	if err != nil {
+		return sqlcommon.HandleSQLError(err, m.logger)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, op, msgType, msgText string
+		if err := rows.Scan(&name, &op, &msgType, &msgText); err != nil {
+			return sqlcommon.HandleSQLError(err, m.logger)
+		}
+		if msgType == "error" {
+			return fmt.Errorf("optimize table %s: %s", table, msgText)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return sqlcommon.HandleSQLError(err, m.logger)
+	}
+
+	return nil
+}
+
+// ReadStoreSettings see [storage.StoreSettingsBackend].ReadStoreSettings.
+func (m *MySQL) ReadStoreSettings(ctx context.Context, store string) (*storage.StoreSettings, error) {
+	ctx, span := tracer.Start(ctx, "mysql.ReadStoreSettings")
+	defer span.End()
+
+	return sqlcommon.ReadStoreSettings(ctx, m.dbInfo, store)
+}
+
+// WriteStoreSettings see [storage.StoreSettingsBackend].WriteStoreSettings.
+func (m *MySQL) WriteStoreSettings(ctx context.Context, store string, settings *storage.StoreSettings) error {
+	ctx, span := tracer.Start(ctx, "mysql.WriteStoreSettings")
+	defer span.End()
+
+	return sqlcommon.WriteStoreSettings(ctx, m.dbInfo, store, settings)
+}
+
 // WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
 func (m *MySQL) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
 	ctx, span := tracer.Start(ctx, "mysql.WriteAssertions")
@@ -668,12 +954,12 @@ func (m *MySQL) ReadChanges(ctx context.Context, store, objectTypeFilter string,
 	sb := m.stbl.
 		Select(
 			"ulid", "object_type", "object_id", "relation", "_user", "operation",
-			"condition_name", "condition_context", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "inserted_at",
 		).
 		From("changelog").
 		Where(sq.Eq{"store": store}).
 		Where(fmt.Sprintf("inserted_at <= NOW() - INTERVAL %d MICROSECOND", horizonOffset.Microseconds())).
-		OrderBy("ulid asc")
+		OrderBy(sqlcommon.ChangelogOrderBy(options))
 
 	if objectTypeFilter != "" {
 		sb = sb.Where(sq.Eq{"object_type": objectTypeFilter})
@@ -687,7 +973,11 @@ func (m *MySQL) ReadChanges(ctx context.Context, store, objectTypeFilter string,
 			return nil, nil, storage.ErrMismatchObjectType
 		}
 
-		sb = sb.Where(sq.Gt{"ulid": token.Ulid}) // > as we always return a continuation token.
+		if options.SortDesc {
+			sb = sb.Where(sq.Lt{"ulid": token.Ulid})
+		} else {
+			sb = sb.Where(sq.Gt{"ulid": token.Ulid}) // > as we always return a continuation token.
+		}
 	}
 	if options.Pagination.PageSize > 0 {
 		sb = sb.Limit(uint64(options.Pagination.PageSize)) // + 1 is NOT used here as we always return a continuation token.
@@ -705,7 +995,7 @@ func (m *MySQL) ReadChanges(ctx context.Context, store, objectTypeFilter string,
 		var objectType, objectID, relation, user string
 		var operation int
 		var insertedAt time.Time
-		var conditionName sql.NullString
+		var conditionName, conditionContextKeyID sql.NullString
 		var conditionContext []byte
 
 		err = rows.Scan(
@@ -717,27 +1007,28 @@ func (m *MySQL) ReadChanges(ctx context.Context, store, objectTypeFilter string,
 			&operation,
 			&conditionName,
 			&conditionContext,
+			&conditionContextKeyID,
 			&insertedAt,
 		)
 		if err != nil {
 			return nil, nil, sqlcommon.HandleSQLError(err, m.logger)
 		}
 
-		var conditionContextStruct structpb.Struct
-		if conditionName.String != "" {
-			if conditionContext != nil {
-				if err := proto.Unmarshal(conditionContext, &conditionContextStruct); err != nil {
-					return nil, nil, err
-				}
+		tk := tupleUtils.NewTupleKey(tupleUtils.BuildObject(objectType, objectID), relation, user)
+		conditionContextStruct := &structpb.Struct{}
+		if conditionName.String != "" && conditionContext != nil {
+			conditionContextStruct, err = m.dbInfo.UnmarshalConditionContext(ctx, store, tk, conditionContextKeyID, conditionContext)
+			if err != nil {
+				return nil, nil, err
 			}
 		}
 
-		tk := tupleUtils.NewTupleKeyWithCondition(
-			tupleUtils.BuildObject(objectType, objectID),
+		tk = tupleUtils.NewTupleKeyWithCondition(
+			tk.GetObject(),
 			relation,
 			user,
 			conditionName.String,
-			&conditionContextStruct,
+			conditionContextStruct,
 		)
 
 		changes = append(changes, &openfgav1.TupleChange{
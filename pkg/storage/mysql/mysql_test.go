@@ -2,15 +2,19 @@ package mysql
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/oklog/ulid/v2"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/pressly/goose/v3"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/openfga/openfga/assets"
+	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/storage/sqlcommon"
 	"github.com/openfga/openfga/pkg/storage/test"
@@ -428,3 +432,306 @@ func TestMarshalledAssertions(t *testing.T) {
 	}
 	require.Equal(t, expectedAssertions, assertions)
 }
+
+// TestReadReplicas asserts that tuple reads are sent to the read replicas unless HIGHER_CONSISTENCY
+// is requested. The replica is simulated by a separate, empty database, i.e. a replica that lags
+// indefinitely behind the primary.
+func TestReadReplicas(t *testing.T) {
+	primary := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+	replica := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+
+	ctx := context.Background()
+	store := ulid.Make().String()
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:jon")
+
+	t.Run("reads_from_lagging_replica_unless_higher_consistency", func(t *testing.T) {
+		ds, err := New(primary.GetConnectionURI(true), sqlcommon.NewConfig(
+			sqlcommon.WithReadReplicaURIs(replica.GetConnectionURI(true)),
+		))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		require.NoError(t, ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tk}))
+
+		_, err = ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		got, err := ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{
+			Consistency: storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY},
+		})
+		require.NoError(t, err)
+		require.Equal(t, tk.GetObject(), got.GetKey().GetObject())
+
+		iter, err := ds.Read(ctx, store, tk, storage.ReadOptions{})
+		require.NoError(t, err)
+		_, err = iter.Next(ctx)
+		iter.Stop()
+		require.ErrorIs(t, err, storage.ErrIteratorDone)
+
+		iter, err = ds.Read(ctx, store, tk, storage.ReadOptions{
+			Consistency: storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY},
+		})
+		require.NoError(t, err)
+		_, err = iter.Next(ctx)
+		iter.Stop()
+		require.NoError(t, err)
+	})
+
+	t.Run("routes_to_primary_if_replica_is_behind_min_changelog_token", func(t *testing.T) {
+		ds, err := New(primary.GetConnectionURI(true), sqlcommon.NewConfig(
+			sqlcommon.WithReadReplicaURIs(replica.GetConnectionURI(true)),
+		))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		_, token, err := ds.ReadChanges(ctx, store, "", storage.ReadChangesOptions{
+			Pagination: storage.NewPaginationOptions(storage.DefaultPageSize, ""),
+		}, 0)
+		require.NoError(t, err)
+
+		tokenCtx := storage.ContextWithMinChangelogToken(ctx, string(token))
+
+		_, err = ds.ReadUserTuple(tokenCtx, store, tk, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+
+		// once the replica has the changes up to the token, it is used again
+		replicaDS, err := New(replica.GetConnectionURI(true), sqlcommon.NewConfig())
+		require.NoError(t, err)
+		defer replicaDS.Close()
+
+		replicaOnly := tuple.NewTupleKey("document:2", "viewer", "user:jon")
+		require.NoError(t, replicaDS.Write(ctx, store, nil, []*openfgav1.TupleKey{replicaOnly}))
+
+		_, err = ds.ReadUserTuple(tokenCtx, store, replicaOnly, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+	})
+
+	t.Run("falls_back_to_primary_if_no_replica_is_healthy", func(t *testing.T) {
+		ds, err := New(primary.GetConnectionURI(true), sqlcommon.NewConfig(
+			sqlcommon.WithReadReplicaURIs("root:secret@tcp(127.0.0.1:1)/defaultdb?parseTime=true"),
+		))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		_, err = ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+	})
+}
+
+// TestReadReplicaLag asserts that a replica that lags behind a store's changelog is only queried for its
+// position once per replicaPositionTTL, and is used again once it has caught up.
+func TestReadReplicaLag(t *testing.T) {
+	ctx := context.Background()
+	store := ulid.Make().String()
+	older, newer := ulid.Make().String(), ulid.Make().String()
+
+	replica := newReadReplica(nil, logger.NewNoopLogger())
+	now := time.Now()
+	replica.now = func() time.Time { return now }
+
+	var queries int
+	replicated := older
+	replica.latestULID = func(context.Context, string) (string, error) {
+		queries++
+		return replicated, nil
+	}
+
+	require.True(t, replica.includes(ctx, store, older))
+	require.Equal(t, 1, queries)
+
+	// the known position is enough for the entries that are already replicated
+	require.True(t, replica.includes(ctx, store, older))
+	require.Equal(t, 1, queries)
+
+	// the replica lags, and isn't queried again until its position is stale
+	require.False(t, replica.includes(ctx, store, newer))
+	require.Equal(t, 1, queries)
+	now = now.Add(replicaPositionTTL)
+	require.False(t, replica.includes(ctx, store, newer))
+	require.Equal(t, 2, queries)
+	require.False(t, replica.includes(ctx, store, newer))
+	require.Equal(t, 2, queries)
+
+	// once caught up, the replica is used after its position expired
+	replicated = newer
+	require.False(t, replica.includes(ctx, store, newer))
+	now = now.Add(replicaPositionTTL)
+	require.True(t, replica.includes(ctx, store, newer))
+	require.Equal(t, 3, queries)
+
+	// failed queries are never cached
+	replica.latestULID = func(context.Context, string) (string, error) {
+		queries++
+		return "", context.DeadlineExceeded
+	}
+	otherStore := ulid.Make().String()
+	require.False(t, replica.includes(ctx, otherStore, older))
+	require.False(t, replica.includes(ctx, otherStore, older))
+	require.Equal(t, 5, queries)
+}
+
+func TestWriteTooLarge(t *testing.T) {
+	ctx := context.Background()
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+	uri := testDatastore.GetConnectionURI(true)
+
+	writes := make([]*openfgav1.TupleKey, 0, 20)
+	for i := 0; i < 20; i++ {
+		writes = append(writes, tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:jon"))
+	}
+
+	t.Run("is_rejected", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithMaxStatementSize(512)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		store := ulid.Make().String()
+		err = ds.Write(ctx, store, nil, writes)
+		var tooLargeErr *storage.WriteTooLargeError
+		require.ErrorAs(t, err, &tooLargeErr)
+		require.Equal(t, 512, tooLargeErr.Limit)
+
+		// the transaction is rolled back
+		_, err = ds.ReadUserTuple(ctx, store, writes[0], storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("is_split", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithMaxStatementSize(512), sqlcommon.WithSplitLargeWrites()))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		store := ulid.Make().String()
+		require.NoError(t, ds.Write(ctx, store, nil, writes))
+
+		changes, _, err := ds.ReadChanges(ctx, store, "", storage.ReadChangesOptions{
+			Pagination: storage.NewPaginationOptions(100, ""),
+		}, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, len(writes))
+	})
+}
+
+func TestAutoMigrate(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+	uri := testDatastore.GetConnectionURI(true)
+
+	db, err := goose.OpenDBWithDriver("mysql", uri)
+	require.NoError(t, err)
+	defer db.Close()
+
+	goose.SetBaseFS(assets.EmbedMigrations)
+	require.NoError(t, goose.DownTo(db, assets.MySQLMigrationDir, 1))
+
+	t.Run("refuses_to_start_on_schema_mismatch", func(t *testing.T) {
+		_, err := New(uri, sqlcommon.NewConfig())
+		require.ErrorContains(t, err, "datastore requires migrations: at revision '1'")
+	})
+
+	t.Run("applies_the_migrations", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithAutoMigrate(true)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		version, err := goose.GetDBVersion(db)
+		require.NoError(t, err)
+		require.Equal(t, testDatastore.GetDatabaseSchemaVersion(), version)
+
+		ds, err = New(uri, sqlcommon.NewConfig())
+		require.NoError(t, err)
+		ds.Close()
+	})
+}
+
+func TestReadAndWriteTimeouts(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+	uri := testDatastore.GetConnectionURI(true)
+
+	ctx := context.Background()
+	store := ulid.Make().String()
+	tk := tuple.NewTupleKey("doc:1", "viewer", "user:anne")
+
+	// a timeout of a nanosecond has expired by the time the query is sent
+	const expired = time.Nanosecond
+
+	t.Run("read_timeout_applies_to_reads_only", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithReadTimeout(expired)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		err = ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tk})
+		require.NoError(t, err)
+
+		_, err = ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, _, err = ds.ReadPage(ctx, store, tuple.NewTupleKey("doc:", "", ""), storage.ReadPageOptions{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, err = ds.ReadStartingWithUser(ctx, store, storage.ReadStartingWithUserFilter{
+			ObjectType: "doc",
+			Relation:   "viewer",
+			UserFilter: []*openfgav1.ObjectRelation{{Object: "user:anne"}},
+		}, storage.ReadStartingWithUserOptions{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// models are not bound by the timeout of the reads of tuples
+		_, err = ds.FindLatestAuthorizationModel(ctx, store)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("write_timeout_applies_to_writes_only", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithWriteTimeout(expired)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		err = ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tuple.NewTupleKey("doc:2", "viewer", "user:anne")})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		err = ds.WriteBatches(ctx, store, []storage.TupleBatch{{Writes: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:2", "viewer", "user:anne")}}})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		got, err := ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+		require.Equal(t, tk.GetObject(), got.GetKey().GetObject())
+
+		_, err = ds.ReadUserTuple(ctx, store, tuple.NewTupleKey("doc:2", "viewer", "user:anne"), storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("iterators_are_readable_until_stopped", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithReadTimeout(time.Minute)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		iter, err := ds.Read(ctx, store, tuple.NewTupleKey("doc:", "", ""), storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		got, err := iter.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, tk.GetObject(), got.GetKey().GetObject())
+	})
+}
+
+func TestConditionContextEncryption(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+	uri := testDatastore.GetConnectionURI(true)
+
+	test.ConditionContextEncryptionTest(t, func(t *testing.T, cipher sqlcommon.ConditionContextCipher) storage.OpenFGADatastore {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithConditionContextCipher(cipher)))
+		require.NoError(t, err)
+		return ds
+	})
+}
+
+func TestChangelogBatching(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+	uri := testDatastore.GetConnectionURI(true)
+
+	test.ChangelogBatchingTest(t, func(t *testing.T, maxDelay time.Duration, maxWrites int) storage.OpenFGADatastore {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithChangelogBatching(maxDelay, maxWrites)))
+		require.NoError(t, err)
+		return ds
+	})
+}
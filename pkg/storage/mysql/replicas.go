@@ -0,0 +1,191 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+)
+
+const (
+	// replicaHealthCheckInterval is how often the read replicas are pinged to decide whether reads can be sent to them.
+	replicaHealthCheckInterval = 10 * time.Second
+	replicaHealthCheckTimeout  = 2 * time.Second
+
+	// replicaPositionTTL is how long the position of a replica that is behind is trusted before querying it
+	// again, so that a lagging replica isn't queried on every read.
+	replicaPositionTTL = 500 * time.Millisecond
+)
+
+type readReplica struct {
+	db      *sql.DB
+	stbl    sq.StatementBuilderType
+	healthy atomic.Bool
+
+	// positions holds, per store, the most recent changelog ulid known to be replicated, as a *replicaPosition.
+	// Replicas only move forward, so it can be used without querying the replica again for older ulids.
+	positions sync.Map
+
+	// latestULID queries the most recent changelog ulid of the store replicated to the replica.
+	latestULID func(ctx context.Context, store string) (string, error)
+	now        func() time.Time
+}
+
+type replicaPosition struct {
+	ulid      string
+	checkedAt time.Time
+}
+
+func newReadReplica(db *sql.DB, logger logger.Logger) *readReplica {
+	r := &readReplica{
+		db:   db,
+		stbl: sq.StatementBuilder.RunWith(sqlcommon.NewRetryingRunner(db, logger)),
+		now:  time.Now,
+	}
+	r.latestULID = r.queryLatestULID
+	return r
+}
+
+// includes returns whether the replica has replicated the changelog entry with the given ulid of the store.
+// A replica that was behind is only queried again once its position is older than replicaPositionTTL.
+func (r *readReplica) includes(ctx context.Context, store, ulid string) bool {
+	if value, ok := r.positions.Load(store); ok {
+		position := value.(*replicaPosition)
+		if position.ulid >= ulid {
+			return true
+		}
+		if r.now().Sub(position.checkedAt) < replicaPositionTTL {
+			return false
+		}
+	}
+
+	checkedAt := r.now()
+	latest, err := r.latestULID(ctx, store)
+	if err != nil {
+		return false
+	}
+
+	r.positions.Store(store, &replicaPosition{ulid: latest, checkedAt: checkedAt})
+	return latest >= ulid
+}
+
+func (r *readReplica) queryLatestULID(ctx context.Context, store string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, replicaHealthCheckTimeout)
+	defer cancel()
+
+	var latest sql.NullString
+	err := r.stbl.
+		Select("MAX(ulid)").
+		From("changelog").
+		Where(sq.Eq{"store": store}).
+		QueryRowContext(ctx).
+		Scan(&latest)
+	if err != nil {
+		return "", err
+	}
+	return latest.String, nil
+}
+
+// readReplicas selects, in round-robin, the read replica to run a tuple read on among the ones that
+// passed their last health check. Health checks run in the background until close is called.
+type readReplicas struct {
+	replicas []*readReplica
+	next     atomic.Uint64
+	logger   logger.Logger
+
+	// ctx is canceled on close, which stops the health checks, including the ones in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newReadReplicas(dbs []*sql.DB, logger logger.Logger) *readReplicas {
+	r := &readReplicas{
+		logger: logger,
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	for _, db := range dbs {
+		r.replicas = append(r.replicas, newReadReplica(db, logger))
+	}
+
+	r.checkHealth()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(replicaHealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+				r.checkHealth()
+			}
+		}
+	}()
+
+	return r
+}
+
+// checkHealth pings every replica, and marks the ones that respond in time as healthy.
+func (r *readReplicas) checkHealth() {
+	for i, replica := range r.replicas {
+		ctx, cancel := context.WithTimeout(r.ctx, replicaHealthCheckTimeout)
+		err := replica.db.PingContext(ctx)
+		cancel()
+		if r.ctx.Err() != nil {
+			// closing, the replica may be healthy
+			return
+		}
+
+		if wasHealthy := replica.healthy.Swap(err == nil); wasHealthy != (err == nil) {
+			if err != nil {
+				r.logger.Warn("mysql read replica is unhealthy", zap.Int("replica", i), zap.Error(err))
+			} else {
+				r.logger.Info("mysql read replica is healthy", zap.Int("replica", i))
+			}
+		}
+	}
+}
+
+// pick returns the statement builder of the next healthy replica, or false if none is healthy.
+func (r *readReplicas) pick() (sq.StatementBuilderType, bool) {
+	for range r.replicas {
+		replica := r.replicas[r.next.Add(1)%uint64(len(r.replicas))]
+		if replica.healthy.Load() {
+			return replica.stbl, true
+		}
+	}
+	return sq.StatementBuilderType{}, false
+}
+
+// pickIncluding is like pick, but skips the replicas that haven't replicated the changelog entry
+// with the given ulid of the store yet.
+func (r *readReplicas) pickIncluding(ctx context.Context, store, ulid string) (sq.StatementBuilderType, bool) {
+	for range r.replicas {
+		replica := r.replicas[r.next.Add(1)%uint64(len(r.replicas))]
+		if replica.healthy.Load() && replica.includes(ctx, store, ulid) {
+			return replica.stbl, true
+		}
+	}
+	return sq.StatementBuilderType{}, false
+}
+
+// close stops the health checks and closes the connections to the replicas.
+func (r *readReplicas) close() {
+	r.cancel()
+	r.wg.Wait()
+
+	for _, replica := range r.replicas {
+		replica.db.Close()
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/openfga/openfga/assets"
 	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/storage/sqlcommon"
@@ -39,13 +40,34 @@ type Postgres struct {
 	dbStatsCollector       prometheus.Collector
 	maxTuplesPerWriteField int
 	maxTypesPerModelField  int
+	readTimeout            time.Duration
+	writeTimeout           time.Duration
+	// writeBatcher is nil if the writes are not batched
+	writeBatcher            *sqlcommon.WriteBatcher
+	idempotencyRecordPurger *sqlcommon.IdempotencyRecordPurger
 }
 
 // Ensures that Postgres implements the OpenFGADatastore interface.
-var _ storage.OpenFGADatastore = (*Postgres)(nil)
+var (
+	_ storage.OpenFGADatastore           = (*Postgres)(nil)
+	_ storage.TransactionalBatchWriter   = (*Postgres)(nil)
+	_ storage.IdempotentWriter           = (*Postgres)(nil)
+	_ storage.AuthorizationModelPruner   = (*Postgres)(nil)
+	_ storage.ConditionContextKeyRotator = (*Postgres)(nil)
+	_ storage.IndexRebuilder             = (*Postgres)(nil)
+)
 
-// New creates a new [Postgres] storage.
+// New creates a new [Postgres] storage. The credentials of the uri and of the config are redacted from the
+// errors returned.
 func New(uri string, cfg *sqlcommon.Config) (*Postgres, error) {
+	p, err := newPostgres(uri, cfg)
+	if err != nil {
+		return nil, sqlcommon.RedactCredentials(err, uri, cfg.Password)
+	}
+	return p, nil
+}
+
+func newPostgres(uri string, cfg *sqlcommon.Config) (*Postgres, error) {
 	if cfg.Username != "" || cfg.Password != "" {
 		parsed, err := url.Parse(uri)
 		if err != nil {
@@ -116,6 +138,10 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Postgres, error) {
 		return nil, fmt.Errorf("ping db: %w", err)
 	}
 
+	if err := sqlcommon.MigrateSchema(db, "postgres", assets.PostgresMigrationDir, cfg.AutoMigrate); err != nil {
+		return nil, err
+	}
+
 	var collector prometheus.Collector
 	if cfg.ExportMetrics {
 		collector = collectors.NewDBStatsCollector(db, "openfga")
@@ -123,22 +149,37 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Postgres, error) {
 			return nil, fmt.Errorf("initialize metrics: %w", err)
 		}
 	}
-	stbl := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).RunWith(db)
-	dbInfo := sqlcommon.NewDBInfo(db, stbl, sq.Expr("NOW()"))
+	stbl := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).RunWith(sqlcommon.NewRetryingRunner(db, cfg.Logger))
+	dbInfo := sqlcommon.NewDBInfo(db, stbl, sq.Expr("NOW()")).
+		WithMaxStatementSize(cfg.MaxStatementSizeInBytes, cfg.SplitLargeWrites).
+		WithConditionContextCipher(cfg.ConditionContextCipher)
+
+	var writeBatcher *sqlcommon.WriteBatcher
+	if cfg.ChangelogBatchDelay > 0 {
+		writeBatcher = sqlcommon.NewWriteBatcher(dbInfo, cfg.ChangelogBatchDelay, cfg.ChangelogBatchMaxWrites, cfg.ChangelogBatchMaxConcurrentGroups, cfg.WriteTimeout)
+	}
 
 	return &Postgres{
-		stbl:                   stbl,
-		db:                     db,
-		dbInfo:                 dbInfo,
-		logger:                 cfg.Logger,
-		dbStatsCollector:       collector,
-		maxTuplesPerWriteField: cfg.MaxTuplesPerWriteField,
-		maxTypesPerModelField:  cfg.MaxTypesPerModelField,
+		stbl:                    stbl,
+		db:                      db,
+		dbInfo:                  dbInfo,
+		logger:                  cfg.Logger,
+		dbStatsCollector:        collector,
+		maxTuplesPerWriteField:  cfg.MaxTuplesPerWriteField,
+		maxTypesPerModelField:   cfg.MaxTypesPerModelField,
+		readTimeout:             cfg.ReadTimeout,
+		writeTimeout:            cfg.WriteTimeout,
+		writeBatcher:            writeBatcher,
+		idempotencyRecordPurger: sqlcommon.NewIdempotencyRecordPurger(dbInfo, cfg.Logger),
 	}, nil
 }
 
 // Close see [storage.OpenFGADatastore].Close.
 func (p *Postgres) Close() {
+	if p.writeBatcher != nil {
+		p.writeBatcher.Close()
+	}
+	p.idempotencyRecordPurger.Close()
 	if p.dbStatsCollector != nil {
 		prometheus.Unregister(p.dbStatsCollector)
 	}
@@ -164,7 +205,7 @@ func (p *Postgres) ReadPage(ctx context.Context, store string, tupleKey *openfga
 	}
 	defer iter.Stop()
 
-	return iter.ToArray(options.Pagination)
+	return iter.ToArray(ctx, options.Pagination)
 }
 
 func (p *Postgres) read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, opts *storage.ReadPageOptions) (*sqlcommon.SQLTupleIterator, error) {
@@ -174,7 +215,7 @@ func (p *Postgres) read(ctx context.Context, store string, tupleKey *openfgav1.T
 	sb := p.stbl.
 		Select(
 			"store", "object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context", "ulid", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
 		).
 		From("tuple").
 		Where(sq.Eq{"store": store})
@@ -206,12 +247,14 @@ func (p *Postgres) read(ctx context.Context, store string, tupleKey *openfgav1.T
 		sb = sb.Limit(uint64(opts.Pagination.PageSize + 1)) // + 1 is used to determine whether to return a continuation token.
 	}
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, p.readTimeout)
 	rows, err := sb.QueryContext(ctx)
 	if err != nil {
+		cancel()
 		return nil, sqlcommon.HandleSQLError(err, p.logger)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(rows), nil
+	return sqlcommon.NewSQLTupleIterator(rows, p.dbInfo).WithCancel(cancel), nil
 }
 
 // Write see [storage.RelationshipTupleWriter].Write.
@@ -219,30 +262,100 @@ func (p *Postgres) Write(ctx context.Context, store string, deletes storage.Dele
 	ctx, span := tracer.Start(ctx, "postgres.Write")
 	defer span.End()
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, p.writeTimeout)
+	defer cancel()
+
 	if len(deletes)+len(writes) > p.MaxTuplesPerWrite() {
 		return storage.ErrExceededWriteBatchLimit
 	}
 
+	if p.writeBatcher != nil {
+		return p.writeBatcher.WriteBatches(ctx, store, []storage.TupleBatch{{Deletes: deletes, Writes: writes}})
+	}
+
 	now := time.Now().UTC()
 	return sqlcommon.Write(ctx, p.dbInfo, store, deletes, writes, now)
 }
 
+// WriteBatches see [storage.TransactionalBatchWriter].WriteBatches.
+func (p *Postgres) WriteBatches(ctx context.Context, store string, batches []storage.TupleBatch) error {
+	ctx, span := tracer.Start(ctx, "postgres.WriteBatches")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, p.writeTimeout)
+	defer cancel()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > p.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	if p.writeBatcher != nil {
+		return p.writeBatcher.WriteBatches(ctx, store, batches)
+	}
+
+	now := time.Now().UTC()
+
+	return sqlcommon.WriteBatches(ctx, p.dbInfo, store, batches, now)
+}
+
+// WriteBatchesIdempotently see [storage.IdempotentWriter].WriteBatchesIdempotently.
+func (p *Postgres) WriteBatchesIdempotently(ctx context.Context, store string, record storage.IdempotencyRecord, batches []storage.TupleBatch) error {
+	ctx, span := tracer.Start(ctx, "postgres.WriteBatchesIdempotently")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, p.writeTimeout)
+	defer cancel()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > p.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	now := time.Now().UTC()
+
+	return sqlcommon.WriteBatchesIdempotently(ctx, p.dbInfo, store, record, batches, now)
+}
+
+// approximateTupleCountsSamplePercent is the percentage of the blocks of the tuple table sampled
+// by approximate tuple counts.
+const approximateTupleCountsSamplePercent = 1
+
+// ReadTupleCountsByRelation see [storage.TupleCountsByRelationReader].ReadTupleCountsByRelation.
+// Approximate counts are estimated from a sample of the blocks of the tuple table.
+func (p *Postgres) ReadTupleCountsByRelation(ctx context.Context, store string, options storage.TupleCountsByRelationOptions) ([]storage.RelationTupleCount, error) {
+	ctx, span := tracer.Start(ctx, "postgres.ReadTupleCountsByRelation")
+	defer span.End()
+
+	if options.Approximate {
+		table := fmt.Sprintf("tuple TABLESAMPLE SYSTEM (%d)", approximateTupleCountsSamplePercent)
+		return sqlcommon.ReadTupleCountsByRelation(ctx, p.dbInfo, table, store, 100/approximateTupleCountsSamplePercent)
+	}
+
+	return sqlcommon.ReadTupleCountsByRelation(ctx, p.dbInfo, "tuple", store, 1)
+}
+
 // ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
 func (p *Postgres) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, _ storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
 	ctx, span := tracer.Start(ctx, "postgres.ReadUserTuple")
 	defer span.End()
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, p.readTimeout)
+	defer cancel()
+
 	objectType, objectID := tupleUtils.SplitObject(tupleKey.GetObject())
 	userType := tupleUtils.GetUserTypeFromUser(tupleKey.GetUser())
 
-	var conditionName sql.NullString
+	var conditionName, conditionContextKeyID sql.NullString
 	var conditionContext []byte
 	var record storage.TupleRecord
 
 	err := p.stbl.
 		Select(
 			"object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context",
+			"condition_name", "condition_context", "condition_context_key_id",
 		).
 		From("tuple").
 		Where(sq.Eq{
@@ -261,6 +374,7 @@ func (p *Postgres) ReadUserTuple(ctx context.Context, store string, tupleKey *op
 			&record.User,
 			&conditionName,
 			&conditionContext,
+			&conditionContextKeyID,
 		)
 	if err != nil {
 		return nil, sqlcommon.HandleSQLError(err, p.logger)
@@ -269,12 +383,9 @@ func (p *Postgres) ReadUserTuple(ctx context.Context, store string, tupleKey *op
 	if conditionName.String != "" {
 		record.ConditionName = conditionName.String
 
-		if conditionContext != nil {
-			var conditionContextStruct structpb.Struct
-			if err := proto.Unmarshal(conditionContext, &conditionContextStruct); err != nil {
-				return nil, err
-			}
-			record.ConditionContext = &conditionContextStruct
+		record.ConditionContext, err = p.dbInfo.UnmarshalConditionContext(ctx, store, tupleKey, conditionContextKeyID, conditionContext)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -289,7 +400,7 @@ func (p *Postgres) ReadUsersetTuples(ctx context.Context, store string, filter s
 	sb := p.stbl.
 		Select(
 			"store", "object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context", "ulid", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
 		).
 		From("tuple").
 		Where(sq.Eq{"store": store}).
@@ -317,12 +428,14 @@ func (p *Postgres) ReadUsersetTuples(ctx context.Context, store string, filter s
 		}
 		sb = sb.Where(orConditions)
 	}
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, p.readTimeout)
 	rows, err := sb.QueryContext(ctx)
 	if err != nil {
+		cancel()
 		return nil, sqlcommon.HandleSQLError(err, p.logger)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(rows), nil
+	return sqlcommon.NewSQLTupleIterator(rows, p.dbInfo).WithCancel(cancel), nil
 }
 
 // ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
@@ -342,7 +455,7 @@ func (p *Postgres) ReadStartingWithUser(ctx context.Context, store string, opts
 	builder := p.stbl.
 		Select(
 			"store", "object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context", "ulid", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
 		).
 		From("tuple").
 		Where(sq.Eq{
@@ -356,12 +469,14 @@ func (p *Postgres) ReadStartingWithUser(ctx context.Context, store string, opts
 		builder = builder.Where(sq.Eq{"object_id": opts.ObjectIDs.Values()})
 	}
 
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, p.readTimeout)
 	rows, err := builder.QueryContext(ctx)
 	if err != nil {
+		cancel()
 		return nil, sqlcommon.HandleSQLError(err, p.logger)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(rows), nil
+	return sqlcommon.NewSQLTupleIterator(rows, p.dbInfo).WithCancel(cancel), nil
 }
 
 // MaxTuplesPerWrite see [storage.RelationshipTupleWriter].MaxTuplesPerWrite.
@@ -500,6 +615,14 @@ func (p *Postgres) CreateStore(ctx context.Context, store *openfgav1.Store) (*op
 	}, nil
 }
 
+// CreateStoreWithUniqueName see [storage.UniqueStoreNameCreator].CreateStoreWithUniqueName.
+func (p *Postgres) CreateStoreWithUniqueName(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
+	ctx, span := tracer.Start(ctx, "postgres.CreateStoreWithUniqueName")
+	defer span.End()
+
+	return sqlcommon.CreateStoreWithUniqueName(ctx, p.dbInfo, store)
+}
+
 // GetStore retrieves the details of a specific store from the Postgres using its storeID.
 func (p *Postgres) GetStore(ctx context.Context, id string) (*openfgav1.Store, error) {
 	ctx, span := tracer.Start(ctx, "postgres.GetStore")
@@ -519,7 +642,7 @@ func (p *Postgres) GetStore(ctx context.Context, id string) (*openfgav1.Store, e
 	err := row.Scan(&storeID, &name, &createdAt, &updatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, storage.ErrNotFound
+			return nil, &storage.StoreNotFoundError{StoreID: id}
 		}
 		return nil, sqlcommon.HandleSQLError(err, p.logger)
 	}
@@ -601,6 +724,7 @@ func (p *Postgres) DeleteStore(ctx context.Context, id string) error {
 	_, err := p.stbl.
 		Update("store").
 		Set("deleted_at", "NOW()").
+		Set("unique_name", nil). // frees the name for a new store
 		Where(sq.Eq{"id": id}).
 		ExecContext(ctx)
 	if err != nil {
@@ -610,6 +734,76 @@ func (p *Postgres) DeleteStore(ctx context.Context, id string) error {
 	return nil
 }
 
+// PruneAuthorizationModels see [storage.AuthorizationModelPruner].PruneAuthorizationModels.
+func (p *Postgres) PruneAuthorizationModels(ctx context.Context, store string, retain int, keep []string) (int, error) {
+	ctx, span := tracer.Start(ctx, "postgres.PruneAuthorizationModels")
+	defer span.End()
+
+	return sqlcommon.PruneAuthorizationModels(ctx, p.dbInfo, store, retain, keep)
+}
+
+// RotateConditionContextKeys see [storage.ConditionContextKeyRotator].RotateConditionContextKeys. The condition
+// contexts are encrypted with the cipher set by [sqlcommon.WithConditionContextCipher].
+func (p *Postgres) RotateConditionContextKeys(ctx context.Context, store string) (int, error) {
+	ctx, span := tracer.Start(ctx, "postgres.RotateConditionContextKeys")
+	defer span.End()
+
+	return sqlcommon.RotateConditionContextKeys(ctx, p.dbInfo, store)
+}
+
+// rebuildIndexesLockID is the key of the advisory lock held while the indexes are rebuilt.
+const rebuildIndexesLockID = 0x6f70656e666761
+
+// RebuildIndexes see [storage.IndexRebuilder].RebuildIndexes. It runs REINDEX on the tuple and changelog tables,
+// which blocks the writes to each table, and the reads that use its indexes, while its indexes are rebuilt.
+func (p *Postgres) RebuildIndexes(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "postgres.RebuildIndexes")
+	defer span.End()
+
+	// the advisory lock belongs to the session, so it's taken and released on the same connection
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return sqlcommon.HandleSQLError(err, p.logger)
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", rebuildIndexesLockID).Scan(&locked); err != nil {
+		return sqlcommon.HandleSQLError(err, p.logger)
+	}
+	if !locked {
+		return storage.ErrIndexRebuildInProgress
+	}
+	defer func() {
+		// if ctx is canceled the connection is discarded, which releases the lock too
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", rebuildIndexesLockID)
+	}()
+
+	for _, table := range []string{"tuple", "changelog"} {
+		if _, err := conn.ExecContext(ctx, "REINDEX TABLE "+table); err != nil {
+			return sqlcommon.HandleSQLError(err, p.logger)
+		}
+	}
+
+	return nil
+}
+
+// ReadStoreSettings see [storage.StoreSettingsBackend].ReadStoreSettings.
+func (p *Postgres) ReadStoreSettings(ctx context.Context, store string) (*storage.StoreSettings, error) {
+	ctx, span := tracer.Start(ctx, "postgres.ReadStoreSettings")
+	defer span.End()
+
+	return sqlcommon.ReadStoreSettings(ctx, p.dbInfo, store)
+}
+
+// WriteStoreSettings see [storage.StoreSettingsBackend].WriteStoreSettings.
+func (p *Postgres) WriteStoreSettings(ctx context.Context, store string, settings *storage.StoreSettings) error {
+	ctx, span := tracer.Start(ctx, "postgres.WriteStoreSettings")
+	defer span.End()
+
+	return sqlcommon.WriteStoreSettings(ctx, p.dbInfo, store, settings)
+}
+
 // WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
 func (p *Postgres) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
 	ctx, span := tracer.Start(ctx, "postgres.WriteAssertions")
@@ -672,12 +866,12 @@ func (p *Postgres) ReadChanges(ctx context.Context, store, objectTypeFilter stri
 	sb := p.stbl.
 		Select(
 			"ulid", "object_type", "object_id", "relation", "_user", "operation",
-			"condition_name", "condition_context", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "inserted_at",
 		).
 		From("changelog").
 		Where(sq.Eq{"store": store}).
 		Where(fmt.Sprintf("inserted_at < NOW() - interval '%dms'", horizonOffset.Milliseconds())).
-		OrderBy("ulid asc")
+		OrderBy(sqlcommon.ChangelogOrderBy(options))
 
 	if objectTypeFilter != "" {
 		sb = sb.Where(sq.Eq{"object_type": objectTypeFilter})
@@ -691,7 +885,11 @@ func (p *Postgres) ReadChanges(ctx context.Context, store, objectTypeFilter stri
 			return nil, nil, storage.ErrMismatchObjectType
 		}
 
-		sb = sb.Where(sq.Gt{"ulid": token.Ulid}) // > as we always return a continuation token.
+		if options.SortDesc {
+			sb = sb.Where(sq.Lt{"ulid": token.Ulid})
+		} else {
+			sb = sb.Where(sq.Gt{"ulid": token.Ulid}) // > as we always return a continuation token.
+		}
 	}
 	if options.Pagination.PageSize > 0 {
 		sb = sb.Limit(uint64(options.Pagination.PageSize)) // + 1 is NOT used here as we always return a continuation token.
@@ -709,7 +907,7 @@ func (p *Postgres) ReadChanges(ctx context.Context, store, objectTypeFilter stri
 		var objectType, objectID, relation, user string
 		var operation int
 		var insertedAt time.Time
-		var conditionName sql.NullString
+		var conditionName, conditionContextKeyID sql.NullString
 		var conditionContext []byte
 
 		err = rows.Scan(
@@ -721,27 +919,28 @@ func (p *Postgres) ReadChanges(ctx context.Context, store, objectTypeFilter stri
 			&operation,
 			&conditionName,
 			&conditionContext,
+			&conditionContextKeyID,
 			&insertedAt,
 		)
 		if err != nil {
 			return nil, nil, sqlcommon.HandleSQLError(err, p.logger)
 		}
 
-		var conditionContextStruct structpb.Struct
-		if conditionName.String != "" {
-			if conditionContext != nil {
-				if err := proto.Unmarshal(conditionContext, &conditionContextStruct); err != nil {
-					return nil, nil, err
-				}
+		tk := tupleUtils.NewTupleKey(tupleUtils.BuildObject(objectType, objectID), relation, user)
+		conditionContextStruct := &structpb.Struct{}
+		if conditionName.String != "" && conditionContext != nil {
+			conditionContextStruct, err = p.dbInfo.UnmarshalConditionContext(ctx, store, tk, conditionContextKeyID, conditionContext)
+			if err != nil {
+				return nil, nil, err
 			}
 		}
 
-		tk := tupleUtils.NewTupleKeyWithCondition(
-			tupleUtils.BuildObject(objectType, objectID),
+		tk = tupleUtils.NewTupleKeyWithCondition(
+			tk.GetObject(),
 			relation,
 			user,
 			conditionName.String,
-			&conditionContextStruct,
+			conditionContextStruct,
 		)
 
 		changes = append(changes, &openfgav1.TupleChange{
@@ -7,9 +7,11 @@ import (
 
 	"github.com/oklog/ulid/v2"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/pressly/goose/v3"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/openfga/openfga/assets"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/storage/sqlcommon"
 	"github.com/openfga/openfga/pkg/storage/test"
@@ -425,3 +427,127 @@ func TestMarshalledAssertions(t *testing.T) {
 	}
 	require.Equal(t, expectedAssertions, assertions)
 }
+
+func TestAutoMigrate(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "postgres")
+	uri := testDatastore.GetConnectionURI(true)
+
+	db, err := goose.OpenDBWithDriver("pgx", uri)
+	require.NoError(t, err)
+	defer db.Close()
+
+	goose.SetBaseFS(assets.EmbedMigrations)
+	require.NoError(t, goose.DownTo(db, assets.PostgresMigrationDir, 1))
+
+	t.Run("refuses_to_start_on_schema_mismatch", func(t *testing.T) {
+		_, err := New(uri, sqlcommon.NewConfig())
+		require.ErrorContains(t, err, "datastore requires migrations: at revision '1'")
+	})
+
+	t.Run("applies_the_migrations", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithAutoMigrate(true)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		version, err := goose.GetDBVersion(db)
+		require.NoError(t, err)
+		require.Equal(t, testDatastore.GetDatabaseSchemaVersion(), version)
+
+		ds, err = New(uri, sqlcommon.NewConfig())
+		require.NoError(t, err)
+		ds.Close()
+	})
+}
+
+func TestReadAndWriteTimeouts(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "postgres")
+	uri := testDatastore.GetConnectionURI(true)
+
+	ctx := context.Background()
+	store := ulid.Make().String()
+	tk := tuple.NewTupleKey("doc:1", "viewer", "user:anne")
+
+	// a timeout of a nanosecond has expired by the time the query is sent
+	const expired = time.Nanosecond
+
+	t.Run("read_timeout_applies_to_reads_only", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithReadTimeout(expired)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		err = ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tk})
+		require.NoError(t, err)
+
+		_, err = ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, _, err = ds.ReadPage(ctx, store, tuple.NewTupleKey("doc:", "", ""), storage.ReadPageOptions{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, err = ds.ReadStartingWithUser(ctx, store, storage.ReadStartingWithUserFilter{
+			ObjectType: "doc",
+			Relation:   "viewer",
+			UserFilter: []*openfgav1.ObjectRelation{{Object: "user:anne"}},
+		}, storage.ReadStartingWithUserOptions{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// models are not bound by the timeout of the reads of tuples
+		_, err = ds.FindLatestAuthorizationModel(ctx, store)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("write_timeout_applies_to_writes_only", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithWriteTimeout(expired)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		err = ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tuple.NewTupleKey("doc:2", "viewer", "user:anne")})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		err = ds.WriteBatches(ctx, store, []storage.TupleBatch{{Writes: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:2", "viewer", "user:anne")}}})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		got, err := ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+		require.Equal(t, tk.GetObject(), got.GetKey().GetObject())
+
+		_, err = ds.ReadUserTuple(ctx, store, tuple.NewTupleKey("doc:2", "viewer", "user:anne"), storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("iterators_are_readable_until_stopped", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithReadTimeout(time.Minute)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		iter, err := ds.Read(ctx, store, tuple.NewTupleKey("doc:", "", ""), storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		got, err := iter.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, tk.GetObject(), got.GetKey().GetObject())
+	})
+}
+
+func TestConditionContextEncryption(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "postgres")
+	uri := testDatastore.GetConnectionURI(true)
+
+	test.ConditionContextEncryptionTest(t, func(t *testing.T, cipher sqlcommon.ConditionContextCipher) storage.OpenFGADatastore {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithConditionContextCipher(cipher)))
+		require.NoError(t, err)
+		return ds
+	})
+}
+
+func TestChangelogBatching(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "postgres")
+	uri := testDatastore.GetConnectionURI(true)
+
+	test.ChangelogBatchingTest(t, func(t *testing.T, maxDelay time.Duration, maxWrites int) storage.OpenFGADatastore {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithChangelogBatching(maxDelay, maxWrites)))
+		require.NoError(t, err)
+		return ds
+	})
+}
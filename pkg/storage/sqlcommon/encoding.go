@@ -1,24 +1,87 @@
 package sqlcommon
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
 )
 
-func marshalRelationshipCondition(
-	rel *openfgav1.RelationshipCondition,
-) (name string, context []byte, err error) {
-	if rel != nil {
-		// Normalize empty context to nil.
-		if rel.GetContext() != nil && len(rel.GetContext().GetFields()) > 0 {
-			context, err = proto.Marshal(rel.GetContext())
-			if err != nil {
-				return name, context, err
-			}
+// conditionContextKeyID returns the ID of the key with which the condition contexts of the store are encrypted,
+// or an empty ID if they aren't.
+func (d *DBInfo) conditionContextKeyID(ctx context.Context, store string) (string, error) {
+	if d.conditionContextCipher == nil {
+		return "", nil
+	}
+	return d.conditionContextCipher.KeyID(ctx, store)
+}
+
+// marshalRelationshipCondition returns the name and the context of the condition of tk to store, with the
+// context encrypted with the key with the given ID, which is also returned, unless the ID is empty.
+func (d *DBInfo) marshalRelationshipCondition(
+	ctx context.Context,
+	keyID, store string,
+	tk *openfgav1.TupleKey,
+) (name string, conditionContext []byte, contextKeyID sql.NullString, err error) {
+	rel := tk.GetCondition()
+	if rel == nil {
+		return name, conditionContext, contextKeyID, nil
+	}
+
+	// Normalize empty context to nil.
+	if rel.GetContext() == nil || len(rel.GetContext().GetFields()) == 0 {
+		return rel.GetName(), conditionContext, contextKeyID, nil
+	}
+
+	conditionContext, err = proto.Marshal(rel.GetContext())
+	if err != nil {
+		return name, nil, contextKeyID, err
+	}
+
+	if keyID != "" {
+		conditionContext, err = d.conditionContextCipher.Encrypt(ctx, keyID, store, tk, conditionContext)
+		if err != nil {
+			return name, nil, contextKeyID, err
+		}
+		contextKeyID = sql.NullString{String: keyID, Valid: true}
+	}
+
+	return rel.GetName(), conditionContext, contextKeyID, nil
+}
+
+// UnmarshalConditionContext returns the condition context stored for the tuple of the store, which is
+// decrypted if keyID is set, or nil if there is none.
+func (d *DBInfo) UnmarshalConditionContext(
+	ctx context.Context,
+	store string,
+	tk tupleUtils.TupleWithoutCondition,
+	keyID sql.NullString,
+	data []byte,
+) (*structpb.Struct, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	if keyID.String != "" {
+		if d.conditionContextCipher == nil {
+			return nil, errors.New("the condition context is encrypted, but there is no condition context cipher")
 		}
 
-		return rel.GetName(), context, err
+		var err error
+		data, err = d.conditionContextCipher.Decrypt(ctx, keyID.String, store, tk, data)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return name, context, err
+	var conditionContext structpb.Struct
+	if err := proto.Unmarshal(data, &conditionContext); err != nil {
+		return nil, err
+	}
+	return &conditionContext, nil
 }
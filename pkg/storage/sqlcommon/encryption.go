@@ -0,0 +1,397 @@
+package sqlcommon
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+const (
+	dataKeySize = 32
+
+	// rotateConditionContextKeysPageSize is the number of rows read at once by [RotateConditionContextKeys].
+	rotateConditionContextKeysPageSize = 100
+)
+
+// ConditionContextCipher encrypts the condition contexts of the tuples, and of their changes, at rest. The ID of
+// the key that encrypted a context is stored in the condition_context_key_id column of its row, so that the keys
+// can be rotated, see [RotateConditionContextKeys]. Its methods may be called concurrently.
+type ConditionContextCipher interface {
+	// KeyID returns the ID of the key with which the condition contexts of the store are encrypted, or an
+	// empty ID if they are stored unencrypted.
+	KeyID(ctx context.Context, store string) (string, error)
+	// Encrypt encrypts the condition context of the tuple of the store, in its protobuf encoding, with the key
+	// with the given ID.
+	Encrypt(ctx context.Context, keyID, store string, tk tupleUtils.TupleWithoutCondition, plaintext []byte) ([]byte, error)
+	// Decrypt decrypts the condition context of the tuple of the store that Encrypt encrypted with the key
+	// with the given ID.
+	Decrypt(ctx context.Context, keyID, store string, tk tupleUtils.TupleWithoutCondition, ciphertext []byte) ([]byte, error)
+}
+
+// WithConditionContextCipher returns a DatastoreOption that encrypts
+// the condition contexts at rest with the cipher in the Config.
+func WithConditionContextCipher(c ConditionContextCipher) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ConditionContextCipher = c
+	}
+}
+
+// RotateConditionContextKeys provides the common method for re-encrypting the condition contexts of the tuples,
+// and of the changes, of a store across sql storage, see [storage.ConditionContextKeyRotator].
+func RotateConditionContextKeys(ctx context.Context, dbInfo *DBInfo, store string) (int, error) {
+	keyID, err := dbInfo.conditionContextKeyID(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+
+	var rotated int
+	for _, table := range []string{"tuple", "changelog"} {
+		n, err := rotateConditionContextKeys(ctx, dbInfo, table, store, keyID)
+		rotated += n
+		if err != nil {
+			return rotated, err
+		}
+	}
+	return rotated, nil
+}
+
+// conditionContextRow is a row of the tuple or changelog table whose condition context is rotated.
+type conditionContextRow struct {
+	tk               *openfgav1.TupleKey
+	conditionContext []byte
+	keyID            sql.NullString
+	ulid             string
+}
+
+// rotateConditionContextKeys re-encrypts, with the key with the given ID, the condition contexts of the rows of
+// the table of the store that aren't encrypted with it, one row at a time.
+func rotateConditionContextKeys(ctx context.Context, dbInfo *DBInfo, table, store, keyID string) (int, error) {
+	var rotated int
+	after := ""
+	for {
+		sb := dbInfo.stbl.
+			Select("object_type", "object_id", "relation", "_user", "condition_context", "condition_context_key_id", "ulid").
+			From(table).
+			Where(sq.Eq{"store": store}).
+			Where(sq.NotEq{"condition_context": nil}).
+			Where(sq.Gt{"ulid": after}).
+			OrderBy("ulid")
+		if keyID == "" {
+			sb = sb.Where(sq.NotEq{"condition_context_key_id": nil})
+		} else {
+			sb = sb.Where(sq.Or{sq.Eq{"condition_context_key_id": nil}, sq.NotEq{"condition_context_key_id": keyID}})
+		}
+
+		page, err := readConditionContextRows(ctx, dbInfo.limit(sb, rotateConditionContextKeysPageSize))
+		if err != nil {
+			return rotated, err
+		}
+
+		for _, row := range page {
+			if err := rotateConditionContextKey(ctx, dbInfo, table, store, keyID, row); err != nil {
+				return rotated, err
+			}
+			rotated++
+			after = row.ulid
+		}
+
+		if len(page) < rotateConditionContextKeysPageSize {
+			return rotated, nil
+		}
+	}
+}
+
+func readConditionContextRows(ctx context.Context, sb sq.SelectBuilder) ([]conditionContextRow, error) {
+	rows, err := sb.QueryContext(ctx)
+	if err != nil {
+		return nil, HandleSQLError(err, nil)
+	}
+	defer rows.Close()
+
+	var page []conditionContextRow
+	for rows.Next() {
+		var objectType, objectID, relation, user string
+		var row conditionContextRow
+		if err := rows.Scan(&objectType, &objectID, &relation, &user, &row.conditionContext, &row.keyID, &row.ulid); err != nil {
+			return nil, HandleSQLError(err, nil)
+		}
+		row.tk = tupleUtils.NewTupleKey(tupleUtils.BuildObject(objectType, objectID), relation, user)
+		page = append(page, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, HandleSQLError(err, nil)
+	}
+	return page, nil
+}
+
+// rotateConditionContextKey re-encrypts the condition context of the row with the key with the given ID. The
+// row is matched by its ULID, so that a tuple that was rewritten since it was read isn't updated.
+func rotateConditionContextKey(ctx context.Context, dbInfo *DBInfo, table, store, keyID string, row conditionContextRow) error {
+	conditionContext := row.conditionContext
+	if row.keyID.String != "" {
+		if dbInfo.conditionContextCipher == nil {
+			return errors.New("the condition context is encrypted, but there is no condition context cipher")
+		}
+
+		var err error
+		conditionContext, err = dbInfo.conditionContextCipher.Decrypt(ctx, row.keyID.String, store, row.tk, conditionContext)
+		if err != nil {
+			return err
+		}
+	}
+
+	var newKeyID sql.NullString
+	if keyID != "" {
+		var err error
+		conditionContext, err = dbInfo.conditionContextCipher.Encrypt(ctx, keyID, store, row.tk, conditionContext)
+		if err != nil {
+			return err
+		}
+		newKeyID = sql.NullString{String: keyID, Valid: true}
+	}
+
+	_, err := dbInfo.stbl.
+		Update(table).
+		Set("condition_context", conditionContext).
+		Set("condition_context_key_id", newKeyID).
+		Where(sq.Eq{"store": store, "ulid": row.ulid}).
+		ExecContext(ctx)
+	if err != nil {
+		return HandleSQLError(err, nil)
+	}
+	return nil
+}
+
+// ConditionContextKeyProvider wraps and unwraps the data keys with which condition contexts are encrypted,
+// e.g. with a KMS. Its methods may be called concurrently.
+type ConditionContextKeyProvider interface {
+	// CurrentKeyID returns the ID of the key with which the data keys of new condition contexts are wrapped.
+	CurrentKeyID(ctx context.Context) (string, error)
+	// WrapKey encrypts dataKey with the key with the given ID.
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key returned by WrapKey for the key with the given ID.
+	UnwrapKey(ctx context.Context, keyID string, wrappedKey []byte) ([]byte, error)
+}
+
+// NewStaticConditionContextKeyProvider returns a [ConditionContextKeyProvider] that wraps data keys with
+// AES-256-GCM using the given 32 byte keys, by ID. Keys that were current before must be kept until the
+// condition contexts encrypted with them are rotated.
+func NewStaticConditionContextKeyProvider(currentKeyID string, keys map[string][]byte) (ConditionContextKeyProvider, error) {
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key '%s': %w", id, err)
+		}
+		aeads[id] = aead
+	}
+
+	if _, ok := aeads[currentKeyID]; !ok {
+		return nil, fmt.Errorf("the current key '%s' is not one of the keys", currentKeyID)
+	}
+
+	return &staticConditionContextKeyProvider{currentKeyID: currentKeyID, aeads: aeads}, nil
+}
+
+type staticConditionContextKeyProvider struct {
+	currentKeyID string
+	aeads        map[string]cipher.AEAD
+}
+
+func (p *staticConditionContextKeyProvider) CurrentKeyID(context.Context) (string, error) {
+	return p.currentKeyID, nil
+}
+
+func (p *staticConditionContextKeyProvider) WrapKey(_ context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := p.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key '%s'", keyID)
+	}
+	return seal(aead, dataKey, nil)
+}
+
+func (p *staticConditionContextKeyProvider) UnwrapKey(_ context.Context, keyID string, wrappedKey []byte) ([]byte, error) {
+	aead, ok := p.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key '%s'", keyID)
+	}
+	return open(aead, wrappedKey, nil)
+}
+
+// NewEnvelopeConditionContextCipher returns a [ConditionContextCipher] that encrypts the condition contexts of
+// the stores for which encrypted returns true with envelope encryption: the contexts are encrypted with
+// AES-256-GCM using a data key, which is wrapped by the current key of keys and stored with each context.
+//
+// A data key is generated for each key of keys the first time it's needed, and reused for all the contexts
+// encrypted with that key. The unwrapped data keys are cached by key ID, so that keys is only called once per
+// data key rather than for every context that is read.
+func NewEnvelopeConditionContextCipher(keys ConditionContextKeyProvider, encrypted func(store string) bool) ConditionContextCipher {
+	return &envelopeConditionContextCipher{
+		keys:      keys,
+		encrypted: encrypted,
+		current:   map[string]*dataKey{},
+		unwrapped: map[string]cipher.AEAD{},
+	}
+}
+
+type envelopeConditionContextCipher struct {
+	keys      ConditionContextKeyProvider
+	encrypted func(store string) bool
+
+	mu sync.Mutex
+	// current are the data keys of the contexts encrypted by this cipher, by key ID.
+	current map[string]*dataKey // GUARDED_BY(mu).
+	// unwrapped are the data keys of the contexts decrypted by this cipher, by key ID and wrapped data key.
+	unwrapped map[string]cipher.AEAD // GUARDED_BY(mu).
+}
+
+type dataKey struct {
+	aead    cipher.AEAD
+	wrapped []byte
+}
+
+func (c *envelopeConditionContextCipher) KeyID(ctx context.Context, store string) (string, error) {
+	if !c.encrypted(store) {
+		return "", nil
+	}
+
+	keyID, err := c.keys.CurrentKeyID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the current condition context key: %w", err)
+	}
+	return keyID, nil
+}
+
+// Encrypt returns the length of the wrapped data key, the wrapped data key, and the encrypted context.
+func (c *envelopeConditionContextCipher) Encrypt(ctx context.Context, keyID, store string, tk tupleUtils.TupleWithoutCondition, plaintext []byte) ([]byte, error) {
+	key, err := c.dataKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := binary.AppendUvarint(nil, uint64(len(key.wrapped)))
+	header = append(header, key.wrapped...)
+	ciphertext, err := seal(key.aead, plaintext, additionalData(store, tk))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, ciphertext...), nil
+}
+
+func (c *envelopeConditionContextCipher) Decrypt(ctx context.Context, keyID, store string, tk tupleUtils.TupleWithoutCondition, ciphertext []byte) ([]byte, error) {
+	n, size := binary.Uvarint(ciphertext)
+	if size <= 0 || uint64(len(ciphertext)-size) < n {
+		return nil, errors.New("invalid encrypted condition context")
+	}
+	wrapped := ciphertext[size : size+int(n)]
+	ciphertext = ciphertext[size+int(n):]
+
+	aead, err := c.unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, ciphertext, additionalData(store, tk))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the condition context: %w", err)
+	}
+	return plaintext, nil
+}
+
+// dataKey returns the data key of the contexts encrypted with the key with the given ID, generating and
+// wrapping it the first time.
+func (c *envelopeConditionContextCipher) dataKey(ctx context.Context, keyID string) (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.current[keyID]; ok {
+		return key, nil
+	}
+
+	plaintextKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(plaintextKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(plaintextKey)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := c.keys.WrapKey(ctx, keyID, plaintextKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap the condition context key: %w", err)
+	}
+
+	key := &dataKey{aead: aead, wrapped: wrapped}
+	c.current[keyID] = key
+	c.unwrapped[keyID+"|"+string(wrapped)] = aead
+	return key, nil
+}
+
+// unwrap returns the data key that was wrapped with the key with the given ID.
+func (c *envelopeConditionContextCipher) unwrap(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	cacheKey := keyID + "|" + string(wrapped)
+
+	c.mu.Lock()
+	aead, ok := c.unwrapped[cacheKey]
+	c.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	plaintextKey, err := c.keys.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap the condition context key: %w", err)
+	}
+	aead, err = newAEAD(plaintextKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.unwrapped[cacheKey] = aead
+	c.mu.Unlock()
+	return aead, nil
+}
+
+// additionalData binds the encrypted context to its tuple, so that it can't be moved to another tuple.
+func additionalData(store string, tk tupleUtils.TupleWithoutCondition) []byte {
+	return []byte(store + "|" + tupleUtils.TupleKeyToString(tk))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("keys must be %d bytes long", dataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext, and returns it after the random nonce it was encrypted with.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
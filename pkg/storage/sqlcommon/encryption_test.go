@@ -0,0 +1,132 @@
+package sqlcommon
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// countingKeyProvider counts the calls to the provider it wraps.
+type countingKeyProvider struct {
+	ConditionContextKeyProvider
+	wraps   atomic.Int32
+	unwraps atomic.Int32
+}
+
+func (p *countingKeyProvider) WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	p.wraps.Add(1)
+	return p.ConditionContextKeyProvider.WrapKey(ctx, keyID, dataKey)
+}
+
+func (p *countingKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrappedKey []byte) ([]byte, error) {
+	p.unwraps.Add(1)
+	return p.ConditionContextKeyProvider.UnwrapKey(ctx, keyID, wrappedKey)
+}
+
+func newCountingKeyProvider(t *testing.T, currentKeyID string, keyIDs ...string) *countingKeyProvider {
+	keys := map[string][]byte{}
+	for _, id := range keyIDs {
+		// the same ID is always the same key
+		keys[id] = bytes.Repeat([]byte(id), 32)[:32]
+	}
+
+	provider, err := NewStaticConditionContextKeyProvider(currentKeyID, keys)
+	require.NoError(t, err)
+	return &countingKeyProvider{ConditionContextKeyProvider: provider}
+}
+
+func TestEnvelopeConditionContextCipher(t *testing.T) {
+	ctx := context.Background()
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+	plaintext := []byte("context")
+
+	encrypted := func(store string) bool {
+		return store == "encrypted"
+	}
+
+	t.Run("stores_that_are_not_encrypted_have_no_key", func(t *testing.T) {
+		c := NewEnvelopeConditionContextCipher(newCountingKeyProvider(t, "key1", "key1"), encrypted)
+
+		keyID, err := c.KeyID(ctx, "other")
+		require.NoError(t, err)
+		require.Empty(t, keyID)
+
+		keyID, err = c.KeyID(ctx, "encrypted")
+		require.NoError(t, err)
+		require.Equal(t, "key1", keyID)
+	})
+
+	t.Run("round_trip", func(t *testing.T) {
+		keys := newCountingKeyProvider(t, "key1", "key1")
+		c := NewEnvelopeConditionContextCipher(keys, encrypted)
+
+		for i := 0; i < 3; i++ {
+			ciphertext, err := c.Encrypt(ctx, "key1", "encrypted", tk, plaintext)
+			require.NoError(t, err)
+			require.NotContains(t, string(ciphertext), string(plaintext))
+
+			got, err := c.Decrypt(ctx, "key1", "encrypted", tk, ciphertext)
+			require.NoError(t, err)
+			require.Equal(t, plaintext, got)
+		}
+
+		// the data key is generated and wrapped once, and never unwrapped since it's known
+		require.Equal(t, int32(1), keys.wraps.Load())
+		require.Zero(t, keys.unwraps.Load())
+	})
+
+	t.Run("unwrapped_data_keys_are_cached", func(t *testing.T) {
+		ciphertext, err := NewEnvelopeConditionContextCipher(newCountingKeyProvider(t, "key1", "key1"), encrypted).
+			Encrypt(ctx, "key1", "encrypted", tk, plaintext)
+		require.NoError(t, err)
+
+		keys := newCountingKeyProvider(t, "key1", "key1")
+		c := NewEnvelopeConditionContextCipher(keys, encrypted)
+		for i := 0; i < 3; i++ {
+			got, err := c.Decrypt(ctx, "key1", "encrypted", tk, ciphertext)
+			require.NoError(t, err)
+			require.Equal(t, plaintext, got)
+		}
+		require.Equal(t, int32(1), keys.unwraps.Load())
+	})
+
+	t.Run("contexts_cannot_be_moved_to_another_tuple", func(t *testing.T) {
+		c := NewEnvelopeConditionContextCipher(newCountingKeyProvider(t, "key1", "key1"), encrypted)
+
+		ciphertext, err := c.Encrypt(ctx, "key1", "encrypted", tk, plaintext)
+		require.NoError(t, err)
+
+		_, err = c.Decrypt(ctx, "key1", "encrypted", tuple.NewTupleKey("document:2", "viewer", "user:anne"), ciphertext)
+		require.ErrorContains(t, err, "failed to decrypt the condition context")
+	})
+
+	t.Run("contexts_cannot_be_decrypted_without_their_key", func(t *testing.T) {
+		ciphertext, err := NewEnvelopeConditionContextCipher(newCountingKeyProvider(t, "key1", "key1"), encrypted).
+			Encrypt(ctx, "key1", "encrypted", tk, plaintext)
+		require.NoError(t, err)
+
+		_, err = NewEnvelopeConditionContextCipher(newCountingKeyProvider(t, "key2", "key2"), encrypted).
+			Decrypt(ctx, "key1", "encrypted", tk, ciphertext)
+		require.ErrorContains(t, err, "failed to unwrap the condition context key")
+	})
+
+	t.Run("invalid_ciphertext", func(t *testing.T) {
+		c := NewEnvelopeConditionContextCipher(newCountingKeyProvider(t, "key1", "key1"), encrypted)
+
+		_, err := c.Decrypt(ctx, "key1", "encrypted", tk, []byte{0xff})
+		require.ErrorContains(t, err, "invalid encrypted condition context")
+	})
+}
+
+func TestNewStaticConditionContextKeyProvider(t *testing.T) {
+	_, err := NewStaticConditionContextKeyProvider("key1", map[string][]byte{"key1": []byte("short")})
+	require.ErrorContains(t, err, "keys must be 32 bytes long")
+
+	_, err = NewStaticConditionContextKeyProvider("key2", map[string][]byte{"key1": bytes.Repeat([]byte{1}, 32)})
+	require.ErrorContains(t, err, "the current key 'key2' is not one of the keys")
+}
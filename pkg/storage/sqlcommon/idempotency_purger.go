@@ -0,0 +1,80 @@
+package sqlcommon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/pkg/logger"
+)
+
+// idempotencyRecordPurgeInterval is how often the expired idempotency records are deleted.
+const idempotencyRecordPurgeInterval = 10 * time.Minute
+
+// IdempotencyRecordPurger deletes the expired idempotency records of all the stores in the background, so that
+// the writes only have to delete the expired record of their own key, if any.
+type IdempotencyRecordPurger struct {
+	dbInfo   *DBInfo
+	logger   logger.Logger
+	interval time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewIdempotencyRecordPurger starts deleting the expired idempotency records every
+// idempotencyRecordPurgeInterval until Close is called.
+func NewIdempotencyRecordPurger(dbInfo *DBInfo, logger logger.Logger) *IdempotencyRecordPurger {
+	return newIdempotencyRecordPurger(dbInfo, logger, idempotencyRecordPurgeInterval)
+}
+
+func newIdempotencyRecordPurger(dbInfo *DBInfo, logger logger.Logger, interval time.Duration) *IdempotencyRecordPurger {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &IdempotencyRecordPurger{
+		dbInfo:   dbInfo,
+		logger:   logger,
+		interval: interval,
+		cancel:   cancel,
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := p.purge(ctx, time.Now().UTC()); err != nil && ctx.Err() == nil {
+					p.logger.Warn("failed to delete the expired idempotency records", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return p
+}
+
+// purge deletes the idempotency records that expired before now, and returns how many were deleted.
+func (p *IdempotencyRecordPurger) purge(ctx context.Context, now time.Time) (int64, error) {
+	res, err := p.dbInfo.stbl.
+		Delete("idempotency_record").
+		Where(sq.Lt{"expires_at": now}).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, HandleSQLError(err, nil)
+	}
+	return res.RowsAffected()
+}
+
+// Close stops deleting the expired idempotency records, and waits for a deletion in progress to stop.
+func (p *IdempotencyRecordPurger) Close() {
+	p.cancel()
+	p.wg.Wait()
+}
@@ -0,0 +1,133 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/logger"
+)
+
+var connectionResetRetriesCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: build.ProjectName,
+	Name:      "datastore_connection_reset_retries_total",
+	Help:      "The total number of datastore reads retried because their connection was reset, e.g. after being evicted while idle.",
+})
+
+// IsConnectionReset returns true if err is caused by the connection to the database being closed or
+// reset by the database or the network, e.g. because it was evicted while idle, as opposed to the
+// query itself failing.
+func IsConnectionReset(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	// some drivers don't wrap the network errors
+	message := err.Error()
+	return strings.Contains(message, "connection reset by peer") || strings.Contains(message, "broken pipe")
+}
+
+// NewRetryingRunner returns a [sq.BaseRunner] that runs the queries on db, and runs once more the
+// SELECT queries whose connection was reset before they returned any row, which logs a warning. The
+// reset connection is discarded by the driver, so the retry runs on another connection. Other
+// statements are never retried, because they may have been applied before the connection was reset.
+func NewRetryingRunner(db *sql.DB, logger logger.Logger) sq.BaseRunner {
+	return &retryingRunner{db: db, logger: logger}
+}
+
+type retryingRunner struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+var (
+	_ sq.BaseRunner        = (*retryingRunner)(nil)
+	_ sq.QueryerContext    = (*retryingRunner)(nil)
+	_ sq.QueryRowerContext = (*retryingRunner)(nil)
+	_ sq.ExecerContext     = (*retryingRunner)(nil)
+)
+
+func (r *retryingRunner) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.db.Exec(query, args...)
+}
+
+func (r *retryingRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.db.ExecContext(ctx, query, args...)
+}
+
+func (r *retryingRunner) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+func (r *retryingRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err == nil || !isRead(query) || !IsConnectionReset(err) || ctx.Err() != nil {
+		return rows, err
+	}
+
+	r.logger.WarnWithContext(ctx, "retrying datastore read after the connection was reset", zap.Error(err))
+	connectionResetRetriesCounter.Inc()
+
+	return r.db.QueryContext(ctx, query, args...)
+}
+
+func (r *retryingRunner) QueryRow(query string, args ...interface{}) sq.RowScanner {
+	return r.QueryRowContext(context.Background(), query, args...)
+}
+
+func (r *retryingRunner) QueryRowContext(ctx context.Context, query string, args ...interface{}) sq.RowScanner {
+	if !isRead(query) {
+		return r.db.QueryRowContext(ctx, query, args...)
+	}
+
+	rows, err := r.QueryContext(ctx, query, args...)
+	return &row{rows: rows, err: err}
+}
+
+// isRead returns true if query is a SELECT query, which can be run again safely.
+func isRead(query string) bool {
+	query = strings.TrimSpace(query)
+	return len(query) >= len("SELECT") && strings.EqualFold(query[:len("SELECT")], "SELECT")
+}
+
+// row is the [sq.RowScanner] of the first row of rows, like [sql.Row].
+type row struct {
+	rows *sql.Rows
+	err  error
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	return r.rows.Close()
+}
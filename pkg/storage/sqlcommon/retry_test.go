@@ -0,0 +1,149 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/openfga/openfga/pkg/logger"
+)
+
+// faultyDriver is a database driver whose connections fail the first statement they run with a
+// connection reset, and return a single `1` row to the queries after that.
+type faultyDriver struct {
+	statements atomic.Int32
+}
+
+func (d *faultyDriver) Open(string) (driver.Conn, error) {
+	return &faultyConn{driver: d}, nil
+}
+
+type faultyConn struct {
+	driver *faultyDriver
+}
+
+var (
+	_ driver.QueryerContext = (*faultyConn)(nil)
+	_ driver.ExecerContext  = (*faultyConn)(nil)
+)
+
+func (c *faultyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *faultyConn) Close() error {
+	return nil
+}
+
+func (c *faultyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *faultyConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if c.driver.statements.Add(1) == 1 {
+		return nil, fmt.Errorf("read tcp: %w", syscall.ECONNRESET)
+	}
+	return &faultyRows{}, nil
+}
+
+func (c *faultyConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if c.driver.statements.Add(1) == 1 {
+		return nil, fmt.Errorf("read tcp: %w", syscall.ECONNRESET)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type faultyRows struct {
+	done bool
+}
+
+func (r *faultyRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *faultyRows) Close() error {
+	return nil
+}
+
+func (r *faultyRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+var faultyDrivers atomic.Int32
+
+// newFaultyDB returns a database whose first statement fails with a connection reset.
+func newFaultyDB(t *testing.T) *sql.DB {
+	name := fmt.Sprintf("faulty-%d", faultyDrivers.Add(1))
+	sql.Register(name, &faultyDriver{})
+
+	db, err := sql.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestRetryingRunner(t *testing.T) {
+	t.Run("read_is_retried_after_connection_reset", func(t *testing.T) {
+		core, logs := observer.New(zap.WarnLevel)
+		stbl := sq.StatementBuilder.RunWith(NewRetryingRunner(newFaultyDB(t), &logger.ZapLogger{Logger: zap.New(core)}))
+
+		var value int
+		err := stbl.Select("1").QueryRowContext(context.Background()).Scan(&value)
+		require.NoError(t, err)
+		require.Equal(t, 1, value)
+
+		require.Equal(t, 1, logs.FilterMessage("retrying datastore read after the connection was reset").Len())
+	})
+
+	t.Run("rows_are_retried_after_connection_reset", func(t *testing.T) {
+		stbl := sq.StatementBuilder.RunWith(NewRetryingRunner(newFaultyDB(t), logger.NewNoopLogger()))
+
+		rows, err := stbl.Select("1").QueryContext(context.Background())
+		require.NoError(t, err)
+		defer rows.Close()
+		require.True(t, rows.Next())
+	})
+
+	t.Run("write_is_not_retried_after_connection_reset", func(t *testing.T) {
+		stbl := sq.StatementBuilder.RunWith(NewRetryingRunner(newFaultyDB(t), logger.NewNoopLogger()))
+
+		_, err := stbl.Insert("tuple").Columns("store").Values("1").ExecContext(context.Background())
+		require.ErrorIs(t, err, syscall.ECONNRESET)
+	})
+
+	t.Run("write_returning_a_row_is_not_retried_after_connection_reset", func(t *testing.T) {
+		stbl := sq.StatementBuilder.RunWith(NewRetryingRunner(newFaultyDB(t), logger.NewNoopLogger()))
+
+		var value int
+		err := stbl.Insert("tuple").Columns("store").Values("1").Suffix("RETURNING store").
+			QueryRowContext(context.Background()).Scan(&value)
+		require.ErrorIs(t, err, syscall.ECONNRESET)
+	})
+}
+
+func TestIsConnectionReset(t *testing.T) {
+	require.True(t, IsConnectionReset(driver.ErrBadConn))
+	require.True(t, IsConnectionReset(fmt.Errorf("failed: %w", io.ErrUnexpectedEOF)))
+	require.True(t, IsConnectionReset(fmt.Errorf("read tcp: %w", syscall.ECONNRESET)))
+	require.True(t, IsConnectionReset(errors.New("write tcp 10.0.0.1:5432: broken pipe")))
+	require.False(t, IsConnectionReset(errors.New(`relation "tuple" does not exist`)))
+	require.False(t, IsConnectionReset(sql.ErrNoRows))
+}
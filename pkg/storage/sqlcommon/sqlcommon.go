@@ -7,25 +7,32 @@ import (
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/go-sql-driver/mysql"
-	"github.com/oklog/ulid/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"github.com/pressly/goose/v3"
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/openfga/openfga/assets"
 	"github.com/openfga/openfga/internal/build"
 	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/storage"
 	tupleUtils "github.com/openfga/openfga/pkg/tuple"
 )
 
+// DefaultChangelogBatchMaxConcurrentGroups is the default maximum number of groups of writes committed at the
+// same time when the writes are grouped.
+const DefaultChangelogBatchMaxConcurrentGroups = 4
+
 // Config defines the configuration parameters
 // for setting up and managing a sql connection.
 type Config struct {
@@ -40,6 +47,38 @@ type Config struct {
 	ConnMaxIdleTime time.Duration
 	ConnMaxLifetime time.Duration
 
+	// ReadTimeout bounds the queries of the reads of tuples, including the iteration of their rows.
+	// WriteTimeout bounds the transactions of the writes of tuples. If 0, only the deadline of the
+	// caller applies.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// ReadReplicaURIs are the connection URIs of the read replicas of the datastore. Only supported by MySQL.
+	ReadReplicaURIs []string
+
+	// MaxStatementSizeInBytes is the size above which a statement of a write is rejected with a
+	// storage.WriteTooLargeError, instead of sending it. If 0, MySQL uses its max_allowed_packet.
+	MaxStatementSizeInBytes int
+	// SplitLargeWrites splits the changelog entries of a write above MaxStatementSizeInBytes into several
+	// statements of the same transaction, instead of rejecting the write.
+	SplitLargeWrites bool
+
+	// ChangelogBatchDelay is the maximum delay for which the writes of tuples are held to be committed
+	// together with the concurrent writes, so that their changelog entries are inserted with grouped
+	// statements. ChangelogBatchMaxWrites is the maximum number of writes committed together, or 0 for
+	// no maximum. If ChangelogBatchDelay is 0, every write is committed on its own.
+	ChangelogBatchDelay     time.Duration
+	ChangelogBatchMaxWrites int
+	// ChangelogBatchMaxConcurrentGroups is the maximum number of groups of writes committed at the same time.
+	ChangelogBatchMaxConcurrentGroups int
+
+	// ConditionContextCipher, if set, encrypts the condition contexts of the tuples at rest.
+	ConditionContextCipher ConditionContextCipher
+
+	// AutoMigrate runs the migrations of the schema when the datastore is created. If false, the datastore
+	// can't be created unless the migrations have been run, e.g. with 'openfga migrate'.
+	AutoMigrate bool
+
 	ExportMetrics bool
 }
 
@@ -116,6 +155,71 @@ func WithConnMaxLifetime(d time.Duration) DatastoreOption {
 	}
 }
 
+// WithReadTimeout returns a DatastoreOption that sets
+// the timeout of the reads of tuples in the Config.
+func WithReadTimeout(d time.Duration) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ReadTimeout = d
+	}
+}
+
+// WithWriteTimeout returns a DatastoreOption that sets
+// the timeout of the writes of tuples in the Config.
+func WithWriteTimeout(d time.Duration) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.WriteTimeout = d
+	}
+}
+
+// WithReadReplicaURIs returns a DatastoreOption that sets the connection URIs
+// of the read replicas in the Config.
+func WithReadReplicaURIs(uris ...string) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ReadReplicaURIs = uris
+	}
+}
+
+// WithMaxStatementSize returns a DatastoreOption that sets the size above which
+// a statement of a write is rejected in the Config.
+func WithMaxStatementSize(bytes int) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.MaxStatementSizeInBytes = bytes
+	}
+}
+
+// WithSplitLargeWrites returns a DatastoreOption that splits the statements
+// of writes above the max statement size in the Config.
+func WithSplitLargeWrites() DatastoreOption {
+	return func(cfg *Config) {
+		cfg.SplitLargeWrites = true
+	}
+}
+
+// WithChangelogBatching returns a DatastoreOption that groups the concurrent writes
+// of tuples, and their changelog entries, into transactions in the Config.
+func WithChangelogBatching(maxDelay time.Duration, maxWrites int) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ChangelogBatchDelay = maxDelay
+		cfg.ChangelogBatchMaxWrites = maxWrites
+	}
+}
+
+// WithChangelogBatchMaxConcurrentGroups returns a DatastoreOption that sets the maximum number of groups
+// of writes committed at the same time when WithChangelogBatching is set in the Config.
+func WithChangelogBatchMaxConcurrentGroups(n int) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ChangelogBatchMaxConcurrentGroups = n
+	}
+}
+
+// WithAutoMigrate returns a DatastoreOption that sets whether
+// the migrations are run when the datastore is created in the Config.
+func WithAutoMigrate(enabled bool) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.AutoMigrate = enabled
+	}
+}
+
 // WithMetrics returns a DatastoreOption that
 // enables the export of metrics in the Config.
 func WithMetrics() DatastoreOption {
@@ -145,9 +249,21 @@ func NewConfig(opts ...DatastoreOption) *Config {
 		cfg.MaxTypesPerModelField = storage.DefaultMaxTypesPerAuthorizationModel
 	}
 
+	if cfg.ChangelogBatchMaxConcurrentGroups == 0 {
+		cfg.ChangelogBatchMaxConcurrentGroups = DefaultChangelogBatchMaxConcurrentGroups
+	}
+
 	return cfg
 }
 
+// ChangelogOrderBy returns the ORDER BY expression of the changelog reads with the given options.
+func ChangelogOrderBy(options storage.ReadChangesOptions) string {
+	if options.SortDesc {
+		return "ulid desc"
+	}
+	return "ulid asc"
+}
+
 // ContToken represents a continuation token structure used in pagination.
 type ContToken struct {
 	Ulid       string `json:"ulid"`
@@ -168,7 +284,7 @@ func NewContToken(ulid, objectType string) *ContToken {
 func UnmarshallContToken(from string) (*ContToken, error) {
 	var token ContToken
 	if err := json.Unmarshal([]byte(from), &token); err != nil {
-		return nil, storage.ErrInvalidContinuationToken
+		return nil, &storage.InvalidContinuationTokenError{Cause: err}
 	}
 	return &token, nil
 }
@@ -177,19 +293,24 @@ func UnmarshallContToken(from string) (*ContToken, error) {
 // interface for iterating over tuples fetched from a SQL database.
 type SQLTupleIterator struct {
 	rows     *sql.Rows
+	dbInfo   *DBInfo
 	resultCh chan *storage.TupleRecord
 	errCh    chan error
 	firstRow *storage.TupleRecord
 	mu       sync.Mutex
+	cancel   context.CancelFunc
 }
 
 // Ensures that SQLTupleIterator implements the TupleIterator interface.
 var _ storage.TupleIterator = (*SQLTupleIterator)(nil)
 
-// NewSQLTupleIterator returns a SQL tuple iterator.
-func NewSQLTupleIterator(rows *sql.Rows) *SQLTupleIterator {
+// NewSQLTupleIterator returns a SQL tuple iterator. The rows must have the columns store, object_type, object_id,
+// relation, _user, condition_name, condition_context, condition_context_key_id, ulid and inserted_at, in this
+// order. The condition contexts are decrypted with the cipher of dbInfo, if they are encrypted.
+func NewSQLTupleIterator(rows *sql.Rows, dbInfo *DBInfo) *SQLTupleIterator {
 	return &SQLTupleIterator{
 		rows:     rows, // GUARDED_BY(mu)
+		dbInfo:   dbInfo,
 		resultCh: make(chan *storage.TupleRecord, 1),
 		errCh:    make(chan error, 1),
 		firstRow: nil, // GUARDED_BY(mu). The firstRow is used as a temporary storage place if head is called.
@@ -200,7 +321,7 @@ func NewSQLTupleIterator(rows *sql.Rows) *SQLTupleIterator {
 	}
 }
 
-func (t *SQLTupleIterator) next() (*storage.TupleRecord, error) {
+func (t *SQLTupleIterator) next(ctx context.Context) (*storage.TupleRecord, error) {
 	t.mu.Lock()
 
 	if t.firstRow != nil {
@@ -227,7 +348,19 @@ func (t *SQLTupleIterator) next() (*storage.TupleRecord, error) {
 		return nil, storage.ErrIteratorDone
 	}
 
-	var conditionName sql.NullString
+	record, err := t.scan(ctx)
+	t.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// scan returns the record of the current row.
+func (t *SQLTupleIterator) scan(ctx context.Context) (*storage.TupleRecord, error) {
+	var conditionName, conditionContextKeyID sql.NullString
 	var conditionContext []byte
 	var record storage.TupleRecord
 	err := t.rows.Scan(
@@ -238,29 +371,26 @@ func (t *SQLTupleIterator) next() (*storage.TupleRecord, error) {
 		&record.User,
 		&conditionName,
 		&conditionContext,
+		&conditionContextKeyID,
 		&record.Ulid,
 		&record.InsertedAt,
 	)
-	t.mu.Unlock()
-
 	if err != nil {
 		return nil, err
 	}
 
 	record.ConditionName = conditionName.String
 
-	if conditionContext != nil {
-		var conditionContextStruct structpb.Struct
-		if err := proto.Unmarshal(conditionContext, &conditionContextStruct); err != nil {
-			return nil, err
-		}
-		record.ConditionContext = &conditionContextStruct
+	tk := tupleUtils.NewTupleKey(tupleUtils.BuildObject(record.ObjectType, record.ObjectID), record.Relation, record.User)
+	record.ConditionContext, err = t.dbInfo.UnmarshalConditionContext(ctx, record.Store, tk, conditionContextKeyID, conditionContext)
+	if err != nil {
+		return nil, err
 	}
 
 	return &record, nil
 }
 
-func (t *SQLTupleIterator) head() (*storage.TupleRecord, error) {
+func (t *SQLTupleIterator) head(ctx context.Context) (*storage.TupleRecord, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -285,46 +415,24 @@ func (t *SQLTupleIterator) head() (*storage.TupleRecord, error) {
 		return nil, storage.ErrIteratorDone
 	}
 
-	var conditionName sql.NullString
-	var conditionContext []byte
-	var record storage.TupleRecord
-	err := t.rows.Scan(
-		&record.Store,
-		&record.ObjectType,
-		&record.ObjectID,
-		&record.Relation,
-		&record.User,
-		&conditionName,
-		&conditionContext,
-		&record.Ulid,
-		&record.InsertedAt,
-	)
+	record, err := t.scan(ctx)
 	if err != nil {
 		return nil, err
 	}
+	t.firstRow = record
 
-	record.ConditionName = conditionName.String
-
-	if conditionContext != nil {
-		var conditionContextStruct structpb.Struct
-		if err := proto.Unmarshal(conditionContext, &conditionContextStruct); err != nil {
-			return nil, err
-		}
-		record.ConditionContext = &conditionContextStruct
-	}
-	t.firstRow = &record
-
-	return &record, nil
+	return record, nil
 }
 
 // ToArray converts the tupleIterator to an []*openfgav1.Tuple and a possibly empty continuation token.
 // If the continuation token exists it is the ulid of the last element of the returned array.
 func (t *SQLTupleIterator) ToArray(
+	ctx context.Context,
 	opts storage.PaginationOptions,
 ) ([]*openfgav1.Tuple, []byte, error) {
 	var res []*openfgav1.Tuple
 	for i := 0; i < opts.PageSize; i++ {
-		tupleRecord, err := t.next()
+		tupleRecord, err := t.next(ctx)
 		if err != nil {
 			if err == storage.ErrIteratorDone {
 				return res, nil, nil
@@ -337,7 +445,7 @@ func (t *SQLTupleIterator) ToArray(
 	// Check if we are at the end of the iterator.
 	// If we are then we do not need to return a continuation token.
 	// This is why we have LIMIT+1 in the query.
-	tupleRecord, err := t.next()
+	tupleRecord, err := t.next(ctx)
 	if err != nil {
 		if errors.Is(err, storage.ErrIteratorDone) {
 			return res, nil, nil
@@ -359,7 +467,7 @@ func (t *SQLTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
 		return nil, ctx.Err()
 	}
 
-	record, err := t.next()
+	record, err := t.next(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -373,7 +481,7 @@ func (t *SQLTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
 		return nil, ctx.Err()
 	}
 
-	record, err := t.head()
+	record, err := t.head(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -384,6 +492,25 @@ func (t *SQLTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
 // Stop terminates iteration.
 func (t *SQLTupleIterator) Stop() {
 	t.rows.Close()
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
+// WithCancel sets the function that cancels the context of the query of the iterator, which is
+// called when the iterator is stopped. It returns the iterator for chaining.
+func (t *SQLTupleIterator) WithCancel(cancel context.CancelFunc) *SQLTupleIterator {
+	t.cancel = cancel
+	return t
+}
+
+// ContextWithTimeout returns a copy of ctx with the timeout applied, and the function to release it.
+// If timeout is not positive, ctx is returned unchanged with a no-op cancel function.
+func ContextWithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // HandleSQLError processes an SQL error and converts it into a more
@@ -407,19 +534,63 @@ func HandleSQLError(err error, logger logger.Logger, args ...interface{}) error
 			}
 		}
 		return storage.ErrCollision
+	} else if isSQLServerDuplicateKey(err) {
+		if len(args) > 0 {
+			if tk, ok := args[0].(*openfgav1.TupleKey); ok {
+				return storage.InvalidWriteInputError(tk, openfgav1.TupleOperation_TUPLE_OPERATION_WRITE)
+			}
+		}
+		return storage.ErrCollision
+	} else if isStatementTooLarge(err) {
+		return &storage.WriteTooLargeError{Cause: err}
 	}
 	if logger != nil {
-		logger.Error("sql", zap.Error(err), zap.Any("stack", string(debug.Stack())))
+		if IsConnectionReset(err) {
+			// not an error of the query itself, so the stack isn't relevant
+			logger.Warn("sql connection reset", zap.Error(err))
+		} else {
+			logger.Error("sql", zap.Error(err), zap.Any("stack", string(debug.Stack())))
+		}
 	}
 
 	return fmt.Errorf("sql error: %w", err)
 }
 
+// isSQLServerDuplicateKey returns true if err is the violation of a primary key or of a unique index by SQL Server.
+func isSQLServerDuplicateKey(err error) bool {
+	var mssqlErr mssql.Error
+	return errors.As(err, &mssqlErr) && (mssqlErr.Number == 2627 || mssqlErr.Number == 2601)
+}
+
+// isStatementTooLarge returns true if err is the rejection of a statement above the size limit of the
+// datastore, either by the MySQL driver or server (ER_NET_PACKET_TOO_LARGE), or by Postgres (program_limit_exceeded).
+func isStatementTooLarge(err error) bool {
+	if errors.Is(err, mysql.ErrPktTooLarge) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1153
+	}
+
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "54000"
+}
+
 // DBInfo encapsulates DB information for use in common method.
 type DBInfo struct {
 	db      *sql.DB
 	stbl    sq.StatementBuilderType
 	sqlTime interface{}
+
+	maxStatementSize int
+	splitLargeWrites bool
+
+	// sqlServer is set if the statements are built for SQL Server
+	sqlServer bool
+
+	conditionContextCipher ConditionContextCipher
 }
 
 // NewDBInfo constructs a [DBInfo] object.
@@ -431,6 +602,87 @@ func NewDBInfo(db *sql.DB, stbl sq.StatementBuilderType, sqlTime interface{}) *D
 	}
 }
 
+// WithMaxStatementSize sets the size above which a statement of a write is rejected, or split if
+// splitLargeWrites is set. A size of 0 means no limit.
+func (d *DBInfo) WithMaxStatementSize(size int, splitLargeWrites bool) *DBInfo {
+	d.maxStatementSize = size
+	d.splitLargeWrites = splitLargeWrites
+	return d
+}
+
+// WithConditionContextCipher encrypts the condition contexts of the tuples that are written with c, and
+// decrypts the ones that are read. If c is nil, the condition contexts are written unencrypted, and the
+// encrypted ones can't be read.
+func (d *DBInfo) WithConditionContextCipher(c ConditionContextCipher) *DBInfo {
+	d.conditionContextCipher = c
+	return d
+}
+
+// WithSQLServerDialect makes the common methods build their statements for SQL Server, which limits the rows of
+// a SELECT with TOP instead of LIMIT, locks the rows read with a table hint instead of FOR UPDATE, names the
+// savepoints of transactions with SAVE TRANSACTION, and allows fewer parameters per statement.
+func (d *DBInfo) WithSQLServerDialect() *DBInfo {
+	d.sqlServer = true
+	return d
+}
+
+// limit returns the query limited to its first n rows.
+func (d *DBInfo) limit(sb sq.SelectBuilder, n uint64) sq.SelectBuilder {
+	if d.sqlServer {
+		return sb.Options(fmt.Sprintf("TOP (%d)", n))
+	}
+	return sb.Limit(n)
+}
+
+// selectForUpdate returns a query of the columns of the table that locks the rows it reads until the end of
+// its transaction.
+func (d *DBInfo) selectForUpdate(table string, columns ...string) sq.SelectBuilder {
+	if d.sqlServer {
+		return d.stbl.Select(columns...).From(table + " WITH (UPDLOCK, ROWLOCK)")
+	}
+	return d.stbl.Select(columns...).From(table).Suffix("FOR UPDATE")
+}
+
+// savepoint returns the statement that sets a savepoint named name in the current transaction.
+func (d *DBInfo) savepoint(name string) string {
+	if d.sqlServer {
+		return "SAVE TRANSACTION " + name
+	}
+	return "SAVEPOINT " + name
+}
+
+// rollbackToSavepoint returns the statement that rolls the current transaction back to the savepoint named name.
+func (d *DBInfo) rollbackToSavepoint(name string) string {
+	if d.sqlServer {
+		return "ROLLBACK TRANSACTION " + name
+	}
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// lengthOf returns the SQL expression of the length in bytes of the binary column.
+func (d *DBInfo) lengthOf(column string) string {
+	if d.sqlServer {
+		return "DATALENGTH(" + column + ")"
+	}
+	return "LENGTH(" + column + ")"
+}
+
+// maxChangelogRowsPerStatement is the maximum number of changelog entries inserted with a single statement.
+// MySQL and Postgres allow up to 65535 parameters per statement, and each changelog entry takes 11.
+const maxChangelogRowsPerStatement = 65535 / 11
+
+// maxSQLServerChangelogRowsPerStatement is [maxChangelogRowsPerStatement] for SQL Server, which allows up to
+// 2100 parameters per statement, leaving room for the ones the driver adds.
+const maxSQLServerChangelogRowsPerStatement = 2000 / 11
+
+// maxChangelogRowsPerStatement returns the maximum number of changelog entries inserted with a single statement.
+func (d *DBInfo) maxChangelogRowsPerStatement() int {
+	if d.sqlServer {
+		return maxSQLServerChangelogRowsPerStatement
+	}
+	return maxChangelogRowsPerStatement
+}
+
 // Write provides the common method for writing to database across sql storage.
 func Write(
 	ctx context.Context,
@@ -439,84 +691,312 @@ func Write(
 	deletes storage.Deletes,
 	writes storage.Writes,
 	now time.Time,
+) error {
+	return WriteBatches(ctx, dbInfo, store, []storage.TupleBatch{{Deletes: deletes, Writes: writes}}, now)
+}
+
+// WriteBatches provides the common method for writing several batches of tuples to database within a single
+// transaction across sql storage. The changelog entries are inserted with as many statements as needed
+// to stay within the parameter limit of a statement.
+func WriteBatches(
+	ctx context.Context,
+	dbInfo *DBInfo,
+	store string,
+	batches []storage.TupleBatch,
+	now time.Time,
+) error {
+	return writeBatches(ctx, dbInfo, store, nil, batches, now)
+}
+
+// WriteBatchesIdempotently is like [WriteBatches], but it also inserts the idempotency record within the
+// transaction, after deleting the expired records of the store. If the store has a record with the same key
+// that hasn't expired, it writes nothing and returns a [storage.IdempotencyKeyExistsError].
+func WriteBatchesIdempotently(
+	ctx context.Context,
+	dbInfo *DBInfo,
+	store string,
+	record storage.IdempotencyRecord,
+	batches []storage.TupleBatch,
+	now time.Time,
+) error {
+	return writeBatches(ctx, dbInfo, store, &record, batches, now)
+}
+
+func writeBatches(
+	ctx context.Context,
+	dbInfo *DBInfo,
+	store string,
+	record *storage.IdempotencyRecord,
+	batches []storage.TupleBatch,
+	now time.Time,
 ) error {
 	txn, err := dbInfo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return HandleSQLError(err, nil)
 	}
 
-	changelogBuilder := dbInfo.stbl.
-		Insert("changelog").
-		Columns(
-			"store", "object_type", "object_id", "relation", "_user",
-			"condition_name", "condition_context", "operation", "ulid", "inserted_at",
-		)
+	if record != nil {
+		if err := insertIdempotencyRecord(ctx, txn, dbInfo, store, *record, now); err != nil {
+			if rollbackErr := txn.Rollback(); rollbackErr != nil {
+				return fmt.Errorf("failed to rollback transaction: %v", err)
+			}
+			if errors.Is(err, storage.ErrCollision) {
+				return readIdempotencyRecord(ctx, dbInfo, store, record.Key)
+			}
+			return err
+		}
+	}
+
+	var changelogRows [][]interface{}
+	for _, batch := range batches {
+		rows, err := writeTupleBatch(ctx, txn, dbInfo, store, batch, now)
+		if err != nil {
+			if rollbackErr := txn.Rollback(); rollbackErr != nil {
+				return fmt.Errorf("failed to rollback transaction: %v", err)
+			}
+			return err
+		}
+		changelogRows = append(changelogRows, rows...)
+	}
+
+	if err := insertChangelogRows(ctx, txn, dbInfo, changelogRows); err != nil {
+		if rollbackErr := txn.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("failed to rollback transaction: %v", err)
+		}
+		return err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return HandleSQLError(err, nil)
+	}
+
+	return nil
+}
+
+// insertChangelogRows inserts the changelog entries as part of txn, with as many statements as needed to
+// stay within the parameter limit and the max statement size of dbInfo. It doesn't rollback txn on error.
+func insertChangelogRows(ctx context.Context, txn *sql.Tx, dbInfo *DBInfo, changelogRows [][]interface{}) error {
+	for start, end := 0, 0; start < len(changelogRows); start = end {
+		var err error
+		end, err = nextChangelogStatementEnd(dbInfo, changelogRows, start)
+		if err != nil {
+			return err
+		}
+
+		changelogBuilder := dbInfo.stbl.
+			Insert("changelog").
+			Columns(
+				"store", "object_type", "object_id", "relation", "_user",
+				"condition_name", "condition_context", "condition_context_key_id", "operation", "ulid", "inserted_at",
+			)
+		for _, row := range changelogRows[start:end] {
+			changelogBuilder = changelogBuilder.Values(row...)
+		}
+
+		_, err = changelogBuilder.RunWith(txn).ExecContext(ctx) // Part of a txn.
+		if err != nil {
+			return HandleSQLError(err, nil)
+		}
+	}
+	return nil
+}
+
+// insertIdempotencyRecord inserts the record within txn, after deleting the record of its key if it expired. The
+// other expired records are deleted by the [IdempotencyRecordPurger]. If the store has a record with the same key,
+// the insert fails with [storage.ErrCollision]. The insert of a concurrent transaction with the same key waits
+// for this one to complete.
+func insertIdempotencyRecord(ctx context.Context, txn *sql.Tx, dbInfo *DBInfo, store string, record storage.IdempotencyRecord, now time.Time) error {
+	_, err := dbInfo.stbl.
+		Delete("idempotency_record").
+		Where(sq.Eq{
+			"store":           store,
+			"idempotency_key": record.Key,
+		}).
+		Where(sq.Lt{"expires_at": now}).
+		RunWith(txn). // Part of a txn.
+		ExecContext(ctx)
+	if err != nil {
+		return HandleSQLError(err, nil)
+	}
+
+	_, err = dbInfo.stbl.
+		Insert("idempotency_record").
+		Columns("store", "idempotency_key", "request_hash", "expires_at").
+		Values(store, record.Key, record.RequestHash, record.ExpiresAt.UTC()).
+		RunWith(txn). // Part of a txn.
+		ExecContext(ctx)
+	if err != nil {
+		return HandleSQLError(err, nil)
+	}
+	return nil
+}
+
+// readIdempotencyRecord returns a [storage.IdempotencyKeyExistsError] with the record of the key. If there is
+// none anymore, e.g. because it expired since, it returns [storage.ErrTransactionalWriteFailed] so that the
+// write is retried.
+func readIdempotencyRecord(ctx context.Context, dbInfo *DBInfo, store, key string) error {
+	record := storage.IdempotencyRecord{Key: key}
+	err := dbInfo.stbl.
+		Select("request_hash", "expires_at").
+		From("idempotency_record").
+		Where(sq.Eq{
+			"store":           store,
+			"idempotency_key": key,
+		}).
+		QueryRowContext(ctx).
+		Scan(&record.RequestHash, &record.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrTransactionalWriteFailed
+		}
+		return HandleSQLError(err, nil)
+	}
+	return &storage.IdempotencyKeyExistsError{Record: record}
+}
+
+// nextChangelogStatementEnd returns the end of the changelog rows to insert with the statement starting
+// at start, which stays within the parameter limit and the max statement size of dbInfo. If the statement
+// can't be split, it returns a storage.WriteTooLargeError.
+func nextChangelogStatementEnd(dbInfo *DBInfo, changelogRows [][]interface{}, start int) (int, error) {
+	end := min(start+dbInfo.maxChangelogRowsPerStatement(), len(changelogRows))
+	if dbInfo.maxStatementSize <= 0 {
+		return end, nil
+	}
+
+	size := 0
+	for i := start; i < end; i++ {
+		size += estimatedRowSize(changelogRows[i])
+		if size <= dbInfo.maxStatementSize {
+			continue
+		}
+
+		if !dbInfo.splitLargeWrites || i == start {
+			for _, row := range changelogRows[i+1 : end] {
+				size += estimatedRowSize(row)
+			}
+			return 0, &storage.WriteTooLargeError{Limit: dbInfo.maxStatementSize, Size: size}
+		}
+		return i, nil
+	}
+	return end, nil
+}
+
+// estimatedRowSize returns the size of the values of a row, which is a lower bound of the size it
+// adds to an insert statement.
+func estimatedRowSize(row []interface{}) int {
+	size := 0
+	for _, value := range row {
+		switch v := value.(type) {
+		case string:
+			size += len(v)
+		case []byte:
+			size += len(v)
+		case sql.NullString:
+			size += len(v.String)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// writeTupleBatch deletes and writes the tuples of a batch as part of txn, and returns the values
+// of the changelog entries to insert for them. It doesn't rollback txn on error.
+func writeTupleBatch(
+	ctx context.Context,
+	txn *sql.Tx,
+	dbInfo *DBInfo,
+	store string,
+	batch storage.TupleBatch,
+	now time.Time,
+) ([][]interface{}, error) {
+	changelogRows := make([][]interface{}, 0, len(batch.Deletes)+len(batch.Writes))
+
+	// the changelog entries of the batch must be ordered as the operations are applied
+	ulids := storage.NewULIDGenerator()
+	defer ulids.Release()
 
 	deleteBuilder := dbInfo.stbl.Delete("tuple")
 
-	for _, tk := range deletes {
-		id := ulid.MustNew(ulid.Timestamp(now), ulid.DefaultEntropy()).String()
+	for _, tk := range batch.Deletes {
+		id := ulids.New(now).String()
 		objectType, objectID := tupleUtils.SplitObject(tk.GetObject())
+		where := sq.Eq{
+			"store":       store,
+			"object_type": objectType,
+			"object_id":   objectID,
+			"relation":    tk.GetRelation(),
+			"_user":       tk.GetUser(),
+			"user_type":   tupleUtils.GetUserTypeFromUser(tk.GetUser()),
+		}
+
+		// The condition of the deleted tuple is recorded in the changelog, so that consumers of the
+		// changes can reconstruct the exact state of the tuples.
+		var conditionName, conditionContextKeyID sql.NullString
+		var conditionContext []byte
+		err := dbInfo.selectForUpdate("tuple", "condition_name", "condition_context", "condition_context_key_id").
+			Where(where).
+			RunWith(txn). // Part of a txn.
+			QueryRowContext(ctx).
+			Scan(&conditionName, &conditionContext, &conditionContextKeyID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, storage.InvalidWriteInputError(
+					tk,
+					openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
+				)
+			}
+			return nil, HandleSQLError(err, nil, tk)
+		}
 
 		res, err := deleteBuilder.
-			Where(sq.Eq{
-				"store":       store,
-				"object_type": objectType,
-				"object_id":   objectID,
-				"relation":    tk.GetRelation(),
-				"_user":       tk.GetUser(),
-				"user_type":   tupleUtils.GetUserTypeFromUser(tk.GetUser()),
-			}).
+			Where(where).
 			RunWith(txn). // Part of a txn.
 			ExecContext(ctx)
 		if err != nil {
-			if rollbackErr := txn.Rollback(); rollbackErr != nil {
-				return fmt.Errorf("failed to rollback transaction: %v", err)
-			}
-			return HandleSQLError(err, nil, tk)
+			return nil, HandleSQLError(err, nil, tk)
 		}
 
 		rowsAffected, err := res.RowsAffected()
 		if err != nil {
-			if rollbackErr := txn.Rollback(); rollbackErr != nil {
-				return fmt.Errorf("failed to rollback transaction: %v", err)
-			}
-			return HandleSQLError(err, nil)
+			return nil, HandleSQLError(err, nil)
 		}
 
 		if rowsAffected != 1 {
-			if rollbackErr := txn.Rollback(); rollbackErr != nil {
-				return fmt.Errorf("failed to rollback transaction: %v", err)
-			}
-			return storage.InvalidWriteInputError(
+			return nil, storage.InvalidWriteInputError(
 				tk,
 				openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
 			)
 		}
 
-		changelogBuilder = changelogBuilder.Values(
+		changelogRows = append(changelogRows, []interface{}{
 			store, objectType, objectID,
 			tk.GetRelation(), tk.GetUser(),
-			"", nil, // Redact condition info for deletes since we only need the base triplet (object, relation, user).
+			conditionName.String, conditionContext, conditionContextKeyID,
 			openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
 			id, dbInfo.sqlTime,
-		)
+		})
 	}
 
 	insertBuilder := dbInfo.stbl.
 		Insert("tuple").
 		Columns(
 			"store", "object_type", "object_id", "relation", "_user", "user_type",
-			"condition_name", "condition_context", "ulid", "inserted_at",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
 		)
 
-	for _, tk := range writes {
-		id := ulid.MustNew(ulid.Timestamp(now), ulid.DefaultEntropy()).String()
+	keyID, err := dbInfo.conditionContextKeyID(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tk := range batch.Writes {
+		id := ulids.New(now).String()
 		objectType, objectID := tupleUtils.SplitObject(tk.GetObject())
 
-		conditionName, conditionContext, err := marshalRelationshipCondition(tk.GetCondition())
+		conditionName, conditionContext, conditionContextKeyID, err := dbInfo.marshalRelationshipCondition(ctx, keyID, store, tk)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		_, err = insertBuilder.
@@ -529,19 +1009,17 @@ func Write(
 				tupleUtils.GetUserTypeFromUser(tk.GetUser()),
 				conditionName,
 				conditionContext,
+				conditionContextKeyID,
 				id,
 				dbInfo.sqlTime,
 			).
 			RunWith(txn). // Part of a txn.
 			ExecContext(ctx)
 		if err != nil {
-			if rollbackErr := txn.Rollback(); rollbackErr != nil {
-				return fmt.Errorf("failed to rollback transaction: %v", err)
-			}
-			return HandleSQLError(err, nil, tk)
+			return nil, HandleSQLError(err, nil, tk)
 		}
 
-		changelogBuilder = changelogBuilder.Values(
+		changelogRows = append(changelogRows, []interface{}{
 			store,
 			objectType,
 			objectID,
@@ -549,27 +1027,14 @@ func Write(
 			tk.GetUser(),
 			conditionName,
 			conditionContext,
+			conditionContextKeyID,
 			openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
 			id,
 			dbInfo.sqlTime,
-		)
+		})
 	}
 
-	if len(writes) > 0 || len(deletes) > 0 {
-		_, err := changelogBuilder.RunWith(txn).ExecContext(ctx) // Part of a txn.
-		if err != nil {
-			if rollbackErr := txn.Rollback(); rollbackErr != nil {
-				return fmt.Errorf("failed to rollback transaction: %v", err)
-			}
-			return HandleSQLError(err, nil)
-		}
-	}
-
-	if err := txn.Commit(); err != nil {
-		return HandleSQLError(err, nil)
-	}
-
-	return nil
+	return changelogRows, nil
 }
 
 // WriteAuthorizationModel writes an authorization model for the given store.
@@ -603,6 +1068,106 @@ func WriteAuthorizationModel(
 	return nil
 }
 
+// PruneAuthorizationModels provides the common method for deleting the old authorization models of a store across
+// sql storage, see [storage.AuthorizationModelPruner].
+func PruneAuthorizationModels(
+	ctx context.Context,
+	dbInfo *DBInfo,
+	store string,
+	retain int,
+	keep []string,
+) (int, error) {
+	txn, err := dbInfo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, HandleSQLError(err, nil)
+	}
+
+	pruned, err := pruneAuthorizationModels(ctx, txn, dbInfo, store, retain, keep)
+	if err != nil {
+		if rollbackErr := txn.Rollback(); rollbackErr != nil {
+			return 0, fmt.Errorf("failed to rollback transaction: %v", err)
+		}
+		return 0, err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return 0, HandleSQLError(err, nil)
+	}
+
+	return pruned, nil
+}
+
+// pruneAuthorizationModels deletes the models to prune as part of txn. It doesn't rollback txn on error.
+func pruneAuthorizationModels(ctx context.Context, txn *sql.Tx, dbInfo *DBInfo, store string, retain int, keep []string) (int, error) {
+	// the models written concurrently are newer than the ones read here, so they are never pruned
+	modelIDs, err := selectStrings(ctx, dbInfo.stbl.
+		Select("DISTINCT authorization_model_id").
+		From("authorization_model").
+		Where(sq.Eq{"store": store}).
+		OrderBy("authorization_model_id desc").
+		RunWith(txn)) // Part of a txn.
+	if err != nil {
+		return 0, err
+	}
+	// the latest model is the first one
+	modelIDs = modelIDs[min(max(retain, 1), len(modelIDs)):]
+
+	withAssertions, err := selectStrings(ctx, dbInfo.stbl.
+		Select("authorization_model_id").
+		From("assertion").
+		Where(sq.Eq{"store": store}).
+		Where(sq.Expr(dbInfo.lengthOf("assertions")+" > 0")).
+		RunWith(txn)) // Part of a txn.
+	if err != nil {
+		return 0, err
+	}
+
+	var toPrune []string
+	for _, id := range modelIDs {
+		if !slices.Contains(withAssertions, id) && !slices.Contains(keep, id) {
+			toPrune = append(toPrune, id)
+		}
+	}
+	if len(toPrune) == 0 {
+		return 0, nil
+	}
+
+	for _, table := range []string{"authorization_model", "assertion"} {
+		_, err := dbInfo.stbl.
+			Delete(table).
+			Where(sq.Eq{"store": store, "authorization_model_id": toPrune}).
+			RunWith(txn). // Part of a txn.
+			ExecContext(ctx)
+		if err != nil {
+			return 0, HandleSQLError(err, nil)
+		}
+	}
+
+	return len(toPrune), nil
+}
+
+// selectStrings returns the values of the single column selected by the query.
+func selectStrings(ctx context.Context, query sq.SelectBuilder) ([]string, error) {
+	rows, err := query.QueryContext(ctx)
+	if err != nil {
+		return nil, HandleSQLError(err, nil)
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, HandleSQLError(err, nil)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, HandleSQLError(err, nil)
+	}
+	return values, nil
+}
+
 func constructAuthorizationModelFromSQLRows(rows *sql.Rows) (*openfgav1.AuthorizationModel, error) {
 	var modelID string
 	var schemaVersion string
@@ -655,12 +1220,11 @@ func FindLatestAuthorizationModel(
 	dbInfo *DBInfo,
 	store string,
 ) (*openfgav1.AuthorizationModel, error) {
-	rows, err := dbInfo.stbl.
+	rows, err := dbInfo.limit(dbInfo.stbl.
 		Select("authorization_model_id", "schema_version", "type", "type_definition", "serialized_protobuf").
 		From("authorization_model").
 		Where(sq.Eq{"store": store}).
-		OrderBy("authorization_model_id desc").
-		Limit(1).
+		OrderBy("authorization_model_id desc"), 1).
 		QueryContext(ctx)
 	if err != nil {
 		return nil, HandleSQLError(err, nil)
@@ -690,6 +1254,192 @@ func ReadAuthorizationModel(
 	return constructAuthorizationModelFromSQLRows(rows)
 }
 
+// ReadTupleCountsByRelation counts the tuples of the store grouped by object type and relation. The tuples
+// are read from table, which is either "tuple" or a sample of it, and the counts are multiplied by scale
+// to estimate the counts of the whole table when it is a sample.
+func ReadTupleCountsByRelation(
+	ctx context.Context,
+	dbInfo *DBInfo,
+	table string,
+	store string,
+	scale int64,
+) ([]storage.RelationTupleCount, error) {
+	rows, err := dbInfo.stbl.
+		Select("object_type", "relation", "COUNT(*)").
+		From(table).
+		Where(sq.Eq{"store": store}).
+		GroupBy("object_type", "relation").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, HandleSQLError(err, nil)
+	}
+	defer rows.Close()
+
+	var counts []storage.RelationTupleCount
+	for rows.Next() {
+		var count storage.RelationTupleCount
+		if err := rows.Scan(&count.ObjectType, &count.Relation, &count.Count); err != nil {
+			return nil, HandleSQLError(err, nil)
+		}
+		count.Count *= scale
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, HandleSQLError(err, nil)
+	}
+
+	return counts, nil
+}
+
+// CreateStoreWithUniqueName see [storage.UniqueStoreNameCreator.CreateStoreWithUniqueName]. The unique index on
+// the unique_name column prevents two stores created concurrently with this method from having the same name.
+func CreateStoreWithUniqueName(ctx context.Context, dbInfo *DBInfo, store *openfgav1.Store) (*openfgav1.Store, error) {
+	var exists int
+	err := dbInfo.limit(dbInfo.stbl.
+		Select("1").
+		From("store").
+		Where(sq.Eq{
+			"name":       store.GetName(),
+			"deleted_at": nil,
+		}), 1).
+		QueryRowContext(ctx).
+		Scan(&exists)
+	if err == nil {
+		return nil, &storage.StoreNameConflictError{Name: store.GetName()}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, HandleSQLError(err, nil)
+	}
+
+	now := time.Now().UTC()
+	_, err = dbInfo.stbl.
+		Insert("store").
+		Columns("id", "name", "unique_name", "created_at", "updated_at").
+		Values(store.GetId(), store.GetName(), store.GetName(), now, now).
+		ExecContext(ctx)
+	if err != nil {
+		err = HandleSQLError(err, nil)
+		if errors.Is(err, storage.ErrCollision) {
+			return nil, &storage.StoreNameConflictError{Name: store.GetName()}
+		}
+		return nil, err
+	}
+
+	return &openfgav1.Store{
+		Id:        store.GetId(),
+		Name:      store.GetName(),
+		CreatedAt: timestamppb.New(now),
+		UpdatedAt: timestamppb.New(now),
+	}, nil
+}
+
+// ReadStoreSettings reads the settings of the store. A store without settings, or no store at all,
+// has empty settings.
+func ReadStoreSettings(ctx context.Context, dbInfo *DBInfo, store string) (*storage.StoreSettings, error) {
+	var allowedObjectTypes, relationAliases, defaultUserType sql.NullString
+	var defaultPageSize, maxAuthorizationModels sql.NullInt32
+	err := dbInfo.stbl.
+		Select("allowed_object_types", "default_page_size", "relation_aliases", "default_user_type", "max_authorization_models").
+		From("store").
+		Where(sq.Eq{
+			"id":         store,
+			"deleted_at": nil,
+		}).
+		QueryRowContext(ctx).
+		Scan(&allowedObjectTypes, &defaultPageSize, &relationAliases, &defaultUserType, &maxAuthorizationModels)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &storage.StoreSettings{}, nil
+		}
+		return nil, HandleSQLError(err, nil)
+	}
+
+	settings := &storage.StoreSettings{
+		DefaultPageSize:        defaultPageSize.Int32,
+		DefaultUserType:        defaultUserType.String,
+		MaxAuthorizationModels: maxAuthorizationModels.Int32,
+	}
+	if allowedObjectTypes.Valid && allowedObjectTypes.String != "" {
+		if err := json.Unmarshal([]byte(allowedObjectTypes.String), &settings.AllowedObjectTypes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the allowed object types of store '%s': %w", store, err)
+		}
+	}
+	if relationAliases.Valid && relationAliases.String != "" {
+		if err := json.Unmarshal([]byte(relationAliases.String), &settings.RelationAliases); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the relation aliases of store '%s': %w", store, err)
+		}
+	}
+	return settings, nil
+}
+
+// WriteStoreSettings overwrites the settings of the store.
+func WriteStoreSettings(ctx context.Context, dbInfo *DBInfo, store string, settings *storage.StoreSettings) error {
+	var id string
+	err := dbInfo.stbl.
+		Select("id").
+		From("store").
+		Where(sq.Eq{
+			"id":         store,
+			"deleted_at": nil,
+		}).
+		QueryRowContext(ctx).
+		Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &storage.StoreNotFoundError{StoreID: store}
+		}
+		return HandleSQLError(err, nil)
+	}
+
+	var allowedObjectTypes sql.NullString
+	if len(settings.AllowedObjectTypes) > 0 {
+		marshalled, err := json.Marshal(settings.AllowedObjectTypes)
+		if err != nil {
+			return err
+		}
+		allowedObjectTypes = sql.NullString{String: string(marshalled), Valid: true}
+	}
+
+	var defaultPageSize sql.NullInt32
+	if settings.DefaultPageSize > 0 {
+		defaultPageSize = sql.NullInt32{Int32: settings.DefaultPageSize, Valid: true}
+	}
+
+	var relationAliases sql.NullString
+	if len(settings.RelationAliases) > 0 {
+		marshalled, err := json.Marshal(settings.RelationAliases)
+		if err != nil {
+			return err
+		}
+		relationAliases = sql.NullString{String: string(marshalled), Valid: true}
+	}
+
+	var defaultUserType sql.NullString
+	if settings.DefaultUserType != "" {
+		defaultUserType = sql.NullString{String: settings.DefaultUserType, Valid: true}
+	}
+
+	var maxAuthorizationModels sql.NullInt32
+	if settings.MaxAuthorizationModels > 0 {
+		maxAuthorizationModels = sql.NullInt32{Int32: settings.MaxAuthorizationModels, Valid: true}
+	}
+
+	_, err = dbInfo.stbl.
+		Update("store").
+		Set("allowed_object_types", allowedObjectTypes).
+		Set("default_page_size", defaultPageSize).
+		Set("relation_aliases", relationAliases).
+		Set("default_user_type", defaultUserType).
+		Set("max_authorization_models", maxAuthorizationModels).
+		Set("updated_at", dbInfo.sqlTime).
+		Where(sq.Eq{"id": store}).
+		ExecContext(ctx)
+	if err != nil {
+		return HandleSQLError(err, nil)
+	}
+	return nil
+}
+
 // IsReady returns true if the connection to the datastore is successful
 // and the datastore has the latest migration applied.
 func IsReady(ctx context.Context, db *sql.DB) (storage.ReadinessStatus, error) {
@@ -716,3 +1466,41 @@ func IsReady(ctx context.Context, db *sql.DB) (storage.ReadinessStatus, error) {
 		IsReady: true,
 	}, nil
 }
+
+// MigrateSchema runs the embedded migrations of migrationsDir against db if autoMigrate is true. Otherwise, it
+// returns an error if db misses any of them, so that a server doesn't start against a schema older than the one
+// it was built for.
+func MigrateSchema(db *sql.DB, dialect, migrationsDir string, autoMigrate bool) error {
+	goose.SetLogger(goose.NopLogger())
+	goose.SetBaseFS(assets.EmbedMigrations)
+	if err := goose.SetDialect(dialect); err != nil {
+		return err
+	}
+
+	if autoMigrate {
+		if err := goose.Up(db, migrationsDir); err != nil {
+			return fmt.Errorf("run migrations: %w", err)
+		}
+		return nil
+	}
+
+	migrations, err := goose.CollectMigrations(migrationsDir, 0, goose.MaxVersion)
+	if err != nil {
+		return fmt.Errorf("collect migrations: %w", err)
+	}
+	latest, err := migrations.Last()
+	if err != nil {
+		return fmt.Errorf("collect migrations: %w", err)
+	}
+
+	revision, err := goose.GetDBVersion(db)
+	if err != nil {
+		return fmt.Errorf("get schema revision: %w", err)
+	}
+
+	if revision < latest.Version {
+		return fmt.Errorf("datastore requires migrations: at revision '%d', but requires '%d'. Run 'openfga migrate' or enable the auto-migration", revision, latest.Version)
+	}
+
+	return nil
+}
@@ -1,11 +1,15 @@
 package sqlcommon
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"github.com/stretchr/testify/require"
 
@@ -55,4 +59,110 @@ func TestHandleSQLError(t *testing.T) {
 		err := HandleSQLError(sql.ErrNoRows, nil)
 		require.ErrorIs(t, err, storage.ErrNotFound)
 	})
+
+	t.Run("statement_too_large_errors_are_converted_to_storage.WriteTooLargeError", func(t *testing.T) {
+		for _, tooLargeErr := range []error{
+			mysql.ErrPktTooLarge,
+			&mysql.MySQLError{Number: 1153, Message: "Got a packet bigger than 'max_allowed_packet' bytes"},
+			&pgconn.PgError{Code: "54000", Message: "total size of jsonb array elements exceeds the maximum"},
+		} {
+			err := HandleSQLError(tooLargeErr, nil)
+			require.ErrorIs(t, err, storage.ErrWriteTooLarge)
+			require.ErrorIs(t, err, tooLargeErr)
+			require.EqualError(t, err, "write exceeds the datastore size limit, split it into smaller writes")
+		}
+	})
+}
+
+func TestNextChangelogStatementEnd(t *testing.T) {
+	// each row is 10 bytes
+	rows := [][]interface{}{{"0123456789"}, {"0123456789"}, {"0123456789"}, {"0123456789"}}
+
+	t.Run("no_limit", func(t *testing.T) {
+		end, err := nextChangelogStatementEnd(&DBInfo{}, rows, 1)
+		require.NoError(t, err)
+		require.Equal(t, 4, end)
+	})
+
+	t.Run("within_the_limit", func(t *testing.T) {
+		end, err := nextChangelogStatementEnd((&DBInfo{}).WithMaxStatementSize(40, false), rows, 0)
+		require.NoError(t, err)
+		require.Equal(t, 4, end)
+	})
+
+	t.Run("above_the_limit_is_rejected", func(t *testing.T) {
+		_, err := nextChangelogStatementEnd((&DBInfo{}).WithMaxStatementSize(25, false), rows, 0)
+		var tooLargeErr *storage.WriteTooLargeError
+		require.ErrorAs(t, err, &tooLargeErr)
+		require.Equal(t, &storage.WriteTooLargeError{Limit: 25, Size: 40}, tooLargeErr)
+		require.EqualError(t, err, "write of 40 bytes exceeds the datastore limit of 25 bytes per statement, split it into smaller writes")
+	})
+
+	t.Run("above_the_limit_is_split", func(t *testing.T) {
+		dbInfo := (&DBInfo{}).WithMaxStatementSize(25, true)
+		end, err := nextChangelogStatementEnd(dbInfo, rows, 0)
+		require.NoError(t, err)
+		require.Equal(t, 2, end)
+
+		end, err = nextChangelogStatementEnd(dbInfo, rows, end)
+		require.NoError(t, err)
+		require.Equal(t, 4, end)
+	})
+
+	t.Run("a_row_above_the_limit_is_rejected", func(t *testing.T) {
+		_, err := nextChangelogStatementEnd((&DBInfo{}).WithMaxStatementSize(5, true), rows, 0)
+		require.ErrorIs(t, err, storage.ErrWriteTooLarge)
+	})
+}
+
+func FuzzUnmarshallContToken(f *testing.F) {
+	valid, err := json.Marshal(NewContToken("01ARZ3NDEKTSV4RRFFQ69G5FAV", "document"))
+	require.NoError(f, err)
+
+	for _, seed := range []string{"", string(valid), "{", "null", "[]", "{\"Ulid\":1}", "\xff"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, token string) {
+		contToken, err := UnmarshallContToken(token)
+		if err != nil {
+			require.Nil(t, contToken)
+			require.ErrorIs(t, err, storage.ErrInvalidContinuationToken)
+			var tokenErr *storage.InvalidContinuationTokenError
+			require.ErrorAs(t, err, &tokenErr)
+		}
+	})
+}
+
+func TestContextWithTimeout(t *testing.T) {
+	t.Run("zero_timeout_returns_the_context_unchanged", func(t *testing.T) {
+		ctx := context.Background()
+		timeoutCtx, cancel := ContextWithTimeout(ctx, 0)
+		defer cancel()
+
+		require.Equal(t, ctx, timeoutCtx)
+		_, ok := timeoutCtx.Deadline()
+		require.False(t, ok)
+	})
+
+	t.Run("positive_timeout_sets_a_deadline", func(t *testing.T) {
+		timeoutCtx, cancel := ContextWithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		deadline, ok := timeoutCtx.Deadline()
+		require.True(t, ok)
+		require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	})
+
+	t.Run("earlier_deadline_of_the_caller_is_kept", func(t *testing.T) {
+		ctx, cancelParent := context.WithTimeout(context.Background(), time.Second)
+		defer cancelParent()
+		parentDeadline, _ := ctx.Deadline()
+
+		timeoutCtx, cancel := ContextWithTimeout(ctx, time.Minute)
+		defer cancel()
+
+		deadline, _ := timeoutCtx.Deadline()
+		require.Equal(t, parentDeadline, deadline)
+	})
 }
@@ -0,0 +1,222 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// WriteBatcher groups the writes of tuples made concurrently within a short delay into a single transaction,
+// so that their changelog entries are inserted with a few grouped statements instead of one insert per
+// write, which relieves the changelog table under high write throughput at the cost of the delay.
+//
+// The tuples and the changelog entries of a write are still committed by the same transaction, so a write
+// that succeeds has all its changelog entries. Each write of a group runs within its own savepoint, so a
+// write that fails, e.g. because it deletes a tuple that doesn't exist, fails alone and doesn't fail the
+// others. Up to maxConcurrentGroups groups are committed at the same time. The ULIDs of the changelog entries
+// of a group are generated in the order of its writes, so the changelog of a group is ordered like its writes,
+// while the entries of the groups committed concurrently may interleave, like the ones of concurrent writes
+// that aren't grouped.
+type WriteBatcher struct {
+	dbInfo *DBInfo
+	// groupDBInfo splits the changelog entries of a group into several statements if needed.
+	groupDBInfo *DBInfo
+	maxDelay    time.Duration
+	maxWrites   int
+	timeout     time.Duration
+
+	mu         sync.Mutex
+	pending    []*batchedWrite
+	generation uint64
+	closed     bool
+
+	// committing bounds the number of groups committed at the same time.
+	committing chan struct{}
+	wg         sync.WaitGroup
+}
+
+type batchedWrite struct {
+	ctx     context.Context
+	store   string
+	batches []storage.TupleBatch
+	err     error
+	done    chan struct{}
+}
+
+// NewWriteBatcher returns a [WriteBatcher] that commits a group of writes once it has maxWrites writes, or
+// maxDelay after its first write, whichever comes first. If maxWrites is 0, the size of a group isn't
+// bounded. At most maxConcurrentGroups groups are committed at the same time, or one if it isn't greater
+// than zero. The transaction of a group is bounded by timeout, unless it is 0.
+func NewWriteBatcher(dbInfo *DBInfo, maxDelay time.Duration, maxWrites, maxConcurrentGroups int, timeout time.Duration) *WriteBatcher {
+	groupDBInfo := *dbInfo
+	groupDBInfo.splitLargeWrites = true
+
+	return &WriteBatcher{
+		dbInfo:      dbInfo,
+		groupDBInfo: &groupDBInfo,
+		maxDelay:    maxDelay,
+		maxWrites:   maxWrites,
+		timeout:     timeout,
+		committing:  make(chan struct{}, max(1, maxConcurrentGroups)),
+	}
+}
+
+// WriteBatches writes the batches of tuples of the store as part of the next group, and returns once the
+// group is committed. If ctx is done before then, it returns the error of ctx, and the write may or may not
+// be committed, as with a write whose deadline expires while it's being committed.
+func (b *WriteBatcher) WriteBatches(ctx context.Context, store string, batches []storage.TupleBatch) error {
+	w := &batchedWrite{
+		ctx:     ctx,
+		store:   store,
+		batches: batches,
+		done:    make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return fmt.Errorf("write batcher closed")
+	}
+	b.pending = append(b.pending, w)
+	switch {
+	case b.maxWrites > 0 && len(b.pending) >= b.maxWrites:
+		b.flushLocked()
+	case len(b.pending) == 1:
+		generation := b.generation
+		time.AfterFunc(b.maxDelay, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			// the group may have been flushed already because it was full
+			if b.generation == generation {
+				b.flushLocked()
+			}
+		})
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return w.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close commits the pending writes and waits for all the groups to be committed. Writes made after Close
+// fail.
+func (b *WriteBatcher) Close() {
+	b.mu.Lock()
+	b.closed = true
+	if len(b.pending) > 0 {
+		b.flushLocked()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// flushLocked commits the pending writes in the background. b.mu must be held.
+func (b *WriteBatcher) flushLocked() {
+	writes := b.pending
+	b.pending = nil
+	b.generation++
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		b.committing <- struct{}{}
+		defer func() { <-b.committing }()
+
+		err := b.commit(writes)
+		for _, w := range writes {
+			if w.err == nil {
+				w.err = err
+			}
+			close(w.done)
+		}
+	}()
+}
+
+// commit writes the tuples of the writes, each within its own savepoint, then inserts the changelog entries
+// of the writes that succeeded, within a single transaction. The writes that fail have their error set. If
+// the transaction fails, it returns the error, which is the error of all the other writes.
+func (b *WriteBatcher) commit(writes []*batchedWrite) error {
+	// the writes of the group outlive the context of any single one of them
+	ctx, cancel := ContextWithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	txn, err := b.dbInfo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return HandleSQLError(err, nil)
+	}
+
+	now := time.Now().UTC()
+	var changelogRows [][]interface{}
+	for i, w := range writes {
+		if err := w.ctx.Err(); err != nil {
+			w.err = err
+			continue
+		}
+
+		savepoint := fmt.Sprintf("write_%d", i)
+		if _, err := txn.ExecContext(ctx, b.dbInfo.savepoint(savepoint)); err != nil {
+			if rollbackErr := txn.Rollback(); rollbackErr != nil {
+				return fmt.Errorf("failed to rollback transaction: %v", err)
+			}
+			return HandleSQLError(err, nil)
+		}
+
+		rows, err := b.writeTuples(ctx, txn, w, now)
+		if err != nil {
+			if _, rollbackErr := txn.ExecContext(ctx, b.dbInfo.rollbackToSavepoint(savepoint)); rollbackErr != nil {
+				if rollbackErr := txn.Rollback(); rollbackErr != nil {
+					return fmt.Errorf("failed to rollback transaction: %v", err)
+				}
+				return err
+			}
+			w.err = err
+			continue
+		}
+		changelogRows = append(changelogRows, rows...)
+	}
+
+	if err := insertChangelogRows(ctx, txn, b.groupDBInfo, changelogRows); err != nil {
+		if rollbackErr := txn.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("failed to rollback transaction: %v", err)
+		}
+		return err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return HandleSQLError(err, nil)
+	}
+
+	return nil
+}
+
+// writeTuples writes the tuples of a write as part of txn, and returns the values of its changelog entries.
+func (b *WriteBatcher) writeTuples(ctx context.Context, txn *sql.Tx, w *batchedWrite, now time.Time) ([][]interface{}, error) {
+	var changelogRows [][]interface{}
+	for _, batch := range w.batches {
+		rows, err := writeTupleBatch(ctx, txn, b.dbInfo, w.store, batch, now)
+		if err != nil {
+			return nil, err
+		}
+		changelogRows = append(changelogRows, rows...)
+	}
+
+	// a write too large on its own is rejected as if it wasn't grouped
+	for start, end := 0, 0; start < len(changelogRows); start = end {
+		var err error
+		if end, err = nextChangelogStatementEnd(b.dbInfo, changelogRows, start); err != nil {
+			return nil, err
+		}
+	}
+
+	return changelogRows, nil
+}
@@ -0,0 +1,222 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// fakeDatabase is a database/sql driver that accepts every statement, and records the statements and the
+// transactions, so that the WriteBatcher can be tested without a database. Commits wait for release to be
+// closed, if it is set.
+type fakeDatabase struct {
+	release chan struct{}
+
+	mu         sync.Mutex
+	statements []string
+	active     int
+	maxActive  int
+	commits    int
+}
+
+func (d *fakeDatabase) open(t *testing.T) *sql.DB {
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (d *fakeDatabase) inserts(table string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := 0
+	for _, statement := range d.statements {
+		if strings.HasPrefix(statement, "INSERT INTO "+table+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *fakeDatabase) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: d}, nil }
+func (d *fakeDatabase) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDatabase }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.active++
+	c.db.maxActive = max(c.db.maxActive, c.db.active)
+	return &fakeTx{db: c.db}, nil
+}
+
+type fakeStmt struct {
+	db    *fakeDatabase
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.statements = append(s.db.statements, s.query)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries are not supported")
+}
+
+type fakeTx struct{ db *fakeDatabase }
+
+func (tx *fakeTx) Commit() error {
+	if tx.db.release != nil {
+		<-tx.db.release
+	}
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	tx.db.active--
+	tx.db.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	tx.db.active--
+	return nil
+}
+
+func TestWriteBatcher(t *testing.T) {
+	ctx := context.Background()
+
+	newWriteBatcher := func(t *testing.T, db *fakeDatabase, maxDelay time.Duration, maxWrites, maxConcurrentGroups int) *WriteBatcher {
+		conn := db.open(t)
+		dbInfo := NewDBInfo(conn, sq.StatementBuilder.RunWith(conn), sq.Expr("NOW()"))
+		b := NewWriteBatcher(dbInfo, maxDelay, maxWrites, maxConcurrentGroups, 0)
+		t.Cleanup(b.Close)
+		return b
+	}
+
+	write := func(b *WriteBatcher, i int) error {
+		return b.WriteBatches(ctx, "store", []storage.TupleBatch{{
+			Writes: []*openfgav1.TupleKey{tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:anne")},
+		}})
+	}
+
+	t.Run("concurrent_writes_are_committed_in_one_group", func(t *testing.T) {
+		db := &fakeDatabase{}
+		b := newWriteBatcher(t, db, time.Minute, 5, 1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				require.NoError(t, write(b, i))
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, 1, db.commits)
+		require.Equal(t, 5, db.inserts("tuple"))
+		require.Equal(t, 1, db.inserts("changelog"))
+	})
+
+	t.Run("groups_are_committed_concurrently_up_to_the_limit", func(t *testing.T) {
+		db := &fakeDatabase{release: make(chan struct{})}
+		b := newWriteBatcher(t, db, time.Minute, 1, 2)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				require.NoError(t, write(b, i))
+			}()
+		}
+
+		require.Eventually(t, func() bool {
+			db.mu.Lock()
+			defer db.mu.Unlock()
+			return db.active == 2
+		}, 5*time.Second, time.Millisecond)
+
+		close(db.release)
+		wg.Wait()
+
+		require.Equal(t, 4, db.commits)
+		require.Equal(t, 2, db.maxActive)
+	})
+
+	t.Run("savepoints_are_set_in_the_dialect_of_the_database", func(t *testing.T) {
+		for _, test := range []struct {
+			name      string
+			sqlServer bool
+			savepoint string
+		}{
+			{name: "default", savepoint: "SAVEPOINT write_0"},
+			{name: "sqlserver", sqlServer: true, savepoint: "SAVE TRANSACTION write_0"},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				db := &fakeDatabase{}
+				conn := db.open(t)
+				dbInfo := NewDBInfo(conn, sq.StatementBuilder.RunWith(conn), sq.Expr("NOW()"))
+				if test.sqlServer {
+					dbInfo = dbInfo.WithSQLServerDialect()
+				}
+				b := NewWriteBatcher(dbInfo, time.Minute, 1, 1, 0)
+				t.Cleanup(b.Close)
+
+				require.NoError(t, write(b, 1))
+				require.Contains(t, db.statements, test.savepoint)
+			})
+		}
+	})
+
+	t.Run("pending_writes_are_committed_on_close", func(t *testing.T) {
+		db := &fakeDatabase{}
+		b := newWriteBatcher(t, db, time.Hour, 0, 1)
+
+		done := make(chan error)
+		go func() { done <- write(b, 1) }()
+
+		require.Eventually(t, func() bool {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return len(b.pending) == 1
+		}, 5*time.Second, time.Millisecond)
+
+		b.Close()
+		require.NoError(t, <-done)
+		require.Equal(t, 1, db.commits)
+
+		require.Error(t, write(b, 2))
+	})
+}
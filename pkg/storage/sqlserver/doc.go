@@ -0,0 +1,2 @@
+// Package sqlserver contains an implementation of the storage interface that works with Microsoft SQL Server.
+package sqlserver
@@ -0,0 +1,934 @@
+package sqlserver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cenkalti/backoff/v4"
+	_ "github.com/microsoft/go-mssqldb" // SQL Server driver.
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/openfga/openfga/assets"
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+var tracer = otel.Tracer("openfga/pkg/storage/sqlserver")
+
+// SQLServer provides a SQL Server based implementation of [storage.OpenFGADatastore].
+type SQLServer struct {
+	stbl                   sq.StatementBuilderType
+	db                     *sql.DB
+	dbInfo                 *sqlcommon.DBInfo
+	logger                 logger.Logger
+	dbStatsCollector       prometheus.Collector
+	maxTuplesPerWriteField int
+	maxTypesPerModelField  int
+	readTimeout            time.Duration
+	writeTimeout           time.Duration
+	// writeBatcher is nil if the writes are not batched
+	writeBatcher            *sqlcommon.WriteBatcher
+	idempotencyRecordPurger *sqlcommon.IdempotencyRecordPurger
+}
+
+// Ensures that SQLServer implements the OpenFGADatastore interface.
+var (
+	_ storage.OpenFGADatastore           = (*SQLServer)(nil)
+	_ storage.TransactionalBatchWriter   = (*SQLServer)(nil)
+	_ storage.IdempotentWriter           = (*SQLServer)(nil)
+	_ storage.AuthorizationModelPruner   = (*SQLServer)(nil)
+	_ storage.ConditionContextKeyRotator = (*SQLServer)(nil)
+)
+
+// New creates a new [SQLServer] storage. The credentials of the uri and of the config are redacted from the
+// errors returned.
+func New(uri string, cfg *sqlcommon.Config) (*SQLServer, error) {
+	s, err := newSQLServer(uri, cfg)
+	if err != nil {
+		return nil, sqlcommon.RedactCredentials(err, uri, cfg.Password)
+	}
+	return s, nil
+}
+
+func newSQLServer(uri string, cfg *sqlcommon.Config) (*SQLServer, error) {
+	if cfg.Username != "" || cfg.Password != "" {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return nil, fmt.Errorf("parse sqlserver connection uri: %w", err)
+		}
+
+		username := ""
+		if cfg.Username != "" {
+			username = cfg.Username
+		} else if parsed.User != nil {
+			username = parsed.User.Username()
+		}
+
+		switch {
+		case cfg.Password != "":
+			parsed.User = url.UserPassword(username, cfg.Password)
+		case parsed.User != nil:
+			if password, ok := parsed.User.Password(); ok {
+				parsed.User = url.UserPassword(username, password)
+			} else {
+				parsed.User = url.User(username)
+			}
+		default:
+			parsed.User = url.User(username)
+		}
+
+		uri = parsed.String()
+	}
+
+	db, err := sql.Open("sqlserver", uri)
+	if err != nil {
+		return nil, fmt.Errorf("initialize sqlserver connection: %w", err)
+	}
+	return NewWithDB(db, cfg)
+}
+
+// NewWithDB creates a new [SQLServer] storage with the provided database connection.
+func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*SQLServer, error) {
+	if cfg.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	if cfg.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
+	if cfg.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	if cfg.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	policy := backoff.NewExponentialBackOff()
+	policy.MaxElapsedTime = 1 * time.Minute
+	attempt := 1
+	err := backoff.Retry(func() error {
+		err := db.PingContext(context.Background())
+		if err != nil {
+			cfg.Logger.Info("waiting for sqlserver", zap.Int("attempt", attempt))
+			attempt++
+			return err
+		}
+		return nil
+	}, policy)
+	if err != nil {
+		return nil, fmt.Errorf("ping db: %w", err)
+	}
+
+	if err := sqlcommon.MigrateSchema(db, "sqlserver", assets.SQLServerMigrationDir, cfg.AutoMigrate); err != nil {
+		return nil, err
+	}
+
+	var collector prometheus.Collector
+	if cfg.ExportMetrics {
+		collector = collectors.NewDBStatsCollector(db, "openfga")
+		if err := prometheus.Register(collector); err != nil {
+			return nil, fmt.Errorf("initialize metrics: %w", err)
+		}
+	}
+	stbl := sq.StatementBuilder.PlaceholderFormat(sq.AtP).RunWith(sqlcommon.NewRetryingRunner(db, cfg.Logger))
+	dbInfo := sqlcommon.NewDBInfo(db, stbl, sq.Expr("SYSUTCDATETIME()")).
+		WithMaxStatementSize(cfg.MaxStatementSizeInBytes, cfg.SplitLargeWrites).
+		WithSQLServerDialect().
+		WithConditionContextCipher(cfg.ConditionContextCipher)
+
+	var writeBatcher *sqlcommon.WriteBatcher
+	if cfg.ChangelogBatchDelay > 0 {
+		writeBatcher = sqlcommon.NewWriteBatcher(dbInfo, cfg.ChangelogBatchDelay, cfg.ChangelogBatchMaxWrites, cfg.ChangelogBatchMaxConcurrentGroups, cfg.WriteTimeout)
+	}
+
+	return &SQLServer{
+		stbl:                    stbl,
+		db:                      db,
+		dbInfo:                  dbInfo,
+		logger:                  cfg.Logger,
+		dbStatsCollector:        collector,
+		maxTuplesPerWriteField:  cfg.MaxTuplesPerWriteField,
+		maxTypesPerModelField:   cfg.MaxTypesPerModelField,
+		readTimeout:             cfg.ReadTimeout,
+		writeTimeout:            cfg.WriteTimeout,
+		writeBatcher:            writeBatcher,
+		idempotencyRecordPurger: sqlcommon.NewIdempotencyRecordPurger(dbInfo, cfg.Logger),
+	}, nil
+}
+
+// Close see [storage.OpenFGADatastore].Close.
+func (s *SQLServer) Close() {
+	if s.writeBatcher != nil {
+		s.writeBatcher.Close()
+	}
+	s.idempotencyRecordPurger.Close()
+	if s.dbStatsCollector != nil {
+		prometheus.Unregister(s.dbStatsCollector)
+	}
+	s.db.Close()
+}
+
+// Read see [storage.RelationshipTupleReader].Read.
+func (s *SQLServer) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.Read")
+	defer span.End()
+
+	return s.read(ctx, store, tupleKey, nil)
+}
+
+// ReadPage see [storage.RelationshipTupleReader].ReadPage.
+func (s *SQLServer) ReadPage(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadPageOptions) ([]*openfgav1.Tuple, []byte, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadPage")
+	defer span.End()
+
+	iter, err := s.read(ctx, store, tupleKey, &options)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iter.Stop()
+
+	return iter.ToArray(ctx, options.Pagination)
+}
+
+func (s *SQLServer) read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, opts *storage.ReadPageOptions) (*sqlcommon.SQLTupleIterator, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.read")
+	defer span.End()
+
+	sb := s.stbl.
+		Select(
+			"store", "object_type", "object_id", "relation", "_user",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
+		).
+		From("tuple").
+		Where(sq.Eq{"store": store})
+	if opts != nil {
+		sb = sb.OrderBy("ulid")
+	}
+
+	objectType, objectID := tupleUtils.SplitObject(tupleKey.GetObject())
+	if objectType != "" {
+		sb = sb.Where(sq.Eq{"object_type": objectType})
+	}
+	if objectID != "" {
+		sb = sb.Where(sq.Eq{"object_id": objectID})
+	}
+	if tupleKey.GetRelation() != "" {
+		sb = sb.Where(sq.Eq{"relation": tupleKey.GetRelation()})
+	}
+	if tupleKey.GetUser() != "" {
+		sb = sb.Where(sq.Eq{"_user": tupleKey.GetUser()})
+	}
+	if opts != nil && opts.Pagination.From != "" {
+		token, err := sqlcommon.UnmarshallContToken(opts.Pagination.From)
+		if err != nil {
+			return nil, err
+		}
+		sb = sb.Where(sq.GtOrEq{"ulid": token.Ulid})
+	}
+	if opts != nil && opts.Pagination.PageSize != 0 {
+		sb = sb.Options(top(opts.Pagination.PageSize + 1)) // + 1 is used to determine whether to return a continuation token.
+	}
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, s.readTimeout)
+	rows, err := sb.QueryContext(ctx)
+	if err != nil {
+		cancel()
+		return nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	return sqlcommon.NewSQLTupleIterator(rows, s.dbInfo).WithCancel(cancel), nil
+}
+
+// Write see [storage.RelationshipTupleWriter].Write.
+func (s *SQLServer) Write(ctx context.Context, store string, deletes storage.Deletes, writes storage.Writes) error {
+	ctx, span := tracer.Start(ctx, "sqlserver.Write")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	if len(deletes)+len(writes) > s.MaxTuplesPerWrite() {
+		return storage.ErrExceededWriteBatchLimit
+	}
+
+	if s.writeBatcher != nil {
+		return s.writeBatcher.WriteBatches(ctx, store, []storage.TupleBatch{{Deletes: deletes, Writes: writes}})
+	}
+
+	now := time.Now().UTC()
+	return sqlcommon.Write(ctx, s.dbInfo, store, deletes, writes, now)
+}
+
+// WriteBatches see [storage.TransactionalBatchWriter].WriteBatches.
+func (s *SQLServer) WriteBatches(ctx context.Context, store string, batches []storage.TupleBatch) error {
+	ctx, span := tracer.Start(ctx, "sqlserver.WriteBatches")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > s.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	if s.writeBatcher != nil {
+		return s.writeBatcher.WriteBatches(ctx, store, batches)
+	}
+
+	now := time.Now().UTC()
+
+	return sqlcommon.WriteBatches(ctx, s.dbInfo, store, batches, now)
+}
+
+// WriteBatchesIdempotently see [storage.IdempotentWriter].WriteBatchesIdempotently.
+func (s *SQLServer) WriteBatchesIdempotently(ctx context.Context, store string, record storage.IdempotencyRecord, batches []storage.TupleBatch) error {
+	ctx, span := tracer.Start(ctx, "sqlserver.WriteBatchesIdempotently")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	for _, batch := range batches {
+		if len(batch.Deletes)+len(batch.Writes) > s.MaxTuplesPerWrite() {
+			return storage.ErrExceededWriteBatchLimit
+		}
+	}
+
+	now := time.Now().UTC()
+
+	return sqlcommon.WriteBatchesIdempotently(ctx, s.dbInfo, store, record, batches, now)
+}
+
+// approximateTupleCountsSamplePercent is the percentage of the pages of the tuple table sampled
+// by approximate tuple counts.
+const approximateTupleCountsSamplePercent = 1
+
+// ReadTupleCountsByRelation see [storage.TupleCountsByRelationReader].ReadTupleCountsByRelation.
+// Approximate counts are estimated from a sample of the pages of the tuple table.
+func (s *SQLServer) ReadTupleCountsByRelation(ctx context.Context, store string, options storage.TupleCountsByRelationOptions) ([]storage.RelationTupleCount, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadTupleCountsByRelation")
+	defer span.End()
+
+	if options.Approximate {
+		table := fmt.Sprintf("tuple TABLESAMPLE (%d PERCENT)", approximateTupleCountsSamplePercent)
+		return sqlcommon.ReadTupleCountsByRelation(ctx, s.dbInfo, table, store, 100/approximateTupleCountsSamplePercent)
+	}
+
+	return sqlcommon.ReadTupleCountsByRelation(ctx, s.dbInfo, "tuple", store, 1)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
+func (s *SQLServer) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, _ storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadUserTuple")
+	defer span.End()
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	objectType, objectID := tupleUtils.SplitObject(tupleKey.GetObject())
+	userType := tupleUtils.GetUserTypeFromUser(tupleKey.GetUser())
+
+	var conditionName, conditionContextKeyID sql.NullString
+	var conditionContext []byte
+	var record storage.TupleRecord
+
+	err := s.stbl.
+		Select(
+			"object_type", "object_id", "relation", "_user",
+			"condition_name", "condition_context", "condition_context_key_id",
+		).
+		From("tuple").
+		Where(sq.Eq{
+			"store":       store,
+			"object_type": objectType,
+			"object_id":   objectID,
+			"relation":    tupleKey.GetRelation(),
+			"_user":       tupleKey.GetUser(),
+			"user_type":   userType,
+		}).
+		QueryRowContext(ctx).
+		Scan(
+			&record.ObjectType,
+			&record.ObjectID,
+			&record.Relation,
+			&record.User,
+			&conditionName,
+			&conditionContext,
+			&conditionContextKeyID,
+		)
+	if err != nil {
+		return nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	if conditionName.String != "" {
+		record.ConditionName = conditionName.String
+
+		record.ConditionContext, err = s.dbInfo.UnmarshalConditionContext(ctx, store, tupleKey, conditionContextKeyID, conditionContext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return record.AsTuple(), nil
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader].ReadUsersetTuples.
+func (s *SQLServer) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, _ storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadUsersetTuples")
+	defer span.End()
+
+	sb := s.stbl.
+		Select(
+			"store", "object_type", "object_id", "relation", "_user",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
+		).
+		From("tuple").
+		Where(sq.Eq{"store": store}).
+		Where(sq.Eq{"user_type": tupleUtils.UserSet})
+
+	objectType, objectID := tupleUtils.SplitObject(filter.Object)
+	if objectType != "" {
+		sb = sb.Where(sq.Eq{"object_type": objectType})
+	}
+	if objectID != "" {
+		sb = sb.Where(sq.Eq{"object_id": objectID})
+	}
+	if filter.Relation != "" {
+		sb = sb.Where(sq.Eq{"relation": filter.Relation})
+	}
+	if len(filter.AllowedUserTypeRestrictions) > 0 {
+		orConditions := sq.Or{}
+		for _, userset := range filter.AllowedUserTypeRestrictions {
+			if _, ok := userset.GetRelationOrWildcard().(*openfgav1.RelationReference_Relation); ok {
+				orConditions = append(orConditions, sq.Like{"_user": userset.GetType() + ":%#" + userset.GetRelation()})
+			}
+			if _, ok := userset.GetRelationOrWildcard().(*openfgav1.RelationReference_Wildcard); ok {
+				orConditions = append(orConditions, sq.Eq{"_user": userset.GetType() + ":*"})
+			}
+		}
+		sb = sb.Where(orConditions)
+	}
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, s.readTimeout)
+	rows, err := sb.QueryContext(ctx)
+	if err != nil {
+		cancel()
+		return nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	return sqlcommon.NewSQLTupleIterator(rows, s.dbInfo).WithCancel(cancel), nil
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+func (s *SQLServer) ReadStartingWithUser(ctx context.Context, store string, opts storage.ReadStartingWithUserFilter, _ storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadStartingWithUser")
+	defer span.End()
+
+	var targetUsersArg []string
+	for _, u := range opts.UserFilter {
+		targetUser := u.GetObject()
+		if u.GetRelation() != "" {
+			targetUser = strings.Join([]string{u.GetObject(), u.GetRelation()}, "#")
+		}
+		targetUsersArg = append(targetUsersArg, targetUser)
+	}
+
+	builder := s.stbl.
+		Select(
+			"store", "object_type", "object_id", "relation", "_user",
+			"condition_name", "condition_context", "condition_context_key_id", "ulid", "inserted_at",
+		).
+		From("tuple").
+		Where(sq.Eq{
+			"store":       store,
+			"object_type": opts.ObjectType,
+			"relation":    opts.Relation,
+			"_user":       targetUsersArg,
+		})
+
+	if opts.ObjectIDs != nil && opts.ObjectIDs.Size() > 0 {
+		builder = builder.Where(sq.Eq{"object_id": opts.ObjectIDs.Values()})
+	}
+
+	ctx, cancel := sqlcommon.ContextWithTimeout(ctx, s.readTimeout)
+	rows, err := builder.QueryContext(ctx)
+	if err != nil {
+		cancel()
+		return nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	return sqlcommon.NewSQLTupleIterator(rows, s.dbInfo).WithCancel(cancel), nil
+}
+
+// MaxTuplesPerWrite see [storage.RelationshipTupleWriter].MaxTuplesPerWrite.
+func (s *SQLServer) MaxTuplesPerWrite() int {
+	return s.maxTuplesPerWriteField
+}
+
+// ReadAuthorizationModel see [storage.AuthorizationModelReadBackend].ReadAuthorizationModel.
+func (s *SQLServer) ReadAuthorizationModel(ctx context.Context, store string, modelID string) (*openfgav1.AuthorizationModel, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadAuthorizationModel")
+	defer span.End()
+
+	return sqlcommon.ReadAuthorizationModel(ctx, s.dbInfo, store, modelID)
+}
+
+// ReadAuthorizationModels see [storage.AuthorizationModelReadBackend].ReadAuthorizationModels.
+func (s *SQLServer) ReadAuthorizationModels(ctx context.Context, store string, options storage.ReadAuthorizationModelsOptions) ([]*openfgav1.AuthorizationModel, []byte, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadAuthorizationModels")
+	defer span.End()
+
+	sb := s.stbl.
+		Select("authorization_model_id").
+		Distinct().
+		From("authorization_model").
+		Where(sq.Eq{"store": store}).
+		OrderBy("authorization_model_id desc")
+
+	if options.Pagination.From != "" {
+		token, err := sqlcommon.UnmarshallContToken(options.Pagination.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		sb = sb.Where(sq.LtOrEq{"authorization_model_id": token.Ulid})
+	}
+	if options.Pagination.PageSize > 0 {
+		sb = sb.Options(top(options.Pagination.PageSize + 1)) // + 1 is used to determine whether to return a continuation token.
+	}
+
+	rows, err := sb.QueryContext(ctx)
+	if err != nil {
+		return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+	defer rows.Close()
+
+	var modelIDs []string
+	var modelID string
+
+	for rows.Next() {
+		err = rows.Scan(&modelID)
+		if err != nil {
+			return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+		}
+
+		modelIDs = append(modelIDs, modelID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	var token []byte
+	numModelIDs := len(modelIDs)
+	if len(modelIDs) > options.Pagination.PageSize {
+		numModelIDs = options.Pagination.PageSize
+		token, err = json.Marshal(sqlcommon.NewContToken(modelID, ""))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// TODO: make this concurrent with a maximum of 5 goroutines. This may be helpful:
+	// https://stackoverflow.com/questions/25306073/always-have-x-number-of-goroutines-running-at-any-time
+	models := make([]*openfgav1.AuthorizationModel, 0, numModelIDs)
+	// We use numModelIDs here to avoid retrieving possibly one extra model.
+	for i := 0; i < numModelIDs; i++ {
+		model, err := s.ReadAuthorizationModel(ctx, store, modelIDs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		models = append(models, model)
+	}
+
+	return models, token, nil
+}
+
+// FindLatestAuthorizationModel see [storage.AuthorizationModelReadBackend].FindLatestAuthorizationModel.
+func (s *SQLServer) FindLatestAuthorizationModel(ctx context.Context, store string) (*openfgav1.AuthorizationModel, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.FindLatestAuthorizationModel")
+	defer span.End()
+
+	return sqlcommon.FindLatestAuthorizationModel(ctx, s.dbInfo, store)
+}
+
+// MaxTypesPerAuthorizationModel see [storage.TypeDefinitionWriteBackend].MaxTypesPerAuthorizationModel.
+func (s *SQLServer) MaxTypesPerAuthorizationModel() int {
+	return s.maxTypesPerModelField
+}
+
+// WriteAuthorizationModel see [storage.TypeDefinitionWriteBackend].WriteAuthorizationModel.
+func (s *SQLServer) WriteAuthorizationModel(ctx context.Context, store string, model *openfgav1.AuthorizationModel) error {
+	ctx, span := tracer.Start(ctx, "sqlserver.WriteAuthorizationModel")
+	defer span.End()
+
+	typeDefinitions := model.GetTypeDefinitions()
+
+	if len(typeDefinitions) > s.MaxTypesPerAuthorizationModel() {
+		return storage.ExceededMaxTypeDefinitionsLimitError(s.maxTypesPerModelField)
+	}
+
+	return sqlcommon.WriteAuthorizationModel(ctx, s.dbInfo, store, model)
+}
+
+// CreateStore adds a new store to the SQL Server storage.
+func (s *SQLServer) CreateStore(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.CreateStore")
+	defer span.End()
+
+	now := time.Now().UTC()
+	_, err := s.stbl.
+		Insert("store").
+		Columns("id", "name", "created_at", "updated_at").
+		Values(store.GetId(), store.GetName(), now, now).
+		ExecContext(ctx)
+	if err != nil {
+		return nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	return &openfgav1.Store{
+		Id:        store.GetId(),
+		Name:      store.GetName(),
+		CreatedAt: timestamppb.New(now),
+		UpdatedAt: timestamppb.New(now),
+	}, nil
+}
+
+// CreateStoreWithUniqueName see [storage.UniqueStoreNameCreator].CreateStoreWithUniqueName.
+func (s *SQLServer) CreateStoreWithUniqueName(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.CreateStoreWithUniqueName")
+	defer span.End()
+
+	return sqlcommon.CreateStoreWithUniqueName(ctx, s.dbInfo, store)
+}
+
+// GetStore retrieves the details of a specific store from the SQL Server using its storeID.
+func (s *SQLServer) GetStore(ctx context.Context, id string) (*openfgav1.Store, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.GetStore")
+	defer span.End()
+
+	row := s.stbl.
+		Select("id", "name", "created_at", "updated_at").
+		From("store").
+		Where(sq.Eq{
+			"id":         id,
+			"deleted_at": nil,
+		}).
+		QueryRowContext(ctx)
+
+	var storeID, name string
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&storeID, &name, &createdAt, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &storage.StoreNotFoundError{StoreID: id}
+		}
+		return nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	return &openfgav1.Store{
+		Id:        storeID,
+		Name:      name,
+		CreatedAt: timestamppb.New(createdAt),
+		UpdatedAt: timestamppb.New(updatedAt),
+	}, nil
+}
+
+// ListStores provides a paginated list of all stores present in the SQL Server storage.
+func (s *SQLServer) ListStores(ctx context.Context, options storage.ListStoresOptions) ([]*openfgav1.Store, []byte, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ListStores")
+	defer span.End()
+
+	sb := s.stbl.
+		Select("id", "name", "created_at", "updated_at").
+		From("store").
+		Where(sq.Eq{"deleted_at": nil}).
+		OrderBy("id")
+
+	if options.Pagination.From != "" {
+		token, err := sqlcommon.UnmarshallContToken(options.Pagination.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		sb = sb.Where(sq.GtOrEq{"id": token.Ulid})
+	}
+	if options.Pagination.PageSize > 0 {
+		sb = sb.Options(top(options.Pagination.PageSize + 1)) // + 1 is used to determine whether to return a continuation token.
+	}
+
+	rows, err := sb.QueryContext(ctx)
+	if err != nil {
+		return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+	defer rows.Close()
+
+	var stores []*openfgav1.Store
+	var id string
+	for rows.Next() {
+		var name string
+		var createdAt, updatedAt time.Time
+		err := rows.Scan(&id, &name, &createdAt, &updatedAt)
+		if err != nil {
+			return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+		}
+
+		stores = append(stores, &openfgav1.Store{
+			Id:        id,
+			Name:      name,
+			CreatedAt: timestamppb.New(createdAt),
+			UpdatedAt: timestamppb.New(updatedAt),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	if len(stores) > options.Pagination.PageSize {
+		contToken, err := json.Marshal(sqlcommon.NewContToken(id, ""))
+		if err != nil {
+			return nil, nil, err
+		}
+		return stores[:options.Pagination.PageSize], contToken, nil
+	}
+
+	return stores, nil, nil
+}
+
+// DeleteStore removes a store from the SQL Server storage.
+func (s *SQLServer) DeleteStore(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "sqlserver.DeleteStore")
+	defer span.End()
+
+	_, err := s.stbl.
+		Update("store").
+		Set("deleted_at", sq.Expr("SYSUTCDATETIME()")).
+		Set("unique_name", nil). // frees the name for a new store
+		Where(sq.Eq{"id": id}).
+		ExecContext(ctx)
+	if err != nil {
+		return sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	return nil
+}
+
+// PruneAuthorizationModels see [storage.AuthorizationModelPruner].PruneAuthorizationModels.
+func (s *SQLServer) PruneAuthorizationModels(ctx context.Context, store string, retain int, keep []string) (int, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.PruneAuthorizationModels")
+	defer span.End()
+
+	return sqlcommon.PruneAuthorizationModels(ctx, s.dbInfo, store, retain, keep)
+}
+
+// RotateConditionContextKeys see [storage.ConditionContextKeyRotator].RotateConditionContextKeys. The condition
+// contexts are encrypted with the cipher set by [sqlcommon.WithConditionContextCipher].
+func (s *SQLServer) RotateConditionContextKeys(ctx context.Context, store string) (int, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.RotateConditionContextKeys")
+	defer span.End()
+
+	return sqlcommon.RotateConditionContextKeys(ctx, s.dbInfo, store)
+}
+
+// ReadStoreSettings see [storage.StoreSettingsBackend].ReadStoreSettings.
+func (s *SQLServer) ReadStoreSettings(ctx context.Context, store string) (*storage.StoreSettings, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadStoreSettings")
+	defer span.End()
+
+	return sqlcommon.ReadStoreSettings(ctx, s.dbInfo, store)
+}
+
+// WriteStoreSettings see [storage.StoreSettingsBackend].WriteStoreSettings.
+func (s *SQLServer) WriteStoreSettings(ctx context.Context, store string, settings *storage.StoreSettings) error {
+	ctx, span := tracer.Start(ctx, "sqlserver.WriteStoreSettings")
+	defer span.End()
+
+	return sqlcommon.WriteStoreSettings(ctx, s.dbInfo, store, settings)
+}
+
+// WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
+func (s *SQLServer) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
+	ctx, span := tracer.Start(ctx, "sqlserver.WriteAssertions")
+	defer span.End()
+
+	marshalledAssertions, err := proto.Marshal(&openfgav1.Assertions{Assertions: assertions})
+	if err != nil {
+		return err
+	}
+
+	// HOLDLOCK keeps two concurrent upserts of the same assertions from both inserting them
+	_, err = s.db.ExecContext(ctx, `MERGE assertion WITH (HOLDLOCK) AS target
+USING (SELECT @p1 AS store, @p2 AS authorization_model_id, @p3 AS assertions) AS source
+ON target.store = source.store AND target.authorization_model_id = source.authorization_model_id
+WHEN MATCHED THEN UPDATE SET assertions = source.assertions
+WHEN NOT MATCHED THEN INSERT (store, authorization_model_id, assertions)
+VALUES (source.store, source.authorization_model_id, source.assertions);`, store, modelID, marshalledAssertions)
+	if err != nil {
+		return sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	return nil
+}
+
+// ReadAssertions see [storage.AssertionsBackend].ReadAssertions.
+func (s *SQLServer) ReadAssertions(ctx context.Context, store, modelID string) ([]*openfgav1.Assertion, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadAssertions")
+	defer span.End()
+
+	var marshalledAssertions []byte
+	err := s.stbl.
+		Select("assertions").
+		From("assertion").
+		Where(sq.Eq{
+			"store":                  store,
+			"authorization_model_id": modelID,
+		}).
+		QueryRowContext(ctx).
+		Scan(&marshalledAssertions)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []*openfgav1.Assertion{}, nil
+		}
+		return nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+
+	var assertions openfgav1.Assertions
+	err = proto.Unmarshal(marshalledAssertions, &assertions)
+	if err != nil {
+		return nil, err
+	}
+
+	return assertions.GetAssertions(), nil
+}
+
+// ReadChanges see [storage.ChangelogBackend].ReadChanges.
+func (s *SQLServer) ReadChanges(ctx context.Context, store, objectTypeFilter string, options storage.ReadChangesOptions, horizonOffset time.Duration) ([]*openfgav1.TupleChange, []byte, error) {
+	ctx, span := tracer.Start(ctx, "sqlserver.ReadChanges")
+	defer span.End()
+
+	sb := s.stbl.
+		Select(
+			"ulid", "object_type", "object_id", "relation", "_user", "operation",
+			"condition_name", "condition_context", "condition_context_key_id", "inserted_at",
+		).
+		From("changelog").
+		Where(sq.Eq{"store": store}).
+		Where(fmt.Sprintf("inserted_at < DATEADD(MILLISECOND, -%d, SYSUTCDATETIME())", horizonOffset.Milliseconds())).
+		OrderBy(sqlcommon.ChangelogOrderBy(options))
+
+	if objectTypeFilter != "" {
+		sb = sb.Where(sq.Eq{"object_type": objectTypeFilter})
+	}
+	if options.Pagination.From != "" {
+		token, err := sqlcommon.UnmarshallContToken(options.Pagination.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		if token.ObjectType != objectTypeFilter {
+			return nil, nil, storage.ErrMismatchObjectType
+		}
+
+		if options.SortDesc {
+			sb = sb.Where(sq.Lt{"ulid": token.Ulid})
+		} else {
+			sb = sb.Where(sq.Gt{"ulid": token.Ulid}) // > as we always return a continuation token.
+		}
+	}
+	if options.Pagination.PageSize > 0 {
+		sb = sb.Options(top(options.Pagination.PageSize)) // + 1 is NOT used here as we always return a continuation token.
+	}
+
+	rows, err := sb.QueryContext(ctx)
+	if err != nil {
+		return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+	}
+	defer rows.Close()
+
+	var changes []*openfgav1.TupleChange
+	var ulid string
+	for rows.Next() {
+		var objectType, objectID, relation, user string
+		var operation int
+		var insertedAt time.Time
+		var conditionName, conditionContextKeyID sql.NullString
+		var conditionContext []byte
+
+		err = rows.Scan(
+			&ulid,
+			&objectType,
+			&objectID,
+			&relation,
+			&user,
+			&operation,
+			&conditionName,
+			&conditionContext,
+			&conditionContextKeyID,
+			&insertedAt,
+		)
+		if err != nil {
+			return nil, nil, sqlcommon.HandleSQLError(err, s.logger)
+		}
+
+		tk := tupleUtils.NewTupleKey(tupleUtils.BuildObject(objectType, objectID), relation, user)
+		conditionContextStruct := &structpb.Struct{}
+		if conditionName.String != "" && conditionContext != nil {
+			conditionContextStruct, err = s.dbInfo.UnmarshalConditionContext(ctx, store, tk, conditionContextKeyID, conditionContext)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		tk = tupleUtils.NewTupleKeyWithCondition(
+			tk.GetObject(),
+			relation,
+			user,
+			conditionName.String,
+			conditionContextStruct,
+		)
+
+		changes = append(changes, &openfgav1.TupleChange{
+			TupleKey:  tk,
+			Operation: openfgav1.TupleOperation(operation),
+			Timestamp: timestamppb.New(insertedAt.UTC()),
+		})
+	}
+
+	if len(changes) == 0 {
+		return nil, nil, storage.ErrNotFound
+	}
+
+	contToken, err := json.Marshal(sqlcommon.NewContToken(ulid, objectTypeFilter))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return changes, contToken, nil
+}
+
+// IsReady see [sqlcommon.IsReady].
+func (s *SQLServer) IsReady(ctx context.Context) (storage.ReadinessStatus, error) {
+	return sqlcommon.IsReady(ctx, s.db)
+}
+
+// top returns the option of a SELECT that limits it to its first n rows, as SQL Server has no LIMIT clause.
+func top(n int) string {
+	return fmt.Sprintf("TOP (%d)", n)
+}
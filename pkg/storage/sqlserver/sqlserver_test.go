@@ -0,0 +1,58 @@
+package sqlserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+	"github.com/openfga/openfga/pkg/storage/test"
+	storagefixtures "github.com/openfga/openfga/pkg/testfixtures/storage"
+)
+
+func TestSQLServerDatastore(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "sqlserver")
+
+	uri := testDatastore.GetConnectionURI(true)
+	ds, err := New(uri, sqlcommon.NewConfig())
+	require.NoError(t, err)
+	defer ds.Close()
+	test.RunAllTests(t, ds)
+}
+
+func TestConditionContextEncryption(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "sqlserver")
+	uri := testDatastore.GetConnectionURI(true)
+
+	test.ConditionContextEncryptionTest(t, func(t *testing.T, cipher sqlcommon.ConditionContextCipher) storage.OpenFGADatastore {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithConditionContextCipher(cipher)))
+		require.NoError(t, err)
+		return ds
+	})
+}
+
+func TestChangelogBatching(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "sqlserver")
+	uri := testDatastore.GetConnectionURI(true)
+
+	test.ChangelogBatchingTest(t, func(t *testing.T, maxDelay time.Duration, maxWrites int) storage.OpenFGADatastore {
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithChangelogBatching(maxDelay, maxWrites)))
+		require.NoError(t, err)
+		return ds
+	})
+}
+
+func TestSQLServerDatastoreAfterCloseIsNotReady(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "sqlserver")
+
+	uri := testDatastore.GetConnectionURI(true)
+	ds, err := New(uri, sqlcommon.NewConfig())
+	require.NoError(t, err)
+	ds.Close()
+	status, err := ds.IsReady(context.Background())
+	require.Error(t, err)
+	require.False(t, status.IsReady)
+}
@@ -4,6 +4,7 @@ package storage
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
@@ -31,6 +32,8 @@ const (
 	DefaultPageSize = 50
 
 	relationshipTupleReaderCtxKey ctxKey = "relationship-tuple-reader-context-key"
+	minChangelogTokenCtxKey       ctxKey = "min-changelog-token-context-key"
+	shadowReadsCtxKey             ctxKey = "shadow-reads-context-key"
 )
 
 // ContextWithRelationshipTupleReader sets the provided [[RelationshipTupleReader]]
@@ -53,6 +56,33 @@ func RelationshipTupleReaderFromContext(ctx context.Context) (RelationshipTupleR
 	return reader, ok
 }
 
+// ContextWithMinChangelogToken requests that the tuple reads made with the returned context resolve
+// against a state of the datastore that includes, at least, all the changes up to token, which is a
+// continuation token returned by [ChangelogBackend.ReadChanges]. Datastores that read from replicas
+// must not read from a replica that is behind token. Other datastores ignore it.
+func ContextWithMinChangelogToken(parent context.Context, token string) context.Context {
+	return context.WithValue(parent, minChangelogTokenCtxKey, token)
+}
+
+// MinChangelogTokenFromContext returns the token set with [ContextWithMinChangelogToken], if any.
+func MinChangelogTokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(minChangelogTokenCtxKey).(string)
+	return token, ok && token != ""
+}
+
+// ContextWithShadowReads requests that the tuple reads made with the returned context are served by the
+// shadow datastore of the server alone. The results resolved with such a context must not be cached, since
+// the caches are shared with the reads served by the primary datastore.
+func ContextWithShadowReads(parent context.Context) context.Context {
+	return context.WithValue(parent, shadowReadsCtxKey, true)
+}
+
+// ShadowReadsFromContext returns whether the context was returned by [ContextWithShadowReads].
+func ShadowReadsFromContext(ctx context.Context) bool {
+	shadowReads, _ := ctx.Value(shadowReadsCtxKey).(bool)
+	return shadowReads
+}
+
 // PaginationOptions should not be instantiated directly. Use NewPaginationOptions.
 type PaginationOptions struct {
 	PageSize int
@@ -90,6 +120,9 @@ type ListStoresOptions struct {
 // be used with the ReadChanges method.
 type ReadChangesOptions struct {
 	Pagination PaginationOptions
+	// SortDesc reads the changes from the most recent to the oldest, and the continuation tokens then lead to
+	// older changes. A continuation token can only be used with the same SortDesc it was returned for.
+	SortDesc bool
 }
 
 // ReadPageOptions represents the options that can
@@ -225,6 +258,63 @@ type RelationshipTupleWriter interface {
 	MaxTuplesPerWrite() int
 }
 
+// TupleBatch is a set of deletes and writes that is written with a single call to [RelationshipTupleWriter.Write].
+type TupleBatch struct {
+	Deletes Deletes
+	Writes  Writes
+}
+
+// TransactionalBatchWriter is implemented by datastores that can atomically write more tuples
+// than MaxTuplesPerWrite, by writing several batches within a single transaction.
+type TransactionalBatchWriter interface {
+	// WriteBatches writes the batches in order within a single transaction, as if each batch was passed to
+	// [RelationshipTupleWriter.Write]: either all the batches are written or none is. A tuple deleted in a
+	// batch may be written again in a later batch, and vice versa.
+	// If a batch has more than MaxTuplesPerWrite items, it must return ErrExceededWriteBatchLimit.
+	WriteBatches(ctx context.Context, store string, batches []TupleBatch) error
+}
+
+// IdempotencyRecord records that a write with an idempotency key was applied to a store.
+type IdempotencyRecord struct {
+	Key string
+	// RequestHash identifies the write that was applied with the key.
+	RequestHash string
+	// ExpiresAt is the time after which the key can be used again.
+	ExpiresAt time.Time
+}
+
+// IdempotentWriter is implemented by datastores that can deduplicate the writes that are retried with the
+// same idempotency key.
+type IdempotentWriter interface {
+	// WriteBatchesIdempotently writes the batches like [TransactionalBatchWriter.WriteBatches], and stores
+	// the record within the same transaction. If the store has a record with the same key that hasn't
+	// expired, it must write nothing and return an [IdempotencyKeyExistsError] with that record.
+	WriteBatchesIdempotently(ctx context.Context, store string, record IdempotencyRecord, batches []TupleBatch) error
+}
+
+// RelationTupleCount is the number of tuples of a store with an object type and a relation.
+type RelationTupleCount struct {
+	ObjectType string
+	Relation   string
+	Count      int64
+}
+
+// TupleCountsByRelationOptions represents the options that can be used with
+// [TupleCountsByRelationReader.ReadTupleCountsByRelation].
+type TupleCountsByRelationOptions struct {
+	// Approximate allows the datastore to estimate the counts from a sample of the tuples, which is
+	// cheaper on very large stores. Relations with few tuples may be missing from an approximate result.
+	Approximate bool
+}
+
+// TupleCountsByRelationReader is implemented by datastores that can count the tuples of a store grouped by
+// object type and relation without reading every tuple.
+type TupleCountsByRelationReader interface {
+	// ReadTupleCountsByRelation returns the number of tuples of the store for every object type and relation
+	// that has tuples, in no particular order.
+	ReadTupleCountsByRelation(ctx context.Context, store string, options TupleCountsByRelationOptions) ([]RelationTupleCount, error)
+}
+
 // ReadStartingWithUserFilter specifies the filter options that will be used
 // to constrain the [RelationshipTupleReader.ReadStartingWithUser] query.
 type ReadStartingWithUserFilter struct {
@@ -277,15 +367,100 @@ type AuthorizationModelBackend interface {
 	TypeDefinitionWriteBackend
 }
 
+// AuthorizationModelPruner is implemented by datastores that can delete the old authorization models of a store.
+type AuthorizationModelPruner interface {
+	// PruneAuthorizationModels deletes, within a single transaction, the authorization models of the store except
+	// the `retain` most recent ones, the ones that have assertions and the ones whose IDs are in keep, along with
+	// their assertions, and returns the number of models deleted. It must never delete the latest model of the
+	// store, even if retain is less than 1.
+	PruneAuthorizationModels(ctx context.Context, store string, retain int, keep []string) (int, error)
+}
+
+// IndexRebuilder is implemented by datastores that can rebuild the indexes of the tuples and of the changelog,
+// e.g. after a bulk import left them bloated.
+type IndexRebuilder interface {
+	// RebuildIndexes rebuilds the indexes of the tuples and of the changelog. It holds a lock of the database
+	// while it runs, so that a single caller rebuilds them at a time across all the instances sharing the
+	// database: if another rebuild is in progress, it must return [ErrIndexRebuildInProgress]. It must stop
+	// when ctx is canceled.
+	RebuildIndexes(ctx context.Context) error
+}
+
+// ConditionContextKeyRotator is implemented by datastores that can encrypt the condition contexts of the tuples at
+// rest, with the ID of the key that encrypted each context stored along with it.
+type ConditionContextKeyRotator interface {
+	// RotateConditionContextKeys re-encrypts, with the current key of the store, the condition contexts of the
+	// tuples and of the changes of the store that are encrypted with another key, or that aren't encrypted. If
+	// the condition contexts of the store must not be encrypted, it decrypts them instead. It returns the number
+	// of re-encrypted contexts. It must be run before a key that was current is removed.
+	RotateConditionContextKeys(ctx context.Context, store string) (int, error)
+}
+
 // StoresBackend is an interface that defines the set of methods required
 // for interacting with and managing different types of storage backends.
 type StoresBackend interface {
 	CreateStore(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error)
 	DeleteStore(ctx context.Context, id string) error
+	// GetStore returns the store with the given ID. If there is none, it must return a [StoreNotFoundError].
 	GetStore(ctx context.Context, id string) (*openfgav1.Store, error)
 	ListStores(ctx context.Context, options ListStoresOptions) ([]*openfgav1.Store, []byte, error)
 }
 
+// UniqueStoreNameCreator is implemented by datastores that can create stores whose names are unique.
+type UniqueStoreNameCreator interface {
+	// CreateStoreWithUniqueName is like [StoresBackend.CreateStore], but it must return a
+	// [StoreNameConflictError] if a store that isn't deleted already has the name of the store.
+	CreateStoreWithUniqueName(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error)
+}
+
+// StoreSettings are the settings of a store, which are stored with the store.
+type StoreSettings struct {
+	// AllowedObjectTypes are the object types that the models and the tuples of the store may use.
+	// If empty, any object type is allowed.
+	AllowedObjectTypes []string
+
+	// DefaultPageSize is the page size of the Reads of the store that don't set one. If zero,
+	// [DefaultPageSize] applies.
+	DefaultPageSize int32
+
+	// RelationAliases maps aliases of relations, as 'objectType#alias', to the relations of the model that
+	// the Checks of the store on the aliases resolve, e.g. 'document#reader' to 'viewer'.
+	RelationAliases map[string]string
+
+	// DefaultUserType is the type of the users of the store that are written or checked without one, e.g. with
+	// 'user', 'anne' is 'user:anne'. If empty, the users without a type are invalid.
+	DefaultUserType string
+
+	// MaxAuthorizationModels is the maximum number of authorization models of the store. If zero, the default
+	// of the server applies.
+	MaxAuthorizationModels int32
+}
+
+// AllowsObjectType returns true if the store may use the object type.
+func (s *StoreSettings) AllowsObjectType(objectType string) bool {
+	return s == nil || len(s.AllowedObjectTypes) == 0 || slices.Contains(s.AllowedObjectTypes, objectType)
+}
+
+// CanonicalRelation returns the relation that the relation of the object type is an alias of, if it is one.
+func (s *StoreSettings) CanonicalRelation(objectType, relation string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	canonical, ok := s.RelationAliases[objectType+"#"+relation]
+	return canonical, ok
+}
+
+// StoreSettingsBackend is implemented by datastores that can store settings with the stores.
+type StoreSettingsBackend interface {
+	// ReadStoreSettings returns the settings of the store. If no settings were written for the store, e.g.
+	// because the store doesn't exist, it must return empty settings.
+	ReadStoreSettings(ctx context.Context, store string) (*StoreSettings, error)
+
+	// WriteStoreSettings overwrites the settings of the store. If there is no store with the given ID, it
+	// must return a [StoreNotFoundError].
+	WriteStoreSettings(ctx context.Context, store string, settings *StoreSettings) error
+}
+
 // AssertionsBackend is an interface that defines the set of methods for reading and writing assertions.
 type AssertionsBackend interface {
 	// WriteAssertions overwrites the assertions for a store and modelID.
@@ -324,6 +499,31 @@ type OpenFGADatastore interface {
 	Close()
 }
 
+// DatastoreWrapper is implemented by the datastores that wrap another datastore. A wrapper may implement the
+// optional interfaces of the datastores, e.g. [TransactionalBatchWriter], by calling the datastore it wraps, in
+// which case it only supports them if the datastore it wraps does, see [As].
+type DatastoreWrapper interface {
+	// Unwrap returns the datastore that is wrapped.
+	Unwrap() OpenFGADatastore
+}
+
+// As returns ds as a T, an optional interface of the datastores such as [StoreSettingsBackend], if ds supports
+// it: if it implements T and, if it's a [DatastoreWrapper], the datastore it wraps supports T too.
+func As[T any](ds OpenFGADatastore) (T, bool) {
+	t, ok := ds.(T)
+	for ok {
+		wrapper, isWrapper := ds.(DatastoreWrapper)
+		if !isWrapper {
+			return t, true
+		}
+		ds = wrapper.Unwrap()
+		_, ok = ds.(T)
+	}
+
+	var zero T
+	return zero, false
+}
+
 // ReadinessStatus represents the readiness status of the datastore.
 type ReadinessStatus struct {
 	// Message is a human-friendly status message for the current datastore status.
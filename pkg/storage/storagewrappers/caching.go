@@ -6,30 +6,81 @@ import (
 	"time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/openfga/openfga/internal/build"
 	"github.com/openfga/openfga/pkg/storage"
 )
 
 const ttl = time.Hour * 168
 
+const (
+	modelCacheSourceLocal     = "local"
+	modelCacheSourceShared    = "shared"
+	modelCacheSourceDatastore = "datastore"
+)
+
+var (
+	authorizationModelCacheSourceCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "authorization_model_cache_source_count",
+		Help:      "The total number of authorization models read, by where they were read from (local, shared or datastore).",
+	}, []string{"source"})
+
+	authorizationModelSharedCacheErrorCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "authorization_model_shared_cache_error_count",
+		Help:      "The total number of shared authorization model cache operations that failed, and were treated as cache misses.",
+	}, []string{"operation"})
+)
+
 var _ storage.OpenFGADatastore = (*cachedOpenFGADatastore)(nil)
 
+// SharedCache stores serialized entries outside the process, e.g. in a Redis shared by every OpenFGA instance.
+// Its operations can fail. The backends of the Check cache are SharedCaches.
+type SharedCache interface {
+	// Get returns the entry of the key, or nil if there is no entry or it has expired.
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
 type cachedOpenFGADatastore struct {
 	storage.OpenFGADatastore
 	lookupGroup singleflight.Group
 	cache       storage.InMemoryCache[*openfgav1.AuthorizationModel]
+	sharedCache SharedCache
+}
+
+// CachedOpenFGADatastoreOpt defines an option that can be used to change the behavior of the wrapper
+// returned by NewCachedOpenFGADatastore.
+type CachedOpenFGADatastoreOpt func(*cachedOpenFGADatastore)
+
+// WithSharedModelCache makes the wrapper look up the models that aren't in memory in the shared cache before
+// the datastore, and store the models read from the datastore in it, so that the model read by one instance
+// doesn't have to be read by the others. A failure of the shared cache is treated as a miss.
+func WithSharedModelCache(sharedCache SharedCache) CachedOpenFGADatastoreOpt {
+	return func(c *cachedOpenFGADatastore) {
+		c.sharedCache = sharedCache
+	}
 }
 
 // NewCachedOpenFGADatastore returns a wrapper over a datastore that caches up to maxSize
 // [*openfgav1.AuthorizationModel] on every call to storage.ReadAuthorizationModel.
 // It caches with unlimited TTL because models are immutable. It uses LRU for eviction.
-func NewCachedOpenFGADatastore(inner storage.OpenFGADatastore, maxSize int) *cachedOpenFGADatastore {
+func NewCachedOpenFGADatastore(inner storage.OpenFGADatastore, maxSize int, opts ...CachedOpenFGADatastoreOpt) *cachedOpenFGADatastore {
 	cache := storage.NewInMemoryLRUCache[*openfgav1.AuthorizationModel](storage.WithMaxCacheSize[*openfgav1.AuthorizationModel](int64(maxSize)))
-	return &cachedOpenFGADatastore{
+	c := &cachedOpenFGADatastore{
 		OpenFGADatastore: inner,
 		cache:            *cache,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // ReadAuthorizationModel reads the model corresponding to store and model ID.
@@ -38,19 +89,73 @@ func (c *cachedOpenFGADatastore) ReadAuthorizationModel(ctx context.Context, sto
 	cachedEntry := c.cache.Get(cacheKey)
 
 	if cachedEntry != nil {
+		authorizationModelCacheSourceCounter.WithLabelValues(modelCacheSourceLocal).Inc()
 		return cachedEntry.Value, nil
 	}
 
+	if model := c.getShared(cacheKey); model != nil {
+		authorizationModelCacheSourceCounter.WithLabelValues(modelCacheSourceShared).Inc()
+		c.cache.Set(cacheKey, model, ttl)
+		return model, nil
+	}
+
 	model, err := c.OpenFGADatastore.ReadAuthorizationModel(ctx, storeID, modelID)
 	if err != nil {
 		return nil, err
 	}
+	authorizationModelCacheSourceCounter.WithLabelValues(modelCacheSourceDatastore).Inc()
 
 	c.cache.Set(cacheKey, model, ttl) // These are immutable, once created, there cannot be edits, therefore they can be cached without ttl.
+	c.setShared(cacheKey, model)
 
 	return model, nil
 }
 
+// sharedModelCacheKey returns the key of a model in the shared cache, which must not start with the ID of the
+// store, since those are the keys of the Check cache entries that are invalidated on each write of tuples.
+func sharedModelCacheKey(cacheKey string) string {
+	return "authorization_model/" + cacheKey
+}
+
+// getShared returns the model of the key in the shared cache, or nil if there is no shared cache, the model
+// isn't in it, or it fails.
+func (c *cachedOpenFGADatastore) getShared(cacheKey string) *openfgav1.AuthorizationModel {
+	if c.sharedCache == nil {
+		return nil
+	}
+
+	value, err := c.sharedCache.Get(sharedModelCacheKey(cacheKey))
+	if err != nil {
+		authorizationModelSharedCacheErrorCounter.WithLabelValues("get").Inc()
+		return nil
+	}
+	if value == nil {
+		return nil
+	}
+
+	model := &openfgav1.AuthorizationModel{}
+	if err := proto.Unmarshal(value, model); err != nil {
+		authorizationModelSharedCacheErrorCounter.WithLabelValues("get").Inc()
+		return nil
+	}
+	return model
+}
+
+// setShared stores the model of the key in the shared cache, if any.
+func (c *cachedOpenFGADatastore) setShared(cacheKey string, model *openfgav1.AuthorizationModel) {
+	if c.sharedCache == nil {
+		return
+	}
+
+	value, err := proto.Marshal(model)
+	if err == nil {
+		err = c.sharedCache.Set(sharedModelCacheKey(cacheKey), value, ttl)
+	}
+	if err != nil {
+		authorizationModelSharedCacheErrorCounter.WithLabelValues("set").Inc()
+	}
+}
+
 // FindLatestAuthorizationModel see [storage.AuthorizationModelReadBackend].FindLatestAuthorizationModel.
 func (c *cachedOpenFGADatastore) FindLatestAuthorizationModel(ctx context.Context, storeID string) (*openfgav1.AuthorizationModel, error) {
 	v, err, _ := c.lookupGroup.Do(fmt.Sprintf("FindLatestAuthorizationModel:%s", storeID), func() (interface{}, error) {
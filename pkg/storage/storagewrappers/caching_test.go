@@ -0,0 +1,120 @@
+package storagewrappers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/testutils"
+)
+
+// mapSharedCache is a SharedCache in memory, shared by the wrappers of a test like a Redis by several instances.
+type mapSharedCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	err     error
+}
+
+func (c *mapSharedCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key], c.err
+}
+
+func (c *mapSharedCache) Set(key string, value []byte, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.entries[key] = value
+	return nil
+}
+
+func TestCachedOpenFGADatastoreWithSharedModelCache(t *testing.T) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user`)
+
+	sourceCount := func(source string) float64 {
+		return testutil.ToFloat64(authorizationModelCacheSourceCounter.WithLabelValues(source))
+	}
+
+	t.Run("a_second_instance_reads_the_model_from_the_shared_cache", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		defer mockController.Finish()
+
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, model.GetId()).Return(model, nil).Times(1)
+		mockDatastore.EXPECT().Close().Times(2)
+
+		sharedCache := &mapSharedCache{entries: map[string][]byte{}}
+		first := NewCachedOpenFGADatastore(mockDatastore, 10, WithSharedModelCache(sharedCache))
+		defer first.Close()
+		second := NewCachedOpenFGADatastore(mockDatastore, 10, WithSharedModelCache(sharedCache))
+		defer second.Close()
+
+		datastoreReads, sharedReads, localReads := sourceCount("datastore"), sourceCount("shared"), sourceCount("local")
+
+		got, err := first.ReadAuthorizationModel(ctx, storeID, model.GetId())
+		require.NoError(t, err)
+		require.True(t, proto.Equal(model, got))
+		require.InDelta(t, datastoreReads+1, sourceCount("datastore"), 0)
+
+		got, err = second.ReadAuthorizationModel(ctx, storeID, model.GetId())
+		require.NoError(t, err)
+		require.True(t, proto.Equal(model, got))
+		require.InDelta(t, sharedReads+1, sourceCount("shared"), 0)
+
+		// the model read from the shared cache is now in memory
+		_, err = second.ReadAuthorizationModel(ctx, storeID, model.GetId())
+		require.NoError(t, err)
+		require.InDelta(t, localReads+1, sourceCount("local"), 0)
+	})
+
+	t.Run("a_failing_shared_cache_is_a_miss", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		defer mockController.Finish()
+
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, model.GetId()).Return(model, nil).Times(2)
+		mockDatastore.EXPECT().Close().Times(2)
+
+		sharedCache := &mapSharedCache{entries: map[string][]byte{}, err: errors.New("connection refused")}
+		first := NewCachedOpenFGADatastore(mockDatastore, 10, WithSharedModelCache(sharedCache))
+		defer first.Close()
+		second := NewCachedOpenFGADatastore(mockDatastore, 10, WithSharedModelCache(sharedCache))
+		defer second.Close()
+
+		errorCount := func(operation string) float64 {
+			return testutil.ToFloat64(authorizationModelSharedCacheErrorCounter.WithLabelValues(operation))
+		}
+		getErrors, setErrors := errorCount("get"), errorCount("set")
+
+		for _, ds := range []*cachedOpenFGADatastore{first, second} {
+			got, err := ds.ReadAuthorizationModel(ctx, storeID, model.GetId())
+			require.NoError(t, err)
+			require.True(t, proto.Equal(model, got))
+		}
+		require.InDelta(t, getErrors+2, errorCount("get"), 0)
+		require.InDelta(t, setErrors+2, errorCount("set"), 0)
+	})
+
+	t.Run("shared_keys_are_not_store_keys", func(t *testing.T) {
+		// the Check cache entries of a store, whose keys start with its ID, are invalidated on writes
+		require.False(t, strings.HasPrefix(sharedModelCacheKey(storeID+":"+model.GetId()), storeID))
+	})
+}
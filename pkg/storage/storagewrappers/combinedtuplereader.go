@@ -2,28 +2,157 @@ package storagewrappers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/tuple"
 )
 
+type contextualTuplesOverlayCtxKey struct{}
+
+// ContextWithContextualTuplesOverlay requests that the contextual tuples of the readers returned by
+// [NewCombinedTupleReader] shadow the stored tuples with the same object, relation and user when reading
+// with the returned context, instead of being added to them. This is not the standard semantics of
+// contextual tuples, which can only add relationships.
+func ContextWithContextualTuplesOverlay(parent context.Context) context.Context {
+	return context.WithValue(parent, contextualTuplesOverlayCtxKey{}, true)
+}
+
+func overlaysContextualTuples(ctx context.Context) bool {
+	overlay, _ := ctx.Value(contextualTuplesOverlayCtxKey{}).(bool)
+	return overlay
+}
+
+// ValidateContextualTuplesAreAdditive returns an [*tuple.InvalidTupleError] for the first contextual
+// tuple that has a condition, and the same object, relation and user as a tuple stored in ds, but not the
+// same condition. Contextual tuples are added to the stored tuples, so such a tuple cannot restrict or remove
+// the stored one, and it is rejected rather than silently ignored by some reads. Contextual tuples without a
+// condition only add relationships and are never rejected.
+//
+// It reads ds once per object type, relation and user of the contextual tuples with a condition.
+func ValidateContextualTuplesAreAdditive(
+	ctx context.Context,
+	ds storage.RelationshipTupleReader,
+	store string,
+	contextualTuples []*openfgav1.TupleKey,
+) error {
+	type lookup struct {
+		objectType, relation, user string
+	}
+
+	conditioned := map[string]*openfgav1.TupleKey{}
+	objectIDs := map[lookup]storage.SortedSet{}
+	for _, tk := range contextualTuples {
+		if tk.GetCondition().GetName() == "" {
+			continue
+		}
+		conditioned[tuple.TupleKeyToString(tk)] = tk
+
+		objectType, objectID := tuple.SplitObject(tk.GetObject())
+		l := lookup{objectType: objectType, relation: tk.GetRelation(), user: tk.GetUser()}
+		if _, ok := objectIDs[l]; !ok {
+			objectIDs[l] = storage.NewSortedSet()
+		}
+		objectIDs[l].Add(objectID)
+	}
+
+	for l, ids := range objectIDs {
+		object, relation := tuple.SplitObjectRelation(l.user)
+		iter, err := ds.ReadStartingWithUser(ctx, store, storage.ReadStartingWithUserFilter{
+			ObjectType: l.objectType,
+			Relation:   l.relation,
+			UserFilter: []*openfgav1.ObjectRelation{{Object: object, Relation: relation}},
+			ObjectIDs:  ids,
+		}, storage.ReadStartingWithUserOptions{})
+		if err != nil {
+			return err
+		}
+
+		err = validateStoredTuples(ctx, iter, conditioned)
+		iter.Stop()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateStoredTuples returns an [*tuple.InvalidTupleError] if a tuple of iter has the key of one of the
+// contextual tuples, but not the same condition.
+func validateStoredTuples(ctx context.Context, iter storage.TupleIterator, contextualTuples map[string]*openfgav1.TupleKey) error {
+	for {
+		stored, err := iter.Next(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrIteratorDone) {
+				return nil
+			}
+			return err
+		}
+
+		tk, ok := contextualTuples[tuple.TupleKeyToString(stored.GetKey())]
+		if ok && !sameCondition(stored.GetKey().GetCondition(), tk.GetCondition()) {
+			return &tuple.InvalidTupleError{
+				Cause:    fmt.Errorf("it conflicts with the stored tuple '%s': contextual tuples can only add relationships, not restrict or remove stored ones", tuple.TupleKeyWithConditionToString(stored.GetKey())),
+				TupleKey: tk,
+			}
+		}
+	}
+}
+
+func sameCondition(a, b *openfgav1.RelationshipCondition) bool {
+	if a.GetName() != b.GetName() {
+		return false
+	}
+	// a missing context and an empty context are the same
+	if len(a.GetContext().GetFields()) == 0 && len(b.GetContext().GetFields()) == 0 {
+		return true
+	}
+	return proto.Equal(a.GetContext(), b.GetContext())
+}
+
 // NewCombinedTupleReader returns a [storage.RelationshipTupleReader] that reads from
 // a persistent datastore and from the contextual tuples specified in the request.
+//
+// The contextual tuples are added to the stored tuples. There is no precedence between a contextual tuple
+// and a stored tuple with the same object, relation and user, so callers can reject contextual tuples with a
+// condition that isn't the one of the stored tuple with [ValidateContextualTuplesAreAdditive]. Reads with a
+// context returned by [ContextWithContextualTuplesOverlay] skip the stored tuples shadowed by a contextual tuple.
 func NewCombinedTupleReader(
 	ds storage.RelationshipTupleReader,
 	contextualTuples []*openfgav1.TupleKey,
 ) storage.RelationshipTupleReader {
+	contextualKeys := make(map[string]struct{}, len(contextualTuples))
+	for _, tk := range contextualTuples {
+		contextualKeys[tuple.TupleKeyToString(tk)] = struct{}{}
+	}
+
 	return &combinedTupleReader{
 		RelationshipTupleReader: ds,
 		contextualTuples:        contextualTuples,
+		contextualKeys:          contextualKeys,
 	}
 }
 
 type combinedTupleReader struct {
 	storage.RelationshipTupleReader
 	contextualTuples []*openfgav1.TupleKey
+	// contextualKeys are the keys, without condition, of the contextual tuples.
+	contextualKeys map[string]struct{}
+}
+
+// stored returns the reader of the stored tuples that are visible with ctx.
+func (c *combinedTupleReader) stored(ctx context.Context) storage.RelationshipTupleReader {
+	if len(c.contextualKeys) == 0 || !overlaysContextualTuples(ctx) {
+		return c.RelationshipTupleReader
+	}
+	return NewFilteredTupleReader(c.RelationshipTupleReader, func(t *openfgav1.Tuple) bool {
+		_, shadowed := c.contextualKeys[tuple.TupleKeyToString(t.GetKey())]
+		return !shadowed
+	})
 }
 
 var _ storage.RelationshipTupleReader = (*combinedTupleReader)(nil)
@@ -52,7 +181,7 @@ func (c *combinedTupleReader) Read(
 ) (storage.TupleIterator, error) {
 	iter1 := storage.NewStaticTupleIterator(filterTuples(c.contextualTuples, tk.GetObject(), tk.GetRelation()))
 
-	iter2, err := c.RelationshipTupleReader.Read(ctx, storeID, tk, options)
+	iter2, err := c.stored(ctx).Read(ctx, storeID, tk, options)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +230,7 @@ func (c *combinedTupleReader) ReadUsersetTuples(
 
 	iter1 := storage.NewStaticTupleIterator(usersetTuples)
 
-	iter2, err := c.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+	iter2, err := c.stored(ctx).ReadUsersetTuples(ctx, store, filter, options)
 	if err != nil {
 		return nil, err
 	}
@@ -142,7 +271,7 @@ func (c *combinedTupleReader) ReadStartingWithUser(
 
 	iter1 := storage.NewStaticTupleIterator(filteredTuples)
 
-	iter2, err := c.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+	iter2, err := c.stored(ctx).ReadStartingWithUser(ctx, store, filter, options)
 	if err != nil {
 		return nil, err
 	}
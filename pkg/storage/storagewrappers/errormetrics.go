@@ -0,0 +1,223 @@
+package storagewrappers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+)
+
+const (
+	// maxStoreIDLabels is the maximum number of distinct store IDs used as metric labels.
+	// The errors of any other store are recorded with otherStoreIDLabel.
+	maxStoreIDLabels = 100
+
+	otherStoreIDLabel = "other"
+
+	errorClassTimeout    = "timeout"
+	errorClassConflict   = "conflict"
+	errorClassConnection = "connection"
+	errorClassOther      = "other"
+)
+
+var datastoreErrorCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: build.ProjectName,
+	Name:      "datastore_errors_total",
+	Help:      "The total number of errors returned by the datastore, by store and class of error (timeout, conflict, connection or other).",
+}, []string{"store_id", "error_class"})
+
+// ErrorMetricsDatastore is a wrapper for a datastore that counts the errors of the calls scoped to a store, by
+// store ID and class of error, so that the failures of one store can be told apart from the overall error rate.
+// Errors that are an expected result of the call, such as [storage.ErrNotFound], or that are caused by the
+// request, such as [storage.ErrInvalidWriteInput] or a cancellation, are not counted.
+type ErrorMetricsDatastore struct {
+	storage.OpenFGADatastore
+
+	storeIDLabelsMu sync.Mutex
+	storeIDLabels   map[string]struct{}
+}
+
+var _ storage.OpenFGADatastore = (*ErrorMetricsDatastore)(nil)
+
+// NewErrorMetricsDatastore returns a new [ErrorMetricsDatastore] wrapping the specified datastore.
+func NewErrorMetricsDatastore(inner storage.OpenFGADatastore) *ErrorMetricsDatastore {
+	return &ErrorMetricsDatastore{
+		OpenFGADatastore: inner,
+		storeIDLabels:    map[string]struct{}{},
+	}
+}
+
+// Read see [storage.RelationshipTupleReader.Read].
+func (e *ErrorMetricsDatastore) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	iter, err := e.OpenFGADatastore.Read(ctx, store, tupleKey, options)
+	if err != nil {
+		return nil, e.observe(store, err)
+	}
+	return &errorMetricsTupleIterator{TupleIterator: iter, store: store, datastore: e}, nil
+}
+
+// ReadPage see [storage.RelationshipTupleReader.ReadPage].
+func (e *ErrorMetricsDatastore) ReadPage(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadPageOptions) ([]*openfgav1.Tuple, []byte, error) {
+	tuples, contToken, err := e.OpenFGADatastore.ReadPage(ctx, store, tupleKey, options)
+	return tuples, contToken, e.observe(store, err)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader.ReadUserTuple].
+func (e *ErrorMetricsDatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	t, err := e.OpenFGADatastore.ReadUserTuple(ctx, store, tupleKey, options)
+	return t, e.observe(store, err)
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader.ReadUsersetTuples].
+func (e *ErrorMetricsDatastore) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	iter, err := e.OpenFGADatastore.ReadUsersetTuples(ctx, store, filter, options)
+	if err != nil {
+		return nil, e.observe(store, err)
+	}
+	return &errorMetricsTupleIterator{TupleIterator: iter, store: store, datastore: e}, nil
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader.ReadStartingWithUser].
+func (e *ErrorMetricsDatastore) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	iter, err := e.OpenFGADatastore.ReadStartingWithUser(ctx, store, filter, options)
+	if err != nil {
+		return nil, e.observe(store, err)
+	}
+	return &errorMetricsTupleIterator{TupleIterator: iter, store: store, datastore: e}, nil
+}
+
+// Write see [storage.RelationshipTupleWriter.Write].
+func (e *ErrorMetricsDatastore) Write(ctx context.Context, store string, deletes storage.Deletes, writes storage.Writes) error {
+	return e.observe(store, e.OpenFGADatastore.Write(ctx, store, deletes, writes))
+}
+
+// ReadAuthorizationModel see [storage.AuthorizationModelReadBackend.ReadAuthorizationModel].
+func (e *ErrorMetricsDatastore) ReadAuthorizationModel(ctx context.Context, store string, id string) (*openfgav1.AuthorizationModel, error) {
+	model, err := e.OpenFGADatastore.ReadAuthorizationModel(ctx, store, id)
+	return model, e.observe(store, err)
+}
+
+// ReadAuthorizationModels see [storage.AuthorizationModelReadBackend.ReadAuthorizationModels].
+func (e *ErrorMetricsDatastore) ReadAuthorizationModels(ctx context.Context, store string, options storage.ReadAuthorizationModelsOptions) ([]*openfgav1.AuthorizationModel, []byte, error) {
+	models, contToken, err := e.OpenFGADatastore.ReadAuthorizationModels(ctx, store, options)
+	return models, contToken, e.observe(store, err)
+}
+
+// FindLatestAuthorizationModel see [storage.AuthorizationModelReadBackend.FindLatestAuthorizationModel].
+func (e *ErrorMetricsDatastore) FindLatestAuthorizationModel(ctx context.Context, store string) (*openfgav1.AuthorizationModel, error) {
+	model, err := e.OpenFGADatastore.FindLatestAuthorizationModel(ctx, store)
+	return model, e.observe(store, err)
+}
+
+// WriteAuthorizationModel see [storage.TypeDefinitionWriteBackend.WriteAuthorizationModel].
+func (e *ErrorMetricsDatastore) WriteAuthorizationModel(ctx context.Context, store string, model *openfgav1.AuthorizationModel) error {
+	return e.observe(store, e.OpenFGADatastore.WriteAuthorizationModel(ctx, store, model))
+}
+
+// WriteAssertions see [storage.AssertionsBackend.WriteAssertions].
+func (e *ErrorMetricsDatastore) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
+	return e.observe(store, e.OpenFGADatastore.WriteAssertions(ctx, store, modelID, assertions))
+}
+
+// ReadAssertions see [storage.AssertionsBackend.ReadAssertions].
+func (e *ErrorMetricsDatastore) ReadAssertions(ctx context.Context, store, modelID string) ([]*openfgav1.Assertion, error) {
+	assertions, err := e.OpenFGADatastore.ReadAssertions(ctx, store, modelID)
+	return assertions, e.observe(store, err)
+}
+
+// ReadChanges see [storage.ChangelogBackend.ReadChanges].
+func (e *ErrorMetricsDatastore) ReadChanges(ctx context.Context, store, objectType string, options storage.ReadChangesOptions, horizonOffset time.Duration) ([]*openfgav1.TupleChange, []byte, error) {
+	changes, contToken, err := e.OpenFGADatastore.ReadChanges(ctx, store, objectType, options, horizonOffset)
+	return changes, contToken, e.observe(store, err)
+}
+
+// observe counts err, if it is a failure of the datastore, and returns it unchanged.
+func (e *ErrorMetricsDatastore) observe(store string, err error) error {
+	if class, ok := classifyDatastoreError(err); ok {
+		datastoreErrorCounter.WithLabelValues(e.storeIDLabel(store), class).Inc()
+	}
+	return err
+}
+
+// storeIDLabel returns the label value for a store ID, which is the ID itself for the first
+// maxStoreIDLabels distinct stores and otherStoreIDLabel afterward, to bound the cardinality of the metrics.
+func (e *ErrorMetricsDatastore) storeIDLabel(store string) string {
+	e.storeIDLabelsMu.Lock()
+	defer e.storeIDLabelsMu.Unlock()
+
+	if _, ok := e.storeIDLabels[store]; ok {
+		return store
+	}
+
+	if len(e.storeIDLabels) >= maxStoreIDLabels {
+		return otherStoreIDLabel
+	}
+
+	e.storeIDLabels[store] = struct{}{}
+	return store
+}
+
+// classifyDatastoreError returns the class of err, and false if err is not a failure of the datastore.
+func classifyDatastoreError(err error) (string, bool) {
+	switch {
+	case err == nil,
+		errors.Is(err, storage.ErrIteratorDone),
+		errors.Is(err, storage.ErrNotFound),
+		errors.Is(err, storage.ErrInvalidWriteInput),
+		errors.Is(err, storage.ErrInvalidContinuationToken),
+		errors.Is(err, storage.ErrMismatchObjectType),
+		errors.Is(err, storage.ErrExceededWriteBatchLimit),
+		errors.Is(err, storage.ErrCancelled),
+		errors.Is(err, context.Canceled):
+		return "", false
+	case errors.Is(err, storage.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return errorClassTimeout, true
+	case errors.Is(err, storage.ErrTransactionalWriteFailed), errors.Is(err, storage.ErrCollision):
+		return errorClassConflict, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return errorClassTimeout, true
+		}
+		return errorClassConnection, true
+	}
+
+	if sqlcommon.IsConnectionReset(err) || errors.Is(err, syscall.ECONNREFUSED) {
+		return errorClassConnection, true
+	}
+
+	return errorClassOther, true
+}
+
+// errorMetricsTupleIterator counts the errors of the datastore while iterating, such as a timeout of the query.
+type errorMetricsTupleIterator struct {
+	storage.TupleIterator
+	store     string
+	datastore *ErrorMetricsDatastore
+}
+
+var _ storage.TupleIterator = (*errorMetricsTupleIterator)(nil)
+
+// Next see [storage.Iterator.Next].
+func (i *errorMetricsTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	t, err := i.TupleIterator.Next(ctx)
+	return t, i.datastore.observe(i.store, err)
+}
+
+// Head see [storage.Iterator.Head].
+func (i *errorMetricsTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	t, err := i.TupleIterator.Head(ctx)
+	return t, i.datastore.observe(i.store, err)
+}
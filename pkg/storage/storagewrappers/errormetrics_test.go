@@ -0,0 +1,115 @@
+package storagewrappers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestErrorMetricsDatastore(t *testing.T) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+	otherStoreID := ulid.Make().String()
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+
+	errorCount := func(store, class string) float64 {
+		return testutil.ToFloat64(datastoreErrorCounter.WithLabelValues(store, class))
+	}
+
+	t.Run("errors_are_attributed_to_the_store_and_class", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		defer mockController.Finish()
+
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, fmt.Errorf("sql error: %w", context.DeadlineExceeded))
+		mockDatastore.EXPECT().Write(gomock.Any(), otherStoreID, gomock.Any(), gomock.Any()).Return(storage.ErrTransactionalWriteFailed)
+		mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, "model").Return(nil, fmt.Errorf("sql error: %w", syscall.ECONNREFUSED))
+
+		ds := NewErrorMetricsDatastore(mockDatastore)
+
+		timeouts := errorCount(storeID, errorClassTimeout)
+		conflicts := errorCount(otherStoreID, errorClassConflict)
+		connections := errorCount(storeID, errorClassConnection)
+		otherStoreTimeouts := errorCount(otherStoreID, errorClassTimeout)
+
+		_, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		err = ds.Write(ctx, otherStoreID, nil, []*openfgav1.TupleKey{tk})
+		require.ErrorIs(t, err, storage.ErrTransactionalWriteFailed)
+		_, err = ds.ReadAuthorizationModel(ctx, storeID, "model")
+		require.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+		require.InDelta(t, timeouts+1, errorCount(storeID, errorClassTimeout), 0)
+		require.InDelta(t, conflicts+1, errorCount(otherStoreID, errorClassConflict), 0)
+		require.InDelta(t, connections+1, errorCount(storeID, errorClassConnection), 0)
+		require.InDelta(t, otherStoreTimeouts, errorCount(otherStoreID, errorClassTimeout), 0)
+	})
+
+	t.Run("errors_while_iterating_are_counted", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		defer mockController.Finish()
+
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().Read(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Return(mocks.NewErrorTupleIterator([]*openfgav1.Tuple{{Key: tk}, {Key: tk}}), nil)
+
+		ds := NewErrorMetricsDatastore(mockDatastore)
+
+		others := errorCount(storeID, errorClassOther)
+
+		iter, err := ds.Read(ctx, storeID, nil, storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		_, err = iter.Next(ctx)
+		require.NoError(t, err)
+		_, err = iter.Next(ctx)
+		require.Error(t, err)
+
+		require.InDelta(t, others+1, errorCount(storeID, errorClassOther), 0)
+	})
+
+	t.Run("expected_errors_are_not_counted", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		defer mockController.Finish()
+
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, storage.ErrNotFound)
+
+		ds := NewErrorMetricsDatastore(mockDatastore)
+
+		values := map[string]float64{}
+		for _, class := range []string{errorClassTimeout, errorClassConflict, errorClassConnection, errorClassOther} {
+			values[class] = errorCount(storeID, class)
+		}
+
+		_, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		for class, value := range values {
+			require.InDelta(t, value, errorCount(storeID, class), 0)
+		}
+	})
+
+	t.Run("store_ids_above_the_limit_are_recorded_as_other", func(t *testing.T) {
+		ds := NewErrorMetricsDatastore(nil)
+
+		for i := 0; i < maxStoreIDLabels; i++ {
+			require.Equal(t, strconv.Itoa(i), ds.storeIDLabel(strconv.Itoa(i)))
+		}
+
+		require.Equal(t, otherStoreIDLabel, ds.storeIDLabel(storeID))
+		require.Equal(t, "0", ds.storeIDLabel("0"))
+	})
+}
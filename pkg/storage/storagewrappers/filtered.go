@@ -0,0 +1,147 @@
+package storagewrappers
+
+import (
+	"context"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+var _ storage.RelationshipTupleReader = (*filteredTupleReader)(nil)
+
+// NewFilteredTupleReader returns a [storage.RelationshipTupleReader] that only returns the tuples
+// of ds for which keep returns true. The other tuples are treated as if they didn't exist. keep may
+// be called concurrently, and more than once for the same tuple.
+func NewFilteredTupleReader(ds storage.RelationshipTupleReader, keep func(*openfgav1.Tuple) bool) storage.RelationshipTupleReader {
+	return &filteredTupleReader{
+		RelationshipTupleReader: ds,
+		keep:                    keep,
+	}
+}
+
+type filteredTupleReader struct {
+	storage.RelationshipTupleReader
+	keep func(*openfgav1.Tuple) bool
+}
+
+// Read see [storage.RelationshipTupleReader.Read].
+func (s *filteredTupleReader) Read(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadOptions,
+) (storage.TupleIterator, error) {
+	iter, err := s.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredTupleIterator{iter: iter, reader: s}, nil
+}
+
+// ReadPage see [storage.RelationshipTupleReader.ReadPage]. Pages may have less tuples than requested,
+// or none at all, even if there are more pages.
+func (s *filteredTupleReader) ReadPage(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadPageOptions,
+) ([]*openfgav1.Tuple, []byte, error) {
+	tuples, continuationToken, err := s.RelationshipTupleReader.ReadPage(ctx, store, tupleKey, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kept := make([]*openfgav1.Tuple, 0, len(tuples))
+	for _, t := range tuples {
+		if s.keep(t) {
+			kept = append(kept, t)
+		}
+	}
+	return kept, continuationToken, nil
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader.ReadUserTuple].
+func (s *filteredTupleReader) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	t, err := s.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+	if err != nil {
+		return nil, err
+	}
+	if !s.keep(t) {
+		return nil, storage.ErrNotFound
+	}
+	return t, nil
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader.ReadUsersetTuples].
+func (s *filteredTupleReader) ReadUsersetTuples(
+	ctx context.Context,
+	store string,
+	filter storage.ReadUsersetTuplesFilter,
+	options storage.ReadUsersetTuplesOptions,
+) (storage.TupleIterator, error) {
+	iter, err := s.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredTupleIterator{iter: iter, reader: s}, nil
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader.ReadStartingWithUser].
+func (s *filteredTupleReader) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	iter, err := s.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredTupleIterator{iter: iter, reader: s}, nil
+}
+
+// filteredTupleIterator skips the tuples of iter that reader doesn't keep.
+type filteredTupleIterator struct {
+	iter   storage.TupleIterator
+	reader *filteredTupleReader
+}
+
+var _ storage.TupleIterator = (*filteredTupleIterator)(nil)
+
+func (s *filteredTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	for {
+		t, err := s.iter.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if s.reader.keep(t) {
+			return t, nil
+		}
+	}
+}
+
+func (s *filteredTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	for {
+		t, err := s.iter.Head(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if s.reader.keep(t) {
+			return t, nil
+		}
+		// discard the filtered out tuple so that the next one becomes the head
+		if _, err := s.iter.Next(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *filteredTupleIterator) Stop() {
+	s.iter.Stop()
+}
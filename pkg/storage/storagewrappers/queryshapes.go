@@ -0,0 +1,135 @@
+package storagewrappers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var _ storage.RelationshipTupleReader = (*queryShapeRecordingTupleReader)(nil)
+
+// QueryShapes counts the datastore queries of a request by shape. The shape of a query is its method with the
+// object types, relations and user types that it reads, but without the IDs, e.g.
+// 'ReadUserTuple(document:?#viewer@user:?)', so that a shape that is queried once per object, like in an N+1
+// pattern, stands out.
+type QueryShapes struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewQueryShapes returns empty counts of query shapes.
+func NewQueryShapes() *QueryShapes {
+	return &QueryShapes{counts: map[string]int{}}
+}
+
+// Counts returns the number of queries of every shape.
+func (q *QueryShapes) Counts() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return maps.Clone(q.counts)
+}
+
+func (q *QueryShapes) add(shape string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.counts[shape]++
+}
+
+type queryShapeRecordingTupleReader struct {
+	storage.RelationshipTupleReader
+	shapes *QueryShapes
+}
+
+// NewQueryShapeRecordingTupleReader returns a wrapper over a datastore that counts the shapes of the queries to
+// it in shapes. The counting has a cost, so it's meant to be used only when the request is traced or debugged.
+func NewQueryShapeRecordingTupleReader(wrapped storage.RelationshipTupleReader, shapes *QueryShapes) *queryShapeRecordingTupleReader {
+	return &queryShapeRecordingTupleReader{
+		RelationshipTupleReader: wrapped,
+		shapes:                  shapes,
+	}
+}
+
+// Read see [storage.RelationshipTupleReader].Read.
+func (r *queryShapeRecordingTupleReader) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	r.shapes.add("Read(" + tupleKeyShape(tupleKey) + ")")
+	return r.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+}
+
+// ReadPage see [storage.RelationshipTupleReader].ReadPage.
+func (r *queryShapeRecordingTupleReader) ReadPage(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadPageOptions) ([]*openfgav1.Tuple, []byte, error) {
+	r.shapes.add("ReadPage(" + tupleKeyShape(tupleKey) + ")")
+	return r.RelationshipTupleReader.ReadPage(ctx, store, tupleKey, options)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
+func (r *queryShapeRecordingTupleReader) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	r.shapes.add("ReadUserTuple(" + tupleKeyShape(tupleKey) + ")")
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader].ReadUsersetTuples.
+func (r *queryShapeRecordingTupleReader) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	userTypes := make([]string, 0, len(filter.AllowedUserTypeRestrictions))
+	for _, restriction := range filter.AllowedUserTypeRestrictions {
+		switch {
+		case restriction.GetWildcard() != nil:
+			userTypes = append(userTypes, tuple.TypedPublicWildcard(restriction.GetType()))
+		case restriction.GetRelation() != "":
+			userTypes = append(userTypes, tuple.ToObjectRelationString(restriction.GetType(), restriction.GetRelation()))
+		default:
+			userTypes = append(userTypes, restriction.GetType())
+		}
+	}
+
+	r.shapes.add(fmt.Sprintf("ReadUsersetTuples(%s#%s@[%s])", objectShape(filter.Object), filter.Relation, strings.Join(userTypes, ",")))
+	return r.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+func (r *queryShapeRecordingTupleReader) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	users := make([]string, 0, len(filter.UserFilter))
+	for _, user := range filter.UserFilter {
+		users = append(users, userShape(tuple.GetObjectRelationAsString(user)))
+	}
+
+	object := filter.ObjectType + ":?"
+	if filter.ObjectIDs != nil {
+		object = filter.ObjectType + ":[?]"
+	}
+
+	r.shapes.add(fmt.Sprintf("ReadStartingWithUser(%s#%s@[%s])", object, filter.Relation, strings.Join(users, ",")))
+	return r.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+}
+
+func tupleKeyShape(tupleKey *openfgav1.TupleKey) string {
+	return fmt.Sprintf("%s#%s@%s", objectShape(tupleKey.GetObject()), tupleKey.GetRelation(), userShape(tupleKey.GetUser()))
+}
+
+// objectShape replaces the ID of the object, if any, with '?'. Objects without an ID, e.g. 'document:', are kept
+// as they are.
+func objectShape(object string) string {
+	objectType, objectID := tuple.SplitObject(object)
+	if objectType == "" || objectID == "" {
+		return object
+	}
+	return objectType + ":?"
+}
+
+// userShape replaces the ID of the user, or of the object of a userset, with '?'. Wildcards are kept.
+func userShape(user string) string {
+	if tuple.IsWildcard(user) {
+		return user
+	}
+	object, relation := tuple.SplitObjectRelation(user)
+	if relation == "" {
+		return objectShape(object)
+	}
+	return tuple.ToObjectRelationString(objectShape(object), relation)
+}
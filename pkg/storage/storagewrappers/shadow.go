@@ -0,0 +1,345 @@
+package storagewrappers
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+const (
+	// maxComparedTuples is the number of tuples buffered from each reader to compare a read. Reads that
+	// return more tuples are not compared.
+	maxComparedTuples = 1000
+
+	// maxConcurrentComparisons is the number of reads compared at the same time. The sampled reads are not
+	// compared while it's reached.
+	maxConcurrentComparisons = 10
+
+	// shadowReadTimeout bounds the shadow reads, which outlive the requests.
+	shadowReadTimeout = 10 * time.Second
+)
+
+var _ storage.RelationshipTupleReader = (*shadowTupleReader)(nil)
+
+// NewShadowTupleReader returns a [storage.RelationshipTupleReader] that serves reads from primary, and
+// runs samplePercentage percent of them against shadow as well, logging a warning when the tuples
+// returned by shadow differ from the ones returned by primary. It is meant to validate a datastore
+// that is being migrated to before switching over to it.
+//
+// The reads are served by primary as usual, and compared with shadow in the background once they are
+// done, so that the comparison adds no latency. Iterators that are stopped before they are done, and reads
+// of more than maxComparedTuples tuples, are not compared. Reads made with a context returned by
+// [storage.ContextWithShadowReads] are served by shadow only. ReadPage is never compared, because each
+// datastore paginates in its own order.
+func NewShadowTupleReader(
+	primary, shadow storage.RelationshipTupleReader,
+	samplePercentage int,
+	logger logger.Logger,
+) storage.RelationshipTupleReader {
+	return &shadowTupleReader{
+		RelationshipTupleReader: primary,
+		shadow:                  shadow,
+		samplePercentage:        samplePercentage,
+		logger:                  logger,
+		comparisons:             make(chan struct{}, maxConcurrentComparisons),
+	}
+}
+
+type shadowTupleReader struct {
+	// RelationshipTupleReader is the primary reader.
+	storage.RelationshipTupleReader
+	shadow           storage.RelationshipTupleReader
+	samplePercentage int
+	logger           logger.Logger
+
+	// comparisons limits the number of concurrent comparisons.
+	comparisons chan struct{}
+}
+
+func (s *shadowTupleReader) sampled() bool {
+	return rand.IntN(100) < s.samplePercentage
+}
+
+// compareInBackground runs compare in a new goroutine with a context that outlives ctx, unless
+// maxConcurrentComparisons comparisons are already running.
+func (s *shadowTupleReader) compareInBackground(ctx context.Context, compare func(ctx context.Context)) {
+	select {
+	case s.comparisons <- struct{}{}:
+	default:
+		return
+	}
+
+	go func() {
+		defer func() { <-s.comparisons }()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowReadTimeout)
+		defer cancel()
+		compare(ctx)
+	}()
+}
+
+// Read see [storage.RelationshipTupleReader.Read].
+func (s *shadowTupleReader) Read(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadOptions,
+) (storage.TupleIterator, error) {
+	if storage.ShadowReadsFromContext(ctx) {
+		return s.shadow.Read(ctx, store, tupleKey, options)
+	}
+
+	iter, err := s.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+	if err != nil || !s.sampled() {
+		return iter, err
+	}
+	return s.newComparingIterator(ctx, "Read", store, iter, func(ctx context.Context) (storage.TupleIterator, error) {
+		return s.shadow.Read(ctx, store, tupleKey, options)
+	}), nil
+}
+
+// ReadPage see [storage.RelationshipTupleReader.ReadPage].
+func (s *shadowTupleReader) ReadPage(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadPageOptions,
+) ([]*openfgav1.Tuple, []byte, error) {
+	if storage.ShadowReadsFromContext(ctx) {
+		return s.shadow.ReadPage(ctx, store, tupleKey, options)
+	}
+	return s.RelationshipTupleReader.ReadPage(ctx, store, tupleKey, options)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader.ReadUserTuple].
+func (s *shadowTupleReader) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	if storage.ShadowReadsFromContext(ctx) {
+		return s.shadow.ReadUserTuple(ctx, store, tupleKey, options)
+	}
+
+	t, err := s.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+	if (err != nil && !errors.Is(err, storage.ErrNotFound)) || !s.sampled() {
+		return t, err
+	}
+
+	var primaryTuples []*openfgav1.Tuple
+	if err == nil {
+		primaryTuples = append(primaryTuples, t)
+	}
+
+	s.compareInBackground(ctx, func(ctx context.Context) {
+		var shadowTuples []*openfgav1.Tuple
+		shadowTuple, shadowErr := s.shadow.ReadUserTuple(ctx, store, tupleKey, options)
+		if shadowErr == nil {
+			shadowTuples = append(shadowTuples, shadowTuple)
+		} else if errors.Is(shadowErr, storage.ErrNotFound) {
+			shadowErr = nil
+		}
+
+		s.logDifferences(ctx, "ReadUserTuple", store, primaryTuples, shadowTuples, shadowErr)
+	})
+	return t, err
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader.ReadUsersetTuples].
+func (s *shadowTupleReader) ReadUsersetTuples(
+	ctx context.Context,
+	store string,
+	filter storage.ReadUsersetTuplesFilter,
+	options storage.ReadUsersetTuplesOptions,
+) (storage.TupleIterator, error) {
+	if storage.ShadowReadsFromContext(ctx) {
+		return s.shadow.ReadUsersetTuples(ctx, store, filter, options)
+	}
+
+	iter, err := s.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+	if err != nil || !s.sampled() {
+		return iter, err
+	}
+	return s.newComparingIterator(ctx, "ReadUsersetTuples", store, iter, func(ctx context.Context) (storage.TupleIterator, error) {
+		return s.shadow.ReadUsersetTuples(ctx, store, filter, options)
+	}), nil
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader.ReadStartingWithUser].
+func (s *shadowTupleReader) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	if storage.ShadowReadsFromContext(ctx) {
+		return s.shadow.ReadStartingWithUser(ctx, store, filter, options)
+	}
+
+	iter, err := s.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+	if err != nil || !s.sampled() {
+		return iter, err
+	}
+	return s.newComparingIterator(ctx, "ReadStartingWithUser", store, iter, func(ctx context.Context) (storage.TupleIterator, error) {
+		return s.shadow.ReadStartingWithUser(ctx, store, filter, options)
+	}), nil
+}
+
+// comparingIterator serves the tuples of the primary iterator, and buffers them to compare them with the
+// tuples read from the shadow reader once the primary iterator is done.
+type comparingIterator struct {
+	storage.TupleIterator
+	reader     *shadowTupleReader
+	ctx        context.Context
+	method     string
+	store      string
+	readShadow func(ctx context.Context) (storage.TupleIterator, error)
+
+	tuples []*openfgav1.Tuple
+	// compared is set once the comparison started, or once it can't be made.
+	compared bool
+}
+
+var _ storage.TupleIterator = (*comparingIterator)(nil)
+
+func (s *shadowTupleReader) newComparingIterator(
+	ctx context.Context,
+	method, store string,
+	primary storage.TupleIterator,
+	readShadow func(ctx context.Context) (storage.TupleIterator, error),
+) *comparingIterator {
+	return &comparingIterator{
+		TupleIterator: primary,
+		reader:        s,
+		ctx:           ctx,
+		method:        method,
+		store:         store,
+		readShadow:    readShadow,
+	}
+}
+
+// Next see [storage.Iterator.Next].
+func (c *comparingIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	t, err := c.TupleIterator.Next(ctx)
+	if c.compared {
+		return t, err
+	}
+
+	switch {
+	case err == nil:
+		if len(c.tuples) == maxComparedTuples {
+			c.compared = true
+			c.tuples = nil
+			return t, nil
+		}
+		c.tuples = append(c.tuples, t)
+	case errors.Is(err, storage.ErrIteratorDone):
+		c.compared = true
+		c.compare()
+	default:
+		c.compared = true
+		c.tuples = nil
+	}
+	return t, err
+}
+
+func (c *comparingIterator) compare() {
+	primaryTuples := c.tuples
+	c.tuples = nil
+
+	c.reader.compareInBackground(c.ctx, func(ctx context.Context) {
+		shadowTuples, shadowErr := readAll(ctx, c.readShadow)
+		if errors.Is(shadowErr, errTooManyTuples) {
+			return
+		}
+		c.reader.logDifferences(ctx, c.method, c.store, primaryTuples, shadowTuples, shadowErr)
+	})
+}
+
+var errTooManyTuples = errors.New("too many tuples to compare")
+
+// readAll returns the tuples of the iterator returned by read, or errTooManyTuples if there are more than
+// maxComparedTuples.
+func readAll(
+	ctx context.Context,
+	read func(ctx context.Context) (storage.TupleIterator, error),
+) ([]*openfgav1.Tuple, error) {
+	iter, err := read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Stop()
+
+	var tuples []*openfgav1.Tuple
+	for {
+		t, err := iter.Next(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrIteratorDone) {
+				return tuples, nil
+			}
+			return nil, err
+		}
+		if len(tuples) == maxComparedTuples {
+			return nil, errTooManyTuples
+		}
+		tuples = append(tuples, t)
+	}
+}
+
+// logDifferences logs a warning if the shadow read failed, or if it returned different tuples than the
+// primary read, in any order.
+func (s *shadowTupleReader) logDifferences(
+	ctx context.Context,
+	method, store string,
+	primaryTuples, shadowTuples []*openfgav1.Tuple,
+	shadowErr error,
+) {
+	if shadowErr != nil {
+		s.logger.WarnWithContext(ctx, "shadow tuple read failed",
+			zap.String("method", method),
+			zap.String("store_id", store),
+			zap.Error(shadowErr),
+		)
+		return
+	}
+
+	inPrimary := make(map[string]struct{}, len(primaryTuples))
+	for _, t := range primaryTuples {
+		inPrimary[tuple.TupleKeyWithConditionToString(t.GetKey())] = struct{}{}
+	}
+
+	var onlyInShadow []string
+	for _, t := range shadowTuples {
+		key := tuple.TupleKeyWithConditionToString(t.GetKey())
+		if _, ok := inPrimary[key]; ok {
+			delete(inPrimary, key)
+			continue
+		}
+		onlyInShadow = append(onlyInShadow, key)
+	}
+
+	if len(inPrimary) == 0 && len(onlyInShadow) == 0 {
+		return
+	}
+
+	onlyInPrimary := make([]string, 0, len(inPrimary))
+	for key := range inPrimary {
+		onlyInPrimary = append(onlyInPrimary, key)
+	}
+
+	s.logger.WarnWithContext(ctx, "shadow tuple read returned different tuples",
+		zap.String("method", method),
+		zap.String("store_id", store),
+		zap.Strings("only_in_primary", onlyInPrimary),
+		zap.Strings("only_in_shadow", onlyInShadow),
+	)
+}
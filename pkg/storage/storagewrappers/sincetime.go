@@ -0,0 +1,20 @@
+package storagewrappers
+
+import (
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// NewSinceTimeTupleReader returns a [storage.RelationshipTupleReader] that only returns the tuples
+// of ds on objects of objectType that were written after since, according to their timestamp. Older
+// tuples on objects of objectType are treated as if they didn't exist, and the tuples on objects of
+// the other types are all returned.
+func NewSinceTimeTupleReader(ds storage.RelationshipTupleReader, objectType string, since time.Time) storage.RelationshipTupleReader {
+	return NewFilteredTupleReader(ds, func(t *openfgav1.Tuple) bool {
+		return tuple.GetType(t.GetKey().GetObject()) != objectType || t.GetTimestamp().AsTime().After(since)
+	})
+}
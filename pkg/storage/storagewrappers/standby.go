@@ -0,0 +1,535 @@
+package storagewrappers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	defaultStandbyFailureThreshold = 5
+	defaultStandbyCooldown         = 10 * time.Second
+
+	// at most one read served by the standby is logged every standbyLogInterval
+	standbyLogInterval = 10 * time.Second
+)
+
+var (
+	datastoreDegradedGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: build.ProjectName,
+		Name:      "datastore_degraded",
+		Help:      "1 while the primary datastore is unavailable and the reads are served by the standby datastore, 0 otherwise.",
+	})
+
+	standbyReadCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "datastore_standby_read_count",
+		Help:      "The total number of reads served by the standby datastore because the primary datastore was unavailable, by method.",
+	}, []string{"method"})
+)
+
+// StandbyDatastoreOpt configures a [StandbyDatastore].
+type StandbyDatastoreOpt func(*StandbyDatastore)
+
+// WithStandbyCircuitBreaker sets the number of consecutive failures of the primary datastore after which it's
+// considered unavailable for the cooldown, during which the reads are served by the standby and the writes
+// fail without calling the primary. After the cooldown the primary is called again, and a single failure
+// makes it unavailable again.
+func WithStandbyCircuitBreaker(failureThreshold int, cooldown time.Duration) StandbyDatastoreOpt {
+	return func(s *StandbyDatastore) {
+		s.failureThreshold = failureThreshold
+		s.cooldown = cooldown
+	}
+}
+
+// StandbyDatastore is a wrapper for a datastore that fails the reads over to a standby datastore, e.g. a
+// replica in another region, while the primary datastore is unavailable, so that Checks keep being served,
+// possibly from slightly stale tuples, during an outage of the primary. The primary is unavailable once it
+// failed too many times in a row, see [WithStandbyCircuitBreaker]; the errors that are an expected result of
+// the call, such as [storage.ErrNotFound], don't count as failures.
+//
+// The writes are never sent to the standby: while the primary is unavailable they fail with
+// [storage.ErrDatastoreUnavailable]. The reads that request higher consistency aren't sent to the standby
+// either. The optional interfaces, e.g. [storage.StoreSettingsBackend], are supported if the primary supports
+// them, see [storage.As]; their reads fail over if the standby supports them too.
+type StandbyDatastore struct {
+	storage.OpenFGADatastore
+	standby storage.OpenFGADatastore
+	logger  logger.Logger
+	limiter *rate.Limiter
+	now     func() time.Time
+
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu sync.Mutex
+	// consecutiveFailures is the number of failures since the last successful call to the primary.
+	consecutiveFailures int // GUARDED_BY(mu).
+	// openUntil is the time until which the primary isn't called.
+	openUntil time.Time // GUARDED_BY(mu).
+	// degraded is whether the primary is unavailable, from the failure that made it unavailable until the
+	// next successful call.
+	degraded bool // GUARDED_BY(mu).
+}
+
+var (
+	_ storage.OpenFGADatastore            = (*StandbyDatastore)(nil)
+	_ storage.DatastoreWrapper            = (*StandbyDatastore)(nil)
+	_ storage.TransactionalBatchWriter    = (*StandbyDatastore)(nil)
+	_ storage.IdempotentWriter            = (*StandbyDatastore)(nil)
+	_ storage.TupleCountsByRelationReader = (*StandbyDatastore)(nil)
+	_ storage.AuthorizationModelPruner    = (*StandbyDatastore)(nil)
+	_ storage.IndexRebuilder              = (*StandbyDatastore)(nil)
+	_ storage.UniqueStoreNameCreator      = (*StandbyDatastore)(nil)
+	_ storage.StoreSettingsBackend        = (*StandbyDatastore)(nil)
+)
+
+// NewStandbyDatastore returns a new [StandbyDatastore] that serves the calls from primary, and the reads from
+// standby while primary is unavailable. Closing it closes primary only.
+func NewStandbyDatastore(primary, standby storage.OpenFGADatastore, logger logger.Logger, opts ...StandbyDatastoreOpt) *StandbyDatastore {
+	s := &StandbyDatastore{
+		OpenFGADatastore: primary,
+		standby:          standby,
+		logger:           logger,
+		limiter:          rate.NewLimiter(rate.Every(standbyLogInterval), 1),
+		now:              time.Now,
+		failureThreshold: defaultStandbyFailureThreshold,
+		cooldown:         defaultStandbyCooldown,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Unwrap see [storage.DatastoreWrapper.Unwrap]. It returns the primary.
+func (s *StandbyDatastore) Unwrap() storage.OpenFGADatastore {
+	return s.OpenFGADatastore
+}
+
+// Degraded returns whether the primary is unavailable, and so the reads are served by the standby, e.g. so that
+// their results, which may be stale, aren't cached.
+func (s *StandbyDatastore) Degraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.degraded
+}
+
+// primaryAllowed returns false if the primary must not be called because it's unavailable.
+func (s *StandbyDatastore) primaryAllowed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.now().Before(s.openUntil)
+}
+
+// observe records the outcome of a call to the primary, and returns whether the primary is unavailable after
+// it.
+func (s *StandbyDatastore) observe(err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !primaryFailed(err) {
+		s.consecutiveFailures = 0
+		if s.degraded {
+			s.degraded = false
+			datastoreDegradedGauge.Set(0)
+			s.logger.Info("primary datastore available again, serving reads from the primary datastore")
+		}
+		return false
+	}
+
+	s.consecutiveFailures++
+	if s.failureThreshold <= 0 || s.consecutiveFailures < s.failureThreshold {
+		return false
+	}
+
+	s.openUntil = s.now().Add(s.cooldown)
+	// after the cooldown, a single failure is enough to make the primary unavailable again
+	s.consecutiveFailures = s.failureThreshold - 1
+	if !s.degraded {
+		s.degraded = true
+		datastoreDegradedGauge.Set(1)
+		s.logger.Error("primary datastore unavailable, serving reads from the standby datastore and failing writes",
+			zap.Duration("cooldown", s.cooldown),
+			zap.Error(err),
+		)
+	}
+	return true
+}
+
+// primaryFailed returns whether err is a failure of the primary, as opposed to an expected result of the call
+// or an error caused by the request.
+func primaryFailed(err error) bool {
+	class, failed := classifyDatastoreError(err)
+	if !failed || class == errorClassConflict {
+		return false
+	}
+
+	for _, expected := range []error{
+		storage.ErrWriteTooLarge,
+		storage.ErrStoreNameConflict,
+		storage.ErrIdempotencyKeyExists,
+		storage.ErrIndexRebuildInProgress,
+		storage.ErrModelQuotaExceeded,
+	} {
+		if errors.Is(err, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// read calls read with the primary, or with the standby if the primary is unavailable, unless the read
+// requests higher consistency.
+func (s *StandbyDatastore) read(method string, preference openfgav1.ConsistencyPreference, read func(ds storage.OpenFGADatastore) error) error {
+	higherConsistency := preference == openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY
+
+	if s.primaryAllowed() {
+		err := read(s.OpenFGADatastore)
+		if !s.observe(err) || higherConsistency {
+			return err
+		}
+	} else if higherConsistency {
+		return fmt.Errorf("%w: the primary datastore is unavailable, and the standby datastore can't serve reads with higher consistency", storage.ErrDatastoreUnavailable)
+	}
+
+	standbyReadCounter.WithLabelValues(method).Inc()
+	if s.limiter.Allow() {
+		s.logger.Warn("primary datastore unavailable, serving a read from the standby datastore", zap.String("method", method))
+	}
+	return read(s.standby)
+}
+
+// write calls write, unless the primary is unavailable.
+func (s *StandbyDatastore) write(write func() error) error {
+	if !s.primaryAllowed() {
+		return fmt.Errorf("%w: the primary datastore is unavailable", storage.ErrDatastoreUnavailable)
+	}
+
+	err := write()
+	s.observe(err)
+	return err
+}
+
+// Read see [storage.RelationshipTupleReader.Read].
+func (s *StandbyDatastore) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	var iter storage.TupleIterator
+	err := s.read("Read", options.Consistency.Preference, func(ds storage.OpenFGADatastore) error {
+		var err error
+		iter, err = ds.Read(ctx, store, tupleKey, options)
+		return err
+	})
+	return iter, err
+}
+
+// ReadPage see [storage.RelationshipTupleReader.ReadPage].
+func (s *StandbyDatastore) ReadPage(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadPageOptions) ([]*openfgav1.Tuple, []byte, error) {
+	var tuples []*openfgav1.Tuple
+	var contToken []byte
+	err := s.read("ReadPage", options.Consistency.Preference, func(ds storage.OpenFGADatastore) error {
+		var err error
+		tuples, contToken, err = ds.ReadPage(ctx, store, tupleKey, options)
+		return err
+	})
+	return tuples, contToken, err
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader.ReadUserTuple].
+func (s *StandbyDatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	var t *openfgav1.Tuple
+	err := s.read("ReadUserTuple", options.Consistency.Preference, func(ds storage.OpenFGADatastore) error {
+		var err error
+		t, err = ds.ReadUserTuple(ctx, store, tupleKey, options)
+		return err
+	})
+	return t, err
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader.ReadUsersetTuples].
+func (s *StandbyDatastore) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	var iter storage.TupleIterator
+	err := s.read("ReadUsersetTuples", options.Consistency.Preference, func(ds storage.OpenFGADatastore) error {
+		var err error
+		iter, err = ds.ReadUsersetTuples(ctx, store, filter, options)
+		return err
+	})
+	return iter, err
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader.ReadStartingWithUser].
+func (s *StandbyDatastore) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	var iter storage.TupleIterator
+	err := s.read("ReadStartingWithUser", options.Consistency.Preference, func(ds storage.OpenFGADatastore) error {
+		var err error
+		iter, err = ds.ReadStartingWithUser(ctx, store, filter, options)
+		return err
+	})
+	return iter, err
+}
+
+// ReadAuthorizationModel see [storage.AuthorizationModelReadBackend.ReadAuthorizationModel].
+func (s *StandbyDatastore) ReadAuthorizationModel(ctx context.Context, store string, id string) (*openfgav1.AuthorizationModel, error) {
+	var model *openfgav1.AuthorizationModel
+	err := s.read("ReadAuthorizationModel", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		var err error
+		model, err = ds.ReadAuthorizationModel(ctx, store, id)
+		return err
+	})
+	return model, err
+}
+
+// ReadAuthorizationModels see [storage.AuthorizationModelReadBackend.ReadAuthorizationModels].
+func (s *StandbyDatastore) ReadAuthorizationModels(ctx context.Context, store string, options storage.ReadAuthorizationModelsOptions) ([]*openfgav1.AuthorizationModel, []byte, error) {
+	var models []*openfgav1.AuthorizationModel
+	var contToken []byte
+	err := s.read("ReadAuthorizationModels", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		var err error
+		models, contToken, err = ds.ReadAuthorizationModels(ctx, store, options)
+		return err
+	})
+	return models, contToken, err
+}
+
+// FindLatestAuthorizationModel see [storage.AuthorizationModelReadBackend.FindLatestAuthorizationModel].
+func (s *StandbyDatastore) FindLatestAuthorizationModel(ctx context.Context, store string) (*openfgav1.AuthorizationModel, error) {
+	var model *openfgav1.AuthorizationModel
+	err := s.read("FindLatestAuthorizationModel", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		var err error
+		model, err = ds.FindLatestAuthorizationModel(ctx, store)
+		return err
+	})
+	return model, err
+}
+
+// GetStore see [storage.StoresBackend.GetStore].
+func (s *StandbyDatastore) GetStore(ctx context.Context, id string) (*openfgav1.Store, error) {
+	var store *openfgav1.Store
+	err := s.read("GetStore", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		var err error
+		store, err = ds.GetStore(ctx, id)
+		return err
+	})
+	return store, err
+}
+
+// ListStores see [storage.StoresBackend.ListStores].
+func (s *StandbyDatastore) ListStores(ctx context.Context, options storage.ListStoresOptions) ([]*openfgav1.Store, []byte, error) {
+	var stores []*openfgav1.Store
+	var contToken []byte
+	err := s.read("ListStores", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		var err error
+		stores, contToken, err = ds.ListStores(ctx, options)
+		return err
+	})
+	return stores, contToken, err
+}
+
+// ReadAssertions see [storage.AssertionsBackend.ReadAssertions].
+func (s *StandbyDatastore) ReadAssertions(ctx context.Context, store, modelID string) ([]*openfgav1.Assertion, error) {
+	var assertions []*openfgav1.Assertion
+	err := s.read("ReadAssertions", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		var err error
+		assertions, err = ds.ReadAssertions(ctx, store, modelID)
+		return err
+	})
+	return assertions, err
+}
+
+// ReadChanges see [storage.ChangelogBackend.ReadChanges].
+func (s *StandbyDatastore) ReadChanges(ctx context.Context, store, objectType string, options storage.ReadChangesOptions, horizonOffset time.Duration) ([]*openfgav1.TupleChange, []byte, error) {
+	var changes []*openfgav1.TupleChange
+	var contToken []byte
+	err := s.read("ReadChanges", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		var err error
+		changes, contToken, err = ds.ReadChanges(ctx, store, objectType, options, horizonOffset)
+		return err
+	})
+	return changes, contToken, err
+}
+
+// Write see [storage.RelationshipTupleWriter.Write].
+func (s *StandbyDatastore) Write(ctx context.Context, store string, deletes storage.Deletes, writes storage.Writes) error {
+	return s.write(func() error {
+		return s.OpenFGADatastore.Write(ctx, store, deletes, writes)
+	})
+}
+
+// WriteAuthorizationModel see [storage.TypeDefinitionWriteBackend.WriteAuthorizationModel].
+func (s *StandbyDatastore) WriteAuthorizationModel(ctx context.Context, store string, model *openfgav1.AuthorizationModel) error {
+	return s.write(func() error {
+		return s.OpenFGADatastore.WriteAuthorizationModel(ctx, store, model)
+	})
+}
+
+// CreateStore see [storage.StoresBackend.CreateStore].
+func (s *StandbyDatastore) CreateStore(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
+	var created *openfgav1.Store
+	err := s.write(func() error {
+		var err error
+		created, err = s.OpenFGADatastore.CreateStore(ctx, store)
+		return err
+	})
+	return created, err
+}
+
+// DeleteStore see [storage.StoresBackend.DeleteStore].
+func (s *StandbyDatastore) DeleteStore(ctx context.Context, id string) error {
+	return s.write(func() error {
+		return s.OpenFGADatastore.DeleteStore(ctx, id)
+	})
+}
+
+// WriteAssertions see [storage.AssertionsBackend.WriteAssertions].
+func (s *StandbyDatastore) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
+	return s.write(func() error {
+		return s.OpenFGADatastore.WriteAssertions(ctx, store, modelID, assertions)
+	})
+}
+
+// WriteBatches see [storage.TransactionalBatchWriter.WriteBatches].
+func (s *StandbyDatastore) WriteBatches(ctx context.Context, store string, batches []storage.TupleBatch) error {
+	batchWriter, ok := s.OpenFGADatastore.(storage.TransactionalBatchWriter)
+	if !ok {
+		return errors.New("the datastore doesn't support writing batches")
+	}
+	return s.write(func() error {
+		return batchWriter.WriteBatches(ctx, store, batches)
+	})
+}
+
+// WriteBatchesIdempotently see [storage.IdempotentWriter.WriteBatchesIdempotently].
+func (s *StandbyDatastore) WriteBatchesIdempotently(ctx context.Context, store string, record storage.IdempotencyRecord, batches []storage.TupleBatch) error {
+	idempotentWriter, ok := s.OpenFGADatastore.(storage.IdempotentWriter)
+	if !ok {
+		return errors.New("the datastore doesn't support idempotency keys")
+	}
+	return s.write(func() error {
+		return idempotentWriter.WriteBatchesIdempotently(ctx, store, record, batches)
+	})
+}
+
+// ReadTupleCountsByRelation see [storage.TupleCountsByRelationReader.ReadTupleCountsByRelation].
+func (s *StandbyDatastore) ReadTupleCountsByRelation(ctx context.Context, store string, options storage.TupleCountsByRelationOptions) ([]storage.RelationTupleCount, error) {
+	if _, ok := s.OpenFGADatastore.(storage.TupleCountsByRelationReader); !ok {
+		return nil, errors.New("the datastore doesn't support counting the tuples by relation")
+	}
+	var counts []storage.RelationTupleCount
+	err := s.read("ReadTupleCountsByRelation", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		reader, ok := ds.(storage.TupleCountsByRelationReader)
+		if !ok {
+			return errors.New("the datastore doesn't support counting the tuples by relation")
+		}
+		var err error
+		counts, err = reader.ReadTupleCountsByRelation(ctx, store, options)
+		return err
+	})
+	return counts, err
+}
+
+// PruneAuthorizationModels see [storage.AuthorizationModelPruner.PruneAuthorizationModels].
+func (s *StandbyDatastore) PruneAuthorizationModels(ctx context.Context, store string, retain int, keep []string) (int, error) {
+	pruner, ok := s.OpenFGADatastore.(storage.AuthorizationModelPruner)
+	if !ok {
+		return 0, errors.New("the datastore doesn't support pruning authorization models")
+	}
+	var pruned int
+	err := s.write(func() error {
+		var err error
+		pruned, err = pruner.PruneAuthorizationModels(ctx, store, retain, keep)
+		return err
+	})
+	return pruned, err
+}
+
+// RebuildIndexes see [storage.IndexRebuilder.RebuildIndexes].
+func (s *StandbyDatastore) RebuildIndexes(ctx context.Context) error {
+	rebuilder, ok := s.OpenFGADatastore.(storage.IndexRebuilder)
+	if !ok {
+		return errors.New("the datastore doesn't support rebuilding its indexes")
+	}
+	return s.write(func() error {
+		return rebuilder.RebuildIndexes(ctx)
+	})
+}
+
+// CreateStoreWithUniqueName see [storage.UniqueStoreNameCreator.CreateStoreWithUniqueName].
+func (s *StandbyDatastore) CreateStoreWithUniqueName(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
+	creator, ok := s.OpenFGADatastore.(storage.UniqueStoreNameCreator)
+	if !ok {
+		return nil, errors.New("the datastore doesn't support unique store names")
+	}
+	var created *openfgav1.Store
+	err := s.write(func() error {
+		var err error
+		created, err = creator.CreateStoreWithUniqueName(ctx, store)
+		return err
+	})
+	return created, err
+}
+
+// ReadStoreSettings see [storage.StoreSettingsBackend.ReadStoreSettings].
+func (s *StandbyDatastore) ReadStoreSettings(ctx context.Context, store string) (*storage.StoreSettings, error) {
+	if _, ok := s.OpenFGADatastore.(storage.StoreSettingsBackend); !ok {
+		return nil, errors.New("the datastore doesn't support store settings")
+	}
+	var settings *storage.StoreSettings
+	err := s.read("ReadStoreSettings", openfgav1.ConsistencyPreference_UNSPECIFIED, func(ds storage.OpenFGADatastore) error {
+		backend, ok := ds.(storage.StoreSettingsBackend)
+		if !ok {
+			return errors.New("the datastore doesn't support store settings")
+		}
+		var err error
+		settings, err = backend.ReadStoreSettings(ctx, store)
+		return err
+	})
+	return settings, err
+}
+
+// WriteStoreSettings see [storage.StoreSettingsBackend.WriteStoreSettings].
+func (s *StandbyDatastore) WriteStoreSettings(ctx context.Context, store string, settings *storage.StoreSettings) error {
+	backend, ok := s.OpenFGADatastore.(storage.StoreSettingsBackend)
+	if !ok {
+		return errors.New("the datastore doesn't support store settings")
+	}
+	return s.write(func() error {
+		return backend.WriteStoreSettings(ctx, store, settings)
+	})
+}
+
+// IsReady reports the readiness of the standby while the primary isn't ready, since the reads can still be
+// served.
+func (s *StandbyDatastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error) {
+	status, err := s.OpenFGADatastore.IsReady(ctx)
+	if err == nil && status.IsReady {
+		return status, nil
+	}
+
+	standbyStatus, standbyErr := s.standby.IsReady(ctx)
+	if standbyErr != nil || !standbyStatus.IsReady {
+		return status, err
+	}
+
+	message := status.Message
+	if err != nil {
+		message = err.Error()
+	}
+	return storage.ReadinessStatus{
+		Message: fmt.Sprintf("primary datastore not ready (%s), serving reads from the standby datastore", message),
+		IsReady: true,
+	}, nil
+}
@@ -0,0 +1,164 @@
+package storagewrappers
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestStandbyDatastore(t *testing.T) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+	unavailable := fmt.Errorf("sql error: %w", syscall.ECONNREFUSED)
+
+	newStandbyDatastore := func(t *testing.T) (*StandbyDatastore, *mocks.MockOpenFGADatastore, *mocks.MockOpenFGADatastore, *observer.ObservedLogs, *time.Time) {
+		mockController := gomock.NewController(t)
+		t.Cleanup(mockController.Finish)
+
+		primary := mocks.NewMockOpenFGADatastore(mockController)
+		standby := mocks.NewMockOpenFGADatastore(mockController)
+		observerLogger, logs := observer.New(zap.InfoLevel)
+
+		ds := NewStandbyDatastore(primary, standby, &logger.ZapLogger{Logger: zap.New(observerLogger)}, WithStandbyCircuitBreaker(2, time.Minute))
+		now := time.Now()
+		ds.now = func() time.Time { return now }
+		return ds, primary, standby, logs, &now
+	}
+
+	t.Run("reads_fail_over_to_the_standby_while_the_primary_is_unavailable", func(t *testing.T) {
+		ds, primary, standby, logs, now := newStandbyDatastore(t)
+		standbyReads := testutil.ToFloat64(standbyReadCounter.WithLabelValues("ReadUserTuple"))
+
+		primary.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, unavailable).Times(2)
+		standby.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(&openfgav1.Tuple{Key: tk}, nil).Times(2)
+
+		// a single failure of the primary is returned
+		_, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, syscall.ECONNREFUSED)
+		require.InDelta(t, 0, testutil.ToFloat64(datastoreDegradedGauge), 0)
+
+		// the failure that makes the primary unavailable is served by the standby, and so are the next reads
+		for i := 0; i < 2; i++ {
+			got, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+			require.NoError(t, err)
+			require.Equal(t, tk, got.GetKey())
+		}
+		require.InDelta(t, 1, testutil.ToFloat64(datastoreDegradedGauge), 0)
+		require.True(t, ds.Degraded())
+		require.InDelta(t, standbyReads+2, testutil.ToFloat64(standbyReadCounter.WithLabelValues("ReadUserTuple")), 0)
+		require.Equal(t, 1, logs.FilterMessage("primary datastore unavailable, serving reads from the standby datastore and failing writes").Len())
+
+		// writes fail without calling the primary
+		err = ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk})
+		require.ErrorIs(t, err, storage.ErrDatastoreUnavailable)
+
+		// and so do the reads that request higher consistency
+		_, err = ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{
+			Consistency: storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY},
+		})
+		require.ErrorIs(t, err, storage.ErrDatastoreUnavailable)
+
+		// after the cooldown, the primary is called again
+		*now = now.Add(time.Minute)
+		primary.EXPECT().Write(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Return(nil)
+		require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk}))
+		require.InDelta(t, 0, testutil.ToFloat64(datastoreDegradedGauge), 0)
+		require.False(t, ds.Degraded())
+		require.Equal(t, 1, logs.FilterMessage("primary datastore available again, serving reads from the primary datastore").Len())
+	})
+
+	t.Run("expected_errors_are_not_failures", func(t *testing.T) {
+		ds, primary, _, _, _ := newStandbyDatastore(t)
+
+		primary.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, storage.ErrNotFound).Times(3)
+		primary.EXPECT().Write(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Return(storage.ErrTransactionalWriteFailed).Times(3)
+
+		for i := 0; i < 3; i++ {
+			_, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+			require.ErrorIs(t, err, storage.ErrNotFound)
+			err = ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk})
+			require.ErrorIs(t, err, storage.ErrTransactionalWriteFailed)
+		}
+		require.True(t, ds.primaryAllowed())
+	})
+
+	t.Run("optional_interfaces_are_supported_if_the_primary_supports_them", func(t *testing.T) {
+		ds, _, _, _, _ := newStandbyDatastore(t)
+		_, ok := storage.As[storage.TransactionalBatchWriter](ds)
+		require.False(t, ok)
+
+		standby := memory.New()
+		t.Cleanup(standby.Close)
+		ds = NewStandbyDatastore(memory.New(), standby, logger.NewNoopLogger())
+		t.Cleanup(ds.Close)
+
+		batchWriter, ok := storage.As[storage.TransactionalBatchWriter](ds)
+		require.True(t, ok)
+		require.Same(t, ds, batchWriter)
+		_, ok = storage.As[storage.IndexRebuilder](ds)
+		require.False(t, ok)
+	})
+
+	t.Run("optional_reads_fail_over_and_optional_writes_fail", func(t *testing.T) {
+		settings := &storage.StoreSettings{DefaultUserType: "user"}
+		ds := NewStandbyDatastore(
+			&storeSettingsDatastore{err: unavailable},
+			&storeSettingsDatastore{settings: settings},
+			logger.NewNoopLogger(),
+			WithStandbyCircuitBreaker(1, time.Minute),
+		)
+
+		got, err := ds.ReadStoreSettings(ctx, storeID)
+		require.NoError(t, err)
+		require.Equal(t, settings, got)
+		require.True(t, ds.Degraded())
+
+		err = ds.WriteStoreSettings(ctx, storeID, settings)
+		require.ErrorIs(t, err, storage.ErrDatastoreUnavailable)
+	})
+
+	t.Run("ready_while_the_standby_is_ready", func(t *testing.T) {
+		ds, primary, standby, _, _ := newStandbyDatastore(t)
+
+		primary.EXPECT().IsReady(gomock.Any()).Return(storage.ReadinessStatus{}, unavailable)
+		standby.EXPECT().IsReady(gomock.Any()).Return(storage.ReadinessStatus{IsReady: true}, nil)
+
+		status, err := ds.IsReady(ctx)
+		require.NoError(t, err)
+		require.True(t, status.IsReady)
+		require.Contains(t, status.Message, "serving reads from the standby datastore")
+	})
+}
+
+// storeSettingsDatastore is a datastore that only supports the store settings, which it reads from settings,
+// or fails with err.
+type storeSettingsDatastore struct {
+	storage.OpenFGADatastore
+	settings *storage.StoreSettings
+	err      error
+}
+
+func (s *storeSettingsDatastore) ReadStoreSettings(context.Context, string) (*storage.StoreSettings, error) {
+	return s.settings, s.err
+}
+
+func (s *storeSettingsDatastore) WriteStoreSettings(context.Context, string, *storage.StoreSettings) error {
+	return s.err
+}
@@ -0,0 +1,187 @@
+package storagewrappers
+
+import (
+	"context"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var _ storage.RelationshipTupleReader = (*typeRoutingTupleReader)(nil)
+
+// NewTypeRoutingTupleReader returns a [storage.RelationshipTupleReader] that sends every read to the
+// reader registered for the object type being read, falling back to the default reader for
+// object types that have no reader registered.
+//
+// Each object type is owned by exactly one reader: tuples of an object type that happen to exist in a reader
+// that doesn't own it are never returned. Reads whose object type is not known (e.g. a Read filtered
+// only by user) fan out to every reader and combine the results, except for ReadPage, which can only
+// paginate over a single reader and therefore always uses the default one.
+//
+// Consistency options are forwarded untouched, so each reader provides the consistency guarantees of its
+// own backend; there is no cross-reader snapshot.
+func NewTypeRoutingTupleReader(
+	defaultReader storage.RelationshipTupleReader,
+	readersByObjectType map[string]storage.RelationshipTupleReader,
+) storage.RelationshipTupleReader {
+	if len(readersByObjectType) == 0 {
+		return defaultReader
+	}
+
+	return &typeRoutingTupleReader{
+		RelationshipTupleReader: defaultReader,
+		readersByObjectType:     readersByObjectType,
+	}
+}
+
+type typeRoutingTupleReader struct {
+	// RelationshipTupleReader is the default reader.
+	storage.RelationshipTupleReader
+	readersByObjectType map[string]storage.RelationshipTupleReader
+}
+
+func (t *typeRoutingTupleReader) readerFor(objectType string) storage.RelationshipTupleReader {
+	if reader, ok := t.readersByObjectType[objectType]; ok {
+		return reader
+	}
+	return t.RelationshipTupleReader
+}
+
+// Read see [storage.RelationshipTupleReader.Read].
+func (t *typeRoutingTupleReader) Read(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadOptions,
+) (storage.TupleIterator, error) {
+	if tupleKey.GetObject() != "" {
+		objectType, _ := tuple.SplitObject(tupleKey.GetObject())
+		return t.readerFor(objectType).Read(ctx, store, tupleKey, options)
+	}
+
+	iter, err := t.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+	if err != nil {
+		return nil, err
+	}
+	iters := []storage.TupleIterator{t.withoutRoutedTypes(iter)}
+
+	for objectType, reader := range t.readersByObjectType {
+		routedIter, err := reader.Read(ctx, store, tupleKey, options)
+		if err != nil {
+			for _, it := range iters {
+				it.Stop()
+			}
+			return nil, err
+		}
+		iters = append(iters, withObjectType(routedIter, objectType))
+	}
+
+	return storage.NewCombinedIterator(iters...), nil
+}
+
+// ReadPage see [storage.RelationshipTupleReader.ReadPage].
+func (t *typeRoutingTupleReader) ReadPage(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadPageOptions,
+) ([]*openfgav1.Tuple, []byte, error) {
+	objectType, _ := tuple.SplitObject(tupleKey.GetObject())
+	return t.readerFor(objectType).ReadPage(ctx, store, tupleKey, options)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader.ReadUserTuple].
+func (t *typeRoutingTupleReader) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	objectType, _ := tuple.SplitObject(tupleKey.GetObject())
+	return t.readerFor(objectType).ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader.ReadUsersetTuples].
+func (t *typeRoutingTupleReader) ReadUsersetTuples(
+	ctx context.Context,
+	store string,
+	filter storage.ReadUsersetTuplesFilter,
+	options storage.ReadUsersetTuplesOptions,
+) (storage.TupleIterator, error) {
+	objectType, _ := tuple.SplitObject(filter.Object)
+	return t.readerFor(objectType).ReadUsersetTuples(ctx, store, filter, options)
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader.ReadStartingWithUser].
+func (t *typeRoutingTupleReader) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	return t.readerFor(filter.ObjectType).ReadStartingWithUser(ctx, store, filter, options)
+}
+
+// withoutRoutedTypes drops the tuples of the default reader whose object type is owned by another reader.
+func (t *typeRoutingTupleReader) withoutRoutedTypes(iter storage.TupleIterator) storage.TupleIterator {
+	return &objectTypeFilteredTupleIterator{iter: iter, keep: func(objectType string) bool {
+		_, routed := t.readersByObjectType[objectType]
+		return !routed
+	}}
+}
+
+// withObjectType drops the tuples of a routed reader whose object type is not the one it is registered for, since
+// the reader only owns that type. A reader registered for several types is read once per type.
+func withObjectType(iter storage.TupleIterator, objectType string) storage.TupleIterator {
+	return &objectTypeFilteredTupleIterator{iter: iter, keep: func(t string) bool {
+		return t == objectType
+	}}
+}
+
+type objectTypeFilteredTupleIterator struct {
+	iter storage.TupleIterator
+	keep func(objectType string) bool
+}
+
+var _ storage.TupleIterator = (*objectTypeFilteredTupleIterator)(nil)
+
+func (o *objectTypeFilteredTupleIterator) kept(t *openfgav1.Tuple) bool {
+	objectType, _ := tuple.SplitObject(t.GetKey().GetObject())
+	return o.keep(objectType)
+}
+
+// Next see [storage.Iterator.Next].
+func (o *objectTypeFilteredTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	for {
+		t, err := o.iter.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if o.kept(t) {
+			return t, nil
+		}
+	}
+}
+
+// Head see [storage.Iterator.Head].
+func (o *objectTypeFilteredTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	for {
+		t, err := o.iter.Head(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if o.kept(t) {
+			return t, nil
+		}
+		if _, err := o.iter.Next(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Stop see [storage.Iterator.Stop].
+func (o *objectTypeFilteredTupleIterator) Stop() {
+	o.iter.Stop()
+}
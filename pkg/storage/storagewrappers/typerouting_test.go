@@ -0,0 +1,63 @@
+package storagewrappers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestTypeRoutingTupleReader(t *testing.T) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	defaultDatastore := memory.New()
+	t.Cleanup(defaultDatastore.Close)
+	groupDatastore := memory.New()
+	t.Cleanup(groupDatastore.Close)
+
+	// each datastore also has a stale tuple of a type it doesn't own
+	require.NoError(t, defaultDatastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("group:stale", "member", "user:anne"),
+	}))
+	require.NoError(t, groupDatastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("document:stale", "viewer", "user:anne"),
+	}))
+
+	reader := NewTypeRoutingTupleReader(defaultDatastore, map[string]storage.RelationshipTupleReader{
+		"group": groupDatastore,
+	})
+
+	readObjects := func(t *testing.T, tk *openfgav1.TupleKey) []string {
+		iter, err := reader.Read(ctx, storeID, tk, storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		var objects []string
+		for {
+			tup, err := iter.Next(ctx)
+			if err != nil {
+				require.ErrorIs(t, err, storage.ErrIteratorDone)
+				return objects
+			}
+			objects = append(objects, tup.GetKey().GetObject())
+		}
+	}
+
+	t.Run("reads_of_an_object_type_are_routed_to_its_reader", func(t *testing.T) {
+		require.Equal(t, []string{"group:eng"}, readObjects(t, &openfgav1.TupleKey{Object: "group:", Relation: "member"}))
+		require.Equal(t, []string{"document:1"}, readObjects(t, &openfgav1.TupleKey{Object: "document:", Relation: "viewer"}))
+	})
+
+	t.Run("fan_out_reads_only_return_the_types_each_reader_owns", func(t *testing.T) {
+		require.ElementsMatch(t, []string{"document:1", "group:eng"}, readObjects(t, &openfgav1.TupleKey{User: "user:anne"}))
+	})
+}
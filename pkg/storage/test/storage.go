@@ -37,6 +37,8 @@ func RunAllTests(t *testing.T, ds storage.OpenFGADatastore) {
 	t.Run("TestReadChanges", func(t *testing.T) { ReadChangesTest(t, ds) })
 	t.Run("TestReadStartingWithUser", func(t *testing.T) { ReadStartingWithUserTest(t, ds) })
 	t.Run("TestReadAndReadPages", func(t *testing.T) { ReadAndReadPageTest(t, ds) })
+	t.Run("TestTupleBatchWriting", func(t *testing.T) { TupleBatchWritingTest(t, ds) })
+	t.Run("TestIdempotentWrite", func(t *testing.T) { IdempotentWriteTest(t, ds) })
 
 	// Authorization models.
 	t.Run("TestWriteAndReadAuthorizationModel", func(t *testing.T) { WriteAndReadAuthorizationModelTest(t, ds) })
@@ -48,6 +50,8 @@ func RunAllTests(t *testing.T, ds storage.OpenFGADatastore) {
 
 	// Stores.
 	t.Run("TestStore", func(t *testing.T) { StoreTest(t, ds) })
+	t.Run("TestStoreSettings", func(t *testing.T) { StoreSettingsTest(t, ds) })
+	t.Run("TestUniqueStoreNames", func(t *testing.T) { UniqueStoreNamesTest(t, ds) })
 }
 
 // BootstrapFGAStore is a utility to write an FGA model and relationship tuples to a datastore.
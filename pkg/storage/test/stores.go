@@ -69,6 +69,11 @@ func StoreTest(t *testing.T, datastore storage.OpenFGADatastore) {
 	t.Run("get_non-existent_store_returns_not_found", func(t *testing.T) {
 		_, err := datastore.GetStore(ctx, "foo")
 		require.ErrorIs(t, err, storage.ErrNotFound)
+		require.ErrorIs(t, err, storage.ErrStoreNotFound)
+
+		var storeNotFoundErr *storage.StoreNotFoundError
+		require.ErrorAs(t, err, &storeNotFoundErr)
+		require.Equal(t, "foo", storeNotFoundErr.StoreID)
 	})
 
 	t.Run("delete_store_succeeds", func(t *testing.T) {
@@ -98,3 +103,120 @@ func StoreTest(t *testing.T, datastore storage.OpenFGADatastore) {
 		}
 	})
 }
+
+func StoreSettingsTest(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	backend, ok := datastore.(storage.StoreSettingsBackend)
+	require.True(t, ok, "the datastore must implement storage.StoreSettingsBackend")
+
+	store, err := datastore.CreateStore(ctx, &openfgav1.Store{
+		Id:   ulid.Make().String(),
+		Name: testutils.CreateRandomString(10),
+	})
+	require.NoError(t, err)
+
+	t.Run("store_without_settings", func(t *testing.T) {
+		settings, err := backend.ReadStoreSettings(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Empty(t, settings.AllowedObjectTypes)
+	})
+
+	t.Run("write_and_read_settings", func(t *testing.T) {
+		err := backend.WriteStoreSettings(ctx, store.GetId(), &storage.StoreSettings{
+			AllowedObjectTypes:     []string{"user", "document"},
+			DefaultPageSize:        7,
+			RelationAliases:        map[string]string{"document#reader": "viewer"},
+			DefaultUserType:        "user",
+			MaxAuthorizationModels: 3,
+		})
+		require.NoError(t, err)
+
+		settings, err := backend.ReadStoreSettings(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Equal(t, []string{"user", "document"}, settings.AllowedObjectTypes)
+		require.Equal(t, int32(7), settings.DefaultPageSize)
+		require.Equal(t, map[string]string{"document#reader": "viewer"}, settings.RelationAliases)
+		require.Equal(t, "user", settings.DefaultUserType)
+		require.Equal(t, int32(3), settings.MaxAuthorizationModels)
+
+		err = backend.WriteStoreSettings(ctx, store.GetId(), &storage.StoreSettings{})
+		require.NoError(t, err)
+
+		settings, err = backend.ReadStoreSettings(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Empty(t, settings.AllowedObjectTypes)
+		require.Zero(t, settings.DefaultPageSize)
+		require.Empty(t, settings.RelationAliases)
+		require.Empty(t, settings.DefaultUserType)
+		require.Zero(t, settings.MaxAuthorizationModels)
+	})
+
+	t.Run("unknown_store", func(t *testing.T) {
+		id := ulid.Make().String()
+		err := backend.WriteStoreSettings(ctx, id, &storage.StoreSettings{AllowedObjectTypes: []string{"user"}})
+		var storeNotFoundErr *storage.StoreNotFoundError
+		require.ErrorAs(t, err, &storeNotFoundErr)
+		require.Equal(t, id, storeNotFoundErr.StoreID)
+
+		settings, err := backend.ReadStoreSettings(ctx, id)
+		require.NoError(t, err)
+		require.Empty(t, settings.AllowedObjectTypes)
+	})
+}
+
+func UniqueStoreNamesTest(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	creator, ok := datastore.(storage.UniqueStoreNameCreator)
+	require.True(t, ok, "the datastore must implement storage.UniqueStoreNameCreator")
+
+	name := testutils.CreateRandomString(10)
+	store, err := creator.CreateStoreWithUniqueName(ctx, &openfgav1.Store{
+		Id:   ulid.Make().String(),
+		Name: name,
+	})
+	require.NoError(t, err)
+	require.Equal(t, name, store.GetName())
+
+	got, err := datastore.GetStore(ctx, store.GetId())
+	require.NoError(t, err)
+	require.Equal(t, name, got.GetName())
+
+	t.Run("name_of_existing_store_conflicts", func(t *testing.T) {
+		_, err := creator.CreateStoreWithUniqueName(ctx, &openfgav1.Store{
+			Id:   ulid.Make().String(),
+			Name: name,
+		})
+		require.ErrorIs(t, err, storage.ErrStoreNameConflict)
+
+		var conflictErr *storage.StoreNameConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		require.Equal(t, name, conflictErr.Name)
+	})
+
+	t.Run("name_of_store_created_without_uniqueness_conflicts", func(t *testing.T) {
+		otherName := testutils.CreateRandomString(10)
+		_, err := datastore.CreateStore(ctx, &openfgav1.Store{
+			Id:   ulid.Make().String(),
+			Name: otherName,
+		})
+		require.NoError(t, err)
+
+		_, err = creator.CreateStoreWithUniqueName(ctx, &openfgav1.Store{
+			Id:   ulid.Make().String(),
+			Name: otherName,
+		})
+		require.ErrorIs(t, err, storage.ErrStoreNameConflict)
+	})
+
+	t.Run("name_of_deleted_store_can_be_reused", func(t *testing.T) {
+		require.NoError(t, datastore.DeleteStore(ctx, store.GetId()))
+
+		_, err := creator.CreateStoreWithUniqueName(ctx, &openfgav1.Store{
+			Id:   ulid.Make().String(),
+			Name: name,
+		})
+		require.NoError(t, err)
+	})
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -13,11 +14,13 @@ import (
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/protoadapt"
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
 	"github.com/openfga/openfga/pkg/testutils"
 	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
@@ -100,6 +103,35 @@ func ReadChangesTest(t *testing.T, datastore storage.OpenFGADatastore) {
 		})
 	})
 
+	t.Run("read_changes_sorted_desc", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		for i := 0; i < 5; i++ {
+			err := datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+				tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:jon"),
+			})
+			require.NoError(t, err)
+		}
+
+		var objects []string
+		token := ""
+		for {
+			changes, next, err := datastore.ReadChanges(ctx, storeID, "", storage.ReadChangesOptions{
+				Pagination: storage.NewPaginationOptions(2, token),
+				SortDesc:   true,
+			}, 0)
+			if errors.Is(err, storage.ErrNotFound) {
+				break
+			}
+			require.NoError(t, err)
+			for _, change := range changes {
+				objects = append(objects, change.GetTupleKey().GetObject())
+			}
+			token = string(next)
+		}
+		require.Equal(t, []string{"document:4", "document:3", "document:2", "document:1", "document:0"}, objects)
+	})
+
 	t.Run("read_changes_returns_non_empty_timestamp", func(t *testing.T) {
 		storeID := ulid.Make().String()
 
@@ -396,9 +428,9 @@ func ReadChangesTest(t *testing.T, datastore storage.OpenFGADatastore) {
 				Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
 			},
 			{
-				// Tuples with a condition that are deleted don't include the condition info
-				// in the changelog entry.
-				TupleKey:  tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+				// Tuples with a condition that are deleted include the condition info they
+				// had in the changelog entry.
+				TupleKey:  tk1,
 				Operation: openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
 			},
 		}
@@ -1497,8 +1529,354 @@ func ReadAndReadPageTest(t *testing.T, datastore storage.OpenFGADatastore) {
 	}
 }
 
+func TupleBatchWritingTest(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	batchWriter, ok := datastore.(storage.TransactionalBatchWriter)
+	require.True(t, ok, "the datastore must implement storage.TransactionalBatchWriter")
+
+	// more tuples than can be inserted in the changelog with a single statement of a datastore with a parameter
+	// limit of 65535, e.g. MySQL and Postgres
+	const numTuples = 7000
+
+	batchesOf := func(tks []*openfgav1.TupleKey) []storage.TupleBatch {
+		var batches []storage.TupleBatch
+		for start := 0; start < len(tks); start += datastore.MaxTuplesPerWrite() {
+			end := min(start+datastore.MaxTuplesPerWrite(), len(tks))
+			batches = append(batches, storage.TupleBatch{Writes: tks[start:end]})
+		}
+		return batches
+	}
+
+	tupleKeys := make([]*openfgav1.TupleKey, 0, numTuples)
+	for i := 0; i < numTuples; i++ {
+		tupleKeys = append(tupleKeys, tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:jon"))
+	}
+
+	t.Run("writes_all_batches", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		err := batchWriter.WriteBatches(ctx, storeID, batchesOf(tupleKeys))
+		require.NoError(t, err)
+
+		tuples := readWithPageSize(t, datastore, storeID, 1000, nil)
+		require.Len(t, tuples, numTuples)
+
+		changes := readChangesWithPageSize(t, datastore, storeID, 1000, "")
+		require.Len(t, changes, numTuples)
+	})
+
+	t.Run("writes_nothing_if_the_last_batch_fails", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		batches := batchesOf(tupleKeys)
+		batches = append(batches, storage.TupleBatch{
+			Deletes: []*openfgav1.TupleKeyWithoutCondition{
+				tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:missing", "viewer", "user:jon")),
+			},
+		})
+
+		err := batchWriter.WriteBatches(ctx, storeID, batches)
+		require.ErrorIs(t, err, storage.ErrInvalidWriteInput)
+
+		tuples, _, err := datastore.ReadPage(ctx, storeID, nil, storage.ReadPageOptions{
+			Pagination: storage.NewPaginationOptions(1, ""),
+		})
+		require.NoError(t, err)
+		require.Empty(t, tuples)
+
+		_, _, err = datastore.ReadChanges(ctx, storeID, "", storage.ReadChangesOptions{}, 0)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("later_batches_see_earlier_batches", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		tk := tuple.NewTupleKey("document:1", "viewer", "user:jon")
+
+		err := batchWriter.WriteBatches(ctx, storeID, []storage.TupleBatch{
+			{Writes: []*openfgav1.TupleKey{tk}},
+			{Deletes: []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}},
+		})
+		require.NoError(t, err)
+
+		_, err = datastore.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("rejects_batches_larger_than_max_tuples_per_write", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		err := batchWriter.WriteBatches(ctx, storeID, []storage.TupleBatch{
+			{Writes: tupleKeys[:datastore.MaxTuplesPerWrite()+1]},
+		})
+		require.ErrorIs(t, err, storage.ErrExceededWriteBatchLimit)
+	})
+}
+
+func IdempotentWriteTest(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+
+	idempotentWriter, ok := datastore.(storage.IdempotentWriter)
+	require.True(t, ok, "the datastore must implement storage.IdempotentWriter")
+
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:jon")
+	recordOf := func(key string, ttl time.Duration) storage.IdempotencyRecord {
+		return storage.IdempotencyRecord{
+			Key:         key,
+			RequestHash: "hash-of-" + key,
+			ExpiresAt:   time.Now().Add(ttl).UTC().Truncate(time.Second),
+		}
+	}
+
+	t.Run("retry_with_the_same_key_writes_nothing_and_returns_the_record", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		record := recordOf("key", time.Hour)
+
+		err := idempotentWriter.WriteBatchesIdempotently(ctx, storeID, record, []storage.TupleBatch{{Writes: []*openfgav1.TupleKey{tk}}})
+		require.NoError(t, err)
+
+		// applying the write again would fail because the tuple exists
+		err = idempotentWriter.WriteBatchesIdempotently(ctx, storeID, record, []storage.TupleBatch{{Writes: []*openfgav1.TupleKey{tk}}})
+		require.ErrorIs(t, err, storage.ErrIdempotencyKeyExists)
+
+		var keyExistsErr *storage.IdempotencyKeyExistsError
+		require.ErrorAs(t, err, &keyExistsErr)
+		require.Equal(t, record.Key, keyExistsErr.Record.Key)
+		require.Equal(t, record.RequestHash, keyExistsErr.Record.RequestHash)
+		require.True(t, record.ExpiresAt.Equal(keyExistsErr.Record.ExpiresAt))
+
+		changes := readChangesWithPageSize(t, datastore, storeID, 10, "")
+		require.Len(t, changes, 1)
+	})
+
+	t.Run("keys_are_scoped_to_the_store", func(t *testing.T) {
+		record := recordOf("key", time.Hour)
+
+		for _, storeID := range []string{ulid.Make().String(), ulid.Make().String()} {
+			err := idempotentWriter.WriteBatchesIdempotently(ctx, storeID, record, []storage.TupleBatch{{Writes: []*openfgav1.TupleKey{tk}}})
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("expired_key_can_be_reused", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		err := idempotentWriter.WriteBatchesIdempotently(ctx, storeID, recordOf("key", -time.Hour), []storage.TupleBatch{{Writes: []*openfgav1.TupleKey{tk}}})
+		require.NoError(t, err)
+
+		err = idempotentWriter.WriteBatchesIdempotently(ctx, storeID, recordOf("key", time.Hour), []storage.TupleBatch{
+			{Deletes: []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}},
+		})
+		require.NoError(t, err)
+
+		_, err = datastore.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("key_of_failed_write_is_not_recorded", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		record := recordOf("key", time.Hour)
+
+		err := idempotentWriter.WriteBatchesIdempotently(ctx, storeID, record, []storage.TupleBatch{
+			{Deletes: []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}},
+		})
+		require.ErrorIs(t, err, storage.ErrInvalidWriteInput)
+
+		err = idempotentWriter.WriteBatchesIdempotently(ctx, storeID, record, []storage.TupleBatch{{Writes: []*openfgav1.TupleKey{tk}}})
+		require.NoError(t, err)
+	})
+}
+
 // getObjects returns all the objects from an iterator.
 // If the iterator throws an error, it fails the test.
+// ChangelogBatchingTest tests a datastore that groups the concurrent writes into transactions. newDatastore
+// returns a datastore grouping the writes made within maxDelay, up to maxWrites writes per group.
+func ChangelogBatchingTest(t *testing.T, newDatastore func(t *testing.T, maxDelay time.Duration, maxWrites int) storage.OpenFGADatastore) {
+	t.Run("all_tests", func(t *testing.T) {
+		ds := newDatastore(t, time.Millisecond, 10)
+		defer ds.Close()
+		RunAllTests(t, ds)
+	})
+
+	t.Run("changelog_is_complete", func(t *testing.T) {
+		ds := newDatastore(t, 50*time.Millisecond, 10)
+		defer ds.Close()
+
+		ctx := context.Background()
+		store := ulid.Make().String()
+
+		// every other write deletes a tuple that doesn't exist, so it fails within the group of the others
+		const numWrites = 50
+		errs := make([]error, numWrites)
+		var wg sync.WaitGroup
+		for i := 0; i < numWrites; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tk := tuple.NewTupleKey(fmt.Sprintf("doc:%d", i), "viewer", "user:anne")
+				if i%2 == 0 {
+					errs[i] = ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tk})
+				} else {
+					errs[i] = ds.Write(ctx, store, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}, nil)
+				}
+			}()
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if i%2 == 0 {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, storage.ErrInvalidWriteInput)
+			}
+		}
+
+		changes, _, err := ds.ReadChanges(ctx, store, "", storage.ReadChangesOptions{
+			Pagination: storage.NewPaginationOptions(numWrites, ""),
+		}, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, numWrites/2)
+
+		written := map[string]bool{}
+		for _, change := range changes {
+			require.Equal(t, openfgav1.TupleOperation_TUPLE_OPERATION_WRITE, change.GetOperation())
+			written[change.GetTupleKey().GetObject()] = true
+
+			_, err := ds.ReadUserTuple(ctx, store, change.GetTupleKey(), storage.ReadUserTupleOptions{})
+			require.NoError(t, err)
+		}
+		require.Len(t, written, numWrites/2)
+	})
+
+	t.Run("writes_after_close_fail", func(t *testing.T) {
+		ds := newDatastore(t, time.Second, 10)
+		ds.Close()
+
+		err := ds.Write(context.Background(), ulid.Make().String(), nil, []*openfgav1.TupleKey{tuple.NewTupleKey("doc:1", "viewer", "user:anne")})
+		require.Error(t, err)
+	})
+}
+
+// ConditionContextEncryptionTest tests the encryption of the condition contexts at rest. newDatastore must return a
+// new datastore, on the same database every time, that encrypts the condition contexts with the cipher, if any.
+func ConditionContextEncryptionTest(t *testing.T, newDatastore func(t *testing.T, cipher sqlcommon.ConditionContextCipher) storage.OpenFGADatastore) {
+	ctx := context.Background()
+	store := ulid.Make().String()
+	otherStore := ulid.Make().String()
+
+	newCipher := func(t *testing.T, encrypted func(string) bool, currentKeyID string, keyIDs ...string) sqlcommon.ConditionContextCipher {
+		keys := map[string][]byte{}
+		for _, id := range keyIDs {
+			// the same ID is always the same key
+			keys[id] = bytes.Repeat([]byte(id), 32)[:32]
+		}
+		provider, err := sqlcommon.NewStaticConditionContextKeyProvider(currentKeyID, keys)
+		require.NoError(t, err)
+		return sqlcommon.NewEnvelopeConditionContextCipher(provider, encrypted)
+	}
+	onlyStore := func(s string) bool { return s == store }
+	allStores := func(string) bool { return true }
+	noStores := func(string) bool { return false }
+
+	conditional := tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", "inRegion",
+		testutils.MustNewStruct(t, map[string]interface{}{"cidr": "192.168.0.0/24"}))
+	unconditional := tuple.NewTupleKey("document:1", "viewer", "user:bob")
+
+	requireDecrypted := func(t *testing.T, ds storage.OpenFGADatastore, store string) {
+		got, err := ds.ReadUserTuple(ctx, store, tuple.NewTupleKey("document:1", "viewer", "user:anne"), storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+		require.True(t, proto.Equal(conditional, got.GetKey()))
+
+		iter, err := ds.Read(ctx, store, tuple.NewTupleKey("document:1", "viewer", ""), storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+		if diff := cmp.Diff([]*openfgav1.TupleKey{conditional, unconditional}, iterateThroughAllTuples(t, iter), cmpSortTupleKeys...); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+
+		changes, _, err := ds.ReadChanges(ctx, store, "", storage.ReadChangesOptions{}, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		require.True(t, proto.Equal(conditional, changes[0].GetTupleKey()))
+	}
+
+	requireEncrypted := func(t *testing.T, store string) {
+		withoutCipher := newDatastore(t, nil)
+		defer withoutCipher.Close()
+
+		_, err := withoutCipher.ReadUserTuple(ctx, store, tuple.NewTupleKey("document:1", "viewer", "user:anne"), storage.ReadUserTupleOptions{})
+		require.ErrorContains(t, err, "the condition context is encrypted")
+	}
+
+	requireNotEncrypted := func(t *testing.T, store string) {
+		withoutCipher := newDatastore(t, nil)
+		defer withoutCipher.Close()
+
+		requireDecrypted(t, withoutCipher, store)
+	}
+
+	rotate := func(t *testing.T, ds storage.OpenFGADatastore, store string) int {
+		rotator, ok := ds.(storage.ConditionContextKeyRotator)
+		require.True(t, ok)
+
+		rotated, err := rotator.RotateConditionContextKeys(ctx, store)
+		require.NoError(t, err)
+		return rotated
+	}
+
+	t.Run("all_tests", func(t *testing.T) {
+		ds := newDatastore(t, newCipher(t, allStores, "key1", "key1"))
+		defer ds.Close()
+		RunAllTests(t, ds)
+	})
+
+	ds := newDatastore(t, newCipher(t, onlyStore, "key1", "key1"))
+	defer ds.Close()
+	require.NoError(t, ds.Write(ctx, store, nil, []*openfgav1.TupleKey{conditional, unconditional}))
+	require.NoError(t, ds.Write(ctx, otherStore, nil, []*openfgav1.TupleKey{conditional, unconditional}))
+
+	t.Run("condition_contexts_are_encrypted_at_rest", func(t *testing.T) {
+		requireDecrypted(t, ds, store)
+		requireEncrypted(t, store)
+	})
+
+	t.Run("condition_contexts_of_other_stores_are_not_encrypted", func(t *testing.T) {
+		requireDecrypted(t, ds, otherStore)
+		requireNotEncrypted(t, otherStore)
+	})
+
+	t.Run("keys_are_rotated", func(t *testing.T) {
+		rotated := newDatastore(t, newCipher(t, onlyStore, "key2", "key1", "key2"))
+		defer rotated.Close()
+
+		// the tuple and its change
+		require.Equal(t, 2, rotate(t, rotated, store))
+
+		// the previous key is no longer needed
+		withoutPreviousKey := newDatastore(t, newCipher(t, onlyStore, "key2", "key2"))
+		defer withoutPreviousKey.Close()
+		requireDecrypted(t, withoutPreviousKey, store)
+		require.Zero(t, rotate(t, withoutPreviousKey, store))
+	})
+
+	t.Run("existing_condition_contexts_are_encrypted_by_rotating_keys", func(t *testing.T) {
+		nowEncrypted := newDatastore(t, newCipher(t, allStores, "key2", "key2"))
+		defer nowEncrypted.Close()
+
+		require.Equal(t, 2, rotate(t, nowEncrypted, otherStore))
+		requireDecrypted(t, nowEncrypted, otherStore)
+		requireEncrypted(t, otherStore)
+	})
+
+	t.Run("condition_contexts_are_decrypted_by_rotating_keys", func(t *testing.T) {
+		noLongerEncrypted := newDatastore(t, newCipher(t, noStores, "key2", "key2"))
+		defer noLongerEncrypted.Close()
+
+		require.Equal(t, 2, rotate(t, noLongerEncrypted, store))
+		requireNotEncrypted(t, store)
+	})
+}
+
 func getObjects(t *testing.T, tupleIterator storage.TupleIterator) []string {
 	var objects []string
 	for {
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	// ulidEntropyMu guards ulidEntropy, the entropy source set with SetULIDEntropy, if any.
+	ulidEntropyMu  sync.Mutex
+	ulidEntropy    io.Reader
+	ulidEntropySet atomic.Bool
+
+	// ulidEntropyPool has the monotonic entropy sources used by default, so that the ULIDs generated
+	// concurrently don't contend on a single source.
+	ulidEntropyPool = sync.Pool{
+		New: func() any {
+			var seed [8]byte
+			if _, err := cryptorand.Read(seed[:]); err != nil {
+				binary.LittleEndian.PutUint64(seed[:], uint64(time.Now().UnixNano()))
+			}
+			return ulid.Monotonic(rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:])))), 0)
+		},
+	}
+)
+
+// ULIDGenerator generates ULIDs from a single monotonic entropy source, so that the ULIDs it generates for the
+// same time increase in the order they are generated, e.g. the IDs of the changelog entries of a write.
+// It isn't safe for concurrent use.
+type ULIDGenerator struct {
+	// entropy is nil if an entropy source was set with SetULIDEntropy.
+	entropy *ulid.MonotonicEntropy
+}
+
+// NewULIDGenerator returns a generator drawing from a pooled entropy source, or from the one set with
+// SetULIDEntropy. Release must be called once the generator is no longer used.
+func NewULIDGenerator() *ULIDGenerator {
+	if ulidEntropySet.Load() {
+		return &ULIDGenerator{}
+	}
+	return &ULIDGenerator{entropy: ulidEntropyPool.Get().(*ulid.MonotonicEntropy)}
+}
+
+// New returns a new ULID for the given time.
+func (g *ULIDGenerator) New(t time.Time) ulid.ULID {
+	if g.entropy == nil {
+		ulidEntropyMu.Lock()
+		defer ulidEntropyMu.Unlock()
+
+		return ulid.MustNew(ulid.Timestamp(t), ulidEntropy)
+	}
+	return ulid.MustNew(ulid.Timestamp(t), g.entropy)
+}
+
+// Release returns the entropy source of the generator to the pool. The generator must not be used afterward.
+func (g *ULIDGenerator) Release() {
+	if g.entropy != nil {
+		ulidEntropyPool.Put(g.entropy)
+		g.entropy = nil
+	}
+}
+
+// NewULID returns a new ULID for the given time. It is used to generate store IDs, authorization model IDs
+// and the IDs of tuples and changelog entries; use a ULIDGenerator to generate several IDs that must be ordered.
+// It is safe for concurrent use.
+func NewULID(t time.Time) ulid.ULID {
+	g := NewULIDGenerator()
+	defer g.Release()
+
+	return g.New(t)
+}
+
+// SetULIDEntropy replaces the entropy source used by NewULID and the generators, e.g. with a seeded
+// [ulid.Monotonic] reader so that tests get predictable IDs. It returns a function that restores
+// the previous source. By default, a pool of [ulid.Monotonic] readers is used.
+func SetULIDEntropy(entropy io.Reader) (restore func()) {
+	ulidEntropyMu.Lock()
+	defer ulidEntropyMu.Unlock()
+
+	previous := ulidEntropy
+	ulidEntropy = entropy
+	ulidEntropySet.Store(entropy != nil)
+
+	return func() {
+		ulidEntropyMu.Lock()
+		defer ulidEntropyMu.Unlock()
+		ulidEntropy = previous
+		ulidEntropySet.Store(previous != nil)
+	}
+}
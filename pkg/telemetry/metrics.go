@@ -0,0 +1,15 @@
+package telemetry
+
+// MetricsSink receives the metrics that the server emits, so that they can be forwarded to a metrics
+// system other than Prometheus. The tags of a metric are its labels, e.g. 'grpc_method'. The metrics that
+// the other packages register with Prometheus directly are not emitted to it.
+type MetricsSink interface {
+	// IncCounter adds value to the counter.
+	IncCounter(name string, value float64, tags map[string]string)
+
+	// ObserveHistogram adds the observation of value to the histogram.
+	ObserveHistogram(name string, value float64, tags map[string]string)
+
+	// SetGauge sets the gauge to value.
+	SetGauge(name string, value float64, tags map[string]string)
+}
@@ -2,11 +2,13 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,19 +27,59 @@ import (
 
 const (
 	mySQLImage = "mysql:8"
+
+	defaultMySQLDatabase = "defaultdb"
+)
+
+var (
+	sharedMySQLContainersMu sync.Mutex
+	// sharedMySQLContainers are the containers started with WithReuse, by image and migrations directory.
+	sharedMySQLContainers = map[string]*sharedMySQLContainer{}
 )
 
+type sharedMySQLContainer struct {
+	mu        sync.Mutex
+	container *mySQLTestContainer
+	stop      func()
+}
+
 type mySQLTestContainer struct {
 	addr     string
 	version  int64
 	username string
 	password string
+	image    string
+	database string
+	reuse    bool
+}
+
+// MySQLTestContainerOption configures the MySQL test container.
+type MySQLTestContainerOption func(*mySQLTestContainer)
+
+// WithImage sets the image of the container, e.g. 'mysql:8.0.35' or a MariaDB image, instead of 'mysql:8'.
+func WithImage(image string) MySQLTestContainerOption {
+	return func(m *mySQLTestContainer) {
+		m.image = image
+	}
+}
+
+// WithReuse makes the tests that run the same image share a single container, which is started by the
+// first of them and stopped by [StopSharedMySQLTestContainers]. Each test gets a new database of it, so
+// that the tests are isolated.
+func WithReuse() MySQLTestContainerOption {
+	return func(m *mySQLTestContainer) {
+		m.reuse = true
+	}
 }
 
 // NewMySQLTestContainer returns an implementation of the DatastoreTestContainer interface
 // for MySQL.
-func NewMySQLTestContainer() *mySQLTestContainer {
-	return &mySQLTestContainer{}
+func NewMySQLTestContainer(opts ...MySQLTestContainerOption) *mySQLTestContainer {
+	m := &mySQLTestContainer{image: mySQLImage, database: defaultMySQLDatabase}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 func (m *mySQLTestContainer) GetDatabaseSchemaVersion() int64 {
@@ -47,16 +89,165 @@ func (m *mySQLTestContainer) GetDatabaseSchemaVersion() int64 {
 // RunMySQLTestContainer runs a MySQL container, connects to it, and returns a
 // bootstrapped implementation of the DatastoreTestContainer interface wired up for the
 // MySQL datastore engine.
+// With [WithReuse], the container is shared with the other tests and the returned
+// DatastoreTestContainer connects to a new database of it, with the migrations applied.
 func (m *mySQLTestContainer) RunMySQLTestContainer(t testing.TB) DatastoreTestContainer {
+	if m.reuse {
+		return m.runSharedMySQLTestContainer(t)
+	}
+
+	dockerClient := newDockerClient(t)
+	t.Cleanup(func() {
+		dockerClient.Close()
+	})
+
+	mySQLTestContainer, containerID, name := m.startMySQLContainer(t, dockerClient)
+	t.Cleanup(func() {
+		stopMySQLContainer(dockerClient, containerID, name, t.Logf)
+	})
+
+	uri := mySQLTestContainer.GetConnectionURI(true)
+
+	err := mysql.SetLogger(log.New(io.Discard, "", 0))
+	require.NoError(t, err)
+
+	goose.SetLogger(goose.NopLogger())
+
+	db, err := goose.OpenDBWithDriver("mysql", uri)
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = pingWithBackoff(db)
+	require.NoError(t, err, "failed to connect to mysql container")
+
+	goose.SetBaseFS(assets.EmbedMigrations)
+
+	err = goose.Up(db, assets.MySQLMigrationDir)
+	require.NoError(t, err)
+	version, err := goose.GetDBVersion(db)
+	require.NoError(t, err)
+	mySQLTestContainer.version = version
+
+	return mySQLTestContainer
+}
+
+// runSharedMySQLTestContainer returns the container shared by the tests that run the same image,
+// starting it on the first call, with a new database for the test. The database is dropped when the test
+// finishes, but the container is only stopped by [StopSharedMySQLTestContainers].
+func (m *mySQLTestContainer) runSharedMySQLTestContainer(t testing.TB) DatastoreTestContainer {
+	key := m.image + "|" + assets.MySQLMigrationDir
+
+	sharedMySQLContainersMu.Lock()
+	shared, ok := sharedMySQLContainers[key]
+	if !ok {
+		shared = &sharedMySQLContainer{}
+		sharedMySQLContainers[key] = shared
+	}
+	sharedMySQLContainersMu.Unlock()
+
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	if shared.container == nil {
+		err := mysql.SetLogger(log.New(io.Discard, "", 0))
+		require.NoError(t, err)
+
+		goose.SetLogger(goose.NopLogger())
+		goose.SetBaseFS(assets.EmbedMigrations)
+
+		// not closed with the test, since the container outlives it
+		dockerClient := newDockerClient(t)
+		started, containerID, name := m.startMySQLContainer(t, dockerClient)
+
+		db, err := goose.OpenDBWithDriver("mysql", started.GetConnectionURI(true))
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = pingWithBackoff(db)
+		if err != nil {
+			stopMySQLContainer(dockerClient, containerID, name, t.Logf)
+			dockerClient.Close()
+			require.NoError(t, err, "failed to connect to mysql container")
+		}
+
+		shared.container = started
+		shared.stop = func() {
+			stopMySQLContainer(dockerClient, containerID, name, log.Printf)
+			dockerClient.Close()
+		}
+	}
+
+	database := "test_" + strings.ToLower(ulid.Make().String())
+
+	root, err := goose.OpenDBWithDriver("mysql", shared.container.GetConnectionURI(true))
+	require.NoError(t, err)
+	defer root.Close()
+
+	_, err = root.Exec("CREATE DATABASE " + database)
+	require.NoError(t, err)
+
+	mySQLTestContainer := &mySQLTestContainer{
+		addr:     shared.container.addr,
+		username: shared.container.username,
+		password: shared.container.password,
+		image:    shared.container.image,
+		database: database,
+	}
+
+	t.Cleanup(func() {
+		db, err := goose.OpenDBWithDriver("mysql", shared.container.GetConnectionURI(true))
+		if err != nil {
+			t.Logf("failed to drop database %s: %v", database, err)
+			return
+		}
+		defer db.Close()
+
+		if _, err := db.Exec("DROP DATABASE IF EXISTS " + database); err != nil {
+			t.Logf("failed to drop database %s: %v", database, err)
+		}
+	})
+
+	db, err := goose.OpenDBWithDriver("mysql", mySQLTestContainer.GetConnectionURI(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = goose.Up(db, assets.MySQLMigrationDir)
+	require.NoError(t, err)
+	version, err := goose.GetDBVersion(db)
+	require.NoError(t, err)
+	mySQLTestContainer.version = version
+
+	return mySQLTestContainer
+}
+
+// StopSharedMySQLTestContainers stops the containers started with [WithReuse]. It is meant to be called
+// once every test is done, e.g. from TestMain after m.Run().
+func StopSharedMySQLTestContainers() {
+	sharedMySQLContainersMu.Lock()
+	defer sharedMySQLContainersMu.Unlock()
+
+	for key, shared := range sharedMySQLContainers {
+		shared.mu.Lock()
+		if shared.stop != nil {
+			shared.stop()
+		}
+		shared.mu.Unlock()
+		delete(sharedMySQLContainers, key)
+	}
+}
+
+func newDockerClient(t testing.TB) *client.Client {
 	dockerClient, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
 	)
 	require.NoError(t, err)
-	t.Cleanup(func() {
-		dockerClient.Close()
-	})
+	return dockerClient
+}
 
+// startMySQLContainer pulls the image if needed, and creates and starts a container of it with
+// an empty 'defaultdb' database. It returns the container, without its schema version, its ID and its name.
+func (m *mySQLTestContainer) startMySQLContainer(t testing.TB, dockerClient *client.Client) (*mySQLTestContainer, string, string) {
 	allImages, err := dockerClient.ImageList(context.Background(), image.ListOptions{
 		All: true,
 	})
@@ -65,7 +256,7 @@ func (m *mySQLTestContainer) RunMySQLTestContainer(t testing.TB) DatastoreTestCo
 	foundMysqlImage := false
 	for _, image := range allImages {
 		for _, tag := range image.RepoTags {
-			if strings.Contains(tag, mySQLImage) {
+			if tag == m.image {
 				foundMysqlImage = true
 				break
 			}
@@ -73,8 +264,8 @@ func (m *mySQLTestContainer) RunMySQLTestContainer(t testing.TB) DatastoreTestCo
 	}
 
 	if !foundMysqlImage {
-		t.Logf("Pulling image %s", mySQLImage)
-		reader, err := dockerClient.ImagePull(context.Background(), mySQLImage, image.PullOptions{})
+		t.Logf("Pulling image %s", m.image)
+		reader, err := dockerClient.ImagePull(context.Background(), m.image, image.PullOptions{})
 		require.NoError(t, err)
 
 		_, err = io.Copy(io.Discard, reader) // consume the image pull output to make sure it's done
@@ -89,7 +280,7 @@ func (m *mySQLTestContainer) RunMySQLTestContainer(t testing.TB) DatastoreTestCo
 		ExposedPorts: nat.PortSet{
 			nat.Port("3306/tcp"): {},
 		},
-		Image: mySQLImage,
+		Image: m.image,
 		Cmd: []string{
 			"--log-error=/var/lib/mysql/error.log",
 			"--log-error-verbosity=3",
@@ -107,83 +298,73 @@ func (m *mySQLTestContainer) RunMySQLTestContainer(t testing.TB) DatastoreTestCo
 	cont, err := dockerClient.ContainerCreate(context.Background(), &containerCfg, &hostCfg, nil, nil, name)
 	require.NoError(t, err, "failed to create mysql docker container")
 
-	t.Cleanup(func() {
-		execID, err := dockerClient.ContainerExecCreate(context.Background(), cont.ID, container.ExecOptions{
-			Cmd:          []string{"cat", "/var/lib/mysql/error.log"},
-			AttachStdout: true,
-			AttachStderr: true,
-		})
-		if err != nil {
-			log.Fatal("Failed to create exec instance:", err)
-		}
-		response, err := dockerClient.ContainerExecAttach(context.Background(), execID.ID, container.ExecAttachOptions{})
-		if err != nil {
-			log.Fatal("Failed to attach to exec instance:", err)
-		}
-		defer response.Close()
-
-		// Print the error logs
-		_, err = io.Copy(os.Stdout, response.Reader)
-		if err != nil {
-			log.Fatal("Failed to print error logs:", err)
-		}
-		t.Logf("stopping container %s", name)
-		timeoutSec := 5
-
-		err = dockerClient.ContainerStop(context.Background(), cont.ID, container.StopOptions{Timeout: &timeoutSec})
-		if err != nil && !client.IsErrNotFound(err) {
-			t.Logf("failed to stop mysql container: %v", err)
-		}
-		t.Logf("stopped container %s", name)
-	})
-
 	err = dockerClient.ContainerStart(context.Background(), cont.ID, container.StartOptions{})
-	require.NoError(t, err, "failed to start mysql container")
+	if err != nil {
+		stopMySQLContainer(dockerClient, cont.ID, name, t.Logf)
+		require.NoError(t, err, "failed to start mysql container")
+	}
 
 	containerJSON, err := dockerClient.ContainerInspect(context.Background(), cont.ID)
-	require.NoError(t, err)
+	if err != nil {
+		stopMySQLContainer(dockerClient, cont.ID, name, t.Logf)
+		require.NoError(t, err)
+	}
 
 	p, ok := containerJSON.NetworkSettings.Ports["3306/tcp"]
 	if !ok || len(p) == 0 {
+		stopMySQLContainer(dockerClient, cont.ID, name, t.Logf)
 		require.Fail(t, "failed to get host port mapping from mysql container")
 	}
 
-	mySQLTestContainer := &mySQLTestContainer{
+	return &mySQLTestContainer{
 		addr:     fmt.Sprintf("localhost:%s", p[0].HostPort),
 		username: "root",
 		password: "secret",
-	}
-
-	uri := fmt.Sprintf("%s:%s@tcp(%s)/defaultdb?parseTime=true", mySQLTestContainer.username, mySQLTestContainer.password, mySQLTestContainer.addr)
+		image:    m.image,
+		database: defaultMySQLDatabase,
+	}, cont.ID, name
+}
 
-	err = mysql.SetLogger(log.New(io.Discard, "", 0))
-	require.NoError(t, err)
+// stopMySQLContainer prints the error log of the container and stops it, which removes it.
+func stopMySQLContainer(dockerClient *client.Client, containerID, name string, logf func(format string, args ...any)) {
+	execID, err := dockerClient.ContainerExecCreate(context.Background(), containerID, container.ExecOptions{
+		Cmd:          []string{"cat", "/var/lib/mysql/error.log"},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		log.Fatal("Failed to create exec instance:", err)
+	}
+	response, err := dockerClient.ContainerExecAttach(context.Background(), execID.ID, container.ExecAttachOptions{})
+	if err != nil {
+		log.Fatal("Failed to attach to exec instance:", err)
+	}
+	defer response.Close()
 
-	goose.SetLogger(goose.NopLogger())
+	// Print the error logs
+	_, err = io.Copy(os.Stdout, response.Reader)
+	if err != nil {
+		log.Fatal("Failed to print error logs:", err)
+	}
+	logf("stopping container %s", name)
+	timeoutSec := 5
 
-	db, err := goose.OpenDBWithDriver("mysql", uri)
-	require.NoError(t, err)
-	defer db.Close()
+	err = dockerClient.ContainerStop(context.Background(), containerID, container.StopOptions{Timeout: &timeoutSec})
+	if err != nil && !client.IsErrNotFound(err) {
+		logf("failed to stop mysql container: %v", err)
+	}
+	logf("stopped container %s", name)
+}
 
+func pingWithBackoff(db *sql.DB) error {
 	backoffPolicy := backoff.NewExponentialBackOff()
 	backoffPolicy.MaxElapsedTime = 2 * time.Minute
-	err = backoff.Retry(
+	return backoff.Retry(
 		func() error {
 			return db.Ping()
 		},
 		backoffPolicy,
 	)
-	require.NoError(t, err, "failed to connect to mysql container")
-
-	goose.SetBaseFS(assets.EmbedMigrations)
-
-	err = goose.Up(db, assets.MySQLMigrationDir)
-	require.NoError(t, err)
-	version, err := goose.GetDBVersion(db)
-	require.NoError(t, err)
-	mySQLTestContainer.version = version
-
-	return mySQLTestContainer
 }
 
 // GetConnectionURI returns the mysql connection uri for the running mysql test container.
@@ -197,7 +378,7 @@ func (m *mySQLTestContainer) GetConnectionURI(includeCredentials bool) string {
 		"%stcp(%s)/%s?parseTime=true",
 		creds,
 		m.addr,
-		"defaultdb",
+		m.database,
 	)
 }
 
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	_ "github.com/microsoft/go-mssqldb" // SQL Server driver.
+	"github.com/oklog/ulid/v2"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/assets"
+)
+
+const (
+	sqlServerImage = "mcr.microsoft.com/mssql/server:2022-latest"
+)
+
+type sqlServerTestContainer struct {
+	addr     string
+	version  int64
+	username string
+	password string
+}
+
+// NewSQLServerTestContainer returns an implementation of the DatastoreTestContainer interface
+// for SQL Server.
+func NewSQLServerTestContainer() *sqlServerTestContainer {
+	return &sqlServerTestContainer{}
+}
+
+func (s *sqlServerTestContainer) GetDatabaseSchemaVersion() int64 {
+	return s.version
+}
+
+// RunSQLServerTestContainer runs a SQL Server container, connects to it, creates the database, and returns a
+// bootstrapped implementation of the DatastoreTestContainer interface wired up for the
+// SQL Server datastore engine.
+func (s *sqlServerTestContainer) RunSQLServerTestContainer(t testing.TB) DatastoreTestContainer {
+	dockerClient, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		dockerClient.Close()
+	})
+
+	allImages, err := dockerClient.ImageList(context.Background(), image.ListOptions{
+		All: true,
+	})
+	require.NoError(t, err)
+
+	foundSQLServerImage := false
+	for _, image := range allImages {
+		for _, tag := range image.RepoTags {
+			if strings.Contains(tag, sqlServerImage) {
+				foundSQLServerImage = true
+				break
+			}
+		}
+	}
+
+	if !foundSQLServerImage {
+		t.Logf("Pulling image %s", sqlServerImage)
+		reader, err := dockerClient.ImagePull(context.Background(), sqlServerImage, image.PullOptions{})
+		require.NoError(t, err)
+
+		_, err = io.Copy(io.Discard, reader) // consume the image pull output to make sure it's done
+		require.NoError(t, err)
+	}
+
+	containerCfg := container.Config{
+		Env: []string{
+			"ACCEPT_EULA=Y",
+			"MSSQL_SA_PASSWORD=Secret-Passw0rd",
+		},
+		ExposedPorts: nat.PortSet{
+			nat.Port("1433/tcp"): {},
+		},
+		Image: sqlServerImage,
+	}
+
+	hostCfg := container.HostConfig{
+		AutoRemove:      true,
+		PublishAllPorts: true,
+	}
+
+	name := fmt.Sprintf("sqlserver-%s", ulid.Make().String())
+
+	cont, err := dockerClient.ContainerCreate(context.Background(), &containerCfg, &hostCfg, nil, nil, name)
+	require.NoError(t, err, "failed to create sqlserver docker container")
+
+	t.Cleanup(func() {
+		t.Logf("stopping container %s", name)
+		timeoutSec := 5
+
+		err := dockerClient.ContainerStop(context.Background(), cont.ID, container.StopOptions{Timeout: &timeoutSec})
+		if err != nil && !client.IsErrNotFound(err) {
+			t.Logf("failed to stop sqlserver container: %v", err)
+		}
+
+		t.Logf("stopped container %s", name)
+	})
+
+	err = dockerClient.ContainerStart(context.Background(), cont.ID, container.StartOptions{})
+	require.NoError(t, err, "failed to start sqlserver container")
+
+	containerJSON, err := dockerClient.ContainerInspect(context.Background(), cont.ID)
+	require.NoError(t, err)
+
+	m, ok := containerJSON.NetworkSettings.Ports["1433/tcp"]
+	if !ok || len(m) == 0 {
+		require.Fail(t, "failed to get host port mapping from sqlserver container")
+	}
+
+	sqlServerContainer := &sqlServerTestContainer{
+		addr:     fmt.Sprintf("localhost:%s", m[0].HostPort),
+		username: "sa",
+		password: "Secret-Passw0rd",
+	}
+
+	// the image has no option to create a database, so it's created through the master database
+	masterDB, err := sql.Open("sqlserver", fmt.Sprintf("sqlserver://%s:%s@%s", sqlServerContainer.username, sqlServerContainer.password, sqlServerContainer.addr))
+	require.NoError(t, err)
+	defer masterDB.Close()
+
+	backoffPolicy := backoff.NewExponentialBackOff()
+	backoffPolicy.MaxElapsedTime = 2 * time.Minute
+	err = backoff.Retry(
+		func() error {
+			return masterDB.Ping()
+		},
+		backoffPolicy,
+	)
+	require.NoError(t, err, "failed to connect to sqlserver container")
+
+	_, err = masterDB.Exec("CREATE DATABASE defaultdb")
+	require.NoError(t, err)
+
+	goose.SetLogger(goose.NopLogger())
+
+	db, err := goose.OpenDBWithDriver("sqlserver", sqlServerContainer.GetConnectionURI(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	goose.SetBaseFS(assets.EmbedMigrations)
+
+	err = goose.Up(db, assets.SQLServerMigrationDir)
+	require.NoError(t, err)
+
+	version, err := goose.GetDBVersion(db)
+	require.NoError(t, err)
+	sqlServerContainer.version = version
+
+	return sqlServerContainer
+}
+
+// GetConnectionURI returns the sqlserver connection uri for the running sqlserver test container.
+func (s *sqlServerTestContainer) GetConnectionURI(includeCredentials bool) string {
+	creds := ""
+	if includeCredentials {
+		creds = fmt.Sprintf("%s:%s@", s.username, s.password)
+	}
+
+	return fmt.Sprintf(
+		"sqlserver://%s%s?database=%s",
+		creds,
+		s.addr,
+		"defaultdb",
+	)
+}
+
+func (s *sqlServerTestContainer) GetUsername() string {
+	return s.username
+}
+
+func (s *sqlServerTestContainer) GetPassword() string {
+	return s.password
+}
@@ -307,6 +307,44 @@ func IsValidUser(user string) bool {
 	return false
 }
 
+// FieldLengthLimits are the maximum lengths, in bytes of their UTF-8 encoding, of the fields of the tuples
+// that are written. A limit that isn't greater than zero disables the validation of its field.
+type FieldLengthLimits struct {
+	ObjectID int
+	Relation int
+	User     int
+}
+
+// DefaultFieldLengthLimits match the sizes of the columns of the tuple table of the MySQL and SQL Server
+// datastores. The SQL Server columns are sized in bytes, and the MySQL ones in characters, so limiting the
+// bytes of the fields ensures that no datastore truncates them.
+var DefaultFieldLengthLimits = FieldLengthLimits{
+	ObjectID: 128,
+	Relation: 50,
+	User:     256,
+}
+
+// ValidateFieldLengths returns a *FieldTooLongError if a field of the tuple is longer than its limit.
+func ValidateFieldLengths(tk TupleWithoutCondition, limits FieldLengthLimits) error {
+	_, objectID := SplitObject(tk.GetObject())
+
+	fields := []struct {
+		name  string
+		value string
+		limit int
+	}{
+		{name: "object_id", value: objectID, limit: limits.ObjectID},
+		{name: "relation", value: tk.GetRelation(), limit: limits.Relation},
+		{name: "user", value: tk.GetUser(), limit: limits.User},
+	}
+	for _, field := range fields {
+		if length := len(field.value); field.limit > 0 && length > field.limit {
+			return &FieldTooLongError{Field: field.name, Length: length, Limit: field.limit}
+		}
+	}
+	return nil
+}
+
 // IsWildcard returns true if the string 's' could be interpreted as a typed or untyped wildcard (e.g. '*' or 'type:*').
 func IsWildcard(s string) bool {
 	return s == Wildcard || IsTypedWildcard(s)
@@ -36,6 +36,17 @@ func (i *InvalidTupleError) Is(target error) bool {
 	return ok
 }
 
+// FieldTooLongError is returned if a field of a tuple is longer than its limit.
+type FieldTooLongError struct {
+	Field  string
+	Length int
+	Limit  int
+}
+
+func (i *FieldTooLongError) Error() string {
+	return fmt.Sprintf("the '%s' field is too long: %d bytes exceeds the limit of %d", i.Field, i.Length, i.Limit)
+}
+
 type TypeNotFoundError struct {
 	TypeName string
 }
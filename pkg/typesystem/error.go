@@ -11,6 +11,10 @@ var (
 	// ErrModelNotFound is returned when an authorization model is not found.
 	ErrModelNotFound = errors.New("authorization model not found")
 
+	// ErrNoModelForStore is returned when the latest authorization model of a store is requested but the store
+	// has none yet. It wraps ErrModelNotFound.
+	ErrNoModelForStore = fmt.Errorf("%w: the store has no authorization model yet", ErrModelNotFound)
+
 	// ErrDuplicateTypes is returned when an authorization model contains duplicate types.
 	ErrDuplicateTypes = errors.New("an authorization model cannot contain duplicate types")
 
@@ -47,8 +51,47 @@ var (
 
 	// ErrNoConditionForRelation is returned when no condition is defined for a relation in the authorization model.
 	ErrNoConditionForRelation = errors.New("no condition defined for relation")
+
+	// ErrRelationNotListable is returned when the objects of a relation can't be listed by reverse expansion
+	// alone, and every object found must be Checked.
+	ErrRelationNotListable = errors.New("relation is not listable")
 )
 
+// ModelNotFoundError is returned when the authorization model with a given ID is not found in a store, e.g.
+// because it was never written or was deleted since. It wraps ErrModelNotFound.
+type ModelNotFoundError struct {
+	StoreID string
+	ModelID string
+}
+
+// Error implements the error interface for ModelNotFoundError.
+func (e *ModelNotFoundError) Error() string {
+	return fmt.Sprintf("%s: no authorization model '%s' in store '%s'", ErrModelNotFound, e.ModelID, e.StoreID)
+}
+
+// Unwrap returns ErrModelNotFound.
+func (e *ModelNotFoundError) Unwrap() error {
+	return ErrModelNotFound
+}
+
+// RelationNotListableError is returned when the objects of a relation can't be listed by reverse expansion
+// alone, because the relation involves an intersection or an exclusion. It wraps ErrRelationNotListable.
+type RelationNotListableError struct {
+	ObjectType string
+	Relation   string
+}
+
+// Error implements the error interface for RelationNotListableError.
+func (e *RelationNotListableError) Error() string {
+	return fmt.Sprintf("%s: relation '%s#%s' involves an intersection or an exclusion, so every object found must be checked",
+		ErrRelationNotListable, e.ObjectType, e.Relation)
+}
+
+// Unwrap returns ErrRelationNotListable.
+func (e *RelationNotListableError) Unwrap() error {
+	return ErrRelationNotListable
+}
+
 // InvalidTypeError represents an error indicating an invalid object type.
 type InvalidTypeError struct {
 	ObjectType string
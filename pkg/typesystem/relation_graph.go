@@ -0,0 +1,180 @@
+package typesystem
+
+import (
+	"sort"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// RelationGraphEdgeKind is the way a relation references another relation or a type.
+type RelationGraphEdgeKind string
+
+const (
+	// RelationGraphEdgeDirect references a type, a typed wildcard or a userset that can be directly
+	// assigned to the relation, e.g. `[user, user:*, group#member]`.
+	RelationGraphEdgeDirect RelationGraphEdgeKind = "direct"
+	// RelationGraphEdgeComputed references another relation of the same type, e.g. `editor`.
+	RelationGraphEdgeComputed RelationGraphEdgeKind = "computed"
+	// RelationGraphEdgeTupleToUserset references a relation of the types related through a tupleset
+	// relation, e.g. `viewer from parent`.
+	RelationGraphEdgeTupleToUserset RelationGraphEdgeKind = "tuple_to_userset"
+)
+
+// RelationGraphOperand is the operand of a set operation that a reference is.
+type RelationGraphOperand string
+
+const (
+	// RelationGraphOperandNone is used for references that aren't an operand of a set operation.
+	RelationGraphOperandNone         RelationGraphOperand = ""
+	RelationGraphOperandUnion        RelationGraphOperand = "union"
+	RelationGraphOperandIntersection RelationGraphOperand = "intersection"
+	// RelationGraphOperandExclusionBase is used for the references of the base of an exclusion, e.g. `editor` in `editor but not blocked`.
+	RelationGraphOperandExclusionBase RelationGraphOperand = "exclusion_base"
+	// RelationGraphOperandExclusionSubtract is used for the references of the subtracted operand of an exclusion, e.g. `blocked` in `editor but not blocked`.
+	RelationGraphOperandExclusionSubtract RelationGraphOperand = "exclusion_subtract"
+)
+
+// RelationGraphNode is a type (e.g. `user`), a typed wildcard (e.g. `user:*`) or a relation of a type
+// (e.g. `document#viewer`).
+type RelationGraphNode struct {
+	// ID is `type`, `type:*` or `type#relation`, and is unique in the graph.
+	ID         string `json:"id"`
+	ObjectType string `json:"object_type"`
+	Relation   string `json:"relation,omitempty"`
+	Wildcard   bool   `json:"wildcard,omitempty"`
+}
+
+// RelationGraphEdge is a reference from a relation to another node of the graph.
+type RelationGraphEdge struct {
+	From string                `json:"from"`
+	To   string                `json:"to"`
+	Kind RelationGraphEdgeKind `json:"kind"`
+	// Operand is the operand of the innermost set operation that contains the reference.
+	Operand RelationGraphOperand `json:"operand,omitempty"`
+	// Tupleset is the tupleset relation of tuple to userset references.
+	Tupleset string `json:"tupleset,omitempty"`
+	// Condition is the condition of direct references that require one.
+	Condition string `json:"condition,omitempty"`
+}
+
+// RelationGraph is the graph of the references between the relations of a model.
+type RelationGraph struct {
+	Nodes []RelationGraphNode `json:"nodes"`
+	Edges []RelationGraphEdge `json:"edges"`
+}
+
+// RelationGraph returns the graph of the references between the relations of the model, as
+// determined statically from their rewrites. Every type and relation of the model is a node, and
+// nodes and edges are sorted so that the graph of a model is always the same.
+func (t *TypeSystem) RelationGraph() *RelationGraph {
+	nodes := map[string]RelationGraphNode{}
+	graph := &RelationGraph{}
+
+	for objectType, relations := range t.relations {
+		nodes[objectType] = RelationGraphNode{ID: objectType, ObjectType: objectType}
+
+		for relationName, relation := range relations {
+			from := tuple.ToObjectRelationString(objectType, relationName)
+			nodes[from] = RelationGraphNode{ID: from, ObjectType: objectType, Relation: relationName}
+
+			builder := &relationGraphBuilder{typesys: t, graph: graph, nodes: nodes, objectType: objectType, from: from}
+			builder.addRewrite(relation, relation.GetRewrite(), RelationGraphOperandNone)
+		}
+	}
+
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Operand != b.Operand {
+			return a.Operand < b.Operand
+		}
+		if a.Tupleset != b.Tupleset {
+			return a.Tupleset < b.Tupleset
+		}
+		return a.Condition < b.Condition
+	})
+
+	return graph
+}
+
+// relationGraphBuilder adds the edges of a single relation to a graph.
+type relationGraphBuilder struct {
+	typesys    *TypeSystem
+	graph      *RelationGraph
+	nodes      map[string]RelationGraphNode
+	objectType string
+	from       string
+}
+
+func (b *relationGraphBuilder) addEdge(edge RelationGraphEdge) {
+	edge.From = b.from
+	b.graph.Edges = append(b.graph.Edges, edge)
+}
+
+func (b *relationGraphBuilder) addRewrite(relation *openfgav1.Relation, rewrite *openfgav1.Userset, operand RelationGraphOperand) {
+	switch rw := rewrite.GetUserset().(type) {
+	case *openfgav1.Userset_This:
+		for _, ref := range relation.GetTypeInfo().GetDirectlyRelatedUserTypes() {
+			to := ref.GetType()
+			switch {
+			case ref.GetWildcard() != nil:
+				to = tuple.TypedPublicWildcard(ref.GetType())
+				b.nodes[to] = RelationGraphNode{ID: to, ObjectType: ref.GetType(), Wildcard: true}
+			case ref.GetRelation() != "":
+				to = tuple.ToObjectRelationString(ref.GetType(), ref.GetRelation())
+			}
+			b.addEdge(RelationGraphEdge{To: to, Kind: RelationGraphEdgeDirect, Operand: operand, Condition: ref.GetCondition()})
+		}
+	case *openfgav1.Userset_ComputedUserset:
+		b.addEdge(RelationGraphEdge{
+			To:      tuple.ToObjectRelationString(b.objectType, rw.ComputedUserset.GetRelation()),
+			Kind:    RelationGraphEdgeComputed,
+			Operand: operand,
+		})
+	case *openfgav1.Userset_TupleToUserset:
+		tupleset := rw.TupleToUserset.GetTupleset().GetRelation()
+		computedRelation := rw.TupleToUserset.GetComputedUserset().GetRelation()
+
+		refs, _ := b.typesys.GetDirectlyRelatedUserTypes(b.objectType, tupleset)
+		for _, ref := range refs {
+			// only the related types that define the computed relation are resolved
+			if _, err := b.typesys.GetRelation(ref.GetType(), computedRelation); err != nil {
+				continue
+			}
+			b.addEdge(RelationGraphEdge{
+				To:       tuple.ToObjectRelationString(ref.GetType(), computedRelation),
+				Kind:     RelationGraphEdgeTupleToUserset,
+				Operand:  operand,
+				Tupleset: tupleset,
+			})
+		}
+	case *openfgav1.Userset_Union:
+		for _, child := range rw.Union.GetChild() {
+			b.addRewrite(relation, child, RelationGraphOperandUnion)
+		}
+	case *openfgav1.Userset_Intersection:
+		for _, child := range rw.Intersection.GetChild() {
+			b.addRewrite(relation, child, RelationGraphOperandIntersection)
+		}
+	case *openfgav1.Userset_Difference:
+		b.addRewrite(relation, rw.Difference.GetBase(), RelationGraphOperandExclusionBase)
+		b.addRewrite(relation, rw.Difference.GetSubtract(), RelationGraphOperandExclusionSubtract)
+	}
+}
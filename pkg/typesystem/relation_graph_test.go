@@ -0,0 +1,124 @@
+package typesystem
+
+import (
+	"testing"
+
+	parser "github.com/openfga/language/pkg/go/transformer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelationGraph(t *testing.T) {
+	tests := map[string]struct {
+		model         string
+		expectedNodes []RelationGraphNode
+		expectedEdges []RelationGraphEdge
+	}{
+		"direct_relations": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type group
+					relations
+						define member: [user, user:*, group#member, user with xcond]
+				condition xcond(x: int) {
+					x < 100
+				}`,
+			expectedNodes: []RelationGraphNode{
+				{ID: "group", ObjectType: "group"},
+				{ID: "group#member", ObjectType: "group", Relation: "member"},
+				{ID: "user", ObjectType: "user"},
+				{ID: "user:*", ObjectType: "user", Wildcard: true},
+			},
+			expectedEdges: []RelationGraphEdge{
+				{From: "group#member", To: "group#member", Kind: RelationGraphEdgeDirect},
+				{From: "group#member", To: "user", Kind: RelationGraphEdgeDirect},
+				{From: "group#member", To: "user", Kind: RelationGraphEdgeDirect, Condition: "xcond"},
+				{From: "group#member", To: "user:*", Kind: RelationGraphEdgeDirect},
+			},
+		},
+		"computed_and_tuple_to_userset": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type folder
+					relations
+						define viewer: [user]
+				type group
+					relations
+						define member: [user]
+				type document
+					relations
+						define parent: [folder, group]
+						define owner: [user]
+						define viewer: owner
+						define inherited_viewer: viewer from parent`,
+			expectedNodes: []RelationGraphNode{
+				{ID: "document", ObjectType: "document"},
+				{ID: "document#inherited_viewer", ObjectType: "document", Relation: "inherited_viewer"},
+				{ID: "document#owner", ObjectType: "document", Relation: "owner"},
+				{ID: "document#parent", ObjectType: "document", Relation: "parent"},
+				{ID: "document#viewer", ObjectType: "document", Relation: "viewer"},
+				{ID: "folder", ObjectType: "folder"},
+				{ID: "folder#viewer", ObjectType: "folder", Relation: "viewer"},
+				{ID: "group", ObjectType: "group"},
+				{ID: "group#member", ObjectType: "group", Relation: "member"},
+				{ID: "user", ObjectType: "user"},
+			},
+			expectedEdges: []RelationGraphEdge{
+				// group doesn't define viewer, so it isn't referenced
+				{From: "document#inherited_viewer", To: "folder#viewer", Kind: RelationGraphEdgeTupleToUserset, Tupleset: "parent"},
+				{From: "document#owner", To: "user", Kind: RelationGraphEdgeDirect},
+				{From: "document#parent", To: "folder", Kind: RelationGraphEdgeDirect},
+				{From: "document#parent", To: "group", Kind: RelationGraphEdgeDirect},
+				{From: "document#viewer", To: "document#owner", Kind: RelationGraphEdgeComputed},
+				{From: "folder#viewer", To: "user", Kind: RelationGraphEdgeDirect},
+				{From: "group#member", To: "user", Kind: RelationGraphEdgeDirect},
+			},
+		},
+		"set_operations": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define owner: [user]
+						define blocked: [user]
+						define editor: [user] or owner
+						define auditor: editor and owner
+						define viewer: (editor or owner) but not blocked`,
+			expectedNodes: []RelationGraphNode{
+				{ID: "document", ObjectType: "document"},
+				{ID: "document#auditor", ObjectType: "document", Relation: "auditor"},
+				{ID: "document#blocked", ObjectType: "document", Relation: "blocked"},
+				{ID: "document#editor", ObjectType: "document", Relation: "editor"},
+				{ID: "document#owner", ObjectType: "document", Relation: "owner"},
+				{ID: "document#viewer", ObjectType: "document", Relation: "viewer"},
+				{ID: "user", ObjectType: "user"},
+			},
+			expectedEdges: []RelationGraphEdge{
+				{From: "document#auditor", To: "document#editor", Kind: RelationGraphEdgeComputed, Operand: RelationGraphOperandIntersection},
+				{From: "document#auditor", To: "document#owner", Kind: RelationGraphEdgeComputed, Operand: RelationGraphOperandIntersection},
+				{From: "document#blocked", To: "user", Kind: RelationGraphEdgeDirect},
+				{From: "document#editor", To: "document#owner", Kind: RelationGraphEdgeComputed, Operand: RelationGraphOperandUnion},
+				{From: "document#editor", To: "user", Kind: RelationGraphEdgeDirect, Operand: RelationGraphOperandUnion},
+				{From: "document#owner", To: "user", Kind: RelationGraphEdgeDirect},
+				{From: "document#viewer", To: "document#blocked", Kind: RelationGraphEdgeComputed, Operand: RelationGraphOperandExclusionSubtract},
+				{From: "document#viewer", To: "document#editor", Kind: RelationGraphEdgeComputed, Operand: RelationGraphOperandUnion},
+				{From: "document#viewer", To: "document#owner", Kind: RelationGraphEdgeComputed, Operand: RelationGraphOperandUnion},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			typesys := New(parser.MustTransformDSLToProto(test.model))
+
+			graph := typesys.RelationGraph()
+			require.Equal(t, test.expectedNodes, graph.Nodes)
+			require.Equal(t, test.expectedEdges, graph.Edges)
+		})
+	}
+}
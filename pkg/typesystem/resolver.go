@@ -47,7 +47,7 @@ func MemoizedTypesystemResolverFunc(datastore storage.AuthorizationModelReadBack
 
 		if modelID != "" {
 			if _, err := ulid.Parse(modelID); err != nil {
-				return nil, ErrModelNotFound
+				return nil, &ModelNotFoundError{StoreID: storeID, ModelID: modelID}
 			}
 		}
 
@@ -59,7 +59,7 @@ func MemoizedTypesystemResolverFunc(datastore storage.AuthorizationModelReadBack
 			})
 			if err != nil {
 				if errors.Is(err, storage.ErrNotFound) {
-					return nil, ErrModelNotFound
+					return nil, ErrNoModelForStore
 				}
 
 				return nil, fmt.Errorf("failed to FindLatestAuthorizationModel: %w", err)
@@ -75,28 +75,38 @@ func MemoizedTypesystemResolverFunc(datastore storage.AuthorizationModelReadBack
 			return item.Value, nil
 		}
 
-		if model == nil {
-			v, err, _ := lookupGroup.Do(fmt.Sprintf("ReadAuthorizationModel:%s/%s", storeID, modelID), func() (interface{}, error) {
-				return datastore.ReadAuthorizationModel(ctx, storeID, modelID)
-			})
-			if err != nil {
-				if errors.Is(err, storage.ErrNotFound) {
-					return nil, ErrModelNotFound
+		// the model is read and compiled once for all the concurrent requests that missed the cache
+		v, err, _ := lookupGroup.Do(fmt.Sprintf("Typesystem:%s", key), func() (interface{}, error) {
+			// the model may have been cached since the cache was missed
+			if item := cache.Get(key); item != nil {
+				return item.Value, nil
+			}
+
+			if model == nil {
+				var err error
+				model, err = datastore.ReadAuthorizationModel(ctx, storeID, modelID)
+				if err != nil {
+					if errors.Is(err, storage.ErrNotFound) {
+						return nil, &ModelNotFoundError{StoreID: storeID, ModelID: modelID}
+					}
+
+					return nil, fmt.Errorf("failed to ReadAuthorizationModel: %w", err)
 				}
+			}
 
-				return nil, fmt.Errorf("failed to ReadAuthorizationModel: %w", err)
+			typesys, err := NewAndValidate(ctx, model)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidModel, err)
 			}
 
-			model = v.(*openfgav1.AuthorizationModel)
-		}
+			cache.Set(key, typesys, typesystemCacheTTL)
 
-		typesys, err := NewAndValidate(ctx, model)
+			return typesys, nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrInvalidModel, err)
+			return nil, err
 		}
 
-		cache.Set(key, typesys, typesystemCacheTTL)
-
-		return typesys, nil
+		return v.(*TypeSystem), nil
 	}, cache.Stop
 }
@@ -0,0 +1,94 @@
+package typesystem
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+)
+
+// countingModelReadBackend counts the models read from the wrapped backend.
+type countingModelReadBackend struct {
+	storage.AuthorizationModelReadBackend
+	reads atomic.Int32
+}
+
+func (c *countingModelReadBackend) ReadAuthorizationModel(ctx context.Context, store, id string) (*openfgav1.AuthorizationModel, error) {
+	c.reads.Add(1)
+	return c.AuthorizationModelReadBackend.ReadAuthorizationModel(ctx, store, id)
+}
+
+func TestMemoizedTypesystemResolverFuncConcurrentCacheMisses(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := "01HVMMBCMGZNT3SED4Z17ECXCA"
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	model.Id = "01HVMMBD123A0MTV2W8F1XY6RN"
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+
+	backend := &countingModelReadBackend{AuthorizationModelReadBackend: ds}
+	resolver, stop := MemoizedTypesystemResolverFunc(backend)
+	t.Cleanup(stop)
+
+	const concurrency = 50
+	typesystems := make([]*TypeSystem, concurrency)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			typesys, err := resolver(ctx, storeID, model.GetId())
+			require.NoError(t, err)
+			typesystems[i] = typesys
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	require.Equal(t, int32(1), backend.reads.Load())
+	for _, typesys := range typesystems {
+		// the model was compiled once and shared by all the requests
+		require.Same(t, typesystems[0], typesys)
+	}
+}
+
+func TestMemoizedTypesystemResolverFuncModelNotFound(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	resolver, stop := MemoizedTypesystemResolverFunc(ds)
+	t.Cleanup(stop)
+
+	storeID := "01HVMMBCMGZNT3SED4Z17ECXCA"
+	for _, modelID := range []string{"01HVMMBD123A0MTV2W8F1XY6RN", "not-a-ulid"} {
+		_, err := resolver(ctx, storeID, modelID)
+		require.ErrorIs(t, err, ErrModelNotFound)
+
+		var notFoundErr *ModelNotFoundError
+		require.ErrorAs(t, err, &notFoundErr)
+		require.Equal(t, storeID, notFoundErr.StoreID)
+		require.Equal(t, modelID, notFoundErr.ModelID)
+	}
+
+	_, err := resolver(ctx, storeID, "")
+	require.ErrorIs(t, err, ErrNoModelForStore)
+}
@@ -0,0 +1,189 @@
+package typesystem
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnsatisfiableRelation describes a relation whose rewrite can never evaluate to true, regardless of the tuples
+// written, e.g. `define viewer: editor but not editor`.
+type UnsatisfiableRelation struct {
+	ObjectType string
+	Relation   string
+	// Reason describes the contradiction that makes the relation unsatisfiable.
+	Reason string
+}
+
+func (u UnsatisfiableRelation) String() string {
+	return fmt.Sprintf("relation '%s#%s' can never be satisfied: %s", u.ObjectType, u.Relation, u.Reason)
+}
+
+// UnsatisfiableRelations statically analyzes the rewrite of every relation in the model and returns
+// the relations that are structurally unsatisfiable, sorted by object type and relation.
+//
+// The analysis is conservative: a relation that is reported can never be true, but a relation that
+// is not reported may still be unsatisfiable for reasons that can't be determined from the rewrites alone.
+// It detects operands that are both required and excluded (e.g. `a and not a`, `(a and b) but not a`),
+// intersections without operands, and relations that are only defined in terms of unsatisfiable relations
+// of the same type.
+func (t *TypeSystem) UnsatisfiableRelations() []UnsatisfiableRelation {
+	analyzer := &satisfiabilityAnalyzer{
+		typesys: t,
+		results: map[string]*string{},
+	}
+
+	var unsatisfiable []UnsatisfiableRelation
+	for objectType, relations := range t.relations {
+		for relationName := range relations {
+			if reason := analyzer.analyzeRelation(objectType, relationName); reason != nil {
+				unsatisfiable = append(unsatisfiable, UnsatisfiableRelation{
+					ObjectType: objectType,
+					Relation:   relationName,
+					Reason:     *reason,
+				})
+			}
+		}
+	}
+
+	sort.Slice(unsatisfiable, func(i, j int) bool {
+		if unsatisfiable[i].ObjectType != unsatisfiable[j].ObjectType {
+			return unsatisfiable[i].ObjectType < unsatisfiable[j].ObjectType
+		}
+		return unsatisfiable[i].Relation < unsatisfiable[j].Relation
+	})
+
+	return unsatisfiable
+}
+
+type satisfiabilityAnalyzer struct {
+	typesys *TypeSystem
+	// results holds, per object type and relation, the reason why the relation is unsatisfiable,
+	// or nil if it may be satisfied. Relations being analyzed are present with a nil value, so cycles
+	// are treated as satisfiable.
+	results map[string]*string
+}
+
+// rewriteAnalysis is the outcome of analyzing a single node of a rewrite tree.
+type rewriteAnalysis struct {
+	// required are the operands that must all be true for the node to be true.
+	required []*openfgav1.Userset
+	// excluded are the operands that must all be false for the node to be true.
+	excluded []*openfgav1.Userset
+	// unsatisfiable is the reason why the node can never be true, if any.
+	unsatisfiable *string
+}
+
+func (s *satisfiabilityAnalyzer) analyzeRelation(objectType, relation string) *string {
+	key := fmt.Sprintf("%s#%s", objectType, relation)
+	if reason, ok := s.results[key]; ok {
+		return reason
+	}
+	s.results[key] = nil
+
+	rel, err := s.typesys.GetRelation(objectType, relation)
+	if err != nil {
+		return nil
+	}
+
+	reason := s.analyzeRewrite(objectType, rel.GetRewrite()).unsatisfiable
+	s.results[key] = reason
+	return reason
+}
+
+func (s *satisfiabilityAnalyzer) analyzeRewrite(objectType string, rewrite *openfgav1.Userset) rewriteAnalysis {
+	switch rw := rewrite.GetUserset().(type) {
+	case *openfgav1.Userset_ComputedUserset:
+		analysis := rewriteAnalysis{required: []*openfgav1.Userset{rewrite}}
+		computedRelation := rw.ComputedUserset.GetRelation()
+		if s.analyzeRelation(objectType, computedRelation) != nil {
+			analysis.unsatisfiable = reasonf("it is defined by '%s', which can never be satisfied", computedRelation)
+		}
+		return analysis
+	case *openfgav1.Userset_Union:
+		for _, child := range rw.Union.GetChild() {
+			if s.analyzeRewrite(objectType, child).unsatisfiable == nil {
+				return rewriteAnalysis{required: []*openfgav1.Userset{rewrite}}
+			}
+		}
+		return rewriteAnalysis{unsatisfiable: reasonf("every operand of '%s' can never be satisfied", describeRewrite(rewrite))}
+	case *openfgav1.Userset_Intersection:
+		children := rw.Intersection.GetChild()
+		if len(children) == 0 {
+			return rewriteAnalysis{unsatisfiable: reasonf("the intersection has no operands")}
+		}
+
+		var analysis rewriteAnalysis
+		for _, child := range children {
+			childAnalysis := s.analyzeRewrite(objectType, child)
+			if childAnalysis.unsatisfiable != nil {
+				return childAnalysis
+			}
+			analysis.required = append(analysis.required, childAnalysis.required...)
+			analysis.excluded = append(analysis.excluded, childAnalysis.excluded...)
+		}
+		analysis.unsatisfiable = contradiction(analysis)
+		return analysis
+	case *openfgav1.Userset_Difference:
+		analysis := s.analyzeRewrite(objectType, rw.Difference.GetBase())
+		if analysis.unsatisfiable != nil {
+			return analysis
+		}
+		analysis.excluded = append(analysis.excluded, rw.Difference.GetSubtract())
+		analysis.unsatisfiable = contradiction(analysis)
+		return analysis
+	default:
+		// direct assignment and tuple to userset rewrites may always be satisfied by writing the right tuples
+		return rewriteAnalysis{required: []*openfgav1.Userset{rewrite}}
+	}
+}
+
+// contradiction returns the reason why an analysis is unsatisfiable if one of its required operands
+// is also excluded, or nil otherwise.
+func contradiction(analysis rewriteAnalysis) *string {
+	for _, required := range analysis.required {
+		for _, excluded := range analysis.excluded {
+			if proto.Equal(required, excluded) {
+				operand := describeRewrite(required)
+				return reasonf("'%s' is both required and excluded ('%s and not %s')", operand, operand, operand)
+			}
+		}
+	}
+	return nil
+}
+
+func reasonf(format string, args ...any) *string {
+	reason := fmt.Sprintf(format, args...)
+	return &reason
+}
+
+// describeRewrite returns a DSL-like representation of a rewrite, to be used in messages.
+func describeRewrite(rewrite *openfgav1.Userset) string {
+	describeChildren := func(children []*openfgav1.Userset, operator string) string {
+		descriptions := make([]string, 0, len(children))
+		for _, child := range children {
+			descriptions = append(descriptions, describeRewrite(child))
+		}
+		return "(" + strings.Join(descriptions, " "+operator+" ") + ")"
+	}
+
+	switch rw := rewrite.GetUserset().(type) {
+	case *openfgav1.Userset_This:
+		return "[direct assignment]"
+	case *openfgav1.Userset_ComputedUserset:
+		return rw.ComputedUserset.GetRelation()
+	case *openfgav1.Userset_TupleToUserset:
+		return fmt.Sprintf("%s from %s", rw.TupleToUserset.GetComputedUserset().GetRelation(), rw.TupleToUserset.GetTupleset().GetRelation())
+	case *openfgav1.Userset_Union:
+		return describeChildren(rw.Union.GetChild(), "or")
+	case *openfgav1.Userset_Intersection:
+		return describeChildren(rw.Intersection.GetChild(), "and")
+	case *openfgav1.Userset_Difference:
+		return fmt.Sprintf("(%s but not %s)", describeRewrite(rw.Difference.GetBase()), describeRewrite(rw.Difference.GetSubtract()))
+	default:
+		return "[unknown]"
+	}
+}
@@ -0,0 +1,138 @@
+package typesystem
+
+import (
+	"context"
+	"testing"
+
+	parser "github.com/openfga/language/pkg/go/transformer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsatisfiableRelations(t *testing.T) {
+	tests := map[string]struct {
+		model    string
+		expected []UnsatisfiableRelation
+	}{
+		"satisfiable_relations": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type group
+					relations
+						define member: [user]
+				type document
+					relations
+						define parent: [group]
+						define owner: [user]
+						define blocked: [user]
+						define editor: [user] or owner
+						define viewer: (editor or member from parent) but not blocked
+						define auditor: editor and owner
+						define restricted: editor but not owner`,
+		},
+		"exclusion_of_itself": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define editor: [user]
+						define viewer: editor but not editor`,
+			expected: []UnsatisfiableRelation{{
+				ObjectType: "document",
+				Relation:   "viewer",
+				Reason:     "'editor' is both required and excluded ('editor and not editor')",
+			}},
+		},
+		"intersection_with_its_own_exclusion": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define owner: [user]
+						define editor: [user]
+						define viewer: owner and (editor but not owner)`,
+			expected: []UnsatisfiableRelation{{
+				ObjectType: "document",
+				Relation:   "viewer",
+				Reason:     "'owner' is both required and excluded ('owner and not owner')",
+			}},
+		},
+		"exclusion_of_required_intersection_operand": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define owner: [user]
+						define editor: [user]
+						define viewer: (owner and editor) but not owner`,
+			expected: []UnsatisfiableRelation{{
+				ObjectType: "document",
+				Relation:   "viewer",
+				Reason:     "'owner' is both required and excluded ('owner and not owner')",
+			}},
+		},
+		"exclusion_of_tuple_to_userset": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type group
+					relations
+						define member: [user]
+				type document
+					relations
+						define parent: [group]
+						define viewer: member from parent but not member from parent`,
+			expected: []UnsatisfiableRelation{{
+				ObjectType: "document",
+				Relation:   "viewer",
+				Reason:     "'member from parent' is both required and excluded ('member from parent and not member from parent')",
+			}},
+		},
+		"propagates_through_computed_relations_and_unions": {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define editor: [user]
+						define never: editor but not editor
+						define also_never: never
+						define still_never: never or also_never
+						define sometimes: [user] or never`,
+			expected: []UnsatisfiableRelation{
+				{
+					ObjectType: "document",
+					Relation:   "also_never",
+					Reason:     "it is defined by 'never', which can never be satisfied",
+				},
+				{
+					ObjectType: "document",
+					Relation:   "never",
+					Reason:     "'editor' is both required and excluded ('editor and not editor')",
+				},
+				{
+					ObjectType: "document",
+					Relation:   "still_never",
+					Reason:     "every operand of '(never or also_never)' can never be satisfied",
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			typesys, err := NewAndValidate(context.Background(), parser.MustTransformDSLToProto(test.model))
+			require.NoError(t, err)
+			require.Equal(t, test.expected, typesys.UnsatisfiableRelations())
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"maps"
 	"reflect"
 	"sort"
+	"sync"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	"go.opentelemetry.io/otel"
@@ -167,6 +168,12 @@ type TypeSystem struct {
 
 	modelID       string
 	schemaVersion string
+
+	nonDeterministicConditionsOnce sync.Once
+	// [conditionName] => the non-deterministic functions that the condition calls.
+	nonDeterministicConditions map[string][]string
+	// [objectType#relation] => whether the relation involves a non-deterministic condition.
+	nonDeterministicRelations sync.Map
 }
 
 // New creates a *TypeSystem from an *openfgav1.AuthorizationModel.
@@ -499,11 +506,64 @@ func (t *TypeSystem) RelationInvolvesExclusion(objectType, relation string) (boo
 	return t.relationInvolves(objectType, relation, visited, exclusionSetOperator)
 }
 
+// NonDeterministicConditions returns the conditions of the model that call non-deterministic functions, e.g.
+// 'now()', mapped to the names of those functions. The conditions that fail to compile are ignored.
+func (t *TypeSystem) NonDeterministicConditions() map[string][]string {
+	t.nonDeterministicConditionsOnce.Do(func() {
+		t.nonDeterministicConditions = map[string][]string{}
+		for name, c := range t.conditions {
+			functions, err := c.NonDeterministicFunctions()
+			if err != nil || len(functions) == 0 {
+				continue
+			}
+
+			t.nonDeterministicConditions[name] = functions
+		}
+	})
+
+	return t.nonDeterministicConditions
+}
+
+// RelationInvolvesNonDeterministicCondition returns true if any of the tuples that the provided relation may be
+// resolved through, directly or indirectly, can have a condition that calls a non-deterministic function. The
+// results of the Checks of such a relation depend on more than their inputs, so they must not be cached.
+func (t *TypeSystem) RelationInvolvesNonDeterministicCondition(objectType, relation string) (bool, error) {
+	if len(t.NonDeterministicConditions()) == 0 {
+		return false, nil
+	}
+
+	key := tuple.ToObjectRelationString(objectType, relation)
+	if involves, ok := t.nonDeterministicRelations.Load(key); ok {
+		return involves.(bool), nil
+	}
+
+	visited := map[string]struct{}{}
+	involves, err := t.relationInvolves(objectType, relation, visited, nonDeterministicCondition)
+	if err != nil {
+		return false, err
+	}
+
+	t.nonDeterministicRelations.Store(key, involves)
+	return involves, nil
+}
+
 const (
 	intersectionSetOperator uint = iota
 	exclusionSetOperator
+	nonDeterministicCondition
 )
 
+// isNonDeterministicTypeRestriction returns whether the tuples of the type restriction can have a
+// non-deterministic condition.
+func (t *TypeSystem) isNonDeterministicTypeRestriction(typeRestriction *openfgav1.RelationReference) bool {
+	if typeRestriction.GetCondition() == "" {
+		return false
+	}
+
+	_, ok := t.NonDeterministicConditions()[typeRestriction.GetCondition()]
+	return ok
+}
+
 func (t *TypeSystem) relationInvolves(objectType, relation string, visited map[string]struct{}, target uint) (bool, error) {
 	key := tuple.ToObjectRelationString(objectType, relation)
 	if _, ok := visited[key]; ok {
@@ -548,6 +608,10 @@ func (t *TypeSystem) relationInvolves(objectType, relation string, visited map[s
 
 			directlyRelatedTypes := tuplesetRel.GetTypeInfo().GetDirectlyRelatedUserTypes()
 			for _, relatedType := range directlyRelatedTypes {
+				if target == nonDeterministicCondition && t.isNonDeterministicTypeRestriction(relatedType) {
+					return true
+				}
+
 				// Must be of the form 'objectType' by this point since we disallow `tupleset` relations of the form `objectType:id#relation`.
 				r := relatedType.GetRelation()
 				if r != "" {
@@ -582,8 +646,15 @@ func (t *TypeSystem) relationInvolves(objectType, relation string, visited map[s
 
 			return nil
 		case *openfgav1.Userset_Intersection:
+			if target == nonDeterministicCondition {
+				// the conditions are in the operands
+				return nil
+			}
 			return target == intersectionSetOperator
 		case *openfgav1.Userset_Difference:
+			if target == nonDeterministicCondition {
+				return nil
+			}
 			return target == exclusionSetOperator
 		}
 
@@ -598,6 +669,10 @@ func (t *TypeSystem) relationInvolves(objectType, relation string, visited map[s
 	}
 
 	for _, typeRestriction := range rel.GetTypeInfo().GetDirectlyRelatedUserTypes() {
+		if target == nonDeterministicCondition && t.isNonDeterministicTypeRestriction(typeRestriction) {
+			return true, nil
+		}
+
 		if typeRestriction.GetRelation() != "" {
 			key := tuple.ToObjectRelationString(typeRestriction.GetType(), typeRestriction.GetRelation())
 			if _, ok := visited[key]; ok {
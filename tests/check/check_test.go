@@ -53,6 +53,8 @@ func testRunTestMatrix(t *testing.T, engine string, experimental bool) {
 		}
 		cfg.Log.Level = "error"
 		cfg.Datastore.Engine = engine
+		// the suite asserts that the Checks missing the parameters of a condition fail
+		cfg.CheckStrictConditionContext = true
 
 		tests.StartServer(t, cfg)
 
@@ -324,6 +326,8 @@ func testRunAll(t *testing.T, engine string) {
 	cfg.Experimentals = append(cfg.Experimentals, "enable-check-optimizations")
 	cfg.Log.Level = "error"
 	cfg.Datastore.Engine = engine
+	// the suite asserts that the Checks missing the parameters of a condition fail
+	cfg.CheckStrictConditionContext = true
 
 	tests.StartServer(t, cfg)
 
@@ -38,6 +38,15 @@ func newOpenFGAServerAndClient(t *testing.T) openfgav1.OpenFGAServiceClient {
 	return client
 }
 
+// createStore creates a store for tests that need one to exist.
+func createStore(t *testing.T, client openfgav1.OpenFGAServiceClient) string {
+	t.Helper()
+
+	resp, err := client.CreateStore(context.Background(), &openfgav1.CreateStoreRequest{Name: "openfga-test"})
+	require.NoError(t, err)
+	return resp.GetId()
+}
+
 func TestGRPCMaxMessageSize(t *testing.T) {
 	client := newOpenFGAServerAndClient(t)
 
@@ -680,6 +689,8 @@ func GRPCCheckTest(t *testing.T, client openfgav1.OpenFGAServiceClient) {
 		errorCode codes.Code
 	}
 
+	storeID := createStore(t, client)
+
 	tests := []struct {
 		name   string
 		input  *openfgav1.CheckRequest
@@ -802,7 +813,7 @@ func GRPCCheckTest(t *testing.T, client openfgav1.OpenFGAServiceClient) {
 		{
 			name: "model_not_found",
 			input: &openfgav1.CheckRequest{
-				StoreId:              ulid.Make().String(),
+				StoreId:              storeID,
 				AuthorizationModelId: ulid.Make().String(),
 				TupleKey: &openfgav1.CheckRequestTupleKey{
 					User:     "user:anne",
@@ -814,6 +825,21 @@ func GRPCCheckTest(t *testing.T, client openfgav1.OpenFGAServiceClient) {
 				errorCode: 2001, // ErrorCode_authorization_model_not_found
 			},
 		},
+		{
+			name: "store_not_found",
+			input: &openfgav1.CheckRequest{
+				StoreId:              ulid.Make().String(),
+				AuthorizationModelId: ulid.Make().String(),
+				TupleKey: &openfgav1.CheckRequestTupleKey{
+					User:     "user:anne",
+					Object:   "obj:1",
+					Relation: "relation",
+				},
+			},
+			output: output{
+				errorCode: codes.Code(openfgav1.NotFoundErrorCode_store_id_not_found),
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -1506,6 +1532,8 @@ func GRPCReadAuthorizationModelTest(t *testing.T, client openfgav1.OpenFGAServic
 		errorCode codes.Code
 	}
 
+	storeID := createStore(t, client)
+
 	tests := []struct {
 		name     string
 		input    *openfgav1.ReadAuthorizationModelRequest
@@ -1521,7 +1549,7 @@ func GRPCReadAuthorizationModelTest(t *testing.T, client openfgav1.OpenFGAServic
 					type user`,
 			},
 			input: &openfgav1.ReadAuthorizationModelRequest{
-				StoreId: ulid.Make().String(),
+				StoreId: storeID,
 				Id:      ulid.Make().String(),
 			},
 			output: output{
@@ -1601,7 +1629,7 @@ func GRPCReadAuthorizationModelTest(t *testing.T, client openfgav1.OpenFGAServic
 }
 
 func GRPCReadAuthorizationModelsTest(t *testing.T, client openfgav1.OpenFGAServiceClient) {
-	storeID := ulid.Make().String()
+	storeID := createStore(t, client)
 
 	_, err := client.WriteAuthorizationModel(context.Background(), &openfgav1.WriteAuthorizationModelRequest{
 		StoreId: storeID,
@@ -1809,6 +1837,8 @@ func GRPCWriteAssertionsTest(t *testing.T, client openfgav1.OpenFGAServiceClient
 		errorMessage string
 	}
 
+	storeID := createStore(t, client)
+
 	tests := []struct {
 		name     string
 		input    *openfgav1.WriteAssertionsRequest
@@ -1828,7 +1858,7 @@ func GRPCWriteAssertionsTest(t *testing.T, client openfgav1.OpenFGAServiceClient
 							define viewer: [user]`,
 			},
 			input: &openfgav1.WriteAssertionsRequest{
-				StoreId:              ulid.Make().String(),
+				StoreId:              storeID,
 				AuthorizationModelId: ulid.Make().String(),
 				Assertions: []*openfgav1.Assertion{
 					{Expectation: true, TupleKey: &openfgav1.AssertionTupleKey{
@@ -27,6 +27,8 @@ func testRunAll(t *testing.T, engine string) {
 	cfg.Experimentals = append(cfg.Experimentals, "enable-check-optimizations")
 	cfg.Log.Level = "error"
 	cfg.Datastore.Engine = engine
+	// the suite asserts that the ListObjects missing the parameters of a condition fail
+	cfg.CheckStrictConditionContext = true
 
 	tests.StartServer(t, cfg)
 